@@ -22,6 +22,7 @@ var args = map[string][]string{
 	"commit":                     {cloneRepository(defaultURL, tempFolder())},
 	"context":                    {defaultURL, tempFolder()},
 	"custom_http":                {defaultURL},
+	"describe":                   {cloneRepository(defaultURL, tempFolder())},
 	"find-if-any-tag-point-head": {cloneRepository(defaultURL, tempFolder())},
 	"ls":                         {cloneRepository(defaultURL, tempFolder()), "HEAD", "vendor"},
 	"ls-remote":                  {defaultURL},
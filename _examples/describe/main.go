@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	. "github.com/go-git/go-git/v5/_examples"
+)
+
+// Example of how to describe HEAD using the closest tag, as used to stamp
+// build versions.
+func main() {
+	CheckArgs("<path>")
+	path := os.Args[1]
+
+	// We instantiate a new repository targeting the given path (the .git folder)
+	r, err := git.PlainOpen(path)
+	CheckIfError(err)
+
+	// ... describes HEAD with any tag, falling back to the abbreviated hash
+	Info("git describe --tags --always --dirty")
+
+	name, err := r.Describe(&git.DescribeOptions{
+		Tags:   true,
+		Always: true,
+		Dirty:  true,
+	})
+	CheckIfError(err)
+
+	fmt.Println(name)
+}
@@ -1,19 +1,34 @@
 package git
 
 import (
+	"bufio"
 	"bytes"
-	"container/heap"
 	"errors"
 	"fmt"
-	"io"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/internal/path_util"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/utils/diff"
-	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var (
+	// ErrBlameReverseWithoutSince is returned by BlameWithOptions when
+	// Reverse is set without a Since commit.
+	ErrBlameReverseWithoutSince = errors.New("reverse blame requires a since commit")
+	// ErrInvalidLineRange is returned by BlameWithOptions when a range of
+	// lines is not in the file.
+	ErrInvalidLineRange = errors.New("invalid line range")
+	// ErrInvalidIgnoreRevs is returned by Worktree.BlameIgnoreRevs when a
+	// line of a file is not a commit hash.
+	ErrInvalidIgnoreRevs = errors.New("invalid object name in ignore revs file")
 )
 
 // BlameResult represents the result of a Blame operation.
@@ -22,101 +37,180 @@ type BlameResult struct {
 	Path string
 	// Rev (Revision) is the hash of the specified Commit used to generate this result.
 	Rev plumbing.Hash
-	// Lines contains every line with its authorship.
+	// Lines contains every line with its authorship, or only the lines of
+	// the blamed ranges if BlameOptions.Lines is given.
 	Lines []*Line
+	// Entries are the groups of consecutive lines blamed to the same
+	// commit, sorted by line.
+	Entries []*BlameEntry
+}
+
+// BlameEntry is a group of consecutive lines of the blamed file with the
+// same origin.
+type BlameEntry struct {
+	// Commit is the commit the lines are blamed to.
+	Commit *object.Commit
+	// Path is the path of the file in Commit, which differs from the path
+	// of the blamed file if it was renamed, or if the lines were moved or
+	// copied from another file.
+	Path string
+	// SourceLine is the number of the first line in the file of Commit,
+	// starting at 1.
+	SourceLine int
+	// FinalLine is the number of the first line in the blamed file,
+	// starting at 1.
+	FinalLine int
+	// NumLines is the number of lines.
+	NumLines int
+	// Boundary is true if Commit is a root commit, or a commit reachable
+	// from BlameOptions.Since.
+	Boundary bool
+	// Ignored is true if the lines were changed by an ignored commit, and
+	// blamed to the commit of the most similar lines of its parents.
+	Ignored bool
+	// Unblamable is true if the lines were changed by an ignored commit,
+	// but no similar lines were found in its parents.
+	Unblamable bool
+	// Previous is the first parent of Commit containing the file, nil if
+	// there is none, and PreviousPath the path of the file in it.
+	Previous     *object.Commit
+	PreviousPath string
 }
 
 // Blame returns a BlameResult with the information about the last author of
 // each line from file `path` at commit `c`.
 func Blame(c *object.Commit, path string) (*BlameResult, error) {
-	// The file to blame is identified by the input arguments:
-	// commit and path. commit is a Commit object obtained from a Repository. Path
-	// represents a path to a specific file contained in the repository.
-	//
-	// Blaming a file is done by walking the tree in reverse order trying to find where each line was last modified.
-	//
-	// When a diff is found it cannot immediately assume it came from that commit, as it may have come from 1 of its
-	// parents, so it will first try to resolve those diffs from its parents, if it couldn't find the change in its
-	// parents then it will assign the change to itself.
-	//
-	// When encountering 2 parents that have made the same change to a file it will choose the parent that was merged
-	// into the current branch first (this is determined by the order of the parents inside the commit).
-	//
-	// This currently works on a line by line basis, if performance becomes an issue it could be changed to work with
-	// hunks rather than lines. Then when encountering diff hunks it would need to split them where necessary.
-
-	b := new(blame)
-	b.fRev = c
-	b.path = path
-	b.q = new(priorityQueue)
-
-	file, err := b.fRev.File(path)
+	return BlameWithOptions(c, path, &BlameOptions{})
+}
+
+// BlameWithOptions returns a BlameResult with the information about the last
+// author of each line from file `path` at commit `c`, using the given options.
+//
+// The lines are blamed as `git blame` does: each commit passes the blame of
+// the lines it did not change to its parents, following the renames of the
+// file, and takes the blame of the remaining ones. If Reverse is set the
+// file blamed is the one of the Since commit, and the blame is passed to
+// the children of the commits instead of their parents, up to commit `c`.
+func BlameWithOptions(c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	if o == nil {
+		o = &BlameOptions{}
+	}
+
+	if o.Reverse && o.Since == nil {
+		return nil, ErrBlameReverseWithoutSince
+	}
+
+	sb, err := newBlameScoreboard(c, path, o)
 	if err != nil {
 		return nil, err
 	}
-	finalLines, err := file.Lines()
-	if err != nil {
+
+	if err := sb.assignBlame(); err != nil {
 		return nil, err
 	}
-	finalLength := len(finalLines)
 
-	needsMap := make([]lineMap, finalLength)
-	for i := range needsMap {
-		needsMap[i] = lineMap{i, i, nil, -1}
+	sb.sortAndCoalesce()
+
+	result := &BlameResult{Path: path, Rev: sb.final.Hash, Lines: make([]*Line, 0)}
+	for e := sb.ent; e != nil; e = e.next {
+		entry := sb.newBlameEntry(e)
+		result.Entries = append(result.Entries, entry)
+
+		for i := 0; i < e.numLines; i++ {
+			text := sb.finalLine(e.lno + i)
+			result.Lines = append(result.Lines, newLine(
+				entry.Commit.Author.Email, entry.Commit.Author.Name, text,
+				entry.Commit.Author.When, entry.Commit.Hash,
+			))
+		}
 	}
-	contents, err := file.Contents()
+
+	return result, nil
+}
+
+// BlameIgnoreRevs returns the commits to be ignored by a blame, listed in
+// the files of the `blame.ignoreRevsFile` config and in the given files,
+// as the `--ignore-revs-file` option of git blame. The relative paths are
+// relative to the root of the worktree, the absolute ones, and the ones
+// starting with '~', are read from the OS filesystem. Each line of the files
+// is a full commit hash, the comments starting with '#' and the empty lines
+// being skipped.
+func (w *Worktree) BlameIgnoreRevs(files ...string) ([]plumbing.Hash, error) {
+	cfg, err := w.r.ConfigScoped(config.SystemScope)
 	if err != nil {
 		return nil, err
 	}
-	b.q.Push(&queueItem{
-		nil,
-		nil,
-		c,
-		path,
-		contents,
-		needsMap,
-		0,
-		false,
-		0,
-	})
-	items := make([]*queueItem, 0)
-	for {
-		items = items[:0]
-		for {
-			if b.q.Len() == 0 {
-				return nil, errors.New("invalid state: no items left on the blame queue")
-			}
-			item := b.q.Pop()
-			items = append(items, item)
-			next := b.q.Peek()
-			if next == nil || next.Hash != item.Commit.Hash {
-				break
-			}
+
+	var paths []string
+	for _, p := range cfg.Raw.Section("blame").Options.GetAll("ignoreRevsFile") {
+		// an empty value resets the list of files
+		if p == "" {
+			paths = nil
+			continue
 		}
-		finished, err := b.addBlames(items)
+
+		paths = append(paths, p)
+	}
+
+	var revs []plumbing.Hash
+	seen := make(map[plumbing.Hash]bool)
+	for _, p := range append(paths, files...) {
+		hashes, err := w.readIgnoreRevs(p)
 		if err != nil {
 			return nil, err
 		}
-		if finished {
-			break
+
+		for _, h := range hashes {
+			if !seen[h] {
+				seen[h] = true
+				revs = append(revs, h)
+			}
 		}
 	}
 
-	b.lineToCommit = make([]*object.Commit, finalLength)
-	for i := range needsMap {
-		b.lineToCommit[i] = needsMap[i].Commit
+	return revs, nil
+}
+
+func (w *Worktree) readIgnoreRevs(path string) ([]plumbing.Hash, error) {
+	path, err := path_util.ReplaceTildeWithHome(path)
+	if err != nil {
+		return nil, err
 	}
 
-	lines, err := newLines(finalLines, b.lineToCommit)
+	// relative paths are relative to the worktree
+	var fs billy.Basic = w.Filesystem
+	if filepath.IsAbs(path) {
+		fs = osfs.Default
+	}
+
+	f, err := fs.Open(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return &BlameResult{
-		Path:  path,
-		Rev:   c.Hash,
-		Lines: lines,
-	}, nil
+	defer f.Close()
+
+	var hashes []plumbing.Hash
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !plumbing.IsHash(line) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidIgnoreRevs, line)
+		}
+
+		hashes = append(hashes, plumbing.NewHash(line))
+	}
+
+	return hashes, s.Err()
 }
 
 // Line values represent the contents and author of a line in BlamedResult values.
@@ -155,313 +249,47 @@ func newLines(contents []string, commits []*object.Commit) ([]*Line, error) {
 	return result, nil
 }
 
-// this struct is internally used by the blame function to hold its
-// inputs, outputs and state.
-type blame struct {
-	// the path of the file to blame
-	path string
-	// the commit of the final revision of the file to blame
-	fRev *object.Commit
-	// resolved lines
-	lineToCommit []*object.Commit
-	// queue of commits that need resolving
-	q *priorityQueue
-}
-
-type lineMap struct {
-	Orig, Cur    int
-	Commit       *object.Commit
-	FromParentNo int
-}
-
-func (b *blame) addBlames(curItems []*queueItem) (bool, error) {
-	curItem := curItems[0]
-
-	// Simple optimisation to merge paths, there is potential to go a bit further here and check for any duplicates
-	// not only if they are all the same.
-	if len(curItems) == 1 {
-		curItems = nil
-	} else if curItem.IdenticalToChild {
-		allSame := true
-		lenCurItems := len(curItems)
-		lowestParentNo := curItem.ParentNo
-		for i := 1; i < lenCurItems; i++ {
-			if !curItems[i].IdenticalToChild || curItem.Child != curItems[i].Child {
-				allSame = false
-				break
-			}
-			lowestParentNo = min(lowestParentNo, curItems[i].ParentNo)
-		}
-		if allSame {
-			curItem.Child.numParentsNeedResolving = curItem.Child.numParentsNeedResolving - lenCurItems + 1
-			curItems = nil // free the memory
-			curItem.ParentNo = lowestParentNo
-
-			// Now check if we can remove the parent completely
-			for curItem.Child.IdenticalToChild && curItem.Child.MergedChildren == nil && curItem.Child.numParentsNeedResolving == 1 {
-				oldChild := curItem.Child
-				curItem.Child = oldChild.Child
-				curItem.ParentNo = oldChild.ParentNo
-			}
-		}
-	}
-
-	// if we have more than 1 item for this commit, create a single needsMap
-	if len(curItems) > 1 {
-		curItem.MergedChildren = make([]childToNeedsMap, len(curItems))
-		for i, c := range curItems {
-			curItem.MergedChildren[i] = childToNeedsMap{c.Child, c.NeedsMap, c.IdenticalToChild, c.ParentNo}
-		}
-		newNeedsMap := make([]lineMap, 0, len(curItem.NeedsMap))
-		newNeedsMap = append(newNeedsMap, curItems[0].NeedsMap...)
-
-		for i := 1; i < len(curItems); i++ {
-			cur := curItems[i].NeedsMap
-			n := 0 // position in newNeedsMap
-			c := 0 // position in current list
-			for c < len(cur) {
-				if n == len(newNeedsMap) {
-					newNeedsMap = append(newNeedsMap, cur[c:]...)
-					break
-				} else if newNeedsMap[n].Cur == cur[c].Cur {
-					n++
-					c++
-				} else if newNeedsMap[n].Cur < cur[c].Cur {
-					n++
-				} else {
-					newNeedsMap = append(newNeedsMap, cur[c])
-					newPos := len(newNeedsMap) - 1
-					for newPos > n {
-						newNeedsMap[newPos-1], newNeedsMap[newPos] = newNeedsMap[newPos], newNeedsMap[newPos-1]
-						newPos--
-					}
-				}
-			}
-		}
-		curItem.NeedsMap = newNeedsMap
-		curItem.IdenticalToChild = false
-		curItem.Child = nil
-		curItems = nil // free the memory
-	}
-
-	parents, err := parentsContainingPath(curItem.path, curItem.Commit)
-	if err != nil {
-		return false, err
-	}
-
-	anyPushed := false
-	for parnetNo, prev := range parents {
-		currentHash, err := blobHash(curItem.path, curItem.Commit)
-		if err != nil {
-			return false, err
-		}
-		prevHash, err := blobHash(prev.Path, prev.Commit)
-		if err != nil {
-			return false, err
-		}
-		if currentHash == prevHash {
-			if len(parents) == 1 && curItem.MergedChildren == nil && curItem.IdenticalToChild {
-				// commit that has 1 parent and 1 child and is the same as both, bypass it completely
-				b.q.Push(&queueItem{
-					Child:            curItem.Child,
-					Commit:           prev.Commit,
-					path:             prev.Path,
-					Contents:         curItem.Contents,
-					NeedsMap:         curItem.NeedsMap, // reuse the NeedsMap as we are throwing away this item
-					IdenticalToChild: true,
-					ParentNo:         curItem.ParentNo,
-				})
-			} else {
-				b.q.Push(&queueItem{
-					Child:            curItem,
-					Commit:           prev.Commit,
-					path:             prev.Path,
-					Contents:         curItem.Contents,
-					NeedsMap:         append([]lineMap(nil), curItem.NeedsMap...), // create new slice and copy
-					IdenticalToChild: true,
-					ParentNo:         parnetNo,
-				})
-				curItem.numParentsNeedResolving++
-			}
-			anyPushed = true
-			continue
-		}
-
-		// get the contents of the file
-		file, err := prev.Commit.File(prev.Path)
-		if err != nil {
-			return false, err
-		}
-		prevContents, err := file.Contents()
-		if err != nil {
-			return false, err
-		}
-
-		hunks := diff.Do(prevContents, curItem.Contents)
-		prevl := -1
-		curl := -1
-		need := 0
-		getFromParent := make([]lineMap, 0)
-	out:
-		for h := range hunks {
-			hLines := countLines(hunks[h].Text)
-			for hl := 0; hl < hLines; hl++ {
-				switch hunks[h].Type {
-				case diffmatchpatch.DiffEqual:
-					prevl++
-					curl++
-					if curl == curItem.NeedsMap[need].Cur {
-						// add to needs
-						getFromParent = append(getFromParent, lineMap{curl, prevl, nil, -1})
-						// move to next need
-						need++
-						if need >= len(curItem.NeedsMap) {
-							break out
-						}
-					}
-				case diffmatchpatch.DiffInsert:
-					curl++
-					if curl == curItem.NeedsMap[need].Cur {
-						// the line we want is added, it may have been added here (or by another parent), skip it for now
-						need++
-						if need >= len(curItem.NeedsMap) {
-							break out
-						}
-					}
-				case diffmatchpatch.DiffDelete:
-					prevl += hLines
-					continue out
-				default:
-					return false, errors.New("invalid state: invalid hunk Type")
-				}
-			}
-		}
-
-		if len(getFromParent) > 0 {
-			b.q.Push(&queueItem{
-				curItem,
-				nil,
-				prev.Commit,
-				prev.Path,
-				prevContents,
-				getFromParent,
-				0,
-				false,
-				parnetNo,
-			})
-			curItem.numParentsNeedResolving++
-			anyPushed = true
-		}
-	}
-
-	curItem.Contents = "" // no longer need, free the memory
-
-	if !anyPushed {
-		return finishNeeds(curItem)
-	}
-
-	return false, nil
-}
-
-func finishNeeds(curItem *queueItem) (bool, error) {
-	// any needs left in the needsMap must have come from this revision
-	for i := range curItem.NeedsMap {
-		if curItem.NeedsMap[i].Commit == nil {
-			curItem.NeedsMap[i].Commit = curItem.Commit
-			curItem.NeedsMap[i].FromParentNo = -1
-		}
-	}
-
-	if curItem.Child == nil && curItem.MergedChildren == nil {
-		return true, nil
-	}
-
-	if curItem.MergedChildren == nil {
-		return applyNeeds(curItem.Child, curItem.NeedsMap, curItem.IdenticalToChild, curItem.ParentNo)
-	}
-
-	for _, ctn := range curItem.MergedChildren {
-		m := 0 // position in merged needs map
-		p := 0 // position in parent needs map
-		for p < len(ctn.NeedsMap) {
-			if ctn.NeedsMap[p].Cur == curItem.NeedsMap[m].Cur {
-				ctn.NeedsMap[p].Commit = curItem.NeedsMap[m].Commit
-				m++
-				p++
-			} else if ctn.NeedsMap[p].Cur < curItem.NeedsMap[m].Cur {
-				p++
-			} else {
-				m++
-			}
-		}
-		finished, err := applyNeeds(ctn.Child, ctn.NeedsMap, ctn.IdenticalToChild, ctn.ParentNo)
-		if finished || err != nil {
-			return finished, err
-		}
-	}
-
-	return false, nil
-}
-
-func applyNeeds(child *queueItem, needsMap []lineMap, identicalToChild bool, parentNo int) (bool, error) {
-	if identicalToChild {
-		for i := range child.NeedsMap {
-			l := &child.NeedsMap[i]
-			if l.Cur != needsMap[i].Cur || l.Orig != needsMap[i].Orig {
-				return false, errors.New("needsMap isn't the same? Why not??")
-			}
-			if l.Commit == nil || parentNo < l.FromParentNo {
-				l.Commit = needsMap[i].Commit
-				l.FromParentNo = parentNo
-			}
-		}
-	} else {
-		i := 0
-	out:
-		for j := range child.NeedsMap {
-			l := &child.NeedsMap[j]
-			for needsMap[i].Orig < l.Cur {
-				i++
-				if i == len(needsMap) {
-					break out
-				}
-			}
-			if l.Cur == needsMap[i].Orig {
-				if l.Commit == nil || parentNo < l.FromParentNo {
-					l.Commit = needsMap[i].Commit
-					l.FromParentNo = parentNo
-				}
-			}
-		}
-	}
-	child.numParentsNeedResolving--
-	if child.numParentsNeedResolving == 0 {
-		finished, err := finishNeeds(child)
-		if finished || err != nil {
-			return finished, err
-		}
-	}
-
-	return false, nil
-}
-
 // String prints the results of a Blame using git-blame's style.
 func (b BlameResult) String() string {
 	var buf bytes.Buffer
 
+	numbers := b.lineNumbers()
 	// max line number length
-	mlnl := len(strconv.Itoa(len(b.Lines)))
+	mlnl := 0
+	if len(numbers) > 0 {
+		mlnl = len(strconv.Itoa(numbers[len(numbers)-1]))
+	}
 	// max author length
 	mal := b.maxAuthorLength()
 	format := fmt.Sprintf("%%s (%%-%ds %%s %%%dd) %%s\n", mal, mlnl)
 
 	for ln := range b.Lines {
 		_, _ = fmt.Fprintf(&buf, format, b.Lines[ln].Hash.String()[:8],
-			b.Lines[ln].AuthorName, b.Lines[ln].Date.Format("2006-01-02 15:04:05 -0700"), ln+1, b.Lines[ln].Text)
+			b.Lines[ln].AuthorName, b.Lines[ln].Date.Format("2006-01-02 15:04:05 -0700"), numbers[ln], b.Lines[ln].Text)
 	}
 	return buf.String()
 }
 
+// lineNumbers returns the numbers of the lines in the blamed file, given by
+// the entries if the blame is limited to ranges of lines.
+func (b BlameResult) lineNumbers() []int {
+	numbers := make([]int, 0, len(b.Lines))
+	for _, e := range b.Entries {
+		for i := 0; i < e.NumLines; i++ {
+			numbers = append(numbers, e.FinalLine+i)
+		}
+	}
+
+	if len(numbers) != len(b.Lines) {
+		numbers = numbers[:0]
+		for ln := range b.Lines {
+			numbers = append(numbers, ln+1)
+		}
+	}
+
+	return numbers
+}
+
 // utility function to calculate the number of runes needed
 // to print the longest author name in the blame of a file.
 func (b BlameResult) maxAuthorLength() int {
@@ -485,103 +313,3 @@ func max(a, b int) int {
 	}
 	return b
 }
-
-type childToNeedsMap struct {
-	Child            *queueItem
-	NeedsMap         []lineMap
-	IdenticalToChild bool
-	ParentNo         int
-}
-
-type queueItem struct {
-	Child                   *queueItem
-	MergedChildren          []childToNeedsMap
-	Commit                  *object.Commit
-	path                    string
-	Contents                string
-	NeedsMap                []lineMap
-	numParentsNeedResolving int
-	IdenticalToChild        bool
-	ParentNo                int
-}
-
-type priorityQueueImp []*queueItem
-
-func (pq *priorityQueueImp) Len() int { return len(*pq) }
-func (pq *priorityQueueImp) Less(i, j int) bool {
-	return !(*pq)[i].Commit.Less((*pq)[j].Commit)
-}
-func (pq *priorityQueueImp) Swap(i, j int) { (*pq)[i], (*pq)[j] = (*pq)[j], (*pq)[i] }
-func (pq *priorityQueueImp) Push(x any)    { *pq = append(*pq, x.(*queueItem)) }
-func (pq *priorityQueueImp) Pop() any {
-	n := len(*pq)
-	ret := (*pq)[n-1]
-	(*pq)[n-1] = nil // ovoid memory leak
-	*pq = (*pq)[0 : n-1]
-
-	return ret
-}
-func (pq *priorityQueueImp) Peek() *object.Commit {
-	if len(*pq) == 0 {
-		return nil
-	}
-	return (*pq)[0].Commit
-}
-
-type priorityQueue priorityQueueImp
-
-func (pq *priorityQueue) Init()    { heap.Init((*priorityQueueImp)(pq)) }
-func (pq *priorityQueue) Len() int { return (*priorityQueueImp)(pq).Len() }
-func (pq *priorityQueue) Push(c *queueItem) {
-	heap.Push((*priorityQueueImp)(pq), c)
-}
-func (pq *priorityQueue) Pop() *queueItem {
-	return heap.Pop((*priorityQueueImp)(pq)).(*queueItem)
-}
-func (pq *priorityQueue) Peek() *object.Commit { return (*priorityQueueImp)(pq).Peek() }
-
-type parentCommit struct {
-	Commit *object.Commit
-	Path   string
-}
-
-func parentsContainingPath(path string, c *object.Commit) ([]parentCommit, error) {
-	// TODO: benchmark this method making git.object.Commit.parent public instead of using
-	// an iterator
-	var result []parentCommit
-	iter := c.Parents()
-	for {
-		parent, err := iter.Next()
-		if err == io.EOF {
-			return result, nil
-		}
-		if err != nil {
-			return nil, err
-		}
-		if _, err := parent.File(path); err == nil {
-			result = append(result, parentCommit{parent, path})
-		} else {
-			// look for renames
-			patch, err := parent.Patch(c)
-			if err != nil {
-				return nil, err
-			} else if patch != nil {
-				for _, fp := range patch.FilePatches() {
-					from, to := fp.Files()
-					if from != nil && to != nil && to.Path() == path {
-						result = append(result, parentCommit{parent, from.Path()})
-						break
-					}
-				}
-			}
-		}
-	}
-}
-
-func blobHash(path string, commit *object.Commit) (plumbing.Hash, error) {
-	file, err := commit.File(path)
-	if err != nil {
-		return plumbing.ZeroHash, err
-	}
-	return file.Hash, nil
-}
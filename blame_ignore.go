@@ -0,0 +1,335 @@
+package git
+
+// The lines changed by an ignored commit are matched with the most similar
+// lines of its parents, as git does. The similarity of two lines is given
+// by the pairs of characters they have in common. See
+// https://github.com/git/git/blob/v2.39.0/blame.c#L323.
+
+const (
+	certainNothingMatches  = -2
+	certaintyNotCalculated = -1
+	// maxSearchDistance is the maximum distance between the lines of the
+	// parent compared with a line, and the line of the parent at the same
+	// relative position.
+	maxSearchDistance = 10
+	// fingerprintFileThreshold is the minimum similarity of the line of the
+	// parent a line not matched in the changed lines is blamed to.
+	fingerprintFileThreshold = 10
+)
+
+// fingerprint is the multiset of the pairs of characters of a line, the
+// letters being lowercased and the whitespaces normalized.
+type fingerprint map[uint32]int
+
+// newFingerprint returns the fingerprint of the line, its end of line
+// included.
+func newFingerprint(line string) fingerprint {
+	f := make(fingerprint)
+	var c0 uint32
+	for i := 0; i <= len(line); i++ {
+		var c1 uint32
+		if i < len(line) && !isBlameSpace(line[i]) {
+			c1 = uint32(toLowerASCII(line[i]))
+		}
+
+		h := c0 | c1<<8
+		c0 = c1
+		// the pairs of whitespaces are ignored
+		if h == 0 {
+			continue
+		}
+
+		f[h]++
+	}
+
+	return f
+}
+
+func isBlameSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func toLowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+// lineFingerprints returns the fingerprints of the lines of contents.
+func lineFingerprints(contents string, lineStarts []int) []fingerprint {
+	fingerprints := make([]fingerprint, len(lineStarts)-1)
+	for i := range fingerprints {
+		fingerprints[i] = newFingerprint(contents[lineStarts[i]:lineStarts[i+1]])
+	}
+
+	return fingerprints
+}
+
+// similarity returns the number of pairs of characters in common.
+func (f fingerprint) similarity(other fingerprint) int {
+	var n int
+	for h, count := range other {
+		n += min(f[h], count)
+	}
+
+	return n
+}
+
+// subtract removes the pairs of characters of other.
+func (f fingerprint) subtract(other fingerprint) {
+	for h, count := range other {
+		c, ok := f[h]
+		if !ok {
+			continue
+		}
+
+		if c <= count {
+			delete(f, h)
+		} else {
+			f[h] = c - count
+		}
+	}
+}
+
+// blameLineTracker is the line a line changed by an ignored commit is
+// blamed to, in the parent or in the target.
+type blameLineTracker struct {
+	isParent bool
+	sLno     int
+}
+
+// guessLineBlames returns the lines of the parent matched with the lines of
+// the target from tlno to same, changed from parentLen lines of the parent.
+// The lines not matched with the changed lines of the parent, the added
+// lines included, are matched with the most similar line of the whole
+// parent, and kept in the target if none is similar enough.
+func guessLineBlames(parent, target *blameOrigin, tlno, offset, same, parentLen int) []blameLineTracker {
+	lineBlames := make([]blameLineTracker, same-tlno)
+	matches := fuzzyFindMatchingLines(parent, target, tlno, tlno+offset, same, parentLen)
+	for i := range lineBlames {
+		best := -1
+		if matches != nil && matches[i] >= 0 {
+			best = matches[i]
+		} else {
+			best = mostSimilarLine(parent, target.fingerprints[tlno+i], tlno+i)
+		}
+
+		if best >= 0 {
+			lineBlames[i] = blameLineTracker{isParent: true, sLno: best}
+		} else {
+			lineBlames[i] = blameLineTracker{sLno: tlno + i}
+		}
+	}
+
+	return lineBlames
+}
+
+// mostSimilarLine returns the line of the parent most similar to the line
+// of the target, the closest to it being chosen among the equally similar
+// ones, or -1 if none is similar enough.
+func mostSimilarLine(parent *blameOrigin, f fingerprint, line int) int {
+	best, bestSimilarity := -1, fingerprintFileThreshold
+	for i, pf := range parent.fingerprints {
+		similarity := pf.similarity(f)
+		if similarity < bestSimilarity {
+			continue
+		}
+
+		if similarity == bestSimilarity && best != -1 && abs(best-line) < abs(i-line) {
+			continue
+		}
+
+		best, bestSimilarity = i, similarity
+	}
+
+	return best
+}
+
+// lineNumberMapping maps the lines of the target to the lines of the parent
+// at the same relative position.
+type lineNumberMapping struct {
+	destinationStart, destinationLength int
+	sourceStart, sourceLength           int
+}
+
+func (m *lineNumberMapping) mapLine(line int) int {
+	return ((line-m.sourceStart)*2+1)*m.destinationLength/(m.sourceLength*2) + m.destinationStart
+}
+
+// fuzzyMatcher finds the lines of the parent, A, most similar to the lines
+// of the target, B.
+type fuzzyMatcher struct {
+	fingerprintsA, fingerprintsB []fingerprint
+	// similarities are the similarities of each line of B with the lines
+	// of A around its mapped line, -1 if not calculated.
+	similarities []int
+	// certainties are how strongly the lines of B are matched.
+	certainties []int
+	// result and secondBestResult are the best and second best matches of
+	// the lines of B, -1 if none.
+	result, secondBestResult []int
+
+	maxSearchDistanceA, maxSearchDistanceB int
+	mapping                                *lineNumberMapping
+}
+
+// fuzzyFindMatchingLines returns the lines of the parent matched with the
+// lines of the target from tlno to same, -1 for the lines not matched, or
+// nil if the parent lines are empty.
+func fuzzyFindMatchingLines(parent, target *blameOrigin, tlno, parentSlno, same, parentLen int) []int {
+	startA, lengthA := parentSlno, parentLen
+	startB, lengthB := tlno, same-tlno
+	if lengthA <= 0 {
+		return nil
+	}
+
+	m := &fuzzyMatcher{
+		fingerprintsA:      parent.fingerprints,
+		fingerprintsB:      target.fingerprints,
+		maxSearchDistanceA: min(maxSearchDistance, lengthA-1),
+		mapping: &lineNumberMapping{
+			destinationStart: startA, destinationLength: lengthA,
+			sourceStart: startB, sourceLength: lengthB,
+		},
+		result:           make([]int, lengthB),
+		secondBestResult: make([]int, lengthB),
+		certainties:      make([]int, lengthB),
+	}
+
+	m.maxSearchDistanceB = ((2*m.maxSearchDistanceA+1)*lengthB - 1) / lengthA
+	m.similarities = make([]int, lengthB*(m.maxSearchDistanceA*2+1))
+	for i := range m.result {
+		m.result[i] = -1
+		m.secondBestResult[i] = -1
+		m.certainties[i] = certaintyNotCalculated
+	}
+
+	for i := range m.similarities {
+		m.similarities[i] = -1
+	}
+
+	m.recurse(startA, startB, lengthA, lengthB, 0)
+	return m.result
+}
+
+// similarity returns the index in similarities of the similarity of the
+// line of A with the line of B, localB being relative to the lines of B
+// of the current recursion starting at offsetB.
+func (m *fuzzyMatcher) similarity(lineA, localB, closestA, offsetB int) *int {
+	return &m.similarities[lineA-closestA+m.maxSearchDistanceA+(offsetB+localB)*(m.maxSearchDistanceA*2+1)]
+}
+
+// findBestLineMatches computes the best matches of the line localB, and the
+// certainty of the best one.
+func (m *fuzzyMatcher) findBestLineMatches(startA, lengthA, startB, localB, offsetB int) {
+	b := offsetB + localB
+	if m.certainties[b] != certaintyNotCalculated {
+		return
+	}
+
+	closestLocalA := m.mapping.mapLine(localB+startB) - startA
+	searchStart := max(closestLocalA-m.maxSearchDistanceA, 0)
+	searchEnd := min(closestLocalA+m.maxSearchDistanceA+1, lengthA)
+
+	var best, secondBest, bestIndex, secondBestIndex int
+	for i := searchStart; i < searchEnd; i++ {
+		similarity := m.similarity(i, localB, closestLocalA, offsetB)
+		if *similarity == -1 {
+			// the distance to the closest line is a tie break between the
+			// lines equally similar
+			*similarity = m.fingerprintsB[startB+localB].similarity(m.fingerprintsA[startA+i]) *
+				(1000 - abs(i-closestLocalA))
+		}
+
+		if *similarity > best {
+			secondBest, secondBestIndex = best, bestIndex
+			best, bestIndex = *similarity, i
+		} else if *similarity > secondBest {
+			secondBest, secondBestIndex = *similarity, i
+		}
+	}
+
+	if best == 0 {
+		m.certainties[b] = certainNothingMatches
+		m.result[b] = -1
+		return
+	}
+
+	// a line matching two lines is less certain, but a line matching two
+	// lines very well is more certain than a line matching one line poorly
+	m.certainties[b] = best*2 - secondBest
+	m.result[b] = startA + bestIndex
+	m.secondBestResult[b] = startA + secondBestIndex
+}
+
+// recurse matches the line of B matched with the most certainty, and
+// the lines before and after it with the lines of A before and after its
+// match, so the lines keep their order.
+func (m *fuzzyMatcher) recurse(startA, startB, lengthA, lengthB, offsetB int) {
+	mostCertainLocalB, mostCertainCertainty := -1, -1
+	for i := 0; i < lengthB; i++ {
+		m.findBestLineMatches(startA, lengthA, startB, i, offsetB)
+		if m.certainties[offsetB+i] > mostCertainCertainty {
+			mostCertainCertainty = m.certainties[offsetB+i]
+			mostCertainLocalB = i
+		}
+	}
+
+	if mostCertainLocalB == -1 {
+		return
+	}
+
+	mostCertainA := m.result[offsetB+mostCertainLocalB]
+
+	// the other lines of B can't match the same parts of the line of A
+	m.fingerprintsA[mostCertainA].subtract(m.fingerprintsB[startB+mostCertainLocalB])
+
+	invalidateMin := max(mostCertainLocalB-m.maxSearchDistanceB, 0)
+	invalidateMax := min(mostCertainLocalB+m.maxSearchDistanceB+1, lengthB)
+
+	// the similarities with the line of A are calculated again
+	for i := invalidateMin; i < invalidateMax; i++ {
+		closestLocalA := m.mapping.mapLine(i+startB) - startA
+		if abs(mostCertainA-startA-closestLocalA) > m.maxSearchDistanceA {
+			continue
+		}
+
+		*m.similarity(mostCertainA-startA, i, closestLocalA, offsetB) = -1
+	}
+
+	// the matches contradicting the order of the lines are discarded
+	for i := mostCertainLocalB - 1; i >= invalidateMin; i-- {
+		b := offsetB + i
+		if m.certainties[b] >= 0 && (m.result[b] >= mostCertainA || m.secondBestResult[b] >= mostCertainA) {
+			m.certainties[b] = certaintyNotCalculated
+		}
+	}
+
+	for i := mostCertainLocalB + 1; i < invalidateMax; i++ {
+		b := offsetB + i
+		if m.certainties[b] >= 0 && (m.result[b] <= mostCertainA || m.secondBestResult[b] <= mostCertainA) {
+			m.certainties[b] = certaintyNotCalculated
+		}
+	}
+
+	if mostCertainLocalB > 0 {
+		m.recurse(startA, startB, mostCertainA+1-startA, mostCertainLocalB, offsetB)
+	}
+
+	if mostCertainLocalB+1 < lengthB {
+		secondStartA := mostCertainA
+		secondOffsetB := mostCertainLocalB + 1
+		m.recurse(secondStartA, startB+secondOffsetB,
+			lengthA+startA-secondStartA, lengthB-secondOffsetB, offsetB+secondOffsetB)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -0,0 +1,119 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Porcelain returns the blame in the format of `git blame --porcelain`,
+// meant to be parsed by tools. Each line is preceded by a header with the
+// hash of its commit and its line numbers, the information of each commit
+// being written before its first line.
+func (b BlameResult) Porcelain() string {
+	var sb strings.Builder
+
+	// the filename is written for each group of lines of the commits whose
+	// lines come from several files
+	paths := make(map[plumbing.Hash]string)
+	morePaths := make(map[plumbing.Hash]bool)
+	for _, e := range b.Entries {
+		if p, ok := paths[e.Commit.Hash]; ok && p != e.Path {
+			morePaths[e.Commit.Hash] = true
+		}
+
+		paths[e.Commit.Hash] = e.Path
+	}
+
+	shown := make(map[plumbing.Hash]bool)
+	line := 0
+	for _, e := range b.Entries {
+		fmt.Fprintf(&sb, "%s %d %d %d\n", e.Commit.Hash, e.SourceLine, e.FinalLine, e.NumLines)
+		if !shown[e.Commit.Hash] || morePaths[e.Commit.Hash] {
+			if !shown[e.Commit.Hash] {
+				writeBlameCommitInfo(&sb, e)
+				shown[e.Commit.Hash] = true
+			}
+
+			writeBlameFilename(&sb, e)
+		}
+
+		for i := 0; i < e.NumLines && line < len(b.Lines); i++ {
+			if i > 0 {
+				fmt.Fprintf(&sb, "%s %d %d\n", e.Commit.Hash, e.SourceLine+i, e.FinalLine+i)
+			}
+
+			fmt.Fprintf(&sb, "\t%s\n", b.Lines[line].Text)
+			line++
+		}
+	}
+
+	return sb.String()
+}
+
+// BlameIncrementalEncoder writes the groups of lines of a blame as they
+// are found, in the format of `git blame --incremental`.
+type BlameIncrementalEncoder struct {
+	w     io.Writer
+	shown map[plumbing.Hash]bool
+}
+
+// NewBlameIncrementalEncoder returns a BlameIncrementalEncoder writing to
+// w, its Encode method being meant to be used as BlameOptions.Incremental.
+func NewBlameIncrementalEncoder(w io.Writer) *BlameIncrementalEncoder {
+	return &BlameIncrementalEncoder{w: w, shown: make(map[plumbing.Hash]bool)}
+}
+
+// Encode writes the group of lines, the information of its commit being
+// written the first time it is seen.
+func (e *BlameIncrementalEncoder) Encode(entry *BlameEntry) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %d %d %d\n", entry.Commit.Hash, entry.SourceLine, entry.FinalLine, entry.NumLines)
+	if !e.shown[entry.Commit.Hash] {
+		writeBlameCommitInfo(&sb, entry)
+		e.shown[entry.Commit.Hash] = true
+	}
+
+	writeBlameFilename(&sb, entry)
+	_, err := io.WriteString(e.w, sb.String())
+	return err
+}
+
+func writeBlameCommitInfo(sb *strings.Builder, e *BlameEntry) {
+	c := e.Commit
+	fmt.Fprintf(sb, "author %s\n", c.Author.Name)
+	fmt.Fprintf(sb, "author-mail <%s>\n", c.Author.Email)
+	fmt.Fprintf(sb, "author-time %d\n", c.Author.When.Unix())
+	fmt.Fprintf(sb, "author-tz %s\n", c.Author.When.Format("-0700"))
+	fmt.Fprintf(sb, "committer %s\n", c.Committer.Name)
+	fmt.Fprintf(sb, "committer-mail <%s>\n", c.Committer.Email)
+	fmt.Fprintf(sb, "committer-time %d\n", c.Committer.When.Unix())
+	fmt.Fprintf(sb, "committer-tz %s\n", c.Committer.When.Format("-0700"))
+	fmt.Fprintf(sb, "summary %s\n", blameSummary(c))
+	if e.Boundary {
+		sb.WriteString("boundary\n")
+	}
+}
+
+func writeBlameFilename(sb *strings.Builder, e *BlameEntry) {
+	if e.Previous != nil {
+		fmt.Fprintf(sb, "previous %s %s\n", e.Previous.Hash, e.PreviousPath)
+	}
+
+	fmt.Fprintf(sb, "filename %s\n", e.Path)
+}
+
+// blameSummary returns the first non-blank line of the message of the
+// commit, or its hash in parentheses if there is none.
+func blameSummary(c *object.Commit) string {
+	for _, line := range strings.Split(c.Message, "\n") {
+		if strings.TrimSpace(line) != "" {
+			return line
+		}
+	}
+
+	return "(" + c.Hash.String() + ")"
+}
@@ -0,0 +1,1411 @@
+package git
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// The blame is computed as git does, with a scoreboard of the groups of
+// lines of the final file and the origins they are suspected to come from.
+// Each commit passes the blame of the lines it did not change to its
+// parents, and takes the blame of the remaining ones. See
+// https://github.com/git/git/blob/v2.39.0/blame.c.
+
+const (
+	defaultBlameMoveScore = 20
+	defaultBlameCopyScore = 40
+	// blameRenameScore is the minimum similarity of a renamed file, the
+	// default one of git.
+	blameRenameScore = 50
+)
+
+// blameOrigin is a file of a commit the lines of the final file may come
+// from.
+type blameOrigin struct {
+	// id is the creation order of the origin, used to sort the entries by
+	// suspect.
+	id     int
+	commit *object.Commit
+	path   string
+	blob   plumbing.Hash
+	mode   filemode.FileMode
+	// next is the next origin of the same commit.
+	next *blameOrigin
+	// suspects are the entries suspected to come from the origin, sorted
+	// by their line in the origin.
+	suspects *blameEntry
+	// previous is the origin of the first parent the blame was passed to.
+	previous *blameOrigin
+	guilty   bool
+
+	loaded       bool
+	contents     string
+	lineStarts   []int
+	fingerprints []fingerprint
+}
+
+// blameEntry is a group of consecutive lines of the final file, suspected
+// to come from the lines starting at sLno in its suspect.
+type blameEntry struct {
+	next *blameEntry
+	// lno is the first line in the final file, starting at 0.
+	lno      int
+	numLines int
+	suspect  *blameOrigin
+	// sLno is the first line in the file of the suspect, starting at 0.
+	sLno int
+	// score is the number of alphanumeric characters of the lines plus
+	// one, 0 if not computed yet.
+	score      int
+	ignored    bool
+	unblamable bool
+}
+
+type blameScoreboard struct {
+	o      *BlameOptions
+	final  *object.Commit
+	path   string
+	tree   *object.Tree
+	trees  map[plumbing.Hash]*object.Tree
+	origin *blameOrigin
+
+	finalBuf        string
+	finalLineStarts []int
+
+	// ent are the entries whose origin is found.
+	ent     *blameEntry
+	commits *blameQueue
+	origins map[plumbing.Hash]*blameOrigin
+	ids     int
+
+	moveScore, copyScore int
+	ignore               map[plumbing.Hash]bool
+	// uninteresting are the commits reachable from the Since commit.
+	uninteresting map[plumbing.Hash]bool
+	// children are the children of the commits in the range, for a
+	// reverse blame.
+	children map[plumbing.Hash][]*object.Commit
+}
+
+func newBlameScoreboard(c *object.Commit, path string, o *BlameOptions) (*blameScoreboard, error) {
+	sb := &blameScoreboard{
+		o:         o,
+		path:      path,
+		trees:     make(map[plumbing.Hash]*object.Tree),
+		commits:   &blameQueue{reverse: o.Reverse},
+		origins:   make(map[plumbing.Hash]*blameOrigin),
+		moveScore: o.MoveScore,
+		copyScore: o.CopyScore,
+		ignore:    make(map[plumbing.Hash]bool),
+	}
+
+	if sb.moveScore == 0 {
+		sb.moveScore = defaultBlameMoveScore
+	}
+
+	if sb.copyScore == 0 {
+		sb.copyScore = defaultBlameCopyScore
+	}
+
+	for _, h := range o.IgnoreRevs {
+		sb.ignore[h] = true
+	}
+
+	sb.final = c
+	if o.Since != nil {
+		var err error
+		if sb.uninteresting, err = reachableCommits(o.Since); err != nil {
+			return nil, err
+		}
+
+		if o.Reverse {
+			sb.final = o.Since
+			if sb.children, err = rangeChildren(c, sb.uninteresting); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	file, err := sb.final.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if sb.tree, err = sb.commitTree(sb.final); err != nil {
+		return nil, err
+	}
+
+	sb.origin = sb.getOrigin(sb.final, path)
+	sb.origin.blob = file.Hash
+	sb.origin.mode = file.Mode
+	if err := sb.fill(sb.origin, false); err != nil {
+		return nil, err
+	}
+
+	sb.finalBuf = sb.origin.contents
+	sb.finalLineStarts = sb.origin.lineStarts
+
+	ranges, err := blameRanges(o.Lines, len(sb.finalLineStarts)-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var ent *blameEntry
+	for i := len(ranges) - 1; i >= 0; i-- {
+		ent = &blameEntry{
+			next:     ent,
+			lno:      ranges[i][0],
+			sLno:     ranges[i][0],
+			numLines: ranges[i][1] - ranges[i][0],
+			suspect:  sb.origin,
+		}
+	}
+
+	sb.origin.suspects = ent
+	sb.commits.put(sb.final)
+	return sb, nil
+}
+
+// blameRanges returns the given ranges of lines as sorted and merged ranges
+// of lines starting at 0, the end being excluded.
+func blameRanges(lines []BlameLineRange, numLines int) ([][2]int, error) {
+	if len(lines) == 0 {
+		if numLines == 0 {
+			return nil, nil
+		}
+
+		return [][2]int{{0, numLines}}, nil
+	}
+
+	var ranges [][2]int
+	for _, l := range lines {
+		start, end := l.Start, l.End
+		if end == 0 {
+			end = max(start, numLines)
+		}
+
+		if end < start {
+			start, end = end, start
+		}
+
+		if start < 1 || start > numLines {
+			return nil, ErrInvalidLineRange
+		}
+
+		ranges = append(ranges, [2]int{start - 1, min(end, numLines)})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1] {
+			last[1] = max(last[1], r[1])
+			continue
+		}
+
+		merged = append(merged, r)
+	}
+
+	return merged, nil
+}
+
+// reachableCommits returns the commits reachable from c, c included.
+func reachableCommits(c *object.Commit) (map[plumbing.Hash]bool, error) {
+	seen := make(map[plumbing.Hash]bool)
+	err := object.NewCommitPreorderIter(c, nil, nil).ForEach(func(commit *object.Commit) error {
+		seen[commit.Hash] = true
+		return nil
+	})
+
+	return seen, err
+}
+
+// rangeChildren returns the children of the commits reachable from c and
+// not reachable from the boundary, sorted by date.
+func rangeChildren(c *object.Commit, boundary map[plumbing.Hash]bool) (map[plumbing.Hash][]*object.Commit, error) {
+	children := make(map[plumbing.Hash][]*object.Commit)
+	if boundary[c.Hash] {
+		return children, nil
+	}
+
+	err := object.NewCommitPreorderIter(c, boundary, nil).ForEach(func(commit *object.Commit) error {
+		return commit.Parents().ForEach(func(p *object.Commit) error {
+			children[p.Hash] = append(children[p.Hash], commit)
+			return nil
+		})
+	})
+
+	for _, list := range children {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Committer.When.Before(list[j].Committer.When)
+		})
+	}
+
+	return children, err
+}
+
+func (sb *blameScoreboard) commitTree(c *object.Commit) (*object.Tree, error) {
+	if t, ok := sb.trees[c.Hash]; ok {
+		return t, nil
+	}
+
+	t, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	sb.trees[c.Hash] = t
+	return t, nil
+}
+
+// getOrigin returns the origin of the path in the commit, moved to the
+// front of the origins of the commit, or a new one.
+func (sb *blameScoreboard) getOrigin(c *object.Commit, path string) *blameOrigin {
+	var l *blameOrigin
+	for o := sb.origins[c.Hash]; o != nil; l, o = o, o.next {
+		if o.path == path {
+			if l != nil {
+				l.next = o.next
+				o.next = sb.origins[c.Hash]
+				sb.origins[c.Hash] = o
+			}
+
+			return o
+		}
+	}
+
+	sb.ids++
+	o := &blameOrigin{id: sb.ids, commit: c, path: path, next: sb.origins[c.Hash]}
+	sb.origins[c.Hash] = o
+	return o
+}
+
+// fill reads the contents of the origin, and the fingerprints of its
+// lines if requested.
+func (sb *blameScoreboard) fill(o *blameOrigin, fingerprints bool) error {
+	if !o.loaded {
+		f, err := sb.tree.TreeEntryFile(&object.TreeEntry{Name: o.path, Mode: o.mode, Hash: o.blob})
+		if err != nil {
+			return err
+		}
+
+		if o.contents, err = f.Contents(); err != nil {
+			return err
+		}
+
+		o.lineStarts = lineStarts(o.contents)
+		o.loaded = true
+	}
+
+	if fingerprints && o.fingerprints == nil {
+		o.fingerprints = lineFingerprints(o.contents, o.lineStarts)
+	}
+
+	return nil
+}
+
+// drop frees the contents of the origin, read again if needed.
+func (o *blameOrigin) drop() {
+	o.loaded = false
+	o.contents = ""
+	o.lineStarts = nil
+	o.fingerprints = nil
+}
+
+// lineStarts returns the offsets of the lines of s, followed by the length
+// of s.
+func lineStarts(s string) []int {
+	var starts []int
+	for i := 0; i < len(s); {
+		starts = append(starts, i)
+		end := strings.IndexByte(s[i:], '\n')
+		if end < 0 {
+			break
+		}
+
+		i += end + 1
+	}
+
+	return append(starts, len(s))
+}
+
+// finalLine returns the text of a line of the final file, without its end
+// of line.
+func (sb *blameScoreboard) finalLine(lno int) string {
+	line := sb.finalBuf[sb.finalLineStarts[lno]:sb.finalLineStarts[lno+1]]
+	return strings.TrimSuffix(line, "\n")
+}
+
+// blameHunk is a group of changed lines, starting at 0.
+type blameHunk struct {
+	startA, countA, startB, countB int
+}
+
+// diffHunks returns the groups of changed lines between a and b.
+func (sb *blameScoreboard) diffHunks(a, b string) []blameHunk {
+	diffs := diff.DoWithOptions(a, b, diff.Options{
+		Algorithm:       sb.o.Algorithm,
+		IndentHeuristic: sb.o.IndentHeuristic,
+	})
+
+	var hunks []blameHunk
+	var cur *blameHunk
+	var la, lb int
+	for _, d := range diffs {
+		n := countLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			if cur != nil {
+				hunks = append(hunks, *cur)
+				cur = nil
+			}
+
+			la += n
+			lb += n
+			continue
+		}
+
+		if cur == nil {
+			cur = &blameHunk{startA: la, startB: lb}
+		}
+
+		if d.Type == diffmatchpatch.DiffDelete {
+			cur.countA += n
+			la += n
+		} else {
+			cur.countB += n
+			lb += n
+		}
+	}
+
+	if cur != nil {
+		hunks = append(hunks, *cur)
+	}
+
+	return hunks
+}
+
+// assignBlame passes the blame of the entries to the origins, from the
+// most recent commit, until every entry has found its origin.
+func (sb *blameScoreboard) assignBlame() error {
+	for {
+		commit := sb.commits.get()
+		if commit == nil {
+			return nil
+		}
+
+		for {
+			// find one suspect to break down
+			suspect := sb.origins[commit.Hash]
+			for suspect != nil && suspect.suspects == nil {
+				suspect = suspect.next
+			}
+
+			if suspect == nil {
+				break
+			}
+
+			if sb.o.Reverse || !sb.uninteresting[commit.Hash] {
+				if err := sb.passBlame(suspect); err != nil {
+					return err
+				}
+			}
+
+			// take responsibility for the remaining entries
+			ent := suspect.suspects
+			if ent == nil {
+				continue
+			}
+
+			suspect.guilty = true
+			for {
+				if sb.o.Incremental != nil {
+					if err := sb.o.Incremental(sb.newBlameEntry(ent)); err != nil {
+						return err
+					}
+				}
+
+				if ent.next == nil {
+					break
+				}
+
+				ent = ent.next
+			}
+
+			ent.next = sb.ent
+			sb.ent = suspect.suspects
+			suspect.suspects = nil
+		}
+	}
+}
+
+// newBlameEntry returns the BlameEntry of a guilty entry.
+func (sb *blameScoreboard) newBlameEntry(e *blameEntry) *BlameEntry {
+	c := e.suspect.commit
+	entry := &BlameEntry{
+		Commit:     mailmapCommit(sb.o.Mailmap, c),
+		Path:       e.suspect.path,
+		SourceLine: e.sLno + 1,
+		FinalLine:  e.lno + 1,
+		NumLines:   e.numLines,
+		Boundary:   sb.uninteresting[c.Hash] || c.NumParents() == 0,
+		Ignored:    e.ignored,
+		Unblamable: e.unblamable,
+	}
+
+	if p := e.suspect.previous; p != nil {
+		entry.Previous = p.commit
+		entry.PreviousPath = p.path
+	}
+
+	return entry
+}
+
+// sortAndCoalesce sorts the guilty entries by line, merging the adjacent
+// ones with the same origin.
+func (sb *blameScoreboard) sortAndCoalesce() {
+	sb.ent = sortBlameEntries(sb.ent, func(a, b *blameEntry) bool {
+		return a.lno < b.lno
+	})
+
+	for ent := sb.ent; ent != nil && ent.next != nil; {
+		next := ent.next
+		if ent.suspect == next.suspect &&
+			ent.sLno+ent.numLines == next.sLno &&
+			ent.lno+ent.numLines == next.lno &&
+			ent.ignored == next.ignored &&
+			ent.unblamable == next.unblamable {
+			ent.numLines += next.numLines
+			ent.next = next.next
+			ent.score = 0
+			continue
+		}
+
+		ent = next
+	}
+}
+
+// scapegoats returns the commits the blame of c can be passed to: its
+// parents, or its children for a reverse blame.
+func (sb *blameScoreboard) scapegoats(c *object.Commit) ([]*object.Commit, error) {
+	if sb.o.Reverse {
+		return sb.children[c.Hash], nil
+	}
+
+	var parents []*object.Commit
+	err := c.Parents().ForEach(func(p *object.Commit) error {
+		parents = append(parents, p)
+		return nil
+	})
+
+	return parents, err
+}
+
+// passBlame passes the blame of the suspects of the origin to the origins
+// of the scapegoats of its commit.
+func (sb *blameScoreboard) passBlame(origin *blameOrigin) error {
+	scapegoats, err := sb.scapegoats(origin.commit)
+	if err != nil {
+		return err
+	}
+
+	var toosmall, blames *blameEntry
+	blametail := &blames
+	sgOrigin := make([]*blameOrigin, len(scapegoats))
+
+	err = sb.passBlameToScapegoats(origin, scapegoats, sgOrigin, &blametail, &toosmall)
+	if err != nil {
+		return err
+	}
+
+	*blametail = nil
+	sb.distributeBlame(blames)
+
+	// prepend toosmall to the suspects, the list is not sorted anymore,
+	// the entries being guilty
+	if toosmall != nil {
+		tail := &toosmall
+		for *tail != nil {
+			tail = &(*tail).next
+		}
+
+		*tail = origin.suspects
+		origin.suspects = toosmall
+	}
+
+	for _, o := range sgOrigin {
+		if o != nil && o.suspects == nil {
+			o.drop()
+		}
+	}
+
+	origin.drop()
+	return nil
+}
+
+func (sb *blameScoreboard) passBlameToScapegoats(
+	origin *blameOrigin, scapegoats []*object.Commit, sgOrigin []*blameOrigin,
+	blametail ***blameEntry, toosmall **blameEntry,
+) error {
+	if len(scapegoats) == 0 {
+		return nil
+	}
+
+	// the first pass looks for the unrenamed path, to optimize for the
+	// common cases, and the second one for the renames
+	for pass := 0; pass < 2; pass++ {
+		for i, p := range scapegoats {
+			if sgOrigin[i] != nil {
+				continue
+			}
+
+			find := sb.findOrigin
+			if pass == 1 {
+				find = sb.findRename
+			}
+
+			porigin, err := find(p, origin)
+			if err != nil {
+				return err
+			}
+
+			if porigin == nil {
+				continue
+			}
+
+			if porigin.blob == origin.blob {
+				sb.passWholeBlame(origin, porigin)
+				return nil
+			}
+
+			same := false
+			for j := 0; j < i; j++ {
+				if sgOrigin[j] != nil && sgOrigin[j].blob == porigin.blob {
+					same = true
+					break
+				}
+			}
+
+			if !same {
+				sgOrigin[i] = porigin
+			}
+		}
+	}
+
+	for _, porigin := range sgOrigin {
+		if porigin == nil {
+			continue
+		}
+
+		if origin.previous == nil {
+			origin.previous = porigin
+		}
+
+		if err := sb.passBlameToParent(origin, porigin, false); err != nil {
+			return err
+		}
+
+		if origin.suspects == nil {
+			return nil
+		}
+	}
+
+	if sb.ignore[origin.commit.Hash] {
+		for _, porigin := range sgOrigin {
+			if porigin == nil {
+				continue
+			}
+
+			if err := sb.passBlameToParent(origin, porigin, true); err != nil {
+				return err
+			}
+
+			// the fingerprints are refreshed if the parent is used again
+			porigin.fingerprints = nil
+			if origin.suspects == nil {
+				return nil
+			}
+		}
+	}
+
+	if sb.o.DetectMoves || sb.o.DetectCopies != BlameCopiesNone {
+		sb.filterSmall(toosmall, &origin.suspects, sb.moveScore)
+		for _, porigin := range sgOrigin {
+			if origin.suspects == nil {
+				break
+			}
+
+			if porigin == nil {
+				continue
+			}
+
+			if err := sb.findMoveInParent(blametail, toosmall, origin, porigin); err != nil {
+				return err
+			}
+		}
+	}
+
+	if sb.o.DetectCopies == BlameCopiesNone {
+		return nil
+	}
+
+	if sb.copyScore > sb.moveScore {
+		sb.filterSmall(toosmall, &origin.suspects, sb.copyScore)
+	} else if sb.copyScore < sb.moveScore {
+		origin.suspects = blameMerge(origin.suspects, *toosmall)
+		*toosmall = nil
+		sb.filterSmall(toosmall, &origin.suspects, sb.copyScore)
+	}
+
+	for i, p := range scapegoats {
+		if origin.suspects == nil {
+			return nil
+		}
+
+		if err := sb.findCopyInParent(blametail, toosmall, origin, p, sgOrigin[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findOrigin returns the origin of the path of the origin in the parent, or
+// nil if the parent does not have it as a file of the same type.
+func (sb *blameScoreboard) findOrigin(parent *object.Commit, origin *blameOrigin) (*blameOrigin, error) {
+	for o := sb.origins[parent.Hash]; o != nil; o = o.next {
+		if o.path == origin.path {
+			return o, nil
+		}
+	}
+
+	tree, err := sb.commitTree(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := tree.FindEntry(origin.path)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !entry.Mode.IsFile() || (entry.Mode == filemode.Symlink) != (origin.mode == filemode.Symlink) {
+		return nil, nil
+	}
+
+	porigin := sb.getOrigin(parent, origin.path)
+	porigin.blob = entry.Hash
+	porigin.mode = entry.Mode
+	return porigin, nil
+}
+
+// findRename returns the origin of the file of the parent renamed to the
+// path of the origin, or nil if there is none.
+func (sb *blameScoreboard) findRename(parent *object.Commit, origin *blameOrigin) (*blameOrigin, error) {
+	from, err := sb.commitTree(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := sb.commitTree(origin.commit)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, &object.DiffTreeOptions{
+		DetectRenames: true,
+		RenameScore:   blameRenameScore,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range changes {
+		if ch.To.Name != origin.path || ch.From.Name == "" || ch.From.Name == ch.To.Name {
+			continue
+		}
+
+		porigin := sb.getOrigin(parent, ch.From.Name)
+		porigin.blob = ch.From.TreeEntry.Hash
+		porigin.mode = ch.From.TreeEntry.Mode
+		return porigin, nil
+	}
+
+	return nil, nil
+}
+
+// passWholeBlame passes the blame of every suspect of the origin to the
+// parent origin with the same contents.
+func (sb *blameScoreboard) passWholeBlame(origin, porigin *blameOrigin) {
+	suspects := origin.suspects
+	origin.suspects = nil
+	for e := suspects; e != nil; e = e.next {
+		e.suspect = porigin
+	}
+
+	sb.queueBlames(porigin, suspects)
+}
+
+// queueBlames adds the sorted entries to the suspects of the origin,
+// queueing its commit if it has no other suspects.
+func (sb *blameScoreboard) queueBlames(porigin *blameOrigin, sorted *blameEntry) {
+	if porigin.suspects != nil {
+		porigin.suspects = blameMerge(porigin.suspects, sorted)
+		return
+	}
+
+	for o := sb.origins[porigin.commit.Hash]; o != nil; o = o.next {
+		if o.suspects != nil {
+			porigin.suspects = sorted
+			return
+		}
+	}
+
+	porigin.suspects = sorted
+	sb.commits.put(porigin.commit)
+}
+
+// distributeBlame queues the entries blamed to other origins by the move
+// and copy detection.
+func (sb *blameScoreboard) distributeBlame(blamed *blameEntry) {
+	blamed = sortBlameEntries(blamed, compareBlameSuspect)
+	for blamed != nil {
+		porigin := blamed.suspect
+		var suspects *blameEntry
+		for blamed != nil && blamed.suspect == porigin {
+			next := blamed.next
+			blamed.next = suspects
+			suspects = blamed
+			blamed = next
+		}
+
+		sb.queueBlames(porigin, reverseBlame(suspects, nil))
+	}
+}
+
+// blameChunkState is the state of the passing of the blame of the target
+// to the parent, chunk by chunk of the diff.
+type blameChunkState struct {
+	parent, target *blameOrigin
+	offset         int
+	ignoreDiffs    bool
+	// dstq is the end of the entries passed to the parent, and srcq the
+	// first entry of the target not processed yet.
+	dstq, srcq **blameEntry
+}
+
+// passBlameToParent passes the blame of the lines of the target not changed
+// from the parent to the parent. If ignoreDiffs is true the changed lines
+// are passed to the most similar lines of the parent instead.
+func (sb *blameScoreboard) passBlameToParent(target, parent *blameOrigin, ignoreDiffs bool) error {
+	if target.suspects == nil {
+		return nil
+	}
+
+	if err := sb.fill(parent, ignoreDiffs); err != nil {
+		return err
+	}
+
+	if err := sb.fill(target, ignoreDiffs); err != nil {
+		return err
+	}
+
+	var newdest *blameEntry
+	d := &blameChunkState{
+		parent:      parent,
+		target:      target,
+		ignoreDiffs: ignoreDiffs,
+		dstq:        &newdest,
+		srcq:        &target.suspects,
+	}
+
+	for _, h := range sb.diffHunks(parent.contents, target.contents) {
+		sb.blameChunk(d, h.startB, h.startA-h.startB, h.startB+h.countB, h.countA)
+		d.offset = h.startA + h.countA - (h.startB + h.countB)
+	}
+
+	// the rest are the same as the parent
+	sb.blameChunk(d, math.MaxInt32, d.offset, math.MaxInt32, 0)
+	*d.dstq = nil
+
+	// the entries of the ignored lines are not sorted
+	if ignoreDiffs {
+		newdest = sortBlameEntries(newdest, compareBlameSuspect)
+	}
+
+	sb.queueBlames(parent, newdest)
+	return nil
+}
+
+// blameChunk passes the blame of the lines of the target before tlno to the
+// parent, their line in the parent being offset, and keeps the blame of the
+// lines from tlno to same, changed from parentLen lines of the parent.
+func (sb *blameScoreboard) blameChunk(d *blameChunkState, tlno, offset, same, parentLen int) {
+	e := *d.srcq
+	var samep, diffp, ignoredp *blameEntry
+
+	for e != nil && e.sLno < tlno {
+		next := e.next
+		// the entry reaching into the differing portion is split, its
+		// second half being examined separately
+		if e.sLno+e.numLines > tlno {
+			n := splitBlameAt(e, tlno-e.sLno, e.suspect)
+			n.next = diffp
+			diffp = n
+		}
+
+		e.suspect = d.parent
+		e.sLno += offset
+		e.next = samep
+		samep = e
+		e = next
+	}
+
+	// the entries before the chunk are the only ones known to be passed
+	// to the parent for now
+	if samep != nil {
+		*d.dstq = reverseBlame(samep, *d.dstq)
+		d.dstq = &samep.next
+	}
+
+	e = reverseBlame(diffp, e)
+	samep, diffp = nil, nil
+
+	var lineBlames []blameLineTracker
+	if d.ignoreDiffs && same-tlno > 0 {
+		lineBlames = guessLineBlames(d.parent, d.target, tlno, offset, same, parentLen)
+	}
+
+	for e != nil && e.sLno < same {
+		next := e.next
+		// the entry extending after the chunk is split, its second half
+		// being processed by the next chunks
+		if e.sLno+e.numLines > same {
+			n := splitBlameAt(e, same-e.sLno, e.suspect)
+			n.next = samep
+			samep = n
+		}
+
+		if d.ignoreDiffs {
+			ignoreBlameEntry(e, d.parent, &diffp, &ignoredp, lineBlames[e.sLno-tlno:])
+		} else {
+			e.next = diffp
+			diffp = e
+		}
+
+		e = next
+	}
+
+	if ignoredp != nil {
+		*d.dstq = reverseBlame(ignoredp, *d.dstq)
+		d.dstq = &ignoredp.next
+	}
+
+	*d.srcq = reverseBlame(diffp, reverseBlame(samep, e))
+	// move across the entries of the changed lines
+	if diffp != nil {
+		d.srcq = &diffp.next
+	}
+}
+
+// splitBlameAt splits the entry after its first length lines, returning the
+// entry of the remaining lines with the given suspect.
+func splitBlameAt(e *blameEntry, length int, suspect *blameOrigin) *blameEntry {
+	n := &blameEntry{
+		suspect:    suspect,
+		ignored:    e.ignored,
+		unblamable: e.unblamable,
+		lno:        e.lno + length,
+		sLno:       e.sLno + length,
+		numLines:   e.numLines - length,
+	}
+
+	e.numLines = length
+	e.score = 0
+	return n
+}
+
+// ignoreBlameEntry splits the entry of lines changed by an ignored commit in
+// groups of lines matched with consecutive lines of the parent, passed to
+// ignoredp, or not matched, kept in diffp.
+func ignoreBlameEntry(e *blameEntry, parent *blameOrigin, diffp, ignoredp **blameEntry, lineBlames []blameLineTracker) {
+	entryLen := 1
+	numLines := e.numLines
+	for i := 0; i < numLines; i++ {
+		var next *blameEntry
+		if i+1 < numLines {
+			if lineBlames[i].isParent == lineBlames[i+1].isParent &&
+				lineBlames[i].sLno+1 == lineBlames[i+1].sLno {
+				entryLen++
+				continue
+			}
+
+			next = splitBlameAt(e, entryLen, e.suspect)
+		}
+
+		if lineBlames[i].isParent {
+			e.ignored = true
+			e.suspect = parent
+			e.sLno = lineBlames[i-entryLen+1].sLno
+			e.next = *ignoredp
+			*ignoredp = e
+		} else {
+			e.unblamable = true
+			e.next = *diffp
+			*diffp = e
+		}
+
+		e = next
+		entryLen = 1
+	}
+}
+
+// entryScore returns the number of alphanumeric characters of the lines of
+// the entry plus one, used to skip the entries too trivial to be detected
+// as moved or copied.
+func (sb *blameScoreboard) entryScore(e *blameEntry) int {
+	if e.score != 0 {
+		return e.score
+	}
+
+	score := 1
+	for _, ch := range []byte(sb.finalBuf[sb.finalLineStarts[e.lno]:sb.finalLineStarts[e.lno+e.numLines]]) {
+		if ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' {
+			score++
+		}
+	}
+
+	e.score = score
+	return score
+}
+
+// filterSmall moves the entries of source with a score not greater than
+// scoreMin to small, returning the end of the moved entries.
+func (sb *blameScoreboard) filterSmall(small, source **blameEntry, scoreMin int) **blameEntry {
+	p := *source
+	oldsmall := *small
+	for p != nil {
+		if sb.entryScore(p) <= scoreMin {
+			*small = p
+			small = &p.next
+			p = *small
+		} else {
+			*source = p
+			source = &p.next
+			p = *source
+		}
+	}
+
+	*small = oldsmall
+	*source = nil
+	return small
+}
+
+// findMoveInParent passes the blame of the lines of the target found in the
+// file of the parent to it, wherever they are.
+func (sb *blameScoreboard) findMoveInParent(blamed ***blameEntry, toosmall **blameEntry, target, parent *blameOrigin) error {
+	unblamed := target.suspects
+	if unblamed == nil {
+		return nil
+	}
+
+	if err := sb.fill(parent, false); err != nil {
+		return err
+	}
+
+	// at each iteration unblamed are the entries not tested yet
+	var leftover *blameEntry
+	for unblamed != nil {
+		unblamedtail := &unblamed
+		var next *blameEntry
+		for e := unblamed; e != nil; e = next {
+			next = e.next
+			split := sb.findCopyInBlob(e, parent)
+			if split[1].suspect != nil && sb.moveScore < sb.entryScore(&split[1]) {
+				splitBlame(blamed, &unblamedtail, &split, e)
+			} else {
+				e.next = leftover
+				leftover = e
+			}
+		}
+
+		*unblamedtail = nil
+		toosmall = sb.filterSmall(toosmall, &unblamed, sb.moveScore)
+	}
+
+	target.suspects = reverseBlame(leftover, nil)
+	return nil
+}
+
+// findCopyInParent passes the blame of the lines of the target found in the
+// other files of the parent to them.
+func (sb *blameScoreboard) findCopyInParent(
+	blamed ***blameEntry, toosmall **blameEntry,
+	target *blameOrigin, parent *object.Commit, porigin *blameOrigin,
+) error {
+	unblamed := target.suspects
+	if unblamed == nil {
+		return nil
+	}
+
+	harder := sb.o.DetectCopies >= BlameCopiesAny ||
+		sb.o.DetectCopies >= BlameCopiesCreation && (porigin == nil || target.path != porigin.path)
+
+	files, err := sb.copySources(parent, target.commit, harder)
+	if err != nil {
+		return err
+	}
+
+	var leftover *blameEntry
+	for unblamed != nil {
+		unblamedtail := &unblamed
+
+		var blist []blameCopyCandidate
+		for e := unblamed; e != nil; e = e.next {
+			blist = append(blist, blameCopyCandidate{ent: e})
+		}
+
+		for _, f := range files {
+			// the moves in the file were already found
+			if porigin != nil && f.Name == porigin.path {
+				continue
+			}
+
+			norigin := sb.getOrigin(parent, f.Name)
+			norigin.blob = f.Hash
+			norigin.mode = f.Mode
+			if err := sb.fill(norigin, false); err != nil {
+				return err
+			}
+
+			for j := range blist {
+				potential := sb.findCopyInBlob(blist[j].ent, norigin)
+				sb.copySplitIfBetter(&blist[j].split, &potential)
+			}
+
+			if norigin.suspects == nil {
+				norigin.drop()
+			}
+		}
+
+		for j := range blist {
+			split := &blist[j].split
+			if split[1].suspect != nil && sb.copyScore < sb.entryScore(&split[1]) {
+				splitBlame(blamed, &unblamedtail, split, blist[j].ent)
+			} else {
+				blist[j].ent.next = leftover
+				leftover = blist[j].ent
+			}
+		}
+
+		*unblamedtail = nil
+		toosmall = sb.filterSmall(toosmall, &unblamed, sb.copyScore)
+	}
+
+	target.suspects = reverseBlame(leftover, nil)
+	return nil
+}
+
+type blameCopyCandidate struct {
+	ent   *blameEntry
+	split [3]blameEntry
+}
+
+// copySources returns the files of the parent the lines of a commit may be
+// copied from, sorted by path: the ones modified or deleted by the commit,
+// or every file of the parent if harder is true.
+func (sb *blameScoreboard) copySources(parent, c *object.Commit, harder bool) ([]object.TreeEntry, error) {
+	from, err := sb.commitTree(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []object.TreeEntry
+	if harder {
+		walker := object.NewTreeWalker(from, true, nil)
+		defer walker.Close()
+
+		for {
+			name, entry, err := walker.Next()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if entry.Mode.IsFile() {
+				entry.Name = name
+				files = append(files, entry)
+			}
+		}
+	} else {
+		to, err := sb.commitTree(c)
+		if err != nil {
+			return nil, err
+		}
+
+		changes, err := object.DiffTreeWithOptions(context.Background(), from, to, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ch := range changes {
+			if ch.From.Name != "" && ch.From.TreeEntry.Mode.IsFile() {
+				entry := ch.From.TreeEntry
+				entry.Name = ch.From.Name
+				files = append(files, entry)
+			}
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// findCopyInBlob returns the best split of the entry in the lines before,
+// found in the parent, and after a group of lines of the parent.
+func (sb *blameScoreboard) findCopyInBlob(ent *blameEntry, parent *blameOrigin) [3]blameEntry {
+	var split [3]blameEntry
+	fileO := sb.finalBuf[sb.finalLineStarts[ent.lno]:sb.finalLineStarts[ent.lno+ent.numLines]]
+
+	var plno, tlno int
+	for _, h := range sb.diffHunks(parent.contents, fileO) {
+		sb.handleSplit(ent, tlno, plno, h.startB, parent, &split)
+		plno = h.startA + h.countA
+		tlno = h.startB + h.countB
+	}
+
+	// the remainder, if any, matches the parent
+	sb.handleSplit(ent, tlno, plno, ent.numLines, parent, &split)
+	return split
+}
+
+// handleSplit keeps the split of the entry at the lines from tlno to same,
+// relative to the entry, matching the lines at plno in the parent, if it
+// is better than the current one.
+func (sb *blameScoreboard) handleSplit(ent *blameEntry, tlno, plno, same int, parent *blameOrigin, split *[3]blameEntry) {
+	if ent.numLines <= tlno || tlno >= same {
+		return
+	}
+
+	potential := splitOverlap(ent, tlno+ent.sLno, plno, same+ent.sLno, parent)
+	sb.copySplitIfBetter(split, &potential)
+}
+
+// copySplitIfBetter replaces the best split with the potential one if its
+// part blamed to the parent has a greater or equal score.
+func (sb *blameScoreboard) copySplitIfBetter(best, potential *[3]blameEntry) {
+	if potential[1].suspect == nil {
+		return
+	}
+
+	if best[1].suspect != nil && sb.entryScore(&potential[1]) < sb.entryScore(&best[1]) {
+		return
+	}
+
+	*best = *potential
+}
+
+// splitOverlap splits the entry in the lines before tlno, the lines from
+// tlno to same blamed to the parent at plno, and the lines after same. The
+// first and last parts have no suspect if empty, and the middle one if
+// there is nothing to blame to the parent.
+func splitOverlap(e *blameEntry, tlno, plno, same int, parent *blameOrigin) [3]blameEntry {
+	var split [3]blameEntry
+	for i := range split {
+		split[i].ignored = e.ignored
+		split[i].unblamable = e.unblamable
+	}
+
+	if e.sLno < tlno {
+		// there is a pre-chunk part not blamed on the parent
+		split[0].suspect = e.suspect
+		split[0].lno = e.lno
+		split[0].sLno = e.sLno
+		split[0].numLines = tlno - e.sLno
+		split[1].lno = e.lno + tlno - e.sLno
+		split[1].sLno = plno
+	} else {
+		split[1].lno = e.lno
+		split[1].sLno = plno + (e.sLno - tlno)
+	}
+
+	chunkEnd := e.lno + e.numLines
+	if same < e.sLno+e.numLines {
+		// there is a post-chunk part not blamed on the parent
+		split[2].suspect = e.suspect
+		split[2].lno = e.lno + (same - e.sLno)
+		split[2].sLno = e.sLno + (same - e.sLno)
+		split[2].numLines = e.sLno + e.numLines - same
+		chunkEnd = split[2].lno
+	}
+
+	split[1].numLines = chunkEnd - split[1].lno
+	if split[1].numLines >= 1 {
+		split[1].suspect = parent
+	}
+
+	return split
+}
+
+// splitBlame replaces the entry with the parts of the split, the part
+// blamed to the parent being added to blamed, and the others to unblamed.
+func splitBlame(blamed, unblamed ***blameEntry, split *[3]blameEntry, e *blameEntry) {
+	switch {
+	case split[0].suspect != nil && split[2].suspect != nil:
+		dupEntry(unblamed, e, &split[0])
+		addBlameEntry(unblamed, &split[2])
+		addBlameEntry(blamed, &split[1])
+	case split[0].suspect == nil && split[2].suspect == nil:
+		dupEntry(blamed, e, &split[1])
+	case split[0].suspect != nil:
+		dupEntry(unblamed, e, &split[0])
+		addBlameEntry(blamed, &split[1])
+	default:
+		dupEntry(blamed, e, &split[1])
+		addBlameEntry(unblamed, &split[2])
+	}
+}
+
+// dupEntry copies src to dst, added at the end of the queue.
+func dupEntry(queue **(*blameEntry), dst, src *blameEntry) {
+	*dst = *src
+	dst.next = **queue
+	**queue = dst
+	*queue = &dst.next
+}
+
+// addBlameEntry adds a copy of src at the end of the queue.
+func addBlameEntry(queue **(*blameEntry), src *blameEntry) {
+	e := *src
+	e.next = **queue
+	**queue = &e
+	*queue = &e.next
+}
+
+// reverseBlame reverses the list of entries, followed by tail.
+func reverseBlame(head, tail *blameEntry) *blameEntry {
+	for head != nil {
+		next := head.next
+		head.next = tail
+		tail = head
+		head = next
+	}
+
+	return tail
+}
+
+// blameMerge merges two lists of entries sorted by their line in the
+// suspect.
+func blameMerge(list1, list2 *blameEntry) *blameEntry {
+	var head *blameEntry
+	tail := &head
+	for list1 != nil && list2 != nil {
+		if list1.sLno <= list2.sLno {
+			*tail = list1
+			list1 = list1.next
+		} else {
+			*tail = list2
+			list2 = list2.next
+		}
+
+		tail = &(*tail).next
+	}
+
+	if list1 != nil {
+		*tail = list1
+	} else {
+		*tail = list2
+	}
+
+	return head
+}
+
+// sortBlameEntries sorts the list of entries, keeping the order of the
+// equal ones.
+func sortBlameEntries(head *blameEntry, less func(a, b *blameEntry) bool) *blameEntry {
+	var entries []*blameEntry
+	for e := head; e != nil; e = e.next {
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	head = nil
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i].next = head
+		head = entries[i]
+	}
+
+	return head
+}
+
+func compareBlameSuspect(a, b *blameEntry) bool {
+	if a.suspect != b.suspect {
+		return a.suspect.id < b.suspect.id
+	}
+
+	return a.sLno < b.sLno
+}
+
+// blameQueue is the queue of the commits with suspects, sorted by commit
+// date, the most recent first, or the oldest first for a reverse blame.
+type blameQueue struct {
+	reverse bool
+	items   []blameQueueItem
+	ctr     int
+}
+
+type blameQueueItem struct {
+	commit *object.Commit
+	ctr    int
+}
+
+func (q *blameQueue) put(c *object.Commit) {
+	q.ctr++
+	heap.Push(q, blameQueueItem{commit: c, ctr: q.ctr})
+}
+
+func (q *blameQueue) get() *object.Commit {
+	if len(q.items) == 0 {
+		return nil
+	}
+
+	return heap.Pop(q).(blameQueueItem).commit
+}
+
+func (q *blameQueue) Len() int { return len(q.items) }
+func (q *blameQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	ta, tb := a.commit.Committer.When.Unix(), b.commit.Committer.When.Unix()
+	if ta != tb {
+		return (ta > tb) != q.reverse
+	}
+
+	return a.ctr < b.ctr
+}
+func (q *blameQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *blameQueue) Push(x any)    { q.items = append(q.items, x.(blameQueueItem)) }
+func (q *blameQueue) Pop() any {
+	n := len(q.items)
+	item := q.items[n-1]
+	q.items = q.items[:n-1]
+	return item
+}
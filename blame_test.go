@@ -1,8 +1,20 @@
 package git
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/mailmap"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/utils/diff"
 
 	fixtures "github.com/go-git/go-git-fixtures/v4"
 	. "gopkg.in/check.v1"
@@ -44,6 +56,14 @@ type blameTest struct {
 	rev    string
 	path   string
 	blames []string // the commits blamed for each line
+	// the entries of the result, as `git blame --porcelain` reports them
+	entries []blameTestEntry
+}
+
+type blameTestEntry struct {
+	source, final, lines int
+	path                 string // the path in the blamed commit, if renamed
+	previous             string // the hash of the previous commit, if any
 }
 
 // run a blame on all the suite's tests
@@ -65,6 +85,401 @@ func (s *BlameSuite) TestBlame(c *C) {
 	}
 }
 
+func (s *BlameSuite) TestBlameWithOptions(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	var commits []*object.Commit
+	for _, content := range []string{"}\n{\n\n  x\n", "b\n\nc\na\n\nc\n}\n"} {
+		c.Assert(util.WriteFile(fs, "foo", []byte(content), 0644), IsNil)
+		_, err = w.Add("foo")
+		c.Assert(err, IsNil)
+
+		h, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+
+		commit, err := r.CommitObject(h)
+		c.Assert(err, IsNil)
+		commits = append(commits, commit)
+	}
+
+	first, second := commits[0].Hash, commits[1].Hash
+	for _, t := range []struct {
+		algorithm diff.Algorithm
+		blames    []plumbing.Hash
+	}{
+		{diff.Patience, []plumbing.Hash{second, second, second, second, second, second, first}},
+		{diff.Histogram, []plumbing.Hash{second, first, second, second, second, second, second}},
+	} {
+		result, err := BlameWithOptions(commits[1], "foo", &BlameOptions{Algorithm: t.algorithm})
+		c.Assert(err, IsNil)
+
+		var blames []plumbing.Hash
+		for _, l := range result.Lines {
+			blames = append(blames, l.Hash)
+		}
+
+		c.Assert(blames, DeepEquals, t.blames, Commentf("%s", t.algorithm))
+	}
+}
+
+// commitBlameFiles writes the files to the worktree and commits them, each
+// commit being one hour after the previous one.
+func (s *BlameSuite) commitBlameFiles(c *C, r *Repository, files map[string]string) *object.Commit {
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for path, content := range files {
+		c.Assert(util.WriteFile(w.Filesystem, path, []byte(content), 0644), IsNil)
+		_, err = w.Add(path)
+		c.Assert(err, IsNil)
+	}
+
+	head, err := r.Head()
+	var n time.Duration
+	if err == nil {
+		commit, err := r.CommitObject(head.Hash())
+		c.Assert(err, IsNil)
+		n = commit.Author.When.Sub(defaultSignature().When) + time.Hour
+	}
+
+	sig := defaultSignature()
+	sig.When = sig.When.Add(n)
+	h, err := w.Commit("foo\n", &CommitOptions{Author: sig})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	return commit
+}
+
+func blameHashes(result *BlameResult) []plumbing.Hash {
+	var blames []plumbing.Hash
+	for _, l := range result.Lines {
+		blames = append(blames, l.Hash)
+	}
+
+	return blames
+}
+
+func (s *BlameSuite) TestBlameLineRanges(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	first := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nb\nc\nd\ne\n"})
+	second := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nB\nc\nD\ne\n"})
+
+	result, err := BlameWithOptions(second, "foo", &BlameOptions{
+		Lines: []BlameLineRange{{Start: 4, End: 0}, {Start: 1, End: 2}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(blameHashes(result), DeepEquals, []plumbing.Hash{
+		first.Hash, second.Hash, second.Hash, first.Hash,
+	})
+	c.Assert(result.Lines[1].Text, Equals, "B")
+	c.Assert(result.Lines[2].Text, Equals, "D")
+	c.Assert(result.Entries, HasLen, 4)
+	c.Assert(result.Entries[2].FinalLine, Equals, 4)
+	c.Assert(result.Entries[3].SourceLine, Equals, 5)
+
+	_, err = BlameWithOptions(second, "foo", &BlameOptions{
+		Lines: []BlameLineRange{{Start: 6}},
+	})
+	c.Assert(err, Equals, ErrInvalidLineRange)
+}
+
+func (s *BlameSuite) TestBlameIgnoreRevs(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	first := s.commitBlameFiles(c, r, map[string]string{"foo": "func foo() {\n\treturn 1\n}\n"})
+	second := s.commitBlameFiles(c, r, map[string]string{"foo": "func Foo() {\n\t@@@\n\treturn 1\n}\n"})
+	third := s.commitBlameFiles(c, r, map[string]string{"foo": "func Foo() {\n\t@@@\n\treturn 2\n}\n"})
+
+	result, err := BlameWithOptions(third, "foo", &BlameOptions{
+		IgnoreRevs: []plumbing.Hash{second.Hash},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(blameHashes(result), DeepEquals, []plumbing.Hash{
+		first.Hash, second.Hash, third.Hash, first.Hash,
+	})
+
+	c.Assert(result.Entries, HasLen, 4)
+	c.Assert(result.Entries[0].Ignored, Equals, true)
+	c.Assert(result.Entries[1].Unblamable, Equals, true)
+	c.Assert(result.Entries[2].Ignored, Equals, false)
+}
+
+func (s *BlameSuite) TestBlameIgnoreRevsAddedLines(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	first := s.commitBlameFiles(c, r, map[string]string{"foo": "A\nB\n"})
+	second := s.commitBlameFiles(c, r, map[string]string{"foo": "A\nB\nC\n"})
+	third := s.commitBlameFiles(c, r, map[string]string{"foo": "X\nA\nB\nC\n"})
+
+	result, err := BlameWithOptions(third, "foo", &BlameOptions{
+		IgnoreRevs: []plumbing.Hash{third.Hash},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(blameHashes(result), DeepEquals, []plumbing.Hash{
+		third.Hash, first.Hash, first.Hash, second.Hash,
+	})
+	c.Assert(result.Entries[0].Unblamable, Equals, true)
+
+	fourth := s.commitBlameFiles(c, r, map[string]string{
+		"foo": "theta foo\nx beta x\ngamma foo eps\n",
+	})
+	fifth := s.commitBlameFiles(c, r, map[string]string{
+		"foo": "theta foo\nx beta x\ngamma eta foo\ngamma foo eps\n",
+	})
+
+	result, err = BlameWithOptions(fifth, "foo", &BlameOptions{
+		IgnoreRevs: []plumbing.Hash{fifth.Hash},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(blameHashes(result), DeepEquals, []plumbing.Hash{
+		fourth.Hash, fourth.Hash, fourth.Hash, fourth.Hash,
+	})
+
+	var added *BlameEntry
+	for _, e := range result.Entries {
+		if e.FinalLine == 3 {
+			added = e
+		}
+	}
+
+	c.Assert(added, NotNil)
+	c.Assert(added.Ignored, Equals, true)
+	c.Assert(added.SourceLine, Equals, 3)
+}
+
+func (s *BlameSuite) TestBlameIgnoreRevsFile(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	first := s.commitBlameFiles(c, r, map[string]string{"foo": "foo\n"})
+	second := s.commitBlameFiles(c, r, map[string]string{"foo": "bar\n"})
+
+	c.Assert(util.WriteFile(fs, ".git-blame-ignore-revs",
+		[]byte("# formatting\n"+first.Hash.String()+"\n\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "other-revs",
+		[]byte(second.Hash.String()+" # renames\n"+first.Hash.String()+"\n"), 0644), IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("blame").SetOption("ignoreRevsFile", ".git-blame-ignore-revs")
+	c.Assert(r.SetConfig(cfg), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	revs, err := w.BlameIgnoreRevs("other-revs")
+	c.Assert(err, IsNil)
+	c.Assert(revs, DeepEquals, []plumbing.Hash{first.Hash, second.Hash})
+
+	abs := filepath.Join(c.MkDir(), "revs")
+	c.Assert(os.WriteFile(abs, []byte(second.Hash.String()+"\n"), 0644), IsNil)
+	revs, err = w.BlameIgnoreRevs(abs)
+	c.Assert(err, IsNil)
+	c.Assert(revs, DeepEquals, []plumbing.Hash{first.Hash, second.Hash})
+
+	c.Assert(util.WriteFile(fs, "other-revs", []byte("HEAD\n"), 0644), IsNil)
+	_, err = w.BlameIgnoreRevs("other-revs")
+	c.Assert(errors.Is(err, ErrInvalidIgnoreRevs), Equals, true)
+}
+
+func (s *BlameSuite) TestBlameMovesAndCopies(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	moved := "func fooBarBaz() {\n\treturn quxQuuxCorge + graultGarply\n}\n"
+	other := "func other() {\n\treturn nil\n}\n"
+	first := s.commitBlameFiles(c, r, map[string]string{
+		"foo": "package foo\n\n" + moved + "\n" + other,
+		"bar": "package bar\n",
+	})
+	second := s.commitBlameFiles(c, r, map[string]string{
+		"foo": "package foo\n\n" + other + "\n" + moved,
+		"bar": "package bar\n\n" + moved,
+	})
+
+	for _, t := range []struct {
+		opts   *BlameOptions
+		path   string
+		blames []plumbing.Hash
+		paths  []string
+	}{
+		{&BlameOptions{}, "foo", []plumbing.Hash{
+			first.Hash, first.Hash, first.Hash, first.Hash, first.Hash,
+			second.Hash, second.Hash, second.Hash, second.Hash,
+		}, repeat("foo", 9)},
+		{&BlameOptions{DetectMoves: true}, "foo", []plumbing.Hash{
+			first.Hash, first.Hash, first.Hash, first.Hash, first.Hash,
+			first.Hash, first.Hash, first.Hash, first.Hash,
+		}, repeat("foo", 9)},
+		{&BlameOptions{DetectMoves: true}, "bar", []plumbing.Hash{
+			first.Hash, second.Hash, second.Hash, second.Hash, second.Hash,
+		}, repeat("bar", 5)},
+		{&BlameOptions{DetectCopies: BlameCopiesModified}, "bar", []plumbing.Hash{
+			first.Hash, first.Hash, first.Hash, first.Hash, first.Hash,
+		}, concat([]string{"bar"}, repeat("foo", 4))},
+	} {
+		result, err := BlameWithOptions(second, t.path, t.opts)
+		c.Assert(err, IsNil)
+		c.Assert(blameHashes(result), DeepEquals, t.blames, Commentf("%s %+v", t.path, t.opts))
+
+		var paths []string
+		for _, e := range result.Entries {
+			paths = append(paths, repeat(e.Path, e.NumLines)...)
+		}
+
+		c.Assert(paths, DeepEquals, t.paths, Commentf("%s %+v", t.path, t.opts))
+	}
+}
+
+func (s *BlameSuite) TestBlameSince(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	first := s.commitBlameFiles(c, r, map[string]string{"foo": "a\n"})
+	second := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nb\n"})
+	third := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nb\nc\n"})
+
+	result, err := BlameWithOptions(third, "foo", &BlameOptions{Since: second})
+	c.Assert(err, IsNil)
+	c.Assert(blameHashes(result), DeepEquals, []plumbing.Hash{
+		second.Hash, second.Hash, third.Hash,
+	})
+	c.Assert(result.Entries[0].Boundary, Equals, true)
+	c.Assert(result.Entries[1].Boundary, Equals, false)
+
+	result, err = BlameWithOptions(third, "foo", &BlameOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Entries[0].Commit.Hash, Equals, first.Hash)
+	c.Assert(result.Entries[0].Boundary, Equals, true)
+}
+
+func (s *BlameSuite) TestBlameReverse(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	first := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nb\nc\n"})
+	second := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nc\n"})
+	third := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nC\n"})
+
+	result, err := BlameWithOptions(third, "foo", &BlameOptions{Since: first, Reverse: true})
+	c.Assert(err, IsNil)
+	c.Assert(result.Rev, Equals, first.Hash)
+	c.Assert(blameHashes(result), DeepEquals, []plumbing.Hash{
+		third.Hash, first.Hash, second.Hash,
+	})
+
+	_, err = BlameWithOptions(third, "foo", &BlameOptions{Reverse: true})
+	c.Assert(err, Equals, ErrBlameReverseWithoutSince)
+}
+
+func (s *BlameSuite) TestBlameIncremental(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	first := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nb\n"})
+	second := s.commitBlameFiles(c, r, map[string]string{"foo": "a\nB\nc\n"})
+
+	buf := bytes.NewBuffer(nil)
+	var entries []*BlameEntry
+	enc := NewBlameIncrementalEncoder(buf)
+	result, err := BlameWithOptions(second, "foo", &BlameOptions{
+		Incremental: func(e *BlameEntry) error {
+			entries = append(entries, e)
+			return enc.Encode(e)
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Commit.Hash, Equals, second.Hash)
+	c.Assert(entries[1].Commit.Hash, Equals, first.Hash)
+	c.Assert(buf.String(), Equals, fmt.Sprintf(""+
+		"%[1]s 2 2 2\n"+
+		"author foo\n"+
+		"author-mail <foo@foo.foo>\n"+
+		"author-time 1493852623\n"+
+		"author-tz +0200\n"+
+		"committer foo\n"+
+		"committer-mail <foo@foo.foo>\n"+
+		"committer-time 1493852623\n"+
+		"committer-tz +0200\n"+
+		"summary foo\n"+
+		"previous %[2]s foo\n"+
+		"filename foo\n"+
+		"%[2]s 1 1 1\n"+
+		"author foo\n"+
+		"author-mail <foo@foo.foo>\n"+
+		"author-time 1493849023\n"+
+		"author-tz +0200\n"+
+		"committer foo\n"+
+		"committer-mail <foo@foo.foo>\n"+
+		"committer-time 1493849023\n"+
+		"committer-tz +0200\n"+
+		"summary foo\n"+
+		"boundary\n"+
+		"filename foo\n",
+		second.Hash, first.Hash))
+
+	c.Assert(result.Porcelain(), Equals, fmt.Sprintf(""+
+		"%[2]s 1 1 1\n"+
+		"author foo\n"+
+		"author-mail <foo@foo.foo>\n"+
+		"author-time 1493849023\n"+
+		"author-tz +0200\n"+
+		"committer foo\n"+
+		"committer-mail <foo@foo.foo>\n"+
+		"committer-time 1493849023\n"+
+		"committer-tz +0200\n"+
+		"summary foo\n"+
+		"boundary\n"+
+		"filename foo\n"+
+		"\ta\n"+
+		"%[1]s 2 2 2\n"+
+		"author foo\n"+
+		"author-mail <foo@foo.foo>\n"+
+		"author-time 1493852623\n"+
+		"author-tz +0200\n"+
+		"committer foo\n"+
+		"committer-mail <foo@foo.foo>\n"+
+		"committer-time 1493852623\n"+
+		"committer-tz +0200\n"+
+		"summary foo\n"+
+		"previous %[2]s foo\n"+
+		"filename foo\n"+
+		"\tB\n"+
+		"%[1]s 3 3\n"+
+		"\tc\n",
+		second.Hash, first.Hash))
+}
+
+func (s *BlameSuite) TestBlameMailmap(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	commit := s.commitBlameFiles(c, r, map[string]string{"foo": "foo\n"})
+
+	m := mailmap.New()
+	m.Add("Jane Doe", "jane@example.com", "foo", "foo@foo.foo")
+
+	result, err := BlameWithOptions(commit, "foo", &BlameOptions{Mailmap: m})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines[0].AuthorName, Equals, "Jane Doe")
+	c.Assert(result.Lines[0].Author, Equals, "jane@example.com")
+	c.Assert(result.Entries[0].Commit.Committer.Name, Equals, "Jane Doe")
+	c.Assert(commit.Author.Name, Equals, "foo")
+}
+
 func (s *BlameSuite) mockBlame(c *C, t blameTest, r *Repository) (blame *BlameResult) {
 	commit, err := r.CommitObject(plumbing.NewHash(t.rev))
 	c.Assert(err, IsNil, Commentf("%v: repo=%s, rev=%s", err, t.repo, t.rev))
@@ -91,10 +506,44 @@ func (s *BlameSuite) mockBlame(c *C, t blameTest, r *Repository) (blame *BlameRe
 	}
 
 	return &BlameResult{
-		Path:  t.path,
-		Rev:   plumbing.NewHash(t.rev),
-		Lines: blamedLines,
+		Path:    t.path,
+		Rev:     plumbing.NewHash(t.rev),
+		Lines:   blamedLines,
+		Entries: s.mockBlameEntries(c, t, r),
+	}
+}
+
+// mockBlameEntries builds the entries of the result, blamed to the commits of
+// their first lines.
+func (s *BlameSuite) mockBlameEntries(c *C, t blameTest, r *Repository) []*BlameEntry {
+	var entries []*BlameEntry
+	for _, te := range t.entries {
+		commit, err := r.CommitObject(plumbing.NewHash(t.blames[te.final-1]))
+		c.Assert(err, IsNil)
+
+		e := &BlameEntry{
+			Commit:     commit,
+			Path:       t.path,
+			SourceLine: te.source,
+			FinalLine:  te.final,
+			NumLines:   te.lines,
+			Boundary:   commit.NumParents() == 0,
+		}
+
+		if te.path != "" {
+			e.Path = te.path
+		}
+
+		if te.previous != "" {
+			e.Previous, err = r.CommitObject(plumbing.NewHash(te.previous))
+			c.Assert(err, IsNil)
+			e.PreviousPath = e.Path
+		}
+
+		entries = append(entries, e)
 	}
+
+	return entries
 }
 
 // utility function to avoid writing so many repeated commits
@@ -124,28 +573,44 @@ var blameTests = [...]blameTest{
 	// use the blame2humantest.bash script to easily add more tests.
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "binary.jpg", concat(
 		repeat("35e85108805c84807bc66a02d91535e1e24b38b9", 285),
-	)},
+	), []blameTestEntry{
+		{1, 1, 285, "", ""},
+	}},
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "CHANGELOG", concat(
 		repeat("b8e471f58bcbca63b07bda20e428190409c2db47", 1),
-	)},
+	), []blameTestEntry{
+		{1, 1, 1, "", ""},
+	}},
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "go/example.go", concat(
 		repeat("918c48b83bd081e863dbe1b80f8998f058cd8294", 142),
-	)},
+	), []blameTestEntry{
+		{1, 1, 142, "", ""},
+	}},
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "json/long.json", concat(
 		repeat("af2d6a6954d532f8ffb47615169c8fdf9d383a1a", 6492),
-	)},
+	), []blameTestEntry{
+		{1, 1, 6492, "", ""},
+	}},
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "json/short.json", concat(
 		repeat("af2d6a6954d532f8ffb47615169c8fdf9d383a1a", 22),
-	)},
+	), []blameTestEntry{
+		{1, 1, 22, "", ""},
+	}},
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "LICENSE", concat(
 		repeat("b029517f6300c2da0f4b651b8642506cd6aaf45d", 22),
-	)},
+	), []blameTestEntry{
+		{1, 1, 22, "", ""},
+	}},
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "php/crappy.php", concat(
 		repeat("918c48b83bd081e863dbe1b80f8998f058cd8294", 259),
-	)},
+	), []blameTestEntry{
+		{1, 1, 259, "", ""},
+	}},
 	{"https://github.com/git-fixtures/basic.git", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", "vendor/foo.go", concat(
 		repeat("6ecf0ef2c2dffb796033e5a02219af86ec6584e5", 7),
-	)},
+	), []blameTestEntry{
+		{1, 1, 7, "", ""},
+	}},
 	/*
 		// This fails due to the different diff tool being used to create the patches.
 		// For example in commit d4b48a39aba7d3bd3e8abef2274a95b112d1ae73 when "function echo_status()" is added:
@@ -310,7 +775,10 @@ var blameTests = [...]blameTest{
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "pylib/spinnaker/reconfigure_spinnaker.py", concat(
 		repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 22),
 		repeat("c89dab0d42f1856d157357e9010f8cc6a12f5b1f", 7),
-	)},
+	), []blameTestEntry{
+		{1, 1, 22, "", ""},
+		{23, 23, 7, "", "8a9804234551d61209f67b3c89f7706f248ae805"},
+	}},
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "pylib/spinnaker/validate_configuration.py", concat(
 		repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 29),
 		repeat("1e3d328a2cabda5d0aaddc5dec65271343e0dc37", 19),
@@ -341,10 +809,42 @@ var blameTests = [...]blameTest{
 		repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 69),
 		repeat("b5d999e2986e190d81767cd3cfeda0260f9f6fb8", 7),
 		repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 4),
-	)},
+	), []blameTestEntry{
+		{1, 1, 29, "", ""},
+		{30, 30, 19, "", "d287c606d356e8d978b9673f5445b27a74ea8721"},
+		{30, 49, 15, "", ""},
+		{64, 64, 1, "", "cda6cf2be5027889bf94bd4d1c5a171422bf566c"},
+		{45, 65, 12, "", ""},
+		{57, 77, 1, "", "a24001f6938d425d0e7504bdf5d27fc866a85c3d"},
+		{57, 78, 4, "", ""},
+		{82, 82, 8, "", "cda6cf2be5027889bf94bd4d1c5a171422bf566c"},
+		{64, 90, 1, "", ""},
+		{91, 91, 4, "", "cda6cf2be5027889bf94bd4d1c5a171422bf566c"},
+		{66, 95, 46, "", ""},
+		{113, 141, 1, "", "a24001f6938d425d0e7504bdf5d27fc866a85c3d"},
+		{113, 142, 4, "", ""},
+		{137, 146, 42, "", "d287c606d356e8d978b9673f5445b27a74ea8721"},
+		{117, 188, 1, "", ""},
+		{180, 189, 1, "", "d287c606d356e8d978b9673f5445b27a74ea8721"},
+		{119, 190, 3, "", ""},
+		{184, 193, 1, "", "d287c606d356e8d978b9673f5445b27a74ea8721"},
+		{123, 194, 1, "", ""},
+		{125, 195, 8, "", "a24001f6938d425d0e7504bdf5d27fc866a85c3d"},
+		{125, 203, 1, "", ""},
+		{134, 204, 2, "", "a24001f6938d425d0e7504bdf5d27fc866a85c3d"},
+		{126, 206, 3, "", ""},
+		{200, 209, 3, "", "d287c606d356e8d978b9673f5445b27a74ea8721"},
+		{130, 212, 12, "", ""},
+		{152, 224, 10, "", "a24001f6938d425d0e7504bdf5d27fc866a85c3d"},
+		{143, 234, 69, "", ""},
+		{303, 303, 7, "", "cda6cf2be5027889bf94bd4d1c5a171422bf566c"},
+		{218, 310, 4, "", ""},
+	}},
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "pylib/spinnaker/run.py", concat(
 		repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 185),
-	)},
+	), []blameTestEntry{
+		{1, 1, 185, "", ""},
+	}},
 	/*
 		// This fails due to the different diff tool being used to create the patches.
 		// For commit c89dab0d42f1856d157357e9010f8cc6a12f5b1f our diff tool keeps an existing newline as moved in the file, whereas
@@ -371,7 +871,7 @@ var blameTests = [...]blameTest{
 			repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 43),
 		)},
 	*/
-	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "pylib/spinnaker/__init__.py", []string{}},
+	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "pylib/spinnaker/__init__.py", []string{}, nil},
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "gradle/wrapper/gradle-wrapper.jar", concat(
 		repeat("11d6c1020b1765e236ca65b2709d37b5bfdba0f4", 1),
 		repeat("bc02440df2ff95a014a7b3cb11b98c3a2bded777", 7),
@@ -421,7 +921,56 @@ var blameTests = [...]blameTest{
 		repeat("bc02440df2ff95a014a7b3cb11b98c3a2bded777", 1),
 		repeat("11d6c1020b1765e236ca65b2709d37b5bfdba0f4", 6),
 		repeat("bc02440df2ff95a014a7b3cb11b98c3a2bded777", 55),
-	)},
+	), []blameTestEntry{
+		{1, 1, 1, "", ""},
+		{2, 2, 7, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{9, 9, 2, "", ""},
+		{11, 11, 2, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{16, 13, 3, "", ""},
+		{16, 16, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{20, 17, 1, "", ""},
+		{18, 18, 10, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{33, 28, 11, "", ""},
+		{39, 39, 29, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{74, 68, 7, "", ""},
+		{75, 75, 58, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{130, 133, 1, "", ""},
+		{134, 134, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{132, 135, 1, "", ""},
+		{136, 136, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{134, 137, 2, "", ""},
+		{139, 139, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{137, 140, 2, "", ""},
+		{142, 142, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{140, 143, 13, "", ""},
+		{156, 156, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{154, 157, 4, "", ""},
+		{161, 161, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{159, 162, 3, "", ""},
+		{165, 165, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{163, 166, 13, "", ""},
+		{179, 179, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{177, 180, 2, "", ""},
+		{182, 182, 9, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{186, 191, 3, "", ""},
+		{194, 194, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{190, 195, 1, "", ""},
+		{196, 196, 17, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{209, 213, 3, "", ""},
+		{216, 216, 6, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{216, 222, 6, "", ""},
+		{228, 228, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{223, 229, 3, "", ""},
+		{232, 232, 5, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{231, 237, 4, "", ""},
+		{241, 241, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{236, 242, 3, "", ""},
+		{245, 245, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{240, 246, 2, "", ""},
+		{248, 248, 1, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+		{243, 249, 6, "", ""},
+		{255, 255, 55, "", "791bcd1592828d9d5d16e83f3a825fb08b0ba22d"},
+	}},
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "config/settings.js", concat(
 		repeat("ae904e8d60228c21c47368f6a10f1cc9ca3aeebf", 17),
 		repeat("99534ecc895fe17a1d562bb3049d4168a04d0865", 1),
@@ -429,7 +978,15 @@ var blameTests = [...]blameTest{
 		repeat("d2838db9f6ef9628645e7d04cd9658a83e8708ea", 1),
 		repeat("637ba49300f701cfbd859c1ccf13c4f39a9ba1c8", 1),
 		repeat("ae904e8d60228c21c47368f6a10f1cc9ca3aeebf", 13),
-	)},
+	), []blameTestEntry{
+		{1, 1, 17, "", ""},
+		{18, 18, 1, "", "1e14f94bcf82694fdc7e2dcbbfdbbed58db0f4d9"},
+		{19, 19, 41, "", ""},
+		{62, 60, 2, "", ""},
+		{64, 62, 1, "", "1f9684d0e81b4c80400677e029a5d483ddfb2027"},
+		{63, 63, 1, "", "4f3c7375fa7c661735a6a69beeeeac1aaa43f7c9"},
+		{64, 64, 13, "", ""},
+	}},
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "config/default-spinnaker-local.yml", concat(
 		repeat("ae904e8d60228c21c47368f6a10f1cc9ca3aeebf", 9),
 		repeat("5e09821cbd7d710405b61cab0a795c2982a71b9c", 2),
@@ -453,7 +1010,31 @@ var blameTests = [...]blameTest{
 		repeat("41e96c54a478e5d09dd07ed7feb2d8d08d8c7e3c", 14),
 		repeat("7c8d9a6081d9cb7a56c479bfe64d70540ea32795", 5),
 		repeat("5a2a845bc08974a36d599a4a4b7e25be833823b0", 2),
-	)},
+	), []blameTestEntry{
+		{1, 1, 9, "", ""},
+		{10, 10, 2, "", "c0ddf58fe5514f0a2f65059e30461f2358916e25"},
+		{12, 12, 1, "", "1e14f94bcf82694fdc7e2dcbbfdbbed58db0f4d9"},
+		{12, 13, 2, "", ""},
+		{15, 15, 1, "", "23a14bd9cbe1808001a88ce8218d5b6d0948fa8a"},
+		{19, 16, 5, "", ""},
+		{25, 21, 2, "", "c0ddf58fe5514f0a2f65059e30461f2358916e25"},
+		{23, 23, 1, "", "23a14bd9cbe1808001a88ce8218d5b6d0948fa8a"},
+		{27, 24, 5, "", ""},
+		{33, 29, 1, "", "c0ddf58fe5514f0a2f65059e30461f2358916e25"},
+		{30, 30, 1, "", "66ac94f0b4442707fb6f695fbed91d62b3bd9d4a"},
+		{34, 31, 25, "", ""},
+		{65, 56, 1, "", "95826fef343fac115001ce83c7f18e8dedc9e618"},
+		{66, 57, 1, "", "f9594594c18bcb7b3610eea25056eb1844d5131e"},
+		{66, 58, 1, "", "95826fef343fac115001ce83c7f18e8dedc9e618"},
+		{64, 59, 1, "", ""},
+		{79, 60, 23, "", ""},
+		{101, 83, 2, "", "1f9684d0e81b4c80400677e029a5d483ddfb2027"},
+		{89, 85, 1, "", "65e37611b1ff9cb589e3060507427a9a2645907e"},
+		{109, 86, 6, "", "a56ccc92c9f7b0f9beb0905fbaedbdc5516ca0a3"},
+		{115, 92, 14, "", "5a2a845bc08974a36d599a4a4b7e25be833823b0"},
+		{106, 106, 5, "", "7ecc2ad58e24a5b52504985467a10c6a3bb85b9b"},
+		{128, 111, 2, "", "a56ccc92c9f7b0f9beb0905fbaedbdc5516ca0a3"},
+	}},
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "config/spinnaker.yml", concat(
 		repeat("ae904e8d60228c21c47368f6a10f1cc9ca3aeebf", 32),
 		repeat("41e96c54a478e5d09dd07ed7feb2d8d08d8c7e3c", 2),
@@ -474,7 +1055,30 @@ var blameTests = [...]blameTest{
 		repeat("ae904e8d60228c21c47368f6a10f1cc9ca3aeebf", 39),
 		repeat("079e42e7c979541b6fab7343838f7b9fd4a360cd", 6),
 		repeat("ae904e8d60228c21c47368f6a10f1cc9ca3aeebf", 15),
-	)},
+	), []blameTestEntry{
+		{1, 1, 32, "", ""},
+		{33, 33, 2, "", "5a2a845bc08974a36d599a4a4b7e25be833823b0"},
+		{33, 35, 1, "", "a56ccc92c9f7b0f9beb0905fbaedbdc5516ca0a3"},
+		{36, 36, 6, "", "5a2a845bc08974a36d599a4a4b7e25be833823b0"},
+		{36, 42, 2, "", "a56ccc92c9f7b0f9beb0905fbaedbdc5516ca0a3"},
+		{44, 44, 2, "", "5a2a845bc08974a36d599a4a4b7e25be833823b0"},
+		{38, 46, 2, "", "a56ccc92c9f7b0f9beb0905fbaedbdc5516ca0a3"},
+		{48, 48, 3, "", "5a2a845bc08974a36d599a4a4b7e25be833823b0"},
+		{51, 51, 3, "", "7ecc2ad58e24a5b52504985467a10c6a3bb85b9b"},
+		{33, 54, 47, "", ""},
+		{82, 101, 3, "", ""},
+		{101, 104, 2, "", "1f9684d0e81b4c80400677e029a5d483ddfb2027"},
+		{103, 106, 1, "", "304cac16bddf7bfbcc1663bf408ac452d29762f2"},
+		{86, 107, 9, "", ""},
+		{100, 116, 1, "", "95826fef343fac115001ce83c7f18e8dedc9e618"},
+		{101, 117, 1, "", "f9594594c18bcb7b3610eea25056eb1844d5131e"},
+		{101, 118, 1, "", "95826fef343fac115001ce83c7f18e8dedc9e618"},
+		{100, 119, 1, "", ""},
+		{115, 120, 37, "", ""},
+		{158, 157, 1, "", ""},
+		{155, 158, 6, "", "b7b9e7c464c3c343133ed17e778a2f600b5863b8"},
+		{164, 164, 15, "", ""},
+	}},
 	/*
 		// This fails due to the different diff tool being used to create the patches
 		// For commit d1ff4e13e9e0b500821aa558373878f93487e34b our diff tool keeps an existing newline as moved in the file, whereas
@@ -549,5 +1153,7 @@ var blameTests = [...]blameTest{
 	*/
 	{"https://github.com/spinnaker/spinnaker.git", "f39d86f59a0781f130e8de6b2115329c1fbe9545", "dev/create_google_dev_vm.sh", concat(
 		repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 20),
-	)},
+	), []blameTestEntry{
+		{1, 1, 20, "dev/create_dev_vm.sh", ""},
+	}},
 }
@@ -98,6 +98,28 @@ type Config struct {
 		DefaultBranch string
 	}
 
+	Diff struct {
+		// Algorithm is the default diff algorithm: default, myers, minimal,
+		// patience or histogram.
+		Algorithm string
+		// NoIndentHeuristic disables the indent heuristic, which is enabled
+		// by default.
+		NoIndentHeuristic bool
+		// Drivers are the diff drivers, by name, used for the files with
+		// the `diff` gitattribute set to their name.
+		Drivers map[string]*DiffDriver
+	}
+
+	Mailmap struct {
+		// File is the path of a mailmap file, read after the .mailmap file
+		// of the worktree and Blob.
+		File string
+		// Blob is the revision of a blob read as a mailmap file, after the
+		// .mailmap file of the worktree, such as "HEAD:.mailmap". It
+		// defaults to "HEAD:.mailmap" in bare repositories.
+		Blob string
+	}
+
 	Extensions struct {
 		// ObjectFormat specifies the hash algorithm to use. The
 		// acceptable values are sha1 and sha256. If not specified,
@@ -107,6 +129,11 @@ type Config struct {
 		// This setting must not be changed after repository initialization
 		// (e.g. clone or init).
 		ObjectFormat format.ObjectFormat
+		// RefStorage specifies the backend used to store references. The
+		// acceptable values are files and reftable. If not specified,
+		// files is assumed. It is an error to specify this key unless
+		// core.repositoryFormatVersion is 1.
+		RefStorage format.RefStorage
 	}
 
 	// Remotes list of repository remotes, the key of the map is the name
@@ -250,6 +277,8 @@ const (
 	initSection                = "init"
 	urlSection                 = "url"
 	extensionsSection          = "extensions"
+	diffSection                = "diff"
+	mailmapSection             = "mailmap"
 	fetchKey                   = "fetch"
 	urlKey                     = "url"
 	pushurlKey                 = "pushurl"
@@ -265,7 +294,14 @@ const (
 	defaultBranchKey           = "defaultBranch"
 	repositoryFormatVersionKey = "repositoryformatversion"
 	objectFormat               = "objectformat"
+	refStorage                 = "refstorage"
 	mirrorKey                  = "mirror"
+	algorithmKey               = "algorithm"
+	indentHeuristicKey         = "indentHeuristic"
+	xfuncnameKey               = "xfuncname"
+	wordRegexKey               = "wordRegex"
+	fileKey                    = "file"
+	blobKey                    = "blob"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
@@ -283,8 +319,11 @@ func (c *Config) Unmarshal(b []byte) error {
 	}
 
 	c.unmarshalCore()
+	c.unmarshalExtensions()
 	c.unmarshalUser()
 	c.unmarshalInit()
+	c.unmarshalDiff()
+	c.unmarshalMailmap()
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
@@ -309,6 +348,19 @@ func (c *Config) unmarshalCore() {
 
 	c.Core.Worktree = s.Options.Get(worktreeKey)
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
+	c.Core.RepositoryFormatVersion = format.RepositoryFormatVersion(s.Options.Get(repositoryFormatVersionKey))
+}
+
+func (c *Config) unmarshalExtensions() {
+	// Extensions are only supported on Version 1, therefore
+	// ignore them otherwise.
+	if c.Core.RepositoryFormatVersion != format.Version_1 {
+		return
+	}
+
+	s := c.Raw.Section(extensionsSection)
+	c.Extensions.ObjectFormat = format.ObjectFormat(s.Options.Get(objectFormat))
+	c.Extensions.RefStorage = format.RefStorage(s.Options.Get(refStorage))
 }
 
 func (c *Config) unmarshalUser() {
@@ -406,6 +458,27 @@ func (c *Config) unmarshalInit() {
 	c.Init.DefaultBranch = s.Options.Get(defaultBranchKey)
 }
 
+func (c *Config) unmarshalDiff() {
+	s := c.Raw.Section(diffSection)
+	c.Diff.Algorithm = s.Options.Get(algorithmKey)
+	c.Diff.NoIndentHeuristic = s.Options.Get(indentHeuristicKey) == "false"
+
+	c.Diff.Drivers = make(map[string]*DiffDriver)
+	for _, sub := range s.Subsections {
+		c.Diff.Drivers[sub.Name] = &DiffDriver{
+			Name:      sub.Name,
+			XFuncname: sub.Options.Get(xfuncnameKey),
+			WordRegex: sub.Options.Get(wordRegexKey),
+		}
+	}
+}
+
+func (c *Config) unmarshalMailmap() {
+	s := c.Raw.Section(mailmapSection)
+	c.Mailmap.File = s.Options.Get(fileKey)
+	c.Mailmap.Blob = s.Options.Get(blobKey)
+}
+
 // Marshal returns Config encoded as a git-config file.
 func (c *Config) Marshal() ([]byte, error) {
 	c.marshalCore()
@@ -417,6 +490,8 @@ func (c *Config) Marshal() ([]byte, error) {
 	c.marshalBranches()
 	c.marshalURLs()
 	c.marshalInit()
+	c.marshalDiff()
+	c.marshalMailmap()
 
 	buf := bytes.NewBuffer(nil)
 	if err := format.NewEncoder(buf).Encode(c.Raw); err != nil {
@@ -443,7 +518,13 @@ func (c *Config) marshalExtensions() {
 	// ignore them otherwise.
 	if c.Core.RepositoryFormatVersion == format.Version_1 {
 		s := c.Raw.Section(extensionsSection)
-		s.SetOption(objectFormat, string(c.Extensions.ObjectFormat))
+		if c.Extensions.ObjectFormat != "" {
+			s.SetOption(objectFormat, string(c.Extensions.ObjectFormat))
+		}
+
+		if c.Extensions.RefStorage != "" {
+			s.SetOption(refStorage, string(c.Extensions.RefStorage))
+		}
 	}
 }
 
@@ -573,6 +654,59 @@ func (c *Config) marshalInit() {
 	}
 }
 
+func (c *Config) marshalDiff() {
+	s := c.Raw.Section(diffSection)
+	if c.Diff.Algorithm != "" {
+		s.SetOption(algorithmKey, c.Diff.Algorithm)
+	}
+
+	if c.Diff.NoIndentHeuristic {
+		s.SetOption(indentHeuristicKey, "false")
+	} else if s.Options.Get(indentHeuristicKey) == "false" {
+		s.RemoveOption(indentHeuristicKey)
+	}
+
+	for name, d := range c.Diff.Drivers {
+		sub := s.Subsection(name)
+		setOrRemoveOption(sub, xfuncnameKey, d.XFuncname)
+		setOrRemoveOption(sub, wordRegexKey, d.WordRegex)
+	}
+}
+
+func (c *Config) marshalMailmap() {
+	s := c.Raw.Section(mailmapSection)
+	if c.Mailmap.File != "" {
+		s.SetOption(fileKey, c.Mailmap.File)
+	}
+
+	if c.Mailmap.Blob != "" {
+		s.SetOption(blobKey, c.Mailmap.Blob)
+	}
+}
+
+func setOrRemoveOption(s *format.Subsection, key, value string) {
+	if value == "" {
+		s.RemoveOption(key)
+		return
+	}
+
+	s.SetOption(key, value)
+}
+
+// DiffDriver is a diff driver, configuring the diffs of the files with the
+// `diff` gitattribute set to its name.
+type DiffDriver struct {
+	// Name of the driver.
+	Name string
+	// XFuncname is the extended regular expression matching the function
+	// lines shown in the hunk headers, or a list of them separated by
+	// newlines, the ones prefixed by '!' excluding the lines they match.
+	XFuncname string
+	// WordRegex is the regular expression matching the words of the word
+	// diffs.
+	WordRegex string
+}
+
 // RemoteConfig contains the configuration for a given remote repository.
 type RemoteConfig struct {
 	// Name of the remote
@@ -395,3 +395,61 @@ func (s *ConfigSuite) TestUnmarshalRemotes(c *C) {
 	c.Assert(cfg.Remotes["origin"].URLs[1], Equals, "git@git.sr.ht:~mcepl/go-git.git")
 }
 
+func (s *ConfigSuite) TestUnmarshalMarshalDiff(c *C) {
+	input := []byte(`[diff]
+	algorithm = histogram
+	indentHeuristic = false
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Diff.Algorithm, Equals, "histogram")
+	c.Assert(cfg.Diff.NoIndentHeuristic, Equals, true)
+
+	cfg.Diff.Algorithm = "patience"
+	cfg.Diff.NoIndentHeuristic = false
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[diff]\n\talgorithm = patience\n[core]\n\tbare = false\n")
+}
+
+func (s *ConfigSuite) TestUnmarshalMarshalMailmap(c *C) {
+	input := []byte(`[mailmap]
+	file = ~/.mailmap
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Mailmap.File, Equals, "~/.mailmap")
+	c.Assert(cfg.Mailmap.Blob, Equals, "")
+
+	cfg.Mailmap.Blob = "HEAD:.mailmap"
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[mailmap]\n\tfile = ~/.mailmap\n\tblob = HEAD:.mailmap\n[core]\n\tbare = false\n")
+}
+
+func (s *ConfigSuite) TestUnmarshalMarshalDiffDrivers(c *C) {
+	input := []byte(`[diff "golang"]
+	xfuncname = "^func .*$"
+	textconv = cat
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Diff.Drivers, HasLen, 1)
+	c.Assert(cfg.Diff.Drivers["golang"].Name, Equals, "golang")
+	c.Assert(cfg.Diff.Drivers["golang"].XFuncname, Equals, "^func .*$")
+
+	cfg.Diff.Drivers["golang"].XFuncname = ""
+	cfg.Diff.Drivers["golang"].WordRegex = "[a-z]+"
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[diff \"golang\"]\n\ttextconv = cat\n\twordRegex = [a-z]+\n[core]\n\tbare = false\n")
+}
@@ -0,0 +1,746 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	commitgraph_fmt "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+)
+
+const (
+	// maxDescribeCandidates is the maximum number of candidate tags, every
+	// candidate uses a bit of the flags of the walked commits.
+	maxDescribeCandidates = 63
+	describeSeen          = uint64(1)
+
+	// containsMergeWeight is the distance added following a parent other
+	// than the first one, so the first parents are preferred, as git does.
+	containsMergeWeight = 65535
+	// containsCutoffSlop is the time before the described commit from which
+	// the commits are not walked, to cope with clock skew.
+	containsCutoffSlop = 24 * time.Hour
+)
+
+// Describe returns a human-readable name of a commit, based on the closest
+// tag reachable from it, as `<tag>-<n>-g<abbrev>`, n being the number of
+// commits on top of the tag. If the commit is tagged, the tag name is
+// returned instead. It is equivalent to running `git describe`.
+//
+// The commit-graph, if present, is used to walk the commits.
+func (r *Repository) Describe(o *DescribeOptions) (string, error) {
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+
+	target := o.Commit
+	if target.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return "", err
+		}
+
+		target = head.Hash()
+	}
+
+	index, closer := r.commitNodeIndex()
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	node, err := index.Get(target)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := r.describeTags(o)
+	if err != nil {
+		return "", err
+	}
+
+	var name string
+	if o.Contains {
+		name, err = r.describeContains(index, node, tags, o)
+	} else {
+		name, err = r.describe(node, tags, o)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if o.Dirty {
+		dirty, err := r.isDirty()
+		if err != nil {
+			return "", err
+		}
+
+		if dirty {
+			name += o.DirtyMark
+		}
+	}
+
+	return name, nil
+}
+
+// describeTag is a tag usable to describe commits.
+type describeTag struct {
+	name      string
+	commit    plumbing.Hash
+	annotated bool
+	// date is the tagger date of annotated tags, or the committer date of
+	// the commit of lightweight tags.
+	date time.Time
+}
+
+// describeTags returns the tags matching the options, sorted by name.
+func (r *Repository) describeTags(o *DescribeOptions) ([]*describeTag, error) {
+	refs, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []*describeTag
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := strings.TrimPrefix(ref.Name().String(), "refs/tags/")
+		if !matchDescribeTag(name, o) {
+			return nil
+		}
+
+		t, err := r.describeTag(name, ref.Hash(), o.Tags)
+		if err != nil || t == nil {
+			return err
+		}
+
+		tags = append(tags, t)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].name < tags[j].name
+	})
+
+	return tags, nil
+}
+
+func matchDescribeTag(name string, o *DescribeOptions) bool {
+	for _, pattern := range o.Exclude {
+		if wildmatch(pattern, name) {
+			return false
+		}
+	}
+
+	if len(o.Match) == 0 {
+		return true
+	}
+
+	for _, pattern := range o.Match {
+		if wildmatch(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wildmatch returns true if name matches the shell wildcard pattern, as git
+// matches the patterns of `git describe` and of the notes.rewriteRef config:
+// unlike path.Match, '*' and '?' also match '/', so "v*" matches "v1/rc". The
+// bracket expressions are negated by '!' or '^', and a malformed pattern
+// matches nothing.
+func wildmatch(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			for i := 0; i <= len(name); i++ {
+				if wildmatch(pattern, name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if name == "" {
+				return false
+			}
+
+			pattern = pattern[1:]
+		case '[':
+			if name == "" {
+				return false
+			}
+
+			n, ok := matchBracket(pattern, name[0])
+			if !ok {
+				return false
+			}
+
+			pattern = pattern[n:]
+		default:
+			if pattern[0] == '\\' {
+				if pattern = pattern[1:]; pattern == "" {
+					return false
+				}
+			}
+
+			if name == "" || name[0] != pattern[0] {
+				return false
+			}
+
+			pattern = pattern[1:]
+		}
+
+		name = name[1:]
+	}
+
+	return name == ""
+}
+
+// matchBracket returns the length of the bracket expression starting pattern
+// and whether c matches it, false if the expression is not terminated.
+func matchBracket(pattern string, c byte) (int, bool) {
+	i := 1
+	negated := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+	if negated {
+		i++
+	}
+
+	matched := false
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			return i + 1, matched != negated
+		}
+
+		lo := pattern[i]
+		if lo == '\\' && i+1 < len(pattern) {
+			i++
+			lo = pattern[i]
+		}
+
+		i++
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi = pattern[i+1]
+			i += 2
+			if hi == '\\' && i < len(pattern) {
+				hi = pattern[i]
+				i++
+			}
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	return 0, false
+}
+
+// describeTag returns the describeTag of a tag reference, nil if the tag
+// doesn't point to a commit or is lightweight and lightweight is false.
+func (r *Repository) describeTag(name string, h plumbing.Hash, lightweight bool) (*describeTag, error) {
+	tag, err := r.TagObject(h)
+	if err == nil {
+		h, _, err := r.peelRevision(h, plumbing.TagObject, "commit")
+		if err != nil {
+			if errors.Is(err, ErrUnexpectedObjectType) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		return &describeTag{name: name, commit: h, annotated: true, date: tag.Tagger.When}, nil
+	}
+
+	if err != plumbing.ErrObjectNotFound {
+		return nil, err
+	}
+
+	if !lightweight {
+		return nil, nil
+	}
+
+	commit, err := r.CommitObject(h)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &describeTag{name: name, commit: h, date: commit.Committer.When}, nil
+}
+
+// describeCandidate is a tag reachable from the described commit.
+type describeCandidate struct {
+	tag *describeTag
+	// depth is the number of commits reachable from the described commit
+	// and not from the tag.
+	depth int
+	flag  uint64
+}
+
+// describe returns the closest tag reachable from the commit, walking the
+// commits as `git describe` does.
+func (r *Repository) describe(node commitgraph.CommitNode, tags []*describeTag, o *DescribeOptions) (string, error) {
+	names := make(map[plumbing.Hash]*describeTag)
+	for _, t := range tags {
+		if current, ok := names[t.commit]; !ok || betterDescribeTag(current, t) {
+			names[t.commit] = t
+		}
+	}
+
+	if t, ok := names[node.ID()]; ok && !o.Long {
+		return t.name, nil
+	}
+
+	if len(names) == 0 {
+		if o.Always {
+			return r.abbreviateHash(node.ID(), o.Abbrev), nil
+		}
+
+		return "", ErrNoDescribeNames
+	}
+
+	candidates := o.Candidates
+	if candidates > maxDescribeCandidates {
+		candidates = maxDescribeCandidates
+	}
+
+	w := newDescribeWalk(o.FirstParent)
+	w.push(node, describeSeen)
+
+	var found []*describeCandidate
+	var gaveUp commitgraph.CommitNode
+	for w.Len() > 0 {
+		c := w.pop()
+		if t, ok := names[c.ID()]; ok {
+			if len(found) == candidates {
+				gaveUp = c
+				break
+			}
+
+			cand := &describeCandidate{tag: t, depth: w.seen - 1, flag: 1 << (len(found) + 1)}
+			w.flags[c.ID()] |= cand.flag
+			found = append(found, cand)
+		}
+
+		for _, cand := range found {
+			if w.flags[c.ID()]&cand.flag == 0 {
+				cand.depth++
+			}
+		}
+
+		if err := w.pushParents(c); err != nil {
+			return "", err
+		}
+	}
+
+	if len(found) == 0 {
+		if o.Always {
+			return r.abbreviateHash(node.ID(), o.Abbrev), nil
+		}
+
+		return "", fmt.Errorf("%w: %s", ErrNoDescribeTags, node.ID())
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].depth < found[j].depth
+	})
+
+	best := found[0]
+	if gaveUp != nil {
+		w.push(gaveUp, 0)
+	}
+
+	if err := w.finishDepth(best); err != nil {
+		return "", err
+	}
+
+	if o.Abbrev < 0 {
+		return best.tag.name, nil
+	}
+
+	return fmt.Sprintf("%s-%d-g%s", best.tag.name, best.depth, r.abbreviateHash(node.ID(), o.Abbrev)), nil
+}
+
+// betterDescribeTag returns true if the tag t is preferred to current to
+// describe their commit: annotated tags are preferred to lightweight ones,
+// and newer annotated tags to older ones.
+func betterDescribeTag(current, t *describeTag) bool {
+	if current.annotated != t.annotated {
+		return t.annotated
+	}
+
+	return t.annotated && current.date.Before(t.date)
+}
+
+// describeWalk walks the commits by corrected commit date, if the
+// commit-graph has it, or by committer time, propagating their flags to
+// their parents.
+type describeWalk struct {
+	firstParent bool
+	flags       map[plumbing.Hash]uint64
+	heap        *binaryheap.Heap
+	pushed      int
+	seen        int
+}
+
+type describeItem struct {
+	node commitgraph.CommitNode
+	n    int
+}
+
+func newDescribeWalk(firstParent bool) *describeWalk {
+	return &describeWalk{
+		firstParent: firstParent,
+		flags:       make(map[plumbing.Hash]uint64),
+		heap: binaryheap.NewWith(func(a, b interface{}) int {
+			ia, ib := a.(describeItem), b.(describeItem)
+			ga, gb := ia.node.GenerationV2(), ib.node.GenerationV2()
+			ta, tb := ia.node.CommitTime(), ib.node.CommitTime()
+			switch {
+			case ga != gb && ga != 0 && gb != 0:
+				if ga < gb {
+					return 1
+				}
+				return -1
+			case ta.Before(tb):
+				return 1
+			case tb.Before(ta):
+				return -1
+			case ia.n > ib.n:
+				return 1
+			default:
+				return -1
+			}
+		}),
+	}
+}
+
+func (w *describeWalk) Len() int {
+	return w.heap.Size()
+}
+
+func (w *describeWalk) push(node commitgraph.CommitNode, flags uint64) {
+	w.flags[node.ID()] |= flags
+	w.heap.Push(describeItem{node: node, n: w.pushed})
+	w.pushed++
+}
+
+func (w *describeWalk) pop() commitgraph.CommitNode {
+	v, _ := w.heap.Pop()
+	w.seen++
+	return v.(describeItem).node
+}
+
+// pushParents pushes the parents not seen yet of the commit, propagating
+// the flags of the commit to all of them.
+func (w *describeWalk) pushParents(c commitgraph.CommitNode) error {
+	flags := w.flags[c.ID()]
+	n := c.NumParents()
+	if w.firstParent && n > 1 {
+		n = 1
+	}
+
+	for i := 0; i < n; i++ {
+		p, err := c.ParentNode(i)
+		if err != nil {
+			return err
+		}
+
+		if w.flags[p.ID()]&describeSeen != 0 {
+			w.flags[p.ID()] |= flags
+			continue
+		}
+
+		w.push(p, flags)
+	}
+
+	return nil
+}
+
+// finishDepth walks the remaining commits to count the ones not reachable
+// from the best candidate, until all the remaining commits are reachable
+// from it.
+func (w *describeWalk) finishDepth(best *describeCandidate) error {
+	for w.Len() > 0 {
+		c := w.pop()
+		if w.flags[c.ID()]&best.flag != 0 {
+			if w.everyoneFlagged(best.flag) {
+				break
+			}
+		} else {
+			best.depth++
+		}
+
+		if err := w.pushParents(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *describeWalk) everyoneFlagged(flag uint64) bool {
+	for _, v := range w.heap.Values() {
+		if w.flags[v.(describeItem).node.ID()]&flag == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containsName is the name of a commit relative to a tag containing it, as
+// computed by `git name-rev`.
+type containsName struct {
+	tip        string
+	generation int
+	distance   int
+}
+
+func (n *containsName) String() string {
+	if n.generation == 0 {
+		return n.tip
+	}
+
+	return strings.TrimSuffix(n.tip, "^0") + "~" + strconv.Itoa(n.generation)
+}
+
+// parent returns the name of the i-th parent of the commit named n.
+func (n *containsName) parent(i int) *containsName {
+	if i == 0 {
+		return &containsName{n.tip, n.generation + 1, n.distance + 1}
+	}
+
+	tip := strings.TrimSuffix(n.tip, "^0")
+	if n.generation > 0 {
+		tip += "~" + strconv.Itoa(n.generation)
+	}
+
+	return &containsName{tip + "^" + strconv.Itoa(i+1), 0, n.distance + containsMergeWeight}
+}
+
+// describeContains returns the name of the commit relative to the oldest tag
+// containing it, as `git describe --contains` does.
+func (r *Repository) describeContains(
+	index commitgraph.CommitNodeIndex,
+	node commitgraph.CommitNode,
+	tags []*describeTag,
+	o *DescribeOptions,
+) (string, error) {
+	var best *containsName
+	var bestTag *describeTag
+	for _, t := range tags {
+		if bestTag != nil && bestTag.date.Before(t.date) {
+			continue
+		}
+
+		tip, err := index.Get(t.commit)
+		if err != nil {
+			return "", err
+		}
+
+		tipName := t.name
+		if t.annotated {
+			tipName += "^0"
+		}
+
+		name, err := containsPath(tip, node, tipName, o.FirstParent)
+		if err != nil {
+			return "", err
+		}
+
+		if name == nil {
+			continue
+		}
+
+		if best == nil || t.date.Before(bestTag.date) || name.distance < best.distance && t.date.Equal(bestTag.date) {
+			best, bestTag = name, t
+		}
+	}
+
+	if best == nil {
+		if o.Always {
+			return r.abbreviateHash(node.ID(), o.Abbrev), nil
+		}
+
+		return "", fmt.Errorf("%w: %s", ErrNoDescribeTags, node.ID())
+	}
+
+	return best.String(), nil
+}
+
+// containsPath returns the shortest name of the target relative to the tip,
+// nil if the target is not reachable from the tip. The commits which can't
+// reach the target, by their generation or commit time, are not walked.
+func containsPath(tip, target commitgraph.CommitNode, tipName string, firstParent bool) (*containsName, error) {
+	type item struct {
+		node commitgraph.CommitNode
+		name *containsName
+	}
+
+	cutoff := target.CommitTime().Add(-containsCutoffSlop)
+	generation := target.Generation()
+	canReach := func(c commitgraph.CommitNode) bool {
+		if c.ID() == target.ID() {
+			return true
+		}
+
+		g := c.Generation()
+		if g != 0 && g != infiniteGeneration && generation != 0 && g <= generation {
+			return false
+		}
+
+		return !c.CommitTime().Before(cutoff)
+	}
+
+	if !canReach(tip) {
+		return nil, nil
+	}
+
+	queue := binaryheap.NewWith(func(a, b interface{}) int {
+		return a.(item).name.distance - b.(item).name.distance
+	})
+
+	queue.Push(item{tip, &containsName{tip: tipName}})
+	done := make(map[plumbing.Hash]bool)
+	for {
+		v, ok := queue.Pop()
+		if !ok {
+			return nil, nil
+		}
+
+		it := v.(item)
+		if it.node.ID() == target.ID() {
+			return it.name, nil
+		}
+
+		if done[it.node.ID()] {
+			continue
+		}
+
+		done[it.node.ID()] = true
+		n := it.node.NumParents()
+		if firstParent && n > 1 {
+			n = 1
+		}
+
+		for i := 0; i < n; i++ {
+			p, err := it.node.ParentNode(i)
+			if err != nil {
+				return nil, err
+			}
+
+			if done[p.ID()] || !canReach(p) {
+				continue
+			}
+
+			queue.Push(item{p, it.name.parent(i)})
+		}
+	}
+}
+
+// infiniteGeneration is the generation of the commits not in the
+// commit-graph.
+const infiniteGeneration = ^uint64(0)
+
+// commitNodeIndex returns a CommitNodeIndex using the commit-graph of the
+// repository, if any, and the io.Closer of the commit-graph, if not nil. As
+// git does, the commit-graph is ignored if there are replaced objects.
+func (r *Repository) commitNodeIndex() (commitgraph.CommitNodeIndex, io.Closer) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	if objects, ok := r.objects().(*replaceStorer); ok {
+		return commitgraph.NewObjectCommitNodeIndex(objects), nil
+	}
+
+	if fs, ok := r.Storer.(fsBased); ok {
+		index, err := commitgraph_fmt.OpenChainOrFileIndex(fs.Filesystem())
+		if err == nil {
+			return commitgraph.NewGraphCommitNodeIndex(index, r.Storer), index
+		}
+	}
+
+	return commitgraph.NewObjectCommitNodeIndex(r.Storer), nil
+}
+
+// abbreviateHash returns the shortest unique abbreviation of the hash, with
+// at least n hexadecimal digits.
+func (r *Repository) abbreviateHash(h plumbing.Hash, n int) string {
+	s := h.String()
+	if n <= 0 {
+		n = DefaultDescribeAbbrev
+	}
+
+	if n >= len(s) {
+		return s
+	}
+
+	var others []string
+	for _, o := range expandPartialHash(r.Storer, h[:n/2]) {
+		if o != h {
+			others = append(others, o.String())
+		}
+	}
+
+	for ; n < len(s); n++ {
+		unique := true
+		for _, o := range others {
+			if strings.HasPrefix(o, s[:n]) {
+				unique = false
+				break
+			}
+		}
+
+		if unique {
+			break
+		}
+	}
+
+	return s[:n]
+}
+
+// isDirty returns true if the index or the working tree have changes in the
+// tracked files.
+func (r *Repository) isDirty() (bool, error) {
+	w, err := r.Worktree()
+	if err != nil {
+		return false, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return false, err
+	}
+
+	for _, s := range status {
+		if s.Staging != Unmodified && s.Staging != Untracked {
+			return true, nil
+		}
+
+		if s.Worktree != Unmodified && s.Worktree != Untracked {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
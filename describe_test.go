@@ -0,0 +1,235 @@
+package git
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type DescribeSuite struct {
+	BaseSuite
+
+	r       *Repository
+	commits []plumbing.Hash
+}
+
+var _ = Suite(&DescribeSuite{})
+
+// SetUpTest creates the following history, tagging c0 with v1.0, c3 with
+// the lightweight tag light and c6 with v2.0:
+//
+//	c0 - c1 - c3 - c4 - c5 - c6
+//	      \       /
+//	       c2 ---
+func (s *DescribeSuite) SetUpTest(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	s.r = r
+	s.commits = nil
+	s.commit(c)
+	s.commit(c, 0)
+	s.commit(c, 1)
+	s.commit(c, 1)
+	s.commit(c, 3, 2)
+	s.commit(c, 4)
+	s.commit(c, 5)
+
+	head := plumbing.NewHashReference(plumbing.Master, s.commits[6])
+	c.Assert(r.Storer.SetReference(head), IsNil)
+
+	s.tag(c, "v1.0", 0, true)
+	s.tag(c, "light", 3, false)
+	s.tag(c, "v2.0", 6, true)
+}
+
+func (s *DescribeSuite) signature(i int) object.Signature {
+	return object.Signature{
+		Name:  "foo",
+		Email: "foo@foo.foo",
+		When:  time.Unix(int64(1500000000+i*3600), 0).UTC(),
+	}
+}
+
+func (s *DescribeSuite) commit(c *C, parents ...int) {
+	tree := s.r.Storer.NewEncodedObject()
+	c.Assert((&object.Tree{}).Encode(tree), IsNil)
+	treeHash, err := s.r.Storer.SetEncodedObject(tree)
+	c.Assert(err, IsNil)
+
+	i := len(s.commits)
+	commit := &object.Commit{
+		Author:    s.signature(i),
+		Committer: s.signature(i),
+		Message:   fmt.Sprintf("c%d\n", i),
+		TreeHash:  treeHash,
+	}
+
+	for _, p := range parents {
+		commit.ParentHashes = append(commit.ParentHashes, s.commits[p])
+	}
+
+	obj := s.r.Storer.NewEncodedObject()
+	c.Assert(commit.Encode(obj), IsNil)
+	h, err := s.r.Storer.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	s.commits = append(s.commits, h)
+}
+
+func (s *DescribeSuite) tag(c *C, name string, commit int, annotated bool) {
+	var opts *CreateTagOptions
+	if annotated {
+		tagger := s.signature(commit)
+		opts = &CreateTagOptions{Tagger: &tagger, Message: name}
+	}
+
+	_, err := s.r.CreateTag(name, s.commits[commit], opts)
+	c.Assert(err, IsNil)
+}
+
+func (s *DescribeSuite) describe(c *C, o *DescribeOptions) string {
+	name, err := s.r.Describe(o)
+	c.Assert(err, IsNil)
+	return name
+}
+
+func (s *DescribeSuite) abbrev(i int) string {
+	return s.commits[i].String()[:7]
+}
+
+func (s *DescribeSuite) TestDescribe(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{}), Equals, "v2.0")
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[5]}), Equals,
+		"v1.0-5-g"+s.abbrev(5))
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[2]}), Equals,
+		"v1.0-2-g"+s.abbrev(2))
+}
+
+func (s *DescribeSuite) TestDescribeTags(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[5], Tags: true}), Equals,
+		"light-3-g"+s.abbrev(5))
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[3], Tags: true}), Equals,
+		"light")
+}
+
+func (s *DescribeSuite) TestDescribeMatchAndExclude(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{
+		Commit: s.commits[5],
+		Tags:   true,
+		Match:  []string{"v*"},
+	}), Equals, "v1.0-5-g"+s.abbrev(5))
+
+	c.Assert(s.describe(c, &DescribeOptions{
+		Commit:  s.commits[5],
+		Tags:    true,
+		Exclude: []string{"l*"},
+	}), Equals, "v1.0-5-g"+s.abbrev(5))
+
+	_, err := s.r.Describe(&DescribeOptions{Match: []string{"foo*"}})
+	c.Assert(err, Equals, ErrNoDescribeNames)
+}
+
+func (s *DescribeSuite) TestDescribeMatchSlash(c *C) {
+	s.tag(c, "v1/rc", 5, false)
+
+	c.Assert(s.describe(c, &DescribeOptions{
+		Commit: s.commits[5],
+		Tags:   true,
+		Match:  []string{"v*"},
+	}), Equals, "v1/rc")
+
+	c.Assert(s.describe(c, &DescribeOptions{
+		Commit:  s.commits[5],
+		Tags:    true,
+		Exclude: []string{"v?/*", "l*"},
+	}), Equals, "v1.0-5-g"+s.abbrev(5))
+}
+
+func (s *DescribeSuite) TestWildmatch(c *C) {
+	for _, t := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"v*", "v1/rc", true},
+		{"v?rc", "v/rc", true},
+		{"*", "", true},
+		{"v*.0", "v1.0/1.0", true},
+		{"v*.0", "v1.0.1", false},
+		{"v[0-9].*", "v1.0", true},
+		{"v[!0-9]*", "v1.0", false},
+		{"v[^a-z]*", "v1.0", true},
+		{"v[]]", "v]", true},
+		{"v[a-]", "v-", true},
+		{`v\*`, "v*", true},
+		{`v\*`, "v1", false},
+		{"v[1", "v1", false},
+		{`v\`, `v\`, false},
+		{"v", "v1", false},
+	} {
+		c.Assert(wildmatch(t.pattern, t.name), Equals, t.match,
+			Commentf("pattern=%q, name=%q", t.pattern, t.name))
+	}
+}
+
+func (s *DescribeSuite) TestDescribeAbbrev(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[5], Abbrev: -1}), Equals, "v1.0")
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[5], Abbrev: 12}), Equals,
+		"v1.0-5-g"+s.commits[5].String()[:12])
+}
+
+func (s *DescribeSuite) TestDescribeLong(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{Long: true}), Equals, "v2.0-0-g"+s.abbrev(6))
+}
+
+func (s *DescribeSuite) TestDescribeAlways(c *C) {
+	o := &DescribeOptions{Match: []string{"foo*"}, Always: true}
+	c.Assert(s.describe(c, o), Equals, s.abbrev(6))
+}
+
+func (s *DescribeSuite) TestDescribeNoTags(c *C) {
+	o := &DescribeOptions{Commit: s.commits[0], Match: []string{"v2*"}}
+	_, err := s.r.Describe(o)
+	c.Assert(err, ErrorMatches, "no tags can describe the commit: .*")
+
+	o.Always = true
+	c.Assert(s.describe(c, o), Equals, s.abbrev(0))
+}
+
+func (s *DescribeSuite) TestDescribeFirstParent(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[5], FirstParent: true}), Equals,
+		"v1.0-4-g"+s.abbrev(5))
+}
+
+func (s *DescribeSuite) TestDescribeContains(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[6], Contains: true}), Equals, "v2.0^0")
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[4], Contains: true}), Equals, "v2.0~2")
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[2], Contains: true}), Equals, "v2.0~2^2")
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[1], Contains: true}), Equals, "light~1")
+	c.Assert(s.describe(c, &DescribeOptions{Commit: s.commits[0], Contains: true}), Equals, "v1.0^0")
+}
+
+func (s *DescribeSuite) TestDescribeDirty(c *C) {
+	c.Assert(s.describe(c, &DescribeOptions{Dirty: true}), Equals, "v2.0")
+
+	w, err := s.r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644), IsNil)
+	c.Assert(s.describe(c, &DescribeOptions{Dirty: true}), Equals, "v2.0")
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+	c.Assert(s.describe(c, &DescribeOptions{Dirty: true}), Equals, "v2.0-dirty")
+	c.Assert(s.describe(c, &DescribeOptions{Dirty: true, DirtyMark: "*"}), Equals, "v2.0*")
+
+	_, err = s.r.Describe(&DescribeOptions{Commit: s.commits[0], Dirty: true})
+	c.Assert(err, Equals, ErrDescribeDirtyCommit)
+}
@@ -0,0 +1,421 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/ioutil"
+)
+
+const (
+	// mboxFromLine is the first line of every message, git uses a fixed
+	// date so the line can be recognized as written by format-patch.
+	mboxFromLine    = "From %s Mon Sep 17 00:00:00 2001\n"
+	mailDateFormat  = "Mon, 2 Jan 2006 15:04:05 -0700"
+	coverSubject    = "*** SUBJECT HERE ***"
+	coverBlurb      = "*** BLURB HERE ***"
+	coverLetterName = "0000-cover-letter.patch"
+	// maxPatchNameLength is the maximum length of the subject part of the
+	// name of the patch files.
+	maxPatchNameLength = 64
+)
+
+// formatPatch is a commit being formatted as an email message.
+type formatPatch struct {
+	commit *object.Commit
+	patch  *object.Patch
+}
+
+// FormatPatch formats the commits of a revision range as email messages in
+// the mbox format, each one with the message and the patch of a commit,
+// from the oldest to the newest. It is equivalent to running
+// `git format-patch <range>`, the range being any revision accepted by
+// LogOptions.Revisions. A single revision, not being a range, selects the
+// commits not reachable from it that are reachable from HEAD, as git does.
+// The merge commits and the commits without changes are skipped.
+//
+// The binary files are written as `Binary files differ`, so they cannot be
+// applied.
+func (r *Repository) FormatPatch(rev plumbing.Revision, o *FormatPatchOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	patches, err := r.formatPatches(rev, o.PatchOptions)
+	if err != nil {
+		return err
+	}
+
+	if len(patches) == 0 {
+		return nil
+	}
+
+	total := len(patches)
+	numbered := o.Numbered || o.CoverLetter || total > 1
+
+	if o.CoverLetter {
+		if err := o.write(coverLetterName, func(w io.Writer) error {
+			return r.writeCoverLetter(w, patches, o)
+		}); err != nil {
+			return err
+		}
+	}
+
+	for i, p := range patches {
+		subject, body := splitCommitMessage(p.commit.Message)
+		prefix := "[" + o.SubjectPrefix + "]"
+		if numbered {
+			prefix = fmt.Sprintf("[%s %d/%d]", o.SubjectPrefix, i+1, total)
+		}
+
+		name := fmt.Sprintf("%04d-%s.patch", i+1, patchFileName(subject))
+		if err := o.write(name, func(w io.Writer) error {
+			return writePatchMessage(w, p, prefix, subject, body, o)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatPatches returns the commits of the range, from the oldest to the
+// newest, with their patches.
+func (r *Repository) formatPatches(rev plumbing.Revision, po *object.PatchOptions) ([]*formatPatch, error) {
+	s := rev.String()
+	if !strings.Contains(s, "..") && !strings.HasPrefix(s, "^") {
+		s += "..HEAD"
+	}
+
+	iter, err := r.Log(&LogOptions{
+		Revisions: []plumbing.Revision{plumbing.Revision(s)},
+		NoMerges:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var patches []*formatPatch
+	err = iter.ForEach(func(c *object.Commit) error {
+		p, err := commitPatch(c, po)
+		if err != nil {
+			return err
+		}
+
+		if len(p.FilePatches()) != 0 {
+			patches = append(patches, &formatPatch{commit: c, patch: p})
+		}
+
+		return nil
+	})
+	if err != nil && err != storer.ErrStop {
+		return nil, err
+	}
+
+	for i, j := 0, len(patches)-1; i < j; i, j = i+1, j-1 {
+		patches[i], patches[j] = patches[j], patches[i]
+	}
+
+	return patches, nil
+}
+
+// commitPatch returns the patch of a commit against its first parent, or
+// against the empty tree for the root commits.
+func commitPatch(c *object.Commit, po *object.PatchOptions) (*object.Patch, error) {
+	var from *object.Tree
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if from, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	to, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return treesPatch(from, to, po)
+}
+
+func treesPatch(from, to *object.Tree, po *object.PatchOptions) (*object.Patch, error) {
+	ctx := context.Background()
+	changes, err := object.DiffTreeWithOptions(ctx, from, to, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes.PatchWithOptions(ctx, po)
+}
+
+// write writes a message to the output, or to a file of the output
+// directory.
+func (o *FormatPatchOptions) write(name string, fn func(io.Writer) error) (err error) {
+	if o.Output != nil {
+		return fn(o.Output)
+	}
+
+	f, err := o.OutputDirectory.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return fn(f)
+}
+
+func writePatchMessage(w io.Writer, p *formatPatch, prefix, subject, body string, o *FormatPatchOptions) error {
+	buf := bytes.NewBuffer(nil)
+	writeMailHeaders(buf, p.commit.Hash, &p.commit.Author, prefix, subject,
+		!isASCII(p.commit.Author.Name) || !isASCII(p.commit.Message))
+
+	buf.WriteString("\n")
+	if body != "" {
+		buf.WriteString(body)
+	}
+
+	buf.WriteString("---\n")
+	if !o.NoStat {
+		writeDiffStat(buf, p.patch)
+		buf.WriteString("\n")
+	}
+
+	if err := fdiff.NewUnifiedEncoder(buf, fdiff.DefaultContextLines).Encode(p.patch); err != nil {
+		return err
+	}
+
+	writeMailSignature(buf, o.Signature)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeCoverLetter writes the cover letter of the patches, with the shortlog
+// and the diffstat of the whole range.
+func (r *Repository) writeCoverLetter(w io.Writer, patches []*formatPatch, o *FormatPatchOptions) error {
+	first, last := patches[0].commit, patches[len(patches)-1].commit
+
+	var from *object.Tree
+	if first.NumParents() != 0 {
+		parent, err := first.Parent(0)
+		if err != nil {
+			return err
+		}
+
+		if from, err = parent.Tree(); err != nil {
+			return err
+		}
+	}
+
+	to, err := last.Tree()
+	if err != nil {
+		return err
+	}
+
+	patch, err := treesPatch(from, to, o.PatchOptions)
+	if err != nil {
+		return err
+	}
+
+	shortlog := shortlogPatches(patches)
+
+	buf := bytes.NewBuffer(nil)
+	prefix := fmt.Sprintf("[%s 0/%d]", o.SubjectPrefix, len(patches))
+	writeMailHeaders(buf, last.Hash, o.Author, prefix, coverSubject,
+		!isASCII(o.Author.Name) || !isASCII(shortlog))
+
+	fmt.Fprintf(buf, "\n%s\n\n%s", coverBlurb, shortlog)
+	writeDiffStat(buf, patch)
+	buf.WriteString("\n")
+	writeMailSignature(buf, o.Signature)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// shortlogPatches returns the subjects of the patches grouped by author, as
+// `git shortlog` does.
+func shortlogPatches(patches []*formatPatch) string {
+	subjects := make(map[string][]string)
+	var authors []string
+	for _, p := range patches {
+		name := p.commit.Author.Name
+		if _, ok := subjects[name]; !ok {
+			authors = append(authors, name)
+		}
+
+		subject, _ := splitCommitMessage(p.commit.Message)
+		subjects[name] = append(subjects[name], subject)
+	}
+
+	sort.Strings(authors)
+
+	var sb strings.Builder
+	for _, name := range authors {
+		fmt.Fprintf(&sb, "%s (%d):\n", name, len(subjects[name]))
+		for _, s := range subjects[name] {
+			fmt.Fprintf(&sb, "  %s\n", s)
+		}
+
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+func writeMailHeaders(buf *bytes.Buffer, h plumbing.Hash, author *object.Signature, prefix, subject string, mimeHeaders bool) {
+	fmt.Fprintf(buf, mboxFromLine, h)
+	fmt.Fprintf(buf, "From: %s <%s>\n", encodeMailName(author.Name), author.Email)
+	fmt.Fprintf(buf, "Date: %s\n", author.When.Format(mailDateFormat))
+	fmt.Fprintf(buf, "Subject: %s %s\n", prefix, mime.QEncoding.Encode("UTF-8", subject))
+
+	if mimeHeaders {
+		buf.WriteString("MIME-Version: 1.0\n")
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\n")
+		buf.WriteString("Content-Transfer-Encoding: 8bit\n")
+	}
+}
+
+func writeMailSignature(buf *bytes.Buffer, signature string) {
+	if signature != "" {
+		fmt.Fprintf(buf, "-- \n%s\n", strings.TrimRight(signature, "\n"))
+	}
+
+	buf.WriteString("\n")
+}
+
+// writeDiffStat writes the diffstat of a patch followed by the summary of
+// the changes, as `git diff --stat --summary` does.
+func writeDiffStat(buf *bytes.Buffer, p *object.Patch) {
+	stats := p.Stats()
+	buf.WriteString(stats.String())
+
+	var files, insertions, deletions int
+	var summary []string
+	for _, fp := range p.FilePatches() {
+		files++
+
+		from, to := fp.Files()
+		switch {
+		case from == nil:
+			summary = append(summary, fmt.Sprintf(" create mode %o %s", to.Mode(), to.Path()))
+		case to == nil:
+			summary = append(summary, fmt.Sprintf(" delete mode %o %s", from.Mode(), from.Path()))
+		case from.Path() != to.Path():
+			summary = append(summary, fmt.Sprintf(" rename %s => %s", from.Path(), to.Path()))
+		case from.Mode() != to.Mode():
+			summary = append(summary, fmt.Sprintf(" mode change %o => %o %s", from.Mode(), to.Mode(), to.Path()))
+		}
+	}
+
+	for _, s := range stats {
+		insertions += s.Addition
+		deletions += s.Deletion
+	}
+
+	fmt.Fprintf(buf, " %d %s changed", files, plural(files, "file", "files"))
+	if insertions != 0 || deletions == 0 {
+		fmt.Fprintf(buf, ", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+
+	if deletions != 0 || insertions == 0 {
+		fmt.Fprintf(buf, ", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+
+	buf.WriteString("\n")
+	for _, s := range summary {
+		buf.WriteString(s + "\n")
+	}
+}
+
+func plural(n int, one, other string) string {
+	if n == 1 {
+		return one
+	}
+
+	return other
+}
+
+// splitCommitMessage returns the subject of a commit message, its first
+// paragraph joined in a single line, and the rest of the message.
+func splitCommitMessage(msg string) (subject, body string) {
+	lines := strings.Split(strings.TrimLeft(msg, "\n"), "\n")
+
+	var title []string
+	i := 0
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		title = append(title, strings.TrimSpace(lines[i]))
+	}
+
+	body = strings.TrimSpace(strings.Join(lines[i:], "\n"))
+	if body != "" {
+		body += "\n"
+	}
+
+	return strings.Join(title, " "), body
+}
+
+// encodeMailName encodes the name of an address, quoting it if it has
+// special characters, or as an encoded-word if it isn't ASCII.
+func encodeMailName(name string) string {
+	if !isASCII(name) {
+		return mime.QEncoding.Encode("UTF-8", name)
+	}
+
+	if strings.ContainsAny(name, `()<>[]:;@\,."`) {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+
+	return name
+}
+
+// patchFileName returns the name of the file of a patch from its subject,
+// as git does.
+func patchFileName(subject string) string {
+	var sb strings.Builder
+	dash := false
+	for _, c := range subject {
+		if c < utf8.RuneSelf && (c == '.' || c == '_' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			if dash && sb.Len() != 0 {
+				sb.WriteByte('-')
+			}
+
+			sb.WriteRune(c)
+			dash = false
+			continue
+		}
+
+		dash = true
+	}
+
+	name := sb.String()
+	if len(name) > maxPatchNameLength {
+		name = name[:maxPatchNameLength]
+	}
+
+	return strings.TrimRight(strings.TrimLeft(name, "."), ".-")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,272 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/utils/diff"
+
+	. "gopkg.in/check.v1"
+)
+
+type FormatPatchSuite struct {
+	BaseSuite
+
+	fs      billy.Filesystem
+	r       *Repository
+	commits []plumbing.Hash
+}
+
+var _ = Suite(&FormatPatchSuite{})
+
+func (s *FormatPatchSuite) SetUpTest(c *C) {
+	s.fs = memfs.New()
+	r, err := Init(memory.NewStorage(), s.fs)
+	c.Assert(err, IsNil)
+
+	s.r = r
+	s.commits = nil
+	s.commit(c, "A U Thor", "initial\n", "a.txt", "a\nb\n")
+	s.commit(c, "A U Thor", "first change\ncontinued title\n\nbody line\n", "a.txt", "a\nB\n")
+	s.commit(c, "Jösé", "Ünïcode second\n", "n.txt", "x\n")
+}
+
+func (s *FormatPatchSuite) commit(c *C, author, msg, name, content string) {
+	s.commits = append(s.commits, commitPatchFile(c, s.r, len(s.commits), author, msg, name, content))
+}
+
+// commitPatchFile writes the file in the worktree of the repository and
+// commits it, the date of the author depending on the number of previous
+// commits n, so the patches of the series have fixed hashes.
+func commitPatchFile(c *C, r *Repository, n int, author, msg, name, content string) plumbing.Hash {
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, name, []byte(content), 0644), IsNil)
+	_, err = w.Add(name)
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit(msg, &CommitOptions{Author: &object.Signature{
+		Name:  author,
+		Email: "author@example.com",
+		When:  time.Date(2005, 4, 7, 15, 13, 13+n, 0, time.FixedZone("", -7*3600)),
+	}})
+	c.Assert(err, IsNil)
+
+	return h
+}
+
+func (s *FormatPatchSuite) TestFormatPatch(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.r.FormatPatch("HEAD~2", &FormatPatchOptions{Output: buf, Signature: "go-git"})
+	c.Assert(err, IsNil)
+
+	c.Assert(buf.String(), Equals, fmt.Sprintf(`From %[1]s Mon Sep 17 00:00:00 2001
+From: A U Thor <author@example.com>
+Date: Thu, 7 Apr 2005 15:13:14 -0700
+Subject: [PATCH 1/2] first change continued title
+
+body line
+---
+ a.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+diff --git a/a.txt b/a.txt
+index 422c2b7ab3b3c668038da977e4e93a5fc623169c..55dce135f5939fc45738aec42a917794a39cbfce 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++B
+%[3]s
+From %[2]s Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?J=C3=B6s=C3=A9?= <author@example.com>
+Date: Thu, 7 Apr 2005 15:13:15 -0700
+Subject: [PATCH 2/2] =?UTF-8?q?=C3=9Cn=C3=AFcode_second?=
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: 8bit
+
+---
+ n.txt | 1 +
+ 1 file changed, 1 insertion(+)
+ create mode 100644 n.txt
+
+diff --git a/n.txt b/n.txt
+new file mode 100644
+index 0000000000000000000000000000000000000000..587be6b4c3f93f93c489c0111bba5596147a26cb
+--- /dev/null
++++ b/n.txt
+@@ -0,0 +1 @@
++x
+%[3]s
+`, s.commits[1], s.commits[2], "-- \ngo-git\n"))
+}
+
+func (s *FormatPatchSuite) TestFormatPatchSingle(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := s.r.FormatPatch("HEAD~2..HEAD~1", &FormatPatchOptions{
+		Output:        buf,
+		SubjectPrefix: "RFC PATCH",
+		NoStat:        true,
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(buf.String(), Equals, fmt.Sprintf(`From %s Mon Sep 17 00:00:00 2001
+From: A U Thor <author@example.com>
+Date: Thu, 7 Apr 2005 15:13:14 -0700
+Subject: [RFC PATCH] first change continued title
+
+body line
+---
+diff --git a/a.txt b/a.txt
+index 422c2b7ab3b3c668038da977e4e93a5fc623169c..55dce135f5939fc45738aec42a917794a39cbfce 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++B
+
+`, s.commits[1]))
+
+	buf.Reset()
+	err = s.r.FormatPatch("HEAD~2..HEAD~1", &FormatPatchOptions{Output: buf, Numbered: true})
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(buf.String(), "Subject: [PATCH 1/1] first change"), Equals, true)
+}
+
+func (s *FormatPatchSuite) TestFormatPatchCoverLetter(c *C) {
+	dir := memfs.New()
+	err := s.r.FormatPatch("HEAD~2", &FormatPatchOptions{
+		OutputDirectory: dir,
+		CoverLetter:     true,
+		Author: &object.Signature{
+			Name:  "Sender",
+			Email: "sender@example.com",
+			When:  time.Date(2005, 4, 8, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	c.Assert(err, IsNil)
+
+	files, err := dir.ReadDir("")
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range files {
+		names = append(names, fi.Name())
+	}
+
+	c.Assert(names, DeepEquals, []string{
+		"0000-cover-letter.patch",
+		"0001-first-change-continued-title.patch",
+		"0002-n-code-second.patch",
+	})
+
+	cover, err := util.ReadFile(dir, "0000-cover-letter.patch")
+	c.Assert(err, IsNil)
+	c.Assert(string(cover), Equals, fmt.Sprintf(`From %s Mon Sep 17 00:00:00 2001
+From: Sender <sender@example.com>
+Date: Fri, 8 Apr 2005 00:00:00 +0000
+Subject: [PATCH 0/2] *** SUBJECT HERE ***
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: 8bit
+
+*** BLURB HERE ***
+
+A U Thor (1):
+  first change continued title
+
+Jösé (1):
+  Ünïcode second
+
+ a.txt | 2 +-
+ n.txt | 1 +
+ 2 files changed, 2 insertions(+), 1 deletion(-)
+ create mode 100644 n.txt
+
+
+`, s.commits[2]))
+}
+
+func (s *FormatPatchSuite) TestFormatPatchOptions(c *C) {
+	err := s.r.FormatPatch("HEAD~1", &FormatPatchOptions{})
+	c.Assert(err, Equals, ErrMissingFormatPatchOutput)
+
+	err = s.r.FormatPatch("HEAD~1", &FormatPatchOptions{
+		Output:          bytes.NewBuffer(nil),
+		OutputDirectory: memfs.New(),
+	})
+	c.Assert(err, Equals, ErrFormatPatchOutputs)
+
+	buf := bytes.NewBuffer(nil)
+	err = s.r.FormatPatch("HEAD", &FormatPatchOptions{Output: buf})
+	c.Assert(err, IsNil)
+	c.Assert(buf.Len(), Equals, 0)
+
+	err = s.r.FormatPatch("HEAD~3..HEAD", &FormatPatchOptions{Output: buf})
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *FormatPatchSuite) TestFormatPatchDiffAlgorithm(c *C) {
+	s.commit(c, "A U Thor", "before\n", "d.txt", "}\n{\n\n  x\n")
+	s.commit(c, "A U Thor", "after\n", "d.txt", "b\n\nc\na\n\nc\n}\n")
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Diff.Algorithm = "patience"
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = s.r.FormatPatch("HEAD~1", &FormatPatchOptions{Output: buf, NoStat: true})
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(buf.String(), `@@ -1,4 +1,7 @@
++b
++
++c
++a
++
++c
+ }
+-{
+-
+-  x
+`), Equals, true, Commentf("%s", buf.String()))
+
+	buf.Reset()
+	err = s.r.FormatPatch("HEAD~1", &FormatPatchOptions{
+		Output:       buf,
+		NoStat:       true,
+		PatchOptions: &object.PatchOptions{Algorithm: diff.Histogram},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(buf.String(), `@@ -1,4 +1,7 @@
+-}
+-{
++b
+ 
+-  x
++c
++a
++
++c
++}
+`), Equals, true, Commentf("%s", buf.String()))
+
+	cfg.Diff.Algorithm = "foo"
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	err = s.r.FormatPatch("HEAD~1", &FormatPatchOptions{Output: buf})
+	c.Assert(errors.Is(err, diff.ErrUnknownAlgorithm), Equals, true)
+}
@@ -47,7 +47,8 @@ type CaretReg struct {
 	Negate bool
 }
 
-// CaretType represents ^{commit}
+// CaretType represents ^{commit}, ^{tree}, ^{blob}, ^{tag}, ^{object} and
+// ^{}, whose ObjectType is empty
 type CaretType struct {
 	ObjectType string
 }
@@ -415,7 +416,7 @@ func (p *Parser) parseCaretBraces() (Revisioner, error) {
 		case tok == word && nextTok == cbrace && (lit == "commit" || lit == "tree" || lit == "blob" || lit == "tag" || lit == "object"):
 			return CaretType{lit}, nil
 		case re == "" && tok == cbrace:
-			return CaretType{"tag"}, nil
+			return CaretType{""}, nil
 		case re == "" && tok == emark && nextTok == emark:
 			re += lit
 		case re == "" && tok == emark && nextTok == minus:
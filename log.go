@@ -0,0 +1,574 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/mailmap"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// CommitSide is the side of a symmetric difference, <rev1>...<rev2>, a commit
+// is reachable from.
+type CommitSide int8
+
+const (
+	// NoSide is the side of the commits not in a symmetric difference.
+	NoSide CommitSide = iota
+	// LeftSide is the side of the commits reachable from <rev1>.
+	LeftSide
+	// RightSide is the side of the commits reachable from <rev2>.
+	RightSide
+)
+
+// LeftRightCommitIter is the CommitIter returned by Repository.Log when
+// LogOptions.LeftRight is set.
+type LeftRightCommitIter interface {
+	object.CommitIter
+	// Side returns the side of the symmetric difference the commit with the
+	// given hash is reachable from.
+	Side(plumbing.Hash) CommitSide
+}
+
+type leftRightCommitIter struct {
+	object.CommitIter
+	sides map[plumbing.Hash]CommitSide
+}
+
+func (i *leftRightCommitIter) Side(h plumbing.Hash) CommitSide {
+	return i.sides[h]
+}
+
+// followCommitIter is the CommitIter returned by Repository.Log when
+// LogOptions.Follow is set and the commits are filtered after being followed.
+type followCommitIter struct {
+	object.CommitIter
+	follow object.CommitFollowIter
+}
+
+func (i *followCommitIter) Path(h plumbing.Hash) string {
+	return i.follow.Path(h)
+}
+
+// Side returns the side of the commit if LogOptions.LeftRight is also set.
+func (i *followCommitIter) Side(h plumbing.Hash) CommitSide {
+	if lr, ok := i.CommitIter.(LeftRightCommitIter); ok {
+		return lr.Side(h)
+	}
+
+	return NoSide
+}
+
+const (
+	walkSeen uint8 = 1 << iota
+	walkDone
+	walkUninteresting
+	walkLeft
+	walkRight
+	walkBottom
+)
+
+// walkSlop is the number of commits walked once all the commits to walk are
+// uninteresting, to cope with clock skew, as git does.
+const walkSlop = 5
+
+// revisionWalk walks the commits reachable from a set of included commits,
+// but not from a set of excluded ones, in committer time order.
+type revisionWalk struct {
+	r           *Repository
+	firstParent bool
+
+	flags   map[plumbing.Hash]uint8
+	commits map[plumbing.Hash]*object.Commit
+	heap    *binaryheap.Heap
+	pushed  int
+}
+
+// walkItem is a commit to walk, with the order it was pushed in, to walk the
+// commits with the same committer time in that order.
+type walkItem struct {
+	c *object.Commit
+	n int
+}
+
+func newRevisionWalk(r *Repository, firstParent bool) *revisionWalk {
+	return &revisionWalk{
+		r:           r,
+		firstParent: firstParent,
+		flags:       make(map[plumbing.Hash]uint8),
+		commits:     make(map[plumbing.Hash]*object.Commit),
+		heap: binaryheap.NewWith(func(a, b interface{}) int {
+			ia, ib := a.(walkItem), b.(walkItem)
+			switch {
+			case ia.c.Committer.When.Before(ib.c.Committer.When):
+				return 1
+			case ib.c.Committer.When.Before(ia.c.Committer.When):
+				return -1
+			case ia.n > ib.n:
+				return 1
+			default:
+				return -1
+			}
+		}),
+	}
+}
+
+// add adds a commit to walk with the given flags.
+func (w *revisionWalk) add(c *object.Commit, flags uint8) {
+	if flags&walkUninteresting != 0 {
+		w.markUninteresting(c.Hash)
+	}
+
+	f := w.flags[c.Hash]
+	w.flags[c.Hash] = f | flags | walkSeen
+	if f&walkSeen == 0 {
+		w.push(c)
+	}
+}
+
+func (w *revisionWalk) push(c *object.Commit) {
+	w.heap.Push(walkItem{c: c, n: w.pushed})
+	w.pushed++
+}
+
+// parents returns the hashes of the parents followed by the walk.
+func (w *revisionWalk) parents(c *object.Commit) []plumbing.Hash {
+	if w.firstParent && len(c.ParentHashes) > 1 {
+		return c.ParentHashes[:1]
+	}
+
+	return c.ParentHashes
+}
+
+// markUninteresting marks the commit and its already walked ancestors as
+// uninteresting. The ancestors not yet walked are marked when walked.
+func (w *revisionWalk) markUninteresting(h plumbing.Hash) {
+	pending := []plumbing.Hash{h}
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		f := w.flags[h]
+		if f&walkUninteresting != 0 {
+			continue
+		}
+
+		w.flags[h] = f | walkUninteresting
+		if c, ok := w.commits[h]; ok {
+			pending = append(pending, w.parents(c)...)
+		}
+	}
+}
+
+// everybodyUninteresting returns true if all the commits to walk are
+// uninteresting.
+func (w *revisionWalk) everybodyUninteresting() bool {
+	for _, v := range w.heap.Values() {
+		if w.flags[v.(walkItem).c.Hash]&walkUninteresting == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// walk returns the interesting commits, sorted by committer time.
+func (w *revisionWalk) walk() ([]*object.Commit, error) {
+	var list []*object.Commit
+	slop := walkSlop
+	for {
+		v, ok := w.heap.Pop()
+		if !ok {
+			break
+		}
+
+		c := v.(walkItem).c
+		f := w.flags[c.Hash] | walkDone
+		w.flags[c.Hash] = f
+		w.commits[c.Hash] = c
+
+		inherited := f & (walkUninteresting | walkLeft | walkRight)
+		for _, h := range w.parents(c) {
+			pf := w.flags[h]
+			if inherited&walkUninteresting != 0 {
+				w.markUninteresting(h)
+			}
+
+			w.flags[h] |= inherited | walkSeen
+			if pf&walkSeen != 0 {
+				continue
+			}
+
+			p, err := w.r.CommitObject(h)
+			if err != nil {
+				return nil, err
+			}
+
+			w.push(p)
+		}
+
+		if f&walkUninteresting == 0 {
+			list = append(list, c)
+			slop = walkSlop
+			continue
+		}
+
+		if w.everybodyUninteresting() {
+			if slop--; slop == 0 {
+				break
+			}
+		}
+	}
+
+	result := list[:0]
+	for _, c := range list {
+		if w.flags[c.Hash]&walkUninteresting == 0 {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// onAncestryPath returns the commits of the list, sorted from newest to
+// oldest, which are descendants of any bottom commit.
+func (w *revisionWalk) onAncestryPath(list []*object.Commit) []*object.Commit {
+	onPath := make(map[plumbing.Hash]bool)
+	for i := len(list) - 1; i >= 0; i-- {
+		c := list[i]
+		for _, h := range w.parents(c) {
+			if onPath[h] || w.flags[h]&walkBottom != 0 {
+				onPath[c.Hash] = true
+				break
+			}
+		}
+	}
+
+	result := list[:0]
+	for _, c := range list {
+		if onPath[c.Hash] {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// logRevisions returns the commits selected by the revisions of the options,
+// and, if LeftRight is set, their sides.
+func (r *Repository) logRevisions(o *LogOptions) (object.CommitIter, map[plumbing.Hash]CommitSide, error) {
+	switch o.Order {
+	case LogOrderDefault, LogOrderCommitterTime:
+	default:
+		return nil, nil, fmt.Errorf("invalid Order=%v for revision ranges", o.Order)
+	}
+
+	w := newRevisionWalk(r, o.FirstParent)
+	for _, revs := range []struct {
+		list []plumbing.Revision
+		not  bool
+	}{{o.Revisions, false}, {o.Not, true}} {
+		for _, rev := range revs.list {
+			if err := r.addLogRevision(w, rev.String(), revs.not); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if o.All {
+		if err := r.addLogAll(w); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(o.Revisions) == 0 && !o.All {
+		c, err := r.logFrom(o.From)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		w.add(c, 0)
+	}
+
+	list, err := w.walk()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if o.AncestryPath {
+		list = w.onAncestryPath(list)
+	}
+
+	var sides map[plumbing.Hash]CommitSide
+	if o.LeftRight {
+		sides = make(map[plumbing.Hash]CommitSide, len(list))
+		for _, c := range list {
+			switch f := w.flags[c.Hash]; {
+			case f&walkLeft != 0:
+				sides[c.Hash] = LeftSide
+			case f&walkRight != 0:
+				sides[c.Hash] = RightSide
+			}
+		}
+	}
+
+	objects := r.objects()
+	return object.NewCommitIter(objects,
+		storer.NewEncodedObjectLookupIter(objects, plumbing.CommitObject, commitHashes(list))), sides, nil
+}
+
+// addLogRevision adds to the walk the commits of a revision, which can be a
+// range or be prefixed by ^ to exclude it. The commits are excluded instead
+// of included if not is true, and the other way around.
+func (r *Repository) addLogRevision(w *revisionWalk, rev string, not bool) error {
+	exclude, include := walkUninteresting|walkBottom, uint8(0)
+	if not {
+		exclude, include = include, exclude
+	}
+
+	// The dots of a range may also appear in a side, as in HEAD^{/a..b}, so
+	// the revision is split at the first dots whose sides resolve, and is a
+	// single revision if there are none. The error of the first split is
+	// returned if the single revision doesn't resolve either.
+	var rangeErr error
+	for i := 0; ; i++ {
+		n := strings.Index(rev[i:], "..")
+		if n < 0 {
+			break
+		}
+
+		i += n
+		if strings.HasPrefix(rev[i:], "...") {
+			left, right, err := r.logRange(rev[:i], rev[i+3:])
+			if err == nil {
+				return addLogSymmetricDifference(w, left, right, include, exclude)
+			}
+
+			if rangeErr == nil {
+				rangeErr = err
+			}
+		}
+
+		from, to, err := r.logRange(rev[:i], rev[i+2:])
+		if err == nil {
+			w.add(from, exclude)
+			w.add(to, include)
+			return nil
+		}
+
+		if rangeErr == nil {
+			rangeErr = err
+		}
+	}
+
+	flags := include
+	if strings.HasPrefix(rev, "^") {
+		rev, flags = rev[1:], exclude
+	}
+
+	c, err := r.logRevision(rev)
+	if err != nil && rangeErr != nil {
+		return rangeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w.add(c, flags)
+	return nil
+}
+
+// addLogSymmetricDifference adds to the walk the commits of the left or the
+// right commit, excluding the ones of both, as done by left...right.
+func addLogSymmetricDifference(w *revisionWalk, left, right *object.Commit, include, exclude uint8) error {
+	bases, err := left.MergeBase(right)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range bases {
+		w.add(b, exclude)
+	}
+
+	w.add(left, include|walkLeft)
+	w.add(right, include|walkRight)
+	return nil
+}
+
+// addLogAll adds to the walk the commits of all the references.
+func (r *Repository) addLogAll(w *revisionWalk) error {
+	refs, err := r.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		ref, err := storer.ResolveReference(r.Storer, ref.Name())
+		if err == plumbing.ErrReferenceNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		o, err := r.objects().EncodedObject(plumbing.AnyObject, ref.Hash())
+		if err != nil {
+			return err
+		}
+
+		h, _, err := r.peelRevision(o.Hash(), o.Type(), "")
+		if err != nil {
+			return err
+		}
+
+		c, err := r.CommitObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		w.add(c, 0)
+		return nil
+	})
+}
+
+func (r *Repository) logRange(from, to string) (*object.Commit, *object.Commit, error) {
+	f, err := r.logRevision(from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t, err := r.logRevision(to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, t, nil
+}
+
+// logRevision returns the commit of the revision, HEAD if empty.
+func (r *Repository) logRevision(rev string) (*object.Commit, error) {
+	if rev == "" {
+		rev = plumbing.HEAD.String()
+	}
+
+	h, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CommitObject(*h)
+}
+
+// logFrom returns the given commit, or the one of HEAD if zero.
+func (r *Repository) logFrom(from plumbing.Hash) (*object.Commit, error) {
+	if from.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return nil, err
+		}
+
+		from = head.Hash()
+	}
+
+	return r.CommitObject(from)
+}
+
+func commitHashes(commits []*object.Commit) []plumbing.Hash {
+	hashes := make([]plumbing.Hash, len(commits))
+	for i, c := range commits {
+		hashes[i] = c.Hash
+	}
+
+	return hashes
+}
+
+// mailmapCommitIter maps the authors and committers of the commits to their
+// canonical identities.
+type mailmapCommitIter struct {
+	object.CommitIter
+	mailmap *mailmap.Mailmap
+}
+
+func (i *mailmapCommitIter) Next() (*object.Commit, error) {
+	c, err := i.CommitIter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return mailmapCommit(i.mailmap, c), nil
+}
+
+func (i *mailmapCommitIter) ForEach(cb func(*object.Commit) error) error {
+	return i.CommitIter.ForEach(func(c *object.Commit) error {
+		return cb(mailmapCommit(i.mailmap, c))
+	})
+}
+
+// commitFilterIter filters the commits by the merges, author and message
+// options of LogOptions.
+type commitFilterIter struct {
+	object.CommitIter
+	o *LogOptions
+}
+
+func newCommitFilterIter(it object.CommitIter, o *LogOptions) object.CommitIter {
+	return &commitFilterIter{CommitIter: it, o: o}
+}
+
+func (i *commitFilterIter) match(c *object.Commit) bool {
+	merge := len(c.ParentHashes) > 1
+	switch {
+	case i.o.Merges && !merge, i.o.NoMerges && merge:
+		return false
+	case i.o.Author != nil && !i.o.Author.MatchString(fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email)):
+		return false
+	case i.o.Grep != nil && !i.o.Grep.MatchString(c.Message):
+		return false
+	}
+
+	return true
+}
+
+func (i *commitFilterIter) Next() (*object.Commit, error) {
+	for {
+		c, err := i.CommitIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if i.match(c) {
+			return c, nil
+		}
+	}
+}
+
+func (i *commitFilterIter) ForEach(cb func(*object.Commit) error) error {
+	for {
+		c, err := i.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := cb(c); err != nil {
+			if err == storer.ErrStop {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,767 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// DefaultNotesRef is the notes reference used when none is given nor
+// configured with core.notesRef.
+const DefaultNotesRef plumbing.ReferenceName = "refs/notes/commits"
+
+var (
+	ErrNoteNotFound        = errors.New("note not found")
+	ErrNoteExists          = errors.New("note already exists")
+	ErrInvalidNotesRef     = errors.New("notes reference outside of refs/notes/")
+	ErrNotesMergeConflict  = errors.New("notes merge conflict")
+	ErrInvalidNotesMerge   = errors.New("invalid notes merge strategy")
+	ErrInvalidNotesRewrite = errors.New("invalid notes rewrite mode")
+)
+
+// Note is a note attached to an object.
+type Note struct {
+	// Object is the hash of the annotated object.
+	Object plumbing.Hash
+	// Hash is the hash of the blob with the content of the note.
+	Hash plumbing.Hash
+	// Content is the content of the note.
+	Content []byte
+}
+
+// Notes is a notes reference, the notes are stored in the tree of the commit
+// it points to, in files named after the hash of the annotated objects,
+// fanned out in directories as `ab/cdef...` when there are many notes.
+// For more information: https://git-scm.com/docs/git-notes
+type Notes struct {
+	r    *Repository
+	name plumbing.ReferenceName
+}
+
+// NotesRef returns the notes stored in the given notes reference. If the
+// name is empty, core.notesRef or DefaultNotesRef is used. Names not
+// starting with refs/notes/ are expanded as git does, so "foo" and
+// "notes/foo" are both refs/notes/foo.
+func (r *Repository) NotesRef(name plumbing.ReferenceName) (*Notes, error) {
+	if name == "" {
+		cfg, err := r.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		name = plumbing.ReferenceName(cfg.Raw.Section("core").Option("notesRef"))
+		if name == "" {
+			name = DefaultNotesRef
+		}
+	}
+
+	name = expandNotesRef(name)
+	if !name.IsNote() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidNotesRef, name)
+	}
+
+	return &Notes{r: r, name: name}, nil
+}
+
+func expandNotesRef(name plumbing.ReferenceName) plumbing.ReferenceName {
+	s := name.String()
+	switch {
+	case strings.HasPrefix(s, "refs/"):
+		return name
+	case strings.HasPrefix(s, "notes/"):
+		return plumbing.ReferenceName("refs/" + s)
+	default:
+		return plumbing.ReferenceName("refs/notes/" + s)
+	}
+}
+
+// Name returns the name of the notes reference.
+func (n *Notes) Name() plumbing.ReferenceName {
+	return n.name
+}
+
+// Get returns the note of the object, ErrNoteNotFound if it has none.
+func (n *Notes) Get(obj plumbing.Hash) (*Note, error) {
+	_, t, err := n.load()
+	if err != nil {
+		return nil, err
+	}
+
+	h, ok := t.notes[obj]
+	if !ok {
+		return nil, ErrNoteNotFound
+	}
+
+	return n.note(obj, h)
+}
+
+// ForEach calls cb for every note, sorted by the hash of the annotated
+// object. If cb returns storer.ErrStop the iteration is stopped but no error
+// is returned.
+func (n *Notes) ForEach(cb func(*Note) error) error {
+	_, t, err := n.load()
+	if err != nil {
+		return err
+	}
+
+	objs := make([]plumbing.Hash, 0, len(t.notes))
+	for obj := range t.notes {
+		objs = append(objs, obj)
+	}
+
+	plumbing.HashesSort(objs)
+	for _, obj := range objs {
+		note, err := n.note(obj, t.notes[obj])
+		if err != nil {
+			return err
+		}
+
+		if err := cb(note); err != nil {
+			if err == storer.ErrStop {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add adds a note to the object. If the object has a note ErrNoteExists is
+// returned, unless Force is set. An empty content removes the note. It is
+// equivalent to running `git notes add`.
+func (n *Notes) Add(obj plumbing.Hash, content []byte, o *NotesOptions) error {
+	return n.update(o, "Notes added by 'git notes add'", func(t *notesTree) error {
+		if _, ok := t.notes[obj]; ok && !o.Force {
+			return ErrNoteExists
+		}
+
+		return n.setNote(t, obj, content)
+	})
+}
+
+// Append appends the content to the note of the object, separated by an
+// empty line, or adds it if the object has no note. It is equivalent to
+// running `git notes append`.
+func (n *Notes) Append(obj plumbing.Hash, content []byte, o *NotesOptions) error {
+	return n.update(o, "Notes added by 'git notes append'", func(t *notesTree) error {
+		current, err := n.content(t, obj)
+		if err != nil {
+			return err
+		}
+
+		return n.setNote(t, obj, concatenateNotes(current, content))
+	})
+}
+
+// Remove removes the note of the object, ErrNoteNotFound is returned if it
+// has none. It is equivalent to running `git notes remove`.
+func (n *Notes) Remove(obj plumbing.Hash, o *NotesOptions) error {
+	return n.update(o, "Notes removed by 'git notes remove'", func(t *notesTree) error {
+		if _, ok := t.notes[obj]; !ok {
+			return ErrNoteNotFound
+		}
+
+		delete(t.notes, obj)
+		return nil
+	})
+}
+
+// Copy copies the note of an object to another one. ErrNoteNotFound is
+// returned if from has no note, and ErrNoteExists if to has one, unless
+// Force is set. It is equivalent to running `git notes copy`.
+func (n *Notes) Copy(from, to plumbing.Hash, o *NotesOptions) error {
+	return n.update(o, "Notes added by 'git notes copy'", func(t *notesTree) error {
+		h, ok := t.notes[from]
+		if !ok {
+			return ErrNoteNotFound
+		}
+
+		if _, ok := t.notes[to]; ok && !o.Force {
+			return ErrNoteExists
+		}
+
+		t.notes[to] = h
+		return nil
+	})
+}
+
+// Merge merges the notes of another notes reference into these notes,
+// creating a merge commit, or fast-forwarding if possible. The notes changed
+// in both sides are combined using the merge strategy. With the manual
+// strategy, ErrNotesMergeConflict is returned, listing the conflicting
+// objects, and the notes are left unchanged. It is equivalent to running
+// `git notes merge`.
+func (n *Notes) Merge(other plumbing.ReferenceName, o *NotesMergeOptions) error {
+	if err := o.Validate(n.r, n.name); err != nil {
+		return err
+	}
+
+	other = expandNotesRef(other)
+	otherRef, err := n.r.Reference(other, true)
+	if err != nil {
+		return err
+	}
+
+	theirs, err := n.r.CommitObject(otherRef.Hash())
+	if err != nil {
+		return err
+	}
+
+	ref, ours, err := n.commit()
+	if err != nil {
+		return err
+	}
+
+	if ours == nil {
+		return n.setRef(ref, theirs.Hash)
+	}
+
+	if ours.Hash == theirs.Hash {
+		return nil
+	}
+
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return err
+	}
+
+	base := &notesTree{notes: make(map[plumbing.Hash]plumbing.Hash)}
+	if len(bases) > 0 {
+		switch bases[0].Hash {
+		case theirs.Hash:
+			return nil
+		case ours.Hash:
+			return n.setRef(ref, theirs.Hash)
+		}
+
+		if base, err = n.tree(bases[0]); err != nil {
+			return err
+		}
+	}
+
+	oursTree, err := n.tree(ours)
+	if err != nil {
+		return err
+	}
+
+	theirsTree, err := n.tree(theirs)
+	if err != nil {
+		return err
+	}
+
+	if err := n.merge(base, oursTree, theirsTree, o.Strategy); err != nil {
+		return err
+	}
+
+	if o.Message == "" {
+		o.Message = fmt.Sprintf("Merged notes from %s into %s", other, n.name)
+	}
+
+	return n.write(ref, oursTree, []plumbing.Hash{ours.Hash, theirs.Hash}, &o.NotesOptions)
+}
+
+// merge merges the changes from base to theirs into ours.
+func (n *Notes) merge(base, ours, theirs *notesTree, strategy NotesMergeStrategy) error {
+	objs := make(map[plumbing.Hash]bool)
+	for _, t := range []*notesTree{base, ours, theirs} {
+		for obj := range t.notes {
+			objs[obj] = true
+		}
+	}
+
+	var conflicts []string
+	for obj := range objs {
+		b, l, r := base.notes[obj], ours.notes[obj], theirs.notes[obj]
+		if l == r || r == b {
+			continue
+		}
+
+		if l == b {
+			n.setHash(ours, obj, r)
+			continue
+		}
+
+		var content []byte
+		switch strategy {
+		case NotesMergeOurs:
+			continue
+		case NotesMergeTheirs:
+			n.setHash(ours, obj, r)
+			continue
+		case NotesMergeManual:
+			conflicts = append(conflicts, obj.String())
+			continue
+		}
+
+		local, err := n.content(ours, obj)
+		if err != nil {
+			return err
+		}
+
+		remote, err := n.content(theirs, obj)
+		if err != nil {
+			return err
+		}
+
+		if strategy == NotesMergeUnion {
+			content = concatenateNotes(local, remote)
+		} else {
+			content = catSortUniqNotes(local, remote)
+		}
+
+		if err := n.setNote(ours, obj, content); err != nil {
+			return err
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("%w: %s", ErrNotesMergeConflict, strings.Join(conflicts, ", "))
+	}
+
+	return nil
+}
+
+// RewriteNotes copies the notes of rewritten objects to their new versions,
+// as git does after amending or rebasing commits, to the notes references
+// matching the notes.rewriteRef globs. The copy is done only if
+// notes.rewrite.<cmd> isn't false, and the notes are combined following
+// notes.rewriteMode, concatenate by default.
+func (r *Repository) RewriteNotes(cmd string, rewritten map[plumbing.Hash]plumbing.Hash, o *NotesOptions) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	section := cfg.Raw.Section("notes")
+	patterns := section.OptionAll("rewriteRef")
+	if len(patterns) == 0 || section.Subsection("rewrite").Option(cmd) == "false" {
+		return nil
+	}
+
+	mode := NotesRewriteMode(section.Option("rewriteMode"))
+	if mode == "" {
+		mode = NotesRewriteConcatenate
+	}
+
+	if err := mode.Validate(); err != nil {
+		return err
+	}
+
+	refs, err := r.Notes()
+	if err != nil {
+		return err
+	}
+
+	var names []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		for _, pattern := range patterns {
+			if wildmatch(pattern, ref.Name().String()) {
+				names = append(names, ref.Name())
+				break
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		n := &Notes{r: r, name: name}
+		err := n.update(o, "Notes added by 'git notes copy'", func(t *notesTree) error {
+			return n.rewrite(t, rewritten, mode)
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *Notes) rewrite(t *notesTree, rewritten map[plumbing.Hash]plumbing.Hash, mode NotesRewriteMode) error {
+	for from, to := range rewritten {
+		h, ok := t.notes[from]
+		if !ok {
+			continue
+		}
+
+		current, ok := t.notes[to]
+		if !ok || mode == NotesRewriteOverwrite {
+			t.notes[to] = h
+			continue
+		}
+
+		if mode == NotesRewriteIgnore || current == h {
+			continue
+		}
+
+		cur, err := n.content(t, to)
+		if err != nil {
+			return err
+		}
+
+		note, err := n.content(t, from)
+		if err != nil {
+			return err
+		}
+
+		var content []byte
+		if mode == NotesRewriteConcatenate {
+			content = concatenateNotes(cur, note)
+		} else {
+			content = catSortUniqNotes(cur, note)
+		}
+
+		if err := n.setNote(t, to, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// concatenateNotes returns the contents of both notes, separated by an
+// empty line.
+func concatenateNotes(current, note []byte) []byte {
+	if len(current) == 0 {
+		return note
+	}
+
+	if len(note) == 0 {
+		return current
+	}
+
+	current = bytes.TrimSuffix(current, []byte("\n"))
+	content := make([]byte, 0, len(current)+len(note)+2)
+	content = append(content, current...)
+	content = append(content, '\n', '\n')
+	return append(content, note...)
+}
+
+// catSortUniqNotes returns the sorted non-empty lines of both notes, without
+// duplicates.
+func catSortUniqNotes(current, note []byte) []byte {
+	lines := make(map[string]bool)
+	for _, content := range [][]byte{current, note} {
+		for _, line := range strings.Split(string(content), "\n") {
+			if line != "" {
+				lines[line] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(lines))
+	for line := range lines {
+		sorted = append(sorted, line)
+	}
+
+	sort.Strings(sorted)
+
+	var buf bytes.Buffer
+	for _, line := range sorted {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// notesTree is the content of the tree of a notes commit.
+type notesTree struct {
+	notes map[plumbing.Hash]plumbing.Hash
+	// others are the files which are not notes, kept as they are.
+	others []object.TreeEntry
+}
+
+// commit returns the notes reference and the commit it points to, both nil
+// if the reference doesn't exist.
+func (n *Notes) commit() (*plumbing.Reference, *object.Commit, error) {
+	ref, err := n.r.Storer.Reference(n.name)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := n.r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ref, c, nil
+}
+
+func (n *Notes) load() (*plumbing.Reference, *notesTree, error) {
+	ref, c, err := n.commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c == nil {
+		return nil, &notesTree{notes: make(map[plumbing.Hash]plumbing.Hash)}, nil
+	}
+
+	t, err := n.tree(c)
+	return ref, t, err
+}
+
+func (n *Notes) tree(c *object.Commit) (*notesTree, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &notesTree{notes: make(map[plumbing.Hash]plumbing.Hash)}
+	w := object.NewTreeWalker(tree, true, nil)
+	defer w.Close()
+
+	for {
+		name, e, err := w.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Mode == filemode.Dir {
+			continue
+		}
+
+		if obj, ok := notePath(name); ok && e.Mode == filemode.Regular {
+			t.notes[obj] = e.Hash
+			continue
+		}
+
+		t.others = append(t.others, object.TreeEntry{Name: name, Mode: e.Mode, Hash: e.Hash})
+	}
+}
+
+// notePath returns the hash of the object annotated by the file with the
+// given path, false if the path isn't a fanned out hash.
+func notePath(name string) (plumbing.Hash, bool) {
+	s := strings.ReplaceAll(name, "/", "")
+	if len(s) != hash.HexSize || !plumbing.IsHash(s) {
+		return plumbing.ZeroHash, false
+	}
+
+	return plumbing.NewHash(s), true
+}
+
+func (n *Notes) note(obj, h plumbing.Hash) (*Note, error) {
+	content, err := n.blob(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Note{Object: obj, Hash: h, Content: content}, nil
+}
+
+// content returns the content of the note of the object, nil if it has none.
+func (n *Notes) content(t *notesTree, obj plumbing.Hash) ([]byte, error) {
+	h, ok := t.notes[obj]
+	if !ok {
+		return nil, nil
+	}
+
+	return n.blob(h)
+}
+
+func (n *Notes) blob(h plumbing.Hash) ([]byte, error) {
+	b, err := n.r.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// setNote sets the content of the note of the object, removing the note if
+// empty.
+func (n *Notes) setNote(t *notesTree, obj plumbing.Hash, content []byte) error {
+	if len(content) == 0 {
+		delete(t.notes, obj)
+		return nil
+	}
+
+	o := n.r.Storer.NewEncodedObject()
+	o.SetType(plumbing.BlobObject)
+	w, err := o.Writer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	h, err := n.r.Storer.SetEncodedObject(o)
+	if err != nil {
+		return err
+	}
+
+	t.notes[obj] = h
+	return nil
+}
+
+func (n *Notes) setHash(t *notesTree, obj, h plumbing.Hash) {
+	if h.IsZero() {
+		delete(t.notes, obj)
+		return
+	}
+
+	t.notes[obj] = h
+}
+
+// update loads the notes, applies fn and commits the result.
+func (n *Notes) update(o *NotesOptions, msg string, fn func(*notesTree) error) error {
+	if err := o.Validate(n.r); err != nil {
+		return err
+	}
+
+	if o.Message == "" {
+		o.Message = msg
+	}
+
+	ref, t, err := n.load()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(t); err != nil {
+		return err
+	}
+
+	var parents []plumbing.Hash
+	if ref != nil {
+		parents = append(parents, ref.Hash())
+	}
+
+	return n.write(ref, t, parents, o)
+}
+
+// write commits the notes tree and updates the notes reference, if it still
+// points to old.
+func (n *Notes) write(old *plumbing.Reference, t *notesTree, parents []plumbing.Hash, o *NotesOptions) error {
+	files := make([]object.TreeEntry, 0, len(t.notes)+len(t.others))
+	fanout := notesFanout(len(t.notes))
+	for obj, h := range t.notes {
+		files = append(files, object.TreeEntry{Name: fanoutPath(obj, fanout), Mode: filemode.Regular, Hash: h})
+	}
+
+	files = append(files, t.others...)
+	tree, err := n.writeTree(files)
+	if err != nil {
+		return err
+	}
+
+	commit := &object.Commit{
+		Author:       *o.Author,
+		Committer:    *o.Committer,
+		Message:      strings.TrimSpace(o.Message) + "\n",
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+
+	obj := n.r.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return err
+	}
+
+	h, err := n.r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return n.setRef(old, h)
+}
+
+func (n *Notes) setRef(old *plumbing.Reference, h plumbing.Hash) error {
+	return n.r.Storer.CheckAndSetReference(plumbing.NewHashReference(n.name, h), old)
+}
+
+// writeTree writes the trees of the files, returning the hash of the root.
+func (n *Notes) writeTree(files []object.TreeEntry) (plumbing.Hash, error) {
+	t := &object.Tree{}
+	dirs := make(map[string][]object.TreeEntry)
+	for _, f := range files {
+		dir, rest, ok := strings.Cut(f.Name, "/")
+		if !ok {
+			t.Entries = append(t.Entries, f)
+			continue
+		}
+
+		dirs[dir] = append(dirs[dir], object.TreeEntry{Name: rest, Mode: f.Mode, Hash: f.Hash})
+	}
+
+	for dir, files := range dirs {
+		h, err := n.writeTree(files)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		t.Entries = append(t.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: h})
+	}
+
+	sort.Sort(sortableEntries(t.Entries))
+	o := n.r.Storer.NewEncodedObject()
+	if err := t.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return n.r.Storer.SetEncodedObject(o)
+}
+
+// notesFanout returns the number of directory levels used to store the given
+// number of notes: one more level for every 256 times more notes.
+func notesFanout(notes int) int {
+	fanout := 0
+	for limit := 256; notes >= limit && fanout < hash.Size-1; limit *= 256 {
+		fanout++
+	}
+
+	return fanout
+}
+
+// fanoutPath returns the path of the note of the object with the given
+// fanout, as `ab/cdef...` for a fanout of one.
+func fanoutPath(obj plumbing.Hash, fanout int) string {
+	s := obj.String()
+	var b strings.Builder
+	for i := 0; i < fanout; i++ {
+		b.WriteString(s[i*2 : i*2+2])
+		b.WriteByte('/')
+	}
+
+	b.WriteString(s[fanout*2:])
+	return b.String()
+}
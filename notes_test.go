@@ -0,0 +1,374 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type NotesSuite struct {
+	BaseSuite
+
+	r *Repository
+}
+
+var _ = Suite(&NotesSuite{})
+
+var (
+	notesCommitA = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	notesCommitB = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	notesCommitC = plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+)
+
+func (s *NotesSuite) SetUpTest(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	s.r = r
+}
+
+func (s *NotesSuite) options() *NotesOptions {
+	return &NotesOptions{Author: defaultSignature(), Committer: defaultSignature()}
+}
+
+func (s *NotesSuite) notes(c *C, name plumbing.ReferenceName) *Notes {
+	n, err := s.r.NotesRef(name)
+	c.Assert(err, IsNil)
+	return n
+}
+
+func (s *NotesSuite) assertNote(c *C, n *Notes, obj plumbing.Hash, content string) {
+	note, err := n.Get(obj)
+	c.Assert(err, IsNil)
+	c.Assert(note.Object, Equals, obj)
+	c.Assert(string(note.Content), Equals, content)
+}
+
+func (s *NotesSuite) TestNotesRef(c *C) {
+	c.Assert(s.notes(c, "").Name(), Equals, DefaultNotesRef)
+	c.Assert(s.notes(c, "ci").Name(), Equals, plumbing.ReferenceName("refs/notes/ci"))
+	c.Assert(s.notes(c, "notes/ci").Name(), Equals, plumbing.ReferenceName("refs/notes/ci"))
+	c.Assert(s.notes(c, "refs/notes/ci").Name(), Equals, plumbing.ReferenceName("refs/notes/ci"))
+
+	_, err := s.r.NotesRef("refs/heads/master")
+	c.Assert(err, ErrorMatches, ErrInvalidNotesRef.Error()+".*")
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption("notesRef", "refs/notes/review")
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	c.Assert(s.notes(c, "").Name(), Equals, plumbing.ReferenceName("refs/notes/review"))
+}
+
+func (s *NotesSuite) TestAdd(c *C) {
+	n := s.notes(c, "")
+
+	_, err := n.Get(notesCommitA)
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	c.Assert(n.Add(notesCommitA, []byte("foo\n"), s.options()), IsNil)
+	s.assertNote(c, n, notesCommitA, "foo\n")
+
+	err = n.Add(notesCommitA, []byte("bar\n"), s.options())
+	c.Assert(err, Equals, ErrNoteExists)
+
+	c.Assert(n.Add(notesCommitA, []byte("bar\n"), &NotesOptions{Author: defaultSignature(), Force: true}), IsNil)
+	s.assertNote(c, n, notesCommitA, "bar\n")
+
+	ref, err := s.r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+
+	commit, err := s.r.CommitObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Notes added by 'git notes add'\n")
+	c.Assert(commit.Author.Name, Equals, "foo")
+	c.Assert(commit.ParentHashes, HasLen, 1)
+
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries, HasLen, 1)
+	c.Assert(tree.Entries[0].Name, Equals, notesCommitA.String())
+	c.Assert(tree.Entries[0].Mode, Equals, filemode.Regular)
+}
+
+func (s *NotesSuite) TestAppend(c *C) {
+	n := s.notes(c, "")
+
+	c.Assert(n.Append(notesCommitA, []byte("foo\n"), s.options()), IsNil)
+	s.assertNote(c, n, notesCommitA, "foo\n")
+
+	c.Assert(n.Append(notesCommitA, []byte("bar\n"), s.options()), IsNil)
+	s.assertNote(c, n, notesCommitA, "foo\n\nbar\n")
+}
+
+func (s *NotesSuite) TestRemove(c *C) {
+	n := s.notes(c, "")
+
+	c.Assert(n.Remove(notesCommitA, s.options()), Equals, ErrNoteNotFound)
+
+	c.Assert(n.Add(notesCommitA, []byte("foo\n"), s.options()), IsNil)
+	c.Assert(n.Add(notesCommitB, []byte("bar\n"), s.options()), IsNil)
+	c.Assert(n.Remove(notesCommitA, s.options()), IsNil)
+
+	_, err := n.Get(notesCommitA)
+	c.Assert(err, Equals, ErrNoteNotFound)
+	s.assertNote(c, n, notesCommitB, "bar\n")
+}
+
+func (s *NotesSuite) TestCopy(c *C) {
+	n := s.notes(c, "")
+
+	c.Assert(n.Copy(notesCommitA, notesCommitB, s.options()), Equals, ErrNoteNotFound)
+
+	c.Assert(n.Add(notesCommitA, []byte("foo\n"), s.options()), IsNil)
+	c.Assert(n.Add(notesCommitC, []byte("bar\n"), s.options()), IsNil)
+	c.Assert(n.Copy(notesCommitA, notesCommitB, s.options()), IsNil)
+	s.assertNote(c, n, notesCommitB, "foo\n")
+
+	c.Assert(n.Copy(notesCommitA, notesCommitC, s.options()), Equals, ErrNoteExists)
+	c.Assert(n.Copy(notesCommitA, notesCommitC, &NotesOptions{Author: defaultSignature(), Force: true}), IsNil)
+	s.assertNote(c, n, notesCommitC, "foo\n")
+}
+
+func (s *NotesSuite) TestForEach(c *C) {
+	n := s.notes(c, "")
+	c.Assert(n.Add(notesCommitB, []byte("b\n"), s.options()), IsNil)
+	c.Assert(n.Add(notesCommitA, []byte("a\n"), s.options()), IsNil)
+
+	var notes []string
+	err := n.ForEach(func(note *Note) error {
+		notes = append(notes, note.Object.String()+" "+string(note.Content))
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(notes, DeepEquals, []string{
+		notesCommitA.String() + " a\n",
+		notesCommitB.String() + " b\n",
+	})
+}
+
+func (s *NotesSuite) TestFanout(c *C) {
+	c.Assert(notesFanout(0), Equals, 0)
+	c.Assert(notesFanout(255), Equals, 0)
+	c.Assert(notesFanout(256), Equals, 1)
+	c.Assert(notesFanout(65536), Equals, 2)
+	c.Assert(fanoutPath(notesCommitA, 0), Equals, notesCommitA.String())
+	c.Assert(fanoutPath(notesCommitA, 2), Equals, "6e/cf/0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	n := s.notes(c, "")
+	t := &notesTree{notes: make(map[plumbing.Hash]plumbing.Hash)}
+	for i := 0; i < 256; i++ {
+		obj := plumbing.ComputeHash(plumbing.BlobObject, []byte(fmt.Sprint(i)))
+		c.Assert(n.setNote(t, obj, []byte(obj.String())), IsNil)
+	}
+
+	c.Assert(n.write(nil, t, nil, s.options()), IsNil)
+
+	ref, err := s.r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+
+	commit, err := s.r.CommitObject(ref.Hash())
+	c.Assert(err, IsNil)
+
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+	for _, e := range tree.Entries {
+		c.Assert(e.Name, HasLen, 2)
+		c.Assert(e.Mode, Equals, filemode.Dir)
+	}
+
+	count := 0
+	err = n.ForEach(func(note *Note) error {
+		c.Assert(string(note.Content), Equals, note.Object.String())
+		count++
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 256)
+}
+
+func (s *NotesSuite) TestKeepOtherFiles(c *C) {
+	n := s.notes(c, "")
+	t := &notesTree{
+		notes:  make(map[plumbing.Hash]plumbing.Hash),
+		others: []object.TreeEntry{{Name: "foo/bar", Mode: filemode.Regular, Hash: notesCommitC}},
+	}
+	c.Assert(n.write(nil, t, nil, s.options()), IsNil)
+	c.Assert(n.Add(notesCommitA, []byte("foo\n"), s.options()), IsNil)
+
+	_, t, err := n.load()
+	c.Assert(err, IsNil)
+	c.Assert(t.notes, HasLen, 1)
+	c.Assert(t.others, DeepEquals, []object.TreeEntry{{Name: "foo/bar", Mode: filemode.Regular, Hash: notesCommitC}})
+}
+
+// setUpMerge creates refs/notes/commits and refs/notes/other, both with
+// notes on A, B and C changed from a common base.
+func (s *NotesSuite) setUpMerge(c *C) *Notes {
+	n := s.notes(c, "")
+	c.Assert(n.Add(notesCommitA, []byte("base\n"), s.options()), IsNil)
+	c.Assert(n.Add(notesCommitB, []byte("base\n"), s.options()), IsNil)
+
+	base, err := s.r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+
+	other := s.notes(c, "other")
+	c.Assert(s.r.Storer.SetReference(plumbing.NewHashReference(other.Name(), base.Hash())), IsNil)
+
+	force := &NotesOptions{Author: defaultSignature(), Force: true}
+	c.Assert(n.Add(notesCommitA, []byte("b\nours\n"), force), IsNil)
+	c.Assert(other.Add(notesCommitA, []byte("a\ntheirs\nb\n"), force), IsNil)
+	c.Assert(other.Remove(notesCommitB, s.options()), IsNil)
+	c.Assert(other.Add(notesCommitC, []byte("theirs\n"), s.options()), IsNil)
+
+	return n
+}
+
+func (s *NotesSuite) assertMerge(c *C, strategy NotesMergeStrategy, a string) {
+	n := s.setUpMerge(c)
+	err := n.Merge("other", &NotesMergeOptions{NotesOptions: *s.options(), Strategy: strategy})
+	c.Assert(err, IsNil)
+
+	s.assertNote(c, n, notesCommitA, a)
+	s.assertNote(c, n, notesCommitC, "theirs\n")
+
+	_, err = n.Get(notesCommitB)
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	ref, err := s.r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+
+	commit, err := s.r.CommitObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 2)
+	c.Assert(commit.Message, Equals, "Merged notes from refs/notes/other into refs/notes/commits\n")
+}
+
+func (s *NotesSuite) TestMergeOurs(c *C) {
+	s.assertMerge(c, NotesMergeOurs, "b\nours\n")
+}
+
+func (s *NotesSuite) TestMergeTheirs(c *C) {
+	s.assertMerge(c, NotesMergeTheirs, "a\ntheirs\nb\n")
+}
+
+func (s *NotesSuite) TestMergeUnion(c *C) {
+	s.assertMerge(c, NotesMergeUnion, "b\nours\n\na\ntheirs\nb\n")
+}
+
+func (s *NotesSuite) TestMergeCatSortUniq(c *C) {
+	s.assertMerge(c, NotesMergeCatSortUniq, "a\nb\nours\ntheirs\n")
+}
+
+func (s *NotesSuite) TestMergeManual(c *C) {
+	n := s.setUpMerge(c)
+	before, err := s.r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+
+	err = n.Merge("other", &NotesMergeOptions{NotesOptions: *s.options()})
+	c.Assert(err, ErrorMatches, "notes merge conflict: "+notesCommitA.String())
+
+	after, err := s.r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+	c.Assert(after.Hash(), Equals, before.Hash())
+}
+
+func (s *NotesSuite) TestMergeConfiguredStrategy(c *C) {
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("notes").SetOption("mergeStrategy", "union")
+	cfg.Raw.Section("notes").Subsection("commits").SetOption("mergeStrategy", "theirs")
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	s.assertMerge(c, "", "a\ntheirs\nb\n")
+}
+
+func (s *NotesSuite) TestMergeInvalidStrategy(c *C) {
+	n := s.notes(c, "")
+	err := n.Merge("other", &NotesMergeOptions{NotesOptions: *s.options(), Strategy: "foo"})
+	c.Assert(err, ErrorMatches, "invalid notes merge strategy: foo")
+}
+
+func (s *NotesSuite) TestMergeFastForward(c *C) {
+	n := s.notes(c, "")
+	other := s.notes(c, "other")
+	c.Assert(other.Add(notesCommitA, []byte("foo\n"), s.options()), IsNil)
+
+	c.Assert(n.Merge("other", &NotesMergeOptions{NotesOptions: *s.options()}), IsNil)
+	s.assertNote(c, n, notesCommitA, "foo\n")
+
+	c.Assert(other.Add(notesCommitB, []byte("bar\n"), s.options()), IsNil)
+	c.Assert(n.Merge("other", &NotesMergeOptions{NotesOptions: *s.options()}), IsNil)
+
+	ours, err := s.r.Reference(n.Name(), false)
+	c.Assert(err, IsNil)
+	theirs, err := s.r.Reference(other.Name(), false)
+	c.Assert(err, IsNil)
+	c.Assert(ours.Hash(), Equals, theirs.Hash())
+}
+
+func (s *NotesSuite) TestRewriteNotes(c *C) {
+	n := s.notes(c, "")
+	c.Assert(n.Add(notesCommitA, []byte("a\n"), s.options()), IsNil)
+	c.Assert(n.Add(notesCommitB, []byte("b\n"), s.options()), IsNil)
+	c.Assert(n.Add(notesCommitC, []byte("c\n"), s.options()), IsNil)
+
+	rewritten := map[plumbing.Hash]plumbing.Hash{
+		notesCommitA: notesCommitB,
+		notesCommitC: plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea"),
+	}
+
+	// without notes.rewriteRef nothing is copied
+	c.Assert(s.r.RewriteNotes("amend", rewritten, s.options()), IsNil)
+	s.assertNote(c, n, notesCommitB, "b\n")
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("notes").AddOption("rewriteRef", "refs/notes/*")
+	cfg.Raw.Section("notes").Subsection("rewrite").SetOption("rebase", "false")
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	c.Assert(s.r.RewriteNotes("rebase", rewritten, s.options()), IsNil)
+	s.assertNote(c, n, notesCommitB, "b\n")
+
+	c.Assert(s.r.RewriteNotes("amend", rewritten, s.options()), IsNil)
+	s.assertNote(c, n, notesCommitB, "b\n\na\n")
+	s.assertNote(c, n, rewritten[notesCommitC], "c\n")
+
+	for mode, expected := range map[NotesRewriteMode]string{
+		NotesRewriteOverwrite:   "a\n",
+		NotesRewriteIgnore:      "b\n",
+		NotesRewriteCatSortUniq: "a\nb\n",
+	} {
+		force := &NotesOptions{Author: defaultSignature(), Force: true}
+		c.Assert(n.Add(notesCommitB, []byte("b\n"), force), IsNil)
+
+		cfg.Raw.Section("notes").SetOption("rewriteMode", string(mode))
+		c.Assert(s.r.SetConfig(cfg), IsNil)
+
+		c.Assert(s.r.RewriteNotes("amend", rewritten, s.options()), IsNil)
+		s.assertNote(c, n, notesCommitB, expected)
+	}
+
+	// '*' matches the nested notes references too
+	nested := s.notes(c, "ci/results")
+	c.Assert(nested.Add(notesCommitA, []byte("ok\n"), s.options()), IsNil)
+	c.Assert(s.r.RewriteNotes("amend", rewritten, s.options()), IsNil)
+	s.assertNote(c, nested, notesCommitB, "ok\n")
+
+	cfg.Raw.Section("notes").SetOption("rewriteMode", "foo")
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+	err = s.r.RewriteNotes("amend", rewritten, s.options())
+	c.Assert(strings.HasPrefix(err.Error(), ErrInvalidNotesRewrite.Error()), Equals, true)
+}
@@ -3,17 +3,22 @@ package git
 import (
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	formatcfg "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/mailmap"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/utils/diff"
 )
 
 // SubmoduleRescursivity defines how depth will affect any submodule recursive
@@ -339,6 +344,23 @@ type SubmoduleUpdateOptions struct {
 	Depth int
 }
 
+// SubmoduleAddOptions describes how a submodule should be added.
+type SubmoduleAddOptions struct {
+	// Name of the submodule, if empty the path of the submodule is used.
+	Name string
+	// Branch to be checked out and recorded at the .gitmodules file, if empty
+	// the remote HEAD is checked out.
+	Branch string
+	// Auth credentials, if required, to use with the remote repository.
+	Auth transport.AuthMethod
+	// Depth limit fetching to the specified number of commits from the tip of
+	// each remote branch history.
+	Depth int
+	// Progress is where the human readable information sent by the server is
+	// stored, if nil nothing is stored.
+	Progress sideband.Progress
+}
+
 var (
 	ErrBranchHashExclusive  = errors.New("Branch and Hash are mutually exclusive")
 	ErrCreateRequiresBranch = errors.New("Branch is mandatory when Create is used")
@@ -467,6 +489,14 @@ type LogOptions struct {
 	// this field is kept for compatibility, it can be replaced with PathFilter
 	FileName *string
 
+	// Follow the renames of the file given in FileName, the commits of the
+	// file before a rename being shown with its old name. The renames are
+	// detected with object.DefaultDiffTreeOptions. If set, the iterator
+	// returned by Log is an object.CommitFollowIter, giving the path of the
+	// file in each commit.
+	// It is equivalent to running `git log --follow -- <file-name>`.
+	Follow bool
+
 	// Filter commits based on the path of files that are updated
 	// takes file path as argument and should return true if the file is desired
 	// It can be used to implement `git log -- <path>`
@@ -485,10 +515,68 @@ type LogOptions struct {
 	// Show commits older than a specific date.
 	// It is equivalent to running `git log --until <date>` or `git log --before <date>`.
 	Until *time.Time
+
+	// Revisions selects the commits to show, as the revisions given to
+	// `git log`. Besides single revisions, whose reachable commits are
+	// included, it accepts "^<rev>" to exclude the commits reachable from
+	// <rev>, "<rev1>..<rev2>" for the commits reachable from <rev2> but not
+	// from <rev1>, and "<rev1>...<rev2>" for the commits reachable from
+	// either but not from both. An empty side of a range means HEAD.
+	// If set, the From option is ignored, and the commits are sorted by
+	// committer time.
+	Revisions []plumbing.Revision
+
+	// Not lists revisions with the opposite meaning than in Revisions, as
+	// the revisions following `--not` in `git log`.
+	Not []plumbing.Revision
+
+	// Follow only the first parent of merge commits.
+	// It is equivalent to running `git log --first-parent`.
+	FirstParent bool
+
+	// Show only the commits which are both descendants of the excluded
+	// revisions and ancestors of the included ones.
+	// It is equivalent to running `git log --ancestry-path`.
+	AncestryPath bool
+
+	// Show only merge commits, it is equivalent to running `git log --merges`.
+	Merges bool
+
+	// Show no merge commits, it is equivalent to running `git log --no-merges`.
+	NoMerges bool
+
+	// Show only the commits whose author, formatted as "Name <email>",
+	// matches the regular expression.
+	// It is equivalent to running `git log --author <pattern>`.
+	Author *regexp.Regexp
+
+	// Show only the commits whose message matches the regular expression.
+	// It is equivalent to running `git log --grep <pattern>`.
+	Grep *regexp.Regexp
+
+	// Mark the side of the symmetric differences, <rev1>...<rev2>, every
+	// commit is reachable from. If set, the iterator returned by Log is a
+	// LeftRightCommitIter.
+	// It is equivalent to running `git log --left-right`.
+	LeftRight bool
+
+	// Mailmap maps the authors and committers of the commits to their
+	// canonical identities, the Author option matching the mapped author.
+	// It is equivalent to running `git log --use-mailmap`.
+	Mailmap *mailmap.Mailmap
+}
+
+// revisionWalk returns true if the options need the revision walk, instead
+// of the walk of a single commit.
+func (o *LogOptions) revisionWalk() bool {
+	return len(o.Revisions) > 0 || len(o.Not) > 0 || o.FirstParent || o.AncestryPath || o.LeftRight
 }
 
 var (
 	ErrMissingAuthor = errors.New("author field is required")
+	// ErrMissingTrailer is returned by Repository.Shortlog when the commits
+	// are grouped by trailer without a trailer key.
+	ErrMissingTrailer = errors.New("trailer key is required")
 )
 
 // AddOptions describes how an `add` operation should be performed
@@ -779,6 +867,9 @@ type PlainOpenOptions struct {
 	// Enable .git/commondir support (see https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt).
 	// NOTE: This option will only work with the filesystem storage.
 	EnableDotGitCommonDir bool
+	// NoReplaceObjects disables the replacement of the objects with the
+	// refs/replace references, as GIT_NO_REPLACE_OBJECTS does.
+	NoReplaceObjects bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -816,3 +907,564 @@ func (o *RestoreOptions) Validate() error {
 
 	return nil
 }
+
+const (
+	// DefaultDescribeAbbrev is the default number of hexadecimal digits of
+	// the abbreviated hashes returned by Repository.Describe.
+	DefaultDescribeAbbrev = 7
+	// DefaultDescribeCandidates is the default number of candidate tags
+	// considered by Repository.Describe.
+	DefaultDescribeCandidates = 10
+	// DefaultDescribeDirtyMark is the default suffix appended by
+	// Repository.Describe when the working tree is dirty.
+	DefaultDescribeDirtyMark = "-dirty"
+)
+
+var (
+	ErrDescribeDirtyCommit = errors.New("dirty can only be used describing HEAD")
+	ErrNoDescribeNames     = errors.New("no names found, cannot describe anything")
+	ErrNoDescribeTags      = errors.New("no tags can describe the commit")
+)
+
+// DescribeOptions describes how a commit should be described.
+type DescribeOptions struct {
+	// Commit is the commit to describe, if empty HEAD is described.
+	Commit plumbing.Hash
+	// Tags uses the lightweight tags besides the annotated ones.
+	// It is equivalent to running `git describe --tags`.
+	Tags bool
+	// Match only considers the tags whose name matches any of the glob
+	// patterns, '*' and '?' also matching '/' as in git. It is equivalent to
+	// running `git describe --match <pattern>`.
+	Match []string
+	// Exclude doesn't consider the tags whose name matches any of the glob
+	// patterns. It is equivalent to running `git describe --exclude <pattern>`.
+	Exclude []string
+	// Abbrev is the minimum number of hexadecimal digits of the abbreviated
+	// hash, DefaultDescribeAbbrev if zero. The abbreviation is extended until
+	// it is unique. A negative value only returns the closest tag, as running
+	// `git describe --abbrev=0`.
+	Abbrev int
+	// Candidates is the number of candidate tags considered,
+	// DefaultDescribeCandidates if zero.
+	// It is equivalent to running `git describe --candidates <n>`.
+	Candidates int
+	// Long always uses the long format, even if the commit is tagged.
+	// It is equivalent to running `git describe --long`.
+	Long bool
+	// Always returns the abbreviated hash if no tag describes the commit.
+	// It is equivalent to running `git describe --always`.
+	Always bool
+	// Dirty appends DirtyMark if the working tree has changes.
+	// It is equivalent to running `git describe --dirty`.
+	Dirty bool
+	// DirtyMark is the suffix appended when the working tree is dirty,
+	// DefaultDescribeDirtyMark if empty.
+	DirtyMark string
+	// FirstParent only follows the first parent of the merge commits.
+	// It is equivalent to running `git describe --first-parent`.
+	FirstParent bool
+	// Contains describes the commit using the oldest tag containing it,
+	// instead of the closest tag reachable from it, as `<tag>~<n>^<p>`.
+	// It is equivalent to running `git describe --contains`, which also
+	// implies Tags.
+	Contains bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *DescribeOptions) Validate() error {
+	if o.Dirty && !o.Commit.IsZero() {
+		return ErrDescribeDirtyCommit
+	}
+
+	if o.Abbrev == 0 {
+		o.Abbrev = DefaultDescribeAbbrev
+	}
+
+	if o.Candidates <= 0 {
+		o.Candidates = DefaultDescribeCandidates
+	}
+
+	if o.DirtyMark == "" {
+		o.DirtyMark = DefaultDescribeDirtyMark
+	}
+
+	if o.Contains {
+		o.Tags = true
+	}
+
+	return nil
+}
+
+// NotesOptions describes how the notes should be committed.
+type NotesOptions struct {
+	// Author is the author of the notes commit. If empty the Name and Email
+	// are read from the config, and time.Now it's used as When.
+	Author *object.Signature
+	// Committer is the committer of the notes commit, if nil Author is used.
+	Committer *object.Signature
+	// Message is the message of the notes commit, if empty a message
+	// describing the change, as the ones of git, is used.
+	Message string
+	// Force overwrites the existing notes when adding or copying notes.
+	Force bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *NotesOptions) Validate(r *Repository) error {
+	if o.Author == nil {
+		c := &CommitOptions{Committer: o.Committer}
+		if err := c.loadConfigAuthorAndCommitter(r); err != nil {
+			return err
+		}
+
+		o.Author, o.Committer = c.Author, c.Committer
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+// NotesMergeStrategy is the strategy used to merge the notes changed on both
+// sides of a notes merge.
+type NotesMergeStrategy string
+
+const (
+	// NotesMergeManual fails the merge if any note conflicts.
+	NotesMergeManual NotesMergeStrategy = "manual"
+	// NotesMergeOurs keeps the local notes.
+	NotesMergeOurs NotesMergeStrategy = "ours"
+	// NotesMergeTheirs uses the remote notes.
+	NotesMergeTheirs NotesMergeStrategy = "theirs"
+	// NotesMergeUnion concatenates the local and remote notes.
+	NotesMergeUnion NotesMergeStrategy = "union"
+	// NotesMergeCatSortUniq concatenates the lines of the local and remote
+	// notes, sorting them and removing the duplicates.
+	NotesMergeCatSortUniq NotesMergeStrategy = "cat_sort_uniq"
+)
+
+// Validate returns ErrInvalidNotesMerge if the strategy is unknown.
+func (s NotesMergeStrategy) Validate() error {
+	switch s {
+	case NotesMergeManual, NotesMergeOurs, NotesMergeTheirs, NotesMergeUnion, NotesMergeCatSortUniq:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidNotesMerge, s)
+}
+
+// NotesMergeOptions describes how a notes merge should be performed.
+type NotesMergeOptions struct {
+	NotesOptions
+	// Strategy is the merge strategy for the conflicting notes. If empty
+	// notes.<name>.mergeStrategy or notes.mergeStrategy are used, or
+	// NotesMergeManual if not configured.
+	Strategy NotesMergeStrategy
+}
+
+// Validate validates the fields and sets the default values.
+func (o *NotesMergeOptions) Validate(r *Repository, name plumbing.ReferenceName) error {
+	if err := o.NotesOptions.Validate(r); err != nil {
+		return err
+	}
+
+	if o.Strategy == "" {
+		cfg, err := r.Config()
+		if err != nil {
+			return err
+		}
+
+		section := cfg.Raw.Section("notes")
+		short := strings.TrimPrefix(name.String(), "refs/notes/")
+		o.Strategy = NotesMergeStrategy(section.Subsection(short).Option("mergeStrategy"))
+		if o.Strategy == "" {
+			o.Strategy = NotesMergeStrategy(section.Option("mergeStrategy"))
+		}
+
+		if o.Strategy == "" {
+			o.Strategy = NotesMergeManual
+		}
+	}
+
+	return o.Strategy.Validate()
+}
+
+// NotesRewriteMode is the way the notes of rewritten objects are combined
+// with the notes of their new versions, as configured with
+// notes.rewriteMode.
+type NotesRewriteMode string
+
+const (
+	// NotesRewriteOverwrite replaces the notes of the new versions.
+	NotesRewriteOverwrite NotesRewriteMode = "overwrite"
+	// NotesRewriteConcatenate concatenates the notes.
+	NotesRewriteConcatenate NotesRewriteMode = "concatenate"
+	// NotesRewriteCatSortUniq concatenates the lines of the notes, sorting
+	// them and removing the duplicates.
+	NotesRewriteCatSortUniq NotesRewriteMode = "cat_sort_uniq"
+	// NotesRewriteIgnore keeps the notes of the new versions.
+	NotesRewriteIgnore NotesRewriteMode = "ignore"
+)
+
+// Validate returns ErrInvalidNotesRewrite if the mode is unknown.
+func (m NotesRewriteMode) Validate() error {
+	switch m {
+	case NotesRewriteOverwrite, NotesRewriteConcatenate, NotesRewriteCatSortUniq, NotesRewriteIgnore:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidNotesRewrite, m)
+}
+
+// WhitespaceAction defines how the whitespace errors of the lines added by a
+// patch are handled, a whitespace error being trailing whitespace.
+type WhitespaceAction int
+
+const (
+	// NoWarnWhitespace applies the lines as they are.
+	NoWarnWhitespace WhitespaceAction = iota
+	// FixWhitespace removes the trailing whitespace of the added lines.
+	FixWhitespace
+	// ErrorWhitespace refuses to apply the patch if an added line has
+	// trailing whitespace.
+	ErrorWhitespace
+)
+
+// ApplyOptions describes how a patch should be applied.
+type ApplyOptions struct {
+	// Index applies the patch to the index and to the working tree, the
+	// patched files of the working tree must match the index.
+	Index bool
+	// Cached applies the patch only to the index, the working tree is not
+	// changed.
+	Cached bool
+	// ThreeWay, if a file patch does not apply, merges the changes of the
+	// patch into the file, using the source blob recorded at the index line
+	// of the patch. The conflicting changes are written between conflict
+	// markers and the index records the three versions of the file. It
+	// implies Index, unless Cached is used.
+	ThreeWay bool
+	// Check only verifies that the patch applies, nothing is changed.
+	Check bool
+	// Reverse applies the patch in reverse.
+	Reverse bool
+	// Fuzz is the maximum number of leading and trailing context lines of
+	// a hunk that can be ignored if the hunk does not apply.
+	Fuzz int
+	// IgnoreWhitespace ignores the whitespace changes of the context lines
+	// when the hunks are located.
+	IgnoreWhitespace bool
+	// Whitespace defines how the whitespace errors of the added lines are
+	// handled.
+	Whitespace WhitespaceAction
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ApplyOptions) Validate() error {
+	if o.Fuzz < 0 {
+		o.Fuzz = 0
+	}
+
+	if o.ThreeWay && !o.Cached {
+		o.Index = true
+	}
+
+	return nil
+}
+
+const (
+	// DefaultFormatPatchSubjectPrefix is the default prefix of the subjects
+	// of the messages written by Repository.FormatPatch.
+	DefaultFormatPatchSubjectPrefix = "PATCH"
+)
+
+var (
+	ErrMissingFormatPatchOutput = errors.New("output or output directory is required")
+	ErrFormatPatchOutputs       = errors.New("output and output directory cannot be used together")
+)
+
+// FormatPatchOptions describes how the commits should be formatted as email
+// messages.
+type FormatPatchOptions struct {
+	// Output is where the messages are written, as a single mbox. It is
+	// equivalent to running `git format-patch --stdout`.
+	Output io.Writer
+	// OutputDirectory is where the messages are written, each one to its
+	// own file named after its number and subject, as git does.
+	OutputDirectory billy.Filesystem
+	// SubjectPrefix is the prefix of the subjects, between brackets,
+	// DefaultFormatPatchSubjectPrefix if empty.
+	SubjectPrefix string
+	// Numbered numbers the subjects, as `[PATCH n/m]`, even if there is a
+	// single patch. They are always numbered if there is more than one.
+	Numbered bool
+	// CoverLetter writes a message before the patches, with the shortlog
+	// and the diffstat of the whole range, to be edited before sending it.
+	CoverLetter bool
+	// Author is the author of the cover letter. If empty the Name and Email
+	// are read from the config, and time.Now it's used as When.
+	Author *object.Signature
+	// NoStat doesn't write the diffstat of the patches.
+	NoStat bool
+	// Signature is written after the patches, following a `-- ` line. If
+	// empty the signature is omitted.
+	Signature string
+	// PatchOptions are the options of the line diffs. If empty they are
+	// read from the `diff` config.
+	PatchOptions *object.PatchOptions
+}
+
+// Validate validates the fields and sets the default values.
+func (o *FormatPatchOptions) Validate(r *Repository) error {
+	if o.Output == nil && o.OutputDirectory == nil {
+		return ErrMissingFormatPatchOutput
+	}
+
+	if o.Output != nil && o.OutputDirectory != nil {
+		return ErrFormatPatchOutputs
+	}
+
+	if o.SubjectPrefix == "" {
+		o.SubjectPrefix = DefaultFormatPatchSubjectPrefix
+	}
+
+	if o.CoverLetter && o.Author == nil {
+		c := &CommitOptions{}
+		if err := c.loadConfigAuthorAndCommitter(r); err != nil {
+			return err
+		}
+
+		o.Author = c.Author
+	}
+
+	if o.PatchOptions == nil {
+		po, err := r.diffOptions()
+		if err != nil {
+			return err
+		}
+
+		o.PatchOptions = po
+	}
+
+	return nil
+}
+
+// ApplyMailboxOptions describes how the patches of a mailbox should be
+// applied and committed.
+type ApplyMailboxOptions struct {
+	// Committer is the committer of the commits. If empty the Name and
+	// Email are read from the config, and time.Now it's used as When.
+	Committer *object.Signature
+	// KeepSubject keeps the subject of the messages as it is, otherwise the
+	// bracketed prefixes, as `[PATCH n/m]`, and the `Re:` are removed.
+	// It is equivalent to running `git am --keep`.
+	KeepSubject bool
+	// ThreeWay merges the changes of a patch that does not apply using the
+	// blobs recorded in the patch, as ApplyOptions.ThreeWay does.
+	ThreeWay bool
+	// Whitespace defines how the whitespace errors of the added lines are
+	// handled.
+	Whitespace WhitespaceAction
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ApplyMailboxOptions) Validate(r *Repository) error {
+	if o.Committer == nil {
+		c := &CommitOptions{}
+		if err := c.loadConfigAuthorAndCommitter(r); err != nil {
+			return err
+		}
+
+		o.Committer = c.Committer
+		if o.Committer == nil {
+			o.Committer = c.Author
+		}
+	}
+
+	return nil
+}
+
+// BlameOptions describes how a file should be blamed.
+type BlameOptions struct {
+	// Algorithm is the line diff algorithm used to find the changed lines
+	// between the revisions of the file.
+	Algorithm diff.Algorithm
+	// IndentHeuristic shifts the groups of changed lines as git does, so
+	// the lines are blamed as `git blame` does.
+	IndentHeuristic bool
+	// Lines limits the blame to the given ranges of lines, as the `-L`
+	// option of git blame. If empty every line of the file is blamed.
+	Lines []BlameLineRange
+	// IgnoreRevs are the commits ignored by the blame, as the
+	// `--ignore-rev` option of git blame: the lines changed by an ignored
+	// commit are blamed to the commits of the most similar lines of its
+	// parents. The commits listed in the files of the
+	// `blame.ignoreRevsFile` config are returned by
+	// Worktree.BlameIgnoreRevs.
+	IgnoreRevs []plumbing.Hash
+	// DetectMoves detects the lines moved or copied within the file, as
+	// the `-M` option of git blame.
+	DetectMoves bool
+	// MoveScore is the minimum number of alphanumeric characters of the
+	// lines detected as moved, the default of 20 being used if zero.
+	MoveScore int
+	// DetectCopies detects the lines moved or copied from other files, as
+	// the `-C` option of git blame. It implies DetectMoves.
+	DetectCopies BlameCopies
+	// CopyScore is the minimum number of alphanumeric characters of the
+	// lines detected as copied from other files, the default of 40 being
+	// used if zero.
+	CopyScore int
+	// Since limits the blame to the commits not reachable from it, the
+	// lines older than it being blamed to the boundary commits, as
+	// `git blame <since>..<commit>`. It is required if Reverse is true.
+	Since *object.Commit
+	// Reverse walks the history forward, from Since to the blamed commit,
+	// blaming the lines of the file in Since to the last commit they
+	// existed in, as the `--reverse` option of git blame.
+	Reverse bool
+	// Incremental is called with each group of lines as soon as it is
+	// blamed, as the `--incremental` option of git blame. If it returns an
+	// error the blame is stopped and the error returned.
+	Incremental func(*BlameEntry) error
+	// Mailmap maps the authors and committers of the commits blamed to
+	// their canonical identities, as git blame does with the mailmap of
+	// the repository, returned by Repository.Mailmap.
+	Mailmap *mailmap.Mailmap
+}
+
+// BlameLineRange is a range of lines blamed by BlameWithOptions.
+type BlameLineRange struct {
+	// Start is the number of the first line, starting at 1.
+	Start int
+	// End is the number of the last line, included. If zero the range
+	// ends at the last line of the file.
+	End int
+}
+
+// BlameCopies is the level of detection of the lines copied from other
+// files by BlameWithOptions.
+type BlameCopies int
+
+const (
+	// BlameCopiesNone does not detect the lines copied from other files.
+	BlameCopiesNone BlameCopies = iota
+	// BlameCopiesModified detects the lines copied from the files modified
+	// by the same commit, as `git blame -C`.
+	BlameCopiesModified
+	// BlameCopiesCreation detects, in addition, the lines copied from any
+	// file by the commit creating the file, as `git blame -C -C`.
+	BlameCopiesCreation
+	// BlameCopiesAny detects the lines copied from any file by any commit,
+	// as `git blame -C -C -C`.
+	BlameCopiesAny
+)
+
+// ShortlogOptions describes how the commits are grouped by
+// Repository.Shortlog.
+type ShortlogOptions struct {
+	// Revisions selects the commits, as LogOptions.Revisions. If empty the
+	// commits reachable from HEAD are selected.
+	Revisions []plumbing.Revision
+	// NoMerges skips the merge commits.
+	// It is equivalent to running `git shortlog --no-merges`.
+	NoMerges bool
+	// Group is the identity the commits are grouped by, the author by
+	// default.
+	Group ShortlogGroup
+	// Trailer is the key of the trailer the commits are grouped by, such
+	// as "Co-authored-by", if Group is ShortlogGroupTrailer.
+	Trailer string
+	// Email groups the contributors by name and email, instead of name.
+	// It is equivalent to running `git shortlog -e`.
+	Email bool
+	// Mailmap maps the identities to their canonical identities. If nil the
+	// mailmap of the repository, returned by Repository.Mailmap, is used.
+	Mailmap *mailmap.Mailmap
+	// SortByCommits sorts the contributors by number of commits, instead
+	// of by name.
+	// It is equivalent to running `git shortlog -n`.
+	SortByCommits bool
+	// LineStats computes the lines added and deleted by the commits of each
+	// contributor, compared with their first parent. The merge commits are
+	// not counted, as in `git log --numstat`.
+	LineStats bool
+	// Workers is the number of goroutines computing the line stats. If
+	// zero, runtime.NumCPU is used.
+	Workers int
+}
+
+// ShortlogGroup is the identity the commits are grouped by in
+// Repository.Shortlog.
+type ShortlogGroup int
+
+const (
+	// ShortlogGroupAuthor groups the commits by author.
+	ShortlogGroupAuthor ShortlogGroup = iota
+	// ShortlogGroupCommitter groups the commits by committer, as
+	// `git shortlog -c`.
+	ShortlogGroupCommitter
+	// ShortlogGroupTrailer groups the commits by the values of a trailer,
+	// as `git shortlog --group=trailer:<key>`. A commit is counted once
+	// for each value, and the commits without the trailer are skipped.
+	ShortlogGroupTrailer
+)
+
+// Validate validates the fields and sets the default values.
+func (o *ShortlogOptions) Validate() error {
+	if o.Group == ShortlogGroupTrailer && o.Trailer == "" {
+		return ErrMissingTrailer
+	}
+
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+
+	return nil
+}
+
+// DiffOptions describes which trees should be compared by Worktree.Diff.
+type DiffOptions struct {
+	// Cached compares the index with the commit, instead of the worktree.
+	// It is equivalent to running `git diff --cached`.
+	Cached bool
+	// Commit is the commit compared with the worktree, or with the index if
+	// Cached is true. If empty the worktree is compared with the index, or
+	// the index with HEAD if Cached is true.
+	Commit plumbing.Hash
+	// Paths limits the diff to the given pathspecs, relative to the root of
+	// the worktree: a path matches if it is equal to a pathspec, or inside a
+	// directory given as a pathspec, or if it matches a pathspec with the
+	// wildcards `*`, `?` and `[...]`, which also match the slashes.
+	Paths []string
+	// DiffTreeOptions are the options of the rename detection. If empty
+	// object.DefaultDiffTreeOptions is used.
+	DiffTreeOptions *object.DiffTreeOptions
+	// PatchOptions are the options of the line diffs of Worktree.DiffPatch.
+	// If empty they are read from the `diff` config.
+	PatchOptions *object.PatchOptions
+}
+
+// Validate validates the fields and sets the default values.
+func (o *DiffOptions) Validate(r *Repository) error {
+	if o.Cached && o.Commit.IsZero() {
+		head, err := r.Head()
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		// on an unborn branch the index is compared with the empty tree
+		if head != nil {
+			o.Commit = head.Hash()
+		}
+	}
+
+	if o.DiffTreeOptions == nil {
+		o.DiffTreeOptions = object.DefaultDiffTreeOptions
+	}
+
+	return nil
+}
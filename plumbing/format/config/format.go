@@ -51,3 +51,17 @@ const (
 	// DefaultObjectFormat holds the default object format.
 	DefaultObjectFormat = SHA1
 )
+
+// RefStorage defines the reference storage backend.
+type RefStorage string
+
+const (
+	// FilesRefStorage represents the loose files and packed-refs backend.
+	FilesRefStorage RefStorage = "files"
+
+	// ReftableRefStorage represents the reftable backend.
+	ReftableRefStorage RefStorage = "reftable"
+
+	// DefaultRefStorage holds the default reference storage backend.
+	DefaultRefStorage = FilesRefStorage
+)
@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"errors"
+)
+
+// base85Alphabet is the alphabet used by git to encode the binary patches,
+// it is not the one of encoding/ascii85.
+const base85Alphabet = "0123456789" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"abcdefghijklmnopqrstuvwxyz" +
+	"!#$%&()*+-;<=>?@^_`{|}~"
+
+var (
+	errBase85Length = errors.New("invalid base85 length")
+	errBase85Byte   = errors.New("invalid base85 byte")
+
+	base85Values [256]byte
+)
+
+func init() {
+	for i := range base85Values {
+		base85Values[i] = 0xff
+	}
+
+	for i := 0; i < len(base85Alphabet); i++ {
+		base85Values[base85Alphabet[i]] = byte(i)
+	}
+}
+
+// decodeBase85 decodes n bytes from src, every 5 bytes of src are decoded
+// into 4 bytes, big-endian.
+func decodeBase85(dst []byte, src []byte, n int) ([]byte, error) {
+	if len(src)%5 != 0 || (n+3)/4 != len(src)/5 {
+		return nil, errBase85Length
+	}
+
+	for len(src) > 0 {
+		var acc uint64
+		for _, c := range src[:5] {
+			v := base85Values[c]
+			if v == 0xff {
+				return nil, errBase85Byte
+			}
+
+			acc = acc*85 + uint64(v)
+		}
+
+		if acc > 0xffffffff {
+			return nil, errBase85Byte
+		}
+
+		for shift := 24; shift >= 0 && n > 0; shift -= 8 {
+			dst = append(dst, byte(acc>>shift))
+			n--
+		}
+
+		src = src[5:]
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// funcnameMaxLen is the maximum length of the function names of the hunk
+// headers, as the one of git.
+const funcnameMaxLen = 80
+
+// DefaultFuncname is the Funcname used by git for the files without a diff
+// driver, matching the lines starting with a letter, '_' or '$'.
+var DefaultFuncname = &Funcname{}
+
+// builtinFuncnames are the xfuncname of some of the builtin diff drivers
+// of git. See https://github.com/git/git/blob/v2.39.0/userdiff.c.
+var builtinFuncnames = map[string]string{
+	"golang": "^[ \t]*(func[ \t]*.*(\\{[ \t]*)?)\n" +
+		"^[ \t]*(type[ \t].*(struct|interface)[ \t]*(\\{[ \t]*)?)",
+	"html": "^[ \t]*(<[Hh][1-6]([ \t].*)?>.*)$",
+	"java": "!^[ \t]*(catch|do|for|if|instanceof|new|return|switch|throw|while)\n" +
+		"^[ \t]*(([a-z]+[ \t]+)*(class|enum|interface)[ \t]+[A-Za-z][A-Za-z0-9_$]*[ \t]+.*)$\n" +
+		"^[ \t]*(([A-Za-z_<>&][][?&<>.,A-Za-z_0-9]*[ \t]+)+[A-Za-z_][A-Za-z_0-9]*[ \t]*\\([^;]*)$",
+	"markdown": "^ {0,3}#{1,6}[ \t].*",
+	"php": "^[\t ]*(((public|protected|private|static|abstract|final)[\t ]+)*function.*)$\n" +
+		"^[\t ]*((((final|abstract)[\t ]+)?class|enum|interface|trait).*)$",
+	"python": "^[ \t]*((class|(async[ \t]+)?def)[ \t].*)$",
+	"ruby":   "^[ \t]*((class|module|def)[ \t].*)$",
+	"rust": "^[\t ]*((pub(\\([^\\)]+\\))?[\t ]+)?((async|const|unsafe|extern([\t ]+\"[^\"]+\"))[\t ]+)?" +
+		"(struct|enum|union|mod|trait|fn|impl|macro_rules!)[< \t]+[^;]*)$",
+}
+
+// Funcname finds the lines shown in the hunk headers, and used as the
+// boundaries of the functions by the function context, as the xfuncname of
+// the diff drivers of git.
+type Funcname struct {
+	patterns []funcnamePattern
+}
+
+type funcnamePattern struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// NewFuncname returns the Funcname of the given xfuncname, a list of regular
+// expressions separated by newlines. The first expression matching a line
+// decides if it is a function line, the lines matched by an expression
+// starting with '!' not being function lines. The name of the function is the
+// first group of the expression, or the whole match if there is no group.
+func NewFuncname(xfuncname string) (*Funcname, error) {
+	f := &Funcname{}
+	for _, p := range strings.Split(xfuncname, "\n") {
+		negate := strings.HasPrefix(p, "!")
+		if negate {
+			p = p[1:]
+		}
+
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		// POSIX regular expressions match the leftmost longest text
+		re.Longest()
+		f.patterns = append(f.patterns, funcnamePattern{re: re, negate: negate})
+	}
+
+	return f, nil
+}
+
+// BuiltinFuncname returns the Funcname of a builtin diff driver of git, or
+// nil if the driver is unknown. The golang, html, java, markdown, php,
+// python, ruby and rust drivers are supported.
+func BuiltinFuncname(driver string) *Funcname {
+	xfuncname, ok := builtinFuncnames[driver]
+	if !ok {
+		return nil
+	}
+
+	f, err := NewFuncname(xfuncname)
+	if err != nil {
+		panic(err)
+	}
+
+	return f
+}
+
+// Match returns the function name of the given line, and if it is a
+// function line.
+func (f *Funcname) Match(line string) (name string, ok bool) {
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+
+	if len(f.patterns) == 0 {
+		if line == "" || !isFuncnameStart(line[0]) {
+			return "", false
+		}
+
+		return trimFuncname(line), true
+	}
+
+	for _, p := range f.patterns {
+		m := p.re.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+
+		if p.negate {
+			return "", false
+		}
+
+		if len(m) > 2 && m[2] >= 0 {
+			return trimFuncname(line[m[2]:m[3]]), true
+		}
+
+		return trimFuncname(line[m[0]:m[1]]), true
+	}
+
+	return "", false
+}
+
+func isFuncnameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
+
+func trimFuncname(name string) string {
+	if len(name) > funcnameMaxLen {
+		name = name[:funcnameMaxLen]
+	}
+
+	return strings.TrimRight(name, " \t\n\v\f\r")
+}
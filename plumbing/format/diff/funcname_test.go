@@ -0,0 +1,69 @@
+package diff
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type FuncnameSuite struct{}
+
+var _ = Suite(&FuncnameSuite{})
+
+func (s *FuncnameSuite) TestDefaultFuncname(c *C) {
+	for line, exp := range map[string]string{
+		"func main() {\n": "func main() {",
+		"_init:  \n":      "_init:",
+		"$var\r\n":        "$var",
+		"\tindented\n":    "",
+		"\n":              "",
+		"# comment\n":     "",
+	} {
+		name, ok := DefaultFuncname.Match(line)
+		c.Assert(ok, Equals, exp != "", Commentf("%q", line))
+		c.Assert(name, Equals, exp)
+	}
+
+	long := strings.Repeat("a", 100)
+	name, ok := DefaultFuncname.Match(long + "\n")
+	c.Assert(ok, Equals, true)
+	c.Assert(name, Equals, long[:80])
+}
+
+func (s *FuncnameSuite) TestNewFuncname(c *C) {
+	f, err := NewFuncname("!^static\n^[a-z]+ ([a-z_]+)\\(\n^class .*")
+	c.Assert(err, IsNil)
+
+	for line, exp := range map[string]string{
+		"void do_it(int a)\n":    "do_it",
+		"static int foo(void)\n": "",
+		"class Foo {  \n":        "class Foo {",
+		"  return 0;\n":          "",
+	} {
+		name, ok := f.Match(line)
+		c.Assert(ok, Equals, exp != "", Commentf("%q", line))
+		c.Assert(name, Equals, exp)
+	}
+
+	_, err = NewFuncname("^(foo")
+	c.Assert(err, NotNil)
+}
+
+func (s *FuncnameSuite) TestBuiltinFuncname(c *C) {
+	for driver := range builtinFuncnames {
+		c.Assert(BuiltinFuncname(driver), NotNil, Commentf("%s", driver))
+	}
+
+	c.Assert(BuiltinFuncname("foo"), IsNil)
+
+	name, ok := BuiltinFuncname("golang").Match("\tfunc (s *Suite) Test(c *C) {\n")
+	c.Assert(ok, Equals, true)
+	c.Assert(name, Equals, "func (s *Suite) Test(c *C) {")
+
+	name, ok = BuiltinFuncname("python").Match("    async def run(self):\n")
+	c.Assert(ok, Equals, true)
+	c.Assert(name, Equals, "async def run(self):")
+
+	_, ok = BuiltinFuncname("java").Match("    return foo(bar);\n")
+	c.Assert(ok, Equals, false)
+}
@@ -39,6 +39,14 @@ type FilePatch interface {
 	Chunks() []Chunk
 }
 
+// CopyFilePatch is a FilePatch that may copy the "from" File instead of
+// renaming it, the "from" File being kept.
+type CopyFilePatch interface {
+	FilePatch
+	// Copied returns true if the "to" File is a copy of the "from" File.
+	Copied() bool
+}
+
 // File contains all the file metadata necessary to print some patch formats.
 type File interface {
 	// Hash returns the File Hash.
@@ -0,0 +1,770 @@
+package diff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+)
+
+var (
+	// ErrMalformedPatch is returned when the input is not a valid patch.
+	ErrMalformedPatch = errors.New("malformed patch")
+)
+
+const devNull = "/dev/null"
+
+// UnifiedDecoder decodes unified diffs, as the ones generated by `git diff`
+// and the UnifiedEncoder, including the git extended headers (renames,
+// copies, mode changes and index lines) and the binary patches.
+type UnifiedDecoder struct {
+	r io.Reader
+
+	// strip is the number of leading path components removed from the file
+	// names of the patch.
+	strip int
+
+	lines []string
+	pos   int
+}
+
+// NewUnifiedDecoder returns a new UnifiedDecoder that reads from r. As
+// `git apply` does, it removes the first component of the file names,
+// which is the "a/" or "b/" prefix of the git diffs.
+func NewUnifiedDecoder(r io.Reader) *UnifiedDecoder {
+	return &UnifiedDecoder{r: r, strip: 1}
+}
+
+// SetStrip sets the number of leading path components removed from the file
+// names and returns d.
+func (d *UnifiedDecoder) SetStrip(n int) *UnifiedDecoder {
+	d.strip = n
+	return d
+}
+
+// Decode decodes the patch. The text preceding the first file is the message
+// of the patch.
+func (d *UnifiedDecoder) Decode() (*UnifiedPatch, error) {
+	b, err := io.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	d.lines = splitLines(string(b))
+	d.pos = 0
+
+	p := &UnifiedPatch{}
+
+	var message strings.Builder
+	for d.pos < len(d.lines) && !d.isFileStart() {
+		message.WriteString(d.lines[d.pos])
+		d.pos++
+	}
+
+	p.message = message.String()
+	for d.pos < len(d.lines) {
+		if !d.isFileStart() {
+			d.pos++
+			continue
+		}
+
+		fp, err := d.decodeFilePatch()
+		if err != nil {
+			return nil, err
+		}
+
+		p.filePatches = append(p.filePatches, fp)
+	}
+
+	return p, nil
+}
+
+func (d *UnifiedDecoder) line() string {
+	if d.pos >= len(d.lines) {
+		return ""
+	}
+
+	return d.lines[d.pos]
+}
+
+func (d *UnifiedDecoder) isFileStart() bool {
+	l := d.line()
+	if strings.HasPrefix(l, "diff --git ") {
+		return true
+	}
+
+	return strings.HasPrefix(l, "--- ") && d.pos+2 < len(d.lines) &&
+		strings.HasPrefix(d.lines[d.pos+1], "+++ ") &&
+		strings.HasPrefix(d.lines[d.pos+2], "@@ ")
+}
+
+func (d *UnifiedDecoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrMalformedPatch, d.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (d *UnifiedDecoder) decodeFilePatch() (*UnifiedFilePatch, error) {
+	h := &fileHeader{}
+	if l := trimNewline(d.line()); strings.HasPrefix(l, "diff --git ") {
+		h.git = true
+		h.oldName, h.newName = d.parseGitNames(l[len("diff --git "):])
+		d.pos++
+
+		if err := d.decodeExtendedHeaders(h); err != nil {
+			return nil, err
+		}
+	}
+
+	fp := h.filePatch()
+	if !strings.HasPrefix(d.line(), "--- ") {
+		return fp, d.decodeBinary(fp, h)
+	}
+
+	oldName, err := d.parseName(d.line()[len("--- "):])
+	if err != nil {
+		return nil, err
+	}
+
+	d.pos++
+	if !strings.HasPrefix(d.line(), "+++ ") {
+		return nil, d.errorf("missing +++ line")
+	}
+
+	newName, err := d.parseName(d.line()[len("+++ "):])
+	if err != nil {
+		return nil, err
+	}
+
+	d.pos++
+	h.setNames(oldName, newName)
+	fp = h.filePatch()
+
+	for strings.HasPrefix(d.line(), "@@ ") {
+		hunk, err := d.decodeHunk()
+		if err != nil {
+			return nil, err
+		}
+
+		fp.Hunks = append(fp.Hunks, hunk)
+	}
+
+	return fp, nil
+}
+
+// fileHeader holds the headers of a file patch while they are decoded.
+type fileHeader struct {
+	git              bool
+	oldName, newName string
+	oldMode, newMode filemode.FileMode
+	oldHash, newHash string
+	created, deleted bool
+	rename, copy     bool
+	similarity       int
+}
+
+func (h *fileHeader) setNames(oldName, newName string) {
+	h.created = h.created || oldName == devNull
+	h.deleted = h.deleted || newName == devNull
+
+	if oldName != devNull {
+		h.oldName = oldName
+	}
+
+	if newName != devNull {
+		h.newName = newName
+	}
+}
+
+func (h *fileHeader) filePatch() *UnifiedFilePatch {
+	fp := &UnifiedFilePatch{
+		IsRename:   h.rename,
+		IsCopy:     h.copy,
+		Similarity: h.similarity,
+	}
+
+	if !h.created {
+		fp.From = &UnifiedFile{Name: h.oldName, FileMode: h.oldMode, Index: h.oldHash}
+	}
+
+	if !h.deleted {
+		fp.To = &UnifiedFile{Name: h.newName, FileMode: h.newMode, Index: h.newHash}
+	}
+
+	return fp
+}
+
+func (d *UnifiedDecoder) decodeExtendedHeaders(h *fileHeader) error {
+	for ; d.pos < len(d.lines); d.pos++ {
+		l := trimNewline(d.line())
+
+		var err error
+		switch {
+		case strings.HasPrefix(l, "old mode "):
+			h.oldMode, err = parseMode(l[len("old mode "):])
+		case strings.HasPrefix(l, "new mode "):
+			h.newMode, err = parseMode(l[len("new mode "):])
+		case strings.HasPrefix(l, "deleted file mode "):
+			h.deleted = true
+			h.oldMode, err = parseMode(l[len("deleted file mode "):])
+		case strings.HasPrefix(l, "new file mode "):
+			h.created = true
+			h.newMode, err = parseMode(l[len("new file mode "):])
+		case strings.HasPrefix(l, "rename from "):
+			h.rename = true
+			h.oldName, err = unquoteName(l[len("rename from "):])
+		case strings.HasPrefix(l, "rename to "):
+			h.rename = true
+			h.newName, err = unquoteName(l[len("rename to "):])
+		case strings.HasPrefix(l, "copy from "):
+			h.copy = true
+			h.oldName, err = unquoteName(l[len("copy from "):])
+		case strings.HasPrefix(l, "copy to "):
+			h.copy = true
+			h.newName, err = unquoteName(l[len("copy to "):])
+		case strings.HasPrefix(l, "similarity index "):
+			h.similarity, err = strconv.Atoi(strings.TrimSuffix(l[len("similarity index "):], "%"))
+		case strings.HasPrefix(l, "dissimilarity index "):
+		case strings.HasPrefix(l, "index "):
+			err = h.parseIndex(l[len("index "):])
+		default:
+			return nil
+		}
+
+		if err != nil {
+			return d.errorf("%s", err)
+		}
+	}
+
+	return nil
+}
+
+// parseIndex parses the "index <old>..<new> [<mode>]" line.
+func (h *fileHeader) parseIndex(s string) error {
+	hashes, mode, hasMode := strings.Cut(s, " ")
+	oldHash, newHash, ok := strings.Cut(hashes, "..")
+	if !ok {
+		return fmt.Errorf("invalid index line %q", s)
+	}
+
+	h.oldHash, h.newHash = oldHash, newHash
+	if !hasMode {
+		return nil
+	}
+
+	m, err := parseMode(mode)
+	if err != nil {
+		return err
+	}
+
+	h.oldMode, h.newMode = m, m
+	return nil
+}
+
+func parseMode(s string) (filemode.FileMode, error) {
+	return filemode.New(strings.TrimSpace(s))
+}
+
+// parseGitNames parses the names of the "diff --git" line, which are
+// ambiguous if they contain spaces and are not quoted. They are only used if
+// the patch contains no other header with the names.
+func (d *UnifiedDecoder) parseGitNames(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		if i := closingQuote(s); i > 0 {
+			oldName, err := strconv.Unquote(s[:i+1])
+			if err == nil {
+				newName, _ := unquoteName(strings.TrimPrefix(s[i+1:], " "))
+				return d.stripName(oldName), d.stripName(newName)
+			}
+		}
+	}
+
+	// as git does, when the names are the same, the line is split in the
+	// middle
+	if len(s)%2 == 1 {
+		mid := len(s) / 2
+		oldName, newName := d.stripName(s[:mid]), d.stripName(s[mid+1:])
+		if s[mid] == ' ' && oldName == newName {
+			return oldName, newName
+		}
+	}
+
+	oldName, newName, _ := strings.Cut(s, " ")
+	if n, err := unquoteName(newName); err == nil {
+		newName = n
+	}
+
+	return d.stripName(oldName), d.stripName(newName)
+}
+
+// parseName parses the name of a "---" or "+++" line, removing the
+// timestamp of the traditional diffs.
+func (d *UnifiedDecoder) parseName(s string) (string, error) {
+	s = trimNewline(s)
+	if i := closingQuote(s); strings.HasPrefix(s, `"`) && i > 0 {
+		s = s[:i+1]
+	} else {
+		s, _, _ = strings.Cut(s, "\t")
+	}
+
+	name, err := unquoteName(s)
+	if err != nil {
+		return "", d.errorf("%s", err)
+	}
+
+	if name == devNull {
+		return name, nil
+	}
+
+	return d.stripName(name), nil
+}
+
+func (d *UnifiedDecoder) stripName(name string) string {
+	for i := 0; i < d.strip; i++ {
+		_, rest, ok := strings.Cut(name, "/")
+		if !ok {
+			break
+		}
+
+		name = rest
+	}
+
+	return name
+}
+
+// unquoteName unquotes the name if it is written as a C-style string, as git
+// does with the names containing special characters.
+func unquoteName(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+
+	return strconv.Unquote(s)
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (d *UnifiedDecoder) decodeHunk() (*Hunk, error) {
+	h := &Hunk{}
+
+	l := trimNewline(d.line())
+	ranges, section, ok := strings.Cut(l[len("@@ "):], " @@")
+	if !ok {
+		return nil, d.errorf("invalid hunk header %q", l)
+	}
+
+	oldRange, newRange, ok := strings.Cut(ranges, " ")
+	if !ok || !strings.HasPrefix(oldRange, "-") || !strings.HasPrefix(newRange, "+") {
+		return nil, d.errorf("invalid hunk header %q", l)
+	}
+
+	var err error
+	if h.OldStart, h.OldLines, err = parseRange(oldRange[1:]); err != nil {
+		return nil, d.errorf("invalid hunk header %q", l)
+	}
+
+	if h.NewStart, h.NewLines, err = parseRange(newRange[1:]); err != nil {
+		return nil, d.errorf("invalid hunk header %q", l)
+	}
+
+	h.Section = strings.TrimPrefix(section, " ")
+	d.pos++
+
+	oldLines, newLines := h.OldLines, h.NewLines
+	for oldLines > 0 || newLines > 0 {
+		if d.pos >= len(d.lines) {
+			return nil, d.errorf("truncated hunk")
+		}
+
+		l := d.line()
+
+		var op Operation
+		switch l[0] {
+		case ' ', '\n':
+			op = Equal
+			oldLines--
+			newLines--
+		case '-':
+			op = Delete
+			oldLines--
+		case '+':
+			op = Add
+			newLines--
+		case '\\':
+			d.noNewline(h)
+			d.pos++
+			continue
+		default:
+			return nil, d.errorf("invalid hunk line %q", trimNewline(l))
+		}
+
+		if oldLines < 0 || newLines < 0 {
+			return nil, d.errorf("hunk line count mismatch")
+		}
+
+		content := l
+		if l[0] != '\n' {
+			content = l[1:]
+		}
+
+		h.Lines = append(h.Lines, HunkLine{Type: op, Content: content})
+		d.pos++
+	}
+
+	if strings.HasPrefix(d.line(), `\`) {
+		d.noNewline(h)
+		d.pos++
+	}
+
+	return h, nil
+}
+
+// noNewline handles the "\ No newline at end of file" line, which applies
+// to the previous line of the hunk.
+func (d *UnifiedDecoder) noNewline(h *Hunk) {
+	if len(h.Lines) == 0 {
+		return
+	}
+
+	last := &h.Lines[len(h.Lines)-1]
+	last.Content = trimNewline(last.Content)
+}
+
+func parseRange(s string) (start, lines int, err error) {
+	startStr, linesStr, ok := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startStr); err != nil {
+		return
+	}
+
+	lines = 1
+	if ok {
+		lines, err = strconv.Atoi(linesStr)
+	}
+
+	return
+}
+
+func (d *UnifiedDecoder) decodeBinary(fp *UnifiedFilePatch, h *fileHeader) error {
+	l := trimNewline(d.line())
+	switch {
+	case strings.HasPrefix(l, "Binary files ") && strings.HasSuffix(l, " differ"):
+		fp.Binary = true
+		d.pos++
+		return nil
+	case l != "GIT binary patch":
+		return nil
+	}
+
+	fp.Binary = true
+	d.pos++
+
+	var err error
+	if fp.BinaryForward, err = d.decodeBinaryHunk(); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(d.line(), "literal ") || strings.HasPrefix(d.line(), "delta ") {
+		fp.BinaryReverse, err = d.decodeBinaryHunk()
+	}
+
+	return err
+}
+
+// decodeBinaryHunk decodes a "literal" or "delta" hunk of a binary patch,
+// its data is deflated and encoded in base85 in lines starting with the
+// length of the decoded line, 'A'-'Z' for 1-26 and 'a'-'z' for 27-52.
+func (d *UnifiedDecoder) decodeBinaryHunk() (*BinaryHunk, error) {
+	l := trimNewline(d.line())
+
+	h := &BinaryHunk{}
+	kind, sizeStr, _ := strings.Cut(l, " ")
+	switch kind {
+	case "literal":
+		h.Type = BinaryLiteral
+	case "delta":
+		h.Type = BinaryDelta
+	default:
+		return nil, d.errorf("invalid binary hunk %q", l)
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return nil, d.errorf("invalid binary hunk %q", l)
+	}
+
+	d.pos++
+
+	var deflated []byte
+	for ; d.pos < len(d.lines); d.pos++ {
+		l := trimNewline(d.line())
+		if l == "" {
+			d.pos++
+			break
+		}
+
+		var n int
+		switch c := l[0]; {
+		case c >= 'A' && c <= 'Z':
+			n = int(c-'A') + 1
+		case c >= 'a' && c <= 'z':
+			n = int(c-'a') + 27
+		default:
+			return nil, d.errorf("invalid binary line")
+		}
+
+		if deflated, err = decodeBase85(deflated, []byte(l[1:]), n); err != nil {
+			return nil, d.errorf("%s", err)
+		}
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(deflated))
+	if err != nil {
+		return nil, d.errorf("%s", err)
+	}
+
+	if h.Data, err = io.ReadAll(zr); err != nil {
+		return nil, d.errorf("%s", err)
+	}
+
+	if int64(len(h.Data)) != size {
+		return nil, d.errorf("binary hunk size mismatch")
+	}
+
+	return h, nil
+}
+
+func trimNewline(s string) string {
+	return strings.TrimSuffix(s, "\n")
+}
+
+// UnifiedPatch is a Patch decoded by the UnifiedDecoder.
+type UnifiedPatch struct {
+	message     string
+	filePatches []*UnifiedFilePatch
+}
+
+// FilePatches returns the patches of the files, they are *UnifiedFilePatch.
+func (p *UnifiedPatch) FilePatches() []FilePatch {
+	fps := make([]FilePatch, len(p.filePatches))
+	for i, fp := range p.filePatches {
+		fps[i] = fp
+	}
+
+	return fps
+}
+
+// Message returns the text preceding the first file of the patch.
+func (p *UnifiedPatch) Message() string {
+	return p.message
+}
+
+// UnifiedFilePatch is a FilePatch decoded by the UnifiedDecoder. Unlike the
+// patches between trees, it only contains the hunks of the file that
+// changed, with their context lines.
+type UnifiedFilePatch struct {
+	// From and To are the source and target files, From is nil if the
+	// patch creates the file and To is nil if it deletes it.
+	From, To *UnifiedFile
+	// Hunks are the changes of a text file.
+	Hunks []*Hunk
+	// IsRename and IsCopy are true if the target file is a rename or a copy
+	// of the source file, with the given Similarity index.
+	IsRename, IsCopy bool
+	Similarity       int
+	// Binary is true if the files are binary, BinaryForward and
+	// BinaryReverse are the changes of the file if the patch contains
+	// them, as written by `git diff --binary`.
+	Binary                       bool
+	BinaryForward, BinaryReverse *BinaryHunk
+}
+
+// IsBinary returns true if the files are binary.
+func (p *UnifiedFilePatch) IsBinary() bool {
+	return p.Binary
+}
+
+// Copied returns true if the target file is a copy of the source file.
+func (p *UnifiedFilePatch) Copied() bool {
+	return p.IsCopy
+}
+
+// Files returns the source and target files.
+func (p *UnifiedFilePatch) Files() (from, to File) {
+	if p.From != nil {
+		from = p.From
+	}
+
+	if p.To != nil {
+		to = p.To
+	}
+
+	return
+}
+
+// Chunks returns the lines of the hunks as chunks, the unchanged lines out of
+// the hunks are not included.
+func (p *UnifiedFilePatch) Chunks() []Chunk {
+	var chunks []Chunk
+	var last *unifiedChunk
+	for _, h := range p.Hunks {
+		for _, l := range h.Lines {
+			if last == nil || last.op != l.Type {
+				last = &unifiedChunk{op: l.Type}
+				chunks = append(chunks, last)
+			}
+
+			last.content += l.Content
+		}
+
+		last = nil
+	}
+
+	return chunks
+}
+
+// Reverse returns the patch undoing this one.
+func (p *UnifiedFilePatch) Reverse() *UnifiedFilePatch {
+	r := &UnifiedFilePatch{
+		From:          p.To,
+		To:            p.From,
+		IsRename:      p.IsRename,
+		IsCopy:        p.IsCopy,
+		Similarity:    p.Similarity,
+		Binary:        p.Binary,
+		BinaryForward: p.BinaryReverse,
+		BinaryReverse: p.BinaryForward,
+	}
+
+	for _, h := range p.Hunks {
+		rh := &Hunk{
+			OldStart: h.NewStart,
+			OldLines: h.NewLines,
+			NewStart: h.OldStart,
+			NewLines: h.OldLines,
+			Section:  h.Section,
+		}
+
+		// the added lines are kept after the deleted ones, as in the
+		// generated diffs
+		var deleted []HunkLine
+		for _, l := range h.Lines {
+			switch l.Type {
+			case Add:
+				rh.Lines = append(rh.Lines, HunkLine{Type: Delete, Content: l.Content})
+			case Delete:
+				deleted = append(deleted, HunkLine{Type: Add, Content: l.Content})
+			default:
+				rh.Lines = append(rh.Lines, deleted...)
+				rh.Lines = append(rh.Lines, l)
+				deleted = nil
+			}
+		}
+
+		rh.Lines = append(rh.Lines, deleted...)
+		r.Hunks = append(r.Hunks, rh)
+	}
+
+	return r
+}
+
+type unifiedChunk struct {
+	content string
+	op      Operation
+}
+
+func (c *unifiedChunk) Content() string {
+	return c.content
+}
+
+func (c *unifiedChunk) Type() Operation {
+	return c.op
+}
+
+// UnifiedFile is a File decoded by the UnifiedDecoder.
+type UnifiedFile struct {
+	// Name is the path of the file.
+	Name string
+	// FileMode is the mode of the file, it is filemode.Empty if the patch
+	// does not contain it.
+	FileMode filemode.FileMode
+	// Index is the hash of the file as written in the index line, it is
+	// usually abbreviated.
+	Index string
+}
+
+// Hash returns the hash of the file, if the index line contains the full
+// hash, or plumbing.ZeroHash otherwise.
+func (f *UnifiedFile) Hash() plumbing.Hash {
+	if !plumbing.IsHash(f.Index) {
+		return plumbing.ZeroHash
+	}
+
+	return plumbing.NewHash(f.Index)
+}
+
+// Mode returns the mode of the file.
+func (f *UnifiedFile) Mode() filemode.FileMode {
+	return f.FileMode
+}
+
+// Path returns the path of the file.
+func (f *UnifiedFile) Path() string {
+	return f.Name
+}
+
+// Hunk is a contiguous change of a text file, with the unchanged lines around
+// it.
+type Hunk struct {
+	// OldStart and OldLines are the first line and the number of lines of
+	// the hunk in the source file, NewStart and NewLines in the target file.
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Section is the text following the line numbers in the hunk header,
+	// usually the function containing the hunk.
+	Section string
+	// Lines are the lines of the hunk.
+	Lines []HunkLine
+}
+
+// HunkLine is a line of a Hunk.
+type HunkLine struct {
+	// Type is Equal for the context lines, Delete for the lines of the
+	// source file and Add for the lines of the target file.
+	Type Operation
+	// Content of the line, including the newline, unless it is the last line
+	// of a file without a newline at the end.
+	Content string
+}
+
+// BinaryHunkType defines the type of a BinaryHunk.
+type BinaryHunkType int
+
+const (
+	// BinaryLiteral hunks contain the full content of the file.
+	BinaryLiteral BinaryHunkType = iota
+	// BinaryDelta hunks contain a git delta from the source file.
+	BinaryDelta
+)
+
+// BinaryHunk is the change of a binary file.
+type BinaryHunk struct {
+	Type BinaryHunkType
+	// Data is the content of the file for a BinaryLiteral hunk or the delta
+	// for a BinaryDelta hunk, already inflated.
+	Data []byte
+}
@@ -0,0 +1,299 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+
+	. "gopkg.in/check.v1"
+)
+
+type UnifiedDecoderTestSuite struct{}
+
+var _ = Suite(&UnifiedDecoderTestSuite{})
+
+func (s *UnifiedDecoderTestSuite) decode(c *C, patch string) *UnifiedPatch {
+	p, err := NewUnifiedDecoder(strings.NewReader(patch)).Decode()
+	c.Assert(err, IsNil)
+	return p
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecode(c *C) {
+	p := s.decode(c, `Subject: [PATCH] some changes
+
+---
+diff --git a/README b/README
+index 94954ab..8b14c4f 100644
+--- a/README
++++ b/README
+@@ -1,2 +1,3 @@ section
+ hello
+-world
++World
++more
+@@ -10 +11 @@
+-no newline
+\ No newline at end of file
++newline
+diff --git a/created.txt b/created.txt
+new file mode 100755
+index 0000000..3e75765
+--- /dev/null
++++ b/created.txt
+@@ -0,0 +1 @@
++new
+diff --git "a/dir with space/f\"q.txt" "b/dir with space/f\"q.txt"
+deleted file mode 100644
+index 587be6b..0000000
+--- "a/dir with space/f\"q.txt"	
++++ /dev/null
+@@ -1 +0,0 @@
+-x
+`)
+
+	c.Assert(p.Message(), Equals, "Subject: [PATCH] some changes\n\n---\n")
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 3)
+
+	fp := fps[0].(*UnifiedFilePatch)
+	c.Assert(fp.IsBinary(), Equals, false)
+	c.Assert(fp.From, DeepEquals, &UnifiedFile{Name: "README", FileMode: filemode.Regular, Index: "94954ab"})
+	c.Assert(fp.To, DeepEquals, &UnifiedFile{Name: "README", FileMode: filemode.Regular, Index: "8b14c4f"})
+	c.Assert(fp.Hunks, DeepEquals, []*Hunk{{
+		OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 3, Section: "section",
+		Lines: []HunkLine{
+			{Type: Equal, Content: "hello\n"},
+			{Type: Delete, Content: "world\n"},
+			{Type: Add, Content: "World\n"},
+			{Type: Add, Content: "more\n"},
+		},
+	}, {
+		OldStart: 10, OldLines: 1, NewStart: 11, NewLines: 1,
+		Lines: []HunkLine{
+			{Type: Delete, Content: "no newline"},
+			{Type: Add, Content: "newline\n"},
+		},
+	}})
+
+	from, to := fps[1].Files()
+	c.Assert(from, IsNil)
+	c.Assert(to.Path(), Equals, "created.txt")
+	c.Assert(to.Mode(), Equals, filemode.Executable)
+	c.Assert(to.Hash(), Equals, plumbing.ZeroHash)
+
+	from, to = fps[2].Files()
+	c.Assert(from.Path(), Equals, `dir with space/f"q.txt`)
+	c.Assert(to, IsNil)
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeExtendedHeaders(c *C) {
+	p := s.decode(c, `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/moved.txt b/renamed.txt
+similarity index 95%
+rename from moved.txt
+rename to renamed.txt
+index 178093c..6bc735f 100644
+--- a/moved.txt
++++ b/renamed.txt
+@@ -1 +1,2 @@
+ 100
++101
+diff --git a/a b/copy
+similarity index 100%
+copy from a
+copy to copy
+diff --git a/with space b/with space
+old mode 100644
+new mode 100755
+`)
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 4)
+
+	fp := fps[0].(*UnifiedFilePatch)
+	c.Assert(fp.From, DeepEquals, &UnifiedFile{Name: "run.sh", FileMode: filemode.Regular})
+	c.Assert(fp.To, DeepEquals, &UnifiedFile{Name: "run.sh", FileMode: filemode.Executable})
+	c.Assert(fp.Hunks, HasLen, 0)
+
+	fp = fps[1].(*UnifiedFilePatch)
+	c.Assert(fp.IsRename, Equals, true)
+	c.Assert(fp.Similarity, Equals, 95)
+	c.Assert(fp.From.Name, Equals, "moved.txt")
+	c.Assert(fp.To.Name, Equals, "renamed.txt")
+	c.Assert(fp.Hunks, HasLen, 1)
+
+	fp = fps[2].(*UnifiedFilePatch)
+	c.Assert(fp.IsCopy, Equals, true)
+	c.Assert(fp.From.Name, Equals, "a")
+	c.Assert(fp.To.Name, Equals, "copy")
+
+	fp = fps[3].(*UnifiedFilePatch)
+	c.Assert(fp.From.Name, Equals, "with space")
+	c.Assert(fp.To.Name, Equals, "with space")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeBinary(c *C) {
+	p := s.decode(c, `diff --git a/f.bin b/f.bin
+index 9583496fd9b881325fc7085e7d6b84ca0573355d..fe647cafed2f54efac591f90570e8cb3f707b52e 100644
+GIT binary patch
+literal 7
+OcmYdfaAHViNC5x^g#l{-
+
+literal 5
+McmYdfNMc9^00VOYCjbBd
+
+diff --git a/other.bin b/other.bin
+index 1234567..89abcde 100644
+Binary files a/other.bin and b/other.bin differ
+`)
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 2)
+
+	fp := fps[0].(*UnifiedFilePatch)
+	c.Assert(fp.IsBinary(), Equals, true)
+	c.Assert(fp.Chunks(), HasLen, 0)
+	c.Assert(fp.From.Hash(), Equals, plumbing.NewHash("9583496fd9b881325fc7085e7d6b84ca0573355d"))
+	c.Assert(fp.BinaryForward, DeepEquals, &BinaryHunk{Type: BinaryLiteral, Data: []byte("a\x00B\x00c\x00d")})
+	c.Assert(fp.BinaryReverse, DeepEquals, &BinaryHunk{Type: BinaryLiteral, Data: []byte("a\x00b\x00c")})
+
+	fp = fps[1].(*UnifiedFilePatch)
+	c.Assert(fp.IsBinary(), Equals, true)
+	c.Assert(fp.BinaryForward, IsNil)
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeTraditional(c *C) {
+	p, err := NewUnifiedDecoder(strings.NewReader(`--- project.orig/src/main.c	2024-01-01 00:00:00
++++ project/src/main.c	2024-01-02 00:00:00
+@@ -1,2 +1,2 @@
+ int main() {
+-	return 1;
++	return 0;
+`)).SetStrip(2).Decode()
+	c.Assert(err, IsNil)
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 1)
+
+	from, to := fps[0].Files()
+	c.Assert(from.Path(), Equals, "main.c")
+	c.Assert(to.Path(), Equals, "main.c")
+	c.Assert(to.Mode(), Equals, filemode.Empty)
+
+	chunks := fps[0].Chunks()
+	c.Assert(chunks, HasLen, 3)
+	c.Assert(chunks[0].Type(), Equals, Equal)
+	c.Assert(chunks[0].Content(), Equals, "int main() {\n")
+	c.Assert(chunks[1].Type(), Equals, Delete)
+	c.Assert(chunks[2].Content(), Equals, "\treturn 0;\n")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeEncoded(c *C) {
+	patch := testPatch{
+		filePatches: []testFilePatch{{
+			from: &testFile{mode: filemode.Regular, path: "onechunk.txt", seed: "A\nB\nC\nD\nE\nF\nG\nH\nI\n"},
+			to:   &testFile{mode: filemode.Regular, path: "onechunk.txt", seed: "B\nC\nD\nE\nF\nG\nI\nZ\n"},
+			chunks: []testChunk{
+				{content: "A\n", op: Delete},
+				{content: "B\nC\nD\nE\nF\nG\n", op: Equal},
+				{content: "H\n", op: Delete},
+				{content: "I\n", op: Equal},
+				{content: "Z\n", op: Add},
+			},
+		}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewUnifiedEncoder(buf, 1).Encode(patch), IsNil)
+
+	p := s.decode(c, buf.String())
+	fp := p.FilePatches()[0].(*UnifiedFilePatch)
+	c.Assert(fp.From.Hash(), Equals, patch.filePatches[0].from.Hash())
+	c.Assert(fp.Hunks, HasLen, 2)
+	c.Assert(fp.Hunks[1], DeepEquals, &Hunk{
+		OldStart: 7, OldLines: 3, NewStart: 6, NewLines: 3, Section: "F",
+		Lines: []HunkLine{
+			{Type: Equal, Content: "G\n"},
+			{Type: Delete, Content: "H\n"},
+			{Type: Equal, Content: "I\n"},
+			{Type: Add, Content: "Z\n"},
+		},
+	})
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeEncodedCopy(c *C) {
+	patch := `diff --git a/a b/copy
+copy from a
+copy to copy
+index 257cc5642cb1a054f08cc83f2d943e56fd3ebe99..3bd1f0e29744a1f32b08d5650e62e2e62afb177c 100644
+--- a/a
++++ b/copy
+@@ -1 +1,2 @@
+ foo
++bar
+`
+
+	p := s.decode(c, patch)
+	c.Assert(p.FilePatches()[0].(CopyFilePatch).Copied(), Equals, true)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewUnifiedEncoder(buf, 3).Encode(p), IsNil)
+	c.Assert(buf.String(), Equals, patch)
+}
+
+func (s *UnifiedDecoderTestSuite) TestReverse(c *C) {
+	p := s.decode(c, `diff --git a/README b/README
+new file mode 100644
+index 0000000..8b14c4f
+--- /dev/null
++++ b/README
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/f b/f
+index 94954ab..8b14c4f 100644
+--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+-a
++b
+ c
+`)
+
+	fps := p.FilePatches()
+	r := fps[0].(*UnifiedFilePatch).Reverse()
+	c.Assert(r.From.Name, Equals, "README")
+	c.Assert(r.To, IsNil)
+	c.Assert(r.Hunks[0].OldStart, Equals, 1)
+	c.Assert(r.Hunks[0].OldLines, Equals, 2)
+	c.Assert(r.Hunks[0].Lines[0], Equals, HunkLine{Type: Delete, Content: "hello\n"})
+
+	r = fps[1].(*UnifiedFilePatch).Reverse()
+	c.Assert(r.From.Index, Equals, "8b14c4f")
+	c.Assert(r.Hunks[0].Lines, DeepEquals, []HunkLine{
+		{Type: Delete, Content: "b\n"},
+		{Type: Add, Content: "a\n"},
+		{Type: Equal, Content: "c\n"},
+	})
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeMalformed(c *C) {
+	for _, patch := range []string{
+		"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n",
+		"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n*a\n",
+		"diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -x +1 @@\n a\n",
+		"diff --git a/f b/f\n--- a/f\nfoo\n",
+		"diff --git a/f b/f\nold mode 10x644\n",
+		"diff --git a/f b/f\nindex 1234567..89abcde 100644\nGIT binary patch\nliteral 7\nOcmYdfaAHViNC5x^g#l{\n\n",
+		"diff --git a/f b/f\nindex 1234567..89abcde 100644\nGIT binary patch\nliteral 8\nOcmYdfaAHViNC5x^g#l{-\n\n",
+	} {
+		_, err := NewUnifiedDecoder(strings.NewReader(patch)).Decode()
+		c.Assert(errors.Is(err, ErrMalformedPatch), Equals, true, Commentf("%q", patch))
+	}
+}
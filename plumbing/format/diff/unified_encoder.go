@@ -8,6 +8,9 @@ import (
 	"strings"
 
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/utils/diff"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
 )
 
 // DefaultContextLines is the default number of context lines.
@@ -29,6 +32,22 @@ var (
 	}
 )
 
+// IgnoreWhitespace are the whitespace changes ignored by an UnifiedEncoder
+// when comparing the lines.
+type IgnoreWhitespace uint8
+
+const (
+	// IgnoreSpaceChange ignores the changes in amount of whitespace, as
+	// `git diff --ignore-space-change`.
+	IgnoreSpaceChange IgnoreWhitespace = 1 << iota
+	// IgnoreAllSpace ignores the whitespaces, as
+	// `git diff --ignore-all-space`.
+	IgnoreAllSpace
+	// IgnoreBlankLines ignores the changes whose lines are all blank, as
+	// `git diff --ignore-blank-lines`.
+	IgnoreBlankLines
+)
+
 // UnifiedEncoder encodes an unified diff into the provided Writer. It does not
 // support similarity index for renames or sorting hash representations.
 type UnifiedEncoder struct {
@@ -44,6 +63,22 @@ type UnifiedEncoder struct {
 
 	// colorConfig is the color configuration. The default is no color.
 	color ColorConfig
+
+	// ignoreWhitespace are the whitespace changes ignored when comparing
+	// the lines.
+	ignoreWhitespace IgnoreWhitespace
+
+	// functionContext shows the whole functions around the changes.
+	functionContext bool
+
+	// funcname returns the Funcname of the hunk headers of a file. The
+	// hunk headers show the line preceding the hunks if it is nil.
+	funcname func(path string) *Funcname
+
+	// wordDiff is the format of the word diff, and wordRegexp matches the
+	// words, the default being the sequences of non-whitespace characters.
+	wordDiff   WordDiff
+	wordRegexp *regexp.Regexp
 }
 
 // NewUnifiedEncoder returns a new UnifiedEncoder that writes to w.
@@ -74,10 +109,48 @@ func (e *UnifiedEncoder) SetDstPrefix(prefix string) *UnifiedEncoder {
 	return e
 }
 
+// SetIgnoreWhitespace sets the whitespace changes ignored by e and returns
+// e. The files without changes once the whitespaces are ignored are not
+// encoded, unless their mode or path changed.
+func (e *UnifiedEncoder) SetIgnoreWhitespace(flags IgnoreWhitespace) *UnifiedEncoder {
+	e.ignoreWhitespace = flags
+	return e
+}
+
+// SetFunctionContext sets if e shows the whole function as context of the
+// changes, as `git diff --function-context`, and returns e.
+func (e *UnifiedEncoder) SetFunctionContext(functionContext bool) *UnifiedEncoder {
+	e.functionContext = functionContext
+	return e
+}
+
+// SetFuncname sets the function returning the Funcname of the hunk headers
+// of a file and returns e. The Funcname of the source file is used, or the
+// one of the destination file for a new file, DefaultFuncname being used if
+// fn returns nil.
+func (e *UnifiedEncoder) SetFuncname(fn func(path string) *Funcname) *UnifiedEncoder {
+	e.funcname = fn
+	return e
+}
+
+// SetWordDiff sets e's word diff format and the regular expression matching
+// the words, as `git diff --word-diff --word-diff-regex`, and returns e. The
+// words are the sequences of non-whitespace characters if wordRegexp is nil.
+func (e *UnifiedEncoder) SetWordDiff(wordDiff WordDiff, wordRegexp *regexp.Regexp) *UnifiedEncoder {
+	e.wordDiff = wordDiff
+	e.wordRegexp = wordRegexp
+	return e
+}
+
 // Encode encodes patch.
 func (e *UnifiedEncoder) Encode(patch Patch) error {
 	sb := &strings.Builder{}
 
+	color := e.color
+	if e.wordDiff == WordDiffColor && len(color) == 0 {
+		color = NewColorConfig()
+	}
+
 	if message := patch.Message(); message != "" {
 		sb.WriteString(message)
 		if !strings.HasSuffix(message, "\n") {
@@ -86,10 +159,23 @@ func (e *UnifiedEncoder) Encode(patch Patch) error {
 	}
 
 	for _, filePatch := range patch.FilePatches() {
-		e.writeFilePatchHeader(sb, filePatch)
 		g := newHunksGenerator(filePatch.Chunks(), e.contextLines)
-		for _, hunk := range g.Generate() {
-			hunk.writeTo(sb, e.color)
+		g.compareWhitespace(e.ignoreWhitespace)
+		g.funcContext = e.functionContext
+		g.funcname = e.fileFuncname(filePatch)
+
+		hunks := g.Generate()
+		if len(hunks) == 0 && e.ignoreWhitespace != 0 && !mustShowHeader(filePatch) {
+			continue
+		}
+
+		e.writeFilePatchHeader(sb, filePatch, color)
+		for _, hunk := range hunks {
+			if e.wordDiff == WordDiffNone {
+				hunk.writeTo(sb, color)
+			} else {
+				hunk.writeWordDiffTo(sb, color, e.wordDiff, e.wordRegexp)
+			}
 		}
 	}
 
@@ -97,7 +183,38 @@ func (e *UnifiedEncoder) Encode(patch Patch) error {
 	return err
 }
 
-func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch FilePatch) {
+// fileFuncname returns the Funcname of the hunk headers of filePatch, or nil
+// if the hunk headers show the line preceding the hunks.
+func (e *UnifiedEncoder) fileFuncname(filePatch FilePatch) *Funcname {
+	if e.funcname == nil {
+		return nil
+	}
+
+	from, to := filePatch.Files()
+	if from != nil {
+		if f := e.funcname(from.Path()); f != nil {
+			return f
+		}
+	}
+
+	if to != nil {
+		if f := e.funcname(to.Path()); f != nil {
+			return f
+		}
+	}
+
+	return DefaultFuncname
+}
+
+// mustShowHeader returns true if the header of filePatch describes a change
+// even if it has no hunks.
+func mustShowHeader(filePatch FilePatch) bool {
+	from, to := filePatch.Files()
+	return from == nil || to == nil || filePatch.IsBinary() ||
+		from.Path() != to.Path() || from.Mode() != to.Mode()
+}
+
+func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch FilePatch, color ColorConfig) {
 	from, to := filePatch.Files()
 	if from == nil && to == nil {
 		return
@@ -118,7 +235,12 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 				fmt.Sprintf("new mode %o", to.Mode()),
 			)
 		}
-		if from.Path() != to.Path() {
+		if cp, ok := filePatch.(CopyFilePatch); ok && cp.Copied() {
+			lines = append(lines,
+				fmt.Sprintf("copy from %s", from.Path()),
+				fmt.Sprintf("copy to %s", to.Path()),
+			)
+		} else if from.Path() != to.Path() {
 			lines = append(lines,
 				fmt.Sprintf("rename from %s", from.Path()),
 				fmt.Sprintf("rename to %s", to.Path()),
@@ -152,13 +274,13 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 		lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), "/dev/null", isBinary)
 	}
 
-	sb.WriteString(e.color[Meta])
+	sb.WriteString(color[Meta])
 	sb.WriteString(lines[0])
 	for _, line := range lines[1:] {
 		sb.WriteByte('\n')
 		sb.WriteString(line)
 	}
-	sb.WriteString(e.color.Reset(Meta))
+	sb.WriteString(color.Reset(Meta))
 	sb.WriteByte('\n')
 }
 
@@ -175,130 +297,368 @@ func (e *UnifiedEncoder) appendPathLines(lines []string, fromPath, toPath string
 }
 
 type hunksGenerator struct {
-	fromLine, toLine            int
-	ctxLines                    int
-	chunks                      []Chunk
-	current                     *hunk
-	hunks                       []*hunk
-	beforeContext, afterContext []string
+	from, to []string
+	changes  []*change
+	ctxLines int
+
+	// funcContext extends the hunks to the whole functions around the
+	// changes.
+	funcContext bool
+	// funcname finds the function names of the hunk headers, the line
+	// preceding the hunks being used if nil.
+	funcname *Funcname
 }
 
-func newHunksGenerator(chunks []Chunk, ctxLines int) *hunksGenerator {
-	return &hunksGenerator{
-		chunks:   chunks,
-		ctxLines: ctxLines,
-	}
+// change is a group of consecutive deleted and added lines, starting at the
+// line i1 of the source and i2 of the destination.
+type change struct {
+	i1, chg1 int
+	i2, chg2 int
+
+	// ignore is true if the change is only shown in the context of other
+	// changes.
+	ignore bool
 }
 
-func (g *hunksGenerator) Generate() []*hunk {
-	for i, chunk := range g.chunks {
+func newHunksGenerator(chunks []Chunk, ctxLines int) *hunksGenerator {
+	g := &hunksGenerator{ctxLines: ctxLines}
+
+	var c *change
+	for _, chunk := range chunks {
 		lines := splitLines(chunk.Content())
-		nLines := len(lines)
+		if len(lines) == 0 {
+			continue
+		}
+
+		if chunk.Type() == Equal {
+			g.from = append(g.from, lines...)
+			g.to = append(g.to, lines...)
+			c = nil
+			continue
+		}
+
+		if c == nil {
+			c = &change{i1: len(g.from), i2: len(g.to)}
+			g.changes = append(g.changes, c)
+		}
 
 		switch chunk.Type() {
-		case Equal:
-			g.fromLine += nLines
-			g.toLine += nLines
-			g.processEqualsLines(lines, i)
 		case Delete:
-			if nLines != 0 {
-				g.fromLine++
-			}
-
-			g.processHunk(i, chunk.Type())
-			g.fromLine += nLines - 1
-			g.current.AddOp(chunk.Type(), lines...)
+			c.chg1 += len(lines)
+			g.from = append(g.from, lines...)
 		case Add:
-			if nLines != 0 {
-				g.toLine++
+			c.chg2 += len(lines)
+			g.to = append(g.to, lines...)
+		}
+	}
+
+	return g
+}
+
+// Generate returns the hunks of the changes, as the ones of git.
+func (g *hunksGenerator) Generate() []*hunk {
+	var hunks []*hunk
+	var funcLine string
+	funcPrev, prevEnd := -1, 0
+
+	for i := 0; i < len(g.changes); {
+		first, last := g.hunkChanges(i)
+		if first < 0 {
+			break
+		}
+
+		s1, s2, first := g.preContext(i, first)
+		e1, e2, last := g.postContext(last)
+
+		h := &hunk{}
+		if g.funcname == nil {
+			if s1 > prevEnd {
+				h.ctxPrefix = strings.TrimSuffix(g.from[s1-1], "\n")
+			}
+		} else {
+			if l, name := g.funcLine(s1-1, funcPrev); l >= 0 {
+				funcLine = name
 			}
-			g.processHunk(i, chunk.Type())
-			g.toLine += nLines - 1
-			g.current.AddOp(chunk.Type(), lines...)
+
+			funcPrev = s1 - 1
+			h.ctxPrefix = funcLine
 		}
 
-		if i == len(g.chunks)-1 && g.current != nil {
-			g.hunks = append(g.hunks, g.current)
+		h.AddOp(Equal, g.to[s2:g.changes[first].i2]...)
+		s1, s2 = g.changes[first].i1, g.changes[first].i2
+		for _, c := range g.changes[first : last+1] {
+			h.AddOp(Equal, g.to[s2:s2+c.i1-s1]...)
+			h.AddOp(Delete, g.from[c.i1:c.i1+c.chg1]...)
+			h.AddOp(Add, g.to[c.i2:c.i2+c.chg2]...)
+			s1, s2 = c.i1+c.chg1, c.i2+c.chg2
 		}
+
+		h.AddOp(Equal, g.to[s2:e2]...)
+
+		h.fromLine, h.toLine = hunkStart(e1-h.fromCount, h.fromCount), hunkStart(e2-h.toCount, h.toCount)
+		hunks = append(hunks, h)
+
+		prevEnd = e1
+		i = last + 1
 	}
 
-	return g.hunks
+	return hunks
 }
 
-func (g *hunksGenerator) processHunk(i int, op Operation) {
-	if g.current != nil {
-		return
+// hunkStart returns the line number of a hunk header, the line preceding
+// the hunk if it is empty.
+func hunkStart(start, count int) int {
+	if count == 0 {
+		return start
 	}
 
-	var ctxPrefix string
-	linesBefore := len(g.beforeContext)
-	if linesBefore > g.ctxLines {
-		ctxPrefix = g.beforeContext[linesBefore-g.ctxLines-1]
-		g.beforeContext = g.beforeContext[linesBefore-g.ctxLines:]
-		linesBefore = g.ctxLines
+	return start + 1
+}
+
+// hunkChanges returns the first and the last changes of the hunk starting
+// at the change i, skipping the ignorable changes too far from the other
+// changes, or -1 if there are only ignorable changes left.
+func (g *hunksGenerator) hunkChanges(i int) (first, last int) {
+	c := g.changes
+	maxCommon, maxIgnorable := 2*g.ctxLines, g.ctxLines
+
+	for p := i; p < len(c) && c[p].ignore; p++ {
+		if p+1 == len(c) || c[p+1].i1-(c[p].i1+c[p].chg1) >= maxIgnorable {
+			i = p + 1
+		}
 	}
 
-	g.current = &hunk{ctxPrefix: strings.TrimSuffix(ctxPrefix, "\n")}
-	g.current.AddOp(Equal, g.beforeContext...)
+	if i >= len(c) {
+		return -1, -1
+	}
 
-	switch op {
-	case Delete:
-		g.current.fromLine, g.current.toLine =
-			g.addLineNumbers(g.fromLine, g.toLine, linesBefore, i, Add)
-	case Add:
-		g.current.toLine, g.current.fromLine =
-			g.addLineNumbers(g.toLine, g.fromLine, linesBefore, i, Delete)
+	last = i
+	ignored := 0
+	for p := i; p+1 < len(c); p++ {
+		x := c[p+1]
+		distance := x.i1 - (c[p].i1 + c[p].chg1)
+		if distance > maxCommon {
+			break
+		}
+
+		switch {
+		case distance < maxIgnorable && (!x.ignore || last == p):
+			last, ignored = p+1, 0
+		case distance < maxIgnorable:
+			ignored += x.chg2
+		case last != p && x.i1+ignored-(c[last].i1+c[last].chg1) > maxCommon:
+			return i, last
+		case !x.ignore:
+			last, ignored = p+1, 0
+		default:
+			ignored += x.chg2
+		}
 	}
 
-	g.beforeContext = nil
+	return i, last
 }
 
-// addLineNumbers obtains the line numbers in a new chunk.
-func (g *hunksGenerator) addLineNumbers(la, lb int, linesBefore int, i int, op Operation) (cla, clb int) {
-	cla = la - linesBefore
-	// we need to search for a reference for the next diff
-	switch {
-	case linesBefore != 0 && g.ctxLines != 0:
-		if lb > g.ctxLines {
-			clb = lb - g.ctxLines + 1
-		} else {
-			clb = 1
+// preContext returns the start of the hunk starting at the change first,
+// in the source and the destination, and its first change. With the
+// function context the hunk starts with the function of the change, and
+// the ignored changes skipped since the change prev are shown if they are
+// in the function.
+func (g *hunksGenerator) preContext(prev, first int) (s1, s2, start int) {
+	for {
+		c := g.changes[first]
+		s1, s2 = max(c.i1-g.ctxLines, 0), max(c.i2-g.ctxLines, 0)
+		if !g.funcContext {
+			return s1, s2, first
+		}
+
+		i1 := c.i1
+		if i1 >= len(g.from) {
+			// no context is needed if a whole function was appended
+			for i2 := c.i2; i2 < len(g.to); i2++ {
+				if g.isFuncLine(g.to[i2]) {
+					return s1, s2, first
+				}
+			}
+
+			i1 = len(g.from) - 1
+		}
+
+		fs1, _ := g.funcLine(i1, -1)
+		for fs1 > 0 && !isEmptyLine(g.from[fs1-1]) && !g.isFuncLine(g.from[fs1-1]) {
+			fs1--
 		}
-	case g.ctxLines == 0:
-		clb = lb
-	case i != len(g.chunks)-1:
-		next := g.chunks[i+1]
-		if next.Type() == op || next.Type() == Equal {
-			// this diff will be into this chunk
-			clb = lb + 1
+
+		fs1 = max(fs1, 0)
+		if fs1 >= s1 {
+			return s1, s2, first
+		}
+
+		s2, s1 = max(s2-(s1-fs1), 0), fs1
+		for prev != first && g.changes[prev].i1+g.changes[prev].chg1 <= s1 &&
+			g.changes[prev].i2+g.changes[prev].chg2 <= s2 {
+			prev++
+		}
+
+		if prev == first {
+			return s1, s2, first
 		}
+
+		first = prev
 	}
+}
 
-	return
+// postContext returns the end of the hunk ending with the change last, in
+// the source and the destination. With the function context the hunk ends
+// with the function of the change, the following changes in the function
+// being included in the hunk.
+func (g *hunksGenerator) postContext(last int) (e1, e2, end int) {
+	nrec1, nrec2 := len(g.from), len(g.to)
+	for {
+		c := g.changes[last]
+		ctx := min(g.ctxLines, nrec1-(c.i1+c.chg1), nrec2-(c.i2+c.chg2))
+		e1, e2 = c.i1+c.chg1+ctx, c.i2+c.chg2+ctx
+		if !g.funcContext {
+			return e1, e2, last
+		}
+
+		fe1, _ := g.funcLine(c.i1+c.chg1, nrec1)
+		for fe1 > 0 && isEmptyLine(g.from[fe1-1]) {
+			fe1--
+		}
+
+		if fe1 < 0 {
+			fe1 = nrec1
+		}
+
+		if fe1 > e1 {
+			e2, e1 = min(e2+(fe1-e1), nrec2), fe1
+		}
+
+		if last+1 == len(g.changes) {
+			return e1, e2, last
+		}
+
+		l := min(g.changes[last+1].i1, nrec1-1)
+		if l-g.ctxLines > e1 {
+			if fl, _ := g.funcLine(l, e1); fl >= 0 {
+				return e1, e2, last
+			}
+		}
+
+		last++
+	}
 }
 
-func (g *hunksGenerator) processEqualsLines(ls []string, i int) {
-	if g.current == nil {
-		g.beforeContext = append(g.beforeContext, ls...)
+// funcLine returns the first function line of the source from start to
+// limit, excluded, and its function name, or -1 if there is none.
+func (g *hunksGenerator) funcLine(start, limit int) (int, string) {
+	step := 1
+	if start > limit {
+		step = -1
+	}
+
+	for l := start; l != limit && l >= 0 && l < len(g.from); l += step {
+		if name, ok := g.matcher().Match(g.from[l]); ok {
+			return l, name
+		}
+	}
+
+	return -1, ""
+}
+
+func (g *hunksGenerator) isFuncLine(line string) bool {
+	_, ok := g.matcher().Match(line)
+	return ok
+}
+
+func (g *hunksGenerator) matcher() *Funcname {
+	if g.funcname == nil {
+		return DefaultFuncname
+	}
+
+	return g.funcname
+}
+
+// compareWhitespace compares again the lines ignoring the given whitespace
+// changes, with the Myers algorithm and the indent heuristic as git does by
+// default, and marks the changes of blank lines as ignorable.
+func (g *hunksGenerator) compareWhitespace(flags IgnoreWhitespace) {
+	if flags&(IgnoreSpaceChange|IgnoreAllSpace) != 0 {
+		g.changes = diffChanges(strings.Join(g.from, ""), strings.Join(g.to, ""), diff.Options{
+			IndentHeuristic:   true,
+			IgnoreSpaceChange: flags&IgnoreSpaceChange != 0,
+			IgnoreAllSpace:    flags&IgnoreAllSpace != 0,
+		})
+	}
+
+	if flags&IgnoreBlankLines == 0 {
 		return
 	}
 
-	g.afterContext = append(g.afterContext, ls...)
-	if len(g.afterContext) <= g.ctxLines*2 && i != len(g.chunks)-1 {
-		g.current.AddOp(Equal, g.afterContext...)
-		g.afterContext = nil
-	} else {
-		ctxLines := g.ctxLines
-		if ctxLines > len(g.afterContext) {
-			ctxLines = len(g.afterContext)
+	for _, c := range g.changes {
+		c.ignore = areBlankLines(g.from[c.i1:c.i1+c.chg1], flags) &&
+			areBlankLines(g.to[c.i2:c.i2+c.chg2], flags)
+	}
+}
+
+// diffChanges returns the changes of the line diff of src and dst.
+func diffChanges(src, dst string, o diff.Options) []*change {
+	var changes []*change
+	var c *change
+	i1, i2 := 0, 0
+	for _, d := range diff.DoWithOptions(src, dst, o) {
+		n := len(splitLines(d.Text))
+		if d.Type == dmp.DiffEqual {
+			i1, i2 = i1+n, i2+n
+			c = nil
+			continue
+		}
+
+		if c == nil {
+			c = &change{i1: i1, i2: i2}
+			changes = append(changes, c)
+		}
+
+		if d.Type == dmp.DiffDelete {
+			c.chg1 += n
+			i1 += n
+		} else {
+			c.chg2 += n
+			i2 += n
+		}
+	}
+
+	return changes
+}
+
+// areBlankLines returns true if the lines are empty, or only whitespaces if
+// whitespace changes are ignored.
+func areBlankLines(lines []string, flags IgnoreWhitespace) bool {
+	for _, line := range lines {
+		if flags&(IgnoreSpaceChange|IgnoreAllSpace) != 0 {
+			if !isEmptyLine(line) {
+				return false
+			}
+		} else if line != "\n" {
+			return false
 		}
-		g.current.AddOp(Equal, g.afterContext[:ctxLines]...)
-		g.hunks = append(g.hunks, g.current)
+	}
+
+	return true
+}
 
-		g.current = nil
-		g.beforeContext = g.afterContext[ctxLines:]
-		g.afterContext = nil
+func isEmptyLine(line string) bool {
+	for i := 0; i < len(line); i++ {
+		if !isSpace(line[i]) {
+			return false
+		}
 	}
+
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
 }
 
 func splitLines(s string) []string {
@@ -321,6 +681,13 @@ type hunk struct {
 }
 
 func (h *hunk) writeTo(sb *strings.Builder, color ColorConfig) {
+	h.writeHeaderTo(sb, color)
+	for _, op := range h.ops {
+		op.writeTo(sb, color)
+	}
+}
+
+func (h *hunk) writeHeaderTo(sb *strings.Builder, color ColorConfig) {
 	sb.WriteString(color[Frag])
 	sb.WriteString("@@ -")
 
@@ -353,10 +720,6 @@ func (h *hunk) writeTo(sb *strings.Builder, color ColorConfig) {
 	}
 
 	sb.WriteByte('\n')
-
-	for _, op := range h.ops {
-		op.writeTo(sb, color)
-	}
 }
 
 func (h *hunk) AddOp(t Operation, ss ...string) {
@@ -7,7 +7,9 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/color"
 	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/utils/diff"
 
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
 	. "gopkg.in/check.v1"
 )
 
@@ -94,6 +96,203 @@ func (s *UnifiedEncoderTestSuite) TestEncode(c *C) {
 	}
 }
 
+const (
+	goSrc = `package main
+
+import "fmt"
+
+func hello(name string) {
+	greeting := "hello"
+	fmt.Println(greeting, name)
+}
+
+func main() {
+	hello("world")
+	hello("there")
+	hello("again")
+	hello("and again")
+}
+`
+	goDst = `package main
+
+import "fmt"
+
+func hello(name string) {
+	greeting := "hi"
+	fmt.Println(greeting, name)
+}
+
+func main() {
+	hello("world")
+	hello("there")
+	hello("again")
+	hello("and again")
+
+	hello("one more time")
+}
+`
+	notesSrc = "The quick brown fox\njumps over\nthe lazy dog.\n"
+	notesDst = "The quick red fox\n  jumps   over\n\nthe lazy dog.\n"
+)
+
+func (s *UnifiedEncoderTestSuite) TestFuncname(c *C) {
+	for _, t := range []struct {
+		context         int
+		functionContext bool
+		diff            string
+	}{{
+		context: 3,
+		diff: `diff --git a/main.go b/main.go
+index 933c038b1ff0d5c2c9c7598e59e7635d15dadaee..93d922164ec1f07c48ba9f4d911bc15c4a9a77c4 100644
+--- a/main.go
++++ b/main.go
+@@ -3,7 +3,7 @@
+ import "fmt"
+ 
+ func hello(name string) {
+-	greeting := "hello"
++	greeting := "hi"
+ 	fmt.Println(greeting, name)
+ }
+ 
+@@ -12,4 +12,6 @@ func main() {
+ 	hello("there")
+ 	hello("again")
+ 	hello("and again")
++
++	hello("one more time")
+ }
+`,
+	}, {
+		context: 1,
+		diff: `diff --git a/main.go b/main.go
+index 933c038b1ff0d5c2c9c7598e59e7635d15dadaee..93d922164ec1f07c48ba9f4d911bc15c4a9a77c4 100644
+--- a/main.go
++++ b/main.go
+@@ -5,3 +5,3 @@
+ func hello(name string) {
+-	greeting := "hello"
++	greeting := "hi"
+ 	fmt.Println(greeting, name)
+@@ -14,2 +14,4 @@ func main() {
+ 	hello("and again")
++
++	hello("one more time")
+ }
+`,
+	}, {
+		context:         3,
+		functionContext: true,
+		diff: `diff --git a/main.go b/main.go
+index 933c038b1ff0d5c2c9c7598e59e7635d15dadaee..93d922164ec1f07c48ba9f4d911bc15c4a9a77c4 100644
+--- a/main.go
++++ b/main.go
+@@ -3,13 +3,15 @@
+ import "fmt"
+ 
+ func hello(name string) {
+-	greeting := "hello"
++	greeting := "hi"
+ 	fmt.Println(greeting, name)
+ }
+ 
+ func main() {
+ 	hello("world")
+ 	hello("there")
+ 	hello("again")
+ 	hello("and again")
++
++	hello("one more time")
+ }
+`,
+	}} {
+		buffer := bytes.NewBuffer(nil)
+		e := NewUnifiedEncoder(buffer, t.context).
+			SetFunctionContext(t.functionContext).
+			SetFuncname(func(path string) *Funcname {
+				c.Assert(path, Equals, "main.go")
+				return BuiltinFuncname("golang")
+			})
+
+		err := e.Encode(testPatch{filePatches: []testFilePatch{newTestFilePatch("main.go", goSrc, goDst)}})
+		c.Assert(err, IsNil)
+		c.Assert(buffer.String(), Equals, t.diff)
+	}
+}
+
+func (s *UnifiedEncoderTestSuite) TestIgnoreWhitespace(c *C) {
+	for _, t := range []struct {
+		flags IgnoreWhitespace
+		diff  string
+	}{{
+		flags: IgnoreSpaceChange,
+		diff: `diff --git a/notes.txt b/notes.txt
+index 7bd984c89449bc89a64d3638477b3340626149f8..4dd76908bd5d9237f9eb8ad854f117933cb8ef3a 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1,3 +1,4 @@
+-The quick brown fox
+-jumps over
++The quick red fox
++  jumps   over
++
+ the lazy dog.
+`,
+	}, {
+		flags: IgnoreAllSpace | IgnoreBlankLines,
+		diff: `diff --git a/notes.txt b/notes.txt
+index 7bd984c89449bc89a64d3638477b3340626149f8..4dd76908bd5d9237f9eb8ad854f117933cb8ef3a 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1,3 +1,4 @@
+-The quick brown fox
++The quick red fox
+   jumps   over
++
+ the lazy dog.
+`,
+	}} {
+		buffer := bytes.NewBuffer(nil)
+		e := NewUnifiedEncoder(buffer, DefaultContextLines).SetIgnoreWhitespace(t.flags)
+
+		err := e.Encode(testPatch{filePatches: []testFilePatch{newTestFilePatch("notes.txt", notesSrc, notesDst)}})
+		c.Assert(err, IsNil)
+		c.Assert(buffer.String(), Equals, t.diff)
+	}
+}
+
+func (s *UnifiedEncoderTestSuite) TestIgnoreWhitespaceNoChanges(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, DefaultContextLines).SetIgnoreWhitespace(IgnoreSpaceChange | IgnoreBlankLines)
+
+	err := e.Encode(testPatch{filePatches: []testFilePatch{newTestFilePatch("ws.txt", "a b\nc\n", "a  b\n\nc\n")}})
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "")
+}
+
+// newTestFilePatch returns the patch of a modified file, with the chunks of
+// the line diff of from and to.
+func newTestFilePatch(path, from, to string) testFilePatch {
+	fp := testFilePatch{
+		from: &testFile{mode: filemode.Regular, path: path, seed: from},
+		to:   &testFile{mode: filemode.Regular, path: path, seed: to},
+	}
+
+	for _, d := range diff.DoWithOptions(from, to, diff.Options{IndentHeuristic: true}) {
+		op := Equal
+		switch d.Type {
+		case dmp.DiffDelete:
+			op = Delete
+		case dmp.DiffInsert:
+			op = Add
+		}
+
+		fp.chunks = append(fp.chunks, testChunk{content: d.Text, op: op})
+	}
+
+	return fp
+}
+
 var oneChunkPatch Patch = testPatch{
 	message: "",
 	filePatches: []testFilePatch{{
@@ -882,7 +1081,7 @@ index 0adddcde4fd38042c354518351820eb06c417c82..553ae669c7a9303cf848fcc749a25692
 index 0adddcde4fd38042c354518351820eb06c417c82..d39ae38aad7ba9447b5e7998b2e4714f26c9218d 100644
 --- a/onechunk.txt
 +++ b/onechunk.txt
-@@ -22,2 +21 @@ X
+@@ -22,2 +22 @@ X
 -Y
 -Z
 \ No newline at end of file
@@ -0,0 +1,253 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+)
+
+// WordDiff is the format of the word diffs encoded by an UnifiedEncoder, as
+// the `--word-diff` option of git.
+type WordDiff int
+
+const (
+	// WordDiffNone encodes the line diffs.
+	WordDiffNone WordDiff = iota
+	// WordDiffPlain encodes the removed and added words as [-removed-] and
+	// {+added+}.
+	WordDiffPlain
+	// WordDiffColor encodes the removed and added words with the colors
+	// of the removed and added lines, the default colors being used if no
+	// color configuration is set.
+	WordDiffColor
+	// WordDiffPorcelain encodes the words in lines prefixed by ' ', '-' or
+	// '+', the end of the lines being encoded as a '~' line.
+	WordDiffPorcelain
+)
+
+// wordStyle is the encoding of the words of a word diff.
+type wordStyle struct {
+	prefix, suffix string
+	color          ColorKey
+}
+
+// wordDiffStyles are the encodings of the added, removed and unchanged
+// words, and of the end of lines, of the word diff formats. See
+// https://github.com/git/git/blob/v2.39.0/diff.c#L2058.
+var wordDiffStyles = map[WordDiff]struct {
+	new, old, ctx wordStyle
+	newline       string
+}{
+	WordDiffPlain: {
+		new:     wordStyle{"{+", "+}", New},
+		old:     wordStyle{"[-", "-]", Old},
+		ctx:     wordStyle{"", "", Context},
+		newline: "\n",
+	},
+	WordDiffColor: {
+		new:     wordStyle{"", "", New},
+		old:     wordStyle{"", "", Old},
+		ctx:     wordStyle{"", "", Context},
+		newline: "\n",
+	},
+	WordDiffPorcelain: {
+		new:     wordStyle{"+", "\n", New},
+		old:     wordStyle{"-", "\n", Old},
+		ctx:     wordStyle{" ", "\n", Context},
+		newline: "~\n",
+	},
+}
+
+// writeWordDiffTo writes the hunk with the removed and added lines of each
+// change diffed word by word. The missing newlines at end of file are not
+// shown, the last lines being ended as the other ones.
+func (h *hunk) writeWordDiffTo(sb *strings.Builder, color ColorConfig, wordDiff WordDiff, wordRegexp *regexp.Regexp) {
+	h.writeHeaderTo(sb, color)
+
+	w := &wordDiffWriter{sb: sb, color: color, wordDiff: wordDiff, wordRegexp: wordRegexp}
+	for _, op := range h.ops {
+		text := op.text
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+
+		switch op.t {
+		case Delete:
+			w.minus.WriteString(text)
+		case Add:
+			w.plus.WriteString(text)
+		case Equal:
+			w.flush()
+			w.writeContext(text)
+		}
+	}
+
+	w.flush()
+}
+
+type wordDiffWriter struct {
+	sb         *strings.Builder
+	color      ColorConfig
+	wordDiff   WordDiff
+	wordRegexp *regexp.Regexp
+
+	// minus and plus are the removed and added lines of the current change.
+	minus, plus strings.Builder
+}
+
+func (w *wordDiffWriter) writeContext(line string) {
+	text := strings.TrimSuffix(line, "\n")
+	if w.wordDiff == WordDiffPorcelain {
+		text = " " + text
+	}
+
+	w.sb.WriteString(w.color[Context])
+	w.sb.WriteString(text)
+	w.sb.WriteString(w.color.Reset(Context))
+	w.sb.WriteByte('\n')
+
+	if w.wordDiff == WordDiffPorcelain {
+		w.sb.WriteString("~\n")
+	}
+}
+
+// flush writes the word diff of the current change.
+func (w *wordDiffWriter) flush() {
+	minus, plus := w.minus.String(), w.plus.String()
+	w.minus.Reset()
+	w.plus.Reset()
+
+	if minus == "" && plus == "" {
+		return
+	}
+
+	style := wordDiffStyles[w.wordDiff]
+	if plus == "" {
+		w.write(style.old, style.newline, minus)
+		return
+	}
+
+	minusWords := findWords(minus, w.wordRegexp)
+	plusWords := findWords(plus, w.wordRegexp)
+
+	current := 0
+	for _, c := range diffChanges(wordKeys(minus, minusWords), wordKeys(plus, plusWords), diff.Options{Native: true}) {
+		minusBegin, minusEnd := wordsBounds(minusWords, c.i1, c.chg1)
+		plusBegin, plusEnd := wordsBounds(plusWords, c.i2, c.chg2)
+
+		if current != plusBegin {
+			w.write(style.ctx, style.newline, plus[current:plusBegin])
+		}
+
+		if minusBegin != minusEnd {
+			w.write(style.old, style.newline, minus[minusBegin:minusEnd])
+		}
+
+		if plusBegin != plusEnd {
+			w.write(style.new, style.newline, plus[plusBegin:plusEnd])
+		}
+
+		current = plusEnd
+	}
+
+	if current != len(plus) {
+		w.write(style.ctx, style.newline, plus[current:])
+	}
+}
+
+// write writes the text with the given style, the end of lines being
+// written as newline.
+func (w *wordDiffWriter) write(style wordStyle, newline, text string) {
+	for text != "" {
+		line := text
+		end := strings.IndexByte(text, '\n')
+		if end >= 0 {
+			line = text[:end]
+		}
+
+		if line != "" {
+			w.sb.WriteString(w.color[style.color])
+			w.sb.WriteString(style.prefix)
+			w.sb.WriteString(line)
+			w.sb.WriteString(style.suffix)
+			w.sb.WriteString(w.color.Reset(style.color))
+		}
+
+		if end < 0 {
+			return
+		}
+
+		w.sb.WriteString(newline)
+		text = text[end+1:]
+	}
+}
+
+// word is the position of a word in a text.
+type word struct {
+	begin, end int
+}
+
+// findWords returns the words of text, preceded by an empty word at the
+// beginning of the text. The words are the matches of wordRegexp, truncated
+// at the end of line, or the sequences of non-whitespace characters.
+func findWords(text string, wordRegexp *regexp.Regexp) []word {
+	words := []word{{}}
+	for i := 0; i < len(text); i++ {
+		var j int
+		if wordRegexp != nil {
+			m := wordRegexp.FindStringIndex(text[i:])
+			if m == nil {
+				break
+			}
+
+			j = i + m[1]
+			if end := strings.IndexByte(text[i+m[0]:j], '\n'); end >= 0 {
+				j = i + m[0] + end
+			}
+
+			i += m[0]
+			if i >= j {
+				break
+			}
+		} else {
+			for i < len(text) && isSpace(text[i]) {
+				i++
+			}
+
+			if i >= len(text) {
+				break
+			}
+
+			for j = i + 1; j < len(text) && !isSpace(text[j]); j++ {
+			}
+		}
+
+		words = append(words, word{i, j})
+		i = j - 1
+	}
+
+	return words
+}
+
+// wordKeys returns the words of text, one per line, skipping the empty
+// word preceding them.
+func wordKeys(text string, words []word) string {
+	var sb strings.Builder
+	for _, w := range words[1:] {
+		sb.WriteString(text[w.begin:w.end])
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// wordsBounds returns the bounds of the count words starting at the word i,
+// indexed from 0, or the end of the preceding word if count is 0.
+func wordsBounds(words []word, i, count int) (begin, end int) {
+	if count == 0 {
+		return words[i].end, words[i].end
+	}
+
+	return words[i+1].begin, words[i+count].end
+}
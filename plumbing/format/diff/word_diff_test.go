@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/go-git/go-git/v5/plumbing/color"
+
+	. "gopkg.in/check.v1"
+)
+
+type WordDiffSuite struct{}
+
+var _ = Suite(&WordDiffSuite{})
+
+const notesHeader = `diff --git a/notes.txt b/notes.txt
+index 7bd984c89449bc89a64d3638477b3340626149f8..4dd76908bd5d9237f9eb8ad854f117933cb8ef3a 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1,3 +1,4 @@
+`
+
+// The expected diffs are the ones of `git diff --word-diff`.
+var wordDiffTests = []struct {
+	wordDiff   WordDiff
+	wordRegexp *regexp.Regexp
+	color      ColorConfig
+	diff       string
+}{{
+	wordDiff: WordDiffPlain,
+	diff: notesHeader + `The quick [-brown-]{+red+} fox
+  jumps   over
+
+the lazy dog.
+`,
+}, {
+	wordDiff: WordDiffPorcelain,
+	diff: notesHeader + ` The quick 
+-brown
++red
+  fox
+~
+   jumps   over
+~
+~
+ the lazy dog.
+~
+`,
+}, {
+	wordDiff:   WordDiffPlain,
+	wordRegexp: regexp.MustCompile(`[a-z]`),
+	diff: notesHeader + `The quick[-b-] r[-own-]{+ed+} fox
+  jumps   over
+
+the lazy dog.
+`,
+}, {
+	wordDiff: WordDiffColor,
+	diff: color.Bold + "diff --git a/notes.txt b/notes.txt\n" +
+		"index 7bd984c89449bc89a64d3638477b3340626149f8..4dd76908bd5d9237f9eb8ad854f117933cb8ef3a 100644\n" +
+		"--- a/notes.txt\n" +
+		"+++ b/notes.txt" + color.Reset + "\n" +
+		color.Cyan + "@@ -1,3 +1,4 @@" + color.Reset + "\n" +
+		"The quick " + color.Red + "brown" + color.Reset + color.Green + "red" + color.Reset + " fox\n" +
+		"  jumps   over\n" +
+		"\n" +
+		"the lazy dog.\n",
+}, {
+	wordDiff: WordDiffColor,
+	color:    NewColorConfig(WithColor(New, color.Blue)),
+	diff: color.Bold + "diff --git a/notes.txt b/notes.txt\n" +
+		"index 7bd984c89449bc89a64d3638477b3340626149f8..4dd76908bd5d9237f9eb8ad854f117933cb8ef3a 100644\n" +
+		"--- a/notes.txt\n" +
+		"+++ b/notes.txt" + color.Reset + "\n" +
+		color.Cyan + "@@ -1,3 +1,4 @@" + color.Reset + "\n" +
+		"The quick " + color.Red + "brown" + color.Reset + color.Blue + "red" + color.Reset + " fox\n" +
+		"  jumps   over\n" +
+		"\n" +
+		"the lazy dog.\n",
+}}
+
+func (s *WordDiffSuite) TestEncode(c *C) {
+	for i, t := range wordDiffTests {
+		buffer := bytes.NewBuffer(nil)
+		e := NewUnifiedEncoder(buffer, DefaultContextLines).
+			SetColor(t.color).
+			SetWordDiff(t.wordDiff, t.wordRegexp)
+
+		err := e.Encode(testPatch{filePatches: []testFilePatch{newTestFilePatch("notes.txt", notesSrc, notesDst)}})
+		c.Assert(err, IsNil)
+		c.Assert(buffer.String(), Equals, t.diff, Commentf("subtest %d", i))
+	}
+}
+
+func (s *WordDiffSuite) TestEncodeOnlyRemoved(c *C) {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 0).SetWordDiff(WordDiffPlain, nil)
+
+	err := e.Encode(testPatch{filePatches: []testFilePatch{newTestFilePatch("notes.txt", "a b\nc d\ne\n", "a b\ne\n")}})
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, `diff --git a/notes.txt b/notes.txt
+index 0768139eab0418e1296ad6d2e71cb3c5150c4e2e..63600e24234120b7e967066ee64af77a92b213b6 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -2 +1,0 @@ a b
+[-c d-]
+`)
+}
@@ -42,6 +42,18 @@ type Index interface {
 	EntriesByOffset() (EntryIter, error)
 }
 
+// ReverseIndex maps the pack order of the objects in a packfile to their
+// position in the idx file, as stored in pack-*.rev files. When a
+// MemoryIndex has a ReverseIndex, offset lookups are resolved against it
+// instead of building an in-memory offset to hash map.
+type ReverseIndex interface {
+	// Count returns the number of entries in the reverse index.
+	Count() int64
+	// IndexPosition returns the position in the idx file of the n-th object
+	// of the packfile, sorted by offset.
+	IndexPosition(n int64) (uint32, error)
+}
+
 // MemoryIndex is the in memory representation of an idx file.
 type MemoryIndex struct {
 	Version uint32
@@ -59,6 +71,8 @@ type MemoryIndex struct {
 
 	offsetHash       map[int64]plumbing.Hash
 	offsetHashIsFull bool
+
+	rev ReverseIndex
 }
 
 var _ Index = (*MemoryIndex)(nil)
@@ -126,7 +140,7 @@ func (idx *MemoryIndex) FindOffset(h plumbing.Hash) (int64, error) {
 
 	offset := idx.getOffset(k, i)
 
-	if !idx.offsetHashIsFull {
+	if idx.rev == nil && !idx.offsetHashIsFull {
 		// Save the offset for reverse lookup
 		if idx.offsetHash == nil {
 			idx.offsetHash = make(map[int64]plumbing.Hash)
@@ -168,11 +182,108 @@ func (idx *MemoryIndex) getCRC32(firstLevel, secondLevel int) uint32 {
 	return encbin.BigEndian.Uint32(idx.CRC32[firstLevel][offset : offset+4])
 }
 
+// FindPosition returns the position of the object with the given hash in
+// the idx file, which is the position of its hash in the sorted list of
+// object names.
+func (idx *MemoryIndex) FindPosition(h plumbing.Hash) (int64, error) {
+	i, ok := idx.findHashIndex(h)
+	if !ok {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	var start uint32
+	if h[0] > 0 {
+		start = idx.Fanout[h[0]-1]
+	}
+
+	return int64(start) + int64(i), nil
+}
+
+// levels returns the first and second level of the object at the given
+// position in the idx file.
+func (idx *MemoryIndex) levels(pos uint32) (int, int, error) {
+	fan := sort.Search(fanout, func(i int) bool {
+		return idx.Fanout[i] > pos
+	})
+
+	if fan == fanout {
+		return 0, 0, ErrMalformedIdxFile
+	}
+
+	var start uint32
+	if fan > 0 {
+		start = idx.Fanout[fan-1]
+	}
+
+	return idx.FanoutMapping[fan], int(pos - start), nil
+}
+
+// SetReverseIndex sets the reverse index used to resolve offset lookups,
+// releasing any offset to hash map built so far.
+func (idx *MemoryIndex) SetReverseIndex(rev ReverseIndex) error {
+	count, err := idx.Count()
+	if err != nil {
+		return err
+	}
+
+	if rev.Count() != count {
+		return ErrMalformedIdxFile
+	}
+
+	idx.rev = rev
+	idx.offsetHash = nil
+	idx.offsetHashIsFull = false
+
+	return nil
+}
+
+// ReverseIndex returns the reverse index set with SetReverseIndex, if any.
+func (idx *MemoryIndex) ReverseIndex() ReverseIndex {
+	return idx.rev
+}
+
+// findHashInReverseIndex does a binary search of the given offset using the
+// reverse index, which lists the objects sorted by offset.
+func (idx *MemoryIndex) findHashInReverseIndex(o int64) (plumbing.Hash, error) {
+	var hash plumbing.Hash
+
+	low, high := int64(0), idx.rev.Count()
+	for low < high {
+		mid := (low + high) >> 1
+		pos, err := idx.rev.IndexPosition(mid)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		firstLevel, secondLevel, err := idx.levels(pos)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		offset := int64(idx.getOffset(firstLevel, secondLevel))
+		switch {
+		case offset < o:
+			low = mid + 1
+		case offset > o:
+			high = mid
+		default:
+			copy(hash[:], idx.Names[firstLevel][secondLevel*objectIDLength:])
+			return hash, nil
+		}
+	}
+
+	return plumbing.ZeroHash, plumbing.ErrObjectNotFound
+}
+
 // FindHash implements the Index interface.
 func (idx *MemoryIndex) FindHash(o int64) (plumbing.Hash, error) {
 	var hash plumbing.Hash
 	var ok bool
 
+	if idx.rev != nil {
+		return idx.findHashInReverseIndex(o)
+	}
+
 	if idx.offsetHash != nil {
 		if hash, ok = idx.offsetHash[o]; ok {
 			return hash, nil
@@ -241,6 +352,24 @@ func (idx *MemoryIndex) EntriesByOffset() (EntryIter, error) {
 		entries: make(entriesByOffset, count),
 	}
 
+	if idx.rev != nil {
+		for n := int64(0); n < count; n++ {
+			pos, err := idx.rev.IndexPosition(n)
+			if err != nil {
+				return nil, err
+			}
+
+			firstLevel, secondLevel, err := idx.levels(pos)
+			if err != nil {
+				return nil, err
+			}
+
+			iter.entries[n] = idx.entry(firstLevel, secondLevel)
+		}
+
+		return iter, nil
+	}
+
 	entries, err := idx.Entries()
 	if err != nil {
 		return nil, err
@@ -287,10 +416,7 @@ func (i *idxfileEntryIter) Next() (*Entry, error) {
 		}
 
 		mappedFirstLevel := i.idx.FanoutMapping[i.firstLevel]
-		entry := new(Entry)
-		copy(entry.Hash[:], i.idx.Names[mappedFirstLevel][i.secondLevel*objectIDLength:])
-		entry.Offset = i.idx.getOffset(mappedFirstLevel, i.secondLevel)
-		entry.CRC32 = i.idx.getCRC32(mappedFirstLevel, i.secondLevel)
+		entry := i.idx.entry(mappedFirstLevel, i.secondLevel)
 
 		i.secondLevel++
 		i.total++
@@ -299,6 +425,15 @@ func (i *idxfileEntryIter) Next() (*Entry, error) {
 	}
 }
 
+func (idx *MemoryIndex) entry(firstLevel, secondLevel int) *Entry {
+	entry := new(Entry)
+	copy(entry.Hash[:], idx.Names[firstLevel][secondLevel*objectIDLength:])
+	entry.Offset = idx.getOffset(firstLevel, secondLevel)
+	entry.CRC32 = idx.getCRC32(firstLevel, secondLevel)
+
+	return entry
+}
+
 func (i *idxfileEntryIter) Close() error {
 	i.firstLevel = fanout
 	return nil
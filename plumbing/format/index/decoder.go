@@ -12,6 +12,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/hash"
 	"github.com/go-git/go-git/v5/utils/binary"
+	"github.com/go-git/go-git/v5/utils/ioutil"
 )
 
 var (
@@ -26,8 +27,24 @@ var (
 	ErrInvalidChecksum = errors.New("invalid checksum")
 	// ErrUnknownExtension is returned when an index extension is encountered that is considered mandatory
 	ErrUnknownExtension = errors.New("unknown extension")
+	// ErrMalformedBitmap is returned by Decode when an EWAH bitmap of an
+	// extension is malformed
+	ErrMalformedBitmap = errors.New("malformed ewah bitmap")
+	// ErrInvalidSplitIndex is returned by Decode when the entries of a split
+	// index don't match the ones of its shared index
+	ErrInvalidSplitIndex = errors.New("invalid split index")
+	// ErrInvalidUntrackedCache is returned by Decode when the untracked
+	// cache extension is malformed
+	ErrInvalidUntrackedCache = errors.New("invalid untracked cache")
+	// ErrSharedIndexRequired is returned by Decode when a split index is
+	// decoded without a SharedIndexFunc
+	ErrSharedIndexRequired = errors.New("shared index required by split index")
 )
 
+// SharedIndexFunc returns the shared index file with the given hash, stored
+// in $GIT_DIR/sharedindex.<hash>, required to decode split indexes.
+type SharedIndexFunc func(plumbing.Hash) (io.ReadCloser, error)
+
 const (
 	entryHeaderLength = 62
 	entryExtended     = 0x4000
@@ -45,6 +62,11 @@ type Decoder struct {
 	lastEntry *Entry
 
 	extReader *bufio.Reader
+
+	sharedIndex    SharedIndexFunc
+	splitDeleted   []bool
+	splitReplaced  []bool
+	fsMonitorDirty []bool
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -59,6 +81,14 @@ func NewDecoder(r io.Reader) *Decoder {
 	}
 }
 
+// NewDecoderWithSharedIndex returns a new decoder that reads from r, able to
+// decode split indexes, reading their shared index with the given function.
+func NewDecoderWithSharedIndex(r io.Reader, shared SharedIndexFunc) *Decoder {
+	d := NewDecoder(r)
+	d.sharedIndex = shared
+	return d
+}
+
 // Decode reads the whole index object from its input and stores it in the
 // value pointed to by idx.
 func (d *Decoder) Decode(idx *Index) error {
@@ -77,7 +107,50 @@ func (d *Decoder) Decode(idx *Index) error {
 		return err
 	}
 
-	return d.readExtensions(idx)
+	if err := d.readExtensions(idx); err != nil {
+		return err
+	}
+
+	if err := d.mergeSharedIndex(idx); err != nil {
+		return err
+	}
+
+	d.applyFileSystemMonitor(idx)
+	return nil
+}
+
+func (d *Decoder) mergeSharedIndex(idx *Index) (err error) {
+	if idx.SplitIndex == nil || idx.SplitIndex.SharedIndex.IsZero() {
+		return nil
+	}
+
+	if d.sharedIndex == nil {
+		return ErrSharedIndexRequired
+	}
+
+	f, err := d.sharedIndex(idx.SplitIndex.SharedIndex)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	shared := &Index{}
+	if err := NewDecoder(f).Decode(shared); err != nil {
+		return err
+	}
+
+	return idx.SplitIndex.merge(idx, shared, d.splitDeleted, d.splitReplaced)
+}
+
+func (d *Decoder) applyFileSystemMonitor(idx *Index) {
+	if idx.FileSystemMonitor == nil {
+		return
+	}
+
+	for i, e := range idx.Entries {
+		e.FSMonitorValid = i >= len(d.fsMonitorDirty) || !d.fsMonitorDirty[i]
+	}
 }
 
 func (d *Decoder) readEntries(idx *Index, count int) error {
@@ -209,9 +282,6 @@ func (d *Decoder) padEntry(idx *Index, e *Entry, read int) error {
 }
 
 func (d *Decoder) readExtensions(idx *Index) error {
-	// TODO: support 'Split index' and 'Untracked cache' extensions, take in
-	// count that they are not supported by jgit or libgit
-
 	var expected []byte
 	var peeked []byte
 	var err error
@@ -271,6 +341,24 @@ func (d *Decoder) readExtension(idx *Index) error {
 		if err := d.Decode(idx.EndOfIndexEntry); err != nil {
 			return err
 		}
+	case bytes.Equal(header[:], splitIndexExtSignature):
+		idx.SplitIndex = &SplitIndex{}
+		sd := &splitIndexDecoder{r}
+		if err := sd.Decode(idx.SplitIndex, &d.splitDeleted, &d.splitReplaced); err != nil {
+			return err
+		}
+	case bytes.Equal(header[:], untrackedCacheExtSignature):
+		idx.UntrackedCache = &UntrackedCache{}
+		d := &untrackedCacheDecoder{r}
+		if err := d.Decode(idx.UntrackedCache); err != nil {
+			return err
+		}
+	case bytes.Equal(header[:], fsMonitorExtSignature):
+		idx.FileSystemMonitor = &FileSystemMonitor{}
+		fd := &fsMonitorDecoder{r}
+		if err := fd.Decode(idx.FileSystemMonitor, &d.fsMonitorDirty); err != nil {
+			return err
+		}
 	default:
 		// See https://git-scm.com/docs/index-format, which says:
 		// If the first byte is 'A'..'Z' the extension is optional and can be ignored.
@@ -284,7 +372,9 @@ func (d *Decoder) readExtension(idx *Index) error {
 		}
 	}
 
-	return nil
+	// skip any remaining data, like the trailing NUL of the untracked cache
+	_, err = io.Copy(io.Discard, r)
+	return err
 }
 
 func (d *Decoder) getExtensionReader() (*bufio.Reader, error) {
@@ -348,10 +438,6 @@ func (d *treeExtensionDecoder) Decode(t *Tree) error {
 			return err
 		}
 
-		if e == nil {
-			continue
-		}
-
 		t.Entries = append(t.Entries, *e)
 	}
 }
@@ -376,12 +462,6 @@ func (d *treeExtensionDecoder) readEntry() (*TreeEntry, error) {
 		return nil, err
 	}
 
-	// An entry can be in an invalidated state and is represented by having a
-	// negative number in the entry_count field.
-	if i == -1 {
-		return nil, nil
-	}
-
 	e.Entries = i
 	trees, err := binary.ReadUntil(d.r, '\n')
 	if err != nil {
@@ -394,6 +474,14 @@ func (d *treeExtensionDecoder) readEntry() (*TreeEntry, error) {
 	}
 
 	e.Trees = i
+
+	// An entry can be in an invalidated state and is represented by having a
+	// negative number in the entry_count field, without hash. It is kept, as
+	// its subtrees follow it.
+	if e.Entries < 0 {
+		return e, nil
+	}
+
 	_, err = io.ReadFull(d.r, e.Hash[:])
 	if err != nil {
 		return nil, err
@@ -483,6 +571,249 @@ func (d *endOfIndexEntryDecoder) Decode(e *EndOfIndexEntry) error {
 	return err
 }
 
+type splitIndexDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *splitIndexDecoder) Decode(s *SplitIndex, deleted, replaced *[]bool) error {
+	if _, err := io.ReadFull(d.r, s.SharedIndex[:]); err != nil {
+		return err
+	}
+
+	// the bitmaps are optional
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return nil
+	}
+
+	var err error
+	if *deleted, err = readEWAH(d.r); err != nil {
+		return err
+	}
+
+	*replaced, err = readEWAH(d.r)
+	return err
+}
+
+type untrackedCacheDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *untrackedCacheDecoder) Decode(u *UntrackedCache) error {
+	l, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return err
+	}
+
+	envs := make([]byte, l)
+	if _, err := io.ReadFull(d.r, envs); err != nil {
+		return err
+	}
+
+	for _, env := range bytes.Split(envs, []byte{0}) {
+		if len(env) != 0 {
+			u.Environments = append(u.Environments, string(env))
+		}
+	}
+
+	if err := readUntrackedCacheStats(d.r, &u.InfoExcludeStats); err != nil {
+		return err
+	}
+
+	if err := readUntrackedCacheStats(d.r, &u.ExcludesFileStats); err != nil {
+		return err
+	}
+
+	flow := []interface{}{
+		&u.DirFlags,
+		&u.InfoExcludeHash,
+		&u.ExcludesFileHash,
+	}
+
+	if err := binary.Read(d.r, flow...); err != nil {
+		return err
+	}
+
+	exclude, err := binary.ReadUntil(d.r, '\x00')
+	if err != nil {
+		return err
+	}
+
+	u.ExcludePerDir = string(exclude)
+
+	count, err := binary.ReadVariableWidthInt(d.r)
+	if err == io.EOF || count == 0 {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var dirs []*UntrackedCacheDirectory
+	if u.Root, err = d.readDirectory(&dirs); err != nil {
+		return err
+	}
+
+	if int64(len(dirs)) != count {
+		return ErrInvalidUntrackedCache
+	}
+
+	return d.readDirectoriesData(dirs)
+}
+
+func (d *untrackedCacheDecoder) readDirectory(dirs *[]*UntrackedCacheDirectory) (*UntrackedCacheDirectory, error) {
+	untracked, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	subdirs, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := binary.ReadUntil(d.r, '\x00')
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &UntrackedCacheDirectory{Name: string(name)}
+	*dirs = append(*dirs, dir)
+
+	for i := int64(0); i < untracked; i++ {
+		name, err := binary.ReadUntil(d.r, '\x00')
+		if err != nil {
+			return nil, err
+		}
+
+		dir.Untracked = append(dir.Untracked, string(name))
+	}
+
+	for i := int64(0); i < subdirs; i++ {
+		subdir, err := d.readDirectory(dirs)
+		if err != nil {
+			return nil, err
+		}
+
+		dir.Directories = append(dir.Directories, subdir)
+	}
+
+	return dir, nil
+}
+
+func (d *untrackedCacheDecoder) readDirectoriesData(dirs []*UntrackedCacheDirectory) error {
+	valid, err := readEWAH(d.r)
+	if err != nil {
+		return err
+	}
+
+	checkOnly, err := readEWAH(d.r)
+	if err != nil {
+		return err
+	}
+
+	hashValid, err := readEWAH(d.r)
+	if err != nil {
+		return err
+	}
+
+	if len(valid) > len(dirs) || len(checkOnly) > len(dirs) || len(hashValid) > len(dirs) {
+		return ErrInvalidUntrackedCache
+	}
+
+	for i, ok := range checkOnly {
+		dirs[i].CheckOnly = ok
+	}
+
+	for i, ok := range valid {
+		if !ok {
+			continue
+		}
+
+		dirs[i].Valid = true
+		if err := readUntrackedCacheStats(d.r, &dirs[i].Stats); err != nil {
+			return err
+		}
+	}
+
+	for i, ok := range hashValid {
+		if !ok {
+			continue
+		}
+
+		if _, err := io.ReadFull(d.r, dirs[i].ExcludeHash[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readUntrackedCacheStats(r io.Reader, s *UntrackedCacheStats) error {
+	var sec, nsec, msec, mnsec uint32
+	flow := []interface{}{
+		&sec, &nsec,
+		&msec, &mnsec,
+		&s.Dev,
+		&s.Inode,
+		&s.UID,
+		&s.GID,
+		&s.Size,
+	}
+
+	if err := binary.Read(r, flow...); err != nil {
+		return err
+	}
+
+	if sec != 0 || nsec != 0 {
+		s.CreatedAt = time.Unix(int64(sec), int64(nsec))
+	}
+
+	if msec != 0 || mnsec != 0 {
+		s.ModifiedAt = time.Unix(int64(msec), int64(mnsec))
+	}
+
+	return nil
+}
+
+type fsMonitorDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *fsMonitorDecoder) Decode(m *FileSystemMonitor, dirty *[]bool) error {
+	version, err := binary.ReadUint32(d.r)
+	if err != nil {
+		return err
+	}
+
+	switch version {
+	case 1:
+		timestamp, err := binary.ReadUint64(d.r)
+		if err != nil {
+			return err
+		}
+
+		m.Token = strconv.FormatUint(timestamp, 10)
+	case 2:
+		token, err := binary.ReadUntil(d.r, '\x00')
+		if err != nil {
+			return err
+		}
+
+		m.Token = string(token)
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	// size of the bitmap
+	if _, err := binary.ReadUint32(d.r); err != nil {
+		return err
+	}
+
+	*dirty, err = readEWAH(d.r)
+	return err
+}
+
 type unknownExtensionDecoder struct {
 	r *bufio.Reader
 }
@@ -320,3 +320,23 @@ func (s *IndexSuite) TestDecodeInvalidHash(c *C) {
 	err = d.Decode(idx)
 	c.Assert(err, ErrorMatches, ErrInvalidChecksum.Error())
 }
+
+func (s *IndexSuite) TestDecodeFileSystemMonitorV1(c *C) {
+	data := "\x00\x00\x00\x01" + // version
+		"\x00\x00\x00\x00\x00\x00\x00\x2a" + // timestamp
+		"\x00\x00\x00\x1c" + // bitmap size
+		"\x00\x00\x00\x02\x00\x00\x00\x02" + // bitmap of 2 bits, 2 words
+		"\x00\x00\x00\x02\x00\x00\x00\x00" + // RLW with 1 literal word
+		"\x00\x00\x00\x00\x00\x00\x00\x02" + // second entry is dirty
+		"\x00\x00\x00\x00" // RLW position
+
+	f := bytes.NewReader(s.buildIndexWithExtension(c, "FSMN", data))
+
+	idx := &Index{}
+	err := NewDecoder(f).Decode(idx)
+	c.Assert(err, IsNil)
+	c.Assert(idx.FileSystemMonitor.Token, Equals, "42")
+	c.Assert(idx.Entries[0].FSMonitorValid, Equals, true)
+	c.Assert(idx.Entries[1].FSMonitorValid, Equals, false)
+	c.Assert(idx.Entries[2].FSMonitorValid, Equals, true)
+}
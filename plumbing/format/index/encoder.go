@@ -43,38 +43,83 @@ func (e *Encoder) Encode(idx *Index) error {
 }
 
 func (e *Encoder) encode(idx *Index, footer bool) error {
-
-	// TODO: support extensions
 	if idx.Version > EncodeVersionSupported {
 		return ErrUnsupportedVersion
 	}
 
-	if err := e.encodeHeader(idx); err != nil {
+	sort.Sort(byName(idx.Entries))
+
+	// the extended flags require the version 3
+	if idx.Version == 2 && hasExtendedFlags(idx.Entries) {
+		idx.Version = 3
+	}
+
+	entries := idx.Entries
+	var deleted, replaced []bool
+	split := idx.SplitIndex != nil && idx.SplitIndex.Base != nil &&
+		!idx.SplitIndex.SharedIndex.IsZero()
+	if split {
+		entries, deleted, replaced = idx.SplitIndex.split(idx.Entries)
+	}
+
+	if err := e.encodeHeader(idx, entries); err != nil {
 		return err
 	}
 
-	if err := e.encodeEntries(idx); err != nil {
+	if err := e.encodeEntries(idx, entries); err != nil {
 		return err
 	}
 
+	if split {
+		if err := e.encodeSplitIndex(idx.SplitIndex, deleted, replaced); err != nil {
+			return err
+		}
+	}
+
+	if idx.Cache != nil {
+		if err := e.encodeTree(idx.Cache); err != nil {
+			return err
+		}
+	}
+
+	if idx.UntrackedCache != nil {
+		if err := e.encodeUntrackedCache(idx.UntrackedCache); err != nil {
+			return err
+		}
+	}
+
+	if idx.FileSystemMonitor != nil {
+		if err := e.encodeFileSystemMonitor(idx); err != nil {
+			return err
+		}
+	}
+
 	if footer {
 		return e.encodeFooter()
 	}
 	return nil
 }
 
-func (e *Encoder) encodeHeader(idx *Index) error {
+func hasExtendedFlags(entries []*Entry) bool {
+	for _, e := range entries {
+		if e.IntentToAdd || e.SkipWorktree {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Encoder) encodeHeader(idx *Index, entries []*Entry) error {
 	return binary.Write(e.w,
 		indexSignature,
 		idx.Version,
-		uint32(len(idx.Entries)),
+		uint32(len(entries)),
 	)
 }
 
-func (e *Encoder) encodeEntries(idx *Index) error {
-	sort.Sort(byName(idx.Entries))
-
-	for _, entry := range idx.Entries {
+func (e *Encoder) encodeEntries(idx *Index, entries []*Entry) error {
+	for _, entry := range entries {
 		if err := e.encodeEntry(idx, entry); err != nil {
 			return err
 		}
@@ -205,6 +250,206 @@ func (e *Encoder) encodeRawExtension(signature string, data []byte) error {
 	return nil
 }
 
+func (e *Encoder) encodeTree(t *Tree) error {
+	buf := bytes.NewBuffer(nil)
+	for _, entry := range t.Entries {
+		_, err := fmt.Fprintf(buf, "%s\x00%d %d\n", entry.Path, entry.Entries, entry.Trees)
+		if err != nil {
+			return err
+		}
+
+		if entry.Entries >= 0 {
+			buf.Write(entry.Hash[:])
+		}
+	}
+
+	return e.encodeRawExtension(string(treeExtSignature), buf.Bytes())
+}
+
+func (e *Encoder) encodeSplitIndex(s *SplitIndex, deleted, replaced []bool) error {
+	buf := bytes.NewBuffer(nil)
+	if err := binary.Write(buf, s.SharedIndex[:]); err != nil {
+		return err
+	}
+
+	if err := writeEWAH(buf, deleted); err != nil {
+		return err
+	}
+
+	if err := writeEWAH(buf, replaced); err != nil {
+		return err
+	}
+
+	return e.encodeRawExtension(string(splitIndexExtSignature), buf.Bytes())
+}
+
+func (e *Encoder) encodeUntrackedCache(u *UntrackedCache) error {
+	buf := bytes.NewBuffer(nil)
+
+	var envs []byte
+	for _, env := range u.Environments {
+		envs = append(envs, env...)
+		envs = append(envs, '\x00')
+	}
+
+	if err := binary.WriteVariableWidthInt(buf, int64(len(envs))); err != nil {
+		return err
+	}
+
+	buf.Write(envs)
+	if err := e.encodeUntrackedCacheStats(buf, &u.InfoExcludeStats); err != nil {
+		return err
+	}
+
+	if err := e.encodeUntrackedCacheStats(buf, &u.ExcludesFileStats); err != nil {
+		return err
+	}
+
+	err := binary.Write(buf,
+		u.DirFlags,
+		u.InfoExcludeHash[:],
+		u.ExcludesFileHash[:],
+		[]byte(u.ExcludePerDir+string('\x00')),
+	)
+	if err != nil {
+		return err
+	}
+
+	if u.Root == nil {
+		if err := binary.WriteVariableWidthInt(buf, 0); err != nil {
+			return err
+		}
+
+		return e.encodeRawExtension(string(untrackedCacheExtSignature), buf.Bytes())
+	}
+
+	var dirs []*UntrackedCacheDirectory
+	blocks := bytes.NewBuffer(nil)
+	if err := e.encodeUntrackedCacheDirectory(blocks, u.Root, &dirs); err != nil {
+		return err
+	}
+
+	if err := binary.WriteVariableWidthInt(buf, int64(len(dirs))); err != nil {
+		return err
+	}
+
+	buf.Write(blocks.Bytes())
+
+	valid := make([]bool, len(dirs))
+	checkOnly := make([]bool, len(dirs))
+	hashValid := make([]bool, len(dirs))
+	stats := bytes.NewBuffer(nil)
+	hashes := bytes.NewBuffer(nil)
+	for i, dir := range dirs {
+		if dir.Valid {
+			valid[i] = true
+			checkOnly[i] = dir.CheckOnly
+			if err := e.encodeUntrackedCacheStats(stats, &dir.Stats); err != nil {
+				return err
+			}
+		}
+
+		if !dir.ExcludeHash.IsZero() {
+			hashValid[i] = true
+			hashes.Write(dir.ExcludeHash[:])
+		}
+	}
+
+	for _, bits := range [][]bool{valid, checkOnly, hashValid} {
+		if err := writeEWAH(buf, bits); err != nil {
+			return err
+		}
+	}
+
+	buf.Write(stats.Bytes())
+	buf.Write(hashes.Bytes())
+	buf.WriteByte('\x00')
+
+	return e.encodeRawExtension(string(untrackedCacheExtSignature), buf.Bytes())
+}
+
+func (e *Encoder) encodeUntrackedCacheDirectory(w io.Writer, d *UntrackedCacheDirectory, dirs *[]*UntrackedCacheDirectory) error {
+	*dirs = append(*dirs, d)
+
+	var untracked []string
+	if d.Valid {
+		untracked = d.Untracked
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(len(untracked))); err != nil {
+		return err
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(len(d.Directories))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, []byte(d.Name+string('\x00'))); err != nil {
+		return err
+	}
+
+	for _, name := range untracked {
+		if err := binary.Write(w, []byte(name+string('\x00'))); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range d.Directories {
+		if err := e.encodeUntrackedCacheDirectory(w, dir, dirs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeUntrackedCacheStats(w io.Writer, s *UntrackedCacheStats) error {
+	sec, nsec, err := e.timeToUint32(&s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	msec, mnsec, err := e.timeToUint32(&s.ModifiedAt)
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w,
+		sec, nsec,
+		msec, mnsec,
+		s.Dev,
+		s.Inode,
+		s.UID,
+		s.GID,
+		s.Size,
+	)
+}
+
+func (e *Encoder) encodeFileSystemMonitor(idx *Index) error {
+	dirty := make([]bool, len(idx.Entries))
+	for i, entry := range idx.Entries {
+		dirty[i] = !entry.FSMonitorValid
+	}
+
+	bitmap := bytes.NewBuffer(nil)
+	if err := writeEWAH(bitmap, dirty); err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := binary.Write(buf,
+		uint32(2),
+		[]byte(idx.FileSystemMonitor.Token+string('\x00')),
+		uint32(bitmap.Len()),
+		bitmap.Bytes(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return e.encodeRawExtension(string(fsMonitorExtSignature), buf.Bytes())
+}
+
 func (e *Encoder) timeToUint32(t *time.Time) (uint32, uint32, error) {
 	if t.IsZero() {
 		return 0, 0, nil
@@ -2,6 +2,7 @@ package index
 
 import (
 	"bytes"
+	"io"
 	"strings"
 	"time"
 
@@ -160,3 +161,152 @@ func (s *IndexSuite) TestEncodeWithSkipWorktreeUnsupportedVersion(c *C) {
 	c.Assert(cmp.Equal(idx, output), Equals, true)
 	c.Assert(output.Entries[0].SkipWorktree, Equals, true)
 }
+
+func (s *IndexSuite) TestEncodeExtendedFlagsVersion(c *C) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{{Name: "foo"}, {Name: "bar", SkipWorktree: true}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+
+	c.Assert(output.Version, Equals, uint32(3))
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeSplitIndex(c *C) {
+	shared := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			{Name: "bar", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+			{Name: "baz", Hash: plumbing.NewHash("5c45cd9bf5edb96a7ea0fe7d7d0e2cbc0bca1bf5")},
+			{Name: "foo", Hash: plumbing.NewHash("2e65efe2a145dda7ee51d1741299f848e5bf752e")},
+		},
+	}
+
+	sharedBuf := bytes.NewBuffer(nil)
+	err := NewEncoder(sharedBuf).Encode(shared)
+	c.Assert(err, IsNil)
+
+	sharedHash := plumbing.NewHash("b29c8946e0e192fae2edc1dabf7be71e8ecf3e25")
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			{Name: "bar", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+			{Name: "foo", Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+			{Name: "qux", Hash: plumbing.NewHash("5c45cd9bf5edb96a7ea0fe7d7d0e2cbc0bca1bf5")},
+		},
+		SplitIndex: &SplitIndex{SharedIndex: sharedHash, Base: shared},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	// only foo, replaced, and qux, added, are written
+	c.Assert(buf.Bytes()[8:12], DeepEquals, []byte{0, 0, 0, 2})
+
+	output := &Index{}
+	err = NewDecoder(bytes.NewReader(buf.Bytes())).Decode(output)
+	c.Assert(err, Equals, ErrSharedIndexRequired)
+
+	output = &Index{}
+	d := NewDecoderWithSharedIndex(buf, func(h plumbing.Hash) (io.ReadCloser, error) {
+		c.Assert(h, Equals, sharedHash)
+		return io.NopCloser(sharedBuf), nil
+	})
+
+	err = d.Decode(output)
+	c.Assert(err, IsNil)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeUntrackedCache(c *C) {
+	idx := &Index{
+		Version: 2,
+		UntrackedCache: &UntrackedCache{
+			Environments:    []string{"Location /foo, system Linux"},
+			DirFlags:        6,
+			InfoExcludeHash: plumbing.NewHash("cc30ca8b9b10bb92f8e5c96ee94348c6c4ac93e6"),
+			InfoExcludeStats: UntrackedCacheStats{
+				CreatedAt:  time.Unix(1500000000, 42),
+				ModifiedAt: time.Unix(1500000000, 42),
+				Inode:      4242,
+				Size:       240,
+			},
+			ExcludePerDir: ".gitignore",
+			Root: &UntrackedCacheDirectory{
+				Untracked:   []string{"qux/", "foo"},
+				Valid:       true,
+				Stats:       UntrackedCacheStats{ModifiedAt: time.Unix(1500000000, 84)},
+				ExcludeHash: plumbing.NewHash("beed5994208e84c68b967c022f14e2629328918f"),
+				Directories: []*UntrackedCacheDirectory{{
+					Name:      "bar",
+					Untracked: []string{"baz"},
+					Valid:     true,
+					CheckOnly: true,
+					Stats:     UntrackedCacheStats{ModifiedAt: time.Unix(1500000000, 168)},
+				}, {
+					Name: "qux",
+				}},
+			},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeFileSystemMonitor(c *C) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			{Name: "bar", FSMonitorValid: true},
+			{Name: "foo"},
+			{Name: "qux", FSMonitorValid: true},
+		},
+		FileSystemMonitor: &FileSystemMonitor{Token: "token"},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeTree(c *C) {
+	idx := &Index{
+		Version: 2,
+		Cache: &Tree{Entries: []TreeEntry{
+			{Path: "", Entries: -1, Trees: 2},
+			{Path: "bar", Entries: 2, Trees: 1, Hash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+			{Path: "baz", Entries: 1, Trees: 0, Hash: plumbing.NewHash("5c45cd9bf5edb96a7ea0fe7d7d0e2cbc0bca1bf5")},
+			{Path: "qux", Entries: -1, Trees: 0},
+		}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
@@ -0,0 +1,145 @@
+package index
+
+import (
+	"io"
+
+	"github.com/go-git/go-git/v5/utils/binary"
+)
+
+const (
+	ewahWordBits       = 64
+	ewahRunningBits    = 32
+	ewahLiteralBits    = ewahWordBits - 1 - ewahRunningBits
+	ewahMaxRunning     = 1<<ewahRunningBits - 1
+	ewahMaxLiteral     = 1<<ewahLiteralBits - 1
+	ewahCleanOnes      = ^uint64(0)
+	ewahCleanZeros     = uint64(0)
+	ewahRunningBitMask = 1
+)
+
+// readEWAH reads a bitmap compressed with the Enhanced Word-Aligned Hybrid
+// format, as written by git:
+//
+//   - 32-bit number of bits of the bitmap
+//   - 32-bit number of 64-bit words
+//   - the 64-bit words: each "running length word" (RLW) is followed by its
+//     literal words, it has the running bit in the bit 0, the number of
+//     running words in the next 32 bits and the number of literal words in
+//     the remaining 31 bits
+//   - 32-bit position of the last RLW
+func readEWAH(r io.Reader) ([]bool, error) {
+	size, err := binary.ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := binary.ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed := make([]uint64, count)
+	for i := range compressed {
+		if compressed[i], err = binary.ReadUint64(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := binary.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	var words []uint64
+	for i := 0; i < len(compressed); {
+		rlw := compressed[i]
+		i++
+
+		running := rlw >> 1 & ewahMaxRunning
+		literal := int(rlw >> (1 + ewahRunningBits))
+		if i+literal > len(compressed) {
+			return nil, ErrMalformedBitmap
+		}
+
+		clean := ewahCleanZeros
+		if rlw&ewahRunningBitMask != 0 {
+			clean = ewahCleanOnes
+		}
+
+		for j := uint64(0); j < running; j++ {
+			words = append(words, clean)
+		}
+
+		words = append(words, compressed[i:i+literal]...)
+		i += literal
+	}
+
+	if uint64(size) > uint64(len(words))*ewahWordBits {
+		return nil, ErrMalformedBitmap
+	}
+
+	bits := make([]bool, size)
+	for i := range bits {
+		bits[i] = words[i/ewahWordBits]&(1<<(i%ewahWordBits)) != 0
+	}
+
+	return bits, nil
+}
+
+// writeEWAH writes the bitmap compressed with the Enhanced Word-Aligned
+// Hybrid format, see readEWAH. As git does, the size of the bitmap is the
+// position of the last set bit plus one.
+func writeEWAH(w io.Writer, bits []bool) error {
+	size := len(bits)
+	for size > 0 && !bits[size-1] {
+		size--
+	}
+
+	words := make([]uint64, (size+ewahWordBits-1)/ewahWordBits)
+	for i := 0; i < size; i++ {
+		if bits[i] {
+			words[i/ewahWordBits] |= 1 << (i % ewahWordBits)
+		}
+	}
+
+	var compressed []uint64
+	var rlw int
+	for i := 0; i < len(words) || len(compressed) == 0; {
+		rlw = len(compressed)
+		compressed = append(compressed, 0)
+
+		var running, header uint64
+		if i < len(words) && (words[i] == ewahCleanZeros || words[i] == ewahCleanOnes) {
+			clean := words[i]
+			for i < len(words) && words[i] == clean && running < ewahMaxRunning {
+				running++
+				i++
+			}
+
+			if clean == ewahCleanOnes {
+				header = ewahRunningBitMask
+			}
+		}
+
+		var literal uint64
+		for i < len(words) && words[i] != ewahCleanZeros && words[i] != ewahCleanOnes &&
+			literal < ewahMaxLiteral {
+			compressed = append(compressed, words[i])
+			literal++
+			i++
+		}
+
+		compressed[rlw] = header | running<<1 | literal<<(1+ewahRunningBits)
+	}
+
+	if err := binary.Write(w, uint32(size), uint32(len(compressed))); err != nil {
+		return err
+	}
+
+	for _, word := range compressed {
+		if err := binary.WriteUint64(w, word); err != nil {
+			return err
+		}
+	}
+
+	return binary.WriteUint32(w, uint32(rlw))
+}
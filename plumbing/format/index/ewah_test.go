@@ -0,0 +1,69 @@
+package index
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *IndexSuite) TestEWAH(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := writeEWAH(buf, []bool{true, true, true, true, false})
+	c.Assert(err, IsNil)
+	c.Assert(buf.Bytes(), DeepEquals, []byte{
+		0, 0, 0, 4, // bits
+		0, 0, 0, 2, // words
+		0, 0, 0, 2, 0, 0, 0, 0, // RLW, 1 literal word
+		0, 0, 0, 0, 0, 0, 0, 0x0f,
+		0, 0, 0, 0, // last RLW
+	})
+
+	bits, err := readEWAH(buf)
+	c.Assert(err, IsNil)
+	c.Assert(bits, DeepEquals, []bool{true, true, true, true})
+}
+
+func (s *IndexSuite) TestEWAHRunningWords(c *C) {
+	expected := make([]bool, 64*3+1)
+	for i := 64; i < 128; i++ {
+		expected[i] = true
+	}
+
+	expected[64*3] = true
+
+	buf := bytes.NewBuffer(nil)
+	err := writeEWAH(buf, expected)
+	c.Assert(err, IsNil)
+
+	bits, err := readEWAH(buf)
+	c.Assert(err, IsNil)
+	c.Assert(bits, DeepEquals, expected)
+}
+
+func (s *IndexSuite) TestEWAHEmpty(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := writeEWAH(buf, []bool{false, false})
+	c.Assert(err, IsNil)
+	c.Assert(buf.Bytes(), DeepEquals, []byte{
+		0, 0, 0, 0,
+		0, 0, 0, 1,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0,
+	})
+
+	bits, err := readEWAH(buf)
+	c.Assert(err, IsNil)
+	c.Assert(bits, HasLen, 0)
+}
+
+func (s *IndexSuite) TestEWAHMalformed(c *C) {
+	buf := bytes.NewBuffer([]byte{
+		0, 0, 0, 65,
+		0, 0, 0, 1,
+		0, 0, 0, 0, 0, 0, 0, 2, // 1 running word
+		0, 0, 0, 0,
+	})
+
+	_, err := readEWAH(buf)
+	c.Assert(err, Equals, ErrMalformedBitmap)
+}
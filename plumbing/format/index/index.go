@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -23,6 +24,9 @@ var (
 	treeExtSignature            = []byte{'T', 'R', 'E', 'E'}
 	resolveUndoExtSignature     = []byte{'R', 'E', 'U', 'C'}
 	endOfIndexEntryExtSignature = []byte{'E', 'O', 'I', 'E'}
+	splitIndexExtSignature      = []byte{'l', 'i', 'n', 'k'}
+	untrackedCacheExtSignature  = []byte{'U', 'N', 'T', 'R'}
+	fsMonitorExtSignature       = []byte{'F', 'S', 'M', 'N'}
 )
 
 // Stage during merge
@@ -54,6 +58,12 @@ type Index struct {
 	ResolveUndo *ResolveUndo
 	// EndOfIndexEntry represents the 'End of Index Entry' extension
 	EndOfIndexEntry *EndOfIndexEntry
+	// SplitIndex represents the 'Split index' extension
+	SplitIndex *SplitIndex
+	// UntrackedCache represents the 'Untracked cache' extension
+	UntrackedCache *UntrackedCache
+	// FileSystemMonitor represents the 'File System Monitor cache' extension
+	FileSystemMonitor *FileSystemMonitor
 }
 
 // Add creates a new Entry and returns it. The caller should first check that
@@ -64,6 +74,8 @@ func (i *Index) Add(path string) *Entry {
 	}
 
 	i.Entries = append(i.Entries, e)
+	i.Cache.Invalidate(e.Name)
+	i.UntrackedCache.Invalidate(e.Name)
 	return e
 }
 
@@ -85,6 +97,8 @@ func (i *Index) Remove(path string) (*Entry, error) {
 	for index, e := range i.Entries {
 		if e.Name == path {
 			i.Entries = append(i.Entries[:index], i.Entries[index+1:]...)
+			i.Cache.Invalidate(path)
+			i.UntrackedCache.Invalidate(path)
 			return e, nil
 		}
 	}
@@ -149,6 +163,9 @@ type Entry struct {
 	// IntentToAdd record only the fact that the path will be added later
 	// https://git-scm.com/docs/git-add ("git add -N")
 	IntentToAdd bool
+	// FSMonitorValid is set when the file system monitor reported no changes
+	// of the file, it is stored in the 'File System Monitor cache' extension.
+	FSMonitorValid bool
 }
 
 func (e Entry) String() string {
@@ -170,12 +187,67 @@ type Tree struct {
 	Entries []TreeEntry
 }
 
-// TreeEntry entry of a cached Tree
+// Invalidate invalidates the trees containing the given path, after it is
+// added, removed or modified in the index. If the path is a cached tree, the
+// tree and its subtrees are removed, as git does.
+func (t *Tree) Invalidate(path string) {
+	if t == nil || len(t.Entries) == 0 {
+		return
+	}
+
+	components := strings.Split(filepath.ToSlash(path), "/")
+	pos := 0
+	for i, name := range components {
+		t.Entries[pos].Entries = -1
+		t.Entries[pos].Hash = plumbing.ZeroHash
+
+		sub := t.subtree(pos, name)
+		if sub < 0 {
+			return
+		}
+
+		if i == len(components)-1 {
+			t.Entries = append(t.Entries[:sub], t.Entries[t.next(sub):]...)
+			t.Entries[pos].Trees--
+			return
+		}
+
+		pos = sub
+	}
+}
+
+// subtree returns the position of the subtree with the given name of the
+// tree at pos, or -1 if there is none.
+func (t *Tree) subtree(pos int, name string) int {
+	sub := pos + 1
+	for n := 0; n < t.Entries[pos].Trees && sub < len(t.Entries); n++ {
+		if t.Entries[sub].Path == name {
+			return sub
+		}
+
+		sub = t.next(sub)
+	}
+
+	return -1
+}
+
+// next returns the position following the tree at pos and its subtrees.
+func (t *Tree) next(pos int) int {
+	next := pos + 1
+	for n := 0; n < t.Entries[pos].Trees && next < len(t.Entries); n++ {
+		next = t.next(next)
+	}
+
+	return next
+}
+
+// TreeEntry entry of a cached Tree. The entries of a Tree are stored depth
+// first, each entry followed by its subtrees.
 type TreeEntry struct {
 	// Path component (relative to its parent directory)
 	Path string
 	// Entries is the number of entries in the index that is covered by the tree
-	// this entry represents.
+	// this entry represents, or -1 if the tree is invalidated.
 	Entries int
 	// Trees is the number that represents the number of subtrees this tree has
 	Trees int
@@ -213,6 +285,275 @@ type EndOfIndexEntry struct {
 	Hash plumbing.Hash
 }
 
+// SplitIndex is used in split index mode, where most of the entries are
+// stored in a shared index file, $GIT_DIR/sharedindex.<hash>. The entries of
+// the index are written as the changes on top of the shared index.
+type SplitIndex struct {
+	// SharedIndex is the hash of the shared index file. If it is ZeroHash,
+	// the index does not require a shared index file.
+	SharedIndex plumbing.Hash
+	// Base is the shared index, the entries of the index are compared with
+	// its entries when the index is encoded. If nil, the whole index is
+	// written, without the extension.
+	Base *Index
+}
+
+type entryKey struct {
+	name  string
+	stage Stage
+}
+
+func newEntryKey(e *Entry) entryKey {
+	return entryKey{e.Name, e.Stage}
+}
+
+// merge replaces the entries of idx, read from a split index, with the
+// entries of the shared index, removing the deleted ones and replacing the
+// replaced ones with the first entries of idx, as git does.
+func (s *SplitIndex) merge(idx, shared *Index, deleted, replaced []bool) error {
+	entries := make([]*Entry, len(shared.Entries))
+	for i, e := range shared.Entries {
+		c := *e
+		entries[i] = &c
+	}
+
+	var n int
+	for pos, ok := range replaced {
+		if !ok {
+			continue
+		}
+
+		if pos >= len(entries) || n >= len(idx.Entries) || idx.Entries[n].Name != "" {
+			return ErrInvalidSplitIndex
+		}
+
+		e := idx.Entries[n]
+		e.Name = entries[pos].Name
+		entries[pos] = e
+		n++
+	}
+
+	var merged []*Entry
+	for pos, e := range entries {
+		if pos >= len(deleted) || !deleted[pos] {
+			merged = append(merged, e)
+		}
+	}
+
+	positions := make(map[entryKey]int, len(merged))
+	for pos, e := range merged {
+		positions[newEntryKey(e)] = pos
+	}
+
+	for _, e := range idx.Entries[n:] {
+		if e.Name == "" {
+			return ErrInvalidSplitIndex
+		}
+
+		if pos, ok := positions[newEntryKey(e)]; ok {
+			merged[pos] = e
+			continue
+		}
+
+		merged = append(merged, e)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Name != merged[j].Name {
+			return merged[i].Name < merged[j].Name
+		}
+
+		return merged[i].Stage < merged[j].Stage
+	})
+
+	idx.Entries = merged
+	s.Base = shared
+	return nil
+}
+
+// split returns the entries to be written in the split index, the replaced
+// entries of the shared index, without name, followed by the added entries,
+// and the deleted and replaced entries of the shared index.
+func (s *SplitIndex) split(entries []*Entry) (split []*Entry, deleted, replaced []bool) {
+	base := s.Base.Entries
+	positions := make(map[entryKey]int, len(base))
+	for pos, e := range base {
+		positions[newEntryKey(e)] = pos
+	}
+
+	found := make([]bool, len(base))
+	deleted = make([]bool, len(base))
+	replaced = make([]bool, len(base))
+	replacements := make([]*Entry, len(base))
+
+	var added []*Entry
+	for _, e := range entries {
+		pos, ok := positions[newEntryKey(e)]
+		if !ok {
+			added = append(added, e)
+			continue
+		}
+
+		found[pos] = true
+		if !e.equal(base[pos]) {
+			c := *e
+			c.Name = ""
+			replaced[pos] = true
+			replacements[pos] = &c
+		}
+	}
+
+	for pos := range base {
+		switch {
+		case !found[pos]:
+			deleted[pos] = true
+		case replaced[pos]:
+			split = append(split, replacements[pos])
+		}
+	}
+
+	return append(split, added...), deleted, replaced
+}
+
+// equal returns true if both entries are written in the same way.
+func (e *Entry) equal(o *Entry) bool {
+	return e.Hash == o.Hash &&
+		e.Name == o.Name &&
+		e.CreatedAt.Equal(o.CreatedAt) &&
+		e.ModifiedAt.Equal(o.ModifiedAt) &&
+		e.Dev == o.Dev &&
+		e.Inode == o.Inode &&
+		e.Mode == o.Mode &&
+		e.UID == o.UID &&
+		e.GID == o.GID &&
+		e.Size == o.Size &&
+		e.Stage == o.Stage &&
+		e.SkipWorktree == o.SkipWorktree &&
+		e.IntentToAdd == o.IntentToAdd
+}
+
+// UntrackedCache saves the untracked files of the directories of the
+// worktree, and the data needed to check if they are still valid, so the
+// directories that did not change don't need to be read again.
+type UntrackedCache struct {
+	// Environments where the cache can be used, usually the location of the
+	// worktree and the operating system.
+	Environments []string
+	// InfoExcludeStats is the stat data of $GIT_DIR/info/exclude.
+	InfoExcludeStats UntrackedCacheStats
+	// ExcludesFileStats is the stat data of the core.excludesFile file.
+	ExcludesFileStats UntrackedCacheStats
+	// DirFlags are the flags used to read the directories.
+	DirFlags uint32
+	// InfoExcludeHash is the hash of $GIT_DIR/info/exclude, ZeroHash if the
+	// file does not exist.
+	InfoExcludeHash plumbing.Hash
+	// ExcludesFileHash is the hash of the core.excludesFile file, ZeroHash
+	// if the file does not exist.
+	ExcludesFileHash plumbing.Hash
+	// ExcludePerDir is the name of the per directory exclude file, usually
+	// .gitignore.
+	ExcludePerDir string
+	// Root is the root directory of the worktree, nil if the cache is empty.
+	Root *UntrackedCacheDirectory
+}
+
+// UntrackedCacheShowOtherDirectories is the directory flag set when the
+// untracked directories are listed instead of their files.
+const UntrackedCacheShowOtherDirectories = 1 << 1
+
+// Invalidate invalidates the directory containing the given path, after the
+// path is added or removed from the index, and its parents if the untracked
+// directories are listed.
+func (u *UntrackedCache) Invalidate(path string) {
+	if u == nil || u.Root == nil {
+		return
+	}
+
+	components := strings.Split(filepath.ToSlash(path), "/")
+	dirs := []*UntrackedCacheDirectory{u.Root}
+	found := true
+	for _, name := range components[:len(components)-1] {
+		dir := dirs[len(dirs)-1].Directory(name)
+		if dir == nil {
+			found = false
+			break
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	if u.DirFlags&UntrackedCacheShowOtherDirectories == 0 {
+		if found {
+			dirs[len(dirs)-1].invalidate()
+		}
+
+		return
+	}
+
+	for _, dir := range dirs {
+		dir.invalidate()
+	}
+}
+
+// UntrackedCacheDirectory is a directory of the untracked cache.
+type UntrackedCacheDirectory struct {
+	// Name of the directory, relative to its parent.
+	Name string
+	// Untracked are the names of the untracked files and directories, the
+	// directories with a trailing slash.
+	Untracked []string
+	// Directories are the subdirectories with cached data.
+	Directories []*UntrackedCacheDirectory
+	// Valid is set if the untracked files of the directory are valid.
+	Valid bool
+	// CheckOnly is set if the directory was only read to check if it has
+	// untracked files, so Untracked may not have all of them.
+	CheckOnly bool
+	// Stats is the stat data of the directory, set if Valid.
+	Stats UntrackedCacheStats
+	// ExcludeHash is the hash of the per directory exclude file, ZeroHash if
+	// the file does not exist.
+	ExcludeHash plumbing.Hash
+}
+
+// Directory returns the subdirectory with the given name, if any.
+func (d *UntrackedCacheDirectory) Directory(name string) *UntrackedCacheDirectory {
+	for _, dir := range d.Directories {
+		if dir.Name == name {
+			return dir
+		}
+	}
+
+	return nil
+}
+
+func (d *UntrackedCacheDirectory) invalidate() {
+	d.Valid = false
+	d.CheckOnly = false
+	d.Untracked = nil
+}
+
+// UntrackedCacheStats is the stat data of a file or a directory in the
+// untracked cache.
+type UntrackedCacheStats struct {
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+	Dev, Inode uint32
+	UID, GID   uint32
+	Size       uint32
+}
+
+// FileSystemMonitor tracks the files for which the core.fsmonitor hook
+// reported changes. The entries not changed since the last update have
+// Entry.FSMonitorValid set.
+type FileSystemMonitor struct {
+	// Token of the last update, given by the hook. The version 1 of the
+	// extension stored a timestamp instead, which is kept as its decimal
+	// representation.
+	Token string
+}
+
 // SkipUnless applies patterns in the form of A, A/B, A/B/C
 // to the index to prevent the files from being checked out
 func (i *Index) SkipUnless(patterns []string) {
@@ -3,6 +3,8 @@ package index
 import (
 	"path/filepath"
 
+	"github.com/go-git/go-git/v5/plumbing"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -51,6 +53,71 @@ func (s *IndexSuite) TestIndexRemove(c *C) {
 	c.Assert(err, Equals, ErrEntryNotFound)
 }
 
+func (s *IndexSuite) TestIndexUntrackedCacheInvalidate(c *C) {
+	newCache := func(flags uint32) *UntrackedCache {
+		return &UntrackedCache{
+			DirFlags: flags,
+			Root: &UntrackedCacheDirectory{
+				Valid:     true,
+				Untracked: []string{"qux"},
+				Directories: []*UntrackedCacheDirectory{
+					{Name: "bar", Valid: true, Untracked: []string{"baz"}},
+				},
+			},
+		}
+	}
+
+	idx := &Index{UntrackedCache: newCache(0)}
+	idx.Add("bar/foo")
+	c.Assert(idx.UntrackedCache.Root.Valid, Equals, true)
+	c.Assert(idx.UntrackedCache.Root.Directories[0].Valid, Equals, false)
+	c.Assert(idx.UntrackedCache.Root.Directories[0].Untracked, HasLen, 0)
+
+	idx = &Index{UntrackedCache: newCache(UntrackedCacheShowOtherDirectories)}
+	idx.Add("bar/foo")
+	c.Assert(idx.UntrackedCache.Root.Valid, Equals, false)
+	c.Assert(idx.UntrackedCache.Root.Directories[0].Valid, Equals, false)
+
+	idx = &Index{UntrackedCache: newCache(0)}
+	idx.Add("foo")
+	_, err := idx.Remove("foo")
+	c.Assert(err, IsNil)
+	c.Assert(idx.UntrackedCache.Root.Valid, Equals, false)
+	c.Assert(idx.UntrackedCache.Root.Directories[0].Valid, Equals, true)
+}
+
+func (s *IndexSuite) TestIndexCacheInvalidate(c *C) {
+	hash := plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")
+	idx := &Index{
+		Cache: &Tree{Entries: []TreeEntry{
+			{Path: "", Entries: 4, Trees: 2, Hash: hash},
+			{Path: "bar", Entries: 2, Trees: 1, Hash: hash},
+			{Path: "baz", Entries: 1, Trees: 0, Hash: hash},
+			{Path: "qux", Entries: 1, Trees: 0, Hash: hash},
+		}},
+	}
+
+	idx.Add("bar/foo")
+	c.Assert(idx.Cache.Entries, DeepEquals, []TreeEntry{
+		{Path: "", Entries: -1, Trees: 2},
+		{Path: "bar", Entries: -1, Trees: 1},
+		{Path: "baz", Entries: 1, Trees: 0, Hash: hash},
+		{Path: "qux", Entries: 1, Trees: 0, Hash: hash},
+	})
+
+	// a file replacing a tree removes it
+	idx.Add("bar/baz")
+	c.Assert(idx.Cache.Entries, DeepEquals, []TreeEntry{
+		{Path: "", Entries: -1, Trees: 2},
+		{Path: "bar", Entries: -1, Trees: 0},
+		{Path: "qux", Entries: 1, Trees: 0, Hash: hash},
+	})
+
+	_, err := idx.Remove("bar/foo")
+	c.Assert(err, IsNil)
+	c.Assert(idx.Cache.Entries[2].Entries, Equals, 1)
+}
+
 func (s *IndexSuite) TestIndexGlob(c *C) {
 	idx := &Index{
 		Entries: []*Entry{
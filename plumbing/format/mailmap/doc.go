@@ -0,0 +1,28 @@
+// Package mailmap implements the parsing and matching of mailmap files,
+// mapping the names and emails of the authors and committers of commits to
+// their canonical identities.
+//
+//	== .mailmap files have the format:
+//
+//	  - A line starting with '#' is a comment, and so is the text after the
+//	    email addresses of a line.
+//
+//	  - Proper Name <commit@email.xx>
+//	    replaces the name of the commits with the given email.
+//
+//	  - <proper@email.xx> <commit@email.xx>
+//	    replaces the email of the commits with the given email.
+//
+//	  - Proper Name <proper@email.xx> <commit@email.xx>
+//	    replaces the name and the email of the commits with the given email.
+//
+//	  - Proper Name <proper@email.xx> Commit Name <commit@email.xx>
+//	    replaces the name and the email of the commits with the given name
+//	    and email.
+//
+// The names and emails are matched case-insensitively, and the later lines
+// override the former ones.
+//
+// Source:
+// https://git-scm.com/docs/gitmailmap
+package mailmap
@@ -0,0 +1,152 @@
+package mailmap
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Mailmap maps the identities of the authors and committers of commits to
+// their canonical identities. A nil Mailmap maps every identity to itself.
+type Mailmap struct {
+	// entries are the entries by lowercased commit email.
+	entries map[string]*entry
+}
+
+// entry is the mapping of a commit email, to the identity given by the
+// lines without a commit name, and to the identities given by the lines
+// with a commit name, by lowercased commit name.
+type entry struct {
+	identity
+	names map[string]*identity
+}
+
+// identity is a proper name and email, empty if not replaced.
+type identity struct {
+	name, email string
+}
+
+// New returns an empty Mailmap.
+func New() *Mailmap {
+	return &Mailmap{entries: make(map[string]*entry)}
+}
+
+// Parse reads the lines of a mailmap file and returns the resulting Mailmap.
+func Parse(r io.Reader) (*Mailmap, error) {
+	m := New()
+	if err := m.Read(r); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Read reads the lines of a mailmap file, adding them to the Mailmap. Its
+// lines override the lines read before. The lines with an invalid format
+// are skipped, as git does.
+func (m *Mailmap) Read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		m.readLine(s.Text())
+	}
+
+	return s.Err()
+}
+
+func (m *Mailmap) readLine(line string) {
+	if strings.HasPrefix(line, "#") {
+		return
+	}
+
+	properName, properEmail, rest, ok := parseNameAndEmail(line, false)
+	if !ok {
+		return
+	}
+
+	commitName, commitEmail, _, ok := parseNameAndEmail(rest, true)
+	if !ok {
+		commitName, commitEmail = "", properEmail
+		properEmail = ""
+	}
+
+	m.Add(properName, properEmail, commitName, commitEmail)
+}
+
+// parseNameAndEmail parses a name followed by an email between angle
+// brackets, the name being optional, and returns the text after them.
+func parseNameAndEmail(s string, allowEmptyEmail bool) (name, email, rest string, ok bool) {
+	left := strings.IndexByte(s, '<')
+	if left < 0 {
+		return "", "", "", false
+	}
+
+	right := strings.IndexByte(s[left+1:], '>')
+	if right < 0 || (right == 0 && !allowEmptyEmail) {
+		return "", "", "", false
+	}
+
+	right += left + 1
+	return strings.Trim(s[:left], " \t\r\n"), s[left+1 : right], s[right+1:], true
+}
+
+// Add adds the mapping of the identities with the given commit email, and
+// also with the given commit name unless empty, to the given proper name
+// and email. An empty proper name or email is not replaced.
+func (m *Mailmap) Add(properName, properEmail, commitName, commitEmail string) {
+	e, ok := m.entries[strings.ToLower(commitEmail)]
+	if !ok {
+		e = &entry{names: make(map[string]*identity)}
+		m.entries[strings.ToLower(commitEmail)] = e
+	}
+
+	if commitName == "" {
+		if properName != "" {
+			e.name = properName
+		}
+
+		if properEmail != "" {
+			e.email = properEmail
+		}
+
+		return
+	}
+
+	e.names[strings.ToLower(commitName)] = &identity{name: properName, email: properEmail}
+}
+
+// Map returns the canonical name and email of the given identity.
+func (m *Mailmap) Map(name, email string) (string, string) {
+	if m == nil {
+		return name, email
+	}
+
+	e, ok := m.entries[strings.ToLower(email)]
+	if !ok {
+		return name, email
+	}
+
+	// the lines with a commit name are preferred to the ones without
+	id := &e.identity
+	if named, ok := e.names[strings.ToLower(name)]; ok {
+		id = named
+	}
+
+	if id.name != "" {
+		name = id.name
+	}
+
+	if id.email != "" {
+		email = id.email
+	}
+
+	return name, email
+}
+
+// Signature returns the signature with the canonical name and email of its
+// identity.
+func (m *Mailmap) Signature(s object.Signature) object.Signature {
+	s.Name, s.Email = m.Map(s.Name, s.Email)
+	return s
+}
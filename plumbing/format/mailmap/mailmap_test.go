@@ -0,0 +1,79 @@
+package mailmap
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MailmapSuite struct{}
+
+var _ = Suite(&MailmapSuite{})
+
+const mailmapFile = `# comment
+Jane Doe <jane@example.com>
+<john@example.com> <jdoe@old.example.com>
+Joe Bloggs <joe@example.com> <JOE@laptop>
+Rob Pike <rob@example.com> robert <rob@home>
+Rob P <rob@work.example.com> ROB <rob@home>
+ Other   <other@example.com> # trailing comment
+Jane Doe <jane@example.com> <>
+Bad line <no-close
+Jane Roe <jane@example.com>
+`
+
+func (s *MailmapSuite) TestMap(c *C) {
+	m, err := Parse(strings.NewReader(mailmapFile))
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		name, email        string
+		mappedName, mapped string
+	}{
+		{"jane", "JANE@example.com", "Jane Roe", "JANE@example.com"},
+		{"John", "jdoe@old.example.com", "John", "john@example.com"},
+		{"joe", "joe@laptop", "Joe Bloggs", "joe@example.com"},
+		{"Robert", "rob@home", "Rob Pike", "rob@example.com"},
+		{"rob", "rob@home", "Rob P", "rob@work.example.com"},
+		{"Nobody", "rob@home", "Nobody", "rob@home"},
+		{"x", "other@example.com", "Other", "other@example.com"},
+		{"Anon", "", "Jane Doe", "jane@example.com"},
+		{"Bad", "no-close", "Bad", "no-close"},
+		{"keep", "keep@example.com", "keep", "keep@example.com"},
+	} {
+		name, email := m.Map(t.name, t.email)
+		c.Assert(name, Equals, t.mappedName, Commentf("%s <%s>", t.name, t.email))
+		c.Assert(email, Equals, t.mapped, Commentf("%s <%s>", t.name, t.email))
+	}
+}
+
+func (s *MailmapSuite) TestRead(c *C) {
+	m := New()
+	m.Add("Jane Doe", "", "", "jane@example.com")
+	c.Assert(m.Read(strings.NewReader("<jane@example.org> <jane@example.com>\n")), IsNil)
+
+	name, email := m.Map("jane", "jane@example.com")
+	c.Assert(name, Equals, "Jane Doe")
+	c.Assert(email, Equals, "jane@example.org")
+}
+
+func (s *MailmapSuite) TestSignature(c *C) {
+	m, err := Parse(strings.NewReader(mailmapFile))
+	c.Assert(err, IsNil)
+
+	when := time.Unix(1700000000, 0)
+	sig := object.Signature{Name: "joe", Email: "joe@laptop", When: when}
+	c.Assert(m.Signature(sig), Equals, object.Signature{
+		Name: "Joe Bloggs", Email: "joe@example.com", When: when,
+	})
+	c.Assert(sig.Name, Equals, "joe")
+
+	var nilMailmap *Mailmap
+	c.Assert(nilMailmap.Signature(sig), Equals, sig)
+}
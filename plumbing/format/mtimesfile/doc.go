@@ -0,0 +1,27 @@
+// Package mtimesfile implements encoding and decoding of packfile mtimes
+// (.mtimes) files.
+//
+// An mtimes file stores the modification time of each object of a cruft
+// pack, so unreachable objects can be expired individually without being
+// exploded into loose objects first.
+//
+//  == pack-*.mtimes files have the format:
+//
+//    - A 4-byte magic number '0x4d544d45' ('MTME').
+//
+//    - A 4-byte version identifier (= 1).
+//
+//    - A 4-byte hash function identifier (= 1 for SHA-1, 2 for SHA-256).
+//
+//    - A table of 4-byte unsigned integers in network order. The ith value
+//      is the modification time (mtime) of the ith object in the
+//      corresponding pack by lexicographic (index) order. The mtimes count
+//      standard epoch seconds.
+//
+//    - A trailer, containing a checksum of the corresponding packfile, and
+//      a checksum of all of the above (each having length according to the
+//      specified hash function).
+//
+// Source:
+// https://git-scm.com/docs/gitformat-pack#_pack_mtimes_files_have_the_format
+package mtimesfile
@@ -0,0 +1,78 @@
+package mtimesfile
+
+import (
+	"io"
+
+	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/go-git/go-git/v5/utils/binary"
+)
+
+// Encoder writes Mtimes structs to an output stream.
+type Encoder struct {
+	io.Writer
+	hash hash.Hash
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	h := hash.New(hash.CryptoType)
+	mw := io.MultiWriter(w, h)
+	return &Encoder{mw, h}
+}
+
+// Encode encodes an Mtimes to the encoder writer.
+func (e *Encoder) Encode(m *Mtimes) (int, error) {
+	flow := []func(*Mtimes) (int, error){
+		e.encodeHeader,
+		e.encodeTimes,
+		e.encodeChecksums,
+	}
+
+	sz := 0
+	for _, f := range flow {
+		i, err := f(m)
+		sz += i
+
+		if err != nil {
+			return sz, err
+		}
+	}
+
+	return sz, nil
+}
+
+func (e *Encoder) encodeHeader(m *Mtimes) (int, error) {
+	c, err := e.Write(mtimesHeader)
+	if err != nil {
+		return c, err
+	}
+
+	if err := binary.WriteUint32(e, m.Version); err != nil {
+		return c, err
+	}
+
+	return c + 8, binary.WriteUint32(e, m.HashID)
+}
+
+func (e *Encoder) encodeTimes(m *Mtimes) (int, error) {
+	for i, t := range m.Times {
+		if err := binary.WriteUint32(e, t); err != nil {
+			return i * 4, err
+		}
+	}
+
+	return len(m.Times) * 4, nil
+}
+
+func (e *Encoder) encodeChecksums(m *Mtimes) (int, error) {
+	if _, err := e.Write(m.PackfileChecksum[:]); err != nil {
+		return 0, err
+	}
+
+	copy(m.MtimesChecksum[:], e.hash.Sum(nil)[:hash.Size])
+	if _, err := e.Write(m.MtimesChecksum[:]); err != nil {
+		return hash.Size, err
+	}
+
+	return 2 * hash.Size, nil
+}
@@ -0,0 +1,102 @@
+package mtimesfile
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+)
+
+const (
+	// VersionSupported is the only mtimes version supported.
+	VersionSupported = 1
+)
+
+var (
+	mtimesHeader = []byte{'M', 'T', 'M', 'E'}
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Decode when the mtimes file
+	// version is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrUnsupportedHash is returned by Decode when the mtimes file was
+	// written with a hash function different from the one in use.
+	ErrUnsupportedHash = errors.New("unsupported hash function")
+	// ErrMalformedMtimesFile is returned by Decode when the mtimes file is
+	// corrupted.
+	ErrMalformedMtimesFile = errors.New("malformed MTIMES file")
+)
+
+// Mtimes is the in memory representation of an mtimes file.
+type Mtimes struct {
+	Version uint32
+	HashID  uint32
+	// Times holds the modification time, in seconds since the epoch, of
+	// every object in the packfile, in the order of the idx file.
+	Times []uint32
+	// PackfileChecksum is the checksum of the packfile the mtimes refer to.
+	PackfileChecksum [hash.Size]byte
+	MtimesChecksum   [hash.Size]byte
+}
+
+// New builds the Mtimes for the objects in the given idx file, calling
+// mtime to retrieve the modification time of each of them.
+func New(idx *idxfile.MemoryIndex, mtime func(plumbing.Hash) time.Time) (*Mtimes, error) {
+	count, err := idx.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+
+	m := &Mtimes{
+		Version:          VersionSupported,
+		HashID:           hash.FormatID,
+		Times:            make([]uint32, 0, count),
+		PackfileChecksum: idx.PackfileChecksum,
+	}
+
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.Times = append(m.Times, uint32(mtime(e.Hash).Unix()))
+	}
+
+	return m, nil
+}
+
+// Count returns the number of entries in the mtimes file.
+func (m *Mtimes) Count() int64 {
+	return int64(len(m.Times))
+}
+
+// FindMtime returns the modification time of the object with the given
+// hash, using idx to find its position.
+func (m *Mtimes) FindMtime(idx *idxfile.MemoryIndex, h plumbing.Hash) (time.Time, error) {
+	pos, err := idx.FindPosition(h)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if pos >= int64(len(m.Times)) {
+		return time.Time{}, ErrMalformedMtimesFile
+	}
+
+	return time.Unix(int64(m.Times[pos]), 0), nil
+}
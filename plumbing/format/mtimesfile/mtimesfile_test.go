@@ -0,0 +1,69 @@
+package mtimesfile_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	. "github.com/go-git/go-git/v5/plumbing/format/mtimesfile"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MtimesfileSuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&MtimesfileSuite{})
+
+func (s *MtimesfileSuite) TestNewAndFind(c *C) {
+	idx := idxfile.NewMemoryIndex()
+	err := idxfile.NewDecoder(fixtures.Basic().One().Idx()).Decode(idx)
+	c.Assert(err, IsNil)
+
+	base := time.Unix(1700000000, 0)
+	mtime := func(h plumbing.Hash) time.Time {
+		return base.Add(time.Duration(h[0]) * time.Second)
+	}
+
+	m, err := New(idx, mtime)
+	c.Assert(err, IsNil)
+	c.Assert(m.Count(), Equals, int64(31))
+
+	h := plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea")
+	t, err := m.FindMtime(idx, h)
+	c.Assert(err, IsNil)
+	c.Assert(t.Equal(mtime(h)), Equals, true)
+
+	_, err = m.FindMtime(idx, plumbing.ZeroHash)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	buf := bytes.NewBuffer(nil)
+	size, err := NewEncoder(buf).Encode(m)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, 12+4*31+40)
+
+	decoded := new(Mtimes)
+	err = NewDecoder(buf).Decode(decoded)
+	c.Assert(err, IsNil)
+	c.Assert(decoded, DeepEquals, m)
+}
+
+func (s *MtimesfileSuite) TestDecodeBadChecksum(c *C) {
+	m := &Mtimes{Version: VersionSupported, HashID: 1, Times: []uint32{1, 2, 3}}
+
+	buf := bytes.NewBuffer(nil)
+	_, err := NewEncoder(buf).Encode(m)
+	c.Assert(err, IsNil)
+
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	err = NewDecoder(bytes.NewReader(data)).Decode(new(Mtimes))
+	c.Assert(err, Equals, ErrMalformedMtimesFile)
+}
@@ -0,0 +1,346 @@
+package reftable
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"sort"
+)
+
+// Block types.
+const (
+	blockTypeRef   byte = 'r'
+	blockTypeLog   byte = 'g'
+	blockTypeObj   byte = 'o'
+	blockTypeIndex byte = 'i'
+)
+
+const (
+	blockHeaderSize = 4
+	// defaultRestartInterval is the number of records between restart
+	// points, which are stored without prefix compression.
+	defaultRestartInterval = 16
+	maxBlockSize           = 1<<24 - 1
+)
+
+// blockWriter writes the records of a single block. The first block of a
+// table also holds the file header, which is accounted in its length.
+type blockWriter struct {
+	typ             byte
+	buf             []byte
+	headerOff       int
+	blockSize       int
+	restartInterval int
+	minUpdateIndex  uint64
+
+	restarts []uint32
+	lastKey  []byte
+	entries  int
+}
+
+func newBlockWriter(typ byte, header []byte, blockSize, restartInterval int, minUpdateIndex uint64) *blockWriter {
+	buf := make([]byte, len(header)+blockHeaderSize, blockSize)
+	copy(buf, header)
+	buf[len(header)] = typ
+
+	return &blockWriter{
+		typ:             typ,
+		buf:             buf,
+		headerOff:       len(header),
+		blockSize:       blockSize,
+		restartInterval: restartInterval,
+		minUpdateIndex:  minUpdateIndex,
+	}
+}
+
+// add appends the record to the block, returning false if it does not fit.
+func (w *blockWriter) add(r record) bool {
+	key := r.key()
+	restart := w.entries%w.restartInterval == 0
+
+	prefix := 0
+	if !restart {
+		prefix = commonPrefix(w.lastKey, key)
+	}
+
+	var typ byte
+	switch rec := r.(type) {
+	case *RefRecord:
+		typ = rec.ValueType
+	case *LogRecord:
+		typ = rec.ValueType
+	}
+
+	rec := putVarint(nil, uint64(prefix))
+	rec = putVarint(rec, uint64(len(key)-prefix)<<3|uint64(typ))
+	rec = append(rec, key[prefix:]...)
+	rec = append(rec, r.encode(w.minUpdateIndex)...)
+
+	restarts := len(w.restarts)
+	if restart {
+		restarts++
+	}
+
+	if len(w.buf)+len(rec)+3*restarts+2 > w.blockSize {
+		return false
+	}
+
+	if restart {
+		w.restarts = append(w.restarts, uint32(len(w.buf)))
+	}
+
+	w.buf = append(w.buf, rec...)
+	w.lastKey = append(w.lastKey[:0], key...)
+	w.entries++
+
+	return true
+}
+
+// finish returns the encoded block. Log blocks are compressed, all other
+// blocks are padded up to the block size when pad is set.
+func (w *blockWriter) finish(pad bool) ([]byte, error) {
+	for _, r := range w.restarts {
+		w.buf = append(w.buf, byte(r>>16), byte(r>>8), byte(r))
+	}
+
+	w.buf = append(w.buf, byte(len(w.restarts)>>8), byte(len(w.restarts)))
+	putUint24(w.buf[w.headerOff+1:], uint32(len(w.buf)))
+
+	if w.typ == blockTypeLog {
+		skip := w.headerOff + blockHeaderSize
+		out := bytes.NewBuffer(make([]byte, 0, len(w.buf)))
+		out.Write(w.buf[:skip])
+
+		zw := zlib.NewWriter(out)
+		if _, err := zw.Write(w.buf[skip:]); err != nil {
+			return nil, err
+		}
+
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		return out.Bytes(), nil
+	}
+
+	if pad {
+		w.buf = w.buf[:w.blockSize]
+	}
+
+	return w.buf, nil
+}
+
+// blockReader reads the records of a single, uncompressed, block.
+type blockReader struct {
+	typ            byte
+	data           []byte
+	headerOff      int
+	restarts       []uint32
+	recordsEnd     int
+	minUpdateIndex uint64
+}
+
+// readBlock reads the block at the given offset of the table data. It
+// returns the block and the offset of the next one.
+func readBlock(data []byte, off, headerOff, blockSize int, end int, minUpdateIndex uint64) (*blockReader, int, error) {
+	start := off + headerOff
+	if start+blockHeaderSize > end {
+		return nil, 0, ErrMalformedTable
+	}
+
+	typ := data[start]
+	blockLen := int(getUint24(data[start+1:]))
+	if blockLen < headerOff+blockHeaderSize {
+		return nil, 0, ErrMalformedTable
+	}
+
+	var block []byte
+	var next int
+	if typ == blockTypeLog {
+		r := bytes.NewReader(data[start+blockHeaderSize : end])
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, 0, ErrMalformedTable
+		}
+
+		block = make([]byte, blockLen)
+		copy(block, data[off:start+blockHeaderSize])
+		if _, err := io.ReadFull(zr, block[headerOff+blockHeaderSize:]); err != nil {
+			return nil, 0, ErrMalformedTable
+		}
+
+		// Read the checksum, so the compressed data is fully consumed.
+		if _, err := zr.Read(make([]byte, 1)); err != io.EOF {
+			return nil, 0, ErrMalformedTable
+		}
+
+		next = end - r.Len()
+	} else {
+		if off+blockLen > end {
+			return nil, 0, ErrMalformedTable
+		}
+
+		block = data[off : off+blockLen]
+
+		// Blocks are padded with zeros up to the block size, unless the
+		// writer chose to write unaligned blocks.
+		next = off + blockSize
+		if blockSize == 0 || blockLen >= blockSize ||
+			(off+blockLen < end && data[off+blockLen] != 0) {
+			next = off + blockLen
+		}
+	}
+
+	br, err := newBlockReader(typ, block, headerOff, minUpdateIndex)
+	return br, next, err
+}
+
+func newBlockReader(typ byte, block []byte, headerOff int, minUpdateIndex uint64) (*blockReader, error) {
+	if len(block) < headerOff+blockHeaderSize+2 {
+		return nil, ErrMalformedTable
+	}
+
+	count := int(block[len(block)-2])<<8 | int(block[len(block)-1])
+	recordsEnd := len(block) - 2 - 3*count
+	if recordsEnd < headerOff+blockHeaderSize {
+		return nil, ErrMalformedTable
+	}
+
+	restarts := make([]uint32, count)
+	for i := range restarts {
+		restarts[i] = getUint24(block[recordsEnd+3*i:])
+		if int(restarts[i]) >= recordsEnd {
+			return nil, ErrMalformedTable
+		}
+	}
+
+	return &blockReader{
+		typ:            typ,
+		data:           block,
+		headerOff:      headerOff,
+		restarts:       restarts,
+		recordsEnd:     recordsEnd,
+		minUpdateIndex: minUpdateIndex,
+	}, nil
+}
+
+// firstKey returns the key of the first record of the block.
+func (b *blockReader) firstKey() ([]byte, error) {
+	it := b.iter()
+	key, _, _, err := it.nextKey()
+	return key, err
+}
+
+// seek returns an iterator positioned at the last restart point whose key is
+// not greater than key, so the record with the given key, if any, is found
+// by iterating from there.
+func (b *blockReader) seek(key []byte) (*blockIter, error) {
+	var err error
+	i := sort.Search(len(b.restarts), func(i int) bool {
+		if err != nil {
+			return false
+		}
+
+		it := &blockIter{b: b, off: int(b.restarts[i])}
+		k, _, _, e := it.nextKey()
+		if e != nil {
+			err = e
+			return false
+		}
+
+		return bytes.Compare(k, key) > 0
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	it := b.iter()
+	if i > 0 {
+		it.off = int(b.restarts[i-1])
+	}
+
+	return it, nil
+}
+
+func (b *blockReader) iter() *blockIter {
+	return &blockIter{b: b, off: b.headerOff + blockHeaderSize}
+}
+
+// blockIter iterates over the records of a block.
+type blockIter struct {
+	b       *blockReader
+	off     int
+	lastKey []byte
+}
+
+// nextKey decodes the key of the next record, returning its value type and
+// the remaining data, or io.EOF at the end of the block.
+func (it *blockIter) nextKey() ([]byte, byte, []byte, error) {
+	if it.off >= it.b.recordsEnd {
+		return nil, 0, nil, io.EOF
+	}
+
+	in := it.b.data[it.off:it.b.recordsEnd]
+	prefix, n := getVarint(in)
+	if n <= 0 {
+		return nil, 0, nil, ErrMalformedTable
+	}
+
+	suffixAndType, m := getVarint(in[n:])
+	if m <= 0 {
+		return nil, 0, nil, ErrMalformedTable
+	}
+
+	n += m
+	suffix := suffixAndType >> 3
+	if prefix > uint64(len(it.lastKey)) || suffix > uint64(len(in)-n) {
+		return nil, 0, nil, ErrMalformedTable
+	}
+
+	key := make([]byte, 0, int(prefix+suffix))
+	key = append(key, it.lastKey[:prefix]...)
+	key = append(key, in[n:n+int(suffix)]...)
+	n += int(suffix)
+
+	it.lastKey = key
+	it.off += n
+
+	return key, byte(suffixAndType & 0x7), in[n:], nil
+}
+
+// next decodes the next record into r, returning io.EOF at the end of the
+// block.
+func (it *blockIter) next(r record) error {
+	key, typ, in, err := it.nextKey()
+	if err != nil {
+		return err
+	}
+
+	n, err := r.decode(key, typ, in, it.b.minUpdateIndex)
+	if err != nil {
+		return err
+	}
+
+	it.off += n
+	return nil
+}
+
+func commonPrefix(a, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v >> 16)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v)
+}
+
+func getUint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
@@ -0,0 +1,38 @@
+// Package reftable implements encoding and decoding of reftable files, and
+// the stack of tables used by git to store references when the
+// extensions.refStorage configuration is set to reftable.
+//
+// A reftable is an immutable, sorted, file of reference and reflog records.
+// References are updated by appending a new table to the stack, listed in
+// the tables.list file, where the records of newer tables shadow the older
+// ones. Tables are periodically merged together, keeping the stack
+// geometrically sized.
+//
+//  == reftable files have the format:
+//
+//    - A header, made of:
+//
+//      4-byte magic number 'REFT'.
+//
+//      1-byte version number (= 1 for SHA-1, 2 for any hash function).
+//
+//      3-byte block size.
+//
+//      8-byte minimum and maximum update indexes of the records.
+//
+//      4-byte hash function identifier, only for version 2.
+//
+//    - A ref section, made of ref blocks, where the first block also holds
+//      the header. Each block contains prefix compressed records followed
+//      by the offsets of the restart points, used for binary searches.
+//
+//    - Optional object and index sections, used to speed up lookups.
+//
+//    - A log section, made of zlib compressed log blocks.
+//
+//    - A footer, containing a copy of the header, the offsets of each
+//      section and a CRC-32 checksum of all of the above.
+//
+// Source:
+// https://git-scm.com/docs/reftable
+package reftable
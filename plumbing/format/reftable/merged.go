@@ -0,0 +1,132 @@
+package reftable
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Merged is a read-only view over a list of tables, where the records of
+// the newer tables shadow the ones of the older tables.
+type Merged struct {
+	// tables are sorted from the oldest to the newest.
+	tables []*Reader
+}
+
+// NewMerged returns a view over the given tables, sorted from the oldest to
+// the newest.
+func NewMerged(tables []*Reader) *Merged {
+	return &Merged{tables: tables}
+}
+
+// MaxUpdateIndex returns the maximum update index of the tables, or zero if
+// there are no tables.
+func (m *Merged) MaxUpdateIndex() uint64 {
+	if len(m.tables) == 0 {
+		return 0
+	}
+
+	return m.tables[len(m.tables)-1].MaxUpdateIndex()
+}
+
+// Ref returns the ref record of the given reference, or
+// plumbing.ErrReferenceNotFound if it does not exist or it was deleted.
+func (m *Merged) Ref(name string) (*RefRecord, error) {
+	for i := len(m.tables) - 1; i >= 0; i-- {
+		rec, err := m.tables[i].Ref(name)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rec.IsDeletion() {
+			break
+		}
+
+		return rec, nil
+	}
+
+	return nil, plumbing.ErrReferenceNotFound
+}
+
+// Refs returns the existing references, sorted by name.
+func (m *Merged) Refs() ([]*RefRecord, error) {
+	return m.refs(false)
+}
+
+func (m *Merged) refs(keepDeletions bool) ([]*RefRecord, error) {
+	byName := make(map[string]*RefRecord)
+	for _, t := range m.tables {
+		refs, err := t.Refs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range refs {
+			byName[r.RefName] = r
+		}
+	}
+
+	refs := make([]*RefRecord, 0, len(byName))
+	for _, r := range byName {
+		if r.IsDeletion() && !keepDeletions {
+			continue
+		}
+
+		refs = append(refs, r)
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].RefName < refs[j].RefName })
+	return refs, nil
+}
+
+// Logs returns the log records of the given reference, from the most recent
+// update to the oldest. All the log records are returned if name is empty.
+func (m *Merged) Logs(name string) ([]*LogRecord, error) {
+	logs, err := m.logs(false)
+	if err != nil || name == "" {
+		return logs, err
+	}
+
+	var filtered []*LogRecord
+	for _, l := range logs {
+		if l.RefName == name {
+			filtered = append(filtered, l)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (m *Merged) logs(keepDeletions bool) ([]*LogRecord, error) {
+	byKey := make(map[string]*LogRecord)
+	for _, t := range m.tables {
+		logs, err := t.Logs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range logs {
+			byKey[string(l.key())] = l
+		}
+	}
+
+	logs := make([]*LogRecord, 0, len(byKey))
+	for _, l := range byKey {
+		if l.IsDeletion() && !keepDeletions {
+			continue
+		}
+
+		logs = append(logs, l)
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		return bytes.Compare(logs[i].key(), logs[j].key()) < 0
+	})
+
+	return logs, nil
+}
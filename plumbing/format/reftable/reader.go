@@ -0,0 +1,221 @@
+package reftable
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Reader reads the records of a single reftable, held in memory.
+type Reader struct {
+	data   []byte
+	header *header
+	footer *footer
+
+	refBlocks []*blockReader
+	refKeys   [][]byte
+
+	logStart int
+	logEnd   int
+}
+
+// NewReader returns a new Reader for the given table data.
+func NewReader(data []byte) (*Reader, error) {
+	h, err := decodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := decodeFooter(data, h)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reader{
+		data:     data,
+		header:   h,
+		footer:   f,
+		logStart: -1,
+	}
+
+	end := len(data) - h.size() - footerSize
+	if end < h.size() {
+		return nil, ErrMalformedTable
+	}
+
+	if end == h.size() {
+		return r, nil
+	}
+
+	first := data[h.size()]
+	if first == blockTypeRef {
+		if err := r.readRefBlocks(r.sectionEnd(end, f.refIndexPos, f.objPos, f.objIndexPos, f.logPos)); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.logPos > 0 || first == blockTypeLog {
+		r.logStart = int(f.logPos)
+		r.logEnd = r.sectionEnd(end, f.logIndexPos)
+		if r.logStart >= r.logEnd {
+			return nil, ErrMalformedTable
+		}
+	}
+
+	return r, nil
+}
+
+// sectionEnd returns the lowest non-zero position, or end.
+func (r *Reader) sectionEnd(end int, positions ...uint64) int {
+	for _, p := range positions {
+		if p > 0 && int(p) < end {
+			end = int(p)
+		}
+	}
+
+	return end
+}
+
+func (r *Reader) readRefBlocks(end int) error {
+	headerOff := r.header.size()
+	for off := 0; off < end; headerOff = 0 {
+		b, next, err := readBlock(r.data, off, headerOff, int(r.header.blockSize), end, r.header.minUpdateIndex)
+		if err != nil {
+			return err
+		}
+
+		if b.typ != blockTypeRef {
+			break
+		}
+
+		key, err := b.firstKey()
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		r.refBlocks = append(r.refBlocks, b)
+		r.refKeys = append(r.refKeys, key)
+		off = next
+	}
+
+	return nil
+}
+
+// MinUpdateIndex returns the minimum update index of the table records.
+func (r *Reader) MinUpdateIndex() uint64 {
+	return r.header.minUpdateIndex
+}
+
+// MaxUpdateIndex returns the maximum update index of the table records.
+func (r *Reader) MaxUpdateIndex() uint64 {
+	return r.header.maxUpdateIndex
+}
+
+// Ref returns the ref record of the given reference, which may be a
+// deletion, or plumbing.ErrReferenceNotFound if the table has no record for
+// it.
+func (r *Reader) Ref(name string) (*RefRecord, error) {
+	key := []byte(name)
+	i := sort.Search(len(r.refKeys), func(i int) bool {
+		return bytes.Compare(r.refKeys[i], key) > 0
+	}) - 1
+
+	if i < 0 {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+
+	it, err := r.refBlocks[i].seek(key)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		rec := &RefRecord{}
+		err := it.next(rec)
+		if err == io.EOF {
+			return nil, plumbing.ErrReferenceNotFound
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch c := bytes.Compare([]byte(rec.RefName), key); {
+		case c == 0:
+			return rec, nil
+		case c > 0:
+			return nil, plumbing.ErrReferenceNotFound
+		}
+	}
+}
+
+// Refs returns all the ref records of the table, including deletions,
+// sorted by name.
+func (r *Reader) Refs() ([]*RefRecord, error) {
+	var refs []*RefRecord
+	for _, b := range r.refBlocks {
+		it := b.iter()
+		for {
+			rec := &RefRecord{}
+			err := it.next(rec)
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			refs = append(refs, rec)
+		}
+	}
+
+	return refs, nil
+}
+
+// Logs returns all the log records of the table, including deletions,
+// sorted by name and, for each reference, from the most recent update to
+// the oldest.
+func (r *Reader) Logs() ([]*LogRecord, error) {
+	if r.logStart < 0 {
+		return nil, nil
+	}
+
+	headerOff := 0
+	if r.logStart == 0 {
+		headerOff = r.header.size()
+	}
+
+	var logs []*LogRecord
+	for off := r.logStart; off < r.logEnd; headerOff = 0 {
+		b, next, err := readBlock(r.data, off, headerOff, int(r.header.blockSize), r.logEnd, r.header.minUpdateIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.typ != blockTypeLog {
+			break
+		}
+
+		it := b.iter()
+		for {
+			rec := &LogRecord{}
+			err := it.next(rec)
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			logs = append(logs, rec)
+		}
+
+		off = next
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,268 @@
+package reftable
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+)
+
+// Value types of the ref records.
+const (
+	// RefDeletion is a tombstone, hiding the reference in older tables.
+	RefDeletion byte = 0x0
+	// RefVal1 is a reference pointing to a single object.
+	RefVal1 byte = 0x1
+	// RefVal2 is a reference pointing to an annotated tag, along with the
+	// object the tag peels to.
+	RefVal2 byte = 0x2
+	// RefSymref is a symbolic reference.
+	RefSymref byte = 0x3
+)
+
+// Value types of the log records.
+const (
+	// LogDeletion is a tombstone, hiding the log entry in older tables.
+	LogDeletion byte = 0x0
+	// LogUpdate is a log entry for an update of a reference.
+	LogUpdate byte = 0x1
+)
+
+// RefRecord is a reference stored in a reftable.
+type RefRecord struct {
+	RefName     string
+	UpdateIndex uint64
+	ValueType   byte
+	// Value is the object the reference points to, for RefVal1 and RefVal2
+	// records.
+	Value plumbing.Hash
+	// TargetValue is the object an annotated tag peels to, for RefVal2
+	// records.
+	TargetValue plumbing.Hash
+	// Target is the name of the reference a RefSymref record points to.
+	Target string
+}
+
+// IsDeletion returns true if the record is a tombstone.
+func (r *RefRecord) IsDeletion() bool {
+	return r.ValueType == RefDeletion
+}
+
+func (r *RefRecord) key() []byte {
+	return []byte(r.RefName)
+}
+
+func (r *RefRecord) encode(minUpdateIndex uint64) []byte {
+	buf := putVarint(nil, r.UpdateIndex-minUpdateIndex)
+	switch r.ValueType {
+	case RefVal1:
+		buf = append(buf, r.Value[:]...)
+	case RefVal2:
+		buf = append(buf, r.Value[:]...)
+		buf = append(buf, r.TargetValue[:]...)
+	case RefSymref:
+		buf = putVarint(buf, uint64(len(r.Target)))
+		buf = append(buf, r.Target...)
+	}
+
+	return buf
+}
+
+func (r *RefRecord) decode(key []byte, typ byte, in []byte, minUpdateIndex uint64) (int, error) {
+	r.RefName = string(key)
+	r.ValueType = typ
+
+	delta, n := getVarint(in)
+	if n <= 0 {
+		return 0, ErrMalformedTable
+	}
+
+	r.UpdateIndex = minUpdateIndex + delta
+	switch typ {
+	case RefDeletion:
+	case RefVal1:
+		if len(in) < n+hash.Size {
+			return 0, ErrMalformedTable
+		}
+
+		copy(r.Value[:], in[n:])
+		n += hash.Size
+	case RefVal2:
+		if len(in) < n+2*hash.Size {
+			return 0, ErrMalformedTable
+		}
+
+		copy(r.Value[:], in[n:])
+		copy(r.TargetValue[:], in[n+hash.Size:])
+		n += 2 * hash.Size
+	case RefSymref:
+		l, m := getVarint(in[n:])
+		if m <= 0 || uint64(len(in)-n-m) < l {
+			return 0, ErrMalformedTable
+		}
+
+		n += m
+		r.Target = string(in[n : n+int(l)])
+		n += int(l)
+	default:
+		return 0, ErrMalformedTable
+	}
+
+	return n, nil
+}
+
+// LogRecord is a reflog entry stored in a reftable.
+type LogRecord struct {
+	RefName     string
+	UpdateIndex uint64
+	ValueType   byte
+	Old         plumbing.Hash
+	New         plumbing.Hash
+	Name        string
+	Email       string
+	// Time is the time of the update, in seconds since the epoch.
+	Time uint64
+	// TZOffset is the timezone offset of Time, in minutes.
+	TZOffset int16
+	Message  string
+}
+
+// IsDeletion returns true if the record is a tombstone.
+func (r *LogRecord) IsDeletion() bool {
+	return r.ValueType == LogDeletion
+}
+
+// key returns the refname, followed by a null byte and the reversed update
+// index, so the most recent entries of a reference are sorted first.
+func (r *LogRecord) key() []byte {
+	k := make([]byte, len(r.RefName)+9)
+	copy(k, r.RefName)
+	binary.BigEndian.PutUint64(k[len(r.RefName)+1:], ^r.UpdateIndex)
+
+	return k
+}
+
+func (r *LogRecord) encode(uint64) []byte {
+	if r.ValueType == LogDeletion {
+		return nil
+	}
+
+	buf := make([]byte, 0, 2*hash.Size+len(r.Name)+len(r.Email)+len(r.Message)+16)
+	buf = append(buf, r.Old[:]...)
+	buf = append(buf, r.New[:]...)
+	buf = putVarint(buf, uint64(len(r.Name)))
+	buf = append(buf, r.Name...)
+	buf = putVarint(buf, uint64(len(r.Email)))
+	buf = append(buf, r.Email...)
+	buf = putVarint(buf, r.Time)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(r.TZOffset))
+	buf = putVarint(buf, uint64(len(r.Message)))
+	buf = append(buf, r.Message...)
+
+	return buf
+}
+
+func (r *LogRecord) decode(key []byte, typ byte, in []byte, _ uint64) (int, error) {
+	i := bytes.IndexByte(key, 0)
+	if i < 0 || len(key) != i+9 {
+		return 0, ErrMalformedTable
+	}
+
+	r.RefName = string(key[:i])
+	r.UpdateIndex = ^binary.BigEndian.Uint64(key[i+1:])
+	r.ValueType = typ
+
+	switch typ {
+	case LogDeletion:
+		return 0, nil
+	case LogUpdate:
+	default:
+		return 0, ErrMalformedTable
+	}
+
+	if len(in) < 2*hash.Size {
+		return 0, ErrMalformedTable
+	}
+
+	copy(r.Old[:], in)
+	copy(r.New[:], in[hash.Size:])
+	n := 2 * hash.Size
+
+	var err error
+	if r.Name, n, err = getString(in, n); err != nil {
+		return 0, err
+	}
+
+	if r.Email, n, err = getString(in, n); err != nil {
+		return 0, err
+	}
+
+	t, m := getVarint(in[n:])
+	if m <= 0 || len(in) < n+m+2 {
+		return 0, ErrMalformedTable
+	}
+
+	r.Time = t
+	n += m
+	r.TZOffset = int16(binary.BigEndian.Uint16(in[n:]))
+	n += 2
+
+	if r.Message, n, err = getString(in, n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+type record interface {
+	key() []byte
+	encode(minUpdateIndex uint64) []byte
+	decode(key []byte, typ byte, in []byte, minUpdateIndex uint64) (int, error)
+}
+
+func getString(in []byte, n int) (string, int, error) {
+	l, m := getVarint(in[n:])
+	if m <= 0 || uint64(len(in)-n-m) < l {
+		return "", 0, ErrMalformedTable
+	}
+
+	n += m
+	return string(in[n : n+int(l)]), n + int(l), nil
+}
+
+// putVarint appends the variable width encoding of v to buf, using the same
+// encoding as the offsets of OFS_DELTA objects in packfiles.
+func putVarint(buf []byte, v uint64) []byte {
+	var tmp [10]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(v & 0x7f)
+	for v >>= 7; v != 0; v >>= 7 {
+		v--
+		i--
+		tmp[i] = 0x80 | byte(v&0x7f)
+	}
+
+	return append(buf, tmp[i:]...)
+}
+
+// getVarint decodes a variable width integer from in, returning the value
+// and the number of bytes read, or 0 if in is too short.
+func getVarint(in []byte) (uint64, int) {
+	if len(in) == 0 {
+		return 0, 0
+	}
+
+	v := uint64(in[0] & 0x7f)
+	n := 1
+	for in[n-1]&0x80 != 0 {
+		if n >= len(in) || n >= 10 {
+			return 0, 0
+		}
+
+		v = ((v + 1) << 7) | uint64(in[n]&0x7f)
+		n++
+	}
+
+	return v, n
+}
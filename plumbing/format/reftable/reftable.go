@@ -0,0 +1,187 @@
+package reftable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+
+	"github.com/go-git/go-git/v5/plumbing/hash"
+)
+
+const (
+	// DefaultBlockSize is the block size used by the Writer when none is
+	// given.
+	DefaultBlockSize = 4096
+
+	headerSizeV1 = 24
+	headerSizeV2 = 28
+	// footerSize is the size of the footer without the copy of the header.
+	footerSize = 5*8 + 4
+)
+
+var (
+	reftableMagic = []byte{'R', 'E', 'F', 'T'}
+
+	hashIDSHA1   = uint32('s')<<24 | uint32('h')<<16 | uint32('a')<<8 | uint32('1')
+	hashIDSHA256 = uint32('s')<<24 | uint32('2')<<16 | uint32('5')<<8 | uint32('6')
+)
+
+var (
+	// ErrUnsupportedVersion is returned by NewReader when the table version
+	// is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrUnsupportedHash is returned by NewReader when the table was written
+	// with a hash function different from the one in use.
+	ErrUnsupportedHash = errors.New("unsupported hash function")
+	// ErrMalformedTable is returned when a table is corrupted.
+	ErrMalformedTable = errors.New("malformed reftable")
+	// ErrRecordTooLarge is returned by the Writer when a ref record does not
+	// fit in a block.
+	ErrRecordTooLarge = errors.New("record too large for block size")
+	// ErrUpdateIndexOutOfRange is returned by the Writer when a record update
+	// index is outside of the table limits.
+	ErrUpdateIndexOutOfRange = errors.New("update index out of range")
+	// ErrLocked is returned by the Stack when the tables.list file is locked
+	// by another writer.
+	ErrLocked = errors.New("reftable stack is locked")
+)
+
+type header struct {
+	version        uint8
+	blockSize      uint32
+	minUpdateIndex uint64
+	maxUpdateIndex uint64
+	hashID         uint32
+}
+
+func newHeader(blockSize uint32, minUpdateIndex, maxUpdateIndex uint64) *header {
+	h := &header{
+		version:        1,
+		blockSize:      blockSize,
+		minUpdateIndex: minUpdateIndex,
+		maxUpdateIndex: maxUpdateIndex,
+		hashID:         hashIDSHA1,
+	}
+
+	if hash.Size != 20 {
+		h.version = 2
+		h.hashID = hashIDSHA256
+	}
+
+	return h
+}
+
+func (h *header) size() int {
+	if h.version == 1 {
+		return headerSizeV1
+	}
+
+	return headerSizeV2
+}
+
+func (h *header) encode() []byte {
+	buf := make([]byte, h.size())
+	copy(buf, reftableMagic)
+	buf[4] = h.version
+	putUint24(buf[5:], h.blockSize)
+	binary.BigEndian.PutUint64(buf[8:], h.minUpdateIndex)
+	binary.BigEndian.PutUint64(buf[16:], h.maxUpdateIndex)
+	if h.version == 2 {
+		binary.BigEndian.PutUint32(buf[24:], h.hashID)
+	}
+
+	return buf
+}
+
+func decodeHeader(data []byte) (*header, error) {
+	if len(data) < headerSizeV1 || !bytes.Equal(data[:4], reftableMagic) {
+		return nil, ErrMalformedTable
+	}
+
+	h := &header{
+		version:        data[4],
+		blockSize:      getUint24(data[5:]),
+		minUpdateIndex: binary.BigEndian.Uint64(data[8:]),
+		maxUpdateIndex: binary.BigEndian.Uint64(data[16:]),
+		hashID:         hashIDSHA1,
+	}
+
+	switch h.version {
+	case 1:
+	case 2:
+		if len(data) < headerSizeV2 {
+			return nil, ErrMalformedTable
+		}
+
+		h.hashID = binary.BigEndian.Uint32(data[24:])
+	default:
+		return nil, ErrUnsupportedVersion
+	}
+
+	expected := hashIDSHA1
+	if hash.Size != 20 {
+		expected = hashIDSHA256
+	}
+
+	if h.hashID != expected {
+		return nil, ErrUnsupportedHash
+	}
+
+	return h, nil
+}
+
+type footer struct {
+	header
+	refIndexPos uint64
+	objPos      uint64
+	objIDLen    uint8
+	objIndexPos uint64
+	logPos      uint64
+	logIndexPos uint64
+}
+
+func (f *footer) encode() []byte {
+	buf := f.header.encode()
+	buf = binary.BigEndian.AppendUint64(buf, f.refIndexPos)
+	buf = binary.BigEndian.AppendUint64(buf, f.objPos<<5|uint64(f.objIDLen))
+	buf = binary.BigEndian.AppendUint64(buf, f.objIndexPos)
+	buf = binary.BigEndian.AppendUint64(buf, f.logPos)
+	buf = binary.BigEndian.AppendUint64(buf, f.logIndexPos)
+
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+}
+
+func decodeFooter(data []byte, h *header) (*footer, error) {
+	size := h.size() + footerSize
+	if len(data) < size {
+		return nil, ErrMalformedTable
+	}
+
+	buf := data[len(data)-size:]
+	if crc32.ChecksumIEEE(buf[:size-4]) != binary.BigEndian.Uint32(buf[size-4:]) {
+		return nil, ErrMalformedTable
+	}
+
+	fh, err := decodeHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if *fh != *h {
+		return nil, ErrMalformedTable
+	}
+
+	buf = buf[h.size():]
+	obj := binary.BigEndian.Uint64(buf[8:])
+
+	return &footer{
+		header:      *h,
+		refIndexPos: binary.BigEndian.Uint64(buf),
+		objPos:      obj >> 5,
+		objIDLen:    uint8(obj & 0x1f),
+		objIndexPos: binary.BigEndian.Uint64(buf[16:]),
+		logPos:      binary.BigEndian.Uint64(buf[24:]),
+		logIndexPos: binary.BigEndian.Uint64(buf[32:]),
+	}, nil
+}
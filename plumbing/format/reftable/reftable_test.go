@@ -0,0 +1,275 @@
+package reftable_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/go-git/go-git/v5/plumbing/format/reftable"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ReftableSuite struct{}
+
+var _ = Suite(&ReftableSuite{})
+
+var errAborted = errors.New("aborted")
+
+func hashFor(i int) plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.BlobObject, []byte(fmt.Sprint(i)))
+}
+
+func writeTable(c *C, opts *WriterOptions, fn func(w *Writer)) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, opts)
+	w.SetLimits(1, 1)
+	fn(w)
+	c.Assert(w.Close(), IsNil)
+
+	return buf.Bytes()
+}
+
+func (s *ReftableSuite) TestEmpty(c *C) {
+	data := writeTable(c, nil, func(w *Writer) {})
+
+	r, err := NewReader(data)
+	c.Assert(err, IsNil)
+	c.Assert(r.MinUpdateIndex(), Equals, uint64(1))
+
+	refs, err := r.Refs()
+	c.Assert(err, IsNil)
+	c.Assert(refs, HasLen, 0)
+
+	_, err = r.Ref("refs/heads/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *ReftableSuite) TestRefs(c *C) {
+	data := writeTable(c, nil, func(w *Writer) {
+		c.Assert(w.AddRef(&RefRecord{RefName: "refs/heads/master", UpdateIndex: 1, ValueType: RefVal1, Value: hashFor(1)}), IsNil)
+		c.Assert(w.AddRef(&RefRecord{RefName: "HEAD", UpdateIndex: 1, ValueType: RefSymref, Target: "refs/heads/master"}), IsNil)
+		c.Assert(w.AddRef(&RefRecord{RefName: "refs/tags/v1", UpdateIndex: 1, ValueType: RefVal2, Value: hashFor(2), TargetValue: hashFor(3)}), IsNil)
+		c.Assert(w.AddRef(&RefRecord{RefName: "refs/heads/gone", UpdateIndex: 1, ValueType: RefDeletion}), IsNil)
+	})
+
+	c.Assert(len(data), Equals, DefaultBlockSize+68)
+
+	r, err := NewReader(data)
+	c.Assert(err, IsNil)
+
+	refs, err := r.Refs()
+	c.Assert(err, IsNil)
+	c.Assert(refs, HasLen, 4)
+	c.Assert(refs[0].RefName, Equals, "HEAD")
+	c.Assert(refs[0].Target, Equals, "refs/heads/master")
+	c.Assert(refs[1].RefName, Equals, "refs/heads/gone")
+	c.Assert(refs[1].IsDeletion(), Equals, true)
+
+	ref, err := r.Ref("refs/tags/v1")
+	c.Assert(err, IsNil)
+	c.Assert(ref.ValueType, Equals, RefVal2)
+	c.Assert(ref.Value, Equals, hashFor(2))
+	c.Assert(ref.TargetValue, Equals, hashFor(3))
+	c.Assert(ref.UpdateIndex, Equals, uint64(1))
+
+	_, err = r.Ref("refs/heads/missing")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *ReftableSuite) TestUpdateIndexOutOfRange(c *C) {
+	w := NewWriter(&bytes.Buffer{}, nil)
+	w.SetLimits(2, 3)
+
+	err := w.AddRef(&RefRecord{RefName: "HEAD", UpdateIndex: 1, ValueType: RefDeletion})
+	c.Assert(err, Equals, ErrUpdateIndexOutOfRange)
+}
+
+func (s *ReftableSuite) TestManyBlocks(c *C) {
+	for _, unpadded := range []bool{false, true} {
+		opts := &WriterOptions{BlockSize: 256, Unpadded: unpadded}
+		data := writeTable(c, opts, func(w *Writer) {
+			for i := 0; i < 1000; i++ {
+				c.Assert(w.AddRef(&RefRecord{
+					RefName:     fmt.Sprintf("refs/heads/branch-%04d", i),
+					UpdateIndex: 1,
+					ValueType:   RefVal1,
+					Value:       hashFor(i),
+				}), IsNil)
+
+				c.Assert(w.AddLog(&LogRecord{
+					RefName:     fmt.Sprintf("refs/heads/branch-%04d", i),
+					UpdateIndex: 1,
+					ValueType:   LogUpdate,
+					New:         hashFor(i),
+					Name:        "John Doe",
+					Email:       "john@example.com",
+					Time:        1700000000,
+					TZOffset:    -120,
+					Message:     strings.Repeat("m", i%50),
+				}), IsNil)
+			}
+		})
+
+		r, err := NewReader(data)
+		c.Assert(err, IsNil)
+
+		refs, err := r.Refs()
+		c.Assert(err, IsNil)
+		c.Assert(refs, HasLen, 1000)
+
+		for i := 0; i < 1000; i++ {
+			ref, err := r.Ref(fmt.Sprintf("refs/heads/branch-%04d", i))
+			c.Assert(err, IsNil)
+			c.Assert(ref.Value, Equals, hashFor(i))
+		}
+
+		logs, err := r.Logs()
+		c.Assert(err, IsNil)
+		c.Assert(logs, HasLen, 1000)
+		c.Assert(logs[999].RefName, Equals, "refs/heads/branch-0999")
+		c.Assert(logs[999].New, Equals, hashFor(999))
+		c.Assert(logs[999].Email, Equals, "john@example.com")
+		c.Assert(logs[999].TZOffset, Equals, int16(-120))
+		c.Assert(logs[999].Message, Equals, strings.Repeat("m", 999%50))
+	}
+}
+
+func (s *ReftableSuite) TestOnlyLogs(c *C) {
+	data := writeTable(c, nil, func(w *Writer) {
+		c.Assert(w.AddLog(&LogRecord{RefName: "HEAD", UpdateIndex: 1, ValueType: LogUpdate, Message: "init"}), IsNil)
+	})
+
+	r, err := NewReader(data)
+	c.Assert(err, IsNil)
+
+	refs, err := r.Refs()
+	c.Assert(err, IsNil)
+	c.Assert(refs, HasLen, 0)
+
+	logs, err := r.Logs()
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, 1)
+	c.Assert(logs[0].Message, Equals, "init")
+}
+
+func (s *ReftableSuite) TestCorrupted(c *C) {
+	data := writeTable(c, nil, func(w *Writer) {
+		c.Assert(w.AddRef(&RefRecord{RefName: "HEAD", UpdateIndex: 1, ValueType: RefSymref, Target: "refs/heads/master"}), IsNil)
+	})
+
+	data[len(data)-1]++
+	_, err := NewReader(data)
+	c.Assert(err, Equals, ErrMalformedTable)
+}
+
+func (s *ReftableSuite) TestStack(c *C) {
+	fs := memfs.New()
+	st := NewStack(fs, nil)
+	c.Assert(st.Init(), IsNil)
+
+	for i := 0; i < 20; i++ {
+		err := st.Add(func(w *Writer, _ *Merged, updateIndex uint64) error {
+			return w.AddRef(&RefRecord{
+				RefName:     fmt.Sprintf("refs/heads/b%d", i),
+				UpdateIndex: updateIndex,
+				ValueType:   RefVal1,
+				Value:       hashFor(i),
+			})
+		})
+		c.Assert(err, IsNil)
+	}
+
+	err := st.Add(func(w *Writer, _ *Merged, updateIndex uint64) error {
+		return w.AddRef(&RefRecord{RefName: "refs/heads/b3", UpdateIndex: updateIndex, ValueType: RefDeletion})
+	})
+	c.Assert(err, IsNil)
+
+	m, err := st.Merged()
+	c.Assert(err, IsNil)
+	c.Assert(m.MaxUpdateIndex(), Equals, uint64(21))
+
+	refs, err := m.Refs()
+	c.Assert(err, IsNil)
+	c.Assert(refs, HasLen, 19)
+
+	_, err = m.Ref("refs/heads/b3")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	ref, err := m.Ref("refs/heads/b4")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Value, Equals, hashFor(4))
+
+	list, err := util.ReadFile(fs, "tables.list")
+	c.Assert(err, IsNil)
+	tables := strings.Split(strings.TrimSpace(string(list)), "\n")
+	c.Assert(len(tables) < 10, Equals, true)
+
+	c.Assert(st.Compact(), IsNil)
+
+	list, err = util.ReadFile(fs, "tables.list")
+	c.Assert(err, IsNil)
+	tables = strings.Split(strings.TrimSpace(string(list)), "\n")
+	c.Assert(tables, HasLen, 1)
+	c.Assert(strings.HasPrefix(tables[0], "0x000000000001-0x000000000015-"), Equals, true)
+
+	files, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 2)
+
+	m, err = NewStack(fs, nil).Merged()
+	c.Assert(err, IsNil)
+
+	refs, err = m.Refs()
+	c.Assert(err, IsNil)
+	c.Assert(refs, HasLen, 19)
+}
+
+func (s *ReftableSuite) TestStackLocked(c *C) {
+	fs := memfs.New()
+	c.Assert(util.WriteFile(fs, "tables.list.lock", nil, 0666), IsNil)
+
+	err := NewStack(fs, nil).Add(func(w *Writer, _ *Merged, updateIndex uint64) error {
+		return nil
+	})
+	c.Assert(err, Equals, ErrLocked)
+}
+
+func (s *ReftableSuite) TestStackAtomic(c *C) {
+	fs := memfs.New()
+	st := NewStack(fs, nil)
+
+	err := st.Add(func(w *Writer, _ *Merged, updateIndex uint64) error {
+		for i := 0; i < 3; i++ {
+			err := w.AddRef(&RefRecord{
+				RefName:     fmt.Sprintf("refs/heads/b%d", i),
+				UpdateIndex: updateIndex,
+				ValueType:   RefVal1,
+				Value:       hashFor(i),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return errAborted
+	})
+	c.Assert(err, Equals, errAborted)
+
+	m, err := st.Merged()
+	c.Assert(err, IsNil)
+
+	refs, err := m.Refs()
+	c.Assert(err, IsNil)
+	c.Assert(refs, HasLen, 0)
+
+	_, err = fs.Stat("tables.list.lock")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,347 @@
+package reftable
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const (
+	tablesListFile = "tables.list"
+	lockSuffix     = ".lock"
+
+	// maxReloadRetries is the number of times the stack is reloaded when a
+	// table listed in tables.list is removed by a concurrent compaction.
+	maxReloadRetries = 3
+)
+
+// Stack is the stack of tables of a repository, listed in the tables.list
+// file of the given filesystem, usually the .git/reftable directory.
+//
+// The tables.list file is reloaded on every operation, so changes made by
+// other processes are visible, while the tables, which are immutable, are
+// kept in memory.
+type Stack struct {
+	fs   billy.Filesystem
+	opts WriterOptions
+
+	m      sync.Mutex
+	tables map[string]*Reader
+}
+
+// NewStack returns a new Stack for the tables in the given filesystem.
+func NewStack(fs billy.Filesystem, opts *WriterOptions) *Stack {
+	if opts == nil {
+		opts = &WriterOptions{}
+	}
+
+	return &Stack{
+		fs:     fs,
+		opts:   *opts,
+		tables: make(map[string]*Reader),
+	}
+}
+
+// Init creates an empty tables.list file, if it does not exist.
+func (s *Stack) Init() error {
+	_, err := s.fs.Stat(tablesListFile)
+	if err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	return util.WriteFile(s.fs, tablesListFile, nil, 0666)
+}
+
+// Merged returns a view over the current tables of the stack.
+func (s *Stack) Merged() (*Merged, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var err error
+	for i := 0; i < maxReloadRetries; i++ {
+		var names []string
+		var tables []*Reader
+		names, err = s.readList()
+		if err != nil {
+			return nil, err
+		}
+
+		tables, err = s.load(names)
+		if err == nil {
+			return NewMerged(tables), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// Add appends a new table to the stack, with the records added by fn to the
+// given Writer, using updateIndex as update index. current is the view over
+// the stack at the time of the update, which does not change until fn
+// returns. The records are committed atomically, either all of them or none
+// are visible to the readers. No table is written if fn does not add any
+// record.
+func (s *Stack) Add(fn func(w *Writer, current *Merged, updateIndex uint64) error) error {
+	return s.locked(func(names []string, tables []*Reader) ([]string, error) {
+		next := uint64(1)
+		if len(tables) > 0 {
+			next = tables[len(tables)-1].MaxUpdateIndex() + 1
+		}
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf, &s.opts)
+		w.SetLimits(next, next)
+		if err := fn(w, NewMerged(tables), next); err != nil {
+			return nil, err
+		}
+
+		if w.Empty() {
+			return names, nil
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		name, t, err := s.writeTable(buf.Bytes(), next, next)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+		tables = append(tables, t)
+
+		return s.autoCompact(names, tables)
+	})
+}
+
+// Compact merges all the tables of the stack into a single one.
+func (s *Stack) Compact() error {
+	return s.locked(func(names []string, tables []*Reader) ([]string, error) {
+		if len(tables) < 2 {
+			return names, nil
+		}
+
+		return s.compact(names, tables, 0)
+	})
+}
+
+// autoCompact merges the newest tables, keeping each table at least twice
+// the size of the sum of the newer ones.
+func (s *Stack) autoCompact(names []string, tables []*Reader) ([]string, error) {
+	start := len(tables) - 1
+	total := len(tables[start].data)
+	for start > 0 && len(tables[start-1].data) < 2*total {
+		start--
+		total += len(tables[start].data)
+	}
+
+	if start == len(tables)-1 {
+		return names, nil
+	}
+
+	return s.compact(names, tables, start)
+}
+
+// compact merges the tables from start to the end of the stack, returning the
+// new list of tables. Deletions are only kept if there are older tables whose
+// records they hide.
+func (s *Stack) compact(names []string, tables []*Reader, start int) ([]string, error) {
+	m := NewMerged(tables[start:])
+	keepDeletions := start > 0
+
+	refs, err := m.refs(keepDeletions)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := m.logs(keepDeletions)
+	if err != nil {
+		return nil, err
+	}
+
+	min := tables[start].MinUpdateIndex()
+	max := tables[len(tables)-1].MaxUpdateIndex()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, &s.opts)
+	w.SetLimits(min, max)
+	for _, r := range refs {
+		if err := w.AddRef(r); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, l := range logs {
+		if err := w.AddLog(l); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	name, _, err := s.writeTable(buf.Bytes(), min, max)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, start+1)
+	result = append(result, names[:start]...)
+	return append(result, name), nil
+}
+
+// locked runs fn holding the lock of the tables.list file, replacing it with
+// the list of tables returned by fn. Tables no longer listed are removed.
+// Tables written by fn are removed too if fn fails.
+func (s *Stack) locked(fn func(names []string, tables []*Reader) ([]string, error)) (err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	lockFile := tablesListFile + lockSuffix
+	f, err := s.fs.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		if os.IsExist(err) {
+			return ErrLocked
+		}
+
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			_ = f.Close()
+			_ = s.fs.Remove(lockFile)
+		}
+	}()
+
+	names, err := s.readList()
+	if err != nil {
+		return err
+	}
+
+	tables, err := s.load(names)
+	if err != nil {
+		return err
+	}
+
+	newNames, err := fn(names, tables)
+	if err != nil {
+		s.removeUnlisted(names)
+		return err
+	}
+
+	var content strings.Builder
+	for _, n := range newNames {
+		content.WriteString(n)
+		content.WriteByte('\n')
+	}
+
+	if _, err := f.Write([]byte(content.String())); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := s.fs.Rename(lockFile, tablesListFile); err != nil {
+		return err
+	}
+
+	committed = true
+
+	s.removeUnlisted(newNames)
+	return nil
+}
+
+// removeUnlisted removes the tables in memory which are not in the given
+// list, such as the ones replaced by a compaction or written by a failed
+// update.
+func (s *Stack) removeUnlisted(names []string) {
+	listed := make(map[string]bool, len(names))
+	for _, n := range names {
+		listed[n] = true
+	}
+
+	for n := range s.tables {
+		if !listed[n] {
+			delete(s.tables, n)
+			_ = s.fs.Remove(n)
+		}
+	}
+}
+
+func (s *Stack) writeTable(data []byte, min, max uint64) (string, *Reader, error) {
+	t, err := NewReader(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := fmt.Sprintf("0x%012x-0x%012x-%08x.ref", min, max, rand.Uint32())
+	if err := util.WriteFile(s.fs, name, data, 0666); err != nil {
+		return "", nil, err
+	}
+
+	s.tables[name] = t
+	return name, t, nil
+}
+
+func (s *Stack) readList() ([]string, error) {
+	data, err := util.ReadFile(s.fs, tablesListFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var names []string
+	for _, n := range strings.Split(string(data), "\n") {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+
+	return names, nil
+}
+
+// load returns the tables with the given names, reading the ones not yet
+// in memory, and forgetting the ones no longer in the stack.
+func (s *Stack) load(names []string) ([]*Reader, error) {
+	tables := make([]*Reader, len(names))
+	current := make(map[string]*Reader, len(names))
+	for i, n := range names {
+		t, ok := s.tables[n]
+		if !ok {
+			data, err := util.ReadFile(s.fs, n)
+			if err != nil {
+				return nil, err
+			}
+
+			t, err = NewReader(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		tables[i] = t
+		current[n] = t
+	}
+
+	s.tables = current
+	return tables, nil
+}
@@ -0,0 +1,192 @@
+package reftable
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+// WriterOptions holds the configuration of a Writer.
+type WriterOptions struct {
+	// BlockSize is the size of the ref blocks, DefaultBlockSize if zero.
+	BlockSize int
+	// Unpadded disables the padding of the ref blocks up to BlockSize.
+	Unpadded bool
+}
+
+// Writer writes a single reftable. Records may be added in any order, they
+// are sorted when the table is written by Close.
+type Writer struct {
+	w    io.Writer
+	opts WriterOptions
+
+	minUpdateIndex uint64
+	maxUpdateIndex uint64
+
+	refs map[string]*RefRecord
+	logs map[string]*LogRecord
+}
+
+// NewWriter returns a new Writer writing the table to w.
+func NewWriter(w io.Writer, opts *WriterOptions) *Writer {
+	if opts == nil {
+		opts = &WriterOptions{}
+	}
+
+	o := *opts
+	if o.BlockSize <= 0 {
+		o.BlockSize = DefaultBlockSize
+	}
+
+	if o.BlockSize > maxBlockSize {
+		o.BlockSize = maxBlockSize
+	}
+
+	return &Writer{
+		w:    w,
+		opts: o,
+		refs: make(map[string]*RefRecord),
+		logs: make(map[string]*LogRecord),
+	}
+}
+
+// SetLimits sets the minimum and maximum update indexes of the records in
+// the table.
+func (w *Writer) SetLimits(min, max uint64) {
+	w.minUpdateIndex = min
+	w.maxUpdateIndex = max
+}
+
+// AddRef adds a ref record to the table, replacing any previous record for
+// the same reference.
+func (w *Writer) AddRef(r *RefRecord) error {
+	if r.UpdateIndex < w.minUpdateIndex || r.UpdateIndex > w.maxUpdateIndex {
+		return ErrUpdateIndexOutOfRange
+	}
+
+	w.refs[r.RefName] = r
+	return nil
+}
+
+// AddLog adds a log record to the table, replacing any previous record for
+// the same reference and update index.
+func (w *Writer) AddLog(r *LogRecord) error {
+	if r.UpdateIndex < w.minUpdateIndex || r.UpdateIndex > w.maxUpdateIndex {
+		return ErrUpdateIndexOutOfRange
+	}
+
+	w.logs[string(r.key())] = r
+	return nil
+}
+
+// Empty returns true if no records were added to the table.
+func (w *Writer) Empty() bool {
+	return len(w.refs) == 0 && len(w.logs) == 0
+}
+
+// Close writes the table.
+func (w *Writer) Close() error {
+	h := newHeader(uint32(w.opts.BlockSize), w.minUpdateIndex, w.maxUpdateIndex)
+	f := &footer{header: *h}
+
+	var out bytes.Buffer
+	first := h.encode()
+
+	refs := make([]*RefRecord, 0, len(w.refs))
+	for _, r := range w.refs {
+		refs = append(refs, r)
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].RefName < refs[j].RefName })
+
+	var bw *blockWriter
+	for _, r := range refs {
+		if bw != nil && bw.add(r) {
+			continue
+		}
+
+		if bw != nil {
+			if err := w.flush(&out, bw, !w.opts.Unpadded); err != nil {
+				return err
+			}
+		}
+
+		bw = newBlockWriter(blockTypeRef, first, w.opts.BlockSize, defaultRestartInterval, w.minUpdateIndex)
+		first = nil
+		if !bw.add(r) {
+			return ErrRecordTooLarge
+		}
+	}
+
+	if bw != nil {
+		if err := w.flush(&out, bw, !w.opts.Unpadded); err != nil {
+			return err
+		}
+	}
+
+	if err := w.writeLogs(&out, first, f); err != nil {
+		return err
+	}
+
+	if out.Len() == 0 {
+		out.Write(h.encode())
+	}
+
+	out.Write(f.encode())
+	_, err := w.w.Write(out.Bytes())
+	return err
+}
+
+func (w *Writer) writeLogs(out *bytes.Buffer, first []byte, f *footer) error {
+	if len(w.logs) == 0 {
+		return nil
+	}
+
+	logs := make([]*LogRecord, 0, len(w.logs))
+	for _, r := range w.logs {
+		logs = append(logs, r)
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		return bytes.Compare(logs[i].key(), logs[j].key()) < 0
+	})
+
+	f.logPos = uint64(out.Len())
+
+	var bw *blockWriter
+	for _, r := range logs {
+		if bw != nil && bw.add(r) {
+			continue
+		}
+
+		if bw != nil {
+			if err := w.flush(out, bw, false); err != nil {
+				return err
+			}
+		}
+
+		bw = newBlockWriter(blockTypeLog, first, w.opts.BlockSize, defaultRestartInterval, w.minUpdateIndex)
+		if !bw.add(r) {
+			// Log blocks are not padded, a larger block is used for
+			// records with long messages.
+			bw = newBlockWriter(blockTypeLog, first, maxBlockSize, defaultRestartInterval, w.minUpdateIndex)
+			if !bw.add(r) {
+				return ErrRecordTooLarge
+			}
+		}
+
+		first = nil
+	}
+
+	return w.flush(out, bw, false)
+}
+
+func (w *Writer) flush(out *bytes.Buffer, bw *blockWriter, pad bool) error {
+	b, err := bw.finish(pad)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(b)
+	return err
+}
@@ -0,0 +1,112 @@
+package revfile
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	encbin "encoding/binary"
+
+	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/go-git/go-git/v5/utils/binary"
+)
+
+// Decoder reads and decodes rev files from an input stream.
+type Decoder struct {
+	*bufio.Reader
+}
+
+// NewDecoder builds a new rev stream decoder, that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{bufio.NewReader(r)}
+}
+
+// Decode reads from the stream and decode the content into the ReverseIndex
+// struct. The number of entries is deduced from the size of the stream, and
+// the trailing checksum is verified.
+func (d *Decoder) Decode(rev *ReverseIndex) error {
+	h := hash.New(hash.CryptoType)
+	r := io.TeeReader(d, h)
+
+	if err := validateHeader(r); err != nil {
+		return err
+	}
+
+	flow := []func(*ReverseIndex, io.Reader) error{
+		readVersion,
+		readHashID,
+	}
+
+	for _, f := range flow {
+		if err := f(rev, r); err != nil {
+			return err
+		}
+	}
+
+	body, err := io.ReadAll(d)
+	if err != nil {
+		return err
+	}
+
+	trailer := 2 * hash.Size
+	if len(body) < trailer || (len(body)-trailer)%4 != 0 {
+		return ErrMalformedRevFile
+	}
+
+	table := body[:len(body)-trailer]
+	rev.Position = make([]uint32, len(table)/4)
+	for i := range rev.Position {
+		rev.Position[i] = encbin.BigEndian.Uint32(table[i*4:])
+	}
+
+	h.Write(body[:len(body)-hash.Size])
+	copy(rev.PackfileChecksum[:], body[len(table):])
+	copy(rev.RevChecksum[:], body[len(table)+hash.Size:])
+
+	if !bytes.Equal(h.Sum(nil), rev.RevChecksum[:]) {
+		return ErrMalformedRevFile
+	}
+
+	return nil
+}
+
+func validateHeader(r io.Reader) error {
+	var h = make([]byte, 4)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h, revHeader) {
+		return ErrMalformedRevFile
+	}
+
+	return nil
+}
+
+func readVersion(rev *ReverseIndex, r io.Reader) error {
+	v, err := binary.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+
+	if v != VersionSupported {
+		return ErrUnsupportedVersion
+	}
+
+	rev.Version = v
+	return nil
+}
+
+func readHashID(rev *ReverseIndex, r io.Reader) error {
+	id, err := binary.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+
+	if id != hash.FormatID {
+		return ErrUnsupportedHash
+	}
+
+	rev.HashID = id
+	return nil
+}
@@ -0,0 +1,29 @@
+// Package revfile implements encoding and decoding of packfile reverse
+// index (.rev) files.
+//
+// A reverse index maps the position of an object in the packfile, when
+// objects are sorted by offset, to its position in the idx file, when they
+// are sorted by name. Using it avoids building an offset to hash map in
+// memory for every packfile that needs offset lookups.
+//
+//  == pack-*.rev files have the format:
+//
+//    - A 4-byte magic number '0x52494458' ('RIDX').
+//
+//    - A 4-byte version identifier (= 1).
+//
+//    - A 4-byte hash function identifier (= 1 for SHA-1, 2 for SHA-256).
+//
+//    - A table of index positions (one per packed object, num_objects in
+//      total, each a 4-byte unsigned integer in network order), sorted by
+//      their corresponding offsets in the packfile.
+//
+//    - A trailer, containing a:
+//
+//      checksum of the corresponding packfile, and
+//
+//      a checksum of all of the above.
+//
+// Source:
+// https://git-scm.com/docs/gitformat-pack#_pack_rev_files_have_the_format
+package revfile
@@ -0,0 +1,78 @@
+package revfile
+
+import (
+	"io"
+
+	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/go-git/go-git/v5/utils/binary"
+)
+
+// Encoder writes ReverseIndex structs to an output stream.
+type Encoder struct {
+	io.Writer
+	hash hash.Hash
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	h := hash.New(hash.CryptoType)
+	mw := io.MultiWriter(w, h)
+	return &Encoder{mw, h}
+}
+
+// Encode encodes a ReverseIndex to the encoder writer.
+func (e *Encoder) Encode(rev *ReverseIndex) (int, error) {
+	flow := []func(*ReverseIndex) (int, error){
+		e.encodeHeader,
+		e.encodePositions,
+		e.encodeChecksums,
+	}
+
+	sz := 0
+	for _, f := range flow {
+		i, err := f(rev)
+		sz += i
+
+		if err != nil {
+			return sz, err
+		}
+	}
+
+	return sz, nil
+}
+
+func (e *Encoder) encodeHeader(rev *ReverseIndex) (int, error) {
+	c, err := e.Write(revHeader)
+	if err != nil {
+		return c, err
+	}
+
+	if err := binary.WriteUint32(e, rev.Version); err != nil {
+		return c, err
+	}
+
+	return c + 8, binary.WriteUint32(e, rev.HashID)
+}
+
+func (e *Encoder) encodePositions(rev *ReverseIndex) (int, error) {
+	for i, p := range rev.Position {
+		if err := binary.WriteUint32(e, p); err != nil {
+			return i * 4, err
+		}
+	}
+
+	return len(rev.Position) * 4, nil
+}
+
+func (e *Encoder) encodeChecksums(rev *ReverseIndex) (int, error) {
+	if _, err := e.Write(rev.PackfileChecksum[:]); err != nil {
+		return 0, err
+	}
+
+	copy(rev.RevChecksum[:], e.hash.Sum(nil)[:hash.Size])
+	if _, err := e.Write(rev.RevChecksum[:]); err != nil {
+		return hash.Size, err
+	}
+
+	return 2 * hash.Size, nil
+}
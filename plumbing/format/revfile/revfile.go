@@ -0,0 +1,107 @@
+package revfile
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+)
+
+const (
+	// VersionSupported is the only rev version supported.
+	VersionSupported = 1
+)
+
+var (
+	revHeader = []byte{'R', 'I', 'D', 'X'}
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Decode when the rev file version
+	// is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrUnsupportedHash is returned by Decode when the rev file was written
+	// with a hash function different from the one in use.
+	ErrUnsupportedHash = errors.New("unsupported hash function")
+	// ErrMalformedRevFile is returned by Decode when the rev file is
+	// corrupted.
+	ErrMalformedRevFile = errors.New("malformed REV file")
+	// ErrOutOfRange is returned by IndexPosition when the requested entry
+	// is not in the reverse index.
+	ErrOutOfRange = errors.New("position out of range")
+)
+
+// ReverseIndex is the in memory representation of a rev file.
+type ReverseIndex struct {
+	Version  uint32
+	HashID   uint32
+	Position []uint32
+	// PackfileChecksum is the checksum of the packfile the reverse index
+	// refers to.
+	PackfileChecksum [hash.Size]byte
+	RevChecksum      [hash.Size]byte
+}
+
+var _ idxfile.ReverseIndex = (*ReverseIndex)(nil)
+
+// New builds a ReverseIndex for the given idx file.
+func New(idx *idxfile.MemoryIndex) (*ReverseIndex, error) {
+	count, err := idx.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+
+	offsets := make([]uint64, 0, count)
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		offsets = append(offsets, e.Offset)
+	}
+
+	rev := &ReverseIndex{
+		Version:          VersionSupported,
+		HashID:           hash.FormatID,
+		Position:         make([]uint32, len(offsets)),
+		PackfileChecksum: idx.PackfileChecksum,
+	}
+
+	for i := range rev.Position {
+		rev.Position[i] = uint32(i)
+	}
+
+	sort.Slice(rev.Position, func(i, j int) bool {
+		return offsets[rev.Position[i]] < offsets[rev.Position[j]]
+	})
+
+	return rev, nil
+}
+
+// Count implements the idxfile.ReverseIndex interface.
+func (r *ReverseIndex) Count() int64 {
+	return int64(len(r.Position))
+}
+
+// IndexPosition implements the idxfile.ReverseIndex interface.
+func (r *ReverseIndex) IndexPosition(n int64) (uint32, error) {
+	if n < 0 || n >= int64(len(r.Position)) {
+		return 0, ErrOutOfRange
+	}
+
+	return r.Position[n], nil
+}
@@ -0,0 +1,139 @@
+package revfile_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	. "github.com/go-git/go-git/v5/plumbing/format/revfile"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type RevfileSuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&RevfileSuite{})
+
+func decodeIdx(c *C, f *fixtures.Fixture) *idxfile.MemoryIndex {
+	idx := idxfile.NewMemoryIndex()
+	err := idxfile.NewDecoder(f.Idx()).Decode(idx)
+	c.Assert(err, IsNil)
+
+	return idx
+}
+
+func (s *RevfileSuite) TestNew(c *C) {
+	idx := decodeIdx(c, fixtures.Basic().One())
+
+	rev, err := New(idx)
+	c.Assert(err, IsNil)
+	c.Assert(rev.Count(), Equals, int64(31))
+	c.Assert(rev.PackfileChecksum, Equals, idx.PackfileChecksum)
+
+	entries, err := idx.EntriesByOffset()
+	c.Assert(err, IsNil)
+
+	for n := int64(0); n < rev.Count(); n++ {
+		e, err := entries.Next()
+		c.Assert(err, IsNil)
+
+		pos, err := rev.IndexPosition(n)
+		c.Assert(err, IsNil)
+
+		expected, err := idx.FindPosition(e.Hash)
+		c.Assert(err, IsNil)
+		c.Assert(int64(pos), Equals, expected)
+	}
+
+	_, err = rev.IndexPosition(rev.Count())
+	c.Assert(err, Equals, ErrOutOfRange)
+}
+
+func (s *RevfileSuite) TestEncodeDecode(c *C) {
+	fixtures.ByTag("packfile").Test(c, func(f *fixtures.Fixture) {
+		rev, err := New(decodeIdx(c, f))
+		c.Assert(err, IsNil)
+
+		buf := bytes.NewBuffer(nil)
+		size, err := NewEncoder(buf).Encode(rev)
+		c.Assert(err, IsNil)
+		c.Assert(size, Equals, buf.Len())
+
+		decoded := new(ReverseIndex)
+		err = NewDecoder(buf).Decode(decoded)
+		c.Assert(err, IsNil)
+		c.Assert(decoded, DeepEquals, rev)
+	})
+}
+
+func (s *RevfileSuite) TestDecodeBadChecksum(c *C) {
+	rev, err := New(decodeIdx(c, fixtures.Basic().One()))
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	_, err = NewEncoder(buf).Encode(rev)
+	c.Assert(err, IsNil)
+
+	data := buf.Bytes()
+	data[12] ^= 0xff
+
+	err = NewDecoder(bytes.NewReader(data)).Decode(new(ReverseIndex))
+	c.Assert(err, Equals, ErrMalformedRevFile)
+}
+
+func (s *RevfileSuite) TestDecodeBadHeader(c *C) {
+	err := NewDecoder(bytes.NewBufferString("RIDY\x00\x00\x00\x01")).Decode(new(ReverseIndex))
+	c.Assert(err, Equals, ErrMalformedRevFile)
+
+	err = NewDecoder(bytes.NewBufferString("RIDX\x00\x00\x00\x02")).Decode(new(ReverseIndex))
+	c.Assert(err, Equals, ErrUnsupportedVersion)
+}
+
+func (s *RevfileSuite) TestFindHashWithReverseIndex(c *C) {
+	idx := decodeIdx(c, fixtures.Basic().One())
+
+	rev, err := New(idx)
+	c.Assert(err, IsNil)
+	c.Assert(idx.SetReverseIndex(rev), IsNil)
+
+	entries, err := idx.Entries()
+	c.Assert(err, IsNil)
+
+	for {
+		e, err := entries.Next()
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+
+		h, err := idx.FindHash(int64(e.Offset))
+		c.Assert(err, IsNil)
+		c.Assert(h, Equals, e.Hash)
+	}
+
+	_, err = idx.FindHash(1)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	byOffset, err := idx.EntriesByOffset()
+	c.Assert(err, IsNil)
+
+	var last uint64
+	for {
+		e, err := byOffset.Next()
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+		c.Assert(e.Offset > last, Equals, true)
+		last = e.Offset
+	}
+}
@@ -12,4 +12,7 @@ const (
 	Size = 20
 	// HexSize defines the strings size of the hash when represented in hexadecimal.
 	HexSize = 40
+	// FormatID is the hash function identifier stored in the header of
+	// pack-related files, such as reverse indexes and mtimes files.
+	FormatID = 1
 )
@@ -16,6 +16,9 @@ import (
 type Change struct {
 	From ChangeEntry
 	To   ChangeEntry
+	// Copy is true if To is a copy of From, From being kept in the final
+	// tree. Copies are only detected with DiffTreeOptions.DetectCopies.
+	Copy bool
 }
 
 var empty ChangeEntry
@@ -92,7 +95,14 @@ func (c *Change) Patch() (*Patch, error) {
 // If context expires, an non-nil error will be returned
 // Provided context must be non-nil
 func (c *Change) PatchContext(ctx context.Context) (*Patch, error) {
-	return getPatchContext(ctx, "", c)
+	return getPatchContext(ctx, "", nil, c)
+}
+
+// PatchWithOptions returns a Patch with all the file changes in chunks,
+// computed with the given options. If context expires, an non-nil error will
+// be returned. Provided context must be non-nil.
+func (c *Change) PatchWithOptions(ctx context.Context, o *PatchOptions) (*Patch, error) {
+	return getPatchContext(ctx, "", o, c)
 }
 
 func (c *Change) name() string {
@@ -155,5 +165,12 @@ func (c Changes) Patch() (*Patch, error) {
 // If context expires, an non-nil error will be returned
 // Provided context must be non-nil
 func (c Changes) PatchContext(ctx context.Context) (*Patch, error) {
-	return getPatchContext(ctx, "", c...)
+	return getPatchContext(ctx, "", nil, c...)
+}
+
+// PatchWithOptions returns a Patch with all the changes in chunks, computed
+// with the given options. If context expires, an non-nil error will be
+// returned. Provided context must be non-nil.
+func (c Changes) PatchWithOptions(ctx context.Context, o *PatchOptions) (*Patch, error) {
+	return getPatchContext(ctx, "", o, c...)
 }
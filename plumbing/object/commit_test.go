@@ -11,6 +11,7 @@ import (
 	fixtures "github.com/go-git/go-git-fixtures/v4"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/utils/diff"
 
 	"github.com/go-git/go-git/v5/storage/filesystem"
 	. "gopkg.in/check.v1"
@@ -197,6 +198,23 @@ func (s *SuiteCommit) TestPatchContext_ToNil(c *C) {
 	c.Assert(len(patch.String()), Equals, 242679)
 }
 
+func (s *SuiteCommit) TestPatchWithOptions(c *C) {
+	from := s.commit(c, plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"))
+	to := s.commit(c, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	expected, err := from.Patch(to)
+	c.Assert(err, IsNil)
+
+	for _, a := range []diff.Algorithm{diff.Myers, diff.Minimal, diff.Patience, diff.Histogram} {
+		patch, err := from.PatchWithOptions(context.Background(), to, &PatchOptions{
+			Algorithm:       a,
+			IndentHeuristic: true,
+		})
+		c.Assert(err, IsNil)
+		c.Assert(patch.String(), Equals, expected.String())
+	}
+}
+
 func (s *SuiteCommit) TestCommitEncodeDecodeIdempotent(c *C) {
 	pgpsignature := `-----BEGIN PGP SIGNATURE-----
 
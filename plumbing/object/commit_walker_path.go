@@ -1,6 +1,7 @@
 package object
 
 import (
+	"context"
 	"io"
 
 	"github.com/go-git/go-git/v5/plumbing"
@@ -165,3 +166,176 @@ func (c *commitPathIter) ForEach(cb func(*Commit) error) error {
 func (c *commitPathIter) Close() {
 	c.sourceIter.Close()
 }
+
+// CommitFollowIter is a CommitIter following a file across its renames, as
+// `git log --follow`.
+type CommitFollowIter interface {
+	CommitIter
+	// Path returns the path of the followed file in the commit with the
+	// given hash, once the commit was returned by the iterator.
+	Path(plumbing.Hash) string
+}
+
+type commitFollowIter struct {
+	sourceIter CommitIter
+	path       string
+	opts       *DiffTreeOptions
+
+	// paths are the paths of the file in the commits, set when their
+	// children are walked.
+	paths map[plumbing.Hash]string
+}
+
+// NewCommitFollowIterFromIter returns a commit iterator returning the commits
+// of commitIter changing the file with the given path, or renaming it. The
+// renames are detected with the given options when the file appears in a
+// commit, its old path being followed in the parents of the commit. If opts
+// is nil, DefaultDiffTreeOptions is used.
+// The path of the file in each commit is tracked from its children, so the
+// commits must be walked before their parents.
+func NewCommitFollowIterFromIter(path string, commitIter CommitIter, opts *DiffTreeOptions) CommitFollowIter {
+	if opts == nil {
+		opts = DefaultDiffTreeOptions
+	}
+
+	return &commitFollowIter{
+		sourceIter: commitIter,
+		path:       path,
+		opts:       opts,
+		paths:      make(map[plumbing.Hash]string),
+	}
+}
+
+func (c *commitFollowIter) Next() (*Commit, error) {
+	for {
+		commit, err := c.sourceIter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		changed, err := c.follow(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		if changed {
+			return commit, nil
+		}
+	}
+}
+
+// follow sets the path of the file in the parents of commit, and returns
+// true if the commit changes the file compared to all its parents.
+func (c *commitFollowIter) follow(commit *Commit) (bool, error) {
+	path, ok := c.paths[commit.Hash]
+	if !ok {
+		path = c.path
+		c.paths[commit.Hash] = path
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return false, err
+	}
+
+	entry, err := followEntry(tree, path)
+	if err != nil {
+		return false, err
+	}
+
+	// the root commits change the file if they contain it
+	if commit.NumParents() == 0 {
+		return entry != nil, nil
+	}
+
+	var treesame bool
+	err = commit.Parents().ForEach(func(parent *Commit) error {
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+
+		parentEntry, err := followEntry(parentTree, path)
+		if err != nil {
+			return err
+		}
+
+		parentPath := path
+		switch {
+		case parentEntry == nil && entry == nil:
+			treesame = true
+		case parentEntry == nil:
+			if parentPath, err = c.renameSource(parentTree, tree, path); err != nil {
+				return err
+			}
+		case entry != nil && parentEntry.Hash == entry.Hash && parentEntry.Mode == entry.Mode:
+			treesame = true
+		}
+
+		if _, ok := c.paths[parent.Hash]; !ok {
+			c.paths[parent.Hash] = parentPath
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return !treesame, nil
+}
+
+// renameSource returns the path in the tree from of the file renamed, or
+// copied, to path in the tree to, or path if it was added.
+func (c *commitFollowIter) renameSource(from, to *Tree, path string) (string, error) {
+	changes, err := DiffTreeWithOptions(context.Background(), from, to, c.opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, change := range changes {
+		if change.To.Name == path && change.From.Name != "" {
+			return change.From.Name, nil
+		}
+	}
+
+	return path, nil
+}
+
+// followEntry returns the entry of the tree with the given path, or nil if
+// it does not exist.
+func followEntry(t *Tree, path string) (*TreeEntry, error) {
+	e, err := t.FindEntry(path)
+	if err == ErrEntryNotFound || err == ErrDirectoryNotFound {
+		return nil, nil
+	}
+
+	return e, err
+}
+
+func (c *commitFollowIter) Path(h plumbing.Hash) string {
+	return c.paths[h]
+}
+
+func (c *commitFollowIter) ForEach(cb func(*Commit) error) error {
+	for {
+		commit, nextErr := c.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+		err := cb(commit)
+		if err == storer.ErrStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *commitFollowIter) Close() {
+	c.sourceIter.Close()
+}
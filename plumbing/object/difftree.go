@@ -42,6 +42,23 @@ type DiffTreeOptions struct {
 	// OnlyExactRenames performs only detection of exact renames and will not perform
 	// any detection of renames based on file similarity.
 	OnlyExactRenames bool
+	// DetectCopies is whether the rename detection also detects the added
+	// files copied from a modified file, as `git diff -C`. The copies are
+	// found with the same RenameScore and RenameLimit as the renames.
+	DetectCopies bool
+	// FindCopiesHarder is whether the unchanged files are also sources of
+	// the copies, as `git diff --find-copies-harder`. It is expensive on
+	// large trees and it requires DetectCopies.
+	FindCopiesHarder bool
+	// BreakRewrites is whether the rename detection breaks the heavily
+	// modified files into a deletion and an insertion, as `git diff -B`, so
+	// their contents can be the source or the destination of a rename. The
+	// broken files that are not renamed are kept as modifications.
+	BreakRewrites bool
+	// BreakScore is the threshold of dissimilarity between the contents of
+	// a modified file to break it. The number must be exactly between 0 and
+	// 100, a value of 0 means the default of 50.
+	BreakScore uint
 }
 
 // DefaultDiffTreeOptions are the default and recommended options for the
@@ -91,7 +108,7 @@ func DiffTreeWithOptions(
 	}
 
 	if opts.DetectRenames {
-		return DetectRenames(changes, opts)
+		return DetectRenamesFromTree(changes, a, opts)
 	}
 
 	return changes, nil
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -21,12 +22,21 @@ var (
 	ErrCanceled = errors.New("operation canceled")
 )
 
+// PatchOptions are the options used to compute the line diffs of a Patch.
+type PatchOptions struct {
+	// Algorithm is the line diff algorithm, as the `diff.algorithm` config.
+	Algorithm diff.Algorithm
+	// IndentHeuristic shifts the groups of changed lines to ease reading
+	// the diff, as git does by default.
+	IndentHeuristic bool
+}
+
 func getPatch(message string, changes ...*Change) (*Patch, error) {
 	ctx := context.Background()
-	return getPatchContext(ctx, message, changes...)
+	return getPatchContext(ctx, message, nil, changes...)
 }
 
-func getPatchContext(ctx context.Context, message string, changes ...*Change) (*Patch, error) {
+func getPatchContext(ctx context.Context, message string, o *PatchOptions, changes ...*Change) (*Patch, error) {
 	var filePatches []fdiff.FilePatch
 	for _, c := range changes {
 		select {
@@ -35,7 +45,7 @@ func getPatchContext(ctx context.Context, message string, changes ...*Change) (*
 		default:
 		}
 
-		fp, err := filePatchWithContext(ctx, c)
+		fp, err := filePatchWithContext(ctx, c, o)
 		if err != nil {
 			return nil, err
 		}
@@ -46,7 +56,7 @@ func getPatchContext(ctx context.Context, message string, changes ...*Change) (*
 	return &Patch{message, filePatches}, nil
 }
 
-func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, error) {
+func filePatchWithContext(ctx context.Context, c *Change, o *PatchOptions) (fdiff.FilePatch, error) {
 	from, to, err := c.Files()
 	if err != nil {
 		return nil, err
@@ -62,10 +72,18 @@ func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, erro
 	}
 
 	if fIsBinary || tIsBinary {
-		return &textFilePatch{from: c.From, to: c.To}, nil
+		return &textFilePatch{from: c.From, to: c.To, copy: c.Copy}, nil
 	}
 
-	diffs := diff.Do(fromContent, toContent)
+	var diffs []dmp.Diff
+	if o == nil {
+		diffs = diff.Do(fromContent, toContent)
+	} else {
+		diffs = diff.DoWithOptions(fromContent, toContent, diff.Options{
+			Algorithm:       o.Algorithm,
+			IndentHeuristic: o.IndentHeuristic,
+		})
+	}
 
 	var chunks []fdiff.Chunk
 	for _, d := range diffs {
@@ -92,6 +110,7 @@ func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, erro
 		chunks: chunks,
 		from:   c.From,
 		to:     c.To,
+		copy:   c.Copy,
 	}, nil
 
 }
@@ -177,6 +196,7 @@ func (f *changeEntryWrapper) Empty() bool {
 type textFilePatch struct {
 	chunks   []fdiff.Chunk
 	from, to ChangeEntry
+	copy     bool
 }
 
 func (tf *textFilePatch) Files() (from fdiff.File, to fdiff.File) {
@@ -202,6 +222,10 @@ func (tf *textFilePatch) Chunks() []fdiff.Chunk {
 	return tf.chunks
 }
 
+func (tf *textFilePatch) Copied() bool {
+	return tf.copy
+}
+
 // textChunk is an implementation of fdiff.Chunk interface
 type textChunk struct {
 	content string
@@ -234,6 +258,173 @@ func (fileStats FileStats) String() string {
 	return printStat(fileStats)
 }
 
+// Numstat returns the stats as `git diff --numstat` does, the numbers of
+// added and deleted lines and the name of each file separated by tabs.
+func (fileStats FileStats) Numstat() string {
+	var sb strings.Builder
+	for _, fs := range fileStats {
+		fmt.Fprintf(&sb, "%d\t%d\t%s\n", fs.Addition, fs.Deletion, fs.Name)
+	}
+
+	return sb.String()
+}
+
+// Shortstat returns the total numbers of changed files, added and deleted
+// lines, as `git diff --shortstat` does.
+func (fileStats FileStats) Shortstat() string {
+	var insertions, deletions int
+	for _, fs := range fileStats {
+		insertions += fs.Addition
+		deletions += fs.Deletion
+	}
+
+	files := len(fileStats)
+	if files == 0 {
+		return " 0 files changed\n"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " %d %s changed", files, plural(files, "file", "files"))
+	if insertions != 0 || deletions == 0 {
+		fmt.Fprintf(&sb, ", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+
+	if deletions != 0 || insertions == 0 {
+		fmt.Fprintf(&sb, ", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func plural(n int, one, other string) string {
+	if n == 1 {
+		return one
+	}
+
+	return other
+}
+
+// DirstatOptions are the options of FileStats.Dirstat.
+type DirstatOptions struct {
+	// Files counts the changed files instead of the changed lines, as the
+	// `files` parameter of `git diff --dirstat`.
+	Files bool
+	// Cumulative counts the changes of the shown directories in their
+	// parent directories too.
+	Cumulative bool
+	// Limit is the minimum percentage of the changes of the shown
+	// directories, 3 if zero.
+	Limit float64
+}
+
+// Dirstat returns the percentage of the changes in each directory, as
+// `git diff --dirstat=lines` does. The changes of a directory are not
+// counted in its parent directories unless the stats are cumulative, and
+// the directories whose changes all come from a single subdirectory are
+// not shown.
+func (fileStats FileStats) Dirstat(o *DirstatOptions) string {
+	if o == nil {
+		o = &DirstatOptions{}
+	}
+
+	limit := o.Limit
+	if limit == 0 {
+		limit = 3
+	}
+
+	var files []dirstatFile
+	total := 0
+	for _, fs := range fileStats {
+		changes := fs.Addition + fs.Deletion
+		if o.Files {
+			changes = 1
+		}
+
+		if changes == 0 {
+			continue
+		}
+
+		name := fs.Name
+		if i := strings.Index(name, " => "); i >= 0 {
+			// the destination path of a renamed file
+			name = name[i+len(" => "):]
+		}
+
+		files = append(files, dirstatFile{name: name, changes: changes})
+		total += changes
+	}
+
+	if total == 0 {
+		return ""
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+
+	d := &dirstat{
+		files:      files,
+		total:      total,
+		permille:   int(limit * 10),
+		cumulative: o.Cumulative,
+	}
+
+	d.gather("")
+	return d.sb.String()
+}
+
+type dirstatFile struct {
+	name    string
+	changes int
+}
+
+type dirstat struct {
+	files      []dirstatFile
+	total      int
+	permille   int
+	cumulative bool
+	sb         strings.Builder
+}
+
+// gather writes the stats of the directory base and of its subdirectories,
+// and returns the changes to count in its parent directory.
+// Original implementation: https://github.com/git/git/blob/v2.39.0/diff.c#L2934
+func (d *dirstat) gather(base string) int {
+	changes, sources := 0, 0
+	for len(d.files) != 0 {
+		f := d.files[0]
+		if !strings.HasPrefix(f.name, base) {
+			break
+		}
+
+		if slash := strings.IndexByte(f.name[len(base):], '/'); slash >= 0 {
+			changes += d.gather(f.name[:len(base)+slash+1])
+			sources++
+		} else {
+			changes += f.changes
+			d.files = d.files[1:]
+			sources += 2
+		}
+	}
+
+	// the top level is not shown, neither the directories whose changes
+	// come from a single subdirectory
+	if base == "" || sources == 1 || changes == 0 {
+		return changes
+	}
+
+	permille := changes * 1000 / d.total
+	if permille < d.permille {
+		return changes
+	}
+
+	fmt.Fprintf(&d.sb, "%4d.%01d%% %s\n", permille/10, permille%10, base)
+	if !d.cumulative {
+		return 0
+	}
+
+	return changes
+}
+
 // printStat prints the stats of changes in content of files.
 // Original implementation: https://github.com/git/git/blob/1a87c842ece327d03d08096395969aca5e0a6996/diff.c#L2615
 // Parts of the output:
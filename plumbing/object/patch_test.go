@@ -155,3 +155,59 @@ func (s *PatchSuite) TestFileStatsString(c *C) {
 		c.Assert(printStat(tc.input), Equals, tc.expected)
 	}
 }
+
+func (s *PatchSuite) TestFileStatsNumstat(c *C) {
+	stats := FileStats{
+		{Name: "README.md", Addition: 3, Deletion: 1},
+		{Name: "old.go => new.go", Addition: 0, Deletion: 2},
+	}
+
+	c.Assert(stats.Numstat(), Equals, "3\t1\tREADME.md\n0\t2\told.go => new.go\n")
+}
+
+func (s *PatchSuite) TestFileStatsShortstat(c *C) {
+	testCases := []struct {
+		input    FileStats
+		expected string
+	}{
+		{nil, " 0 files changed\n"},
+		{FileStats{{Name: "a", Addition: 1}}, " 1 file changed, 1 insertion(+)\n"},
+		{FileStats{{Name: "a", Deletion: 2}}, " 1 file changed, 2 deletions(-)\n"},
+		{FileStats{{Name: "a"}}, " 1 file changed, 0 insertions(+), 0 deletions(-)\n"},
+		{
+			FileStats{{Name: "a", Addition: 2, Deletion: 1}, {Name: "b", Addition: 1}},
+			" 2 files changed, 3 insertions(+), 1 deletion(-)\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		c.Assert(tc.input.Shortstat(), Equals, tc.expected)
+	}
+}
+
+func (s *PatchSuite) TestFileStatsDirstat(c *C) {
+	stats := FileStats{
+		{Name: "README.md", Addition: 10},
+		{Name: "a/x.go", Addition: 10, Deletion: 10},
+		{Name: "a/b/y.go", Addition: 5},
+		{Name: "a/b/z.go", Deletion: 5},
+		{Name: "c/d/e/w.go", Addition: 55, Deletion: 5},
+		{Name: "f/old.go => g/new.go", Addition: 1},
+	}
+
+	testCases := []struct {
+		options  *DirstatOptions
+		expected string
+	}{
+		{nil, "   9.9% a/b/\n  19.8% a/\n  59.4% c/d/e/\n"},
+		{&DirstatOptions{Cumulative: true}, "   9.9% a/b/\n  29.7% a/\n  59.4% c/d/e/\n"},
+		{&DirstatOptions{Limit: 10}, "  29.7% a/\n  59.4% c/d/e/\n"},
+		{&DirstatOptions{Files: true, Limit: 10}, "  33.3% a/b/\n  16.6% a/\n  16.6% c/d/e/\n  16.6% g/\n"},
+	}
+
+	for _, tc := range testCases {
+		c.Assert(stats.Dirstat(tc.options), Equals, tc.expected)
+	}
+
+	c.Assert(FileStats{}.Dirstat(nil), Equals, "")
+}
@@ -19,6 +19,19 @@ import (
 func DetectRenames(
 	changes Changes,
 	opts *DiffTreeOptions,
+) (Changes, error) {
+	return DetectRenamesFromTree(changes, nil, opts)
+}
+
+// DetectRenamesFromTree detects the renames in the given changes between the
+// tree from and another tree, as DetectRenames. If copies are detected with
+// FindCopiesHarder, the files of from not changed are also the sources of the
+// copies. The tree from may be nil.
+// If options is nil, the default diff tree options will be used.
+func DetectRenamesFromTree(
+	changes Changes,
+	from *Tree,
+	opts *DiffTreeOptions,
 ) (Changes, error) {
 	if opts == nil {
 		opts = DefaultDiffTreeOptions
@@ -28,6 +41,14 @@ func DetectRenames(
 		renameScore: int(opts.RenameScore),
 		renameLimit: int(opts.RenameLimit),
 		onlyExact:   opts.OnlyExactRenames,
+		findCopies:  opts.DetectCopies,
+	}
+
+	if opts.BreakRewrites {
+		detector.breakScore = int(opts.BreakScore)
+		if detector.breakScore == 0 {
+			detector.breakScore = defaultBreakScore
+		}
 	}
 
 	for _, c := range changes {
@@ -46,19 +67,56 @@ func DetectRenames(
 		}
 	}
 
+	if opts.DetectCopies && opts.FindCopiesHarder && from != nil {
+		changed := make(map[string]bool, len(changes))
+		for _, c := range changes {
+			changed[c.From.Name] = true
+		}
+
+		err := walkTreeEntries(from, "", func(e ChangeEntry) {
+			if !changed[e.Name] {
+				detector.unchanged = append(detector.unchanged, &Change{From: e})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return detector.detect()
 }
 
+const (
+	// defaultBreakScore is the default dissimilarity threshold to break the
+	// modified files, as the one of git.
+	defaultBreakScore = 50
+	// minBreakSize is the size under which the modified files are not
+	// broken, as the one of git.
+	minBreakSize = 400
+)
+
 // renameDetector will detect and resolve renames in a set of changes.
 // see: https://github.com/eclipse/jgit/blob/master/org.eclipse.jgit/src/org/eclipse/jgit/diff/RenameDetector.java
 type renameDetector struct {
 	added    []*Change
 	deleted  []*Change
 	modified []*Change
+	// unchanged are the files not changed, as deletions, used as sources
+	// of the copies.
+	unchanged []*Change
+	// broken are the modifications broken into a deletion and an insertion.
+	broken []brokenChange
 
 	renameScore int
 	renameLimit int
 	onlyExact   bool
+	findCopies  bool
+	breakScore  int
+}
+
+// brokenChange is a modification broken into a deletion and an insertion.
+type brokenChange struct {
+	change, deleted, added *Change
 }
 
 // detectExactRenames detects matches files that were deleted with files that
@@ -244,7 +302,158 @@ func (d *renameDetector) detectContentRenames() error {
 	return nil
 }
 
+// breakRewrites breaks the modifications of regular files whose contents are
+// more dissimilar than the break score into a deletion and an insertion.
+// see: https://github.com/git/git/blob/v2.39.0/diffcore-break.c
+func (d *renameDetector) breakRewrites() error {
+	var modified []*Change
+	for _, c := range d.modified {
+		broken, err := d.isRewrite(c)
+		if err != nil {
+			return err
+		}
+
+		if !broken {
+			modified = append(modified, c)
+			continue
+		}
+
+		b := brokenChange{change: c, deleted: &Change{From: c.From}, added: &Change{To: c.To}}
+		d.broken = append(d.broken, b)
+		d.deleted = append(d.deleted, b.deleted)
+		d.added = append(d.added, b.added)
+	}
+
+	d.modified = modified
+	return nil
+}
+
+func (d *renameDetector) isRewrite(c *Change) (bool, error) {
+	if c.From.Name != c.To.Name || c.From.TreeEntry.Hash == c.To.TreeEntry.Hash ||
+		c.From.TreeEntry.Mode != filemode.Regular || c.To.TreeEntry.Mode != filemode.Regular {
+		return false, nil
+	}
+
+	from, to, err := c.Files()
+	if err != nil {
+		return false, err
+	}
+
+	if max(int(from.Size), int(to.Size)) < minBreakSize {
+		return false, nil
+	}
+
+	s, err := fileSimilarityIndex(from)
+	if err == errIndexFull {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	di, err := fileSimilarityIndex(to)
+	if err == errIndexFull {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return 100-s.score(di, 100) >= d.breakScore, nil
+}
+
+// mergeBroken merges back the broken modifications whose deletion and
+// insertion were both not renamed.
+func (d *renameDetector) mergeBroken() {
+	for _, b := range d.broken {
+		i := indexOfChange(d.deleted, b.deleted)
+		j := indexOfChange(d.added, b.added)
+		if i < 0 || j < 0 {
+			continue
+		}
+
+		d.deleted = append(d.deleted[:i], d.deleted[i+1:]...)
+		d.added = append(d.added[:j], d.added[j+1:]...)
+		d.modified = append(d.modified, b.change)
+	}
+}
+
+// detectCopies detects the added files that are copies of the sources of
+// the modifications and renames, or of the unchanged files. Unlike renames,
+// a source may be copied to several files.
+func (d *renameDetector) detectCopies() error {
+	srcs := make([]*Change, 0, len(d.modified)+len(d.unchanged))
+	for _, c := range d.modified {
+		srcs = append(srcs, &Change{From: c.From})
+	}
+	srcs = append(srcs, d.unchanged...)
+
+	if len(srcs) == 0 {
+		return nil
+	}
+
+	copies := d.detectExactCopies(srcs)
+	if !d.onlyExact && len(d.added) > 0 {
+		cnt := max(len(d.added), len(srcs))
+		if d.renameLimit == 0 || cnt <= d.renameLimit {
+			dsts := d.added
+			matrix, err := buildSimilarityMatrix(srcs, dsts, d.renameScore)
+			if err != nil {
+				return err
+			}
+
+			for i := len(matrix) - 1; i >= 0; i-- {
+				pair := matrix[i]
+				dst := dsts[pair.added]
+				if dst == nil {
+					// It was already matched before
+					continue
+				}
+
+				copies = append(copies, &Change{From: srcs[pair.deleted].From, To: dst.To, Copy: true})
+				dsts[pair.added] = nil
+			}
+
+			d.added = compactChanges(dsts)
+		}
+	}
+
+	d.modified = append(d.modified, copies...)
+	return nil
+}
+
+// detectExactCopies detects the added files with the same content and mode
+// as a source, the source with the most similar path being chosen.
+func (d *renameDetector) detectExactCopies(srcs []*Change) []*Change {
+	bySrcHash := groupChangesByHash(srcs)
+
+	var copies, addedLeft []*Change
+	for _, c := range d.added {
+		var candidates []*Change
+		for _, src := range bySrcHash[changeHash(c)] {
+			if sameMode(c, src) {
+				candidates = append(candidates, src)
+			}
+		}
+
+		if src := bestNameMatch(c, candidates); src != nil {
+			copies = append(copies, &Change{From: src.From, To: c.To, Copy: true})
+		} else if len(candidates) > 0 {
+			copies = append(copies, &Change{From: candidates[0].From, To: c.To, Copy: true})
+		} else {
+			addedLeft = append(addedLeft, c)
+		}
+	}
+
+	d.added = addedLeft
+	return copies
+}
+
 func (d *renameDetector) detect() (Changes, error) {
+	if d.breakScore > 0 {
+		if err := d.breakRewrites(); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(d.added) > 0 && len(d.deleted) > 0 {
 		d.detectExactRenames()
 
@@ -255,6 +464,16 @@ func (d *renameDetector) detect() (Changes, error) {
 		}
 	}
 
+	if d.breakScore > 0 {
+		d.mergeBroken()
+	}
+
+	if d.findCopies && len(d.added) > 0 {
+		if err := d.detectCopies(); err != nil {
+			return nil, err
+		}
+	}
+
 	result := make(Changes, 0, len(d.added)+len(d.deleted)+len(d.modified))
 	result = append(result, d.added...)
 	result = append(result, d.deleted...)
@@ -520,6 +739,44 @@ outerLoop:
 	return matrix, nil
 }
 
+func indexOfChange(changes []*Change, c *Change) int {
+	for i, o := range changes {
+		if o == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// walkTreeEntries calls fn with the files of the tree t, whose path is
+// dir, and of its subtrees.
+func walkTreeEntries(t *Tree, dir string, fn func(ChangeEntry)) error {
+	for _, e := range t.Entries {
+		name := e.Name
+		if dir != "" {
+			name = dir + "/" + e.Name
+		}
+
+		switch e.Mode {
+		case filemode.Dir:
+			sub, err := GetTree(t.s, e.Hash)
+			if err != nil {
+				return err
+			}
+
+			if err := walkTreeEntries(sub, name, fn); err != nil {
+				return err
+			}
+		case filemode.Submodule:
+		default:
+			fn(ChangeEntry{Name: name, Tree: t, TreeEntry: e})
+		}
+	}
+
+	return nil
+}
+
 func compactChanges(changes []*Change) []*Change {
 	var result []*Change
 	for _, c := range changes {
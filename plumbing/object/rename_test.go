@@ -1,6 +1,7 @@
 package object
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -297,6 +298,124 @@ func (s *RenameSuite) TestRenameExactManyAddsManyDeletesNoGaps(c *C) {
 	}
 }
 
+func (s *RenameSuite) TestExactCopy_FromModified(c *C) {
+	m := makeChange(c,
+		makeFile(c, pathA, filemode.Regular, "foo"),
+		makeFile(c, pathA, filemode.Regular, "bar"),
+	)
+	a := makeAdd(c, makeFile(c, pathB, filemode.Regular, "foo"))
+
+	result := detectRenames(c, Changes{m, a}, &DiffTreeOptions{DetectCopies: true}, 2)
+	c.Assert(result[0], DeepEquals, m)
+	assertCopy(c, m, a, result[1])
+
+	result = detectRenames(c, Changes{m, a}, nil, 2)
+	c.Assert(result[0], DeepEquals, m)
+	c.Assert(result[1], DeepEquals, a)
+}
+
+func (s *RenameSuite) TestExactCopy_FromRenamed(c *C) {
+	d := makeDelete(c, makeFile(c, pathA, filemode.Regular, "foo"))
+	b := makeAdd(c, makeFile(c, pathB, filemode.Regular, "foo"))
+	q := makeAdd(c, makeFile(c, "other/Q", filemode.Regular, "foo"))
+
+	result := detectRenames(c, Changes{d, b, q}, &DiffTreeOptions{DetectCopies: true}, 2)
+	assertRename(c, d, b, result[0])
+	assertCopy(c, d, q, result[1])
+}
+
+func (s *RenameSuite) TestContentCopy_FromModified(c *C) {
+	m := makeChange(c,
+		makeFile(c, pathA, filemode.Regular, "a\nb\nc\nd\n"),
+		makeFile(c, pathA, filemode.Regular, "x\n"),
+	)
+	a := makeAdd(c, makeFile(c, pathB, filemode.Regular, "a\nb\nc\nd\ne\n"))
+	h := makeAdd(c, makeFile(c, pathH, filemode.Regular, "y\nz\n"))
+
+	opts := &DiffTreeOptions{RenameScore: 60, DetectCopies: true}
+	result := detectRenames(c, Changes{m, a, h}, opts, 3)
+	c.Assert(result[0], DeepEquals, m)
+	assertCopy(c, m, a, result[1])
+	c.Assert(result[2], DeepEquals, h)
+
+	opts.OnlyExactRenames = true
+	result = detectRenames(c, Changes{m, a, h}, opts, 3)
+	c.Assert(result[0], DeepEquals, m)
+	c.Assert(result[1], DeepEquals, a)
+	c.Assert(result[2], DeepEquals, h)
+}
+
+func (s *RenameSuite) TestCopy_FindCopiesHarder(c *C) {
+	sto := memory.NewStorage()
+	unchanged := makeFile(c, "h.txt", filemode.Regular, "foo\nbar\n")
+	_, err := sto.SetEncodedObject(unchanged.obj)
+	c.Assert(err, IsNil)
+
+	sub := &Tree{Entries: []TreeEntry{{Name: "h.txt", Mode: filemode.Regular, Hash: unchanged.Hash}}, s: sto}
+	obj := sto.NewEncodedObject()
+	c.Assert(sub.Encode(obj), IsNil)
+	sub.Hash, err = sto.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	tree := &Tree{Entries: []TreeEntry{{Name: "dir", Mode: filemode.Dir, Hash: sub.Hash}}, s: sto}
+	a := makeAdd(c, makeFile(c, pathB, filemode.Regular, "foo\nbar\n"))
+
+	opts := &DiffTreeOptions{RenameScore: 60, DetectCopies: true}
+	result, err := DetectRenamesFromTree(Changes{a}, tree, opts)
+	c.Assert(err, IsNil)
+	c.Assert(result, HasLen, 1)
+	c.Assert(result[0], DeepEquals, a)
+
+	opts.FindCopiesHarder = true
+	result, err = DetectRenamesFromTree(Changes{a}, tree, opts)
+	c.Assert(err, IsNil)
+	c.Assert(result, HasLen, 1)
+	c.Assert(result[0].Copy, Equals, true)
+	c.Assert(result[0].From.Name, Equals, "dir/h.txt")
+	c.Assert(result[0].To, DeepEquals, a.To)
+
+	from, _, err := result[0].Files()
+	c.Assert(err, IsNil)
+	c.Assert(from.Hash, Equals, unchanged.Hash)
+}
+
+func (s *RenameSuite) TestBreakRewrites(c *C) {
+	var old, rewritten strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&old, "line %d\n", i)
+		fmt.Fprintf(&rewritten, "other line %d\n", i)
+	}
+
+	m := makeChange(c,
+		makeFile(c, pathA, filemode.Regular, old.String()),
+		makeFile(c, pathA, filemode.Regular, rewritten.String()),
+	)
+	d := makeDelete(c, makeFile(c, pathB, filemode.Regular, rewritten.String()))
+
+	result := detectRenames(c, Changes{m, d}, nil, 2)
+	c.Assert(result[0], DeepEquals, m)
+	c.Assert(result[1], DeepEquals, d)
+
+	opts := &DiffTreeOptions{RenameScore: 60, BreakRewrites: true}
+	result = detectRenames(c, Changes{m, d}, opts, 2)
+	c.Assert(result[0], DeepEquals, &Change{From: m.From})
+	assertRename(c, d, m, result[1])
+
+	// the broken files not renamed are merged back
+	result = detectRenames(c, Changes{m}, opts, 1)
+	c.Assert(result[0], Equals, m)
+
+	// the small files are not broken
+	small := makeChange(c,
+		makeFile(c, pathA, filemode.Regular, "foo\n"),
+		makeFile(c, pathA, filemode.Regular, "bar\n"),
+	)
+	d = makeDelete(c, makeFile(c, pathB, filemode.Regular, "bar\n"))
+	result = detectRenames(c, Changes{small, d}, opts, 2)
+	c.Assert(result[0], DeepEquals, small)
+	c.Assert(result[1], DeepEquals, d)
+}
+
 func detectRenames(c *C, changes Changes, opts *DiffTreeOptions, expectedResults int) Changes {
 	result, err := DetectRenames(changes, opts)
 	c.Assert(err, IsNil)
@@ -308,6 +427,10 @@ func assertRename(c *C, from, to *Change, rename *Change) {
 	c.Assert(&Change{From: from.From, To: to.To}, DeepEquals, rename)
 }
 
+func assertCopy(c *C, from, to *Change, cp *Change) {
+	c.Assert(&Change{From: from.From, To: to.To, Copy: true}, DeepEquals, cp)
+}
+
 type SimilarityIndexSuite struct {
 	BaseObjectsSuite
 }
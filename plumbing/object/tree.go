@@ -372,6 +372,18 @@ func (t *Tree) PatchContext(ctx context.Context, to *Tree) (*Patch, error) {
 	return changes.PatchContext(ctx)
 }
 
+// PatchWithOptions returns a Patch with all the changes between trees,
+// computed with the given options. If context expires, an error will be
+// returned. Provided context must be non-nil.
+func (t *Tree) PatchWithOptions(ctx context.Context, to *Tree, o *PatchOptions) (*Patch, error) {
+	changes, err := t.DiffContext(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes.PatchWithOptions(ctx, o)
+}
+
 // treeEntryIter facilitates iterating through the TreeEntry objects in a Tree.
 type treeEntryIter struct {
 	t   *Tree
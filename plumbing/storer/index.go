@@ -1,9 +1,39 @@
 package storer
 
-import "github.com/go-git/go-git/v5/plumbing/format/index"
+import (
+	"errors"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// ErrIndexHasChanged is returned by CheckAndSetIndex when the stored index
+// is not the one that was read.
+var ErrIndexHasChanged = errors.New("index has changed concurrently")
 
 // IndexStorer generic storage of index.Index
 type IndexStorer interface {
 	SetIndex(*index.Index) error
 	Index() (*index.Index, error)
 }
+
+// IndexModTimeStorer is an optional interface of the IndexStorer returning
+// the time the index was last written, or the zero time if unknown. The
+// entries modified at or after it are racily clean: their stat data can not
+// be trusted.
+type IndexModTimeStorer interface {
+	IndexModTime() (time.Time, error)
+}
+
+// IndexCheckAndSetStorer is an optional interface of the IndexStorer writing
+// the index only if it was not written since it was read, so the changes
+// made concurrently are not lost.
+type IndexCheckAndSetStorer interface {
+	// IndexChecksum returns the checksum of the stored index, or the zero
+	// hash if there is none.
+	IndexChecksum() (plumbing.Hash, error)
+	// CheckAndSetIndex writes idx if the checksum of the stored index is
+	// old, returning ErrIndexHasChanged otherwise.
+	CheckAndSetIndex(idx *index.Index, old plumbing.Hash) error
+}
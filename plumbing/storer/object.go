@@ -88,6 +88,22 @@ type PackedObjectStorer interface {
 	DeleteOldObjectPackAndIndex(plumbing.Hash, time.Time) error
 }
 
+// CruftPackStorer is an optional interface for PackedObjectStorer, it
+// enables keeping the unreachable objects in cruft packs, along with their
+// modification time.
+type CruftPackStorer interface {
+	// ForEachObjectPackTime iterates over all the objects in the given pack,
+	// along with their modification time. Objects in cruft packs get the time
+	// recorded in the pack mtimes file, while the objects of any other pack
+	// get the modification time of the pack itself.
+	// If ErrStop is sent the iteration is stop but no error is returned.
+	ForEachObjectPackTime(plumbing.Hash, func(plumbing.Hash, time.Time) error) error
+	// CruftPackfileWriter returns a writer for a cruft pack. Along with the
+	// packfile, the storage writes the modification time of each object, as
+	// returned by the given function.
+	CruftPackfileWriter(func(plumbing.Hash) time.Time) (io.WriteCloser, error)
+}
+
 // PackfileWriter is an optional method for ObjectStorer, it enables directly writing
 // a packfile to storage.
 type PackfileWriter interface {
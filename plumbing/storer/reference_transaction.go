@@ -0,0 +1,201 @@
+package storer
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	// ErrReferenceHasChanged is returned when a reference does not have the
+	// expected value while being updated.
+	ErrReferenceHasChanged = errors.New("reference has changed concurrently")
+	// ErrTransactionClosed is returned when using a ReferenceTransaction
+	// already committed or aborted.
+	ErrTransactionClosed = errors.New("reference transaction already closed")
+	// ErrDuplicateReferenceUpdate is returned when queuing more than one
+	// update for the same reference in a ReferenceTransaction.
+	ErrDuplicateReferenceUpdate = errors.New("multiple updates for the same reference")
+)
+
+// ReferenceTransaction is a set of reference updates applied atomically,
+// either all of them are applied or none is.
+//
+// The updates are only queued until Commit is called, which checks the
+// expected values of all the references before updating any of them.
+type ReferenceTransaction interface {
+	// Create queues the creation of the given reference, which must not
+	// exist when the transaction is committed.
+	Create(ref *plumbing.Reference) error
+	// Update queues the update of the given reference. If old is not nil,
+	// the reference must exist and have its value when the transaction is
+	// committed.
+	Update(ref, old *plumbing.Reference) error
+	// Delete queues the removal of the reference with the given name. If
+	// old is not nil, the reference must exist and have its value when the
+	// transaction is committed.
+	Delete(name plumbing.ReferenceName, old *plumbing.Reference) error
+	// Commit applies the queued updates. ErrReferenceHasChanged is returned,
+	// and no reference is updated, if any reference does not have the
+	// expected value.
+	Commit() error
+	// Abort discards the queued updates.
+	Abort() error
+}
+
+// ReferenceTransactioner is implemented by the reference storers able to
+// update several references atomically.
+type ReferenceTransactioner interface {
+	// BeginReferenceTransaction starts a new ReferenceTransaction.
+	BeginReferenceTransaction() (ReferenceTransaction, error)
+}
+
+// ReferenceUpdate is an update of a reference queued in a
+// ReferenceTransaction.
+type ReferenceUpdate struct {
+	// Name is the name of the updated reference.
+	Name plumbing.ReferenceName
+	// New is the new value of the reference, nil for deletions.
+	New *plumbing.Reference
+	// Old is the expected value of the reference, nil if not checked.
+	Old *plumbing.Reference
+	// MustNotExist is set when creating a reference.
+	MustNotExist bool
+}
+
+// IsDelete returns true if the update removes the reference.
+func (u *ReferenceUpdate) IsDelete() bool {
+	return u.New == nil
+}
+
+// Check returns ErrReferenceHasChanged if the current value of the
+// reference, nil if it does not exist, is not the expected one.
+func (u *ReferenceUpdate) Check(current *plumbing.Reference) error {
+	if u.MustNotExist && current != nil {
+		return ErrReferenceHasChanged
+	}
+
+	if u.Old == nil {
+		return nil
+	}
+
+	if current == nil || current.Type() != u.Old.Type() {
+		return ErrReferenceHasChanged
+	}
+
+	if current.Hash() != u.Old.Hash() || current.Target() != u.Old.Target() {
+		return ErrReferenceHasChanged
+	}
+
+	return nil
+}
+
+// NewReferenceTransaction returns a ReferenceTransaction queuing the updates
+// in memory, which are passed, sorted by name, to the given commit function
+// once the transaction is committed. The commit function is responsible for
+// checking and applying the updates atomically.
+func NewReferenceTransaction(commit func([]*ReferenceUpdate) error) ReferenceTransaction {
+	return &referenceTransaction{
+		commit: commit,
+		names:  make(map[plumbing.ReferenceName]bool),
+	}
+}
+
+// BeginReferenceTransaction starts a new ReferenceTransaction for the given
+// storer. If the storer is not a ReferenceTransactioner, the updates are
+// checked before applying any of them, but they are applied one by one, so
+// the transaction is not atomic regarding concurrent changes.
+func BeginReferenceTransaction(s ReferenceStorer) (ReferenceTransaction, error) {
+	if t, ok := s.(ReferenceTransactioner); ok {
+		return t.BeginReferenceTransaction()
+	}
+
+	return NewReferenceTransaction(func(updates []*ReferenceUpdate) error {
+		for _, u := range updates {
+			current, err := s.Reference(u.Name)
+			if err != nil && err != plumbing.ErrReferenceNotFound {
+				return err
+			}
+
+			if err := u.Check(current); err != nil {
+				return err
+			}
+		}
+
+		for _, u := range updates {
+			var err error
+			if u.IsDelete() {
+				err = s.RemoveReference(u.Name)
+			} else {
+				err = s.CheckAndSetReference(u.New, u.Old)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}), nil
+}
+
+type referenceTransaction struct {
+	commit  func([]*ReferenceUpdate) error
+	updates []*ReferenceUpdate
+	names   map[plumbing.ReferenceName]bool
+	closed  bool
+}
+
+func (t *referenceTransaction) Create(ref *plumbing.Reference) error {
+	return t.queue(&ReferenceUpdate{Name: ref.Name(), New: ref, MustNotExist: true})
+}
+
+func (t *referenceTransaction) Update(ref, old *plumbing.Reference) error {
+	return t.queue(&ReferenceUpdate{Name: ref.Name(), New: ref, Old: old})
+}
+
+func (t *referenceTransaction) Delete(name plumbing.ReferenceName, old *plumbing.Reference) error {
+	return t.queue(&ReferenceUpdate{Name: name, Old: old})
+}
+
+func (t *referenceTransaction) queue(u *ReferenceUpdate) error {
+	if t.closed {
+		return ErrTransactionClosed
+	}
+
+	if t.names[u.Name] {
+		return ErrDuplicateReferenceUpdate
+	}
+
+	t.names[u.Name] = true
+	t.updates = append(t.updates, u)
+	return nil
+}
+
+func (t *referenceTransaction) Commit() error {
+	if t.closed {
+		return ErrTransactionClosed
+	}
+
+	t.closed = true
+	if len(t.updates) == 0 {
+		return nil
+	}
+
+	sort.Slice(t.updates, func(i, j int) bool {
+		return t.updates[i].Name < t.updates[j].Name
+	})
+
+	return t.commit(t.updates)
+}
+
+func (t *referenceTransaction) Abort() error {
+	if t.closed {
+		return ErrTransactionClosed
+	}
+
+	t.closed = true
+	t.updates = nil
+	return nil
+}
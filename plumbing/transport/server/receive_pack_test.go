@@ -5,7 +5,9 @@ import (
 
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
 
 	fixtures "github.com/go-git/go-git-fixtures/v4"
 	. "gopkg.in/check.v1"
@@ -62,3 +64,75 @@ func (s *ReceivePackSuite) TestReceivePackWithNilPackfile(c *C) {
 	c.Assert(report, IsNil, comment)
 	c.Assert(err, NotNil, comment)
 }
+
+func (s *ReceivePackSuite) TestReceivePackAtomic(c *C) {
+	fixture := fixtures.Basic().ByTag("packfile").One()
+	head := plumbing.NewHash(fixture.Head)
+
+	req := packp.NewReferenceUpdateRequest()
+	c.Assert(req.Capabilities.Set(capability.ReportStatus), IsNil)
+	c.Assert(req.Capabilities.Set(capability.Atomic), IsNil)
+	req.Commands = []*packp.Command{
+		{Name: "refs/heads/newbranch", Old: plumbing.ZeroHash, New: head},
+		{Name: "refs/heads/missing", Old: head, New: plumbing.ZeroHash},
+	}
+
+	r, err := s.Client.NewReceivePackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	report, err := r.ReceivePack(context.Background(), req)
+	c.Assert(err, Equals, server.ErrUpdateReference)
+	c.Assert(report, NotNil)
+
+	statuses := make(map[plumbing.ReferenceName]string)
+	for _, cs := range report.CommandStatuses {
+		statuses[cs.ReferenceName] = cs.Status
+	}
+
+	c.Assert(statuses, DeepEquals, map[plumbing.ReferenceName]string{
+		"refs/heads/newbranch": server.ErrAtomicPushFailed.Error(),
+		"refs/heads/missing":   server.ErrUpdateReference.Error(),
+	})
+
+	_, err = s.loader[s.Endpoint.String()].Reference("refs/heads/newbranch")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *ReceivePackSuite) TestReceivePackNonAtomicStaleReference(c *C) {
+	fixture := fixtures.Basic().ByTag("packfile").One()
+	head := plumbing.NewHash(fixture.Head)
+	stale := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	req := packp.NewReferenceUpdateRequest()
+	c.Assert(req.Capabilities.Set(capability.ReportStatus), IsNil)
+	req.Commands = []*packp.Command{
+		{Name: "refs/heads/newbranch", Old: plumbing.ZeroHash, New: head},
+		{Name: "refs/heads/master", Old: stale, New: plumbing.ZeroHash},
+	}
+
+	r, err := s.Client.NewReceivePackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	report, err := r.ReceivePack(context.Background(), req)
+	c.Assert(err, NotNil)
+	c.Assert(report, NotNil)
+
+	statuses := make(map[plumbing.ReferenceName]string)
+	for _, cs := range report.CommandStatuses {
+		statuses[cs.ReferenceName] = cs.Status
+	}
+
+	c.Assert(statuses["refs/heads/newbranch"], Equals, "ok")
+	c.Assert(statuses["refs/heads/master"], Not(Equals), "ok")
+
+	sto := s.loader[s.Endpoint.String()]
+	ref, err := sto.Reference("refs/heads/newbranch")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, head)
+
+	ref, err = sto.Reference("refs/heads/master")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, head)
+}
@@ -233,6 +233,9 @@ func (s *rpSession) AdvertisedReferencesContext(ctx context.Context) (*packp.Adv
 
 var (
 	ErrUpdateReference = errors.New("failed to update ref")
+	// ErrAtomicPushFailed is the status of the commands not applied because
+	// another command of an atomic push failed.
+	ErrAtomicPushFailed = errors.New("atomic push failure")
 )
 
 func (s *rpSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateRequest) (*packp.ReportStatus, error) {
@@ -249,8 +252,6 @@ func (s *rpSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateR
 
 	s.caps = req.Capabilities
 
-	//TODO: Implement 'atomic' update of references.
-
 	if req.Packfile != nil {
 		r := ioutil.NewContextReadCloser(ctx, req.Packfile)
 		if err := s.writePackfile(r); err != nil {
@@ -264,41 +265,97 @@ func (s *rpSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateR
 	return s.reportStatus(), s.firstErr
 }
 
+// updateReferences applies the commands in a single reference transaction if
+// the atomic capability was requested, so no reference is updated if any of
+// them fails, or each command in its own transaction otherwise.
 func (s *rpSession) updateReferences(req *packp.ReferenceUpdateRequest) {
-	for _, cmd := range req.Commands {
-		exists, err := referenceExists(s.storer, cmd.Name)
-		if err != nil {
-			s.setStatus(cmd.Name, err)
-			continue
+	if !s.caps.Supports(capability.Atomic) {
+		for _, cmd := range req.Commands {
+			s.setStatus(cmd.Name, s.updateReference(cmd))
 		}
 
-		switch cmd.Action() {
-		case packp.Create:
-			if exists {
-				s.setStatus(cmd.Name, ErrUpdateReference)
-				continue
-			}
+		return
+	}
 
-			ref := plumbing.NewHashReference(cmd.Name, cmd.New)
-			err := s.storer.SetReference(ref)
+	tx, err := storer.BeginReferenceTransaction(s.storer)
+	if err != nil {
+		for _, cmd := range req.Commands {
 			s.setStatus(cmd.Name, err)
-		case packp.Delete:
-			if !exists {
-				s.setStatus(cmd.Name, ErrUpdateReference)
-				continue
-			}
+		}
 
-			err := s.storer.RemoveReference(cmd.Name)
-			s.setStatus(cmd.Name, err)
-		case packp.Update:
-			if !exists {
-				s.setStatus(cmd.Name, ErrUpdateReference)
-				continue
-			}
-
-			ref := plumbing.NewHashReference(cmd.Name, cmd.New)
-			err := s.storer.SetReference(ref)
+		return
+	}
+
+	var queued []plumbing.ReferenceName
+	for _, cmd := range req.Commands {
+		if err := s.queueCommand(tx, cmd); err != nil {
 			s.setStatus(cmd.Name, err)
+			_ = tx.Abort()
+			s.failAtomicPush(req)
+			return
+		}
+
+		queued = append(queued, cmd.Name)
+	}
+
+	err = tx.Commit()
+	for _, name := range queued {
+		s.setStatus(name, err)
+	}
+}
+
+// updateReference applies the command in its own reference transaction.
+func (s *rpSession) updateReference(cmd *packp.Command) error {
+	tx, err := storer.BeginReferenceTransaction(s.storer)
+	if err != nil {
+		return err
+	}
+
+	if err := s.queueCommand(tx, cmd); err != nil {
+		_ = tx.Abort()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *rpSession) queueCommand(tx storer.ReferenceTransaction, cmd *packp.Command) error {
+	exists, err := referenceExists(s.storer, cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	ref := plumbing.NewHashReference(cmd.Name, cmd.New)
+	old := plumbing.NewHashReference(cmd.Name, cmd.Old)
+	switch cmd.Action() {
+	case packp.Create:
+		if exists {
+			return ErrUpdateReference
+		}
+
+		return tx.Create(ref)
+	case packp.Delete:
+		if !exists {
+			return ErrUpdateReference
+		}
+
+		return tx.Delete(cmd.Name, old)
+	case packp.Update:
+		if !exists {
+			return ErrUpdateReference
+		}
+
+		return tx.Update(ref, old)
+	}
+
+	return ErrUpdateReference
+}
+
+// failAtomicPush sets the status of the commands not failed yet.
+func (s *rpSession) failAtomicPush(req *packp.ReferenceUpdateRequest) {
+	for _, cmd := range req.Commands {
+		if _, ok := s.cmdStatus[cmd.Name]; !ok {
+			s.setStatus(cmd.Name, ErrAtomicPushFailed)
 		}
 	}
 }
@@ -367,6 +424,10 @@ func (*rpSession) setSupportedCapabilities(c *capability.List) error {
 		return err
 	}
 
+	if err := c.Set(capability.Atomic); err != nil {
+		return err
+	}
+
 	return c.Set(capability.ReportStatus)
 }
 
@@ -65,6 +65,9 @@ const (
 type Remote struct {
 	c *config.RemoteConfig
 	s storage.Storer
+	// updated is called once the references are fetched or pushed, if the
+	// remote belongs to a Repository.
+	updated func()
 }
 
 // NewRemote creates a new Remote.
@@ -106,6 +109,10 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return err
 	}
 
+	if r.updated != nil {
+		defer r.updated()
+	}
+
 	if o.RemoteName != r.c.Name {
 		return fmt.Errorf("remote names don't match: %s != %s", o.RemoteName, r.c.Name)
 	}
@@ -351,6 +358,10 @@ func (r *Remote) newReferenceUpdateRequest(
 func (r *Remote) updateRemoteReferenceStorage(
 	req *packp.ReferenceUpdateRequest,
 ) error {
+	u, err := newReferenceUpdater(r.s)
+	if err != nil {
+		return err
+	}
 
 	for _, spec := range r.c.Fetch {
 		for _, c := range req.Commands {
@@ -362,18 +373,19 @@ func (r *Remote) updateRemoteReferenceStorage(
 			ref := plumbing.NewHashReference(local, c.New)
 			switch c.Action() {
 			case packp.Create, packp.Update:
-				if err := r.s.SetReference(ref); err != nil {
-					return err
-				}
+				_, err = u.update(ref, nil)
 			case packp.Delete:
-				if err := r.s.RemoveReference(local); err != nil {
-					return err
-				}
+				err = u.remove(local)
+			}
+
+			if err != nil {
+				u.abort()
+				return err
 			}
 		}
 	}
 
-	return nil
+	return u.commit()
 }
 
 // FetchContext fetches references along with the objects necessary to complete
@@ -408,6 +420,10 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
+	if r.updated != nil {
+		defer r.updated()
+	}
+
 	if len(o.RefSpecs) == 0 {
 		o.RefSpecs = r.c.Fetch
 	}
@@ -471,15 +487,31 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		}
 	}
 
+	u, err := newReferenceUpdater(r.s)
+	if err != nil {
+		return nil, err
+	}
+
 	var updatedPrune bool
 	if o.Prune {
-		updatedPrune, err = r.pruneRemotes(o.RefSpecs, localRefs, remoteRefs)
+		updatedPrune, err = r.pruneRemotes(u, o.RefSpecs, localRefs, remoteRefs)
 		if err != nil {
+			u.abort()
 			return nil, err
 		}
 	}
 
-	updated, err := r.updateLocalReferenceStorage(o.RefSpecs, refs, remoteRefs, specToRefs, o.Tags, o.Force)
+	updated, err := r.updateLocalReferenceStorage(u, o.RefSpecs, refs, remoteRefs, specToRefs, o.Tags, o.Force)
+	if err != nil && err != ErrForceNeeded {
+		u.abort()
+		return nil, err
+	}
+
+	// The references not needing a forced update are updated anyway.
+	if cerr := u.commit(); cerr != nil {
+		return nil, cerr
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -594,7 +626,7 @@ func (r *Remote) fetchPack(ctx context.Context, o *FetchOptions, s transport.Upl
 	return err
 }
 
-func (r *Remote) pruneRemotes(specs []config.RefSpec, localRefs []*plumbing.Reference, remoteRefs memory.ReferenceStorage) (bool, error) {
+func (r *Remote) pruneRemotes(u *referenceUpdater, specs []config.RefSpec, localRefs []*plumbing.Reference, remoteRefs memory.ReferenceStorage) (bool, error) {
 	var updatedPrune bool
 	for _, spec := range specs {
 		rev := spec.Reverse()
@@ -605,7 +637,7 @@ func (r *Remote) pruneRemotes(specs []config.RefSpec, localRefs []*plumbing.Refe
 			_, err := remoteRefs.Reference(rev.Dst(ref.Name()))
 			if errors.Is(err, plumbing.ErrReferenceNotFound) {
 				updatedPrune = true
-				err := r.s.RemoveReference(ref.Name())
+				err := u.remove(ref.Name())
 				if err != nil {
 					return false, err
 				}
@@ -1225,6 +1257,7 @@ func buildSidebandIfSupported(l *capability.List, reader io.Reader, p sideband.P
 }
 
 func (r *Remote) updateLocalReferenceStorage(
+	u *referenceUpdater,
 	specs []config.RefSpec,
 	fetchedRefs, remoteRefs memory.ReferenceStorage,
 	specToRefs [][]*plumbing.Reference,
@@ -1266,7 +1299,7 @@ func (r *Remote) updateLocalReferenceStorage(
 				}
 			}
 
-			refUpdated, err := checkAndUpdateReferenceStorerIfNeeded(r.s, new, old)
+			refUpdated, err := u.update(new, old)
 			if err != nil {
 				return updated, err
 			}
@@ -1285,7 +1318,7 @@ func (r *Remote) updateLocalReferenceStorage(
 	if isWildcard {
 		tags = remoteRefs
 	}
-	tagUpdated, err := r.buildFetchedTags(tags)
+	tagUpdated, err := r.buildFetchedTags(u, tags)
 	if err != nil {
 		return updated, err
 	}
@@ -1301,7 +1334,7 @@ func (r *Remote) updateLocalReferenceStorage(
 	return
 }
 
-func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, err error) {
+func (r *Remote) buildFetchedTags(u *referenceUpdater, refs memory.ReferenceStorage) (updated bool, err error) {
 	for _, ref := range refs {
 		if !ref.Name().IsTag() {
 			continue
@@ -1316,7 +1349,7 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, e
 			return false, err
 		}
 
-		refUpdated, err := updateReferenceStorerIfNeeded(r.s, ref)
+		refUpdated, err := u.update(ref, nil)
 		if err != nil {
 			return updated, err
 		}
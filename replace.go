@@ -0,0 +1,285 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage"
+)
+
+const (
+	// DefaultReplaceRefBase is the prefix of the replace references, unless
+	// overridden with the GIT_REPLACE_REF_BASE environment variable.
+	DefaultReplaceRefBase = "refs/replace/"
+
+	// maxReplaceDepth is the maximum number of replacements followed, as
+	// replacement objects can also be replaced.
+	maxReplaceDepth = 5
+)
+
+var (
+	ErrReplaceExists       = errors.New("replace reference already exists")
+	ErrReplaceSameObject   = errors.New("new object is the same as the old one")
+	ErrReplaceTypeMismatch = errors.New("objects must be of the same type")
+	ErrReplaceDepth        = errors.New("replace depth too high")
+)
+
+// ReplaceOptions describes how a replacement should be created.
+type ReplaceOptions struct {
+	// Force overwrites an existing replacement of the object and allows
+	// replacing an object with one of another type.
+	Force bool
+}
+
+// ReplaceRefs returns all the References replacing objects. For more
+// information: https://git-scm.com/docs/git-replace
+func (r *Repository) ReplaceRefs() (storer.ReferenceIter, error) {
+	refIter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	base := replaceRefBase()
+	return storer.NewReferenceFilteredIter(
+		func(r *plumbing.Reference) bool {
+			return strings.HasPrefix(r.Name().String(), base)
+		}, refIter), nil
+}
+
+// CreateReplace replaces an object with another one of the same type,
+// creating the refs/replace/<object> reference. The replacement is used
+// instead of the object by the object getters of the Repository and by the
+// commit walks, keeping the hash of the replaced object. It is equivalent to
+// running `git replace <object> <replacement>`.
+func (r *Repository) CreateReplace(obj, replacement plumbing.Hash, o *ReplaceOptions) (*plumbing.Reference, error) {
+	if o == nil {
+		o = &ReplaceOptions{}
+	}
+
+	if obj == replacement {
+		return nil, ErrReplaceSameObject
+	}
+
+	name := replaceRefName(obj)
+	if _, err := r.Storer.Reference(name); err == nil && !o.Force {
+		return nil, fmt.Errorf("%w: %s", ErrReplaceExists, name)
+	} else if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	original, err := r.Storer.EncodedObject(plumbing.AnyObject, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	repl, err := r.Storer.EncodedObject(plumbing.AnyObject, replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	if original.Type() != repl.Type() && !o.Force {
+		return nil, fmt.Errorf("%w: %s is a %s, %s is a %s",
+			ErrReplaceTypeMismatch, obj, original.Type(), replacement, repl.Type())
+	}
+
+	ref := plumbing.NewHashReference(name, replacement)
+	if err := r.Storer.SetReference(ref); err != nil {
+		return nil, err
+	}
+
+	r.resetReplacements()
+	return ref, nil
+}
+
+// CreateGraft replaces a commit with a copy of it with the given parents,
+// or without parents if none is given. It is equivalent to running
+// `git replace --graft <commit> [<parent>...]`.
+func (r *Repository) CreateGraft(commit plumbing.Hash, parents []plumbing.Hash, o *ReplaceOptions) (*plumbing.Reference, error) {
+	c, err := object.GetCommit(r.Storer, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range parents {
+		if _, err := r.CommitObject(p); err != nil {
+			return nil, err
+		}
+	}
+
+	c.ParentHashes = parents
+
+	// the signature would no longer be valid
+	c.PGPSignature = ""
+
+	obj := r.Storer.NewEncodedObject()
+	if err := c.Encode(obj); err != nil {
+		return nil, err
+	}
+
+	h, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CreateReplace(commit, h, o)
+}
+
+// DeleteReplace deletes the replacement of an object. It is equivalent to
+// running `git replace -d <object>`.
+func (r *Repository) DeleteReplace(obj plumbing.Hash) error {
+	name := replaceRefName(obj)
+	if _, err := r.Storer.Reference(name); err != nil {
+		return err
+	}
+
+	if err := r.Storer.RemoveReference(name); err != nil {
+		return err
+	}
+
+	r.resetReplacements()
+	return nil
+}
+
+func replaceRefBase() string {
+	if base := os.Getenv("GIT_REPLACE_REF_BASE"); base != "" {
+		return strings.TrimSuffix(base, "/") + "/"
+	}
+
+	return DefaultReplaceRefBase
+}
+
+func replaceRefName(obj plumbing.Hash) plumbing.ReferenceName {
+	return plumbing.ReferenceName(replaceRefBase() + obj.String())
+}
+
+// useReplaceRefs returns false if the replacements are disabled, with the
+// GIT_NO_REPLACE_OBJECTS environment variable, the core.useReplaceRefs
+// config or PlainOpenOptions.NoReplaceObjects.
+func (r *Repository) useReplaceRefs() (bool, error) {
+	if r.noReplace {
+		return false, nil
+	}
+
+	if _, ok := os.LookupEnv("GIT_NO_REPLACE_OBJECTS"); ok {
+		return false, nil
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return false, err
+	}
+
+	return cfg.Raw.Section("core").Option("useReplaceRefs") != "false", nil
+}
+
+// replacements returns the replaced objects with their replacements. They
+// are loaded from the references the first time, and reloaded after the
+// replacements are changed with the Repository, or after its remotes fetch
+// or push references. The references set directly with the Storer are only
+// seen once any of these happens.
+func (r *Repository) replacements() (map[plumbing.Hash]plumbing.Hash, error) {
+	r.replaceMu.Lock()
+	defer r.replaceMu.Unlock()
+
+	if r.replaced != nil {
+		return r.replaced, nil
+	}
+
+	replaced := make(map[plumbing.Hash]plumbing.Hash)
+	use, err := r.useReplaceRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	if use {
+		refs, err := r.ReplaceRefs()
+		if err != nil {
+			return nil, err
+		}
+
+		base := replaceRefBase()
+		err = refs.ForEach(func(ref *plumbing.Reference) error {
+			name := strings.TrimPrefix(ref.Name().String(), base)
+			if ref.Type() == plumbing.HashReference && plumbing.IsHash(name) {
+				replaced[plumbing.NewHash(name)] = ref.Hash()
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.replaced = replaced
+	return replaced, nil
+}
+
+func (r *Repository) resetReplacements() {
+	r.replaceMu.Lock()
+	r.replaced = nil
+	r.replaceMu.Unlock()
+}
+
+// objects returns the storage used to read the objects, replacing them if
+// there are replacements.
+func (r *Repository) objects() storage.Storer {
+	replaced, err := r.replacements()
+	if err != nil || len(replaced) == 0 {
+		return r.Storer
+	}
+
+	return &replaceStorer{Storer: r.Storer, replaced: replaced}
+}
+
+// replaceStorer is a storage.Storer returning the replacements of the
+// replaced objects.
+type replaceStorer struct {
+	storage.Storer
+	replaced map[plumbing.Hash]plumbing.Hash
+}
+
+func (s *replaceStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	replacement, err := s.replacement(h)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := s.Storer.EncodedObject(t, replacement)
+	if err != nil || replacement == h {
+		return o, err
+	}
+
+	return &replacedObject{EncodedObject: o, hash: h}, nil
+}
+
+func (s *replaceStorer) replacement(h plumbing.Hash) (plumbing.Hash, error) {
+	replacement := h
+	for depth := 0; depth < maxReplaceDepth; depth++ {
+		next, ok := s.replaced[replacement]
+		if !ok {
+			return replacement, nil
+		}
+
+		replacement = next
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("%w: %s", ErrReplaceDepth, h)
+}
+
+// replacedObject is the replacement of an object, with the hash of the
+// replaced object.
+type replacedObject struct {
+	plumbing.EncodedObject
+	hash plumbing.Hash
+}
+
+func (o *replacedObject) Hash() plumbing.Hash {
+	return o.hash
+}
@@ -0,0 +1,251 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type ReplaceSuite struct {
+	BaseSuite
+
+	r *Repository
+}
+
+var _ = Suite(&ReplaceSuite{})
+
+func (s *ReplaceSuite) SetUpTest(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	s.r = r
+}
+
+func (s *ReplaceSuite) assertLog(c *C, expected ...string) {
+	iter, err := s.r.Log(&LogOptions{})
+	c.Assert(err, IsNil)
+
+	var hashes []string
+	for {
+		commit, err := iter.Next()
+		if err != nil {
+			break
+		}
+
+		hashes = append(hashes, commit.Hash.String())
+	}
+
+	c.Assert(hashes, DeepEquals, expected)
+}
+
+func (s *ReplaceSuite) TestCreateReplace(c *C) {
+	original := plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+	replacement := plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9")
+
+	ref, err := s.r.CreateReplace(original, replacement, nil)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name().String(), Equals, "refs/replace/"+original.String())
+	c.Assert(ref.Hash(), Equals, replacement)
+
+	commit, err := s.r.CommitObject(original)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, original)
+	c.Assert(commit.Message, Equals, "binary file\n")
+
+	s.assertLog(c,
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	)
+
+	var names []string
+	refs, err := s.r.ReplaceRefs()
+	c.Assert(err, IsNil)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name().String())
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"refs/replace/" + original.String()})
+}
+
+func (s *ReplaceSuite) TestCreateReplaceBlob(c *C) {
+	license := plumbing.NewHash("c192bd6a24ea1ab01d78686e417c8bdc7c3d197f")
+	changelog := plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa")
+
+	_, err := s.r.CreateReplace(license, changelog, nil)
+	c.Assert(err, IsNil)
+
+	commit, err := s.r.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, IsNil)
+
+	file, err := commit.File("LICENSE")
+	c.Assert(err, IsNil)
+	c.Assert(file.Hash, Equals, license)
+
+	contents, err := file.Contents()
+	c.Assert(err, IsNil)
+
+	blob, err := s.r.Storer.EncodedObject(plumbing.BlobObject, changelog)
+	c.Assert(err, IsNil)
+	c.Assert(contents, HasLen, int(blob.Size()))
+}
+
+func (s *ReplaceSuite) TestCreateReplaceErrors(c *C) {
+	original := plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+	replacement := plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9")
+	blob := plumbing.NewHash("c192bd6a24ea1ab01d78686e417c8bdc7c3d197f")
+
+	_, err := s.r.CreateReplace(original, original, nil)
+	c.Assert(err, Equals, ErrReplaceSameObject)
+
+	_, err = s.r.CreateReplace(original, blob, nil)
+	c.Assert(errors.Is(err, ErrReplaceTypeMismatch), Equals, true)
+
+	_, err = s.r.CreateReplace(original, replacement, nil)
+	c.Assert(err, IsNil)
+
+	_, err = s.r.CreateReplace(original, replacement, nil)
+	c.Assert(errors.Is(err, ErrReplaceExists), Equals, true)
+
+	_, err = s.r.CreateReplace(original, blob, &ReplaceOptions{Force: true})
+	c.Assert(err, IsNil)
+}
+
+func (s *ReplaceSuite) TestCreateReplaceDepth(c *C) {
+	hashes := []plumbing.Hash{
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+		plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a"),
+		plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea"),
+		plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69"),
+		plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"),
+		plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+	}
+
+	for i := 0; i < len(hashes)-1; i++ {
+		_, err := s.r.CreateReplace(hashes[i], hashes[i+1], nil)
+		c.Assert(err, IsNil)
+	}
+
+	commit, err := s.r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Creating changelog\n")
+
+	_, err = s.r.CommitObject(hashes[1])
+	c.Assert(errors.Is(err, ErrReplaceDepth), Equals, true)
+}
+
+func (s *ReplaceSuite) TestCreateGraft(c *C) {
+	_, err := s.r.CreateGraft(plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"), nil, nil)
+	c.Assert(err, IsNil)
+
+	s.assertLog(c,
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+	)
+
+	c.Assert(s.r.DeleteReplace(plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")), IsNil)
+
+	merge := plumbing.NewHash("1669dce138d9b841a518c64b10914d88f5e488ea")
+	_, err = s.r.CreateGraft(merge, []plumbing.Hash{
+		plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"),
+	}, nil)
+	c.Assert(err, IsNil)
+
+	commit, err := s.r.CommitObject(merge)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, merge)
+	c.Assert(commit.Message, Equals, "Merge branch 'master' of github.com:tyba/git-fixture\n")
+	c.Assert(commit.NumParents(), Equals, 1)
+
+	s.assertLog(c,
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	)
+}
+
+func (s *ReplaceSuite) TestDeleteReplace(c *C) {
+	original := plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+
+	c.Assert(s.r.DeleteReplace(original), Equals, plumbing.ErrReferenceNotFound)
+
+	_, err := s.r.CreateReplace(original, plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"), nil)
+	c.Assert(err, IsNil)
+	c.Assert(s.r.DeleteReplace(original), IsNil)
+
+	commit, err := s.r.CommitObject(original)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "some json\n")
+}
+
+func (s *ReplaceSuite) TestFetchReplace(c *C) {
+	original := plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+
+	commit, err := s.r.CommitObject(original)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "some json\n")
+
+	dir := c.MkDir()
+	upstream, err := PlainClone(dir, true, &CloneOptions{URL: s.GetBasicLocalRepositoryURL()})
+	c.Assert(err, IsNil)
+	_, err = upstream.CreateReplace(original, plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"), nil)
+	c.Assert(err, IsNil)
+
+	_, err = s.r.CreateRemote(&config.RemoteConfig{Name: "upstream", URLs: []string{dir}})
+	c.Assert(err, IsNil)
+
+	err = s.r.Fetch(&FetchOptions{
+		RemoteName: "upstream",
+		RefSpecs:   []config.RefSpec{"refs/replace/*:refs/replace/*"},
+	})
+	c.Assert(err, IsNil)
+
+	commit, err = s.r.CommitObject(original)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "binary file\n")
+}
+
+func (s *ReplaceSuite) TestNoReplaceObjects(c *C) {
+	original := plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+	_, err := s.r.CreateReplace(original, plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"), nil)
+	c.Assert(err, IsNil)
+
+	assertMessage := func(expected string) {
+		s.r.resetReplacements()
+
+		commit, err := s.r.CommitObject(original)
+		c.Assert(err, IsNil)
+		c.Assert(commit.Message, Equals, expected)
+	}
+
+	assertMessage("binary file\n")
+
+	os.Setenv("GIT_NO_REPLACE_OBJECTS", "1")
+	assertMessage("some json\n")
+	os.Unsetenv("GIT_NO_REPLACE_OBJECTS")
+
+	s.r.noReplace = true
+	assertMessage("some json\n")
+	s.r.noReplace = false
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption("useReplaceRefs", "false")
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+	assertMessage("some json\n")
+}
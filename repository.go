@@ -11,7 +11,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"dario.cat/mergo"
@@ -25,7 +27,10 @@ import (
 	"github.com/go-git/go-git/v5/internal/url"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	formatcfg "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/format/mailmap"
 	"github.com/go-git/go-git/v5/plumbing/format/packfile"
 	"github.com/go-git/go-git/v5/plumbing/hash"
 	"github.com/go-git/go-git/v5/plumbing/object"
@@ -33,6 +38,7 @@ import (
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v5/utils/diff"
 	"github.com/go-git/go-git/v5/utils/ioutil"
 )
 
@@ -62,10 +68,23 @@ var (
 	ErrIsBareRepository            = errors.New("worktree not available in a bare repository")
 	ErrUnableToResolveCommit       = errors.New("unable to resolve commit")
 	ErrPackedObjectsNotSupported   = errors.New("packed objects not supported")
+	ErrCruftPacksNotSupported      = errors.New("cruft packs not supported")
 	ErrSHA256NotSupported          = errors.New("go-git was not compiled with SHA256 support")
 	ErrAlternatePathNotSupported   = errors.New("alternate path must use the file scheme")
 	ErrUnsupportedMergeStrategy    = errors.New("unsupported merge strategy")
 	ErrFastForwardMergeNotPossible = errors.New("not possible to fast-forward merge changes")
+	// ErrReflogNotSupported is returned when resolving revisions which need
+	// the reflog, such as @{1}, or @{-1} if the storage isn't file based.
+	ErrReflogNotSupported = errors.New("revisions using the reflog are not supported")
+	// ErrUpstreamNotFound is returned when resolving @{upstream} or @{push}
+	// for a branch without upstream.
+	ErrUpstreamNotFound = errors.New("no upstream configured for branch")
+	// ErrUnexpectedObjectType is returned when a revision can't be peeled to
+	// the requested object type.
+	ErrUnexpectedObjectType = errors.New("unexpected object type")
+	// ErrFollowWithoutFileName is returned by Log when LogOptions.Follow is
+	// set without a FileName.
+	ErrFollowWithoutFileName = errors.New("follow requires a file name")
 )
 
 // Repository represents a git repository
@@ -74,6 +93,10 @@ type Repository struct {
 
 	r  map[string]*Remote
 	wt billy.Filesystem
+
+	noReplace bool
+	replaceMu sync.Mutex
+	replaced  map[plumbing.Hash]plumbing.Hash
 }
 
 type InitOptions struct {
@@ -328,7 +351,13 @@ func PlainOpenWithOptions(path string, o *PlainOpenOptions) (*Repository, error)
 
 	s := filesystem.NewStorage(repositoryFs, cache.NewObjectLRUDefault())
 
-	return Open(s, wt)
+	r, err := Open(s, wt)
+	if err != nil {
+		return nil, err
+	}
+
+	r.noReplace = o.NoReplaceObjects
+	return r, nil
 }
 
 func dotGitToOSFilesystems(path string, detect bool) (dot, wt billy.Filesystem, err error) {
@@ -557,7 +586,12 @@ func (r *Repository) Config() (*config.Config, error) {
 // with the result of `Repository.Config` and never with the output of
 // `Repository.ConfigScoped`.
 func (r *Repository) SetConfig(cfg *config.Config) error {
-	return r.Storer.SetConfig(cfg)
+	if err := r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	r.resetReplacements()
+	return nil
 }
 
 // ConfigScoped returns the repository config, merged with requested scope and
@@ -593,6 +627,124 @@ func (r *Repository) ConfigScoped(scope config.Scope) (*config.Config, error) {
 	return local, nil
 }
 
+// diffOptions returns the options of the line diffs, read from the `diff`
+// config: the algorithm, and whether the indent heuristic is used.
+func (r *Repository) diffOptions() (*object.PatchOptions, error) {
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, err := diff.ParseAlgorithm(cfg.Diff.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, cfg.Diff.Algorithm)
+	}
+
+	return &object.PatchOptions{
+		Algorithm:       algorithm,
+		IndentHeuristic: !cfg.Diff.NoIndentHeuristic,
+	}, nil
+}
+
+// Mailmap returns the mailmap of the repository, read as git does from the
+// .mailmap file of the worktree, the blob of the `mailmap.blob` config,
+// HEAD:.mailmap by default in bare repositories, and the file of the
+// `mailmap.file` config, the later lines overriding the former ones. The
+// missing files and blobs are skipped.
+func (r *Repository) Mailmap() (*mailmap.Mailmap, error) {
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	m := mailmap.New()
+	blob := cfg.Mailmap.Blob
+	if r.wt != nil {
+		if err := readMailmapFile(m, r.wt, ".mailmap"); err != nil {
+			return nil, err
+		}
+	} else if blob == "" {
+		blob = "HEAD:.mailmap"
+	}
+
+	if blob != "" {
+		if err := r.readMailmapBlob(m, blob); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Mailmap.File != "" {
+		path, err := path_util.ReplaceTildeWithHome(cfg.Mailmap.File)
+		if err != nil {
+			return nil, err
+		}
+
+		// relative paths are relative to the worktree, if any
+		var fs billy.Basic = osfs.Default
+		if r.wt != nil && !filepath.IsAbs(path) {
+			fs = r.wt
+		}
+
+		if err := readMailmapFile(m, fs, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func readMailmapFile(m *mailmap.Mailmap, fs billy.Basic, path string) (err error) {
+	f, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return m.Read(f)
+}
+
+func (r *Repository) readMailmapBlob(m *mailmap.Mailmap, rev string) (err error) {
+	h, t, err := r.ResolveRevisionObject(plumbing.Revision(rev))
+	if err != nil {
+		// as git, a blob that does not exist is skipped
+		return nil
+	}
+
+	if t != plumbing.BlobObject {
+		return fmt.Errorf("%w: mailmap blob %s is a %s", ErrUnexpectedObjectType, rev, t)
+	}
+
+	b, err := r.BlobObject(*h)
+	if err != nil {
+		return err
+	}
+
+	reader, err := b.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(reader, &err)
+	return m.Read(reader)
+}
+
+// mailmapCommit returns a copy of the commit with the canonical identities
+// of its author and committer, or the commit itself if m is nil.
+func mailmapCommit(m *mailmap.Mailmap, c *object.Commit) *object.Commit {
+	if m == nil {
+		return c
+	}
+
+	mapped := *c
+	mapped.Author = m.Signature(c.Author)
+	mapped.Committer = m.Signature(c.Committer)
+	return &mapped
+}
+
 // Remote return a remote if exists
 func (r *Repository) Remote(name string) (*Remote, error) {
 	cfg, err := r.Config()
@@ -605,7 +757,7 @@ func (r *Repository) Remote(name string) (*Remote, error) {
 		return nil, ErrRemoteNotFound
 	}
 
-	return NewRemote(r.Storer, c), nil
+	return r.newRemote(c), nil
 }
 
 // Remotes returns a list with all the remotes
@@ -619,7 +771,7 @@ func (r *Repository) Remotes() ([]*Remote, error) {
 
 	var i int
 	for _, c := range cfg.Remotes {
-		remotes[i] = NewRemote(r.Storer, c)
+		remotes[i] = r.newRemote(c)
 		i++
 	}
 
@@ -632,7 +784,7 @@ func (r *Repository) CreateRemote(c *config.RemoteConfig) (*Remote, error) {
 		return nil, err
 	}
 
-	remote := NewRemote(r.Storer, c)
+	remote := r.newRemote(c)
 
 	cfg, err := r.Config()
 	if err != nil {
@@ -658,11 +810,19 @@ func (r *Repository) CreateRemoteAnonymous(c *config.RemoteConfig) (*Remote, err
 		return nil, ErrAnonymousRemoteName
 	}
 
-	remote := NewRemote(r.Storer, c)
+	remote := r.newRemote(c)
 
 	return remote, nil
 }
 
+// newRemote returns a remote of the repository, resetting the replacements
+// once it fetches or pushes references.
+func (r *Repository) newRemote(c *config.RemoteConfig) *Remote {
+	remote := NewRemote(r.Storer, c)
+	remote.updated = r.resetReplacements
+	return remote
+}
+
 // DeleteRemote delete a remote from the repository and delete the config
 func (r *Repository) DeleteRemote(name string) error {
 	cfg, err := r.Config()
@@ -1096,37 +1256,44 @@ func (r *Repository) fetchAndUpdateReferences(
 func (r *Repository) updateReferences(spec []config.RefSpec,
 	resolvedRef *plumbing.Reference) (updated bool, err error) {
 
+	var refs []*plumbing.Reference
 	if !resolvedRef.Name().IsBranch() {
 		// Detached HEAD mode
 		h, err := r.resolveToCommitHash(resolvedRef.Hash())
 		if err != nil {
 			return false, err
 		}
-		head := plumbing.NewHashReference(plumbing.HEAD, h)
-		return updateReferenceStorerIfNeeded(r.Storer, head)
-	}
 
-	refs := []*plumbing.Reference{
-		// Create local reference for the resolved ref
-		resolvedRef,
-		// Create local symbolic HEAD
-		plumbing.NewSymbolicReference(plumbing.HEAD, resolvedRef.Name()),
+		refs = append(refs, plumbing.NewHashReference(plumbing.HEAD, h))
+	} else {
+		refs = append(refs,
+			// Create local reference for the resolved ref
+			resolvedRef,
+			// Create local symbolic HEAD
+			plumbing.NewSymbolicReference(plumbing.HEAD, resolvedRef.Name()),
+		)
+
+		refs = append(refs, r.calculateRemoteHeadReference(spec, resolvedRef)...)
 	}
 
-	refs = append(refs, r.calculateRemoteHeadReference(spec, resolvedRef)...)
+	u, err := newReferenceUpdater(r.Storer)
+	if err != nil {
+		return false, err
+	}
 
 	for _, ref := range refs {
-		u, err := updateReferenceStorerIfNeeded(r.Storer, ref)
+		refUpdated, err := u.update(ref, nil)
 		if err != nil {
-			return updated, err
+			u.abort()
+			return false, err
 		}
 
-		if u {
+		if refUpdated {
 			updated = true
 		}
 	}
 
-	return
+	return updated, u.commit()
 }
 
 func (r *Repository) calculateRemoteHeadReference(spec []config.RefSpec,
@@ -1152,29 +1319,110 @@ func (r *Repository) calculateRemoteHeadReference(spec []config.RefSpec,
 	return refs
 }
 
-func checkAndUpdateReferenceStorerIfNeeded(
-	s storer.ReferenceStorer, r, old *plumbing.Reference) (
-	updated bool, err error) {
-	p, err := s.Reference(r.Name())
-	if err != nil && err != plumbing.ErrReferenceNotFound {
+// referenceUpdater collects the updates of the references which differ from
+// the stored ones, applying them in a single transaction. The transaction
+// fails if the stored references change before it's committed.
+type referenceUpdater struct {
+	s       storer.ReferenceStorer
+	tx      storer.ReferenceTransaction
+	pending map[plumbing.ReferenceName]*storer.ReferenceUpdate
+}
+
+func newReferenceUpdater(s storer.ReferenceStorer) (*referenceUpdater, error) {
+	tx, err := storer.BeginReferenceTransaction(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &referenceUpdater{
+		s:       s,
+		tx:      tx,
+		pending: make(map[plumbing.ReferenceName]*storer.ReferenceUpdate),
+	}, nil
+}
+
+// stored returns the stored value of the reference, nil if it doesn't exist.
+func (u *referenceUpdater) stored(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	if p, ok := u.pending[n]; ok {
+		return p.Old, nil
+	}
+
+	r, err := u.s.Reference(n)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	return r, err
+}
+
+// update sets the new value of the reference, returning false if it's
+// already stored, or pending, with the same value. If old is not nil, the
+// update only succeeds if the stored reference is old when it's committed,
+// otherwise if it's still the one stored now.
+func (u *referenceUpdater) update(r, old *plumbing.Reference) (updated bool, err error) {
+	if p, ok := u.pending[r.Name()]; ok && !p.IsDelete() && p.New.String() == r.String() {
+		return false, nil
+	}
+
+	stored, err := u.stored(r.Name())
+	if err != nil {
 		return false, err
 	}
 
 	// we use the string method to compare references, is the easiest way
-	if err == plumbing.ErrReferenceNotFound || r.String() != p.String() {
-		if err := s.CheckAndSetReference(r, old); err != nil {
-			return false, err
+	if stored != nil && stored.String() == r.String() {
+		delete(u.pending, r.Name())
+		return false, nil
+	}
+
+	if old == nil {
+		old = stored
+	}
+
+	u.pending[r.Name()] = &storer.ReferenceUpdate{Name: r.Name(), New: r, Old: old}
+	return true, nil
+}
+
+// remove sets the reference to be removed, if it's stored.
+func (u *referenceUpdater) remove(n plumbing.ReferenceName) error {
+	old, err := u.stored(n)
+	if err != nil {
+		return err
+	}
+
+	if old == nil {
+		delete(u.pending, n)
+		return nil
+	}
+
+	u.pending[n] = &storer.ReferenceUpdate{Name: n, Old: old}
+	return nil
+}
+
+// commit applies the pending updates atomically.
+func (u *referenceUpdater) commit() error {
+	for _, p := range u.pending {
+		var err error
+		switch {
+		case p.IsDelete():
+			err = u.tx.Delete(p.Name, p.Old)
+		case p.Old == nil:
+			err = u.tx.Create(p.New)
+		default:
+			err = u.tx.Update(p.New, p.Old)
 		}
 
-		return true, nil
+		if err != nil {
+			u.abort()
+			return err
+		}
 	}
 
-	return false, nil
+	return u.tx.Commit()
 }
 
-func updateReferenceStorerIfNeeded(
-	s storer.ReferenceStorer, r *plumbing.Reference) (updated bool, err error) {
-	return checkAndUpdateReferenceStorerIfNeeded(s, r, nil)
+func (u *referenceUpdater) abort() {
+	_ = u.tx.Abort()
 }
 
 // Fetch fetches references along with the objects necessary to complete
@@ -1243,10 +1491,13 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 	}
 
 	var (
-		it  object.CommitIter
-		err error
+		it    object.CommitIter
+		sides map[plumbing.Hash]CommitSide
+		err   error
 	)
-	if o.All {
+	if o.revisionWalk() {
+		it, sides, err = r.logRevisions(o)
+	} else if o.All {
 		it, err = r.logAll(fn)
 	} else {
 		it, err = r.log(o.From, fn)
@@ -1256,7 +1507,23 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 		return nil, err
 	}
 
-	if o.FileName != nil {
+	if o.Mailmap != nil {
+		it = &mailmapCommitIter{CommitIter: it, mailmap: o.Mailmap}
+	}
+
+	if o.Merges || o.NoMerges || o.Author != nil || o.Grep != nil {
+		it = newCommitFilterIter(it, o)
+	}
+
+	var follow object.CommitFollowIter
+	if o.Follow {
+		if o.FileName == nil {
+			return nil, ErrFollowWithoutFileName
+		}
+
+		follow = object.NewCommitFollowIterFromIter(*o.FileName, it, nil)
+		it = follow
+	} else if o.FileName != nil {
 		// for `git log --all` also check parent (if the next commit comes from the real parent)
 		it = r.logWithFile(*o.FileName, it, o.All)
 	}
@@ -1269,6 +1536,14 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 		it = r.logWithLimit(it, limitOptions)
 	}
 
+	if o.LeftRight {
+		it = &leftRightCommitIter{CommitIter: it, sides: sides}
+	}
+
+	if follow != nil && it != follow {
+		it = &followCommitIter{CommitIter: it, follow: follow}
+	}
+
 	return it, nil
 }
 
@@ -1291,7 +1566,7 @@ func (r *Repository) log(from plumbing.Hash, commitIterFunc func(*object.Commit)
 }
 
 func (r *Repository) logAll(commitIterFunc func(*object.Commit) object.CommitIter) (object.CommitIter, error) {
-	return object.NewCommitAllIter(r.Storer, commitIterFunc)
+	return object.NewCommitAllIter(r.objects(), commitIterFunc)
 }
 
 func (*Repository) logWithFile(fileName string, commitIter object.CommitIter, checkParent bool) object.CommitIter {
@@ -1408,7 +1683,7 @@ func (r *Repository) Notes() (storer.ReferenceIter, error) {
 // TreeObject return a Tree with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned
 func (r *Repository) TreeObject(h plumbing.Hash) (*object.Tree, error) {
-	return object.GetTree(r.Storer, h)
+	return object.GetTree(r.objects(), h)
 }
 
 // TreeObjects returns an unsorted TreeIter with all the trees in the repository
@@ -1424,7 +1699,7 @@ func (r *Repository) TreeObjects() (*object.TreeIter, error) {
 // CommitObject return a Commit with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) CommitObject(h plumbing.Hash) (*object.Commit, error) {
-	return object.GetCommit(r.Storer, h)
+	return object.GetCommit(r.objects(), h)
 }
 
 // CommitObjects returns an unsorted CommitIter with all the commits in the repository.
@@ -1440,7 +1715,7 @@ func (r *Repository) CommitObjects() (object.CommitIter, error) {
 // BlobObject returns a Blob with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) BlobObject(h plumbing.Hash) (*object.Blob, error) {
-	return object.GetBlob(r.Storer, h)
+	return object.GetBlob(r.objects(), h)
 }
 
 // BlobObjects returns an unsorted BlobIter with all the blobs in the repository.
@@ -1457,7 +1732,7 @@ func (r *Repository) BlobObjects() (*object.BlobIter, error) {
 // plumbing.ErrObjectNotFound is returned. This method only returns
 // annotated Tags, no lightweight Tags.
 func (r *Repository) TagObject(h plumbing.Hash) (*object.Tag, error) {
-	return object.GetTag(r.Storer, h)
+	return object.GetTag(r.objects(), h)
 }
 
 // TagObjects returns a unsorted TagIter that can step through all of the annotated
@@ -1474,12 +1749,13 @@ func (r *Repository) TagObjects() (*object.TagIter, error) {
 // Object returns an Object with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) Object(t plumbing.ObjectType, h plumbing.Hash) (object.Object, error) {
-	obj, err := r.Storer.EncodedObject(t, h)
+	objects := r.objects()
+	obj, err := objects.EncodedObject(t, h)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.DecodeObject(r.Storer, obj)
+	return object.DecodeObject(objects, obj)
 }
 
 // Objects returns an unsorted ObjectIter with all the objects in the repository.
@@ -1541,149 +1817,485 @@ func expand_ref(s storer.ReferenceStorer, ref plumbing.ReferenceName) (*plumbing
 	return nil, ret
 }
 
-// ResolveRevision resolves revision to corresponding hash. It will always
-// resolve to a commit hash, not a tree or annotated tag.
+// ResolveRevision resolves revision to corresponding hash. Revisions naming a
+// reference, including annotated tags, resolve to the tagged object, usually
+// a commit, as do tilde and caret. Other revisions resolve to the object they
+// name, such as a tree or a blob, use ResolveRevisionObject to get its type.
 //
 // Implemented resolvers : HEAD, branch, tag, heads/branch, refs/heads/branch,
-// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug}), hash (prefix and full)
+// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug}, :/fix nasty bug), hash (prefix and full),
+// peeling (v1.0^{tree}, v1.0^{commit}, v1.0^{tag}, v1.0^{}), paths (HEAD:README, :README, :1:README), upstream and push branches (@{upstream}, master@{u}, @{push})
 func (r *Repository) ResolveRevision(in plumbing.Revision) (*plumbing.Hash, error) {
+	h, _, err := r.resolveRevision(in, true)
+	return h, err
+}
+
+// ResolveRevisionObject resolves revision to the hash and type of the object
+// it names, as git rev-parse does. Unlike ResolveRevision, revisions naming an
+// annotated tag resolve to the tag object itself.
+//
+// See ResolveRevision for the supported revisions.
+func (r *Repository) ResolveRevisionObject(in plumbing.Revision) (*plumbing.Hash, plumbing.ObjectType, error) {
+	return r.resolveRevision(in, false)
+}
+
+func (r *Repository) resolveRevision(in plumbing.Revision, peelTags bool) (*plumbing.Hash, plumbing.ObjectType, error) {
 	rev := in.String()
 	if rev == "" {
-		return &plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+		return &plumbing.ZeroHash, plumbing.InvalidObject, plumbing.ErrReferenceNotFound
 	}
 
 	p := revision.NewParserFromString(rev)
 	items, err := p.Parse()
 
 	if err != nil {
-		return nil, err
+		return nil, plumbing.InvalidObject, err
 	}
 
-	var commit *object.Commit
+	var h plumbing.Hash
+	var t plumbing.ObjectType
+	var branch string
 
-	for _, item := range items {
+	for i, item := range items {
 		switch item := item.(type) {
 		case revision.Ref:
-			revisionRef := item
+			if i+1 < len(items) {
+				switch items[i+1].(type) {
+				case revision.AtUpstream, revision.AtPush:
+					branch = string(item)
+					continue
+				}
+			}
 
-			var tryHashes []plumbing.Hash
+			h, t, err = r.resolveRevisionRef(string(item))
+		case revision.CaretPath:
+			h, err = r.resolveRevisionParent(h, t, item.Depth, false)
+			t = plumbing.CommitObject
+		case revision.TildePath:
+			h, err = r.resolveRevisionParent(h, t, item.Depth, true)
+			t = plumbing.CommitObject
+		case revision.CaretReg:
+			var commit *object.Commit
+			commit, err = r.peelToCommit(h, t)
+			if err != nil {
+				break
+			}
 
-			tryHashes = append(tryHashes, r.resolveHashPrefix(string(revisionRef))...)
+			h, err = findRevisionCommit(object.NewCommitPreorderIter(commit, nil, nil), item.Regexp, item.Negate, false)
+			t = plumbing.CommitObject
+		case revision.ColonReg:
+			var iter object.CommitIter
+			iter, err = r.Log(&LogOptions{All: true})
+			if err != nil {
+				break
+			}
 
-			ref, err := expand_ref(r.Storer, plumbing.ReferenceName(revisionRef))
-			if err == nil {
-				tryHashes = append(tryHashes, ref.Hash())
+			h, err = findRevisionCommit(iter, item.Regexp, item.Negate, true)
+			t = plumbing.CommitObject
+		case revision.CaretType:
+			h, t, err = r.peelRevision(h, t, item.ObjectType)
+		case revision.ColonPath:
+			if i == 0 {
+				// The entries not in conflict have stage 0 in the index.
+				h, t, err = r.resolveRevisionIndexPath(item.Path, 0)
+				break
 			}
 
-			// in ambiguous cases, `git rev-parse` will emit a warning, but
-			// will always return the oid in preference to a ref; we don't have
-			// the ability to emit a warning here, so (for speed purposes)
-			// don't bother to detect the ambiguity either, just return in the
-			// priority that git would.
-			gotOne := false
-			for _, hash := range tryHashes {
-				commitObj, err := r.CommitObject(hash)
-				if err == nil {
-					commit = commitObj
-					gotOne = true
-					break
-				}
+			h, t, err = r.resolveRevisionTreePath(h, t, item.Path)
+		case revision.ColonStagePath:
+			h, t, err = r.resolveRevisionIndexPath(item.Path, index.Stage(item.Stage))
+		case revision.AtUpstream:
+			h, t, err = r.resolveRevisionUpstream(branch, false)
+		case revision.AtPush:
+			h, t, err = r.resolveRevisionUpstream(branch, true)
+		case revision.AtCheckout:
+			h, t, err = r.resolveRevisionCheckout(item.Depth)
+		case revision.AtReflog, revision.AtDate:
+			err = ErrReflogNotSupported
+		}
 
-				tagObj, err := r.TagObject(hash)
-				if err == nil {
-					// If the tag target lookup fails here, this most likely
-					// represents some sort of repo corruption, so let the
-					// error bubble up.
-					tagCommit, err := tagObj.Commit()
-					if err != nil {
-						return &plumbing.ZeroHash, err
-					}
-					commit = tagCommit
-					gotOne = true
-					break
-				}
-			}
+		if err != nil {
+			return &plumbing.ZeroHash, plumbing.InvalidObject, err
+		}
+	}
 
-			if !gotOne {
-				return &plumbing.ZeroHash, plumbing.ErrReferenceNotFound
-			}
+	if h.IsZero() {
+		return &plumbing.ZeroHash, plumbing.InvalidObject, plumbing.ErrReferenceNotFound
+	}
 
-		case revision.CaretPath:
-			depth := item.Depth
+	if _, ok := items[len(items)-1].(revision.CaretType); peelTags && !ok {
+		h, t, err = r.peelRevision(h, t, "")
+		if err != nil {
+			return &plumbing.ZeroHash, plumbing.InvalidObject, err
+		}
+	}
 
-			if depth == 0 {
-				break
-			}
+	return &h, t, nil
+}
 
-			iter := commit.Parents()
+// resolveRevisionRef resolves a hash, or hash prefix, or reference name to
+// an object.
+func (r *Repository) resolveRevisionRef(rev string) (plumbing.Hash, plumbing.ObjectType, error) {
+	tryHashes := r.resolveHashPrefix(rev)
 
-			c, err := iter.Next()
+	ref, err := expand_ref(r.Storer, plumbing.ReferenceName(rev))
+	if err == nil {
+		tryHashes = append(tryHashes, ref.Hash())
+	}
 
-			if err != nil {
-				return &plumbing.ZeroHash, err
+	// in ambiguous cases, `git rev-parse` will emit a warning, but
+	// will always return the oid in preference to a ref; we don't have
+	// the ability to emit a warning here, so (for speed purposes)
+	// don't bother to detect the ambiguity either, just return in the
+	// priority that git would. Hashes of commits and tags, which can be
+	// resolved to commits, are preferred to the ones of trees and blobs.
+	var fallback plumbing.EncodedObject
+	for _, hash := range tryHashes {
+		obj, err := r.Storer.EncodedObject(plumbing.AnyObject, hash)
+		if err != nil {
+			continue
+		}
+
+		switch obj.Type() {
+		case plumbing.CommitObject, plumbing.TagObject:
+			return hash, obj.Type(), nil
+		default:
+			if fallback == nil {
+				fallback = obj
 			}
+		}
+	}
 
-			if depth == 1 {
-				commit = c
+	if fallback == nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, plumbing.ErrReferenceNotFound
+	}
 
-				break
-			}
+	return fallback.Hash(), fallback.Type(), nil
+}
 
-			c, err = iter.Next()
+// peelRevision peels the object until reaching an object of the given type,
+// as done by ^{<type>}. An empty type peels tags until reaching a non-tag
+// object, and "object" does nothing.
+func (r *Repository) peelRevision(h plumbing.Hash, t plumbing.ObjectType, typ string) (plumbing.Hash, plumbing.ObjectType, error) {
+	var target plumbing.ObjectType
+	switch typ {
+	case "object":
+		return h, t, nil
+	case "":
+		target = plumbing.AnyObject
+	default:
+		var err error
+		target, err = plumbing.ParseObjectType(typ)
+		if err != nil {
+			return plumbing.ZeroHash, plumbing.InvalidObject, err
+		}
+	}
 
+	for {
+		switch {
+		case t == target:
+			return h, t, nil
+		case t == plumbing.TagObject:
+			tag, err := r.TagObject(h)
 			if err != nil {
-				return &plumbing.ZeroHash, err
+				return plumbing.ZeroHash, plumbing.InvalidObject, err
 			}
 
-			commit = c
-		case revision.TildePath:
-			for i := 0; i < item.Depth; i++ {
-				c, err := commit.Parents().Next()
+			h, t = tag.Target, tag.TargetType
+		case target == plumbing.AnyObject:
+			return h, t, nil
+		case t == plumbing.CommitObject && target == plumbing.TreeObject:
+			commit, err := r.CommitObject(h)
+			if err != nil {
+				return plumbing.ZeroHash, plumbing.InvalidObject, err
+			}
 
-				if err != nil {
-					return &plumbing.ZeroHash, err
-				}
+			return commit.TreeHash, plumbing.TreeObject, nil
+		default:
+			return plumbing.ZeroHash, plumbing.InvalidObject,
+				fmt.Errorf("%w: %s is a %s, not a %s", ErrUnexpectedObjectType, h, t, target)
+		}
+	}
+}
 
-				commit = c
-			}
-		case revision.CaretReg:
-			history := object.NewCommitPreorderIter(commit, nil, nil)
+func (r *Repository) peelToCommit(h plumbing.Hash, t plumbing.ObjectType) (*object.Commit, error) {
+	h, _, err := r.peelRevision(h, t, "commit")
+	if err != nil {
+		return nil, err
+	}
 
-			re := item.Regexp
-			negate := item.Negate
+	return r.CommitObject(h)
+}
 
-			var c *object.Commit
+// resolveRevisionParent returns the n-th parent of the commit, as done by
+// ^<n>, or the n-th generation ancestor following the first parents, as done
+// by ~<n>.
+func (r *Repository) resolveRevisionParent(h plumbing.Hash, t plumbing.ObjectType, n int, generation bool) (plumbing.Hash, error) {
+	commit, err := r.peelToCommit(h, t)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
 
-			err := history.ForEach(func(hc *object.Commit) error {
-				if !negate && re.MatchString(hc.Message) {
-					c = hc
-					return storer.ErrStop
-				}
+	if n == 0 {
+		return commit.Hash, nil
+	}
 
-				if negate && !re.MatchString(hc.Message) {
-					c = hc
-					return storer.ErrStop
-				}
+	if !generation {
+		c, err := commit.Parent(n - 1)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
 
-				return nil
-			})
-			if err != nil {
-				return &plumbing.ZeroHash, err
+		return c.Hash, nil
+	}
+
+	for i := 0; i < n; i++ {
+		commit, err = commit.Parents().Next()
+		if err == io.EOF {
+			return plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+		}
+
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	return commit.Hash, nil
+}
+
+// resolveRevisionCheckout resolves the n-th branch, or commit, checked out
+// before the current one, as done by @{-n}, reading the checkouts from the
+// reflog of HEAD.
+func (r *Repository) resolveRevisionCheckout(n int) (plumbing.Hash, plumbing.ObjectType, error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	fs, isFSBased := r.Storer.(fsBased)
+	if !isFSBased {
+		return plumbing.ZeroHash, plumbing.InvalidObject, ErrReflogNotSupported
+	}
+
+	b, err := util.ReadFile(fs.Filesystem(), fs.Filesystem().Join("logs", "HEAD"))
+	if os.IsNotExist(err) {
+		return plumbing.ZeroHash, plumbing.InvalidObject, plumbing.ErrReferenceNotFound
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	const checkout = "checkout: moving from "
+	lines := strings.Split(string(b), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		_, msg, ok := strings.Cut(lines[i], "\t")
+		if !ok || !strings.HasPrefix(msg, checkout) {
+			continue
+		}
+
+		if n--; n > 0 {
+			continue
+		}
+
+		from, _, ok := strings.Cut(strings.TrimPrefix(msg, checkout), " to ")
+		if !ok {
+			break
+		}
+
+		return r.resolveRevisionRef(from)
+	}
+
+	return plumbing.ZeroHash, plumbing.InvalidObject, plumbing.ErrReferenceNotFound
+}
+
+// findRevisionCommit returns the first commit of the iterator whose message
+// matches, or doesn't match if negate is set, the regular expression. If
+// youngest is set, the youngest matching commit is returned instead.
+func findRevisionCommit(iter object.CommitIter, re *regexp.Regexp, negate, youngest bool) (plumbing.Hash, error) {
+	var found *object.Commit
+	err := iter.ForEach(func(c *object.Commit) error {
+		if re.MatchString(c.Message) == negate {
+			return nil
+		}
+
+		if found == nil || c.Committer.When.After(found.Committer.When) {
+			found = c
+		}
+
+		if !youngest {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if found == nil {
+		return plumbing.ZeroHash, fmt.Errorf("no commit message match regexp: %q", re.String())
+	}
+
+	return found.Hash, nil
+}
+
+// resolveRevisionTreePath returns the entry of the tree of the object at the
+// given path, as done by <rev>:<path>.
+func (r *Repository) resolveRevisionTreePath(h plumbing.Hash, t plumbing.ObjectType, p string) (plumbing.Hash, plumbing.ObjectType, error) {
+	h, _, err := r.peelRevision(h, t, "tree")
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	p = cleanRevisionPath(p)
+	if p == "" {
+		return h, plumbing.TreeObject, nil
+	}
+
+	tree, err := r.TreeObject(h)
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	e, err := tree.FindEntry(p)
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	return e.Hash, entryObjectType(e.Mode), nil
+}
+
+// resolveRevisionIndexPath returns the entry of the index at the given path
+// and stage, as done by :<n>:<path>.
+func (r *Repository) resolveRevisionIndexPath(p string, stage index.Stage) (plumbing.Hash, plumbing.ObjectType, error) {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	p = cleanRevisionPath(p)
+	for _, e := range idx.Entries {
+		if e.Name == p && e.Stage == stage {
+			return e.Hash, entryObjectType(e.Mode), nil
+		}
+	}
+
+	return plumbing.ZeroHash, plumbing.InvalidObject, index.ErrEntryNotFound
+}
+
+// cleanRevisionPath returns the path relative to the root of the repository,
+// since the paths starting with ./ or ../ are relative to the root as well.
+func cleanRevisionPath(p string) string {
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+func entryObjectType(m filemode.FileMode) plumbing.ObjectType {
+	switch m {
+	case filemode.Dir:
+		return plumbing.TreeObject
+	case filemode.Submodule:
+		return plumbing.CommitObject
+	default:
+		return plumbing.BlobObject
+	}
+}
+
+// resolveRevisionUpstream returns the remote-tracking branch of the upstream
+// of the given branch, the current one if empty, as done by @{upstream}. If
+// push is set, the remote-tracking branch where the branch is pushed to is
+// returned instead, as done by @{push}.
+func (r *Repository) resolveRevisionUpstream(branch string, push bool) (plumbing.Hash, plumbing.ObjectType, error) {
+	name, err := r.revisionBranchName(branch)
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	remote, merge := "", plumbing.ReferenceName("")
+	if b, ok := cfg.Branches[name]; ok {
+		remote, merge = b.Remote, b.Merge
+	}
+
+	if push {
+		upstream := remote
+		if s := cfg.Raw.Section("branch").Subsection(name).Option("pushRemote"); s != "" {
+			remote = s
+		} else if s := cfg.Raw.Section("remote").Option("pushDefault"); s != "" {
+			remote = s
+		}
+
+		// With the default push.default, simple, a branch is pushed to its
+		// upstream only if pushing to the remote it's tracked from.
+		switch cfg.Raw.Section("push").Option("default") {
+		case "", "simple", "upstream":
+			if remote != upstream || merge == "" {
+				merge = plumbing.NewBranchReferenceName(name)
 			}
+		default:
+			merge = plumbing.NewBranchReferenceName(name)
+		}
+	}
 
-			if c == nil {
-				return &plumbing.ZeroHash, fmt.Errorf("no commit message match regexp: %q", re.String())
+	if remote == "" || merge == "" {
+		return plumbing.ZeroHash, plumbing.InvalidObject, fmt.Errorf("%w: %s", ErrUpstreamNotFound, name)
+	}
+
+	tracking := merge
+	if remote != "." {
+		rc, ok := cfg.Remotes[remote]
+		if !ok {
+			return plumbing.ZeroHash, plumbing.InvalidObject, ErrRemoteNotFound
+		}
+
+		tracking = ""
+		for _, spec := range rc.Fetch {
+			if spec.Match(merge) {
+				tracking = spec.Dst(merge)
+				break
 			}
+		}
 
-			commit = c
+		if tracking == "" {
+			return plumbing.ZeroHash, plumbing.InvalidObject, fmt.Errorf("%w: %s", ErrUpstreamNotFound, name)
 		}
 	}
 
-	if commit == nil {
-		return &plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+	ref, err := storer.ResolveReference(r.Storer, tracking)
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
+	}
+
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, ref.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.InvalidObject, err
 	}
 
-	return &commit.Hash, nil
+	return obj.Hash(), obj.Type(), nil
+}
+
+// revisionBranchName returns the short name of the given branch, or of the
+// current branch if empty or HEAD.
+func (r *Repository) revisionBranchName(branch string) (string, error) {
+	if branch != "" && branch != plumbing.HEAD.String() {
+		return strings.TrimPrefix(strings.TrimPrefix(branch, "refs/"), "heads/"), nil
+	}
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", fmt.Errorf("%w: HEAD does not point to a branch", ErrUpstreamNotFound)
+	}
+
+	return head.Target().Short(), nil
 }
 
 // resolveHashPrefix returns a list of potential hashes that the given string
@@ -1736,6 +2348,14 @@ type RepackConfig struct {
 	// OnlyDeletePacksOlderThan if set to non-zero value
 	// selects only objects older than the time provided.
 	OnlyDeletePacksOlderThan time.Time
+	// Cruft writes the unreachable objects into a cruft pack, along with
+	// their modification time, instead of deleting them. Their age is kept
+	// across repacks, so they can be expired later on.
+	Cruft bool
+	// CruftExpiration if set to non-zero value, together with Cruft,
+	// deletes the unreachable objects older than the time provided instead
+	// of writing them into the cruft pack.
+	CruftExpiration time.Time
 }
 
 func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
@@ -1744,22 +2364,43 @@ func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
 		return ErrPackedObjectsNotSupported
 	}
 
+	cps, ok := r.Storer.(storer.CruftPackStorer)
+	if cfg.Cruft && !ok {
+		return ErrCruftPacksNotSupported
+	}
+
 	// Get the existing object packs.
 	hs, err := pos.ObjectPacks()
 	if err != nil {
 		return err
 	}
 
+	ow := newObjectWalker(r.Storer)
+	err = ow.walkAllRefs()
+	if err != nil {
+		return err
+	}
+
 	// Create a new pack.
-	nh, err := r.createNewObjectPack(cfg)
+	nh, err := r.createNewObjectPack(cfg, ow)
 	if err != nil {
 		return err
 	}
 
+	keep := map[plumbing.Hash]struct{}{nh: {}}
+	if cfg.Cruft {
+		ch, err := r.createCruftObjectPack(cfg, ow, cps, hs)
+		if err != nil {
+			return err
+		}
+
+		keep[ch] = struct{}{}
+	}
+
 	// Delete old packs.
 	for _, h := range hs {
-		// Skip if new hash is the same as an old one.
-		if h == nh {
+		// Skip if a new hash is the same as an old one.
+		if _, ok := keep[h]; ok {
 			continue
 		}
 		err = pos.DeleteOldObjectPackAndIndex(h, cfg.OnlyDeletePacksOlderThan)
@@ -1809,12 +2450,7 @@ func (r *Repository) Merge(ref plumbing.Reference, opts MergeOptions) error {
 // createNewObjectPack is a helper for RepackObjects taking care
 // of creating a new pack. It is used so the PackfileWriter
 // deferred close has the right scope.
-func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, err error) {
-	ow := newObjectWalker(r.Storer)
-	err = ow.walkAllRefs()
-	if err != nil {
-		return h, err
-	}
+func (r *Repository) createNewObjectPack(cfg *RepackConfig, ow *objectWalker) (h plumbing.Hash, err error) {
 	objs := make([]plumbing.Hash, 0, len(ow.seen))
 	for h := range ow.seen {
 		objs = append(objs, h)
@@ -1857,6 +2493,103 @@ func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, er
 	return h, err
 }
 
+// createCruftObjectPack is a helper for RepackObjects writing the objects
+// not seen by the given walker, found in the given packs or loose, into a
+// cruft pack. The loose objects are deleted once packed, or when expired.
+func (r *Repository) createCruftObjectPack(
+	cfg *RepackConfig,
+	ow *objectWalker,
+	cps storer.CruftPackStorer,
+	packs []plumbing.Hash,
+) (h plumbing.Hash, err error) {
+	mtimes := make(map[plumbing.Hash]time.Time)
+	add := func(hash plumbing.Hash, t time.Time) error {
+		if ow.isSeen(hash) {
+			return nil
+		}
+
+		// An object may be in several packs, or both packed and loose,
+		// keep the most recent time.
+		if prev, ok := mtimes[hash]; !ok || t.After(prev) {
+			mtimes[hash] = t
+		}
+
+		return nil
+	}
+
+	for _, ph := range packs {
+		if err := cps.ForEachObjectPackTime(ph, add); err != nil {
+			return h, err
+		}
+	}
+
+	los, hasLoose := r.Storer.(storer.LooseObjectStorer)
+	var loose []plumbing.Hash
+	if hasLoose {
+		err = los.ForEachObjectHash(func(hash plumbing.Hash) error {
+			if ow.isSeen(hash) {
+				return nil
+			}
+
+			t, err := los.LooseObjectTime(hash)
+			if err != nil {
+				return err
+			}
+
+			loose = append(loose, hash)
+			return add(hash, t)
+		})
+		if err != nil {
+			return h, err
+		}
+	}
+
+	objs := make([]plumbing.Hash, 0, len(mtimes))
+	for hash, t := range mtimes {
+		if !cfg.CruftExpiration.IsZero() && t.Before(cfg.CruftExpiration) {
+			continue
+		}
+
+		objs = append(objs, hash)
+	}
+
+	if len(objs) > 0 {
+		h, err = r.writeCruftObjectPack(cfg, cps, objs, mtimes)
+		if err != nil {
+			return h, err
+		}
+	}
+
+	for _, hash := range loose {
+		if err := los.DeleteLooseObject(hash); err != nil {
+			return h, err
+		}
+	}
+
+	return h, nil
+}
+
+func (r *Repository) writeCruftObjectPack(
+	cfg *RepackConfig,
+	cps storer.CruftPackStorer,
+	objs []plumbing.Hash,
+	mtimes map[plumbing.Hash]time.Time,
+) (h plumbing.Hash, err error) {
+	wc, err := cps.CruftPackfileWriter(func(hash plumbing.Hash) time.Time {
+		return mtimes[hash]
+	})
+	if err != nil {
+		return h, err
+	}
+	defer ioutil.CheckClose(wc, &err)
+	scfg, err := r.Config()
+	if err != nil {
+		return h, err
+	}
+	enc := packfile.NewEncoder(wc, r.Storer, cfg.UseRefDeltas)
+	return enc.Encode(objs, scfg.Pack.Window)
+}
+
 func expandPartialHash(st storer.EncodedObjectStorer, prefix []byte) (hashes []plumbing.Hash) {
 	// The fast version is implemented by storage/filesystem.ObjectStorage.
 	type fastIter interface {
@@ -25,6 +25,10 @@ import (
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	formatcfg "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/format/mailmap"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
@@ -742,6 +746,47 @@ func (s *RepositorySuite) TestPlainOpenNotBare(c *C) {
 	c.Assert(r, NotNil)
 }
 
+func (s *RepositorySuite) TestPlainOpenReftable(c *C) {
+	dir := c.MkDir()
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.RepositoryFormatVersion = formatcfg.Version_1
+	cfg.Extensions.RefStorage = formatcfg.ReftableRefStorage
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)
+	c.Assert(r.Storer.SetReference(head), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("foo", &CommitOptions{
+		Author:    defaultSignature(),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	_, err = os.Stat(filepath.Join(dir, ".git", "refs", "heads", "master"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = os.Stat(filepath.Join(dir, ".git", "reftable", "tables.list"))
+	c.Assert(err, IsNil)
+
+	r, err = PlainOpen(dir)
+	c.Assert(err, IsNil)
+
+	ref, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.Master)
+	c.Assert(ref.Hash(), Equals, hash)
+}
+
 func (s *RepositorySuite) testPlainOpenGitFile(c *C, f func(string, string) string) {
 	fs := s.TemporalFilesystem(c)
 
@@ -1259,6 +1304,27 @@ func (s *RepositorySuite) TestFetch(c *C) {
 	c.Assert(branch.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
 }
 
+func (s *RepositorySuite) TestReferenceUpdaterOld(c *C) {
+	sto := memory.NewStorage()
+	name := plumbing.ReferenceName("refs/remotes/origin/master")
+	old := plumbing.NewHashReference(name, plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"))
+	c.Assert(sto.SetReference(old), IsNil)
+
+	u, err := newReferenceUpdater(sto)
+	c.Assert(err, IsNil)
+
+	// the reference changes after the caller read it
+	stale := plumbing.NewHashReference(name, plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a"))
+	updated, err := u.update(plumbing.NewHashReference(name, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")), stale)
+	c.Assert(err, IsNil)
+	c.Assert(updated, Equals, true)
+	c.Assert(u.commit(), Equals, storer.ErrReferenceHasChanged)
+
+	ref, err := sto.Reference(name)
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, old)
+}
+
 func (s *RepositorySuite) TestFetchContext(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	_, err := r.CreateRemote(&config.RemoteConfig{
@@ -2371,6 +2437,362 @@ func (s *RepositorySuite) TestLogLimitWithOtherParamsPass(c *C) {
 	c.Assert(iterErr, Equals, io.EOF)
 }
 
+func (s *RepositorySuite) assertLog(c *C, r *Repository, o *LogOptions, expected ...string) {
+	cIter, err := r.Log(o)
+	c.Assert(err, IsNil)
+
+	var hashes []string
+	err = cIter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash.String())
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, expected)
+}
+
+func (s *RepositorySuite) TestLogRevisions(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	s.assertLog(c, r, &LogOptions{
+		Revisions: []plumbing.Revision{"origin/branch..master"},
+	}, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	s.assertLog(c, r, &LogOptions{
+		Revisions: []plumbing.Revision{"..origin/branch"},
+	}, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	s.assertLog(c, r, &LogOptions{
+		Revisions: []plumbing.Revision{"origin/branch"},
+		Not:       []plumbing.Revision{"HEAD~1"},
+	}, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	s.assertLog(c, r, &LogOptions{
+		Revisions: []plumbing.Revision{"master", "^b029517f6300c2da0f4b651b8642506cd6aaf45d"},
+	},
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+		"b8e471f58bcbca63b07bda20e428190409c2db47",
+	)
+
+	s.assertLog(c, r, &LogOptions{
+		Not: []plumbing.Revision{"1669dce138d9b841a518c64b10914d88f5e488ea"},
+	},
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+	)
+
+	// the dots of a message search are not a range
+	s.assertLog(c, r, &LogOptions{
+		Revisions: []plumbing.Revision{"master^{/Creat..g}"},
+	},
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+		"b8e471f58bcbca63b07bda20e428190409c2db47",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	)
+
+	s.assertLog(c, r, &LogOptions{
+		Revisions: []plumbing.Revision{"origin/branch^{/Creat..g}..origin/branch"},
+	},
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+	)
+}
+
+func (s *RepositorySuite) TestLogRevisionsSymmetricDifference(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	cIter, err := r.Log(&LogOptions{
+		Revisions: []plumbing.Revision{"master...origin/branch"},
+		LeftRight: true,
+	})
+	c.Assert(err, IsNil)
+
+	lr, ok := cIter.(LeftRightCommitIter)
+	c.Assert(ok, Equals, true)
+
+	expected := []struct {
+		hash string
+		side CommitSide
+	}{
+		{"6ecf0ef2c2dffb796033e5a02219af86ec6584e5", LeftSide},
+		{"e8d3ffab552895c19b9fcf7aa264d277cde33881", RightSide},
+	}
+
+	for _, e := range expected {
+		commit, err := lr.Next()
+		c.Assert(err, IsNil)
+		c.Assert(commit.Hash.String(), Equals, e.hash)
+		c.Assert(lr.Side(commit.Hash), Equals, e.side)
+	}
+
+	_, err = lr.Next()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *RepositorySuite) TestLogFirstParent(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	s.assertLog(c, r, &LogOptions{FirstParent: true},
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+	)
+}
+
+func (s *RepositorySuite) TestLogAncestryPath(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	s.assertLog(c, r, &LogOptions{
+		Revisions:    []plumbing.Revision{"b8e471f58bcbca63b07bda20e428190409c2db47..master"},
+		AncestryPath: true,
+	},
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+	)
+}
+
+func (s *RepositorySuite) TestLogMerges(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	s.assertLog(c, r, &LogOptions{Merges: true},
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+	)
+
+	s.assertLog(c, r, &LogOptions{NoMerges: true},
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d",
+		"b8e471f58bcbca63b07bda20e428190409c2db47",
+	)
+}
+
+func (s *RepositorySuite) TestLogAuthorAndGrep(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	s.assertLog(c, r, &LogOptions{Author: regexp.MustCompile("Ripolles")},
+		"b8e471f58bcbca63b07bda20e428190409c2db47",
+	)
+
+	s.assertLog(c, r, &LogOptions{
+		Author: regexp.MustCompile("<mcuadros@gmail.com>"),
+		Grep:   regexp.MustCompile("^some"),
+	},
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+	)
+}
+
+func (s *RepositorySuite) TestLogMailmap(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	m := mailmap.New()
+	m.Add("Jane Doe", "jane@example.com", "", "MCUADROS@gmail.com")
+
+	s.assertLog(c, r, &LogOptions{
+		Mailmap: m,
+		Author:  regexp.MustCompile("^Jane Doe <jane@example.com>$"),
+		Grep:    regexp.MustCompile("^some"),
+	},
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+	)
+
+	iter, err := r.Log(&LogOptions{Mailmap: m})
+	c.Assert(err, IsNil)
+
+	commit, err := iter.Next()
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(commit.Author.Name, Equals, "Jane Doe")
+	c.Assert(commit.Committer.Email, Equals, "jane@example.com")
+}
+
+func (s *RepositorySuite) TestMailmap(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "mailmap", []byte(
+		"Jane Blob <jane@example.com>\n<joe@example.com> <joe@laptop>\n"), 0644), IsNil)
+	_, err = w.Add("mailmap")
+	c.Assert(err, IsNil)
+	_, err = w.Commit("mailmap\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, ".mailmap", []byte(
+		"Jane Doe <jane@example.com>\nJohn Doe <john@example.com>\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "other.mailmap", []byte(
+		"Joe <joe@home> <joe@laptop>\n"), 0644), IsNil)
+
+	for _, t := range []struct {
+		blob, file string
+		ids        map[string]string
+	}{
+		{"", "", map[string]string{
+			"jane <jane@example.com>": "Jane Doe <jane@example.com>",
+			"john <john@example.com>": "John Doe <john@example.com>",
+			"joe <joe@laptop>":        "joe <joe@laptop>",
+		}},
+		{"HEAD:mailmap", "other.mailmap", map[string]string{
+			"jane <jane@example.com>": "Jane Blob <jane@example.com>",
+			"john <john@example.com>": "John Doe <john@example.com>",
+			"joe <joe@laptop>":        "Joe <joe@home>",
+		}},
+		{"HEAD:missing", "missing", map[string]string{
+			"jane <jane@example.com>": "Jane Doe <jane@example.com>",
+		}},
+	} {
+		cfg, err := r.Config()
+		c.Assert(err, IsNil)
+		cfg.Mailmap.Blob = t.blob
+		cfg.Mailmap.File = t.file
+		c.Assert(r.SetConfig(cfg), IsNil)
+
+		m, err := r.Mailmap()
+		c.Assert(err, IsNil)
+
+		for id, expected := range t.ids {
+			email := id[strings.Index(id, "<")+1 : len(id)-1]
+			name, email := m.Map(id[:strings.Index(id, " <")], email)
+			c.Assert(fmt.Sprintf("%s <%s>", name, email), Equals, expected, Commentf("%s %s", t.blob, t.file))
+		}
+	}
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Mailmap.Blob = "HEAD"
+	c.Assert(r.SetConfig(cfg), IsNil)
+
+	_, err = r.Mailmap()
+	c.Assert(errors.Is(err, ErrUnexpectedObjectType), Equals, true)
+}
+
+func (s *RepositorySuite) TestLogFollow(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	content := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	var hashes []plumbing.Hash
+	commit := func(msg string, files map[string]string, removed ...string) {
+		for name, content := range files {
+			c.Assert(util.WriteFile(fs, name, []byte(content), 0644), IsNil)
+		}
+
+		for _, name := range removed {
+			c.Assert(fs.Remove(name), IsNil)
+		}
+
+		c.Assert(w.AddWithOptions(&AddOptions{All: true}), IsNil)
+		h, err := w.Commit(msg, &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+		hashes = append(hashes, h)
+	}
+
+	commit("add a\n", map[string]string{"a.txt": content, "other.txt": "foo\n"})
+	commit("modify a\n", map[string]string{"a.txt": content + "10\n"})
+	commit("modify other\n", map[string]string{"other.txt": "bar\n"})
+	commit("rename a to b\n", map[string]string{"b.txt": content + "10\n"}, "a.txt")
+	commit("modify b\n", map[string]string{"b.txt": content + "10\n11\n"})
+
+	fileName := "b.txt"
+	it, err := r.Log(&LogOptions{FileName: &fileName, Follow: true})
+	c.Assert(err, IsNil)
+
+	follow, ok := it.(object.CommitFollowIter)
+	c.Assert(ok, Equals, true)
+
+	var commits []plumbing.Hash
+	var paths []string
+	c.Assert(it.ForEach(func(commit *object.Commit) error {
+		commits = append(commits, commit.Hash)
+		paths = append(paths, follow.Path(commit.Hash))
+		return nil
+	}), IsNil)
+
+	c.Assert(commits, DeepEquals, []plumbing.Hash{hashes[4], hashes[3], hashes[1], hashes[0]})
+	c.Assert(paths, DeepEquals, []string{"b.txt", "b.txt", "a.txt", "a.txt"})
+
+	s.assertLog(c, r, &LogOptions{FileName: &fileName},
+		hashes[4].String(),
+		hashes[3].String(),
+	)
+
+	since := time.Unix(0, 0)
+	it, err = r.Log(&LogOptions{FileName: &fileName, Follow: true, Since: &since})
+	c.Assert(err, IsNil)
+	_, ok = it.(object.CommitFollowIter)
+	c.Assert(ok, Equals, true)
+
+	_, err = r.Log(&LogOptions{Follow: true})
+	c.Assert(err, Equals, ErrFollowWithoutFileName)
+}
+
+func (s *RepositorySuite) TestLogRevisionsInvalidOrder(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.Log(&LogOptions{
+		Revisions: []plumbing.Revision{"master"},
+		Order:     LogOrderBSF,
+	})
+	c.Assert(err, NotNil)
+}
+
 func (s *RepositorySuite) TestConfigScoped(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	err := r.clone(context.Background(), &CloneOptions{
@@ -3211,6 +3633,173 @@ func (s *RepositorySuite) TestResolveRevisionWithErrors(c *C) {
 	}
 }
 
+func (s *RepositorySuite) TestResolveRevisionObject(c *C) {
+	f := fixtures.ByURL("https://github.com/git-fixtures/basic.git").One()
+	sto := filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+	r, err := Open(sto, f.DotGit())
+	c.Assert(err, IsNil)
+
+	datas := map[string]struct {
+		hash string
+		typ  plumbing.ObjectType
+	}{
+		"HEAD":                 {"6ecf0ef2c2dffb796033e5a02219af86ec6584e5", plumbing.CommitObject},
+		"HEAD:CHANGELOG":       {"d3ff53e0564a9f87d8e84b6e28e5060e517008aa", plumbing.BlobObject},
+		"HEAD:./go/example.go": {"880cd14280f4b9b6ed3986d6671f907d7cc2a198", plumbing.BlobObject},
+		"HEAD:go":              {"a39771a7651f97faf5c72e08224d857fc35133db", plumbing.TreeObject},
+		"HEAD~1:":              {"fb72698cab7617ac416264415f13224dfd7a165e", plumbing.TreeObject},
+		"HEAD^{tree}":          {"a8d315b2b1c615d43042c3a62402b8a54288cf5c", plumbing.TreeObject},
+		"v1.0.0^{tree}":        {"a8d315b2b1c615d43042c3a62402b8a54288cf5c", plumbing.TreeObject},
+		"HEAD^{commit}":        {"6ecf0ef2c2dffb796033e5a02219af86ec6584e5", plumbing.CommitObject},
+		"d3ff53e":              {"d3ff53e0564a9f87d8e84b6e28e5060e517008aa", plumbing.BlobObject},
+		":/binary file":        {"35e85108805c84807bc66a02d91535e1e24b38b9", plumbing.CommitObject},
+	}
+
+	for rev, expected := range datas {
+		h, t, err := r.ResolveRevisionObject(plumbing.Revision(rev))
+		c.Assert(err, IsNil, Commentf("while checking %s", rev))
+		c.Check(h.String(), Equals, expected.hash, Commentf("while checking %s", rev))
+		c.Check(t, Equals, expected.typ, Commentf("while checking %s", rev))
+	}
+
+	h, err := r.ResolveRevision("HEAD:CHANGELOG")
+	c.Assert(err, IsNil)
+	c.Assert(h.String(), Equals, "d3ff53e0564a9f87d8e84b6e28e5060e517008aa")
+}
+
+func (s *RepositorySuite) TestResolveRevisionObjectAnnotated(c *C) {
+	f := fixtures.ByURL("https://github.com/git-fixtures/tags.git").One()
+	sto := filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+	r, err := Open(sto, f.DotGit())
+	c.Assert(err, IsNil)
+
+	datas := map[string]struct {
+		hash string
+		typ  plumbing.ObjectType
+	}{
+		"annotated-tag":          {"b742a2a9fa0afcfa9a6fad080980fbc26b007c69", plumbing.TagObject},
+		"annotated-tag^{tag}":    {"b742a2a9fa0afcfa9a6fad080980fbc26b007c69", plumbing.TagObject},
+		"annotated-tag^{}":       {"f7b877701fbf855b44c0a9e86f3fdce2c298b07f", plumbing.CommitObject},
+		"annotated-tag^{commit}": {"f7b877701fbf855b44c0a9e86f3fdce2c298b07f", plumbing.CommitObject},
+		"tree-tag^{}":            {"70846e9a10ef7b41064b40f07713d5b8b9a8fc73", plumbing.TreeObject},
+		"blob-tag^{}":            {"e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", plumbing.BlobObject},
+	}
+
+	for rev, expected := range datas {
+		h, t, err := r.ResolveRevisionObject(plumbing.Revision(rev))
+		c.Assert(err, IsNil, Commentf("while checking %s", rev))
+		c.Check(h.String(), Equals, expected.hash, Commentf("while checking %s", rev))
+		c.Check(t, Equals, expected.typ, Commentf("while checking %s", rev))
+	}
+
+	_, _, err = r.ResolveRevisionObject("blob-tag^{commit}")
+	c.Assert(errors.Is(err, ErrUnexpectedObjectType), Equals, true)
+
+	_, _, err = r.ResolveRevisionObject("master^{tag}")
+	c.Assert(errors.Is(err, ErrUnexpectedObjectType), Equals, true)
+}
+
+func (s *RepositorySuite) TestResolveRevisionIndex(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	ours := plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa")
+	theirs := plumbing.NewHash("880cd14280f4b9b6ed3986d6671f907d7cc2a198")
+	other := plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c")
+
+	err = r.Storer.SetIndex(&index.Index{Version: 2, Entries: []*index.Entry{
+		{Name: "bar", Hash: other, Mode: filemode.Regular},
+		{Name: "foo", Hash: ours, Mode: filemode.Regular, Stage: index.OurMode},
+		{Name: "foo", Hash: theirs, Mode: filemode.Regular, Stage: index.TheirMode},
+	}})
+	c.Assert(err, IsNil)
+
+	h, t, err := r.ResolveRevisionObject(":2:foo")
+	c.Assert(err, IsNil)
+	c.Assert(*h, Equals, ours)
+	c.Assert(t, Equals, plumbing.BlobObject)
+
+	h, _, err = r.ResolveRevisionObject(":3:foo")
+	c.Assert(err, IsNil)
+	c.Assert(*h, Equals, theirs)
+
+	h, _, err = r.ResolveRevisionObject(":bar")
+	c.Assert(err, IsNil)
+	c.Assert(*h, Equals, other)
+
+	_, _, err = r.ResolveRevisionObject(":foo")
+	c.Assert(err, Equals, index.ErrEntryNotFound)
+}
+
+func (s *RepositorySuite) TestResolveRevisionUpstream(c *C) {
+	url := s.GetLocalRepositoryURL(
+		fixtures.ByURL("https://github.com/git-fixtures/basic.git").One(),
+	)
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{URL: url})
+	c.Assert(err, IsNil)
+
+	remoteHead := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	err = r.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/master", remoteHead))
+	c.Assert(err, IsNil)
+
+	for _, rev := range []string{"@{u}", "@{upstream}", "master@{u}", "HEAD@{upstream}", "@{push}"} {
+		h, err := r.ResolveRevision(plumbing.Revision(rev))
+		c.Assert(err, IsNil, Commentf("while checking %s", rev))
+		c.Check(*h, Equals, remoteHead, Commentf("while checking %s", rev))
+	}
+
+	err = r.CreateBranch(&config.Branch{Name: "local"})
+	c.Assert(err, IsNil)
+
+	_, err = r.ResolveRevision("local@{u}")
+	c.Assert(errors.Is(err, ErrUpstreamNotFound), Equals, true)
+
+	_, err = r.ResolveRevision("@{1}")
+	c.Assert(err, Equals, ErrReflogNotSupported)
+}
+
+func (s *RepositorySuite) TestResolveRevisionCheckout(c *C) {
+	fs := fixtures.Basic().One().DotGit()
+	r, err := Open(filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil)
+	c.Assert(err, IsNil)
+
+	head := "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	branch := "e8d3ffab552895c19b9fcf7aa264d277cde33881"
+	err = util.WriteFile(fs, "logs/HEAD", []byte(strings.Join([]string{
+		plumbing.ZeroHash.String() + " " + head + " foo <foo@foo.com> 1500000000 +0000\tclone: from origin",
+		head + " " + branch + " foo <foo@foo.com> 1500000001 +0000\tcheckout: moving from master to branch",
+		branch + " " + branch + " foo <foo@foo.com> 1500000002 +0000\tcommit: foo",
+		branch + " " + head + " foo <foo@foo.com> 1500000003 +0000\tcheckout: moving from branch to " + head,
+	}, "\n")+"\n"), 0644)
+	c.Assert(err, IsNil)
+
+	h, err := r.ResolveRevision("@{-1}")
+	c.Assert(err, IsNil)
+	c.Assert(h.String(), Equals, branch)
+
+	h, err = r.ResolveRevision("@{-2}")
+	c.Assert(err, IsNil)
+	c.Assert(h.String(), Equals, head)
+
+	_, err = r.ResolveRevision("@{-3}")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	r, err = Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.ResolveRevision("@{-1}")
+	c.Assert(err, Equals, ErrReflogNotSupported)
+}
+
+func (s *RepositorySuite) TestResolveRevisionPastRoot(c *C) {
+	_, err := s.Repository.ResolveRevision("HEAD~100")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = s.Repository.ResolveRevision("b029517f6300c2da0f4b651b8642506cd6aaf45d~1")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
 func (s *RepositorySuite) testRepackObjects(
 	c *C, deleteTime time.Time, expectedPacks int) {
 	srcFs := fixtures.ByTag("unpacked").One().DotGit()
@@ -3276,6 +3865,89 @@ func (s *RepositorySuite) TestRepackObjectsWithNoDelete(c *C) {
 	s.testRepackObjects(c, time.Unix(0, 1), 3)
 }
 
+func (s *RepositorySuite) TestRepackObjectsCruftNotSupported(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	err = r.RepackObjects(&RepackConfig{Cruft: true})
+	c.Assert(err, Equals, ErrCruftPacksNotSupported)
+}
+
+func (s *RepositorySuite) TestRepackObjectsCruft(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	sto := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+
+	r, err := Open(sto, fs)
+	c.Assert(err, IsNil)
+
+	obj := sto.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("unreachable"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	unreachable, err := sto.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	looseTime, err := sto.LooseObjectTime(unreachable)
+	c.Assert(err, IsNil)
+
+	err = r.RepackObjects(&RepackConfig{Cruft: true})
+	c.Assert(err, IsNil)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 2)
+
+	err = sto.ForEachObjectHash(func(plumbing.Hash) error {
+		return fmt.Errorf("unexpected loose object")
+	})
+	c.Assert(err, IsNil)
+
+	sto.Reindex()
+	_, err = sto.EncodedObject(plumbing.BlobObject, unreachable)
+	c.Assert(err, IsNil)
+
+	var cruft []plumbing.Hash
+	for _, p := range packs {
+		err = sto.ForEachObjectPackTime(p, func(h plumbing.Hash, t time.Time) error {
+			if h == unreachable {
+				cruft = append(cruft, p)
+				c.Assert(t.Unix(), Equals, looseTime.Unix())
+			}
+
+			return nil
+		})
+		c.Assert(err, IsNil)
+	}
+	c.Assert(cruft, HasLen, 1)
+
+	// A second repack keeps the time of the unreachable object.
+	err = r.RepackObjects(&RepackConfig{Cruft: true})
+	c.Assert(err, IsNil)
+
+	packs, err = sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 2)
+	c.Assert(packs[0] == cruft[0] || packs[1] == cruft[0], Equals, true)
+
+	err = r.RepackObjects(&RepackConfig{
+		Cruft:           true,
+		CruftExpiration: looseTime.Add(time.Hour),
+	})
+	c.Assert(err, IsNil)
+
+	packs, err = sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 1)
+
+	sto.Reindex()
+	_, err = sto.EncodedObject(plumbing.BlobObject, unreachable)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
 func ExecuteOnPath(c *C, path string, cmds ...string) error {
 	for _, cmd := range cmds {
 		err := executeOnPath(path, cmd)
@@ -0,0 +1,286 @@
+package git
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/mailmap"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ContributorStats are the commits of a contributor, returned by
+// Repository.Shortlog.
+type ContributorStats struct {
+	// Name of the contributor, or the value of the trailer if it is not an
+	// identity.
+	Name string
+	// Email of the contributor, only set if ShortlogOptions.Email is set.
+	Email string
+	// Commits are the commits of the contributor, from the oldest to the
+	// newest.
+	Commits []plumbing.Hash
+	// Subjects are the subjects of the commits, in the same order.
+	Subjects []string
+	// Additions and Deletions are the lines added and deleted by the
+	// commits, only computed if ShortlogOptions.LineStats is set.
+	Additions int
+	Deletions int
+}
+
+// Shortlog groups the commits of the given revisions by contributor, as
+// `git shortlog` does, returning the contributors sorted by name, or by
+// number of commits if SortByCommits is set.
+func (r *Repository) Shortlog(o *ShortlogOptions) ([]*ContributorStats, error) {
+	if o == nil {
+		o = &ShortlogOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := o.Mailmap
+	if m == nil {
+		var err error
+		if m, err = r.Mailmap(); err != nil {
+			return nil, err
+		}
+	}
+
+	iter, err := r.Log(&LogOptions{
+		Revisions: o.Revisions,
+		NoMerges:  o.NoMerges,
+		Order:     LogOrderCommitterTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*ContributorStats)
+	var commits []*object.Commit
+	err = iter.ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		subject, _ := splitCommitMessage(c.Message)
+		for _, id := range shortlogIdentities(c, o, m) {
+			key := id.Name
+			if o.Email {
+				key += " <" + id.Email + ">"
+			}
+
+			stats, ok := byKey[key]
+			if !ok {
+				stats = &ContributorStats{Name: id.Name}
+				if o.Email {
+					stats.Email = id.Email
+				}
+
+				byKey[key] = stats
+			}
+
+			stats.Commits = append(stats.Commits, c.Hash)
+			stats.Subjects = append(stats.Subjects, subject)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if o.LineStats {
+		lines, err := commitLineStats(commits, o.Workers)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, stats := range byKey {
+			for _, h := range stats.Commits {
+				stats.Additions += lines[h].Addition
+				stats.Deletions += lines[h].Deletion
+			}
+		}
+	}
+
+	result := make([]*ContributorStats, 0, len(byKey))
+	for _, stats := range byKey {
+		// the commits were walked from the newest to the oldest
+		for i, j := 0, len(stats.Commits)-1; i < j; i, j = i+1, j-1 {
+			stats.Commits[i], stats.Commits[j] = stats.Commits[j], stats.Commits[i]
+			stats.Subjects[i], stats.Subjects[j] = stats.Subjects[j], stats.Subjects[i]
+		}
+
+		result = append(result, stats)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if o.SortByCommits && len(a.Commits) != len(b.Commits) {
+			return len(a.Commits) > len(b.Commits)
+		}
+
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+
+		return a.Email < b.Email
+	})
+
+	return result, nil
+}
+
+// shortlogIdentities returns the canonical identities the commit is grouped
+// by, the values of the trailer not being identities kept as names.
+func shortlogIdentities(c *object.Commit, o *ShortlogOptions, m *mailmap.Mailmap) []object.Signature {
+	switch o.Group {
+	case ShortlogGroupCommitter:
+		return []object.Signature{m.Signature(c.Committer)}
+	case ShortlogGroupTrailer:
+		var ids []object.Signature
+		seen := make(map[string]bool)
+		for _, value := range commitTrailers(c.Message, o.Trailer) {
+			id := object.Signature{Name: value}
+			if name, email, ok := parseIdentity(value); ok {
+				id = m.Signature(object.Signature{Name: name, Email: email})
+			}
+
+			// a commit is counted once for each contributor
+			key := id.Name + " <" + id.Email + ">"
+			if !seen[key] {
+				seen[key] = true
+				ids = append(ids, id)
+			}
+		}
+
+		return ids
+	default:
+		return []object.Signature{m.Signature(c.Author)}
+	}
+}
+
+// parseIdentity parses an identity formatted as "Name <email>".
+func parseIdentity(s string) (name, email string, ok bool) {
+	left := strings.IndexByte(s, '<')
+	if left < 0 || !strings.HasSuffix(s, ">") {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(s[:left]), s[left+1 : len(s)-1], true
+}
+
+// commitTrailers returns the values of the trailers of the message with the
+// given key, compared case-insensitively. The trailers are the lines
+// formatted as "Key: value" of the last paragraph of the message, not being
+// its subject, the lines starting with a whitespace continuing the value
+// of the previous one. The paragraph is not a trailer block if any of its
+// lines is not a trailer.
+func commitTrailers(msg, key string) []string {
+	lines := strings.Split(strings.TrimRight(msg, "\n"), "\n")
+	start := len(lines)
+	for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+		start--
+	}
+
+	// the first paragraph is the subject
+	if start == 0 || strings.TrimSpace(strings.Join(lines[:start], "")) == "" {
+		return nil
+	}
+
+	var keys, values []string
+	for _, line := range lines[start:] {
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(values) == 0 {
+				return nil
+			}
+
+			values[len(values)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i <= 0 || !isTrailerKey(line[:i]) {
+			return nil
+		}
+
+		keys = append(keys, line[:i])
+		values = append(values, strings.TrimSpace(line[i+1:]))
+	}
+
+	var result []string
+	for i, k := range keys {
+		if strings.EqualFold(k, key) && values[i] != "" {
+			result = append(result, values[i])
+		}
+	}
+
+	return result
+}
+
+func isTrailerKey(s string) bool {
+	for _, c := range s {
+		if !(c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// commitLineStats returns the lines added and deleted by the commits, the
+// merge commits being skipped, computed with the given number of
+// goroutines.
+func commitLineStats(commits []*object.Commit, workers int) (map[plumbing.Hash]object.FileStat, error) {
+	queue := make(chan *object.Commit)
+	result := make(map[plumbing.Hash]object.FileStat, len(commits))
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range queue {
+				stats, err := c.Stats()
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+
+				var total object.FileStat
+				for _, s := range stats {
+					total.Addition += s.Addition
+					total.Deletion += s.Deletion
+				}
+
+				result[c.Hash] = total
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, c := range commits {
+		if c.NumParents() > 1 {
+			continue
+		}
+
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+
+		queue <- c
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return result, firstErr
+}
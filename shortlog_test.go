@@ -0,0 +1,132 @@
+package git
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/mailmap"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type ShortlogSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ShortlogSuite{})
+
+func (s *ShortlogSuite) commit(c *C, r *Repository, author, email, msg string, n int) plumbing.Hash {
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	name := fmt.Sprintf("file%d", n%2)
+	content, _ := util.ReadFile(w.Filesystem, name)
+	content = append(content, fmt.Sprintf("%d\n", n)...)
+	c.Assert(util.WriteFile(w.Filesystem, name, content, 0644), IsNil)
+	_, err = w.Add(name)
+	c.Assert(err, IsNil)
+
+	when := time.Date(2020, 1, 1, 0, n, 0, 0, time.UTC)
+	h, err := w.Commit(msg, &CommitOptions{
+		Author:    &object.Signature{Name: author, Email: email, When: when},
+		Committer: &object.Signature{Name: "Comm", Email: "comm@x", When: when},
+	})
+	c.Assert(err, IsNil)
+	return h
+}
+
+func (s *ShortlogSuite) TestShortlog(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	first := s.commit(c, r, "alice", "alice@a", "first thing\n", 1)
+	second := s.commit(c, r, "Bob", "bob@b", "second\nline two of subject\n\nbody here\n", 2)
+	third := s.commit(c, r, "alice smith", "alice@work", "third\n\n"+
+		"Co-authored-by: Bob <bob@b>\nCo-authored-by: Carol <carol@c>\nReviewed-by: someone\n", 3)
+	last := s.commit(c, r, "Zed", "zed@z", "last\n\nco-authored-by: not an\n  ident\n", 4)
+
+	c.Assert(util.WriteFile(fs, ".mailmap", []byte(
+		"Alice Smith <alice@a>\nAlice Smith <alice@a> <alice@work>\n"), 0644), IsNil)
+
+	result, err := r.Shortlog(&ShortlogOptions{LineStats: true, Workers: 2})
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, []*ContributorStats{{
+		Name:      "Alice Smith",
+		Commits:   []plumbing.Hash{first, third},
+		Subjects:  []string{"first thing", "third"},
+		Additions: 2,
+	}, {
+		Name:      "Bob",
+		Commits:   []plumbing.Hash{second},
+		Subjects:  []string{"second line two of subject"},
+		Additions: 1,
+	}, {
+		Name:      "Zed",
+		Commits:   []plumbing.Hash{last},
+		Subjects:  []string{"last"},
+		Additions: 1,
+	}})
+
+	result, err = r.Shortlog(&ShortlogOptions{
+		Email:         true,
+		SortByCommits: true,
+		Mailmap:       mailmap.New(),
+		Revisions:     []plumbing.Revision{"HEAD~1"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(result, HasLen, 3)
+	c.Assert(result[0].Name, Equals, "Bob")
+	c.Assert(result[0].Email, Equals, "bob@b")
+	c.Assert(result[1].Name, Equals, "alice")
+	c.Assert(result[2].Name, Equals, "alice smith")
+	c.Assert(result[2].Email, Equals, "alice@work")
+}
+
+func (s *ShortlogSuite) TestShortlogGroup(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	first := s.commit(c, r, "alice", "alice@a", "first\n\nCo-authored-by: Bob <BOB@b>\n", 1)
+	second := s.commit(c, r, "Bob", "bob@b", "second\n\n"+
+		"Co-authored-by: Carol <carol@c>\nCO-AUTHORED-BY: Carol <carol@c>\n", 2)
+	third := s.commit(c, r, "Carol", "carol@c", "third\n\nCo-authored-by: not an\n  ident\n", 3)
+	s.commit(c, r, "Dan", "dan@d", "Co-authored-by: Bob <bob@b>\n", 4)
+
+	m := mailmap.New()
+	m.Add("Robert", "", "", "bob@b")
+
+	result, err := r.Shortlog(&ShortlogOptions{
+		Group:   ShortlogGroupTrailer,
+		Trailer: "co-authored-by",
+		Mailmap: m,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, []*ContributorStats{{
+		Name:     "Carol",
+		Commits:  []plumbing.Hash{second},
+		Subjects: []string{"second"},
+	}, {
+		Name:     "Robert",
+		Commits:  []plumbing.Hash{first},
+		Subjects: []string{"first"},
+	}, {
+		Name:     "not an ident",
+		Commits:  []plumbing.Hash{third},
+		Subjects: []string{"third"},
+	}})
+
+	result, err = r.Shortlog(&ShortlogOptions{Group: ShortlogGroupCommitter, Mailmap: m})
+	c.Assert(err, IsNil)
+	c.Assert(result, HasLen, 1)
+	c.Assert(result[0].Name, Equals, "Comm")
+	c.Assert(result[0].Commits, HasLen, 4)
+
+	_, err = r.Shortlog(&ShortlogOptions{Group: ShortlogGroupTrailer})
+	c.Assert(err, Equals, ErrMissingTrailer)
+}
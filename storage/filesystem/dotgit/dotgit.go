@@ -18,6 +18,7 @@ import (
 
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/utils/ioutil"
 
@@ -48,6 +49,8 @@ const (
 	packPrefix = "pack-"
 	packExt    = ".pack"
 	idxExt     = ".idx"
+	revExt     = ".rev"
+	mtimesExt  = ".mtimes"
 )
 
 var (
@@ -57,6 +60,15 @@ var (
 	ErrIdxNotFound = errors.New("idx file not found")
 	// ErrPackfileNotFound is returned by Packfile when the packfile is not found
 	ErrPackfileNotFound = errors.New("packfile not found")
+	// ErrRevNotFound is returned by ObjectPackRev when the packfile has no
+	// reverse index
+	ErrRevNotFound = errors.New("rev file not found")
+	// ErrMtimesNotFound is returned by ObjectPackMtimes when the packfile has
+	// no mtimes file
+	ErrMtimesNotFound = errors.New("mtimes file not found")
+	// ErrIndexLocked is returned by IndexLockWriter when the index is locked
+	// by another writer
+	ErrIndexLocked = errors.New("index is locked")
 	// ErrConfigNotFound is returned by Config when the config is not found
 	ErrConfigNotFound = errors.New("config file not found")
 	// ErrPackedRefsDuplicatedRef is returned when a duplicated reference is
@@ -189,11 +201,131 @@ func (d *DotGit) IndexWriter() (billy.File, error) {
 	return d.fs.Create(indexPath)
 }
 
+// IndexLockWriter returns a file pointer for write to the index lock file,
+// which replaces the index file when closed, unless a write failed. The
+// index is locked until then, ErrIndexLocked being returned if it's
+// already locked.
+func (d *DotGit) IndexLockWriter() (billy.File, error) {
+	f, err := d.fs.OpenFile(indexPath+lockExt, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrIndexLocked
+		}
+
+		return nil, err
+	}
+
+	return &lockFile{File: f, fs: d.fs, path: indexPath}, nil
+}
+
+// IndexCheckAndLockWriter returns the lock file of IndexLockWriter if the
+// checksum of the index file is old, checked once the index is locked. The
+// lock is released and storer.ErrIndexHasChanged returned otherwise.
+func (d *DotGit) IndexCheckAndLockWriter(old plumbing.Hash) (billy.File, error) {
+	f, err := d.IndexLockWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := d.IndexChecksum()
+	if err == nil && current != old {
+		err = storer.ErrIndexHasChanged
+	}
+
+	if err != nil {
+		_ = f.(*lockFile).release()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// IndexChecksum returns the checksum of the index file, read from its
+// trailer, or the zero hash if it does not exist.
+func (d *DotGit) IndexChecksum() (plumbing.Hash, error) {
+	f, err := d.fs.Open(indexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return plumbing.ZeroHash, nil
+		}
+
+		return plumbing.ZeroHash, err
+	}
+
+	defer f.Close()
+
+	var h plumbing.Hash
+	if _, err := f.Seek(-int64(len(h)), io.SeekEnd); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	_, err = io.ReadFull(f, h[:])
+	return h, err
+}
+
+// lockFile is a lock file replacing the locked file when closed.
+type lockFile struct {
+	billy.File
+	fs   billy.Filesystem
+	path string
+	err  error
+}
+
+func (f *lockFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+
+	return n, err
+}
+
+func (f *lockFile) Close() error {
+	err := f.File.Close()
+	if err == nil {
+		err = f.err
+	}
+
+	if err == nil {
+		err = f.fs.Rename(f.File.Name(), f.path)
+	}
+
+	if err != nil {
+		_ = f.fs.Remove(f.File.Name())
+	}
+
+	return err
+}
+
+// release removes the lock file without replacing the locked file.
+func (f *lockFile) release() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+
+	return f.fs.Remove(f.File.Name())
+}
+
 // Index returns a file pointer for read to the index file
 func (d *DotGit) Index() (billy.File, error) {
 	return d.fs.Open(indexPath)
 }
 
+// IndexStat returns the os.FileInfo of the index file
+func (d *DotGit) IndexStat() (os.FileInfo, error) {
+	return d.fs.Lstat(indexPath)
+}
+
+// sharedIndexPrefix is the prefix of the shared index files, followed by
+// their hash.
+const sharedIndexPrefix = "sharedindex"
+
+// SharedIndex returns a file pointer for read to the shared index file with
+// the given hash, used by split indexes
+func (d *DotGit) SharedIndex(h plumbing.Hash) (billy.File, error) {
+	return d.fs.Open(fmt.Sprintf("%s.%s", sharedIndexPrefix, h))
+}
+
 // ShallowWriter returns a file pointer for write to the shallow file
 func (d *DotGit) ShallowWriter() (billy.File, error) {
 	return d.fs.Create(shallowPath)
@@ -220,6 +352,19 @@ func (d *DotGit) NewObjectPack() (*PackWriter, error) {
 	return newPackWrite(d.fs)
 }
 
+// NewCruftObjectPack return a writer for a new cruft pack, it saves the
+// packfile to disk along with its index and its mtimes file, holding the
+// modification time of each object as returned by mtime.
+func (d *DotGit) NewCruftObjectPack(mtime func(plumbing.Hash) time.Time) (*PackWriter, error) {
+	w, err := d.NewObjectPack()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mtime = mtime
+	return w, nil
+}
+
 // ObjectPacks returns the list of availables packfiles
 func (d *DotGit) ObjectPacks() ([]plumbing.Hash, error) {
 	if !d.options.ExclusiveAccess {
@@ -321,6 +466,55 @@ func (d *DotGit) ObjectPackIdx(hash plumbing.Hash) (billy.File, error) {
 	return d.objectPackOpen(hash, `idx`)
 }
 
+// ObjectPackRev returns a fs.File of the reverse index file for a given
+// packfile, or ErrRevNotFound if the packfile has none.
+func (d *DotGit) ObjectPackRev(hash plumbing.Hash) (billy.File, error) {
+	return d.objectPackOpenOptional(hash, revExt, ErrRevNotFound)
+}
+
+// ObjectPackMtimes returns a fs.File of the mtimes file for a given
+// packfile, or ErrMtimesNotFound if the packfile has none.
+func (d *DotGit) ObjectPackMtimes(hash plumbing.Hash) (billy.File, error) {
+	return d.objectPackOpenOptional(hash, mtimesExt, ErrMtimesNotFound)
+}
+
+func (d *DotGit) objectPackOpenOptional(hash plumbing.Hash, ext string, notFound error) (billy.File, error) {
+	err := d.hasPack(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := d.fs.Open(d.objectPackPath(hash, ext[1:]))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, notFound
+		}
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ObjectPackStat returns a os.FileInfo pointing the given packfile, if exists
+func (d *DotGit) ObjectPackStat(hash plumbing.Hash) (os.FileInfo, error) {
+	err := d.hasPack(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := d.fs.Stat(d.objectPackPath(hash, `pack`))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrPackfileNotFound
+		}
+
+		return nil, err
+	}
+
+	return fi, nil
+}
+
 func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) error {
 	d.cleanPackList()
 
@@ -339,6 +533,14 @@ func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) er
 	if err != nil {
 		return err
 	}
+
+	for _, ext := range []string{revExt, mtimesExt} {
+		err := d.fs.Remove(d.objectPackPath(hash, ext[1:]))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
 	return d.fs.Remove(d.objectPackPath(hash, `idx`))
 }
 
@@ -702,17 +904,9 @@ func (d *DotGit) checkReferenceAndTruncate(f billy.File, old *plumbing.Reference
 }
 
 func (d *DotGit) SetRef(r, old *plumbing.Reference) error {
-	var content string
-	switch r.Type() {
-	case plumbing.SymbolicReference:
-		content = fmt.Sprintf("ref: %s\n", r.Target())
-	case plumbing.HashReference:
-		content = fmt.Sprintln(r.Hash().String())
-	}
-
 	fileName := r.Name().String()
 
-	return d.setRef(fileName, content, old)
+	return d.setRef(fileName, refContent(r), old)
 }
 
 // Refs scans the git directory collecting references, which it returns.
@@ -3,6 +3,8 @@ package dotgit
 import (
 	"bufio"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,12 +12,14 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-billy/v5/util"
 	fixtures "github.com/go-git/go-git-fixtures/v4"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/stretchr/testify/assert"
 	. "gopkg.in/check.v1"
@@ -245,6 +249,73 @@ func (s *SuiteDotGit) TestRemoveRefFromPackedRefs(c *C) {
 		"e8d3ffab552895c19b9fcf7aa264d277cde33881 refs/remotes/origin/branch\n")
 }
 
+func (s *SuiteDotGit) TestUpdateRefs(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	dir := New(fs)
+
+	master := plumbing.NewReferenceFromStrings("refs/heads/master", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	err := dir.UpdateRefs([]*storer.ReferenceUpdate{{
+		Name: "refs/heads/foo",
+		New:  plumbing.NewReferenceFromStrings("refs/heads/foo", "e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+	}, {
+		Name: "refs/heads/master",
+		Old:  master,
+	}, {
+		Name: "refs/remotes/origin/master",
+	}})
+	c.Assert(err, IsNil)
+
+	b, err := util.ReadFile(fs, packedRefsPath)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, ""+
+		"# pack-refs with: peeled fully-peeled \n"+
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881 refs/remotes/origin/branch\n")
+
+	ref, err := dir.Ref("refs/heads/foo")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	for _, name := range []string{"refs/heads/foo.lock", "refs/heads/master.lock", packedRefsPath + ".lock"} {
+		_, err = fs.Stat(name)
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+}
+
+func (s *SuiteDotGit) TestUpdateRefsError(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	dir := New(fs)
+
+	err := dir.UpdateRefs([]*storer.ReferenceUpdate{{
+		Name: "refs/heads/foo",
+		New:  plumbing.NewReferenceFromStrings("refs/heads/foo", "e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+	}, {
+		Name: "refs/heads/master",
+		Old:  plumbing.NewReferenceFromStrings("refs/heads/master", "e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+	}})
+	c.Assert(err, Equals, storage.ErrReferenceHasChanged)
+
+	_, err = dir.Ref("refs/heads/foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	f, err := fs.Create("refs/heads/master.lock")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	err = dir.UpdateRefs([]*storer.ReferenceUpdate{{
+		Name: "refs/heads/foo",
+		New:  plumbing.NewReferenceFromStrings("refs/heads/foo", "e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+	}, {
+		Name: "refs/heads/master",
+	}})
+	c.Assert(errors.Is(err, ErrRefLocked), Equals, true)
+
+	_, err = fs.Stat("refs/heads/foo.lock")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = dir.Ref("refs/heads/foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
 func (s *SuiteDotGit) TestRemoveRefFromReferenceFileAndPackedRefs(c *C) {
 	fs := fixtures.Basic().ByTag(".git").One().DotGit()
 	dir := New(fs)
@@ -551,6 +622,43 @@ func (s *SuiteDotGit) TestObjectPackIdx(c *C) {
 	c.Assert(idx.Close(), IsNil)
 }
 
+func (s *SuiteDotGit) TestObjectPackRevAndMtimes(c *C) {
+	f := fixtures.Basic().ByTag(".git").One()
+	fs := f.DotGit()
+	dir := New(fs)
+
+	h := plumbing.NewHash(f.PackfileHash)
+	rev, err := dir.ObjectPackRev(h)
+	c.Assert(err, Equals, ErrRevNotFound)
+	c.Assert(rev, IsNil)
+
+	mtimes, err := dir.ObjectPackMtimes(h)
+	c.Assert(err, Equals, ErrMtimesNotFound)
+	c.Assert(mtimes, IsNil)
+
+	for _, ext := range []string{"rev", "mtimes"} {
+		err = util.WriteFile(fs, fmt.Sprintf("objects/pack/pack-%s.%s", h, ext), nil, 0644)
+		c.Assert(err, IsNil)
+	}
+
+	rev, err = dir.ObjectPackRev(h)
+	c.Assert(err, IsNil)
+	c.Assert(filepath.Ext(rev.Name()), Equals, ".rev")
+	c.Assert(rev.Close(), IsNil)
+
+	mtimes, err = dir.ObjectPackMtimes(h)
+	c.Assert(err, IsNil)
+	c.Assert(filepath.Ext(mtimes.Name()), Equals, ".mtimes")
+	c.Assert(mtimes.Close(), IsNil)
+
+	err = dir.DeleteOldObjectPackAndIndex(h, time.Time{})
+	c.Assert(err, IsNil)
+
+	files, err := fs.ReadDir("objects/pack")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
+
 func (s *SuiteDotGit) TestObjectPackNotFound(c *C) {
 	fs := fixtures.Basic().ByTag(".git").One().DotGit()
 	dir := New(fs)
@@ -0,0 +1,202 @@
+package dotgit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/ioutil"
+)
+
+const lockExt = ".lock"
+
+// ErrRefLocked is returned by UpdateRefs when a reference, or the
+// packed-refs file, is locked by another writer.
+var ErrRefLocked = errors.New("reference is locked")
+
+// UpdateRefs applies the given updates atomically. Every updated reference,
+// and the packed-refs file if any reference is deleted, is locked creating
+// a .lock file next to it. The new values are only written once all the
+// locks are held and all the references have their expected values,
+// otherwise no reference is changed.
+func (d *DotGit) UpdateRefs(updates []*storer.ReferenceUpdate) (err error) {
+	var locks []string
+	var files []billy.File
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+
+		for _, l := range locks {
+			if l != "" {
+				_ = d.fs.Remove(l)
+			}
+		}
+	}()
+
+	lock := func(name string) (billy.File, error) {
+		f, err := d.fs.OpenFile(name+lockExt, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if err != nil {
+			if os.IsExist(err) {
+				return nil, fmt.Errorf("%w: %s", ErrRefLocked, name)
+			}
+
+			return nil, err
+		}
+
+		locks = append(locks, name+lockExt)
+		files = append(files, f)
+		return f, nil
+	}
+
+	deleted := make(map[plumbing.ReferenceName]bool)
+	for _, u := range updates {
+		if _, err := lock(u.Name.String()); err != nil {
+			return err
+		}
+
+		if u.IsDelete() {
+			deleted[u.Name] = true
+		}
+	}
+
+	var packed billy.File
+	if len(deleted) > 0 {
+		if packed, err = lock(packedRefsPath); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range updates {
+		current, err := d.Ref(u.Name)
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		if err := u.Check(current); err != nil {
+			return err
+		}
+	}
+
+	for i, u := range updates {
+		if u.IsDelete() {
+			continue
+		}
+
+		if _, err := files[i].Write([]byte(refContent(u.New))); err != nil {
+			return err
+		}
+	}
+
+	if packed != nil {
+		renamed, err := d.rewritePackedRefsWithoutRefs(packed, deleted)
+		if err != nil {
+			return err
+		}
+
+		if renamed {
+			locks[len(locks)-1] = ""
+		}
+	}
+
+	for i, u := range updates {
+		if err := files[i].Close(); err != nil {
+			return err
+		}
+
+		name := u.Name.String()
+		if u.IsDelete() {
+			if err := d.fs.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
+			continue
+		}
+
+		if err := d.fs.Rename(locks[i], name); err != nil {
+			return err
+		}
+
+		// The lock file does not exist anymore, so it must not be removed,
+		// since it may be the lock of another writer.
+		locks[i] = ""
+	}
+
+	return nil
+}
+
+// rewritePackedRefsWithoutRefs writes to the locked packed-refs file the
+// packed references, but the given ones, and renames it over packed-refs.
+// It returns false, leaving the lock file unchanged, if none of the
+// references is packed.
+func (d *DotGit) rewritePackedRefsWithoutRefs(lock billy.File, names map[plumbing.ReferenceName]bool) (renamed bool, err error) {
+	pr, err := d.openAndLockPackedRefs(false)
+	if err != nil {
+		return false, err
+	}
+
+	if pr == nil {
+		return false, nil
+	}
+
+	defer ioutil.CheckClose(pr, &err)
+
+	s := bufio.NewScanner(pr)
+	w := bufio.NewWriter(lock)
+	found, skipPeeled := false, false
+	for s.Scan() {
+		line := s.Text()
+		ref, err := d.processLine(line)
+		if err != nil {
+			return false, err
+		}
+
+		// The peeled value of an annotated tag follows its reference.
+		if len(line) > 0 && line[0] == '^' && skipPeeled {
+			continue
+		}
+
+		skipPeeled = ref != nil && names[ref.Name()]
+		if skipPeeled {
+			found = true
+			continue
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return false, err
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return false, err
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+
+	if err := d.rewritePackedRefsWhileLocked(lock, pr); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func refContent(r *plumbing.Reference) string {
+	switch r.Type() {
+	case plumbing.SymbolicReference:
+		return fmt.Sprintf("ref: %s\n", r.Target())
+	case plumbing.HashReference:
+		return fmt.Sprintln(r.Hash().String())
+	}
+
+	return ""
+}
@@ -1,14 +1,18 @@
 package dotgit
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"sync/atomic"
+	"time"
 
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/plumbing/format/mtimesfile"
 	"github.com/go-git/go-git/v5/plumbing/format/objfile"
 	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/format/revfile"
 	"github.com/go-git/go-git/v5/plumbing/hash"
 
 	"github.com/go-git/go-billy/v5"
@@ -30,6 +34,7 @@ type PackWriter struct {
 	parser   *packfile.Parser
 	writer   *idxfile.Writer
 	result   chan error
+	mtime    func(plumbing.Hash) time.Time
 }
 
 func newPackWrite(fs billy.Filesystem) (*PackWriter, error) {
@@ -144,6 +149,34 @@ func (w *PackWriter) save() error {
 		return err
 	}
 
+	rev, err := w.fs.Create(fmt.Sprintf("%s.rev", base))
+	if err != nil {
+		return err
+	}
+
+	if err := w.encodeRev(rev); err != nil {
+		return err
+	}
+
+	if err := rev.Close(); err != nil {
+		return err
+	}
+
+	if w.mtime != nil {
+		mtimes, err := w.fs.Create(fmt.Sprintf("%s.mtimes", base))
+		if err != nil {
+			return err
+		}
+
+		if err := w.encodeMtimes(mtimes); err != nil {
+			return err
+		}
+
+		if err := mtimes.Close(); err != nil {
+			return err
+		}
+	}
+
 	return w.fs.Rename(w.fw.Name(), fmt.Sprintf("%s.pack", base))
 }
 
@@ -158,6 +191,52 @@ func (w *PackWriter) encodeIdx(writer io.Writer) error {
 	return err
 }
 
+// encodeRev writes the reverse index of the packfile, and sets it in the
+// index so the notified index does not need to build an offset map.
+func (w *PackWriter) encodeRev(writer io.Writer) error {
+	idx, err := w.writer.Index()
+	if err != nil {
+		return err
+	}
+
+	rev, err := revfile.New(idx)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(writer)
+	e := revfile.NewEncoder(bw)
+	if _, err := e.Encode(rev); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	return idx.SetReverseIndex(rev)
+}
+
+func (w *PackWriter) encodeMtimes(writer io.Writer) error {
+	idx, err := w.writer.Index()
+	if err != nil {
+		return err
+	}
+
+	m, err := mtimesfile.New(idx, w.mtime)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(writer)
+	e := mtimesfile.NewEncoder(bw)
+	if _, err := e.Encode(m); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
 type syncedReader struct {
 	w io.Writer
 	r io.ReadSeeker
@@ -42,6 +42,11 @@ func (s *SuiteDotGit) TestNewObjectPack(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(stat.Size(), Equals, int64(1940))
 
+	revPath := fmt.Sprintf("objects/pack/pack-%s.rev", f.PackfileHash)
+	stat, err = fs.Stat(revPath)
+	c.Assert(err, IsNil)
+	c.Assert(stat.Size(), Equals, int64(176))
+
 	pf, err := fs.Open(pfPath)
 	c.Assert(err, IsNil)
 	pfs := packfile.NewScanner(pf)
@@ -1,9 +1,12 @@
 package filesystem
 
 import (
-	"bufio"
+	"bytes"
+	"io"
 	"os"
+	"time"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
 	"github.com/go-git/go-git/v5/utils/ioutil"
@@ -13,22 +16,46 @@ type IndexStorage struct {
 	dir *dotgit.DotGit
 }
 
+// SetIndex writes the index, locking it while it's written. The index is
+// encoded before taking the lock, so it's not replaced if it can't be
+// encoded.
 func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
-	f, err := s.dir.IndexWriter()
+	var buf bytes.Buffer
+	if err := index.NewEncoder(&buf).Encode(idx); err != nil {
+		return err
+	}
+
+	f, err := s.dir.IndexLockWriter()
 	if err != nil {
 		return err
 	}
 
 	defer ioutil.CheckClose(f, &err)
-	bw := bufio.NewWriter(f)
-	defer func() {
-		if e := bw.Flush(); err == nil && e != nil {
-			err = e
-		}
-	}()
+	_, err = buf.WriteTo(f)
+	return err
+}
 
-	e := index.NewEncoder(bw)
-	err = e.Encode(idx)
+// IndexChecksum returns the checksum of the index, or the zero hash if it
+// does not exist.
+func (s *IndexStorage) IndexChecksum() (plumbing.Hash, error) {
+	return s.dir.IndexChecksum()
+}
+
+// CheckAndSetIndex writes the index as SetIndex does, if the checksum of the
+// stored index is old once it's locked.
+func (s *IndexStorage) CheckAndSetIndex(idx *index.Index, old plumbing.Hash) (err error) {
+	var buf bytes.Buffer
+	if err := index.NewEncoder(&buf).Encode(idx); err != nil {
+		return err
+	}
+
+	f, err := s.dir.IndexCheckAndLockWriter(old)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	_, err = buf.WriteTo(f)
 	return err
 }
 
@@ -48,7 +75,25 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 
 	defer ioutil.CheckClose(f, &err)
 
-	d := index.NewDecoder(f)
+	d := index.NewDecoderWithSharedIndex(f, func(h plumbing.Hash) (io.ReadCloser, error) {
+		return s.dir.SharedIndex(h)
+	})
+
 	err = d.Decode(idx)
 	return idx, err
 }
+
+// IndexModTime returns the modification time of the index file, or the zero
+// time if it does not exist.
+func (s *IndexStorage) IndexModTime() (time.Time, error) {
+	fi, err := s.dir.IndexStat()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	return fi.ModTime(), nil
+}
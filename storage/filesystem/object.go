@@ -10,8 +10,10 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/plumbing/format/mtimesfile"
 	"github.com/go-git/go-git/v5/plumbing/format/objfile"
 	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/format/revfile"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
 	"github.com/go-git/go-git/v5/utils/ioutil"
@@ -87,10 +89,39 @@ func (s *ObjectStorage) loadIdxFile(h plumbing.Hash) (err error) {
 		return err
 	}
 
+	if err = s.loadRevFile(h, idxf); err != nil {
+		return err
+	}
+
 	s.index[h] = idxf
 	return err
 }
 
+// loadRevFile sets the reverse index of the given packfile in idx, when the
+// packfile has one. Reverse indexes that cannot be used are ignored, as the
+// offset lookups can still be resolved using the idx file alone.
+func (s *ObjectStorage) loadRevFile(h plumbing.Hash, idx *idxfile.MemoryIndex) (err error) {
+	f, err := s.dir.ObjectPackRev(h)
+	if err != nil {
+		if err == dotgit.ErrRevNotFound {
+			return nil
+		}
+
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	rev := new(revfile.ReverseIndex)
+	d := revfile.NewDecoder(f)
+	if d.Decode(rev) != nil || rev.PackfileChecksum != idx.PackfileChecksum {
+		return nil
+	}
+
+	_ = idx.SetReverseIndex(rev)
+	return nil
+}
+
 func (s *ObjectStorage) NewEncodedObject() plumbing.EncodedObject {
 	return &plumbing.MemoryObject{}
 }
@@ -105,14 +136,33 @@ func (s *ObjectStorage) PackfileWriter() (io.WriteCloser, error) {
 		return nil, err
 	}
 
+	s.notifyPackWriter(w)
+	return w, nil
+}
+
+// CruftPackfileWriter returns a writer for a cruft pack, saving the
+// modification time returned by mtime for each of its objects.
+func (s *ObjectStorage) CruftPackfileWriter(mtime func(plumbing.Hash) time.Time) (io.WriteCloser, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	w, err := s.dir.NewCruftObjectPack(mtime)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyPackWriter(w)
+	return w, nil
+}
+
+func (s *ObjectStorage) notifyPackWriter(w *dotgit.PackWriter) {
 	w.Notify = func(h plumbing.Hash, writer *idxfile.Writer) {
 		index, err := writer.Index()
 		if err == nil {
 			s.index[h] = index
 		}
 	}
-
-	return w, nil
 }
 
 // SetEncodedObject adds a new object to the storage.
@@ -888,5 +938,109 @@ func (s *ObjectStorage) ObjectPacks() ([]plumbing.Hash, error) {
 }
 
 func (s *ObjectStorage) DeleteOldObjectPackAndIndex(h plumbing.Hash, t time.Time) error {
-	return s.dir.DeleteOldObjectPackAndIndex(h, t)
+	if err := s.dir.DeleteOldObjectPackAndIndex(h, t); err != nil {
+		return err
+	}
+
+	// Forget the pack if it was deleted, so its objects are no longer
+	// looked up on it.
+	if _, err := s.dir.ObjectPackStat(h); err != dotgit.ErrPackfileNotFound {
+		return nil
+	}
+
+	delete(s.index, h)
+	if p, ok := s.packfiles[h]; ok {
+		delete(s.packfiles, h)
+		return p.Close()
+	}
+
+	return nil
+}
+
+func (s *ObjectStorage) ForEachObjectPackTime(h plumbing.Hash, fun func(plumbing.Hash, time.Time) error) error {
+	if err := s.requireIndex(); err != nil {
+		return err
+	}
+
+	idx, ok := s.index[h].(*idxfile.MemoryIndex)
+	if !ok {
+		return dotgit.ErrPackfileNotFound
+	}
+
+	mtime, err := s.objectPackTimes(h, idx)
+	if err != nil {
+		return err
+	}
+
+	iter, err := idx.Entries()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+
+	for pos := int64(0); ; pos++ {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		t, err := mtime(pos)
+		if err != nil {
+			return err
+		}
+
+		if err := fun(e.Hash, t); err != nil {
+			if err == storer.ErrStop {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// objectPackTimes returns a function resolving the modification time of the
+// object at the given position of the idx file of a pack, from its mtimes
+// file if the pack is a cruft pack, or from the pack itself otherwise.
+func (s *ObjectStorage) objectPackTimes(h plumbing.Hash, idx *idxfile.MemoryIndex) (
+	mtime func(int64) (time.Time, error), err error) {
+	f, err := s.dir.ObjectPackMtimes(h)
+	if err == dotgit.ErrMtimesNotFound {
+		fi, err := s.dir.ObjectPackStat(h)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(int64) (time.Time, error) {
+			return fi.ModTime(), nil
+		}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	m := new(mtimesfile.Mtimes)
+	if err = mtimesfile.NewDecoder(f).Decode(m); err != nil {
+		return nil, err
+	}
+
+	if m.PackfileChecksum != idx.PackfileChecksum {
+		return nil, mtimesfile.ErrMalformedMtimesFile
+	}
+
+	return func(pos int64) (time.Time, error) {
+		if pos >= m.Count() {
+			return time.Time{}, mtimesfile.ErrMalformedMtimesFile
+		}
+
+		return time.Unix(int64(m.Times[pos]), 0), nil
+	}, nil
 }
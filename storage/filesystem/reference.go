@@ -1,28 +1,132 @@
 package filesystem
 
 import (
+	"os"
+	"sync"
+
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	formatcfg "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/reftable"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v5/utils/ioutil"
 )
 
 type ReferenceStorage struct {
 	dir *dotgit.DotGit
+
+	m sync.Mutex
+	// detected is set once the reference backend in use is known, table is
+	// only set for repositories using the reftable backend.
+	detected bool
+	table    *reftableReferenceStorage
+}
+
+// reftable returns the reftable storage if the repository has the
+// extensions.refStorage option set to reftable, or nil otherwise.
+func (r *ReferenceStorage) reftable() (*reftableReferenceStorage, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.detected {
+		return r.table, nil
+	}
+
+	cfg, err := r.readConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Extensions.RefStorage == formatcfg.ReftableRefStorage {
+		fs, err := r.dir.Fs().Chroot(reftableDir)
+		if err != nil {
+			return nil, err
+		}
+
+		r.table = &reftableReferenceStorage{
+			stack:  reftable.NewStack(fs, nil),
+			config: r.readConfig,
+		}
+	}
+
+	r.detected = true
+	return r.table, nil
+}
+
+func (r *ReferenceStorage) readConfig() (cfg *config.Config, err error) {
+	f, err := r.dir.Config()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config.NewConfig(), nil
+		}
+
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return config.ReadConfig(f)
+}
+
+// reset forgets the reference backend in use, so it's detected again from
+// the configuration.
+func (r *ReferenceStorage) reset() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.detected = false
+	r.table = nil
 }
 
 func (r *ReferenceStorage) SetReference(ref *plumbing.Reference) error {
+	t, err := r.reftable()
+	if err != nil {
+		return err
+	}
+
+	if t != nil {
+		return t.SetReference(ref)
+	}
+
 	return r.dir.SetRef(ref, nil)
 }
 
 func (r *ReferenceStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	t, err := r.reftable()
+	if err != nil {
+		return err
+	}
+
+	if t != nil {
+		return t.CheckAndSetReference(ref, old)
+	}
+
 	return r.dir.SetRef(ref, old)
 }
 
 func (r *ReferenceStorage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	t, err := r.reftable()
+	if err != nil {
+		return nil, err
+	}
+
+	if t != nil {
+		return t.Reference(n)
+	}
+
 	return r.dir.Ref(n)
 }
 
 func (r *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
+	t, err := r.reftable()
+	if err != nil {
+		return nil, err
+	}
+
+	if t != nil {
+		return t.IterReferences()
+	}
+
 	refs, err := r.dir.Refs()
 	if err != nil {
 		return nil, err
@@ -32,13 +136,55 @@ func (r *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 }
 
 func (r *ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
+	t, err := r.reftable()
+	if err != nil {
+		return err
+	}
+
+	if t != nil {
+		return t.RemoveReference(n)
+	}
+
 	return r.dir.RemoveRef(n)
 }
 
 func (r *ReferenceStorage) CountLooseRefs() (int, error) {
+	t, err := r.reftable()
+	if err != nil {
+		return 0, err
+	}
+
+	if t != nil {
+		return t.CountLooseRefs()
+	}
+
 	return r.dir.CountLooseRefs()
 }
 
 func (r *ReferenceStorage) PackRefs() error {
+	t, err := r.reftable()
+	if err != nil {
+		return err
+	}
+
+	if t != nil {
+		return t.PackRefs()
+	}
+
 	return r.dir.PackRefs()
 }
+
+// BeginReferenceTransaction honors the storer.ReferenceTransactioner
+// interface.
+func (r *ReferenceStorage) BeginReferenceTransaction() (storer.ReferenceTransaction, error) {
+	t, err := r.reftable()
+	if err != nil {
+		return nil, err
+	}
+
+	if t != nil {
+		return storer.NewReferenceTransaction(t.updateReferences), nil
+	}
+
+	return storer.NewReferenceTransaction(r.dir.UpdateRefs), nil
+}
@@ -0,0 +1,246 @@
+package filesystem
+
+import (
+	"time"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/reftable"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage"
+)
+
+// reftableDir is the directory, relative to the git directory, holding the
+// tables of repositories using the reftable reference backend.
+const reftableDir = "reftable"
+
+// reftableReferenceStorage stores the references in a stack of reftables.
+// Every update is logged in the same table, as the reflog of the reference.
+type reftableReferenceStorage struct {
+	stack *reftable.Stack
+	// config returns the configuration of the repository, holding the
+	// identity used for the log records.
+	config func() (*config.Config, error)
+}
+
+func (r *reftableReferenceStorage) SetReference(ref *plumbing.Reference) error {
+	return r.CheckAndSetReference(ref, nil)
+}
+
+func (r *reftableReferenceStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	if ref == nil {
+		return nil
+	}
+
+	return r.stack.Add(func(w *reftable.Writer, current *reftable.Merged, updateIndex uint64) error {
+		if old != nil {
+			rec, err := current.Ref(old.Name().String())
+			if err == plumbing.ErrReferenceNotFound {
+				return storage.ErrReferenceHasChanged
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if newReferenceFromRecord(rec).Hash() != old.Hash() {
+				return storage.ErrReferenceHasChanged
+			}
+		}
+
+		stored, err := current.Ref(ref.Name().String())
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		if err := w.AddRef(newRecordFromReference(ref, updateIndex)); err != nil {
+			return err
+		}
+
+		return r.addLog(w, ref.Name(), stored, ref, updateIndex)
+	})
+}
+
+func (r *reftableReferenceStorage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	m, err := r.stack.Merged()
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := m.Ref(n.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return newReferenceFromRecord(rec), nil
+}
+
+func (r *reftableReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
+	m, err := r.stack.Merged()
+	if err != nil {
+		return nil, err
+	}
+
+	recs, err := m.Refs()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]*plumbing.Reference, 0, len(recs))
+	for _, rec := range recs {
+		refs = append(refs, newReferenceFromRecord(rec))
+	}
+
+	return storer.NewReferenceSliceIter(refs), nil
+}
+
+func (r *reftableReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
+	return r.stack.Add(func(w *reftable.Writer, current *reftable.Merged, updateIndex uint64) error {
+		stored, err := current.Ref(n.String())
+		if err != nil {
+			if err == plumbing.ErrReferenceNotFound {
+				return nil
+			}
+
+			return err
+		}
+
+		err = w.AddRef(&reftable.RefRecord{
+			RefName:     n.String(),
+			UpdateIndex: updateIndex,
+			ValueType:   reftable.RefDeletion,
+		})
+		if err != nil {
+			return err
+		}
+
+		return r.addLog(w, n, stored, nil, updateIndex)
+	})
+}
+
+// updateReferences applies the given updates atomically, adding a single
+// table to the stack.
+func (r *reftableReferenceStorage) updateReferences(updates []*storer.ReferenceUpdate) error {
+	return r.stack.Add(func(w *reftable.Writer, current *reftable.Merged, updateIndex uint64) error {
+		for _, u := range updates {
+			rec, err := current.Ref(u.Name.String())
+			if err != nil && err != plumbing.ErrReferenceNotFound {
+				return err
+			}
+
+			var ref *plumbing.Reference
+			if rec != nil {
+				ref = newReferenceFromRecord(rec)
+			}
+
+			if err := u.Check(ref); err != nil {
+				return err
+			}
+
+			stored := rec
+			if !u.IsDelete() {
+				rec = newRecordFromReference(u.New, updateIndex)
+			} else if ref != nil {
+				rec = &reftable.RefRecord{
+					RefName:     u.Name.String(),
+					UpdateIndex: updateIndex,
+					ValueType:   reftable.RefDeletion,
+				}
+			} else {
+				continue
+			}
+
+			if err := w.AddRef(rec); err != nil {
+				return err
+			}
+
+			if err := r.addLog(w, u.Name, stored, u.New, updateIndex); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// addLog adds the log record of the update of the reference from the stored
+// record to the new reference, nil if it's deleted. The identity is the
+// committer of the configuration, or the user if it's not set.
+func (r *reftableReferenceStorage) addLog(
+	w *reftable.Writer,
+	n plumbing.ReferenceName,
+	stored *reftable.RefRecord,
+	new *plumbing.Reference,
+	updateIndex uint64,
+) error {
+	cfg, err := r.config()
+	if err != nil {
+		return err
+	}
+
+	name, email := cfg.Committer.Name, cfg.Committer.Email
+	if name == "" {
+		name = cfg.User.Name
+	}
+
+	if email == "" {
+		email = cfg.User.Email
+	}
+
+	now := time.Now()
+	_, offset := now.Zone()
+	log := &reftable.LogRecord{
+		RefName:     n.String(),
+		UpdateIndex: updateIndex,
+		ValueType:   reftable.LogUpdate,
+		Name:        name,
+		Email:       email,
+		Time:        uint64(now.Unix()),
+		TZOffset:    int16(offset / 60),
+	}
+
+	if stored != nil {
+		log.Old = newReferenceFromRecord(stored).Hash()
+	}
+
+	if new != nil {
+		log.New = new.Hash()
+	}
+
+	return w.AddLog(log)
+}
+
+func (r *reftableReferenceStorage) CountLooseRefs() (int, error) {
+	return 0, nil
+}
+
+func (r *reftableReferenceStorage) PackRefs() error {
+	return r.stack.Compact()
+}
+
+func newRecordFromReference(ref *plumbing.Reference, updateIndex uint64) *reftable.RefRecord {
+	rec := &reftable.RefRecord{
+		RefName:     ref.Name().String(),
+		UpdateIndex: updateIndex,
+	}
+
+	switch ref.Type() {
+	case plumbing.SymbolicReference:
+		rec.ValueType = reftable.RefSymref
+		rec.Target = ref.Target().String()
+	default:
+		rec.ValueType = reftable.RefVal1
+		rec.Value = ref.Hash()
+	}
+
+	return rec
+}
+
+func newReferenceFromRecord(rec *reftable.RefRecord) *plumbing.Reference {
+	name := plumbing.ReferenceName(rec.RefName)
+	if rec.ValueType == reftable.RefSymref {
+		return plumbing.NewSymbolicReference(name, plumbing.ReferenceName(rec.Target))
+	}
+
+	return plumbing.NewHashReference(name, rec.Value)
+}
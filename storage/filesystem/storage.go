@@ -2,6 +2,7 @@
 package filesystem
 
 import (
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
 
@@ -80,6 +81,17 @@ func (s *Storage) Init() error {
 	return s.dir.Initialize()
 }
 
+// SetConfig stores the given configuration. The reference backend is
+// selected again, since the extensions.refStorage option may have changed.
+func (s *Storage) SetConfig(cfg *config.Config) error {
+	if err := s.ConfigStorage.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	s.ReferenceStorage.reset()
+	return nil
+}
+
 func (s *Storage) AddAlternate(remote string) error {
 	return s.dir.AddAlternate(remote)
 }
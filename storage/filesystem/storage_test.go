@@ -1,10 +1,18 @@
 package filesystem
 
 import (
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	formatcfg "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/format/reftable"
 	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/test"
 
 	"github.com/go-git/go-billy/v5"
@@ -39,6 +47,7 @@ func setUpTest(s *StorageSuite, c *C, storage *Storage) {
 	// ensure that right interfaces are implemented
 	var _ storer.EncodedObjectStorer = storage
 	var _ storer.IndexStorer = storage
+	var _ storer.IndexCheckAndSetStorer = storage
 	var _ storer.ReferenceStorer = storage
 	var _ storer.ShallowStorer = storage
 	var _ storer.DeltaObjectStorer = storage
@@ -54,6 +63,37 @@ func (s *StorageSuite) TestFilesystem(c *C) {
 	c.Assert(storage.Filesystem(), Equals, fs)
 }
 
+func (s *StorageSuite) TestCheckAndSetIndex(c *C) {
+	storage := NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+
+	old, err := storage.IndexChecksum()
+	c.Assert(err, IsNil)
+	c.Assert(old, Equals, plumbing.ZeroHash)
+
+	idx := &index.Index{Version: 2}
+	idx.Add("foo")
+	c.Assert(storage.CheckAndSetIndex(idx, old), IsNil)
+
+	checksum, err := storage.IndexChecksum()
+	c.Assert(err, IsNil)
+	c.Assert(checksum, Not(Equals), plumbing.ZeroHash)
+
+	idx.Add("bar")
+	err = storage.CheckAndSetIndex(idx, old)
+	c.Assert(err, Equals, storer.ErrIndexHasChanged)
+
+	stored, err := storage.Index()
+	c.Assert(err, IsNil)
+	c.Assert(stored.Entries, HasLen, 1)
+
+	// the index is not left locked
+	c.Assert(storage.CheckAndSetIndex(idx, checksum), IsNil)
+
+	stored, err = storage.Index()
+	c.Assert(err, IsNil)
+	c.Assert(stored.Entries, HasLen, 2)
+}
+
 func (s *StorageSuite) TestNewStorageShouldNotAddAnyContentsToDir(c *C) {
 	fis, err := s.fs.ReadDir("/")
 	c.Assert(err, IsNil)
@@ -80,3 +120,127 @@ func (s *StorageExclusiveSuite) SetUpTest(c *C) {
 
 	setUpTest(&s.StorageSuite, c, storage)
 }
+
+type StorageReftableSuite struct {
+	test.BaseStorageSuite
+	storage *Storage
+}
+
+var _ = Suite(&StorageReftableSuite{})
+
+func (s *StorageReftableSuite) SetUpTest(c *C) {
+	s.storage = NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+
+	cfg := config.NewConfig()
+	cfg.Core.RepositoryFormatVersion = formatcfg.Version_1
+	cfg.Extensions.RefStorage = formatcfg.ReftableRefStorage
+	c.Assert(s.storage.SetConfig(cfg), IsNil)
+
+	s.BaseStorageSuite = test.NewBaseStorageSuite(s.storage)
+}
+
+func (s *StorageReftableSuite) TestReftableFiles(c *C) {
+	ref := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(s.storage.SetReference(ref), IsNil)
+
+	_, err := s.storage.Filesystem().Stat("refs/heads/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	list, err := util.ReadFile(s.storage.Filesystem(), "reftable/tables.list")
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(string(list), "0x000000000001-0x000000000001-"), Equals, true)
+
+	n, err := s.storage.CountLooseRefs()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+
+	c.Assert(s.storage.RemoveReference(ref.Name()), IsNil)
+	c.Assert(s.storage.PackRefs(), IsNil)
+
+	list, err = util.ReadFile(s.storage.Filesystem(), "reftable/tables.list")
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(string(list), "0x000000000001-0x000000000002-"), Equals, true)
+
+	_, err = s.storage.Reference(ref.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *StorageReftableSuite) TestReftableCheckAndSetMissingReference(c *C) {
+	old := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	ref := plumbing.NewReferenceFromStrings("refs/heads/foo", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	err := s.storage.CheckAndSetReference(ref, old)
+	c.Assert(err, Equals, storage.ErrReferenceHasChanged)
+
+	_, err = s.storage.Reference(ref.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *StorageReftableSuite) TestReftableLogs(c *C) {
+	cfg, err := s.storage.Config()
+	c.Assert(err, IsNil)
+	cfg.User.Name = "foo"
+	cfg.User.Email = "foo@example.com"
+	c.Assert(s.storage.SetConfig(cfg), IsNil)
+
+	first := plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	second := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	name := plumbing.ReferenceName("refs/heads/foo")
+
+	var logs []*reftable.LogRecord
+	for _, update := range []func() error{
+		func() error { return s.storage.SetReference(plumbing.NewHashReference(name, first)) },
+		func() error { return s.storage.SetReference(plumbing.NewHashReference(name, second)) },
+		func() error { return s.storage.RemoveReference(name) },
+	} {
+		c.Assert(update(), IsNil)
+
+		// the log record is written in the table of the update
+		t := s.lastTable(c)
+		tableLogs, err := t.Logs()
+		c.Assert(err, IsNil)
+		c.Assert(tableLogs, Not(HasLen), 0)
+		c.Assert(tableLogs[0].UpdateIndex, Equals, t.MaxUpdateIndex())
+
+		logs = append(logs, tableLogs[0])
+	}
+
+	for i, hashes := range [][2]plumbing.Hash{
+		{plumbing.ZeroHash, first},
+		{first, second},
+		{second, plumbing.ZeroHash},
+	} {
+		c.Assert(logs[i].RefName, Equals, name.String())
+		c.Assert(logs[i].ValueType, Equals, reftable.LogUpdate)
+		c.Assert(logs[i].Old, Equals, hashes[0])
+		c.Assert(logs[i].New, Equals, hashes[1])
+		c.Assert(logs[i].Name, Equals, "foo")
+		c.Assert(logs[i].Email, Equals, "foo@example.com")
+	}
+}
+
+// lastTable returns a reader of the newest table of the stack.
+func (s *StorageReftableSuite) lastTable(c *C) *reftable.Reader {
+	list, err := util.ReadFile(s.storage.Filesystem(), "reftable/tables.list")
+	c.Assert(err, IsNil)
+
+	tables := strings.Fields(string(list))
+	c.Assert(tables, Not(HasLen), 0)
+
+	data, err := util.ReadFile(s.storage.Filesystem(), "reftable/"+tables[len(tables)-1])
+	c.Assert(err, IsNil)
+
+	t, err := reftable.NewReader(data)
+	c.Assert(err, IsNil)
+	return t
+}
+
+func (s *StorageReftableSuite) TestReftableDetectedOnOpen(c *C) {
+	ref := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(s.storage.SetReference(ref), IsNil)
+
+	sto := NewStorage(s.storage.Filesystem(), cache.NewObjectLRUDefault())
+	r, err := sto.Reference(ref.Name())
+	c.Assert(err, IsNil)
+	c.Assert(r.Hash(), Equals, ref.Hash())
+}
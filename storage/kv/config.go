@@ -0,0 +1,40 @@
+package kv
+
+import (
+	"bytes"
+
+	"github.com/go-git/go-git/v5/config"
+)
+
+// ConfigStorage implements the config.ConfigStorer over a Store.
+type ConfigStorage struct {
+	buckets
+}
+
+// Config honors the config.ConfigStorer interface.
+func (s *ConfigStorage) Config() (*config.Config, error) {
+	value, err := s.get(metaBucket, configKey)
+	if err != nil {
+		if err == ErrKeyNotFound {
+			return config.NewConfig(), nil
+		}
+
+		return nil, err
+	}
+
+	return config.ReadConfig(bytes.NewReader(value))
+}
+
+// SetConfig honors the config.ConfigStorer interface.
+func (s *ConfigStorage) SetConfig(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return s.put(metaBucket, configKey, b)
+}
@@ -0,0 +1,41 @@
+package kv
+
+import (
+	"bytes"
+
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// IndexStorage implements the storer.IndexStorer over a Store.
+type IndexStorage struct {
+	buckets
+}
+
+// SetIndex honors the storer.IndexStorer interface.
+func (s *IndexStorage) SetIndex(idx *index.Index) error {
+	var buf bytes.Buffer
+	if err := index.NewEncoder(&buf).Encode(idx); err != nil {
+		return err
+	}
+
+	return s.put(metaBucket, indexKey, buf.Bytes())
+}
+
+// Index honors the storer.IndexStorer interface.
+func (s *IndexStorage) Index() (*index.Index, error) {
+	idx := &index.Index{
+		Version: 2,
+	}
+
+	value, err := s.get(metaBucket, indexKey)
+	if err != nil {
+		if err == ErrKeyNotFound {
+			return idx, nil
+		}
+
+		return nil, err
+	}
+
+	err = index.NewDecoder(bytes.NewReader(value)).Decode(idx)
+	return idx, err
+}
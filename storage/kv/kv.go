@@ -0,0 +1,40 @@
+// Package kv is a storage backend storing the repository in a key/value
+// store, such as a SQL database or a bbolt file, instead of many small files.
+//
+// The key/value store is pluggable through the Store interface, the packages
+// kvsql and kvbolt provide implementations for database/sql and bbolt. They
+// are modules of their own, so go-git doesn't depend on bbolt, nor on the
+// SQL driver kvsql is tested with.
+package kv
+
+import (
+	"errors"
+)
+
+// ErrKeyNotFound is returned by Tx.Get when the key doesn't exist.
+var ErrKeyNotFound = errors.New("key not found")
+
+// Store is a transactional key/value store, where the keys are grouped in
+// buckets. Buckets are created on the first write.
+type Store interface {
+	// View runs fn in a read-only transaction.
+	View(fn func(Tx) error) error
+	// Update runs fn in a read-write transaction, which is committed if fn
+	// returns nil, and rolled back otherwise.
+	Update(fn func(Tx) error) error
+}
+
+// Tx is a transaction of a Store. The values returned by a transaction are
+// only valid until the transaction ends.
+type Tx interface {
+	// Get returns the value of the given key, or ErrKeyNotFound.
+	Get(bucket, key string) ([]byte, error)
+	// Put sets the value of the given key.
+	Put(bucket, key string, value []byte) error
+	// Delete removes the given key, if it exists.
+	Delete(bucket, key string) error
+	// ForEach calls fn for every key of the bucket starting with prefix,
+	// sorted by key. The iteration stops if fn returns an error. fn must not
+	// use the transaction.
+	ForEach(bucket, prefix string, fn func(key string, value []byte) error) error
+}
@@ -0,0 +1,32 @@
+package kvbolt_test
+
+import (
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/storage/kv"
+	"github.com/go-git/go-git/v5/storage/kv/kvbolt"
+	"github.com/go-git/go-git/v5/storage/test"
+
+	bolt "go.etcd.io/bbolt"
+	. "gopkg.in/check.v1"
+)
+
+type StorageSuite struct {
+	test.BaseStorageSuite
+	db *bolt.DB
+}
+
+var _ = Suite(&StorageSuite{})
+
+func (s *StorageSuite) SetUpTest(c *C) {
+	db, err := bolt.Open(filepath.Join(c.MkDir(), "git.db"), 0600, nil)
+	c.Assert(err, IsNil)
+
+	s.db = db
+	s.BaseStorageSuite = test.NewBaseStorageSuite(kv.NewStorage(kvbolt.NewStore(db), "repo"))
+}
+
+func (s *StorageSuite) TearDownTest(c *C) {
+	c.Assert(s.db.Close(), IsNil)
+	s.BaseStorageSuite.TearDownTest(c)
+}
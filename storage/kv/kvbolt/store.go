@@ -0,0 +1,88 @@
+// Package kvbolt implements a kv.Store over a bbolt database. It's a module
+// of its own, so bbolt is only a dependency of the programs using it.
+package kvbolt
+
+import (
+	"bytes"
+
+	"github.com/go-git/go-git/v5/storage/kv"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store is a kv.Store over a bbolt database, using a bbolt bucket for every
+// bucket of the store.
+type Store struct {
+	db *bolt.DB
+}
+
+// NewStore returns a new Store over the given database.
+func NewStore(db *bolt.DB) *Store {
+	return &Store{db: db}
+}
+
+// View honors the kv.Store interface.
+func (s *Store) View(fn func(kv.Tx) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx})
+	})
+}
+
+// Update honors the kv.Store interface.
+func (s *Store) Update(fn func(kv.Tx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx})
+	})
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (t *boltTx) Get(bucket, key string) ([]byte, error) {
+	b := t.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil, kv.ErrKeyNotFound
+	}
+
+	value := b.Get([]byte(key))
+	if value == nil {
+		return nil, kv.ErrKeyNotFound
+	}
+
+	return value, nil
+}
+
+func (t *boltTx) Put(bucket, key string, value []byte) error {
+	b, err := t.tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(key), value)
+}
+
+func (t *boltTx) Delete(bucket, key string) error {
+	b := t.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+
+	return b.Delete([]byte(key))
+}
+
+func (t *boltTx) ForEach(bucket, prefix string, fn func(key string, value []byte) error) error {
+	b := t.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+
+	p := []byte(prefix)
+	c := b.Cursor()
+	for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+		if err := fn(string(k), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
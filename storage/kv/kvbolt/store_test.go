@@ -0,0 +1,87 @@
+package kvbolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/storage/kv"
+	bolt "go.etcd.io/bbolt"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StoreSuite struct {
+	db    *bolt.DB
+	store *Store
+}
+
+var _ = Suite(&StoreSuite{})
+
+func (s *StoreSuite) SetUpTest(c *C) {
+	db, err := bolt.Open(filepath.Join(c.MkDir(), "git.db"), 0600, nil)
+	c.Assert(err, IsNil)
+
+	s.db = db
+	s.store = NewStore(db)
+}
+
+func (s *StoreSuite) TearDownTest(c *C) {
+	c.Assert(s.db.Close(), IsNil)
+}
+
+func (s *StoreSuite) TestMissingBucket(c *C) {
+	err := s.store.View(func(tx kv.Tx) error {
+		_, err := tx.Get("foo", "bar")
+		c.Assert(err, Equals, kv.ErrKeyNotFound)
+
+		return tx.ForEach("foo", "", func(string, []byte) error {
+			c.Fatal("unexpected key")
+			return nil
+		})
+	})
+	c.Assert(err, IsNil)
+
+	err = s.store.Update(func(tx kv.Tx) error {
+		return tx.Delete("foo", "bar")
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *StoreSuite) TestForEachPrefix(c *C) {
+	err := s.store.Update(func(tx kv.Tx) error {
+		for _, k := range []string{"a", "ab", "abc", "abd", "b"} {
+			if err := tx.Put("foo", k, []byte(k)); err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete("foo", "abd")
+	})
+	c.Assert(err, IsNil)
+
+	var keys []string
+	err = s.store.View(func(tx kv.Tx) error {
+		return tx.ForEach("foo", "ab", func(key string, value []byte) error {
+			c.Assert(string(value), Equals, key)
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	c.Assert(err, IsNil)
+	c.Assert(keys, DeepEquals, []string{"ab", "abc"})
+}
+
+func (s *StoreSuite) TestUpdateRollback(c *C) {
+	err := s.store.Update(func(tx kv.Tx) error {
+		c.Assert(tx.Put("foo", "bar", []byte("qux")), IsNil)
+		return kv.ErrKeyNotFound
+	})
+	c.Assert(err, Equals, kv.ErrKeyNotFound)
+
+	err = s.store.View(func(tx kv.Tx) error {
+		_, err := tx.Get("foo", "bar")
+		return err
+	})
+	c.Assert(err, Equals, kv.ErrKeyNotFound)
+}
@@ -0,0 +1,36 @@
+package kvsql_test
+
+import (
+	"database/sql"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/storage/kv"
+	"github.com/go-git/go-git/v5/storage/kv/kvsql"
+	"github.com/go-git/go-git/v5/storage/test"
+
+	_ "github.com/mattn/go-sqlite3"
+	. "gopkg.in/check.v1"
+)
+
+type StorageSuite struct {
+	test.BaseStorageSuite
+	db *sql.DB
+}
+
+var _ = Suite(&StorageSuite{})
+
+func (s *StorageSuite) SetUpTest(c *C) {
+	db, err := sql.Open("sqlite3", filepath.Join(c.MkDir(), "git.db"))
+	c.Assert(err, IsNil)
+
+	store := kvsql.NewStore(db, "")
+	c.Assert(store.Init(), IsNil)
+
+	s.db = db
+	s.BaseStorageSuite = test.NewBaseStorageSuite(kv.NewStorage(store, "repo"))
+}
+
+func (s *StorageSuite) TearDownTest(c *C) {
+	c.Assert(s.db.Close(), IsNil)
+	s.BaseStorageSuite.TearDownTest(c)
+}
@@ -0,0 +1,171 @@
+// Package kvsql implements a kv.Store over a database/sql database.
+//
+// The keys are stored in a single table, with the following schema, which
+// is created by Store.Init:
+//
+//	CREATE TABLE IF NOT EXISTS <table> (
+//		bucket TEXT NOT NULL,
+//		name   TEXT NOT NULL,
+//		value  BLOB NOT NULL,
+//		PRIMARY KEY (bucket, name)
+//	)
+//
+// The queries use "?" placeholders and "INSERT ... ON CONFLICT" upserts, as
+// supported by SQLite, which is the reference database. It's a module of its
+// own, so the SQLite driver it's tested with isn't a dependency of go-git.
+package kvsql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/storage/kv"
+	"github.com/go-git/go-git/v5/utils/ioutil"
+)
+
+// DefaultTable is the table used when no table is given to NewStore.
+const DefaultTable = "git_kv"
+
+// Store is a kv.Store over a database/sql database.
+type Store struct {
+	db *sql.DB
+
+	createTable, get, put, del, forEach, forEachPrefix string
+}
+
+// NewStore returns a new Store using the given table of the database. If
+// table is empty, DefaultTable is used. The table name is not quoted.
+func NewStore(db *sql.DB, table string) *Store {
+	if table == "" {
+		table = DefaultTable
+	}
+
+	return &Store{
+		db: db,
+
+		createTable: fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ("+
+			"bucket TEXT NOT NULL, name TEXT NOT NULL, value BLOB NOT NULL, "+
+			"PRIMARY KEY (bucket, name))", table),
+		get: fmt.Sprintf("SELECT value FROM %s WHERE bucket = ? AND name = ?", table),
+		put: fmt.Sprintf("INSERT INTO %s (bucket, name, value) VALUES (?, ?, ?) "+
+			"ON CONFLICT (bucket, name) DO UPDATE SET value = excluded.value", table),
+		del: fmt.Sprintf("DELETE FROM %s WHERE bucket = ? AND name = ?", table),
+		forEach: fmt.Sprintf("SELECT name, value FROM %s WHERE bucket = ? "+
+			"ORDER BY name", table),
+		forEachPrefix: fmt.Sprintf("SELECT name, value FROM %s WHERE bucket = ? "+
+			"AND name >= ? AND name < ? ORDER BY name", table),
+	}
+}
+
+// Init creates the table of the Store, if it doesn't exist.
+func (s *Store) Init() error {
+	_, err := s.db.Exec(s.createTable)
+	return err
+}
+
+// View honors the kv.Store interface.
+func (s *Store) View(fn func(kv.Tx) error) error {
+	return s.run(fn, true)
+}
+
+// Update honors the kv.Store interface.
+func (s *Store) Update(fn func(kv.Tx) error) error {
+	return s.run(fn, false)
+}
+
+func (s *Store) run(fn func(kv.Tx) error, readOnly bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&sqlTx{s: s, tx: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if readOnly {
+		return tx.Rollback()
+	}
+
+	return tx.Commit()
+}
+
+type sqlTx struct {
+	s  *Store
+	tx *sql.Tx
+}
+
+func (t *sqlTx) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := t.tx.QueryRow(t.s.get, bucket, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, kv.ErrKeyNotFound
+	}
+
+	return value, err
+}
+
+func (t *sqlTx) Put(bucket, key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	_, err := t.tx.Exec(t.s.put, bucket, key, value)
+	return err
+}
+
+func (t *sqlTx) Delete(bucket, key string) error {
+	_, err := t.tx.Exec(t.s.del, bucket, key)
+	return err
+}
+
+// ForEach honors the kv.Tx interface. The rows are streamed while fn is
+// called, so fn must not use the transaction.
+func (t *sqlTx) ForEach(bucket, prefix string, fn func(key string, value []byte) error) (err error) {
+	var rows *sql.Rows
+	if end, ok := prefixEnd(prefix); ok {
+		rows, err = t.tx.Query(t.s.forEachPrefix, bucket, prefix, end)
+	} else {
+		rows, err = t.tx.Query(t.s.forEach, bucket)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(rows, &err)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// prefixEnd returns the smallest key greater than all the keys starting with
+// prefix, false if there is no such key, which happens for an empty prefix or
+// a prefix of only 0xff bytes.
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1]), true
+		}
+	}
+
+	return "", false
+}
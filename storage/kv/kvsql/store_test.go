@@ -0,0 +1,34 @@
+package kvsql
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StoreSuite struct{}
+
+var _ = Suite(&StoreSuite{})
+
+func (s *StoreSuite) TestPrefixEnd(c *C) {
+	for _, t := range []struct {
+		prefix, end string
+		ok          bool
+	}{
+		{"", "", false},
+		{"ab", "ac", true},
+		{"a\xff", "b", true},
+		{"\xff\xff", "", false},
+	} {
+		end, ok := prefixEnd(t.prefix)
+		c.Assert(ok, Equals, t.ok, Commentf("prefix %q", t.prefix))
+		c.Assert(end, Equals, t.end, Commentf("prefix %q", t.prefix))
+	}
+}
+
+func (s *StoreSuite) TestNewStoreDefaultTable(c *C) {
+	st := NewStore(nil, "")
+	c.Assert(st.get, Equals, "SELECT value FROM git_kv WHERE bucket = ? AND name = ?")
+}
@@ -0,0 +1,264 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+var (
+	// ErrUnsupportedObjectType is returned when storing an object of an
+	// unsupported type, such as delta objects.
+	ErrUnsupportedObjectType = errors.New("unsupported object type")
+	// ErrMalformedObject is returned when a stored object can't be decoded.
+	ErrMalformedObject = errors.New("malformed stored object")
+
+	errAlternatesNotSupported = errors.New("alternates not supported")
+)
+
+// ObjectStorage implements the storer.EncodedObjectStorer over a Store. The
+// objects are stored uncompressed, keyed by hash, with their type as the
+// first byte of the value, and indexed by type in a bucket of their own.
+type ObjectStorage struct {
+	buckets
+}
+
+// NewEncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *ObjectStorage) NewEncodedObject() plumbing.EncodedObject {
+	return &plumbing.MemoryObject{}
+}
+
+// SetEncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *ObjectStorage) SetEncodedObject(o plumbing.EncodedObject) (plumbing.Hash, error) {
+	value, err := encodeObject(o)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h := o.Hash()
+	return h, s.store.Update(func(tx Tx) error {
+		return s.putObject(tx, h, o.Type(), value)
+	})
+}
+
+// HasEncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *ObjectStorage) HasEncodedObject(h plumbing.Hash) error {
+	_, err := s.EncodedObjectSize(h)
+	return err
+}
+
+// EncodedObjectSize honors the storer.EncodedObjectStorer interface.
+func (s *ObjectStorage) EncodedObjectSize(h plumbing.Hash) (size int64, err error) {
+	err = s.store.View(func(tx Tx) error {
+		value, err := tx.Get(s.bucket(objectsBucket), h.String())
+		if err != nil {
+			return err
+		}
+
+		if len(value) == 0 {
+			return ErrMalformedObject
+		}
+
+		size = int64(len(value) - 1)
+		return nil
+	})
+
+	if err == ErrKeyNotFound {
+		err = plumbing.ErrObjectNotFound
+	}
+
+	return size, err
+}
+
+// EncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *ObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	value, err := s.get(objectsBucket, h.String())
+	if err != nil {
+		if err == ErrKeyNotFound {
+			err = plumbing.ErrObjectNotFound
+		}
+
+		return nil, err
+	}
+
+	o, err := decodeObject(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if t != plumbing.AnyObject && o.Type() != t {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return o, nil
+}
+
+// IterEncodedObjects honors the storer.EncodedObjectStorer interface. The
+// hashes of the objects of the type are read from the type index, and the
+// objects are read from the Store while iterating.
+func (s *ObjectStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	var hashes []plumbing.Hash
+	err := s.forEachObjectHash(t, func(h plumbing.Hash) error {
+		hashes = append(hashes, h)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewEncodedObjectLookupIter(s, t, hashes), nil
+}
+
+// ForEachObjectHash iterates over the hashes of the stored objects. If fn
+// returns storer.ErrStop the iteration is stopped but no error is returned.
+func (s *ObjectStorage) ForEachObjectHash(fn func(plumbing.Hash) error) error {
+	err := s.forEachObjectHash(plumbing.AnyObject, fn)
+	if err == storer.ErrStop {
+		return nil
+	}
+
+	return err
+}
+
+// forEachObjectHash calls fn for the hashes of the objects of the type, read
+// from the type index.
+func (s *ObjectStorage) forEachObjectHash(t plumbing.ObjectType, fn func(plumbing.Hash) error) error {
+	prefix := ""
+	if t != plumbing.AnyObject {
+		prefix = t.String() + "/"
+	}
+
+	return s.store.View(func(tx Tx) error {
+		return tx.ForEach(s.bucket(objectTypesBucket), prefix, func(key string, _ []byte) error {
+			i := strings.LastIndexByte(key, '/')
+			if i < 0 {
+				return ErrMalformedObject
+			}
+
+			return fn(plumbing.NewHash(key[i+1:]))
+		})
+	})
+}
+
+// putObject stores the encoded value of an object, and indexes it by type.
+func (s *ObjectStorage) putObject(tx Tx, h plumbing.Hash, t plumbing.ObjectType, value []byte) error {
+	if err := tx.Put(s.bucket(objectsBucket), h.String(), value); err != nil {
+		return err
+	}
+
+	return tx.Put(s.bucket(objectTypesBucket), t.String()+"/"+h.String(), []byte{})
+}
+
+// AddAlternate honors the storer.EncodedObjectStorer interface, alternates
+// are not supported.
+func (s *ObjectStorage) AddAlternate(remote string) error {
+	return errAlternatesNotSupported
+}
+
+// Begin honors the storer.Transactioner interface. The objects of the
+// transaction are kept in memory until the transaction is committed, then
+// they are written in a single transaction of the Store.
+func (s *ObjectStorage) Begin() storer.Transaction {
+	return &TxObjectStorage{
+		s:       s,
+		objects: make(map[plumbing.Hash]plumbing.EncodedObject),
+	}
+}
+
+// TxObjectStorage is a storer.Transaction over an ObjectStorage.
+type TxObjectStorage struct {
+	s       *ObjectStorage
+	objects map[plumbing.Hash]plumbing.EncodedObject
+}
+
+// SetEncodedObject honors the storer.Transaction interface.
+func (tx *TxObjectStorage) SetEncodedObject(o plumbing.EncodedObject) (plumbing.Hash, error) {
+	h := o.Hash()
+	tx.objects[h] = o
+
+	return h, nil
+}
+
+// EncodedObject honors the storer.Transaction interface.
+func (tx *TxObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	o, ok := tx.objects[h]
+	if !ok || (t != plumbing.AnyObject && o.Type() != t) {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return o, nil
+}
+
+// Commit honors the storer.Transaction interface.
+func (tx *TxObjectStorage) Commit() error {
+	err := tx.s.store.Update(func(t Tx) error {
+		for h, o := range tx.objects {
+			value, err := encodeObject(o)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.s.putObject(t, h, o.Type(), value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Rollback()
+}
+
+// Rollback honors the storer.Transaction interface.
+func (tx *TxObjectStorage) Rollback() error {
+	tx.objects = make(map[plumbing.Hash]plumbing.EncodedObject)
+	return nil
+}
+
+func encodeObject(o plumbing.EncodedObject) ([]byte, error) {
+	switch o.Type() {
+	case plumbing.CommitObject, plumbing.TreeObject, plumbing.BlobObject, plumbing.TagObject:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedObjectType, o.Type())
+	}
+
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	value := make([]byte, 1, o.Size()+1)
+	value[0] = byte(o.Type())
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(value, content...), nil
+}
+
+func decodeObject(value []byte) (plumbing.EncodedObject, error) {
+	if len(value) == 0 {
+		return nil, ErrMalformedObject
+	}
+
+	o := &plumbing.MemoryObject{}
+	o.SetType(plumbing.ObjectType(value[0]))
+	if _, err := o.Write(value[1:]); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
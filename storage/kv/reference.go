@@ -0,0 +1,138 @@
+package kv
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage"
+)
+
+// ReferenceStorage implements the storer.ReferenceStorer over a Store. The
+// references are keyed by name, with the same content as loose references,
+// the hash or "ref: " followed by the target.
+type ReferenceStorage struct {
+	buckets
+}
+
+// SetReference honors the storer.ReferenceStorer interface.
+func (s *ReferenceStorage) SetReference(ref *plumbing.Reference) error {
+	return s.CheckAndSetReference(ref, nil)
+}
+
+// CheckAndSetReference honors the storer.ReferenceStorer interface.
+func (s *ReferenceStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	if ref == nil {
+		return nil
+	}
+
+	return s.store.Update(func(tx Tx) error {
+		if old != nil {
+			current, err := s.reference(tx, old.Name())
+			if err != nil && err != plumbing.ErrReferenceNotFound {
+				return err
+			}
+
+			if current != nil && current.Hash() != old.Hash() {
+				return storage.ErrReferenceHasChanged
+			}
+		}
+
+		return s.setReference(tx, ref)
+	})
+}
+
+// Reference honors the storer.ReferenceStorer interface.
+func (s *ReferenceStorage) Reference(n plumbing.ReferenceName) (ref *plumbing.Reference, err error) {
+	err = s.store.View(func(tx Tx) error {
+		ref, err = s.reference(tx, n)
+		return err
+	})
+
+	return ref, err
+}
+
+// IterReferences honors the storer.ReferenceStorer interface.
+func (s *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
+	var refs []*plumbing.Reference
+	err := s.store.View(func(tx Tx) error {
+		return tx.ForEach(s.bucket(refsBucket), "", func(key string, value []byte) error {
+			refs = append(refs, plumbing.NewReferenceFromStrings(key, string(value)))
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewReferenceSliceIter(refs), nil
+}
+
+// RemoveReference honors the storer.ReferenceStorer interface.
+func (s *ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
+	return s.store.Update(func(tx Tx) error {
+		return tx.Delete(s.bucket(refsBucket), n.String())
+	})
+}
+
+// CountLooseRefs honors the storer.ReferenceStorer interface.
+func (s *ReferenceStorage) CountLooseRefs() (int, error) {
+	return 0, nil
+}
+
+// PackRefs honors the storer.ReferenceStorer interface, the references are
+// never packed.
+func (s *ReferenceStorage) PackRefs() error {
+	return nil
+}
+
+// BeginReferenceTransaction honors the storer.ReferenceTransactioner
+// interface. The updates are checked and applied in a single transaction of
+// the Store.
+func (s *ReferenceStorage) BeginReferenceTransaction() (storer.ReferenceTransaction, error) {
+	return storer.NewReferenceTransaction(func(updates []*storer.ReferenceUpdate) error {
+		return s.store.Update(func(tx Tx) error {
+			for _, u := range updates {
+				current, err := s.reference(tx, u.Name)
+				if err != nil && err != plumbing.ErrReferenceNotFound {
+					return err
+				}
+
+				if err := u.Check(current); err != nil {
+					return err
+				}
+			}
+
+			for _, u := range updates {
+				var err error
+				if u.IsDelete() {
+					err = tx.Delete(s.bucket(refsBucket), u.Name.String())
+				} else {
+					err = s.setReference(tx, u.New)
+				}
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}), nil
+}
+
+func (s *ReferenceStorage) reference(tx Tx, n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	value, err := tx.Get(s.bucket(refsBucket), n.String())
+	if err != nil {
+		if err == ErrKeyNotFound {
+			err = plumbing.ErrReferenceNotFound
+		}
+
+		return nil, err
+	}
+
+	return plumbing.NewReferenceFromStrings(n.String(), string(value)), nil
+}
+
+func (s *ReferenceStorage) setReference(tx Tx, ref *plumbing.Reference) error {
+	return tx.Put(s.bucket(refsBucket), ref.Name().String(), []byte(ref.Strings()[1]))
+}
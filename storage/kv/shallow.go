@@ -0,0 +1,45 @@
+package kv
+
+import (
+	"bytes"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ShallowStorage implements the storer.ShallowStorer over a Store. The
+// shallow commits are stored as in the shallow file, one hash per line.
+type ShallowStorage struct {
+	buckets
+}
+
+// SetShallow honors the storer.ShallowStorer interface.
+func (s *ShallowStorage) SetShallow(commits []plumbing.Hash) error {
+	var buf bytes.Buffer
+	for _, h := range commits {
+		buf.WriteString(h.String())
+		buf.WriteByte('\n')
+	}
+
+	return s.put(metaBucket, shallowKey, buf.Bytes())
+}
+
+// Shallow honors the storer.ShallowStorer interface.
+func (s *ShallowStorage) Shallow() ([]plumbing.Hash, error) {
+	value, err := s.get(metaBucket, shallowKey)
+	if err != nil {
+		if err == ErrKeyNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	for _, line := range bytes.Split(value, []byte{'\n'}) {
+		if len(line) != 0 {
+			hashes = append(hashes, plumbing.NewHash(string(line)))
+		}
+	}
+
+	return hashes, nil
+}
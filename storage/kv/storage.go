@@ -0,0 +1,92 @@
+package kv
+
+import (
+	"github.com/go-git/go-git/v5/storage"
+)
+
+const (
+	objectsBucket = "objects"
+	// objectTypesBucket indexes the objects by type, with "<type>/<hash>"
+	// keys and empty values, to iterate them without reading their values.
+	objectTypesBucket = "object-types"
+	refsBucket        = "refs"
+	// metaBucket holds the config, index and shallow files, by name.
+	metaBucket = "meta"
+
+	configKey  = "config"
+	indexKey   = "index"
+	shallowKey = "shallow"
+
+	modulesPrefix = "modules/"
+)
+
+// Storage is an implementation of git.Storer that stores the repository in a
+// Store. Every repository, and every submodule, uses its own buckets, so
+// several repositories can share the same Store using different names.
+type Storage struct {
+	ObjectStorage
+	ReferenceStorage
+	IndexStorage
+	ShallowStorage
+	ConfigStorage
+	ModuleStorage
+}
+
+// NewStorage returns a new Storage for the repository with the given name,
+// which is used as prefix of the buckets of the Store.
+func NewStorage(s Store, name string) *Storage {
+	b := buckets{store: s, prefix: name}
+	if name != "" {
+		b.prefix += "/"
+	}
+
+	return &Storage{
+		ObjectStorage:    ObjectStorage{b},
+		ReferenceStorage: ReferenceStorage{b},
+		IndexStorage:     IndexStorage{b},
+		ShallowStorage:   ShallowStorage{b},
+		ConfigStorage:    ConfigStorage{b},
+		ModuleStorage:    ModuleStorage{b},
+	}
+}
+
+// buckets gives access to the buckets of a repository.
+type buckets struct {
+	store  Store
+	prefix string
+}
+
+func (b buckets) bucket(name string) string {
+	return b.prefix + name
+}
+
+func (b buckets) get(bucket, key string) (value []byte, err error) {
+	err = b.store.View(func(tx Tx) error {
+		v, err := tx.Get(b.bucket(bucket), key)
+		if err != nil {
+			return err
+		}
+
+		value = append([]byte(nil), v...)
+		return nil
+	})
+
+	return value, err
+}
+
+func (b buckets) put(bucket, key string, value []byte) error {
+	return b.store.Update(func(tx Tx) error {
+		return tx.Put(b.bucket(bucket), key, value)
+	})
+}
+
+// ModuleStorage implements the storage.ModuleStorer over a Store, using the
+// buckets of the repository, prefixed by the name of the module.
+type ModuleStorage struct {
+	buckets
+}
+
+// Module honors the storage.ModuleStorer interface.
+func (s *ModuleStorage) Module(name string) (storage.Storer, error) {
+	return NewStorage(s.store, s.bucket(modulesPrefix+name)), nil
+}
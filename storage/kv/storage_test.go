@@ -0,0 +1,168 @@
+package kv_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/storage/kv"
+	"github.com/go-git/go-git/v5/storage/test"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StorageSuite struct {
+	test.BaseStorageSuite
+	store *memoryStore
+}
+
+var _ = Suite(&StorageSuite{})
+
+func (s *StorageSuite) SetUpTest(c *C) {
+	s.store = newMemoryStore()
+	s.BaseStorageSuite = test.NewBaseStorageSuite(kv.NewStorage(s.store, "repo"))
+}
+
+func (s *StorageSuite) TestRepositoriesAreIsolated(c *C) {
+	other := kv.NewStorage(s.store, "other")
+
+	ref := plumbing.NewReferenceFromStrings("refs/heads/master", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(s.Storer.SetReference(ref), IsNil)
+
+	_, err := other.Reference(ref.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	m, err := s.Storer.Module("other")
+	c.Assert(err, IsNil)
+
+	_, err = m.Reference(ref.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *StorageSuite) TestSymbolicReference(c *C) {
+	ref := plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/master")
+	c.Assert(s.Storer.SetReference(ref), IsNil)
+
+	stored, err := s.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(stored.Type(), Equals, plumbing.SymbolicReference)
+	c.Assert(stored.Target(), Equals, plumbing.ReferenceName("refs/heads/master"))
+}
+
+func (s *StorageSuite) TestEncodedObjectNotFound(c *C) {
+	h := plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+	_, err := s.Storer.EncodedObject(plumbing.AnyObject, h)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+	c.Assert(s.Storer.HasEncodedObject(h), Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *StorageSuite) TestUpdateObjectStorage(c *C) {
+	f := fixtures.Basic().One()
+	c.Assert(packfile.UpdateObjectStorage(s.Storer, f.Packfile()), IsNil)
+
+	s.store.scanned = nil
+	iter, err := s.Storer.IterEncodedObjects(plumbing.CommitObject)
+	c.Assert(err, IsNil)
+
+	// the objects are found in the type index, without reading them all
+	c.Assert(s.store.scanned, DeepEquals, []string{"repo/object-types"})
+
+	count := 0
+	c.Assert(iter.ForEach(func(o plumbing.EncodedObject) error {
+		c.Assert(o.Type(), Equals, plumbing.CommitObject)
+		count++
+		return nil
+	}), IsNil)
+	c.Assert(count, Equals, 9)
+
+	o, err := s.Storer.EncodedObject(plumbing.AnyObject, plumbing.NewHash(f.Head))
+	c.Assert(err, IsNil)
+	c.Assert(o.Hash().String(), Equals, f.Head)
+}
+
+// memoryStore is a kv.Store keeping the buckets in memory, the changes of a
+// transaction being applied to a copy of them. The buckets iterated are
+// recorded in scanned.
+type memoryStore struct {
+	buckets map[string]map[string][]byte
+	scanned []string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: make(map[string]map[string][]byte)}
+}
+
+func (s *memoryStore) View(fn func(kv.Tx) error) error {
+	return fn(&memoryTx{s: s, buckets: s.buckets})
+}
+
+func (s *memoryStore) Update(fn func(kv.Tx) error) error {
+	buckets := make(map[string]map[string][]byte, len(s.buckets))
+	for name, b := range s.buckets {
+		buckets[name] = make(map[string][]byte, len(b))
+		for k, v := range b {
+			buckets[name][k] = v
+		}
+	}
+
+	if err := fn(&memoryTx{s: s, buckets: buckets}); err != nil {
+		return err
+	}
+
+	s.buckets = buckets
+	return nil
+}
+
+type memoryTx struct {
+	s       *memoryStore
+	buckets map[string]map[string][]byte
+}
+
+func (t *memoryTx) Get(bucket, key string) ([]byte, error) {
+	v, ok := t.buckets[bucket][key]
+	if !ok {
+		return nil, kv.ErrKeyNotFound
+	}
+
+	return v, nil
+}
+
+func (t *memoryTx) Put(bucket, key string, value []byte) error {
+	if t.buckets[bucket] == nil {
+		t.buckets[bucket] = make(map[string][]byte)
+	}
+
+	t.buckets[bucket][key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (t *memoryTx) Delete(bucket, key string) error {
+	delete(t.buckets[bucket], key)
+	return nil
+}
+
+func (t *memoryTx) ForEach(bucket, prefix string, fn func(key string, value []byte) error) error {
+	t.s.scanned = append(t.s.scanned, bucket)
+
+	var keys []string
+	for k := range t.buckets[bucket] {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, t.buckets[bucket][k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -66,11 +66,13 @@ func (c *ConfigStorage) Config() (*config.Config, error) {
 }
 
 type IndexStorage struct {
-	index *index.Index
+	index   *index.Index
+	modTime time.Time
 }
 
 func (c *IndexStorage) SetIndex(idx *index.Index) error {
 	c.index = idx
+	c.modTime = time.Now()
 	return nil
 }
 
@@ -82,6 +84,10 @@ func (c *IndexStorage) Index() (*index.Index, error) {
 	return c.index, nil
 }
 
+func (c *IndexStorage) IndexModTime() (time.Time, error) {
+	return c.modTime, nil
+}
+
 type ObjectStorage struct {
 	Objects map[plumbing.Hash]plumbing.EncodedObject
 	Commits map[plumbing.Hash]plumbing.EncodedObject
@@ -299,6 +305,27 @@ func (r ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
 	return nil
 }
 
+func (r ReferenceStorage) BeginReferenceTransaction() (storer.ReferenceTransaction, error) {
+	return storer.NewReferenceTransaction(func(updates []*storer.ReferenceUpdate) error {
+		for _, u := range updates {
+			if err := u.Check(r[u.Name]); err != nil {
+				return err
+			}
+		}
+
+		for _, u := range updates {
+			if u.IsDelete() {
+				delete(r, u.Name)
+				continue
+			}
+
+			r[u.Name] = u.New
+		}
+
+		return nil
+	}), nil
+}
+
 type ShallowStorage []plumbing.Hash
 
 func (s *ShallowStorage) SetShallow(commits []plumbing.Hash) error {
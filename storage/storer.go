@@ -1,13 +1,14 @@
 package storage
 
 import (
-	"errors"
-
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 )
 
-var ErrReferenceHasChanged = errors.New("reference has changed concurrently")
+// ErrReferenceHasChanged is returned when a reference does not have the
+// expected value while being updated. It's the same error as
+// storer.ErrReferenceHasChanged.
+var ErrReferenceHasChanged = storer.ErrReferenceHasChanged
 
 // Storer is a generic storage of objects, references and any information
 // related to a particular repository. The package github.com/go-git/go-git/v5/storage
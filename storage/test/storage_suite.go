@@ -326,6 +326,93 @@ func (s *BaseStorageSuite) TestCheckAndSetReferenceError(c *C) {
 	c.Assert(e.Hash().String(), Equals, "c3f4688a08fd86f1bf8e055724c84b7a40a09733")
 }
 
+func (s *BaseStorageSuite) TestReferenceTransaction(c *C) {
+	err := s.Storer.SetReference(
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "482e0eada5de4039e6f216b45b3c9b683b83bfa"),
+	)
+	c.Assert(err, IsNil)
+
+	err = s.Storer.SetReference(
+		plumbing.NewReferenceFromStrings("refs/heads/bar", "c3f4688a08fd86f1bf8e055724c84b7a40a09733"),
+	)
+	c.Assert(err, IsNil)
+
+	tx, err := storer.BeginReferenceTransaction(s.Storer)
+	c.Assert(err, IsNil)
+
+	c.Assert(tx.Update(
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "482e0eada5de4039e6f216b45b3c9b683b83bfa"),
+	), IsNil)
+	c.Assert(tx.Create(
+		plumbing.NewReferenceFromStrings("refs/heads/qux", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+	), IsNil)
+	c.Assert(tx.Delete(
+		plumbing.ReferenceName("refs/heads/bar"),
+		plumbing.NewReferenceFromStrings("refs/heads/bar", "c3f4688a08fd86f1bf8e055724c84b7a40a09733"),
+	), IsNil)
+	c.Assert(tx.Delete(plumbing.ReferenceName("refs/heads/bar"), nil), Equals, storer.ErrDuplicateReferenceUpdate)
+	c.Assert(tx.Commit(), IsNil)
+	c.Assert(tx.Commit(), Equals, storer.ErrTransactionClosed)
+
+	e, err := s.Storer.Reference(plumbing.ReferenceName("refs/heads/foo"))
+	c.Assert(err, IsNil)
+	c.Assert(e.Hash().String(), Equals, "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+	e, err = s.Storer.Reference(plumbing.ReferenceName("refs/heads/qux"))
+	c.Assert(err, IsNil)
+	c.Assert(e.Hash().String(), Equals, "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+	_, err = s.Storer.Reference(plumbing.ReferenceName("refs/heads/bar"))
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *BaseStorageSuite) TestReferenceTransactionError(c *C) {
+	err := s.Storer.SetReference(
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "c3f4688a08fd86f1bf8e055724c84b7a40a09733"),
+	)
+	c.Assert(err, IsNil)
+
+	tx, err := storer.BeginReferenceTransaction(s.Storer)
+	c.Assert(err, IsNil)
+
+	c.Assert(tx.Create(
+		plumbing.NewReferenceFromStrings("refs/heads/bar", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+	), IsNil)
+	c.Assert(tx.Update(
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "482e0eada5de4039e6f216b45b3c9b683b83bfa"),
+	), IsNil)
+	c.Assert(tx.Commit(), Equals, storage.ErrReferenceHasChanged)
+
+	e, err := s.Storer.Reference(plumbing.ReferenceName("refs/heads/foo"))
+	c.Assert(err, IsNil)
+	c.Assert(e.Hash().String(), Equals, "c3f4688a08fd86f1bf8e055724c84b7a40a09733")
+
+	_, err = s.Storer.Reference(plumbing.ReferenceName("refs/heads/bar"))
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	tx, err = storer.BeginReferenceTransaction(s.Storer)
+	c.Assert(err, IsNil)
+
+	c.Assert(tx.Create(
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+	), IsNil)
+	c.Assert(tx.Commit(), Equals, storage.ErrReferenceHasChanged)
+
+	tx, err = storer.BeginReferenceTransaction(s.Storer)
+	c.Assert(err, IsNil)
+
+	c.Assert(tx.Create(
+		plumbing.NewReferenceFromStrings("refs/heads/bar", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+	), IsNil)
+	c.Assert(tx.Abort(), IsNil)
+	c.Assert(tx.Commit(), Equals, storer.ErrTransactionClosed)
+
+	_, err = s.Storer.Reference(plumbing.ReferenceName("refs/heads/bar"))
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
 func (s *BaseStorageSuite) TestRemoveReference(c *C) {
 	err := s.Storer.SetReference(
 		plumbing.NewReferenceFromStrings("foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
@@ -5,9 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/index"
@@ -17,6 +19,8 @@ import (
 var (
 	ErrSubmoduleAlreadyInitialized = errors.New("submodule already initialized")
 	ErrSubmoduleNotInitialized     = errors.New("submodule not initialized")
+	ErrSubmoduleAlreadyExists      = errors.New("submodule already exists")
+	ErrSubmoduleModified           = errors.New("submodule contains local modifications")
 )
 
 // Submodule a submodule allows you to keep another Git repository in a
@@ -125,7 +129,17 @@ func (s *Submodule) Repository() (*Repository, error) {
 	}
 
 	if exists {
-		return Open(storer, worktree)
+		r, err := Open(storer, worktree)
+		if err != nil {
+			return nil, err
+		}
+
+		// the .git file is removed when the submodule is deinitialized
+		if _, err := worktree.Lstat(GitDirName); os.IsNotExist(err) {
+			return r, setWorktreeAndStoragePaths(r, worktree)
+		}
+
+		return r, nil
 	}
 
 	r, err := Init(storer, worktree)
@@ -133,6 +147,22 @@ func (s *Submodule) Repository() (*Repository, error) {
 		return nil, err
 	}
 
+	moduleEndpoint, err := s.endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{moduleEndpoint.String()},
+	})
+
+	return r, err
+}
+
+// endpoint returns the endpoint of the submodule URL, the relative URLs are
+// resolved against the URL of the remote of the superproject.
+func (s *Submodule) endpoint() (*transport.Endpoint, error) {
 	moduleEndpoint, err := transport.NewEndpoint(s.c.URL)
 	if err != nil {
 		return nil, err
@@ -153,12 +183,181 @@ func (s *Submodule) Repository() (*Repository, error) {
 		*moduleEndpoint = *rootEndpoint
 	}
 
-	_, err = r.CreateRemote(&config.RemoteConfig{
-		Name: DefaultRemoteName,
-		URLs: []string{moduleEndpoint.String()},
-	})
+	return moduleEndpoint, nil
+}
 
-	return r, err
+// isPopulated returns true if the repository of the submodule was already
+// cloned into the superproject.
+func (s *Submodule) isPopulated() (bool, error) {
+	storer, err := s.w.r.Storer.Module(s.c.Name)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = storer.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Deinit unregisters the submodule, removing its section from the config of
+// the repository and the files of its working tree. The repository of the
+// submodule is kept, so it can be initialized and updated again. Unless force
+// is true, ErrSubmoduleModified is returned if the working tree of the
+// submodule contains local modifications.
+func (s *Submodule) Deinit(force bool) error {
+	if !s.initialized {
+		return ErrSubmoduleNotInitialized
+	}
+
+	populated, err := s.isPopulated()
+	if err != nil {
+		return err
+	}
+
+	if populated && !force {
+		if err := s.checkClean(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.removeWorktree(); err != nil {
+		return err
+	}
+
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	delete(cfg.Submodules, s.c.Name)
+	if err := s.w.r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	s.initialized = false
+	return nil
+}
+
+func (s *Submodule) checkClean() error {
+	r, err := s.Repository()
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	if !status.IsClean() {
+		return ErrSubmoduleModified
+	}
+
+	return nil
+}
+
+func (s *Submodule) isWorktreeEmpty() (bool, error) {
+	files, err := s.readWorktree()
+	return len(files) == 0, err
+}
+
+func (s *Submodule) readWorktree() ([]os.FileInfo, error) {
+	files, err := s.w.Filesystem.ReadDir(s.c.Path)
+	if err != nil && os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return files, err
+}
+
+// removeWorktree removes the content of the working tree of the submodule,
+// keeping the empty directory as git does.
+func (s *Submodule) removeWorktree() error {
+	fs := s.w.Filesystem
+	files, err := s.readWorktree()
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		if err := util.RemoveAll(fs, fs.Join(s.c.Path, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sync synchronizes the URL of the submodule with the one recorded at the
+// .gitmodules file, updating the config of the repository and the default
+// remote of the submodule repository, if it was already cloned.
+func (s *Submodule) Sync() error {
+	if !s.initialized {
+		return ErrSubmoduleNotInitialized
+	}
+
+	m, err := s.w.readGitmodulesFile()
+	if err != nil {
+		return err
+	}
+
+	if m == nil || m.Submodules[s.c.Name] == nil {
+		return ErrSubmoduleNotFound
+	}
+
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	s.c.URL = m.Submodules[s.c.Name].URL
+	if c, ok := cfg.Submodules[s.c.Name]; ok {
+		c.URL = s.c.URL
+	}
+
+	if err := s.w.r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	populated, err := s.isPopulated()
+	if err != nil || !populated {
+		return err
+	}
+
+	return s.syncRemote()
+}
+
+func (s *Submodule) syncRemote() error {
+	moduleEndpoint, err := s.endpoint()
+	if err != nil {
+		return err
+	}
+
+	r, err := s.Repository()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	remote, ok := cfg.Remotes[DefaultRemoteName]
+	if !ok {
+		return nil
+	}
+
+	remote.URLs = []string{moduleEndpoint.String()}
+	return r.Storer.SetConfig(cfg)
 }
 
 // Update the registered submodule to match what the superproject expects, the
@@ -205,12 +404,20 @@ func (s *Submodule) update(ctx context.Context, o *SubmoduleUpdateOptions, force
 		hash = e.Hash
 	}
 
+	// the working tree is empty if it was never checked out or if the
+	// submodule was deinitialized, it is then checked out discarding the
+	// index of the submodule
+	empty, err := s.isWorktreeEmpty()
+	if err != nil {
+		return err
+	}
+
 	r, err := s.Repository()
 	if err != nil {
 		return err
 	}
 
-	if err := s.fetchAndCheckout(ctx, r, o, hash); err != nil {
+	if err := s.fetchAndCheckout(ctx, r, o, hash, empty); err != nil {
 		return err
 	}
 
@@ -240,7 +447,7 @@ func (s *Submodule) doRecursiveUpdate(ctx context.Context, r *Repository, o *Sub
 }
 
 func (s *Submodule) fetchAndCheckout(
-	ctx context.Context, r *Repository, o *SubmoduleUpdateOptions, hash plumbing.Hash,
+	ctx context.Context, r *Repository, o *SubmoduleUpdateOptions, hash plumbing.Hash, force bool,
 ) error {
 	if !o.NoFetch {
 		err := r.FetchContext(ctx, &FetchOptions{Auth: o.Auth, Depth: o.Depth})
@@ -273,7 +480,7 @@ func (s *Submodule) fetchAndCheckout(
 		}
 	}
 
-	if err := w.Checkout(&CheckoutOptions{Hash: hash}); err != nil {
+	if err := w.Checkout(&CheckoutOptions{Hash: hash, Force: force}); err != nil {
 		return err
 	}
 
@@ -315,6 +522,72 @@ func (s Submodules) UpdateContext(ctx context.Context, o *SubmoduleUpdateOptions
 	return nil
 }
 
+// Sync synchronizes the URL of the initialized submodules in this list.
+func (s Submodules) Sync() error {
+	for _, sub := range s {
+		if !sub.initialized {
+			continue
+		}
+
+		if err := sub.Sync(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Foreach calls fn with each submodule in this list and its Repository, the
+// submodules not initialized or not cloned yet are skipped. The nested
+// submodules are visited after their parent until the given recursion depth
+// is reached, as `git submodule foreach --recursive` does. If fn returns an
+// error the iteration stops and the error is returned.
+func (s Submodules) Foreach(recurse SubmoduleRescursivity, fn func(*Submodule, *Repository) error) error {
+	for _, sub := range s {
+		if !sub.initialized {
+			continue
+		}
+
+		populated, err := sub.isPopulated()
+		if err != nil {
+			return err
+		}
+
+		if !populated {
+			continue
+		}
+
+		r, err := sub.Repository()
+		if err != nil {
+			return err
+		}
+
+		if err := fn(sub, r); err != nil {
+			return err
+		}
+
+		if recurse == NoRecurseSubmodules {
+			continue
+		}
+
+		w, err := r.Worktree()
+		if err != nil {
+			return err
+		}
+
+		l, err := w.Submodules()
+		if err != nil {
+			return err
+		}
+
+		if err := l.Foreach(recurse-1, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Status returns the status of the submodules.
 func (s Submodules) Status() (SubmodulesStatus, error) {
 	var list SubmodulesStatus
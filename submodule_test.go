@@ -2,12 +2,15 @@ package git
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
 	"testing"
 
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/storage/memory"
 
 	fixtures "github.com/go-git/go-git-fixtures/v4"
@@ -278,3 +281,171 @@ func (s *SubmoduleSuite) TestSubmoduleParseScp(c *C) {
 	_, err := submodule.Repository()
 	c.Assert(err, IsNil)
 }
+
+func (s *SubmoduleSuite) TestAddSubmodule(c *C) {
+	url := s.GetBasicLocalRepositoryURL()
+	sm, err := s.Worktree.AddSubmodule(url, "vendor/basic", nil)
+	c.Assert(err, IsNil)
+	c.Assert(sm.initialized, Equals, true)
+	c.Assert(sm.Config().Name, Equals, "vendor/basic")
+	c.Assert(sm.Config().Path, Equals, "vendor/basic")
+
+	_, err = s.Worktree.Filesystem.Stat("vendor/basic/LICENSE")
+	c.Assert(err, IsNil)
+
+	idx, err := s.Repository.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry("vendor/basic")
+	c.Assert(err, IsNil)
+	c.Assert(e.Mode, Equals, filemode.Submodule)
+	c.Assert(e.Hash.String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	m, err := s.Worktree.readGitmodulesFile()
+	c.Assert(err, IsNil)
+	c.Assert(m.Submodules, HasLen, 3)
+	c.Assert(m.Submodules["vendor/basic"].URL, Equals, url)
+
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Submodules["vendor/basic"].URL, Equals, url)
+
+	sm, err = s.Worktree.Submodule("vendor/basic")
+	c.Assert(err, IsNil)
+
+	status, err := sm.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	_, err = s.Worktree.AddSubmodule(s.GetBasicLocalRepositoryURL(), "vendor/basic", nil)
+	c.Assert(errors.Is(err, ErrSubmoduleAlreadyExists), Equals, true)
+
+	_, err = s.Worktree.AddSubmodule(s.GetBasicLocalRepositoryURL(), "vendor", &SubmoduleAddOptions{
+		Name: "other",
+	})
+	c.Assert(errors.Is(err, ErrDestinationExists), Equals, true)
+}
+
+func (s *SubmoduleSuite) TestAddSubmoduleBranch(c *C) {
+	sm, err := s.Worktree.AddSubmodule(s.GetBasicLocalRepositoryURL(), "branch", &SubmoduleAddOptions{
+		Name:   "basic-branch",
+		Branch: "branch",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(sm.Config().Name, Equals, "basic-branch")
+
+	status, err := sm.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.Expected.String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	m, err := s.Worktree.readGitmodulesFile()
+	c.Assert(err, IsNil)
+	c.Assert(m.Submodules["basic-branch"].Branch, Equals, "branch")
+}
+
+func (s *SubmoduleSuite) TestDeinit(c *C) {
+	sm, err := s.Worktree.AddSubmodule(s.GetBasicLocalRepositoryURL(), "vendor/basic", nil)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(s.Worktree.Filesystem, "vendor/basic/LICENSE", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	err = sm.Deinit(false)
+	c.Assert(err, Equals, ErrSubmoduleModified)
+
+	err = sm.Deinit(true)
+	c.Assert(err, IsNil)
+	c.Assert(sm.initialized, Equals, false)
+
+	files, err := s.Worktree.Filesystem.ReadDir("vendor/basic")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Submodules["vendor/basic"], IsNil)
+
+	err = sm.Deinit(false)
+	c.Assert(err, Equals, ErrSubmoduleNotInitialized)
+
+	sm, err = s.Worktree.Submodule("vendor/basic")
+	c.Assert(err, IsNil)
+
+	err = sm.Update(&SubmoduleUpdateOptions{Init: true, NoFetch: true})
+	c.Assert(err, IsNil)
+
+	_, err = s.Worktree.Filesystem.Stat("vendor/basic/LICENSE")
+	c.Assert(err, IsNil)
+
+	_, err = s.Worktree.Filesystem.Stat("vendor/basic/.git")
+	c.Assert(err, IsNil)
+}
+
+func (s *SubmoduleSuite) TestSync(c *C) {
+	sm, err := s.Worktree.AddSubmodule(s.GetBasicLocalRepositoryURL(), "vendor/basic", nil)
+	c.Assert(err, IsNil)
+
+	url := s.GetLocalRepositoryURL(fixtures.ByTag("tags").One())
+
+	m, err := s.Worktree.readGitmodulesFile()
+	c.Assert(err, IsNil)
+	m.Submodules["vendor/basic"].URL = url
+	c.Assert(s.Worktree.writeGitmodulesFile(m), IsNil)
+
+	l, err := s.Worktree.Submodules()
+	c.Assert(err, IsNil)
+	c.Assert(l.Sync(), IsNil)
+
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Submodules["vendor/basic"].URL, Equals, url)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	remote, err := r.Remote(DefaultRemoteName)
+	c.Assert(err, IsNil)
+	c.Assert(remote.Config().URLs, DeepEquals, []string{"file://" + url})
+}
+
+func (s *SubmoduleSuite) TestSubmodulesForeach(c *C) {
+	sm, err := s.Worktree.AddSubmodule(s.GetBasicLocalRepositoryURL(), "vendor/basic", nil)
+	c.Assert(err, IsNil)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.AddSubmodule(s.GetBasicLocalRepositoryURL(), "nested", nil)
+	c.Assert(err, IsNil)
+
+	l, err := s.Worktree.Submodules()
+	c.Assert(err, IsNil)
+
+	var paths []string
+	collect := func(sub *Submodule, r *Repository) error {
+		head, err := r.Head()
+		c.Assert(err, IsNil)
+		c.Assert(head.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+		paths = append(paths, sub.Config().Path)
+		return nil
+	}
+
+	err = l.Foreach(NoRecurseSubmodules, collect)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"vendor/basic"})
+
+	paths = nil
+	err = l.Foreach(DefaultSubmoduleRecursionDepth, collect)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"vendor/basic", "nested"})
+
+	stop := errors.New("stop")
+	err = l.Foreach(DefaultSubmoduleRecursionDepth, func(*Submodule, *Repository) error {
+		return stop
+	})
+	c.Assert(err, Equals, stop)
+}
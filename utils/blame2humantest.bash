@@ -44,4 +44,14 @@ for i in ${blame[@]} ; do
     fi
 done
 echo -e "\t\trepeat(\"${prev}\", $count),"
-echo -e "\t)},"
+echo -e "\t), []blameTestEntry{"
+git blame --root --porcelain $commit -- $path | awk -v path="$path" '
+/^[0-9a-f]+ [0-9]+ [0-9]+ [0-9]+$/ { hash = $1; src = $2; final = $3; n = $4; pending = 1; next }
+/^previous / { prev[hash] = $2; next }
+/^filename / { file[hash] = substr($0, 10); next }
+/^\t/ && pending {
+    f = file[hash] == path ? "" : file[hash]
+    printf "\t\t{%s, %s, %s, \"%s\", \"%s\"},\n", src, final, n, f, prev[hash]
+    pending = 0
+}'
+echo -e "\t}},"
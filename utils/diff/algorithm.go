@@ -0,0 +1,248 @@
+package diff
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Algorithm is a line diff algorithm.
+type Algorithm int
+
+const (
+	// Myers is the default algorithm, using diffmatchpatch. If the indent
+	// heuristic is used, it uses a native implementation with the same
+	// heuristics as git, which may not produce the smallest diff when the
+	// files are very different.
+	Myers Algorithm = iota
+	// Minimal is the Myers algorithm producing the smallest possible diff.
+	Minimal
+	// Patience matches first the lines that are unique in both sides, and
+	// diffs recursively the lines between them.
+	Patience
+	// Histogram extends the Patience algorithm to support the lines that
+	// are not unique, matching first the lines with less occurrences.
+	Histogram
+)
+
+// ErrUnknownAlgorithm is returned when parsing an unknown algorithm name.
+var ErrUnknownAlgorithm = errors.New("unknown diff algorithm")
+
+// ParseAlgorithm returns the algorithm with the given name, as the values of
+// the `diff.algorithm` config: "default" or "myers", "minimal", "patience"
+// and "histogram".
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch strings.ToLower(name) {
+	case "", "default", "myers":
+		return Myers, nil
+	case "minimal":
+		return Minimal, nil
+	case "patience":
+		return Patience, nil
+	case "histogram":
+		return Histogram, nil
+	}
+
+	return Myers, ErrUnknownAlgorithm
+}
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case Myers:
+		return "myers"
+	case Minimal:
+		return "minimal"
+	case Patience:
+		return "patience"
+	case Histogram:
+		return "histogram"
+	}
+
+	return "unknown"
+}
+
+// Options are the options of a line diff.
+type Options struct {
+	// Algorithm is the algorithm used to compute the diff.
+	Algorithm Algorithm
+	// IndentHeuristic shifts the groups of added or deleted lines, when
+	// they can be shifted, so the boundaries of the groups fall where the
+	// diff is easier to read, based on the indentation of the lines around
+	// them, as git does by default.
+	IndentHeuristic bool
+	// IgnoreSpaceChange compares the lines ignoring the changes in amount
+	// of whitespace, the trailing whitespaces being ignored.
+	IgnoreSpaceChange bool
+	// IgnoreAllSpace compares the lines ignoring all the whitespaces.
+	IgnoreAllSpace bool
+	// Native uses the native implementation of the Myers algorithm even
+	// without heuristics, producing the same diffs as git, instead of
+	// diffmatchpatch.
+	Native bool
+}
+
+// DoWithOptions computes the (line oriented) modifications needed to turn
+// the src string into the dst string using the given options. Except for
+// the default Myers algorithm without heuristics and not native, the groups
+// of changes are shifted as git does, aligning them with the changes of the other side
+// when possible, or down as far as possible otherwise. If whitespaces are
+// ignored, the equal lines are the ones of dst, so Src of the diffs may not
+// be src.
+func DoWithOptions(src, dst string, o Options) []diffmatchpatch.Diff {
+	if o.Algorithm == Myers && !o.Native && !o.IndentHeuristic && !o.IgnoreSpaceChange && !o.IgnoreAllSpace {
+		return Do(src, dst)
+	}
+
+	d := newDiffer(src, dst, o)
+	switch o.Algorithm {
+	case Patience:
+		d.patience(0, len(d.a.lines), 0, len(d.b.lines))
+	case Histogram:
+		d.histogram(0, len(d.a.lines), 0, len(d.b.lines))
+	default:
+		d.myers(0, len(d.a.lines), 0, len(d.b.lines), o.Algorithm == Minimal)
+	}
+
+	d.a.compact(d.b, o.IndentHeuristic)
+	d.b.compact(d.a, o.IndentHeuristic)
+
+	return d.diffs()
+}
+
+// file is one side of a diff, changed has a sentinel at both ends, so the
+// change flag of the line i is changed[i+1].
+type file struct {
+	text    []string
+	lines   []int
+	changed []bool
+}
+
+func (f *file) isChanged(i int) bool {
+	return f.changed[i+1]
+}
+
+func (f *file) setChanged(i int, v bool) {
+	f.changed[i+1] = v
+}
+
+// differ computes the changed lines of two files, the lines are compared
+// by their ids, equal lines sharing the same id.
+type differ struct {
+	a, b *file
+}
+
+func newDiffer(src, dst string, o Options) *differ {
+	ids := make(map[string]int)
+	newFile := func(s string) *file {
+		text := splitLines(s)
+		f := &file{
+			text:    text,
+			lines:   make([]int, len(text)),
+			changed: make([]bool, len(text)+2),
+		}
+
+		for i, l := range text {
+			if o.IgnoreSpaceChange || o.IgnoreAllSpace {
+				l = normalizeWhitespace(l, o.IgnoreAllSpace)
+			}
+
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+
+			f.lines[i] = id
+		}
+
+		return f
+	}
+
+	return &differ{a: newFile(src), b: newFile(dst)}
+}
+
+// splitLines splits s in lines, keeping the line endings.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+
+	return lines
+}
+
+// normalizeWhitespace returns the line without its whitespaces if all is
+// true, or else without its trailing whitespaces and with the runs of
+// whitespaces collapsed to a space, as git compares the lines ignoring
+// whitespaces.
+func normalizeWhitespace(line string, all bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(line); i++ {
+		if !isSpace(line[i]) {
+			sb.WriteByte(line[i])
+			continue
+		}
+
+		for i+1 < len(line) && isSpace(line[i+1]) {
+			i++
+		}
+
+		if !all && i+1 < len(line) {
+			sb.WriteByte(' ')
+		}
+	}
+
+	return sb.String()
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+// changeRange marks as changed the lines [start, end) of f.
+func (f *file) changeRange(start, end int) {
+	for i := start; i < end; i++ {
+		f.setChanged(i, true)
+	}
+}
+
+// diffs returns the changed lines as diffs, the deleted lines being before
+// the inserted ones.
+func (d *differ) diffs() []diffmatchpatch.Diff {
+	var diffs []diffmatchpatch.Diff
+	add := func(op diffmatchpatch.Operation, text string) {
+		if n := len(diffs); n != 0 && diffs[n-1].Type == op {
+			diffs[n-1].Text += text
+			return
+		}
+
+		diffs = append(diffs, diffmatchpatch.Diff{Type: op, Text: text})
+	}
+
+	i, j := 0, 0
+	for i < len(d.a.lines) || j < len(d.b.lines) {
+		switch {
+		case i < len(d.a.lines) && d.a.isChanged(i):
+			add(diffmatchpatch.DiffDelete, d.a.text[i])
+			i++
+		case j < len(d.b.lines) && d.b.isChanged(j):
+			add(diffmatchpatch.DiffInsert, d.b.text[j])
+			j++
+		default:
+			add(diffmatchpatch.DiffEqual, d.b.text[j])
+			i++
+			j++
+		}
+	}
+
+	return diffs
+}
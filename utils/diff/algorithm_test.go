@@ -0,0 +1,138 @@
+package diff_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	. "gopkg.in/check.v1"
+)
+
+type AlgorithmSuite struct{}
+
+var _ = Suite(&AlgorithmSuite{})
+
+var algorithms = []diff.Algorithm{
+	diff.Myers, diff.Minimal, diff.Patience, diff.Histogram,
+}
+
+func (s *AlgorithmSuite) TestParseAlgorithm(c *C) {
+	for name, exp := range map[string]diff.Algorithm{
+		"":          diff.Myers,
+		"default":   diff.Myers,
+		"myers":     diff.Myers,
+		"minimal":   diff.Minimal,
+		"Patience":  diff.Patience,
+		"histogram": diff.Histogram,
+	} {
+		a, err := diff.ParseAlgorithm(name)
+		c.Assert(err, IsNil)
+		c.Assert(a, Equals, exp)
+	}
+
+	_, err := diff.ParseAlgorithm("foo")
+	c.Assert(err, Equals, diff.ErrUnknownAlgorithm)
+
+	c.Assert(diff.Histogram.String(), Equals, "histogram")
+}
+
+func (s *AlgorithmSuite) TestDoWithOptionsSrcDst(c *C) {
+	for _, a := range algorithms {
+		for _, ih := range []bool{false, true} {
+			o := diff.Options{Algorithm: a, IndentHeuristic: ih}
+			for i, t := range diffTests {
+				diffs := diff.DoWithOptions(t.src, t.dst, o)
+				c.Assert(diff.Src(diffs), Equals, t.src, Commentf("%s subtest %d", a, i))
+				c.Assert(diff.Dst(diffs), Equals, t.dst, Commentf("%s subtest %d", a, i))
+			}
+		}
+	}
+}
+
+// The expected diffs are the ones of `git diff --diff-algorithm=<algorithm>`.
+var doWithOptionsTests = [...]struct {
+	src, dst string
+	o        diff.Options
+	exp      []diffmatchpatch.Diff
+}{
+	{
+		src: "}\n{\n\n  x\n",
+		dst: "b\n\nc\na\n\nc\n}\n",
+		o:   diff.Options{Algorithm: diff.Myers, IndentHeuristic: true},
+		exp: []diffmatchpatch.Diff{
+			{Type: -1, Text: "}\n{\n"},
+			{Type: 1, Text: "b\n\nc\na\n"},
+			{Type: 0, Text: "\n"},
+			{Type: -1, Text: "  x\n"},
+			{Type: 1, Text: "c\n}\n"},
+		},
+	},
+	{
+		src: "}\n{\n\n  x\n",
+		dst: "b\n\nc\na\n\nc\n}\n",
+		o:   diff.Options{Algorithm: diff.Patience},
+		exp: []diffmatchpatch.Diff{
+			{Type: 1, Text: "b\n\nc\na\n\nc\n"},
+			{Type: 0, Text: "}\n"},
+			{Type: -1, Text: "{\n\n  x\n"},
+		},
+	},
+	{
+		src: "}\n{\n\n  x\n",
+		dst: "b\n\nc\na\n\nc\n}\n",
+		o:   diff.Options{Algorithm: diff.Histogram},
+		exp: []diffmatchpatch.Diff{
+			{Type: -1, Text: "}\n{\n"},
+			{Type: 1, Text: "b\n"},
+			{Type: 0, Text: "\n"},
+			{Type: -1, Text: "  x\n"},
+			{Type: 1, Text: "c\na\n\nc\n}\n"},
+		},
+	},
+	{
+		src: "bar();\n\tfoo();\nbar();\n\n",
+		dst: "bar();\n\n\tfoo();\n\n",
+		o:   diff.Options{Algorithm: diff.Histogram},
+		exp: []diffmatchpatch.Diff{
+			{Type: 0, Text: "bar();\n"},
+			{Type: -1, Text: "\tfoo();\nbar();\n"},
+			{Type: 1, Text: "\n\tfoo();\n"},
+			{Type: 0, Text: "\n"},
+		},
+	},
+	{
+		src: "bar();\n\tfoo();\nbar();\n\n",
+		dst: "bar();\n\n\tfoo();\n\n",
+		o:   diff.Options{Algorithm: diff.Histogram, IndentHeuristic: true},
+		exp: []diffmatchpatch.Diff{
+			{Type: -1, Text: "bar();\n\tfoo();\n"},
+			{Type: 0, Text: "bar();\n\n"},
+			{Type: 1, Text: "\tfoo();\n\n"},
+		},
+	},
+}
+
+func (s *AlgorithmSuite) TestDoWithOptions(c *C) {
+	for i, t := range doWithOptionsTests {
+		diffs := diff.DoWithOptions(t.src, t.dst, t.o)
+		c.Assert(diffs, DeepEquals, t.exp, Commentf("subtest %d", i))
+	}
+}
+
+func (s *AlgorithmSuite) TestDoWithOptionsLarge(c *C) {
+	var src, dst strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&src, "line %d\n", i%97)
+		if i%7 != 0 {
+			fmt.Fprintf(&dst, "line %d\n", i%89)
+		}
+	}
+
+	for _, a := range algorithms {
+		diffs := diff.DoWithOptions(src.String(), dst.String(), diff.Options{Algorithm: a})
+		c.Assert(diff.Src(diffs), Equals, src.String(), Commentf("%s", a))
+		c.Assert(diff.Dst(diffs), Equals, dst.String(), Commentf("%s", a))
+	}
+}
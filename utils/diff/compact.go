@@ -0,0 +1,337 @@
+package diff
+
+// The constants of the indent heuristic, tuned by git on a corpus of human
+// made diffs.
+const (
+	maxIndent = 200
+	maxBlanks = 20
+
+	startOfFilePenalty              = 1
+	endOfFilePenalty                = 21
+	totalBlankWeight                = -30
+	postBlankWeight                 = 6
+	relativeIndentPenalty           = -4
+	relativeIndentWithBlankPenalty  = 10
+	relativeOutdentPenalty          = 24
+	relativeOutdentWithBlankPenalty = 17
+	relativeDedentPenalty           = 23
+	relativeDedentWithBlankPenalty  = 17
+	indentWeight                    = 60
+	indentHeuristicMaxSliding       = 100
+)
+
+// group is a group of consecutive changed lines [start, end), which is empty
+// if start and end are equal.
+type group struct {
+	start, end int
+}
+
+func (f *file) firstGroup() group {
+	g := group{}
+	for f.isChanged(g.end) {
+		g.end++
+	}
+
+	return g
+}
+
+// nextGroup moves g to the next group, it returns false if g is the last.
+func (f *file) nextGroup(g *group) bool {
+	if g.end == len(f.lines) {
+		return false
+	}
+
+	g.start = g.end + 1
+	for g.end = g.start; f.isChanged(g.end); g.end++ {
+	}
+
+	return true
+}
+
+// previousGroup moves g to the previous group, it returns false if g is
+// the first.
+func (f *file) previousGroup(g *group) bool {
+	if g.start == 0 {
+		return false
+	}
+
+	g.end = g.start - 1
+	for g.start = g.end; f.isChanged(g.start - 1); g.start-- {
+	}
+
+	return true
+}
+
+// slideDown shifts g down by one line, merging it with the next group if
+// they become adjacent. It returns false if g cannot be shifted.
+func (f *file) slideDown(g *group) bool {
+	if g.end < len(f.lines) && f.lines[g.start] == f.lines[g.end] {
+		f.setChanged(g.start, false)
+		f.setChanged(g.end, true)
+		g.start++
+		g.end++
+
+		for f.isChanged(g.end) {
+			g.end++
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// slideUp shifts g up by one line, merging it with the previous group if
+// they become adjacent. It returns false if g cannot be shifted.
+func (f *file) slideUp(g *group) bool {
+	if g.start > 0 && f.lines[g.start-1] == f.lines[g.end-1] {
+		g.start--
+		g.end--
+		f.setChanged(g.start, true)
+		f.setChanged(g.end, false)
+
+		for f.isChanged(g.start - 1) {
+			g.start--
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// compact shifts the groups of changed lines of f, o being the other side
+// of the diff. The groups are aligned with the changes of o if possible,
+// otherwise they are shifted down as much as possible or, if indent is
+// true, to the position with the best score of the indent heuristic. The
+// empty groups of f are kept in sync with the groups of o, as git does.
+func (f *file) compact(o *file, indent bool) {
+	g, og := f.firstGroup(), o.firstGroup()
+
+	for {
+		if g.end != g.start {
+			f.compactGroup(o, &g, &og, indent)
+		}
+
+		if !f.nextGroup(&g) {
+			break
+		}
+
+		o.nextGroup(&og)
+	}
+}
+
+func (f *file) compactGroup(o *file, g, og *group, indent bool) {
+	var size, earliestEnd int
+	endMatchingOther := -1
+	for {
+		size = g.end - g.start
+		endMatchingOther = -1
+
+		for f.slideUp(g) {
+			o.previousGroup(og)
+		}
+
+		earliestEnd = g.end
+		if og.end > og.start {
+			endMatchingOther = g.end
+		}
+
+		for f.slideDown(g) {
+			o.nextGroup(og)
+			if og.end > og.start {
+				endMatchingOther = g.end
+			}
+		}
+
+		if size == g.end-g.start {
+			break
+		}
+	}
+
+	switch {
+	case g.end == earliestEnd:
+		// the group cannot be shifted
+	case endMatchingOther != -1:
+		for og.end == og.start {
+			f.slideUp(g)
+			o.previousGroup(og)
+		}
+	case indent:
+		shift := earliestEnd
+		if g.end-size-1 > shift {
+			shift = g.end - size - 1
+		}
+
+		if g.end-indentHeuristicMaxSliding > shift {
+			shift = g.end - indentHeuristicMaxSliding
+		}
+
+		bestShift := -1
+		var best splitScore
+		for ; shift <= g.end; shift++ {
+			var score splitScore
+			score.add(f.measureSplit(shift))
+			score.add(f.measureSplit(shift - size))
+
+			if bestShift == -1 || score.cmp(best) <= 0 {
+				best = score
+				bestShift = shift
+			}
+		}
+
+		for g.end > bestShift {
+			f.slideUp(g)
+			o.previousGroup(og)
+		}
+	}
+}
+
+// splitMeasurement describes the lines around a split between two lines.
+type splitMeasurement struct {
+	endOfFile bool
+	// indent is the indent of the line after the split, -1 if it is blank.
+	indent int
+	// preBlank is the number of blank lines before the split.
+	preBlank int
+	// preIndent is the indent of the first non-blank line before the split.
+	preIndent int
+	// postBlank is the number of blank lines after the line after the split.
+	postBlank int
+	// postIndent is the indent of the first non-blank line after the line
+	// after the split.
+	postIndent int
+}
+
+// splitScore is the badness of a split, the smaller the better.
+type splitScore struct {
+	effectiveIndent int
+	penalty         int
+}
+
+// measureSplit measures the split before the line split.
+func (f *file) measureSplit(split int) splitMeasurement {
+	var m splitMeasurement
+	if split >= len(f.lines) {
+		m.endOfFile = true
+		m.indent = -1
+	} else {
+		m.indent = lineIndent(f.text[split])
+	}
+
+	m.preIndent = -1
+	for i := split - 1; i >= 0; i-- {
+		m.preIndent = lineIndent(f.text[i])
+		if m.preIndent != -1 {
+			break
+		}
+
+		m.preBlank++
+		if m.preBlank == maxBlanks {
+			m.preIndent = 0
+			break
+		}
+	}
+
+	m.postIndent = -1
+	for i := split + 1; i < len(f.lines); i++ {
+		m.postIndent = lineIndent(f.text[i])
+		if m.postIndent != -1 {
+			break
+		}
+
+		m.postBlank++
+		if m.postBlank == maxBlanks {
+			m.postIndent = 0
+			break
+		}
+	}
+
+	return m
+}
+
+// lineIndent returns the indent of a line, tabs counting up to the next
+// multiple of 8, or -1 if the line is blank.
+func lineIndent(line string) int {
+	indent := 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 8 - indent%8
+		case '\n', '\r', '\v', '\f':
+		default:
+			return indent
+		}
+
+		if indent >= maxIndent {
+			return maxIndent
+		}
+	}
+
+	return -1
+}
+
+func (s *splitScore) add(m splitMeasurement) {
+	if m.preIndent == -1 && m.preBlank == 0 {
+		s.penalty += startOfFilePenalty
+	}
+
+	if m.endOfFile {
+		s.penalty += endOfFilePenalty
+	}
+
+	postBlank := 0
+	if m.indent == -1 {
+		postBlank = 1 + m.postBlank
+	}
+
+	totalBlank := m.preBlank + postBlank
+	s.penalty += totalBlankWeight * totalBlank
+	s.penalty += postBlankWeight * postBlank
+
+	indent := m.indent
+	if indent == -1 {
+		indent = m.postIndent
+	}
+
+	anyBlanks := totalBlank != 0
+	s.effectiveIndent += indent
+
+	switch {
+	case indent == -1, m.preIndent == -1, indent == m.preIndent:
+	case indent > m.preIndent:
+		if anyBlanks {
+			s.penalty += relativeIndentWithBlankPenalty
+		} else {
+			s.penalty += relativeIndentPenalty
+		}
+	case m.postIndent != -1 && m.postIndent > indent:
+		if anyBlanks {
+			s.penalty += relativeOutdentWithBlankPenalty
+		} else {
+			s.penalty += relativeOutdentPenalty
+		}
+	default:
+		if anyBlanks {
+			s.penalty += relativeDedentWithBlankPenalty
+		} else {
+			s.penalty += relativeDedentPenalty
+		}
+	}
+}
+
+// cmp returns a negative number if s is better than o, and a positive one
+// if it is worse.
+func (s splitScore) cmp(o splitScore) int {
+	cmpIndents := 0
+	switch {
+	case s.effectiveIndent > o.effectiveIndent:
+		cmpIndents = 1
+	case s.effectiveIndent < o.effectiveIndent:
+		cmpIndents = -1
+	}
+
+	return indentWeight*cmpIndents + s.penalty - o.penalty
+}
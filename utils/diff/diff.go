@@ -1,9 +1,11 @@
 // Package diff implements line oriented diffs, similar to the ancient
 // Unix diff command.
 //
-// The current implementation is just a wrapper around Sergi's
+// The default implementation is just a wrapper around Sergi's
 // go-diff/diffmatchpatch library, which is a go port of Neil
-// Fraser's google-diff-match-patch code
+// Fraser's google-diff-match-patch code. DoWithOptions provides native
+// ports of the myers, minimal, patience and histogram algorithms of git,
+// with its indent heuristic.
 package diff
 
 import (
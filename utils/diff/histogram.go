@@ -0,0 +1,133 @@
+package diff
+
+// maxHistogramChain is the maximum number of occurrences of a line to be
+// used to match a region, as the max_chain_length of git.
+const maxHistogramChain = 64
+
+// histogramRegion is a common region of a and b, the ends are inclusive.
+type histogramRegion struct {
+	begin1, end1 int
+	begin2, end2 int
+}
+
+// histogramIndex holds the occurrences of the lines of the range of a being
+// diffed.
+type histogramIndex struct {
+	// positions are the positions of each line in a, in ascending order.
+	positions map[int][]int
+	// cnt is the number of occurrences of the lines of the best region.
+	cnt       int
+	hasCommon bool
+}
+
+// histogram marks the changed lines of the ranges [line1, end1) of a and
+// [line2, end2) of b. The longest common region containing the lines with
+// the smallest number of occurrences in a is matched first, and the lines
+// before and after it are diffed recursively. If all the common lines have
+// too many occurrences, the ranges are diffed with the Myers algorithm.
+func (d *differ) histogram(line1, end1, line2, end2 int) {
+	for {
+		if line1 == end1 {
+			d.b.changeRange(line2, end2)
+			return
+		}
+
+		if line2 == end2 {
+			d.a.changeRange(line1, end1)
+			return
+		}
+
+		lcs, found, fallback := d.findLCS(line1, end1, line2, end2)
+		if fallback {
+			d.myers(line1, end1, line2, end2, false)
+			return
+		}
+
+		if !found {
+			d.a.changeRange(line1, end1)
+			d.b.changeRange(line2, end2)
+			return
+		}
+
+		d.histogram(line1, lcs.begin1, line2, lcs.begin2)
+		line1, line2 = lcs.end1+1, lcs.end2+1
+	}
+}
+
+// findLCS returns the longest common region of the ranges with the lines
+// with the smallest number of occurrences. fallback is true if there are
+// common lines, but all of them have too many occurrences.
+func (d *differ) findLCS(line1, end1, line2, end2 int) (lcs histogramRegion, found, fallback bool) {
+	idx := &histogramIndex{
+		positions: make(map[int][]int),
+		cnt:       maxHistogramChain + 1,
+	}
+
+	for i := line1; i < end1; i++ {
+		l := d.a.lines[i]
+		idx.positions[l] = append(idx.positions[l], i)
+	}
+
+	for b := line2; b < end2; {
+		b = d.tryLCS(idx, &lcs, &found, b, line1, end1, line2, end2)
+	}
+
+	return lcs, found, idx.hasCommon && idx.cnt > maxHistogramChain
+}
+
+// tryLCS tries the common regions containing the line bPos of b, and
+// returns the next line of b to try.
+func (d *differ) tryLCS(idx *histogramIndex, lcs *histogramRegion, found *bool, bPos, line1, end1, line2, end2 int) int {
+	a, b := d.a.lines, d.b.lines
+	bNext := bPos + 1
+
+	positions := idx.positions[b[bPos]]
+	if len(positions) == 0 {
+		return bNext
+	}
+
+	if len(positions) > idx.cnt {
+		idx.hasCommon = true
+		return bNext
+	}
+
+	idx.hasCommon = true
+	for i := 0; i < len(positions); {
+		as, ae := positions[i], positions[i]
+		bs, be := bPos, bPos
+		rc := len(positions)
+
+		for line1 < as && line2 < bs && a[as-1] == b[bs-1] {
+			as--
+			bs--
+			if rc > 1 {
+				rc = min(rc, len(idx.positions[a[as]]))
+			}
+		}
+
+		for ae < end1-1 && be < end2-1 && a[ae+1] == b[be+1] {
+			ae++
+			be++
+			if rc > 1 {
+				rc = min(rc, len(idx.positions[a[ae]]))
+			}
+		}
+
+		if bNext <= be {
+			bNext = be + 1
+		}
+
+		if lcs.end1-lcs.begin1 < ae-as || rc < idx.cnt {
+			*lcs = histogramRegion{begin1: as, end1: ae, begin2: bs, end2: be}
+			*found = true
+			idx.cnt = rc
+		}
+
+		// skip the occurrences inside the matched region
+		for i < len(positions) && positions[i] <= ae {
+			i++
+		}
+	}
+
+	return bNext
+}
@@ -0,0 +1,444 @@
+package diff
+
+import "math"
+
+// The constants of the heuristics of the Myers algorithm, as the ones of
+// git, used unless the minimal diff is required.
+const (
+	// maxEqLimit is the maximum number of occurrences of a line in the
+	// other side to consider it as matching many lines.
+	maxEqLimit = 1024
+	// simScanWindow is the number of lines scanned around a line matching
+	// many lines to decide if it is discarded.
+	simScanWindow = 100
+	// kpDisRun is the ratio of lines matching many lines to the scanned
+	// lines to discard a line.
+	kpDisRun = 4
+	// maxCostMin is the minimum cost from which the furthest reaching
+	// path is taken, instead of looking for an optimal one.
+	maxCostMin = 256
+	// heurMinCost is the minimum cost from which a good snake is taken.
+	heurMinCost = 256
+	// snakeCnt is the minimum length of a good snake.
+	snakeCnt = 20
+	// kHeur is the factor of the cost to consider a snake interesting.
+	kHeur = 4
+)
+
+// myersEnv holds the lines of the ranges being diffed with the Myers
+// algorithm, after discarding the lines which cannot match, with the
+// position of each one in the files.
+type myersEnv struct {
+	d        *differ
+	ha1, ha2 []int
+	rindex1  []int
+	rindex2  []int
+	// kvdf and kvdb hold the furthest reaching paths of each diagonal,
+	// forward and backward, the diagonal 0 being at off.
+	kvdf, kvdb []int
+	off        int
+	mxcost     int
+}
+
+// split is a point of the diff path, minLo and minHi are true if the
+// sub-ranges before and after it must be diffed to the minimal diff.
+type split struct {
+	i1, i2       int
+	minLo, minHi bool
+}
+
+// myers marks the changed lines of the ranges [off1, lim1) of a and
+// [off2, lim2) of b, as the classic diff of git does. The equal lines at
+// both ends are trimmed, and the lines which do not appear in the other
+// range, or which appear too many times among such lines, are discarded
+// before running the linear space variant of the Myers algorithm.
+func (d *differ) myers(off1, lim1, off2, lim2 int, minimal bool) {
+	a, b := d.a.lines, d.b.lines
+
+	counts1 := make(map[int]int)
+	for _, l := range a[off1:lim1] {
+		counts1[l]++
+	}
+
+	counts2 := make(map[int]int)
+	for _, l := range b[off2:lim2] {
+		counts2[l]++
+	}
+
+	start1, start2 := off1, off2
+	for start1 < lim1 && start2 < lim2 && a[start1] == b[start2] {
+		start1++
+		start2++
+	}
+
+	end1, end2 := lim1, lim2
+	for end1 > start1 && end2 > start2 && a[end1-1] == b[end2-1] {
+		end1--
+		end2--
+	}
+
+	env := &myersEnv{d: d}
+	env.ha1, env.rindex1 = cleanupRecords(d.a, a, start1, end1, lim1-off1, counts2, minimal)
+	env.ha2, env.rindex2 = cleanupRecords(d.b, b, start2, end2, lim2-off2, counts1, minimal)
+
+	ndiags := len(env.ha1) + len(env.ha2) + 3
+	env.kvdf = make([]int, ndiags)
+	env.kvdb = make([]int, ndiags)
+	env.off = len(env.ha2) + 1
+	env.mxcost = bogoSqrt(ndiags)
+	if env.mxcost < maxCostMin {
+		env.mxcost = maxCostMin
+	}
+
+	env.compare(0, len(env.ha1), 0, len(env.ha2), minimal)
+}
+
+// cleanupRecords returns the lines of [start, end) of f not discarded,
+// with their positions. The lines without matches in the other range are
+// discarded, and marked as changed, as the lines with many matches among
+// the lines without matches.
+func cleanupRecords(f *file, lines []int, start, end, nrec int, other map[int]int, minimal bool) (ha, rindex []int) {
+	mlim := bogoSqrt(nrec)
+	if mlim > maxEqLimit {
+		mlim = maxEqLimit
+	}
+
+	dis := make([]byte, end-start)
+	for i := range dis {
+		switch nm := other[lines[start+i]]; {
+		case nm == 0:
+			dis[i] = 0
+		case nm >= mlim && !minimal:
+			dis[i] = 2
+		default:
+			dis[i] = 1
+		}
+	}
+
+	for i := range dis {
+		if dis[i] == 1 || (dis[i] == 2 && !cleanMultiMatch(dis, i, 0, len(dis)-1)) {
+			ha = append(ha, lines[start+i])
+			rindex = append(rindex, start+i)
+			continue
+		}
+
+		f.setChanged(start+i, true)
+	}
+
+	return ha, rindex
+}
+
+// cleanMultiMatch returns true if the line i, matching many lines, is in
+// the middle of a run of lines mostly without matches.
+func cleanMultiMatch(dis []byte, i, s, e int) bool {
+	if i-s > simScanWindow {
+		s = i - simScanWindow
+	}
+
+	if e-i > simScanWindow {
+		e = i + simScanWindow
+	}
+
+	rdis0, rpdis0 := 0, 1
+	for r := 1; i-r >= s; r++ {
+		if dis[i-r] == 0 {
+			rdis0++
+		} else if dis[i-r] == 2 {
+			rpdis0++
+		} else {
+			break
+		}
+	}
+
+	if rdis0 == 0 {
+		return false
+	}
+
+	rdis1, rpdis1 := 0, 1
+	for r := 1; i+r <= e; r++ {
+		if dis[i+r] == 0 {
+			rdis1++
+		} else if dis[i+r] == 2 {
+			rpdis1++
+		} else {
+			break
+		}
+	}
+
+	if rdis1 == 0 {
+		return false
+	}
+
+	rdis1 += rdis0
+	rpdis1 += rpdis0
+
+	return rpdis1*kpDisRun < rpdis1+rdis1
+}
+
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+
+	return i
+}
+
+// compare marks the changed lines of the ranges [off1, lim1) and
+// [off2, lim2) of the not discarded lines, splitting them recursively.
+func (e *myersEnv) compare(off1, lim1, off2, lim2 int, minimal bool) {
+	ha1, ha2 := e.ha1, e.ha2
+	for off1 < lim1 && off2 < lim2 && ha1[off1] == ha2[off2] {
+		off1++
+		off2++
+	}
+
+	for off1 < lim1 && off2 < lim2 && ha1[lim1-1] == ha2[lim2-1] {
+		lim1--
+		lim2--
+	}
+
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			e.d.b.setChanged(e.rindex2[off2], true)
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			e.d.a.setChanged(e.rindex1[off1], true)
+		}
+	default:
+		spl := e.split(off1, lim1, off2, lim2, minimal)
+		e.compare(off1, spl.i1, off2, spl.i2, spl.minLo)
+		e.compare(spl.i1, lim1, spl.i2, lim2, spl.minHi)
+	}
+}
+
+// split returns the point where the ranges are split, the middle snake of
+// an optimal path if minimal is true or the cost is small enough, or else
+// a point found by the heuristics.
+func (e *myersEnv) split(off1, lim1, off2, lim2 int, minimal bool) split {
+	ha1, ha2 := e.ha1, e.ha2
+	kvdf := func(d int) *int { return &e.kvdf[e.off+d] }
+	kvdb := func(d int) *int { return &e.kvdb[e.off+d] }
+
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+
+	*kvdf(fmid) = off1
+	*kvdb(bmid) = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+
+		if fmin > dmin {
+			fmin--
+			*kvdf(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+
+		if fmax < dmax {
+			fmax++
+			*kvdf(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if *kvdf(d - 1) >= *kvdf(d + 1) {
+				i1 = *kvdf(d - 1) + 1
+			} else {
+				i1 = *kvdf(d + 1)
+			}
+
+			prev1 := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && ha1[i1] == ha2[i2] {
+				i1++
+				i2++
+			}
+
+			if i1-prev1 > snakeCnt {
+				gotSnake = true
+			}
+
+			*kvdf(d) = i1
+			if odd && bmin <= d && d <= bmax && *kvdb(d) <= i1 {
+				return split{i1: i1, i2: i2, minLo: true, minHi: true}
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			*kvdb(bmin - 1) = math.MaxInt
+		} else {
+			bmin++
+		}
+
+		if bmax < dmax {
+			bmax++
+			*kvdb(bmax + 1) = math.MaxInt
+		} else {
+			bmax--
+		}
+
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if *kvdb(d - 1) < *kvdb(d + 1) {
+				i1 = *kvdb(d - 1)
+			} else {
+				i1 = *kvdb(d + 1) - 1
+			}
+
+			prev1 := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && ha1[i1-1] == ha2[i2-1] {
+				i1--
+				i2--
+			}
+
+			if prev1-i1 > snakeCnt {
+				gotSnake = true
+			}
+
+			*kvdb(d) = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= *kvdf(d) {
+				return split{i1: i1, i2: i2, minLo: true, minHi: true}
+			}
+		}
+
+		if minimal {
+			continue
+		}
+
+		// If the cost is above the heuristic trigger, and a good snake was
+		// found, the furthest reaching paths are sampled to find one far
+		// from the start, and not too far from the middle diagonal, ending
+		// with a long snake.
+		if gotSnake && ec > heurMinCost {
+			if spl, ok := e.forwardSnake(ec, off1, lim1, off2, lim2, fmin, fmax, fmid); ok {
+				return spl
+			}
+
+			if spl, ok := e.backwardSnake(ec, off1, lim1, off2, lim2, bmin, bmax, bmid); ok {
+				return spl
+			}
+		}
+
+		// Enough is enough, the furthest reaching path is taken.
+		if ec >= e.mxcost {
+			return e.furthestReaching(off1, lim1, off2, lim2, fmin, fmax, bmin, bmax)
+		}
+	}
+}
+
+func (e *myersEnv) forwardSnake(ec, off1, lim1, off2, lim2, fmin, fmax, fmid int) (split, bool) {
+	var spl split
+	best := 0
+	for d := fmax; d >= fmin; d -= 2 {
+		dd := fmid - d
+		if d > fmid {
+			dd = d - fmid
+		}
+
+		i1 := e.kvdf[e.off+d]
+		i2 := i1 - d
+		v := (i1 - off1) + (i2 - off2) - dd
+
+		if v > kHeur*ec && v > best &&
+			off1+snakeCnt <= i1 && i1 < lim1 &&
+			off2+snakeCnt <= i2 && i2 < lim2 {
+			for k := 1; e.ha1[i1-k] == e.ha2[i2-k]; k++ {
+				if k == snakeCnt {
+					best = v
+					spl.i1, spl.i2 = i1, i2
+					break
+				}
+			}
+		}
+	}
+
+	spl.minLo = true
+	return spl, best > 0
+}
+
+func (e *myersEnv) backwardSnake(ec, off1, lim1, off2, lim2, bmin, bmax, bmid int) (split, bool) {
+	var spl split
+	best := 0
+	for d := bmax; d >= bmin; d -= 2 {
+		dd := bmid - d
+		if d > bmid {
+			dd = d - bmid
+		}
+
+		i1 := e.kvdb[e.off+d]
+		i2 := i1 - d
+		v := (lim1 - i1) + (lim2 - i2) - dd
+
+		if v > kHeur*ec && v > best &&
+			off1 < i1 && i1 <= lim1-snakeCnt &&
+			off2 < i2 && i2 <= lim2-snakeCnt {
+			for k := 0; e.ha1[i1+k] == e.ha2[i2+k]; k++ {
+				if k == snakeCnt-1 {
+					best = v
+					spl.i1, spl.i2 = i1, i2
+					break
+				}
+			}
+		}
+	}
+
+	spl.minHi = true
+	return spl, best > 0
+}
+
+func (e *myersEnv) furthestReaching(off1, lim1, off2, lim2, fmin, fmax, bmin, bmax int) split {
+	fbest, fbest1 := -1, -1
+	for d := fmax; d >= fmin; d -= 2 {
+		i1 := e.kvdf[e.off+d]
+		if i1 > lim1 {
+			i1 = lim1
+		}
+
+		i2 := i1 - d
+		if lim2 < i2 {
+			i1 = lim2 + d
+			i2 = lim2
+		}
+
+		if fbest < i1+i2 {
+			fbest = i1 + i2
+			fbest1 = i1
+		}
+	}
+
+	bbest, bbest1 := math.MaxInt, math.MaxInt
+	for d := bmax; d >= bmin; d -= 2 {
+		i1 := e.kvdb[e.off+d]
+		if i1 < off1 {
+			i1 = off1
+		}
+
+		i2 := i1 - d
+		if i2 < off2 {
+			i1 = off2 + d
+			i2 = off2
+		}
+
+		if i1+i2 < bbest {
+			bbest = i1 + i2
+			bbest1 = i1
+		}
+	}
+
+	if (lim1+lim2)-bbest < fbest-(off1+off2) {
+		return split{i1: fbest1, i2: fbest - fbest1, minLo: true}
+	}
+
+	return split{i1: bbest1, i2: bbest - bbest1, minHi: true}
+}
@@ -0,0 +1,156 @@
+package diff
+
+// patienceEntry is a line of the range of a being diffed, with the position
+// of its first occurrence in a and in b.
+type patienceEntry struct {
+	line1, line2 int
+	// unique is false if the line appears more than once in a or b.
+	unique   bool
+	previous *patienceEntry
+	next     *patienceEntry
+}
+
+// patience marks the changed lines of the ranges [line1, end1) of a and
+// [line2, end2) of b. The lines unique in both ranges are matched first,
+// taking the longest sequence of them in the same order in a and b, and
+// the lines between them are diffed recursively. If there are no unique
+// lines, the ranges are diffed with the Myers algorithm, as git does.
+func (d *differ) patience(line1, end1, line2, end2 int) {
+	if line1 == end1 {
+		d.b.changeRange(line2, end2)
+		return
+	}
+
+	if line2 == end2 {
+		d.a.changeRange(line1, end1)
+		return
+	}
+
+	entries := make(map[int]*patienceEntry)
+	var order []*patienceEntry
+	for i := line1; i < end1; i++ {
+		if e, ok := entries[d.a.lines[i]]; ok {
+			e.unique = false
+			continue
+		}
+
+		e := &patienceEntry{line1: i, line2: -1, unique: true}
+		entries[d.a.lines[i]] = e
+		order = append(order, e)
+	}
+
+	matches := false
+	for i := line2; i < end2; i++ {
+		e, ok := entries[d.b.lines[i]]
+		if !ok {
+			continue
+		}
+
+		matches = true
+		if e.line2 != -1 {
+			e.unique = false
+		}
+
+		e.line2 = i
+	}
+
+	if !matches {
+		d.a.changeRange(line1, end1)
+		d.b.changeRange(line2, end2)
+		return
+	}
+
+	first := longestCommonSequence(order)
+	if first == nil {
+		d.myers(line1, end1, line2, end2, false)
+		return
+	}
+
+	d.walkCommonSequence(first, line1, end1, line2, end2)
+}
+
+// longestCommonSequence returns the first entry of the longest sequence of
+// unique lines in the same order in a and b, using patience sorting.
+func longestCommonSequence(order []*patienceEntry) *patienceEntry {
+	var sequence []*patienceEntry
+	for _, e := range order {
+		if !e.unique || e.line2 == -1 {
+			continue
+		}
+
+		// i is the index of the last element of sequence whose line2 is
+		// smaller than the one of e.
+		left, right := -1, len(sequence)
+		for left+1 < right {
+			middle := left + (right-left)/2
+			if sequence[middle].line2 > e.line2 {
+				right = middle
+			} else {
+				left = middle
+			}
+		}
+
+		i := left
+		if i >= 0 {
+			e.previous = sequence[i]
+		}
+
+		if i+1 == len(sequence) {
+			sequence = append(sequence, e)
+		} else {
+			sequence[i+1] = e
+		}
+	}
+
+	if len(sequence) == 0 {
+		return nil
+	}
+
+	e := sequence[len(sequence)-1]
+	e.next = nil
+	for e.previous != nil {
+		e.previous.next = e
+		e = e.previous
+	}
+
+	return e
+}
+
+// walkCommonSequence diffs the lines between the matched unique lines,
+// extending the matches with the equal lines around them.
+func (d *differ) walkCommonSequence(first *patienceEntry, line1, end1, line2, end2 int) {
+	a, b := d.a.lines, d.b.lines
+	for {
+		next1, next2 := end1, end2
+		if first != nil {
+			next1, next2 = first.line1, first.line2
+			for next1 > line1 && next2 > line2 && a[next1-1] == b[next2-1] {
+				next1--
+				next2--
+			}
+		}
+
+		for line1 < next1 && line2 < next2 && a[line1] == b[line2] {
+			line1++
+			line2++
+		}
+
+		if next1 > line1 || next2 > line2 {
+			d.patience(line1, next1, line2, next2)
+		}
+
+		if first == nil {
+			return
+		}
+
+		for first.next != nil &&
+			first.next.line1 == first.line1+1 &&
+			first.next.line2 == first.line2+1 {
+			first = first.next
+		}
+
+		line1 = first.line1 + 1
+		line2 = first.line2 + 1
+		first = first.next
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"io"
 	"os"
 	"path"
+	"time"
 
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/utils/merkletrie/noder"
 
 	"github.com/go-git/go-billy/v5"
@@ -24,6 +26,10 @@ var ignore = map[string]bool{
 type node struct {
 	fs         billy.Filesystem
 	submodules map[string]plumbing.Hash
+	untracked  *untrackedCache
+	cached     *index.UntrackedCacheDirectory
+	stat       *statCache
+	workers    int
 
 	path     string
 	hash     []byte
@@ -31,6 +37,8 @@ type node struct {
 	isDir    bool
 	mode     os.FileMode
 	size     int64
+	modTime  time.Time
+	sys      interface{}
 }
 
 // NewRootNode returns the root node based on a given billy.Filesystem.
@@ -45,6 +53,60 @@ func NewRootNode(
 	return &node{fs: fs, submodules: submodules, isDir: true}
 }
 
+// Options contains configuration for the filesystem node.
+type Options struct {
+	// Index is the index of the worktree. The files with the same stat data
+	// as their entry, and not racily clean, are not read: their hash is the
+	// hash of the entry, as git does.
+	Index *index.Index
+	// IndexModTime is the time the Index was last written. The entries
+	// modified at or after it are racily clean, the files are read. If it is
+	// zero, all the files are read.
+	IndexModTime time.Time
+	// UntrackedCache enables the use of the untracked cache of the Index, if
+	// any, to avoid reading the directories not changed since it was built:
+	// their files are the tracked files and the cached untracked files.
+	UntrackedCache bool
+	// FileSystemMonitor enables the use of the Entry.FSMonitorValid flag of
+	// the Index entries: the files of the valid entries are trusted to be
+	// unchanged, they are not read.
+	FileSystemMonitor bool
+	// SystemInfo fills the system dependent stat data of an entry, from the
+	// os.FileInfo.Sys() of its file, to be compared with the Index entries.
+	SystemInfo func(e *index.Entry, sys interface{})
+	// OnRefresh, if not nil, enables the refresh of the Index: when a file is
+	// read and matches its entry, the stat data of the entry is updated and
+	// OnRefresh is called with it. The calls are not concurrent.
+	OnRefresh func(e *index.Entry)
+	// Workers is the number of goroutines reading the directories and
+	// hashing the tracked files in parallel, before they are compared. If it
+	// is lower than 2, they are read when needed.
+	Workers int
+}
+
+// NewRootNodeWithOptions returns the root node based on a given
+// billy.Filesystem and options, see NewRootNode.
+func NewRootNodeWithOptions(
+	fs billy.Filesystem,
+	submodules map[string]plumbing.Hash,
+	options Options,
+) noder.Noder {
+	n := &node{fs: fs, submodules: submodules, isDir: true, workers: options.Workers}
+	idx := options.Index
+	if idx == nil {
+		return n
+	}
+
+	n.stat = newStatCache(idx, options.IndexModTime, options.SystemInfo, options.OnRefresh)
+	n.stat.fsMonitor = options.FileSystemMonitor
+	if options.UntrackedCache && idx.UntrackedCache != nil && idx.UntrackedCache.Root != nil {
+		n.untracked = newUntrackedCache(idx)
+		n.cached = idx.UntrackedCache.Root
+	}
+
+	return n
+}
+
 // Hash the hash of a filesystem is the result of concatenating the computed
 // plumbing.Hash of the file as a Blob and its plumbing.FileMode; that way the
 // difftree algorithm will detect changes in the contents of files and also in
@@ -99,6 +161,23 @@ func (n *node) calculateChildren() error {
 		return nil
 	}
 
+	if n.workers > 1 {
+		workers := n.workers
+		n.workers = 0
+		return n.preload(workers)
+	}
+
+	if n.cached != nil {
+		files, ok, err := n.untracked.readDir(n)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return n.addChildren(files)
+		}
+	}
+
 	files, err := n.fs.ReadDir(n.path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -107,6 +186,10 @@ func (n *node) calculateChildren() error {
 		return err
 	}
 
+	return n.addChildren(files)
+}
+
+func (n *node) addChildren(files []os.FileInfo) error {
 	for _, file := range files {
 		if _, ok := ignore[file.Name()]; ok {
 			continue
@@ -133,17 +216,25 @@ func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 	node := &node{
 		fs:         n.fs,
 		submodules: n.submodules,
-
-		path:  path,
-		isDir: file.IsDir(),
-		size:  file.Size(),
-		mode:  file.Mode(),
+		untracked:  n.untracked,
+		stat:       n.stat,
+
+		path:    path,
+		isDir:   file.IsDir(),
+		size:    file.Size(),
+		mode:    file.Mode(),
+		modTime: file.ModTime(),
+		sys:     file.Sys(),
 	}
 
 	if _, isSubmodule := n.submodules[path]; isSubmodule {
 		node.isDir = false
 	}
 
+	if node.isDir && n.cached != nil {
+		node.cached = n.cached.Directory(file.Name())
+	}
+
 	return node, nil
 }
 
@@ -161,12 +252,25 @@ func (n *node) calculateHash() {
 		n.hash = append(submoduleHash[:], filemode.Submodule.Bytes()...)
 		return
 	}
+	e := n.stat.entry(n.path)
+	if n.stat.isValid(e) {
+		n.hash = append(e.Hash[:], e.Mode.Bytes()...)
+		return
+	}
+
+	if n.stat.isClean(e, n, mode) {
+		n.hash = append(e.Hash[:], mode.Bytes()...)
+		return
+	}
+
 	var hash plumbing.Hash
 	if n.mode&os.ModeSymlink != 0 {
 		hash = n.doCalculateHashForSymlink()
 	} else {
 		hash = n.doCalculateHashForRegular()
 	}
+
+	n.stat.refresh(e, n, hash, mode)
 	n.hash = append(hash[:], mode.Bytes()...)
 }
 
@@ -9,8 +9,11 @@ import (
 	"path"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/utils/merkletrie"
 	"github.com/go-git/go-git/v5/utils/merkletrie/noder"
 
@@ -221,6 +224,156 @@ func (s *NoderSuite) TestSocket(c *C) {
 	c.Assert(childs, HasLen, 1)
 }
 
+func (s *NoderSuite) TestUntrackedCache(c *C) {
+	fs := osfs.New(c.MkDir())
+	WriteFile(fs, "foo", []byte("foo"), 0644)
+	WriteFile(fs, "bar", []byte("bar"), 0644)
+	WriteFile(fs, "qux", []byte("qux"), 0644)
+
+	fi, err := fs.Lstat("")
+	c.Assert(err, IsNil)
+
+	idx := &index.Index{
+		Entries: []*index.Entry{{Name: "foo"}},
+		UntrackedCache: &index.UntrackedCache{
+			Root: &index.UntrackedCacheDirectory{
+				Valid:     true,
+				Untracked: []string{"bar"},
+				Stats:     index.UntrackedCacheStats{ModifiedAt: fi.ModTime()},
+			},
+		},
+	}
+
+	names := func() []string {
+		n := NewRootNodeWithOptions(fs, nil, Options{Index: idx, UntrackedCache: true})
+		children, err := n.Children()
+		c.Assert(err, IsNil)
+
+		var names []string
+		for _, child := range children {
+			names = append(names, child.Name())
+		}
+
+		return names
+	}
+
+	// qux is not listed, as the directory did not change
+	c.Assert(names(), DeepEquals, []string{"bar", "foo"})
+
+	idx.UntrackedCache.Invalidate("baz")
+	c.Assert(names(), DeepEquals, []string{"bar", "foo", "qux"})
+}
+
+func (s *NoderSuite) TestStatCache(c *C) {
+	fs := osfs.New(c.MkDir())
+	WriteFile(fs, "foo", []byte("foo"), 0644)
+
+	fi, err := fs.Lstat("foo")
+	c.Assert(err, IsNil)
+
+	hash := plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")
+	e := &index.Entry{
+		Name:       "foo",
+		Hash:       hash,
+		Mode:       filemode.Regular,
+		ModifiedAt: fi.ModTime(),
+		Size:       3,
+	}
+
+	idx := &index.Index{Entries: []*index.Entry{e}}
+	modTime := fi.ModTime().Add(time.Second)
+
+	fooHash := func() plumbing.Hash {
+		n := NewRootNodeWithOptions(fs, nil, Options{Index: idx, IndexModTime: modTime})
+		children, err := n.Children()
+		c.Assert(err, IsNil)
+		c.Assert(children, HasLen, 1)
+
+		var h plumbing.Hash
+		copy(h[:], children[0].Hash())
+		return h
+	}
+
+	// the file is not read, as its stat data did not change
+	c.Assert(fooHash(), Equals, hash)
+
+	// racily clean
+	modTime = fi.ModTime()
+	c.Assert(fooHash(), Equals, plumbing.ComputeHash(plumbing.BlobObject, []byte("foo")))
+
+	modTime = fi.ModTime().Add(time.Second)
+	e.Size = 4
+	c.Assert(fooHash(), Equals, plumbing.ComputeHash(plumbing.BlobObject, []byte("foo")))
+}
+
+func (s *NoderSuite) TestStatCacheRefresh(c *C) {
+	fs := osfs.New(c.MkDir())
+	WriteFile(fs, "foo", []byte("foo"), 0644)
+	WriteFile(fs, "bar", []byte("bar"), 0644)
+
+	e := &index.Entry{
+		Name: "foo",
+		Hash: plumbing.ComputeHash(plumbing.BlobObject, []byte("foo")),
+		Mode: filemode.Regular,
+	}
+
+	modified := &index.Entry{
+		Name: "bar",
+		Hash: plumbing.ComputeHash(plumbing.BlobObject, []byte("baz")),
+		Mode: filemode.Regular,
+	}
+
+	idx := &index.Index{Entries: []*index.Entry{e, modified}}
+
+	var refreshed []string
+	n := NewRootNodeWithOptions(fs, nil, Options{
+		Index:     idx,
+		OnRefresh: func(e *index.Entry) { refreshed = append(refreshed, e.Name) },
+	})
+
+	children, err := n.Children()
+	c.Assert(err, IsNil)
+	for _, child := range children {
+		child.Hash()
+	}
+
+	fi, err := fs.Lstat("foo")
+	c.Assert(err, IsNil)
+
+	c.Assert(refreshed, DeepEquals, []string{"foo"})
+	c.Assert(e.ModifiedAt.Equal(fi.ModTime()), Equals, true)
+	c.Assert(e.Size, Equals, uint32(3))
+	c.Assert(modified.ModifiedAt.IsZero(), Equals, true)
+}
+
+func (s *NoderSuite) TestPreload(c *C) {
+	fs := memfs.New()
+	idx := &index.Index{}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			name := fmt.Sprintf("%d/%d/foo", i, j)
+			WriteFile(fs, name, []byte(name), 0644)
+			idx.Add(name)
+		}
+	}
+
+	WriteFile(fs, "bar", []byte("bar"), 0644)
+
+	a := NewRootNodeWithOptions(fs, nil, Options{Index: idx})
+	b := NewRootNodeWithOptions(fs, nil, Options{Index: idx, Workers: 4})
+
+	// preloaded when the children of the root are first read
+	children, err := b.Children()
+	c.Assert(err, IsNil)
+	c.Assert(children, HasLen, 11)
+	c.Assert(children[0].(*node).children, HasLen, 10)
+	c.Assert(children[0].(*node).children[0].(*node).children[0].(*node).hash, NotNil)
+
+	ch, err := merkletrie.DiffTree(a, b, IsEquals)
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 0)
+}
+
 func WriteFile(fs billy.Filesystem, filename string, data []byte, perm os.FileMode) error {
 	f, err := fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
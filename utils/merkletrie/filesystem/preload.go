@@ -0,0 +1,86 @@
+package filesystem
+
+import (
+	"sync"
+)
+
+// preload reads the directories and hashes the tracked files of the tree of
+// the node, with the given number of goroutines.
+func (n *node) preload(workers int) error {
+	p := &preloader{queue: []*node{n}, pending: 1}
+	p.cond = sync.NewCond(&p.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work()
+		}()
+	}
+
+	wg.Wait()
+	return p.err
+}
+
+// preloader is a pool of goroutines processing the queued nodes, queuing the
+// children of the directories.
+type preloader struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	queue []*node
+	// pending is the number of nodes queued or being processed.
+	pending int
+	err     error
+}
+
+func (p *preloader) work() {
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 && p.pending > 0 {
+			p.cond.Wait()
+		}
+
+		if p.pending == 0 {
+			p.mu.Unlock()
+			return
+		}
+
+		n := p.queue[len(p.queue)-1]
+		p.queue = p.queue[:len(p.queue)-1]
+		p.mu.Unlock()
+
+		err := p.load(n)
+
+		p.mu.Lock()
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+
+		if p.err == nil {
+			for _, c := range n.children {
+				p.queue = append(p.queue, c.(*node))
+			}
+
+			p.pending += len(n.children)
+		}
+
+		p.pending--
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+func (p *preloader) load(n *node) error {
+	if n.isDir {
+		return n.calculateChildren()
+	}
+
+	// the untracked files are not compared, so they are not hashed
+	if n.stat.entry(n.path) != nil {
+		n.calculateHash()
+	}
+
+	return nil
+}
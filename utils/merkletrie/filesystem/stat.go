@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// statCache compares the stat data of the files with the stat data of their
+// index entries, to avoid reading the files not modified since they were
+// added to the index, as git does.
+type statCache struct {
+	entries    map[string]*index.Entry
+	modTime    time.Time
+	systemInfo func(e *index.Entry, sys interface{})
+	onRefresh  func(e *index.Entry)
+	// fsMonitor is set if the Entry.FSMonitorValid flags can be trusted.
+	fsMonitor bool
+
+	mu sync.Mutex
+}
+
+func newStatCache(
+	idx *index.Index,
+	modTime time.Time,
+	systemInfo func(e *index.Entry, sys interface{}),
+	onRefresh func(e *index.Entry),
+) *statCache {
+	entries := make(map[string]*index.Entry, len(idx.Entries))
+	for _, e := range idx.Entries {
+		// the unmerged entries have no stat data
+		if e.Stage == 0 {
+			entries[e.Name] = e
+		}
+	}
+
+	return &statCache{
+		entries:    entries,
+		modTime:    modTime,
+		systemInfo: systemInfo,
+		onRefresh:  onRefresh,
+	}
+}
+
+// entry returns the index entry of the given path, if any.
+func (c *statCache) entry(path string) *index.Entry {
+	if c == nil {
+		return nil
+	}
+
+	return c.entries[path]
+}
+
+// isValid returns true if the file monitor reported no changes of the file
+// of the entry.
+func (c *statCache) isValid(e *index.Entry) bool {
+	return e != nil && c.fsMonitor && e.FSMonitorValid && !e.IntentToAdd
+}
+
+// isClean returns true if the file of the node has the same stat data as its
+// entry, and the entry is not racily clean: modified at or after the index
+// was written, as the file could then have been modified again without
+// changing its stat data.
+func (c *statCache) isClean(e *index.Entry, n *node, mode filemode.FileMode) bool {
+	if e == nil || e.IntentToAdd || e.Mode != mode {
+		return false
+	}
+
+	if c.modTime.IsZero() || !e.ModifiedAt.Before(c.modTime) {
+		return false
+	}
+
+	if !e.ModifiedAt.Equal(n.modTime) || e.Size != uint32(n.size) {
+		return false
+	}
+
+	if c.systemInfo == nil {
+		return true
+	}
+
+	var s index.Entry
+	c.systemInfo(&s, n.sys)
+
+	return s.CreatedAt.Equal(e.CreatedAt) &&
+		s.Dev == e.Dev && s.Inode == e.Inode &&
+		s.UID == e.UID && s.GID == e.GID
+}
+
+// refresh updates the stat data of the entry with the stat data of the file
+// of the node, if their content and mode are the same, so the file is not
+// read again once the index is written.
+func (c *statCache) refresh(e *index.Entry, n *node, hash plumbing.Hash, mode filemode.FileMode) {
+	if e == nil || c.onRefresh == nil || e.Hash != hash || e.Mode != mode {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.ModifiedAt = n.modTime
+	e.Size = uint32(n.size)
+	if c.systemInfo != nil {
+		c.systemInfo(e, n.sys)
+	}
+
+	c.onRefresh(e)
+}
+
+// entryFileInfo is the os.FileInfo of a file from the stat data of its index
+// entry, used when the file is not examined.
+type entryFileInfo struct {
+	e *index.Entry
+}
+
+func (fi *entryFileInfo) Name() string {
+	return path.Base(fi.e.Name)
+}
+
+func (fi *entryFileInfo) Size() int64 {
+	return int64(fi.e.Size)
+}
+
+func (fi *entryFileInfo) Mode() os.FileMode {
+	mode, _ := fi.e.Mode.ToOSFileMode()
+	return mode
+}
+
+func (fi *entryFileInfo) ModTime() time.Time {
+	return fi.e.ModifiedAt
+}
+
+func (fi *entryFileInfo) IsDir() bool {
+	return false
+}
+
+func (fi *entryFileInfo) Sys() interface{} {
+	return nil
+}
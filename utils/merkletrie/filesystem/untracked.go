@@ -0,0 +1,151 @@
+package filesystem
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/utils/ioutil"
+)
+
+// untrackedCache lists the files of the directories using the untracked
+// cache of the index, as git does, instead of reading the directories.
+type untrackedCache struct {
+	excludePerDir string
+	// tracked are the names of the tracked files and directories in each
+	// directory.
+	tracked map[string][]string
+}
+
+func newUntrackedCache(idx *index.Index) *untrackedCache {
+	c := &untrackedCache{
+		excludePerDir: idx.UntrackedCache.ExcludePerDir,
+		tracked:       make(map[string][]string),
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range idx.Entries {
+		name := e.Name
+		for name != "" && !seen[name] {
+			seen[name] = true
+
+			dir, base := path.Split(name)
+			dir = strings.TrimSuffix(dir, "/")
+			c.tracked[dir] = append(c.tracked[dir], base)
+			name = dir
+		}
+	}
+
+	return c
+}
+
+// readDir returns the files of the directory of the node, the tracked ones
+// and the cached untracked ones, if the cached directory is still valid. If
+// the exclude file of the directory changed, the cached data of its
+// subdirectories are no longer used either.
+func (c *untrackedCache) readDir(n *node) ([]os.FileInfo, bool, error) {
+	dir := n.cached
+
+	valid, err := c.isExcludeValid(n)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !valid {
+		n.cached = nil
+		return nil, false, nil
+	}
+
+	if !dir.Valid || dir.CheckOnly {
+		return nil, false, nil
+	}
+
+	fi, err := n.fs.Lstat(n.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, true, nil
+		}
+
+		return nil, false, err
+	}
+
+	if !fi.ModTime().Equal(dir.Stats.ModifiedAt) {
+		return nil, false, nil
+	}
+
+	names := append([]string(nil), c.tracked[n.path]...)
+	for _, name := range dir.Untracked {
+		names = append(names, strings.TrimSuffix(name, "/"))
+	}
+
+	sort.Strings(names)
+
+	var files []os.FileInfo
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+
+		fullpath := path.Join(n.path, name)
+		if e := n.stat.entry(fullpath); n.stat.isValid(e) {
+			files = append(files, &entryFileInfo{e})
+			continue
+		}
+
+		fi, err := n.fs.Lstat(fullpath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, false, err
+		}
+
+		files = append(files, fi)
+	}
+
+	return files, true, nil
+}
+
+// isExcludeValid returns true if the exclude file of the directory of the
+// node did not change. git stores the hash of the blob of the file if it is
+// tracked and unmodified, or the hash of its content followed by a newline
+// otherwise, as it is read to be parsed.
+func (c *untrackedCache) isExcludeValid(n *node) (valid bool, err error) {
+	if c.excludePerDir == "" {
+		return true, nil
+	}
+
+	name := path.Join(n.path, c.excludePerDir)
+	fi, err := n.fs.Lstat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return n.cached.ExcludeHash.IsZero(), nil
+		}
+
+		return false, err
+	}
+
+	f, err := n.fs.Open(name)
+	if err != nil {
+		return false, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	blob := plumbing.NewHasher(plumbing.BlobObject, fi.Size())
+	read := plumbing.NewHasher(plumbing.BlobObject, fi.Size()+1)
+	if _, err := io.Copy(io.MultiWriter(blob, read), f); err != nil {
+		return false, err
+	}
+
+	if _, err := read.Write([]byte{'\n'}); err != nil {
+		return false, err
+	}
+
+	return n.cached.ExcludeHash == blob.Sum() || n.cached.ExcludeHash == read.Sum(), nil
+}
@@ -39,6 +39,9 @@ type Worktree struct {
 	Filesystem billy.Filesystem
 	// External excludes not found in the repository .gitignore
 	Excludes []gitignore.Pattern
+	// FileSystemMonitor, if not nil, reports the files changed since the
+	// last status, so the other tracked files are not examined.
+	FileSystemMonitor FileSystemMonitor
 
 	r *Repository
 }
@@ -873,6 +876,159 @@ func (w *Worktree) Submodules() (Submodules, error) {
 	return l, nil
 }
 
+// AddSubmodule adds the repository at the given url as a submodule at the
+// given path, it is cloned into the modules of the repository, recorded at
+// the .gitmodules file, initialized and staged as a gitlink entry of the
+// index. It is equivalent to `git submodule add <url> <path>`.
+func (w *Worktree) AddSubmodule(url, path string, o *SubmoduleAddOptions) (*Submodule, error) {
+	return w.AddSubmoduleContext(context.Background(), url, path, o)
+}
+
+// AddSubmoduleContext adds the repository at the given url as a submodule at
+// the given path, see AddSubmodule.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned. The context only affects the
+// transport operations.
+func (w *Worktree) AddSubmoduleContext(ctx context.Context, url, path string, o *SubmoduleAddOptions) (*Submodule, error) {
+	if o == nil {
+		o = &SubmoduleAddOptions{}
+	}
+
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	name := o.Name
+	if name == "" {
+		name = path
+	}
+
+	c := &config.Submodule{Name: name, Path: path, URL: url, Branch: o.Branch}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	m, err := w.readGitmodulesFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		m = config.NewModules()
+	}
+
+	if err := w.checkSubmoduleAdd(m, c); err != nil {
+		return nil, err
+	}
+
+	sub := &Submodule{initialized: true, c: c, w: w}
+	moduleEndpoint, err := sub.endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	storer, err := w.r.Storer.Module(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := w.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	co := &CloneOptions{
+		URL:      moduleEndpoint.String(),
+		Auth:     o.Auth,
+		Depth:    o.Depth,
+		Progress: o.Progress,
+	}
+
+	if o.Branch != "" {
+		co.ReferenceName = plumbing.NewBranchReferenceName(o.Branch)
+		co.SingleBranch = true
+	}
+
+	r, err := CloneContext(ctx, storer, fs, co)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	m.Submodules[name] = c
+	if err := w.writeGitmodulesFile(m); err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	e := idx.Add(path)
+	e.Hash = head.Hash()
+	e.Mode = filemode.Submodule
+	if err := w.r.Storer.SetIndex(idx); err != nil {
+		return nil, err
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Submodules[name] = &config.Submodule{Name: name, URL: url}
+	if err := w.r.Storer.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return w.newSubmodule(c, cfg.Submodules[name]), nil
+}
+
+// checkSubmoduleAdd returns an error if the submodule is already recorded or
+// its path is already used.
+func (w *Worktree) checkSubmoduleAdd(m *config.Modules, c *config.Submodule) error {
+	if _, ok := m.Submodules[c.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrSubmoduleAlreadyExists, c.Name)
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	if _, err := idx.Entry(c.Path); err == nil {
+		return fmt.Errorf("%w: %s", ErrSubmoduleAlreadyExists, c.Path)
+	}
+
+	files, err := w.Filesystem.ReadDir(c.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(files) != 0 {
+		return fmt.Errorf("%w: %s", ErrDestinationExists, c.Path)
+	}
+
+	return nil
+}
+
+func (w *Worktree) writeGitmodulesFile(m *config.Modules) error {
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := util.WriteFile(w.Filesystem, gitmodulesFile, b, 0644); err != nil {
+		return err
+	}
+
+	_, err = w.Add(gitmodulesFile)
+	return err
+}
+
 func (w *Worktree) newSubmodule(fromModules, fromConfig *config.Submodule) *Submodule {
 	m := &Submodule{w: w}
 	m.initialized = fromConfig != nil
@@ -1168,6 +1324,10 @@ func removeDirIfEmpty(fs billy.Filesystem, dir string) (bool, error) {
 
 type indexBuilder struct {
 	entries map[string]*index.Entry
+	// changed are the names added or removed, modified the names of the
+	// entries replaced with another hash or mode.
+	changed  []string
+	modified []string
 }
 
 func newIndexBuilder(idx *index.Index) *indexBuilder {
@@ -1185,12 +1345,32 @@ func (b *indexBuilder) Write(idx *index.Index) {
 	for _, e := range b.entries {
 		idx.Entries = append(idx.Entries, e)
 	}
+
+	for _, name := range b.changed {
+		idx.Cache.Invalidate(name)
+		idx.UntrackedCache.Invalidate(name)
+	}
+
+	for _, name := range b.modified {
+		idx.Cache.Invalidate(name)
+	}
 }
 
 func (b *indexBuilder) Add(e *index.Entry) {
+	if old, ok := b.entries[e.Name]; !ok {
+		b.changed = append(b.changed, e.Name)
+	} else if old.Hash != e.Hash || old.Mode != e.Mode {
+		b.modified = append(b.modified, e.Name)
+	}
+
 	b.entries[e.Name] = e
 }
 
 func (b *indexBuilder) Remove(name string) {
-	delete(b.entries, filepath.ToSlash(name))
+	name = filepath.ToSlash(name)
+	if _, ok := b.entries[name]; ok {
+		b.changed = append(b.changed, name)
+	}
+
+	delete(b.entries, name)
 }
@@ -0,0 +1,991 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/go-git/go-git/v5/utils/ioutil"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var (
+	ErrPatchDoesNotApply  = errors.New("patch does not apply")
+	ErrPatchFileExists    = errors.New("patched file already exists")
+	ErrPatchFileNotFound  = errors.New("patched file does not exist")
+	ErrPatchIndexMismatch = errors.New("patched file does not match index")
+	ErrPatchBinary        = errors.New("binary patch without data")
+	ErrPatchWhitespace    = errors.New("patch adds trailing whitespace")
+	ErrPatchConflict      = errors.New("patch applied with conflicts")
+)
+
+// Apply applies the patch to the working tree, to the index or to both, as
+// `git apply` does. The patch can be decoded with diff.UnifiedDecoder or be
+// a patch between trees, such as the ones returned by object.Commit.Patch.
+//
+// The patch is applied entirely or not at all, if a file patch does not
+// apply an error is returned and nothing is changed. If the patch is
+// applied with ApplyOptions.ThreeWay and a file is merged with conflicts,
+// the files are written and ErrPatchConflict is returned.
+func (w *Worktree) Apply(patch fdiff.Patch, o *ApplyOptions) error {
+	if o == nil {
+		o = &ApplyOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	a := &patchApplier{
+		w:     w,
+		o:     o,
+		idx:   idx,
+		files: make(map[string]*patchedFile),
+	}
+
+	for _, fp := range patch.FilePatches() {
+		up, err := a.unifiedFilePatch(fp)
+		if err != nil {
+			return err
+		}
+
+		if o.Reverse {
+			up = up.Reverse()
+		}
+
+		if err := a.apply(up); err != nil {
+			return err
+		}
+	}
+
+	if !o.Check {
+		if err := a.write(); err != nil {
+			return err
+		}
+	}
+
+	if a.conflicts {
+		return ErrPatchConflict
+	}
+
+	return nil
+}
+
+// patchedFile is the result of the file patches applied to a file.
+type patchedFile struct {
+	name    string
+	content []byte
+	mode    filemode.FileMode
+	deleted bool
+	// stages are the base, ours and theirs contents of a file merged with
+	// conflicts.
+	stages [][]byte
+}
+
+type patchApplier struct {
+	w   *Worktree
+	o   *ApplyOptions
+	idx *index.Index
+
+	// files are the patched files, they are written once all the file
+	// patches are applied.
+	files     map[string]*patchedFile
+	order     []string
+	conflicts bool
+}
+
+// unifiedFilePatch returns the file patch as a *fdiff.UnifiedFilePatch, the
+// patches between trees are converted to a single hunk with the content of
+// the files.
+func (a *patchApplier) unifiedFilePatch(fp fdiff.FilePatch) (*fdiff.UnifiedFilePatch, error) {
+	if up, ok := fp.(*fdiff.UnifiedFilePatch); ok {
+		return up, nil
+	}
+
+	up := &fdiff.UnifiedFilePatch{Binary: fp.IsBinary()}
+
+	from, to := fp.Files()
+	if from != nil {
+		up.From = &fdiff.UnifiedFile{Name: from.Path(), FileMode: from.Mode(), Index: from.Hash().String()}
+	}
+
+	if to != nil {
+		up.To = &fdiff.UnifiedFile{Name: to.Path(), FileMode: to.Mode(), Index: to.Hash().String()}
+		if cp, ok := fp.(fdiff.CopyFilePatch); ok && cp.Copied() {
+			up.IsCopy = true
+		} else {
+			up.IsRename = from != nil && from.Path() != to.Path()
+		}
+	}
+
+	if up.Binary {
+		if to == nil {
+			return up, nil
+		}
+
+		content, err := a.blob(to.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrPatchBinary, to.Path())
+		}
+
+		up.BinaryForward = &fdiff.BinaryHunk{Type: fdiff.BinaryLiteral, Data: content}
+		if from != nil {
+			content, err := a.blob(from.Hash())
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrPatchBinary, from.Path())
+			}
+
+			up.BinaryReverse = &fdiff.BinaryHunk{Type: fdiff.BinaryLiteral, Data: content}
+		}
+
+		return up, nil
+	}
+
+	h := &fdiff.Hunk{}
+	for _, c := range fp.Chunks() {
+		for _, l := range splitPatchLines(c.Content()) {
+			h.Lines = append(h.Lines, fdiff.HunkLine{Type: c.Type(), Content: l})
+			if c.Type() != fdiff.Add {
+				h.OldLines++
+			}
+
+			if c.Type() != fdiff.Delete {
+				h.NewLines++
+			}
+		}
+	}
+
+	if h.OldLines > 0 {
+		h.OldStart = 1
+	}
+
+	if h.NewLines > 0 {
+		h.NewStart = 1
+	}
+
+	if len(h.Lines) > 0 {
+		up.Hunks = []*fdiff.Hunk{h}
+	}
+
+	return up, nil
+}
+
+func (a *patchApplier) apply(fp *fdiff.UnifiedFilePatch) error {
+	var pre *patchedFile
+	if fp.From != nil {
+		var err error
+		if pre, err = a.read(fp.From.Name); err != nil {
+			return err
+		}
+	}
+
+	if fp.To != nil && (fp.From == nil || fp.To.Name != fp.From.Name) {
+		exists, err := a.exists(fp.To.Name)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return fmt.Errorf("%w: %s", ErrPatchFileExists, fp.To.Name)
+		}
+	}
+
+	name := fp.To
+	if name == nil {
+		name = fp.From
+	}
+
+	result, err := a.patch(fp, pre)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, name.Name)
+	}
+
+	if fp.From != nil && !fp.IsCopy && (fp.To == nil || fp.To.Name != fp.From.Name) {
+		a.set(&patchedFile{name: fp.From.Name, deleted: true})
+	}
+
+	if fp.To == nil {
+		return nil
+	}
+
+	result.name = fp.To.Name
+	result.mode = fp.To.FileMode
+	if result.mode == filemode.Empty && pre != nil {
+		result.mode = pre.mode
+	}
+
+	if result.mode == filemode.Empty {
+		result.mode = filemode.Regular
+	}
+
+	a.set(result)
+	return nil
+}
+
+func (a *patchApplier) set(f *patchedFile) {
+	if _, ok := a.files[f.name]; !ok {
+		a.order = append(a.order, f.name)
+	}
+
+	a.files[f.name] = f
+	if f.stages != nil {
+		a.conflicts = true
+	}
+}
+
+// read returns the content of the file to be patched, from the index if
+// the patch is applied only to the index, or from the working tree.
+func (a *patchApplier) read(name string) (*patchedFile, error) {
+	if f, ok := a.files[name]; ok {
+		if f.deleted {
+			return nil, fmt.Errorf("%w: %s", ErrPatchFileNotFound, name)
+		}
+
+		return f, nil
+	}
+
+	e, err := a.idx.Entry(name)
+	if err != nil && err != index.ErrEntryNotFound {
+		return nil, err
+	}
+
+	if (a.o.Cached || a.o.Index) && e == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPatchFileNotFound, name)
+	}
+
+	if a.o.Cached {
+		content, err := a.blob(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return &patchedFile{name: name, content: content, mode: e.Mode}, nil
+	}
+
+	f, err := a.readWorktree(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.o.Index && (e.Mode != f.mode || e.Hash != plumbing.ComputeHash(plumbing.BlobObject, f.content)) {
+		return nil, fmt.Errorf("%w: %s", ErrPatchIndexMismatch, name)
+	}
+
+	return f, nil
+}
+
+func (a *patchApplier) readWorktree(name string) (*patchedFile, error) {
+	fs := a.w.Filesystem
+	fi, err := fs.Lstat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrPatchFileNotFound, name)
+		}
+
+		return nil, err
+	}
+
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil {
+		return nil, err
+	}
+
+	f := &patchedFile{name: name, mode: mode}
+	if mode == filemode.Symlink {
+		target, err := fs.Readlink(name)
+		f.content = []byte(target)
+		return f, err
+	}
+
+	f.content, err = readFile(fs, name)
+	return f, err
+}
+
+func readFile(fs billy.Filesystem, name string) (content []byte, err error) {
+	r, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return io.ReadAll(r)
+}
+
+// exists returns true if the file exists in the index or in the working
+// tree, when they are patched.
+func (a *patchApplier) exists(name string) (bool, error) {
+	if f, ok := a.files[name]; ok {
+		return !f.deleted, nil
+	}
+
+	if a.o.Cached || a.o.Index {
+		if _, err := a.idx.Entry(name); err == nil {
+			return true, nil
+		} else if err != index.ErrEntryNotFound {
+			return false, err
+		}
+	}
+
+	if a.o.Cached {
+		return false, nil
+	}
+
+	_, err := a.w.Filesystem.Lstat(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (a *patchApplier) blob(h plumbing.Hash) (content []byte, err error) {
+	obj, err := a.w.r.Storer.EncodedObject(plumbing.BlobObject, h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return io.ReadAll(r)
+}
+
+// patch applies the changes of the file patch to the preimage, which is nil
+// if the patch creates the file.
+func (a *patchApplier) patch(fp *fdiff.UnifiedFilePatch, pre *patchedFile) (*patchedFile, error) {
+	var content []byte
+	if pre != nil {
+		content = pre.content
+	}
+
+	if fp.Binary {
+		post, err := a.patchBinary(fp, content)
+		return &patchedFile{content: post}, err
+	}
+
+	hunks, err := a.checkWhitespace(fp.Hunks)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := applyHunks(content, hunks, a.o)
+	if err != nil && a.o.ThreeWay {
+		return a.threeWay(fp, hunks, content, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if fp.To == nil && len(post) != 0 {
+		return nil, ErrPatchDoesNotApply
+	}
+
+	return &patchedFile{content: post}, nil
+}
+
+func (a *patchApplier) patchBinary(fp *fdiff.UnifiedFilePatch, pre []byte) ([]byte, error) {
+	if fp.From != nil && !matchesIndex(fp.From.Index, pre) {
+		return nil, ErrPatchDoesNotApply
+	}
+
+	if fp.To == nil {
+		return nil, nil
+	}
+
+	h := fp.BinaryForward
+	if h == nil {
+		return nil, ErrPatchBinary
+	}
+
+	post := h.Data
+	if h.Type == fdiff.BinaryDelta {
+		var err error
+		if post, err = packfile.PatchDelta(pre, h.Data); err != nil {
+			return nil, ErrPatchDoesNotApply
+		}
+	}
+
+	if !matchesIndex(fp.To.Index, post) {
+		return nil, ErrPatchDoesNotApply
+	}
+
+	return post, nil
+}
+
+// matchesIndex returns true if the hash of the content starts with the
+// abbreviated hash of an index line.
+func matchesIndex(abbrev string, content []byte) bool {
+	return abbrev == "" || strings.HasPrefix(plumbing.ComputeHash(plumbing.BlobObject, content).String(), abbrev)
+}
+
+// checkWhitespace handles the trailing whitespace of the added lines as
+// configured by ApplyOptions.Whitespace.
+func (a *patchApplier) checkWhitespace(hunks []*fdiff.Hunk) ([]*fdiff.Hunk, error) {
+	if a.o.Whitespace == NoWarnWhitespace {
+		return hunks, nil
+	}
+
+	fixed := make([]*fdiff.Hunk, len(hunks))
+	for i, h := range hunks {
+		fh := *h
+		fh.Lines = make([]fdiff.HunkLine, len(h.Lines))
+		for j, l := range h.Lines {
+			fh.Lines[j] = l
+			if l.Type != fdiff.Add {
+				continue
+			}
+
+			text := strings.TrimSuffix(l.Content, "\n")
+			trimmed := strings.TrimRight(text, " \t\r")
+			if trimmed == text {
+				continue
+			}
+
+			if a.o.Whitespace == ErrorWhitespace {
+				return nil, ErrPatchWhitespace
+			}
+
+			fh.Lines[j].Content = trimmed + l.Content[len(text):]
+		}
+
+		fixed[i] = &fh
+	}
+
+	return fixed, nil
+}
+
+// threeWay merges the changes of the file patch into the preimage, applying
+// the patch to the source blob recorded at the index line.
+func (a *patchApplier) threeWay(fp *fdiff.UnifiedFilePatch, hunks []*fdiff.Hunk, ours []byte, applyErr error) (*patchedFile, error) {
+	if fp.From == nil || fp.To == nil {
+		return nil, applyErr
+	}
+
+	candidates := a.w.r.resolveHashPrefix(fp.From.Index)
+	if len(candidates) != 1 {
+		return nil, applyErr
+	}
+
+	base, err := a.blob(candidates[0])
+	if err != nil {
+		return nil, applyErr
+	}
+
+	theirs, err := applyHunks(base, hunks, &ApplyOptions{})
+	if err != nil {
+		return nil, applyErr
+	}
+
+	merged, conflict := merge3(string(base), string(ours), string(theirs))
+	f := &patchedFile{content: []byte(merged)}
+	if conflict {
+		f.stages = [][]byte{base, ours, theirs}
+	}
+
+	return f, nil
+}
+
+// write writes the patched files to the working tree and to the index.
+func (a *patchApplier) write() error {
+	toIndex := a.o.Index || a.o.Cached
+	b := newIndexBuilder(a.idx)
+	for _, name := range a.order {
+		f := a.files[name]
+		if !a.o.Cached {
+			if err := a.writeWorktree(f); err != nil {
+				return err
+			}
+		}
+
+		if !toIndex || f.stages != nil {
+			continue
+		}
+
+		if f.deleted {
+			b.Remove(name)
+			continue
+		}
+
+		h, err := a.storeBlob(f.content)
+		if err != nil {
+			return err
+		}
+
+		if a.o.Cached {
+			b.Remove(name)
+			b.Add(&index.Entry{Name: name, Hash: h, Mode: f.mode})
+		} else if err := a.w.addIndexFromFile(name, h, f.mode, b); err != nil {
+			return err
+		}
+	}
+
+	if !toIndex {
+		return nil
+	}
+
+	b.Write(a.idx)
+	if err := a.writeConflicts(); err != nil {
+		return err
+	}
+
+	return a.w.r.Storer.SetIndex(a.idx)
+}
+
+// writeConflicts records the base, ours and theirs versions of the files
+// merged with conflicts as the stages of their index entries.
+func (a *patchApplier) writeConflicts() error {
+	for _, name := range a.order {
+		f := a.files[name]
+		if f.stages == nil {
+			continue
+		}
+
+		entries := a.idx.Entries[:0]
+		for _, e := range a.idx.Entries {
+			if e.Name != name {
+				entries = append(entries, e)
+			}
+		}
+
+		a.idx.Entries = entries
+		for i, content := range f.stages {
+			h, err := a.storeBlob(content)
+			if err != nil {
+				return err
+			}
+
+			e := a.idx.Add(name)
+			e.Hash = h
+			e.Mode = f.mode
+			e.Stage = index.AncestorMode + index.Stage(i)
+		}
+	}
+
+	return nil
+}
+
+func (a *patchApplier) writeWorktree(f *patchedFile) (err error) {
+	fs := a.w.Filesystem
+	if f.deleted {
+		return rmFileAndDirsIfEmpty(fs, f.name)
+	}
+
+	// the file is created again if its mode changes, as the permissions of
+	// an existing file are kept when it is opened
+	if fi, err := fs.Lstat(f.name); err == nil {
+		mode, err := filemode.NewFromOSFileMode(fi.Mode())
+		if err != nil || mode != f.mode || mode == filemode.Symlink {
+			if err := fs.Remove(f.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if f.mode == filemode.Symlink {
+		return fs.Symlink(string(f.content), f.name)
+	}
+
+	mode, err := f.mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	w, err := fs.OpenFile(f.name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(w, &err)
+	_, err = w.Write(f.content)
+	return err
+}
+
+func (a *patchApplier) storeBlob(content []byte) (plumbing.Hash, error) {
+	obj := a.w.r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return a.w.r.Storer.SetEncodedObject(obj)
+}
+
+// applyHunks applies the hunks to the content. As git does, a hunk is
+// searched first at the position given by its header, shifted by the
+// offset of the previous hunks, and then further away from it. If the hunk
+// is not found, up to ApplyOptions.Fuzz leading and trailing context lines
+// are ignored.
+func applyHunks(content []byte, hunks []*fdiff.Hunk, o *ApplyOptions) ([]byte, error) {
+	lines := splitPatchLines(string(content))
+
+	var out bytes.Buffer
+	var last, offset int
+	for _, h := range hunks {
+		pos, image, ok := matchHunk(lines, h, last, offset, o)
+		if !ok {
+			return nil, fmt.Errorf("%w: hunk at line %d", ErrPatchDoesNotApply, h.OldStart)
+		}
+
+		for _, l := range lines[last:pos] {
+			out.WriteString(l)
+		}
+
+		for _, l := range image.postimage {
+			if l.old >= 0 {
+				out.WriteString(lines[pos+l.old])
+			} else {
+				out.WriteString(l.content)
+			}
+		}
+
+		last = pos + len(image.preimage)
+		offset = pos - image.start
+	}
+
+	for _, l := range lines[last:] {
+		out.WriteString(l)
+	}
+
+	return out.Bytes(), nil
+}
+
+// hunkImage are the lines of a hunk, before and after the change.
+type hunkImage struct {
+	// start is the position of the preimage given by the hunk header.
+	start     int
+	preimage  []fdiff.HunkLine
+	postimage []hunkImageLine
+	// leading and trailing are the number of context lines around the
+	// changes.
+	leading, trailing int
+}
+
+type hunkImageLine struct {
+	content string
+	// old is the position of a context line in the preimage, or -1 for the
+	// added lines.
+	old int
+}
+
+func newHunkImage(h *fdiff.Hunk) *hunkImage {
+	img := &hunkImage{start: h.OldStart - 1}
+	if img.start < 0 {
+		img.start = 0
+	}
+
+	changed := false
+	for _, l := range h.Lines {
+		switch l.Type {
+		case fdiff.Equal:
+			img.postimage = append(img.postimage, hunkImageLine{l.Content, len(img.preimage)})
+			img.preimage = append(img.preimage, l)
+			if changed {
+				img.trailing++
+			} else {
+				img.leading++
+			}
+		case fdiff.Delete:
+			img.preimage = append(img.preimage, l)
+			changed, img.trailing = true, 0
+		case fdiff.Add:
+			img.postimage = append(img.postimage, hunkImageLine{l.Content, -1})
+			changed, img.trailing = true, 0
+		}
+	}
+
+	return img
+}
+
+// reduce returns the image without the given number of leading and trailing
+// context lines.
+func (img *hunkImage) reduce(leading, trailing int) *hunkImage {
+	r := &hunkImage{
+		start:     img.start + leading,
+		preimage:  img.preimage[leading : len(img.preimage)-trailing],
+		leading:   img.leading - leading,
+		trailing:  img.trailing - trailing,
+		postimage: make([]hunkImageLine, 0, len(img.postimage)),
+	}
+
+	for _, l := range img.postimage[leading : len(img.postimage)-trailing] {
+		if l.old >= 0 {
+			l.old -= leading
+		}
+
+		r.postimage = append(r.postimage, l)
+	}
+
+	return r
+}
+
+func matchHunk(lines []string, h *fdiff.Hunk, last, offset int, o *ApplyOptions) (int, *hunkImage, bool) {
+	img := newHunkImage(h)
+	for fuzz := 0; fuzz <= o.Fuzz; fuzz++ {
+		leading, trailing := fuzz, fuzz
+		if leading > img.leading {
+			leading = img.leading
+		}
+
+		if trailing > img.trailing {
+			trailing = img.trailing
+		}
+
+		// no more context lines can be ignored
+		if fuzz > 1 && leading < fuzz && trailing < fuzz {
+			break
+		}
+
+		r := img.reduce(leading, trailing)
+
+		// the hunks without leading or trailing context must be at the
+		// beginning or the end of the file, unless the context was reduced
+		matchBeginning := fuzz == 0 && h.OldStart <= 1
+		matchEnd := fuzz == 0 && img.trailing == 0
+		if pos, ok := findHunk(lines, r, last, r.start+offset, matchBeginning, matchEnd, o); ok {
+			return pos, r, true
+		}
+	}
+
+	return 0, nil, false
+}
+
+func findHunk(lines []string, img *hunkImage, last, expected int, matchBeginning, matchEnd bool, o *ApplyOptions) (int, bool) {
+	maxPos := len(lines) - len(img.preimage)
+	if maxPos < last {
+		return 0, false
+	}
+
+	if matchBeginning || matchEnd {
+		pos := last
+		if matchEnd {
+			pos = maxPos
+		}
+
+		ok := (!matchBeginning || pos == 0) && matchLines(lines[pos:], img.preimage, o)
+		return pos, ok
+	}
+
+	if expected < last {
+		expected = last
+	}
+
+	if expected > maxPos {
+		expected = maxPos
+	}
+
+	for distance := 0; expected-distance >= last || expected+distance <= maxPos; distance++ {
+		if pos := expected - distance; pos >= last && matchLines(lines[pos:], img.preimage, o) {
+			return pos, true
+		}
+
+		if pos := expected + distance; distance > 0 && pos <= maxPos && matchLines(lines[pos:], img.preimage, o) {
+			return pos, true
+		}
+	}
+
+	return 0, false
+}
+
+func matchLines(lines []string, preimage []fdiff.HunkLine, o *ApplyOptions) bool {
+	for i, l := range preimage {
+		if lines[i] == l.Content {
+			continue
+		}
+
+		if l.Type != fdiff.Equal || !o.IgnoreWhitespace ||
+			strings.Join(strings.Fields(lines[i]), " ") != strings.Join(strings.Fields(l.Content), " ") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitPatchLines splits the content in lines, keeping the newlines.
+func splitPatchLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// lineChange is a change of a range of lines of a base file.
+type lineChange struct {
+	start, end int
+	lines      []string
+}
+
+// lineChanges returns the changes of the lines of base in other.
+func lineChanges(base, other string) []lineChange {
+	var changes []lineChange
+	var current *lineChange
+	var pos int
+	for _, d := range diff.Do(base, other) {
+		lines := splitPatchLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			if current != nil {
+				changes = append(changes, *current)
+				current = nil
+			}
+
+			pos += len(lines)
+			continue
+		}
+
+		if current == nil {
+			current = &lineChange{start: pos, end: pos}
+		}
+
+		if d.Type == diffmatchpatch.DiffDelete {
+			pos += len(lines)
+			current.end = pos
+		} else {
+			current.lines = append(current.lines, lines...)
+		}
+	}
+
+	if current != nil {
+		changes = append(changes, *current)
+	}
+
+	return changes
+}
+
+// merge3 merges the changes from base to ours and from base to theirs, line
+// by line. The changes of both sides overlapping or adjacent are a conflict
+// if they differ, they are written between conflict markers.
+func merge3(base, ours, theirs string) (string, bool) {
+	baseLines := splitPatchLines(base)
+	oursChanges := lineChanges(base, ours)
+	theirsChanges := lineChanges(base, theirs)
+
+	var out strings.Builder
+	var conflict bool
+	var pos, i, j int
+	for i < len(oursChanges) || j < len(theirsChanges) {
+		start := len(baseLines)
+		if i < len(oursChanges) {
+			start = oursChanges[i].start
+		}
+
+		if j < len(theirsChanges) && theirsChanges[j].start < start {
+			start = theirsChanges[j].start
+		}
+
+		end := start
+		var oursGroup, theirsGroup []lineChange
+		for {
+			if i < len(oursChanges) && oursChanges[i].start <= end {
+				oursGroup = append(oursGroup, oursChanges[i])
+				end = max(end, oursChanges[i].end)
+				i++
+				continue
+			}
+
+			if j < len(theirsChanges) && theirsChanges[j].start <= end {
+				theirsGroup = append(theirsGroup, theirsChanges[j])
+				end = max(end, theirsChanges[j].end)
+				j++
+				continue
+			}
+
+			break
+		}
+
+		writeLines(&out, baseLines[pos:start])
+		oursLines := applyLineChanges(baseLines, start, end, oursGroup)
+		theirsLines := applyLineChanges(baseLines, start, end, theirsGroup)
+
+		switch {
+		case len(theirsGroup) == 0 || equalLines(oursLines, theirsLines):
+			writeLines(&out, oursLines)
+		case len(oursGroup) == 0:
+			writeLines(&out, theirsLines)
+		default:
+			conflict = true
+			out.WriteString("<<<<<<< ours\n")
+			writeConflictLines(&out, oursLines)
+			out.WriteString("=======\n")
+			writeConflictLines(&out, theirsLines)
+			out.WriteString(">>>>>>> theirs\n")
+		}
+
+		pos = end
+	}
+
+	writeLines(&out, baseLines[pos:])
+	return out.String(), conflict
+}
+
+func applyLineChanges(base []string, start, end int, changes []lineChange) []string {
+	var lines []string
+	pos := start
+	for _, c := range changes {
+		lines = append(lines, base[pos:c.start]...)
+		lines = append(lines, c.lines...)
+		pos = c.end
+	}
+
+	return append(lines, base[pos:end]...)
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeLines(sb *strings.Builder, lines []string) {
+	for _, l := range lines {
+		sb.WriteString(l)
+	}
+}
+
+// writeConflictLines writes the lines of a side of a conflict, ending them
+// with a newline so the conflict markers are at the start of a line.
+func writeConflictLines(sb *strings.Builder, lines []string) {
+	writeLines(sb, lines)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		sb.WriteByte('\n')
+	}
+}
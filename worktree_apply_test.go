@@ -0,0 +1,401 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type ApplySuite struct {
+	BaseSuite
+
+	fs billy.Filesystem
+	w  *Worktree
+}
+
+var _ = Suite(&ApplySuite{})
+
+const applyPatch = `diff --git a/nums.txt b/nums.txt
+--- a/nums.txt
++++ b/nums.txt
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+@@ -16,5 +16,6 @@
+ 16
+ 17
+ 18
++18.5
+ 19
+ 20
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/hello.txt b/greeting.txt
+similarity index 80%
+rename from hello.txt
+rename to greeting.txt
+--- a/hello.txt
++++ b/greeting.txt
+@@ -1,2 +1,2 @@
+ hello
+-world
++there
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new
+`
+
+func numbers(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		sb.WriteString(strconv.Itoa(i) + "\n")
+	}
+
+	return sb.String()
+}
+
+func (s *ApplySuite) SetUpTest(c *C) {
+	s.fs = memfs.New()
+	r, err := Init(memory.NewStorage(), s.fs)
+	c.Assert(err, IsNil)
+
+	s.w, err = r.Worktree()
+	c.Assert(err, IsNil)
+
+	s.write(c, "nums.txt", numbers(1, 20))
+	s.write(c, "hello.txt", "hello\nworld\n")
+	s.write(c, "gone.txt", "gone\n")
+
+	_, err = s.w.Add(".")
+	c.Assert(err, IsNil)
+
+	_, err = s.w.Commit("initial\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+}
+
+func (s *ApplySuite) write(c *C, name, content string) {
+	c.Assert(util.WriteFile(s.fs, name, []byte(content), 0644), IsNil)
+}
+
+func (s *ApplySuite) decode(c *C, patch string) diff.Patch {
+	p, err := diff.NewUnifiedDecoder(strings.NewReader(patch)).Decode()
+	c.Assert(err, IsNil)
+	return p
+}
+
+func (s *ApplySuite) assertFile(c *C, name, expected string) {
+	content, err := util.ReadFile(s.fs, name)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, expected)
+}
+
+func (s *ApplySuite) assertNotExists(c *C, name string) {
+	_, err := s.fs.Lstat(name)
+	c.Assert(err, NotNil)
+}
+
+func (s *ApplySuite) assertIndex(c *C, name, expected string) {
+	idx, err := s.w.r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry(name)
+	if expected == "" {
+		c.Assert(err, Equals, index.ErrEntryNotFound)
+		return
+	}
+
+	c.Assert(err, IsNil)
+
+	blob, err := s.w.r.BlobObject(e.Hash)
+	c.Assert(err, IsNil)
+
+	r, err := blob.Reader()
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, expected)
+}
+
+func patchedNumbers() string {
+	return numbers(1, 4) + "five\n" + numbers(6, 18) + "18.5\n" + numbers(19, 20)
+}
+
+func (s *ApplySuite) TestApply(c *C) {
+	err := s.w.Apply(s.decode(c, applyPatch), nil)
+	c.Assert(err, IsNil)
+
+	s.assertFile(c, "nums.txt", patchedNumbers())
+	s.assertFile(c, "greeting.txt", "hello\nthere\n")
+	s.assertFile(c, "new.txt", "new\n")
+	s.assertNotExists(c, "hello.txt")
+	s.assertNotExists(c, "gone.txt")
+
+	s.assertIndex(c, "nums.txt", numbers(1, 20))
+	s.assertIndex(c, "hello.txt", "hello\nworld\n")
+	s.assertIndex(c, "new.txt", "")
+}
+
+func (s *ApplySuite) TestApplyIndex(c *C) {
+	err := s.w.Apply(s.decode(c, applyPatch), &ApplyOptions{Index: true})
+	c.Assert(err, IsNil)
+
+	s.assertFile(c, "nums.txt", patchedNumbers())
+	s.assertIndex(c, "nums.txt", patchedNumbers())
+	s.assertIndex(c, "greeting.txt", "hello\nthere\n")
+	s.assertIndex(c, "new.txt", "new\n")
+	s.assertIndex(c, "hello.txt", "")
+	s.assertIndex(c, "gone.txt", "")
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("nums.txt").Staging, Equals, Modified)
+	c.Assert(status.File("nums.txt").Worktree, Equals, Unmodified)
+	c.Assert(status.File("new.txt").Staging, Equals, Added)
+	c.Assert(status.File("gone.txt").Staging, Equals, Deleted)
+}
+
+func (s *ApplySuite) TestApplyCached(c *C) {
+	err := s.w.Apply(s.decode(c, applyPatch), &ApplyOptions{Cached: true})
+	c.Assert(err, IsNil)
+
+	s.assertFile(c, "nums.txt", numbers(1, 20))
+	s.assertFile(c, "hello.txt", "hello\nworld\n")
+	s.assertNotExists(c, "new.txt")
+
+	s.assertIndex(c, "nums.txt", patchedNumbers())
+	s.assertIndex(c, "greeting.txt", "hello\nthere\n")
+	s.assertIndex(c, "new.txt", "new\n")
+	s.assertIndex(c, "hello.txt", "")
+}
+
+func (s *ApplySuite) TestApplyCheck(c *C) {
+	err := s.w.Apply(s.decode(c, applyPatch), &ApplyOptions{Check: true})
+	c.Assert(err, IsNil)
+
+	s.assertFile(c, "nums.txt", numbers(1, 20))
+	s.assertNotExists(c, "new.txt")
+
+	// the patch is not applied if one of the files does not apply
+	s.write(c, "hello.txt", "hello\nmodified\n")
+	err = s.w.Apply(s.decode(c, applyPatch), nil)
+	c.Assert(errors.Is(err, ErrPatchDoesNotApply), Equals, true)
+
+	s.assertFile(c, "nums.txt", numbers(1, 20))
+	s.assertFile(c, "gone.txt", "gone\n")
+	s.assertNotExists(c, "new.txt")
+}
+
+func (s *ApplySuite) TestApplyReverse(c *C) {
+	err := s.w.Apply(s.decode(c, applyPatch), &ApplyOptions{Index: true})
+	c.Assert(err, IsNil)
+
+	err = s.w.Apply(s.decode(c, applyPatch), &ApplyOptions{Index: true, Reverse: true})
+	c.Assert(err, IsNil)
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *ApplySuite) TestApplyErrors(c *C) {
+	s.write(c, "new.txt", "exists\n")
+	err := s.w.Apply(s.decode(c, applyPatch), nil)
+	c.Assert(errors.Is(err, ErrPatchFileExists), Equals, true)
+
+	c.Assert(s.fs.Remove("new.txt"), IsNil)
+	c.Assert(s.fs.Remove("gone.txt"), IsNil)
+	err = s.w.Apply(s.decode(c, applyPatch), nil)
+	c.Assert(errors.Is(err, ErrPatchFileNotFound), Equals, true)
+
+	s.write(c, "gone.txt", "gone\n")
+	s.write(c, "nums.txt", numbers(1, 21))
+	err = s.w.Apply(s.decode(c, applyPatch), &ApplyOptions{Index: true})
+	c.Assert(errors.Is(err, ErrPatchIndexMismatch), Equals, true)
+
+	s.write(c, "gone.txt", "gone\nmodified\n")
+	err = s.w.Apply(s.decode(c, applyPatch), nil)
+	c.Assert(errors.Is(err, ErrPatchDoesNotApply), Equals, true)
+}
+
+func (s *ApplySuite) TestApplyOffsetAndFuzz(c *C) {
+	s.write(c, "nums.txt", "a\nb\nc\n"+numbers(1, 20))
+
+	p := s.decode(c, applyPatch)
+	err := s.w.Apply(p, &ApplyOptions{Check: true})
+	c.Assert(err, IsNil)
+
+	s.write(c, "nums.txt", "a\nb\nc\n1\ntwo\n"+numbers(3, 20))
+	err = s.w.Apply(p, nil)
+	c.Assert(errors.Is(err, ErrPatchDoesNotApply), Equals, true)
+
+	err = s.w.Apply(p, &ApplyOptions{Fuzz: 1})
+	c.Assert(err, IsNil)
+
+	s.assertFile(c, "nums.txt", "a\nb\nc\n1\ntwo\n"+numbers(3, 4)+"five\n"+numbers(6, 18)+"18.5\n"+numbers(19, 20))
+}
+
+func (s *ApplySuite) TestApplyWhitespace(c *C) {
+	p := s.decode(c, `diff --git a/hello.txt b/hello.txt
+--- a/hello.txt
++++ b/hello.txt
+@@ -1,2 +1,3 @@
+ hello
+ world
++trailing`+"  \n")
+
+	err := s.w.Apply(p, &ApplyOptions{Whitespace: ErrorWhitespace})
+	c.Assert(errors.Is(err, ErrPatchWhitespace), Equals, true)
+
+	s.write(c, "hello.txt", "hello\n  world\n")
+	err = s.w.Apply(p, &ApplyOptions{Whitespace: FixWhitespace})
+	c.Assert(errors.Is(err, ErrPatchDoesNotApply), Equals, true)
+
+	err = s.w.Apply(p, &ApplyOptions{Whitespace: FixWhitespace, IgnoreWhitespace: true})
+	c.Assert(err, IsNil)
+
+	s.assertFile(c, "hello.txt", "hello\n  world\ntrailing\n")
+}
+
+func (s *ApplySuite) threeWayPatch(c *C) diff.Patch {
+	base := plumbing.ComputeHash(plumbing.BlobObject, []byte(numbers(1, 20)))
+	return s.decode(c, fmt.Sprintf(`diff --git a/nums.txt b/nums.txt
+index %s..0000000 100644
+--- a/nums.txt
++++ b/nums.txt
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`, base.String()[:7]))
+}
+
+func (s *ApplySuite) TestApplyThreeWay(c *C) {
+	s.write(c, "nums.txt", numbers(1, 2)+"three\n"+numbers(4, 20))
+	_, err := s.w.Add("nums.txt")
+	c.Assert(err, IsNil)
+
+	p := s.threeWayPatch(c)
+	err = s.w.Apply(p, nil)
+	c.Assert(errors.Is(err, ErrPatchDoesNotApply), Equals, true)
+
+	err = s.w.Apply(p, &ApplyOptions{ThreeWay: true})
+	c.Assert(err, IsNil)
+
+	expected := numbers(1, 2) + "three\n4\nfive\n" + numbers(6, 20)
+	s.assertFile(c, "nums.txt", expected)
+	s.assertIndex(c, "nums.txt", expected)
+}
+
+func (s *ApplySuite) TestApplyThreeWayConflict(c *C) {
+	s.write(c, "nums.txt", numbers(1, 4)+"FIVE\n"+numbers(6, 20))
+	_, err := s.w.Add("nums.txt")
+	c.Assert(err, IsNil)
+
+	err = s.w.Apply(s.threeWayPatch(c), &ApplyOptions{ThreeWay: true})
+	c.Assert(err, Equals, ErrPatchConflict)
+
+	s.assertFile(c, "nums.txt", numbers(1, 4)+
+		"<<<<<<< ours\nFIVE\n=======\nfive\n>>>>>>> theirs\n"+numbers(6, 20))
+
+	idx, err := s.w.r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	var stages []index.Stage
+	for _, e := range idx.Entries {
+		if e.Name == "nums.txt" {
+			stages = append(stages, e.Stage)
+		}
+	}
+
+	c.Assert(stages, DeepEquals, []index.Stage{index.AncestorMode, index.OurMode, index.TheirMode})
+}
+
+func (s *ApplySuite) TestApplyBinary(c *C) {
+	s.write(c, "f.bin", "a\x00b\x00c")
+
+	p := s.decode(c, `diff --git a/f.bin b/f.bin
+index 9583496fd9b881325fc7085e7d6b84ca0573355d..fe647cafed2f54efac591f90570e8cb3f707b52e 100644
+GIT binary patch
+literal 7
+OcmYdfaAHViNC5x^g#l{-
+
+literal 5
+McmYdfNMc9^00VOYCjbBd
+
+`)
+
+	err := s.w.Apply(p, nil)
+	c.Assert(err, IsNil)
+	s.assertFile(c, "f.bin", "a\x00B\x00c\x00d")
+
+	err = s.w.Apply(p, nil)
+	c.Assert(errors.Is(err, ErrPatchDoesNotApply), Equals, true)
+
+	err = s.w.Apply(p, &ApplyOptions{Reverse: true})
+	c.Assert(err, IsNil)
+	s.assertFile(c, "f.bin", "a\x00b\x00c")
+}
+
+func (s *ApplySuite) TestApplyTreePatch(c *C) {
+	head, err := s.w.r.Head()
+	c.Assert(err, IsNil)
+
+	err = s.w.Apply(s.decode(c, applyPatch), &ApplyOptions{Index: true})
+	c.Assert(err, IsNil)
+
+	h, err := s.w.Commit("patched\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	from, err := s.w.r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+
+	to, err := s.w.r.CommitObject(h)
+	c.Assert(err, IsNil)
+
+	p, err := from.Patch(to)
+	c.Assert(err, IsNil)
+
+	err = s.w.Reset(&ResetOptions{Commit: head.Hash(), Mode: HardReset})
+	c.Assert(err, IsNil)
+	s.assertFile(c, "nums.txt", numbers(1, 20))
+
+	err = s.w.Apply(p, &ApplyOptions{Index: true})
+	c.Assert(err, IsNil)
+
+	s.assertFile(c, "nums.txt", patchedNumbers())
+	s.assertFile(c, "greeting.txt", "hello\nthere\n")
+	s.assertNotExists(c, "gone.txt")
+	s.assertIndex(c, "new.txt", "new\n")
+}
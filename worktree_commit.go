@@ -79,6 +79,11 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
+	// the index is written back with the updated cache-tree
+	if err := w.r.Storer.SetIndex(idx); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	previousTree := plumbing.ZeroHash
 	if len(opts.Parents) > 0 {
 		parentCommit, err := w.r.CommitObject(opts.Parents[0])
@@ -192,13 +197,18 @@ func (s *gpgSigner) Sign(message io.Reader) ([]byte, error) {
 
 // buildTreeHelper converts a given index.Index file into multiple git objects
 // reading the blobs from the given filesystem and creating the trees from the
-// index structure. The created objects are pushed to a given Storer.
+// index structure. The created objects are pushed to a given Storer. The
+// valid trees of the cache-tree of the index are reused, and the cache-tree
+// is updated with the created trees.
 type buildTreeHelper struct {
 	fs billy.Filesystem
 	s  storage.Storer
 
 	trees   map[string]*object.Tree
 	entries map[string]*object.TreeEntry
+	hashes  map[string]plumbing.Hash
+	counts  map[string]int
+	cached  map[string][]index.TreeEntry
 }
 
 // BuildTree builds the tree objects and push its to the storer, the hash
@@ -207,6 +217,13 @@ func (h *buildTreeHelper) BuildTree(idx *index.Index, opts *CommitOptions) (plum
 	const rootNode = ""
 	h.trees = map[string]*object.Tree{rootNode: {}}
 	h.entries = map[string]*object.TreeEntry{}
+	h.hashes = map[string]plumbing.Hash{}
+	h.counts = countTreeEntries(idx)
+	h.cached = cachedTrees(idx.Cache)
+
+	if _, ok := h.cachedTree(rootNode); ok {
+		return h.cached[rootNode][0].Hash, nil
+	}
 
 	for _, e := range idx.Entries {
 		if err := h.commitIndexEntry(e); err != nil {
@@ -214,7 +231,13 @@ func (h *buildTreeHelper) BuildTree(idx *index.Index, opts *CommitOptions) (plum
 		}
 	}
 
-	return h.copyTreeToStorageRecursive(rootNode, h.trees[rootNode])
+	hash, err := h.copyTreeToStorageRecursive(rootNode, h.trees[rootNode])
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	idx.Cache = &index.Tree{Entries: h.cacheTree(rootNode, h.trees[rootNode])}
+	return hash, nil
 }
 
 func (h *buildTreeHelper) commitIndexEntry(e *index.Entry) error {
@@ -225,6 +248,13 @@ func (h *buildTreeHelper) commitIndexEntry(e *index.Entry) error {
 		parent := fullpath
 		fullpath = path.Join(fullpath, part)
 
+		if fullpath != e.Name {
+			if te, ok := h.cachedTree(fullpath); ok {
+				h.doBuildCachedTree(te, parent, fullpath)
+				return nil
+			}
+		}
+
 		h.doBuildTree(e, parent, fullpath)
 	}
 
@@ -253,6 +283,104 @@ func (h *buildTreeHelper) doBuildTree(e *index.Entry, parent, fullpath string) {
 	h.trees[parent].Entries = append(h.trees[parent].Entries, te)
 }
 
+// doBuildCachedTree adds the tree of the cache-tree to its parent, instead
+// of building it from the index entries.
+func (h *buildTreeHelper) doBuildCachedTree(e *index.TreeEntry, parent, fullpath string) {
+	if _, ok := h.entries[fullpath]; ok {
+		return
+	}
+
+	te := object.TreeEntry{Name: path.Base(fullpath), Mode: filemode.Dir, Hash: e.Hash}
+	h.entries[fullpath] = &te
+	h.trees[parent].Entries = append(h.trees[parent].Entries, te)
+}
+
+// cachedTree returns the cached tree at the given path, if it is valid: it
+// covers the same number of entries as the index and its object exists.
+func (h *buildTreeHelper) cachedTree(fullpath string) (*index.TreeEntry, bool) {
+	entries, ok := h.cached[fullpath]
+	if !ok || entries[0].Entries != h.counts[fullpath] {
+		return nil, false
+	}
+
+	if err := h.s.HasEncodedObject(entries[0].Hash); err != nil {
+		delete(h.cached, fullpath)
+		return nil, false
+	}
+
+	return &entries[0], true
+}
+
+// cacheTree returns the cache-tree entries of the tree at the given path and
+// of its subtrees.
+func (h *buildTreeHelper) cacheTree(fullpath string, t *object.Tree) []index.TreeEntry {
+	entries := []index.TreeEntry{{
+		Entries: h.counts[fullpath],
+		Hash:    h.hashes[fullpath],
+	}}
+
+	if fullpath != "" {
+		entries[0].Path = path.Base(fullpath)
+	}
+
+	for _, e := range t.Entries {
+		if e.Mode != filemode.Dir {
+			continue
+		}
+
+		entries[0].Trees++
+		sub := path.Join(fullpath, e.Name)
+		if _, ok := h.entries[sub]; ok {
+			entries = append(entries, h.cached[sub]...)
+			continue
+		}
+
+		entries = append(entries, h.cacheTree(sub, h.trees[sub])...)
+	}
+
+	return entries
+}
+
+// countTreeEntries returns the number of index entries in each directory,
+// including its subdirectories.
+func countTreeEntries(idx *index.Index) map[string]int {
+	counts := map[string]int{}
+	for _, e := range idx.Entries {
+		counts[""]++
+		for dir := path.Dir(e.Name); dir != "."; dir = path.Dir(dir) {
+			counts[dir]++
+		}
+	}
+
+	return counts
+}
+
+// cachedTrees returns the valid trees of the cache-tree by path, each one
+// with the entries of its subtrees.
+func cachedTrees(t *index.Tree) map[string][]index.TreeEntry {
+	trees := map[string][]index.TreeEntry{}
+	if t == nil || len(t.Entries) == 0 {
+		return trees
+	}
+
+	var walk func(pos int, fullpath string) int
+	walk = func(pos int, fullpath string) int {
+		next := pos + 1
+		for n := 0; n < t.Entries[pos].Trees && next < len(t.Entries); n++ {
+			next = walk(next, path.Join(fullpath, t.Entries[next].Path))
+		}
+
+		if t.Entries[pos].Entries >= 0 {
+			trees[fullpath] = t.Entries[pos:next]
+		}
+
+		return next
+	}
+
+	walk(0, "")
+	return trees
+}
+
 type sortableEntries []object.TreeEntry
 
 func (sortableEntries) sortName(te object.TreeEntry) string {
@@ -268,7 +396,7 @@ func (se sortableEntries) Swap(i int, j int)      { se[i], se[j] = se[j], se[i]
 func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tree) (plumbing.Hash, error) {
 	sort.Sort(sortableEntries(t.Entries))
 	for i, e := range t.Entries {
-		if e.Mode != filemode.Dir && !e.Hash.IsZero() {
+		if !e.Hash.IsZero() {
 			continue
 		}
 
@@ -289,6 +417,7 @@ func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tr
 	}
 
 	hash := o.Hash()
+	h.hashes[parent] = hash
 	if h.s.HasEncodedObject(hash) == nil {
 		return hash, nil
 	}
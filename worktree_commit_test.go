@@ -13,6 +13,7 @@ import (
 	fixtures "github.com/go-git/go-git-fixtures/v4"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/storage/filesystem"
@@ -560,6 +561,82 @@ func (s *WorktreeSuite) TestCommitTreeSort(c *C) {
 	c.Assert(err, IsNil, Commentf("%s", buf.Bytes()))
 }
 
+func (s *WorktreeSuite) TestCommitCacheTree(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, p := range []string{"foo", "bar/baz", "bar/qux/quux", "corge/grault"} {
+		util.WriteFile(fs, p, []byte(p), 0644)
+	}
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Cache.Entries, HasLen, 4)
+	c.Assert(idx.Cache.Entries[0], DeepEquals, index.TreeEntry{
+		Entries: 4, Trees: 2, Hash: commit.TreeHash,
+	})
+
+	for i, name := range []string{"bar", "qux", "corge"} {
+		tree, err := commit.Tree()
+		c.Assert(err, IsNil)
+
+		p := name
+		if name == "qux" {
+			p = "bar/qux"
+		}
+
+		entry, err := tree.FindEntry(p)
+		c.Assert(err, IsNil)
+		c.Assert(idx.Cache.Entries[i+1].Path, Equals, name)
+		c.Assert(idx.Cache.Entries[i+1].Hash, Equals, entry.Hash)
+	}
+
+	// the valid trees of the cache are used as they are, so the tree of
+	// corge is replaced with the one of qux
+	idx.Cache.Entries[3].Hash = idx.Cache.Entries[2].Hash
+	c.Assert(r.Storer.SetIndex(idx), IsNil)
+
+	util.WriteFile(fs, "bar/baz", []byte("modified"), 0644)
+	_, err = w.Add("bar/baz")
+	c.Assert(err, IsNil)
+
+	idx, err = r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Cache.Entries[0].Entries, Equals, -1)
+	c.Assert(idx.Cache.Entries[1].Entries, Equals, -1)
+	c.Assert(idx.Cache.Entries[2].Entries, Equals, 1)
+
+	hash, err = w.Commit("bar\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	file, err := commit.File("corge/quux")
+	c.Assert(err, IsNil)
+	c.Assert(file.Name, Equals, "corge/quux")
+
+	file, err = commit.File("bar/baz")
+	c.Assert(err, IsNil)
+
+	contents, err := file.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(contents, Equals, "modified")
+}
+
 // https://github.com/go-git/go-git/pull/224
 func (s *WorktreeSuite) TestJustStoreObjectsNotAlreadyStored(c *C) {
 	fs := s.TemporalFilesystem(c)
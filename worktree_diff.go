@@ -0,0 +1,416 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+var (
+	// ErrInvalidPathspec is returned by Worktree.Diff when a pathspec is
+	// not a valid pattern.
+	ErrInvalidPathspec = errors.New("invalid pathspec")
+)
+
+// Diff returns the changes between the worktree, the index and a commit, as
+// `git diff` does. By default the changes of the worktree not staged in the
+// index are returned, as `git diff`. If Cached is true the changes staged in
+// the index are returned, as `git diff --cached`, and if a Commit is given
+// the changes of the worktree, or the index, since the commit are returned,
+// as `git diff <commit>`.
+//
+// Only the files tracked in the index are compared, the untracked files are
+// ignored. The changes are limited to the given pathspecs before detecting
+// the renames. The trees of the index and the worktree are not written to
+// the repository, the blobs of the changed files are kept in memory.
+func (w *Worktree) Diff(o *DiffOptions) (object.Changes, error) {
+	if err := o.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	match, err := pathspecMatcher(o.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &overlayStorer{Storer: w.r.Storer, mem: memory.NewStorage()}
+
+	var from, to *object.Tree
+	if o.Cached || !o.Commit.IsZero() {
+		if from, err = w.commitTree(o.Commit); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Cached {
+		to, err = w.indexTree(s)
+	} else {
+		if from == nil {
+			if from, err = w.indexTree(s); err != nil {
+				return nil, err
+			}
+		}
+
+		to, err = w.worktreeTree(s)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if match != nil {
+		var matched object.Changes
+		for _, c := range changes {
+			if match(c.From.Name) || match(c.To.Name) {
+				matched = append(matched, c)
+			}
+		}
+
+		changes = matched
+	}
+
+	if o.DiffTreeOptions.DetectRenames {
+		if changes, err = object.DetectRenamesFromTree(changes, from, o.DiffTreeOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	// the changes are sorted as git does, by the destination path
+	sort.SliceStable(changes, func(i, j int) bool {
+		return diffChangeName(changes[i]) < diffChangeName(changes[j])
+	})
+
+	return changes, nil
+}
+
+func diffChangeName(c *object.Change) string {
+	if c.To.Name != "" {
+		return c.To.Name
+	}
+
+	return c.From.Name
+}
+
+// DiffPatch returns the Patch of the changes returned by Worktree.Diff, with
+// the line diffs computed as `git diff` does.
+func (w *Worktree) DiffPatch(o *DiffOptions) (*object.Patch, error) {
+	changes, err := w.Diff(o)
+	if err != nil {
+		return nil, err
+	}
+
+	po := o.PatchOptions
+	if po == nil {
+		if po, err = w.r.diffOptions(); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes.PatchWithOptions(context.Background(), po)
+}
+
+// DiffFuncname returns the function returning the Funcname of the hunk
+// headers of a file, to be set with diff.UnifiedEncoder.SetFuncname. The
+// diff driver of a file is given by its `diff` gitattribute, its xfuncname
+// being the one of the `diff.<driver>.xfuncname` config, or the one of the
+// builtin driver with the same name.
+func (w *Worktree) DiffFuncname() (func(path string) *fdiff.Funcname, error) {
+	cfg, err := w.r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := gitattributes.ReadPatterns(w.Filesystem, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	drivers := make(map[string]*fdiff.Funcname)
+	for name, d := range cfg.Diff.Drivers {
+		if d.XFuncname == "" {
+			continue
+		}
+
+		if drivers[name], err = fdiff.NewFuncname(d.XFuncname); err != nil {
+			return nil, fmt.Errorf("invalid xfuncname of diff driver %q: %w", name, err)
+		}
+	}
+
+	m := gitattributes.NewMatcher(patterns)
+	return func(path string) *fdiff.Funcname {
+		attrs, _ := m.Match(strings.Split(path, "/"), []string{"diff"})
+		a, ok := attrs["diff"]
+		if !ok || !a.IsValueSet() {
+			return nil
+		}
+
+		f, ok := drivers[a.Value()]
+		if !ok {
+			f = fdiff.BuiltinFuncname(a.Value())
+			drivers[a.Value()] = f
+		}
+
+		return f
+	}, nil
+}
+
+func (w *Worktree) commitTree(h plumbing.Hash) (*object.Tree, error) {
+	if h.IsZero() {
+		return nil, nil
+	}
+
+	c, err := w.r.CommitObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Tree()
+}
+
+// indexTree returns the tree of the merged entries of the index.
+func (w *Worktree) indexTree(s storage.Storer) (*object.Tree, error) {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	return w.buildDiffTree(s, idx, idx.Cache, nil)
+}
+
+// worktreeTree returns the tree of the files of the worktree tracked in the
+// index, the blobs of the modified files being written to s.
+func (w *Worktree) worktreeTree(s storage.Storer) (*object.Tree, error) {
+	changes, err := w.diffStagingWithWorktree(false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	submodules, err := w.getSubmodulesStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	modified := make(map[string]*index.Entry)
+	for _, ch := range changes {
+		a, err := ch.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		switch a {
+		case merkletrie.Delete:
+			modified[ch.From.String()] = nil
+		case merkletrie.Modify:
+			name := ch.To.String()
+			e, err := w.worktreeEntry(s, name, submodules)
+			if err != nil {
+				return nil, err
+			}
+
+			modified[name] = e
+		}
+	}
+
+	// the cache-tree is not used, since it describes the index
+	return w.buildDiffTree(s, idx, nil, modified)
+}
+
+// worktreeEntry returns the entry of a modified file of the worktree.
+func (w *Worktree) worktreeEntry(s storage.Storer, name string, submodules map[string]plumbing.Hash) (*index.Entry, error) {
+	if h, ok := submodules[name]; ok {
+		return &index.Entry{Name: name, Hash: h, Mode: filemode.Submodule}, nil
+	}
+
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := w.copyFileToStorer(s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &index.Entry{Name: name, Hash: h, Mode: mode}, nil
+}
+
+// buildDiffTree builds the tree of the merged entries of the index, with
+// the given entries replaced, or removed if nil, writing the trees to s.
+func (w *Worktree) buildDiffTree(s storage.Storer, idx *index.Index, cache *index.Tree, modified map[string]*index.Entry) (*object.Tree, error) {
+	tmp := &index.Index{Version: idx.Version, Cache: cache}
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			continue
+		}
+
+		if m, ok := modified[e.Name]; ok {
+			e = m
+		}
+
+		if e != nil {
+			tmp.Entries = append(tmp.Entries, e)
+		}
+	}
+
+	h := &buildTreeHelper{fs: w.Filesystem, s: s}
+	hash, err := h.BuildTree(tmp, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(s, hash)
+}
+
+// overlayStorer is a storer writing the new objects to memory, and reading
+// them from memory or from the storer of the repository, so the trees of
+// the index and the worktree can be diffed without writing them to the
+// repository.
+type overlayStorer struct {
+	storage.Storer
+	mem *memory.Storage
+}
+
+func (s *overlayStorer) NewEncodedObject() plumbing.EncodedObject {
+	return s.mem.NewEncodedObject()
+}
+
+func (s *overlayStorer) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	return s.mem.SetEncodedObject(obj)
+}
+
+func (s *overlayStorer) HasEncodedObject(h plumbing.Hash) error {
+	if err := s.mem.HasEncodedObject(h); err == nil {
+		return nil
+	}
+
+	return s.Storer.HasEncodedObject(h)
+}
+
+func (s *overlayStorer) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	if size, err := s.mem.EncodedObjectSize(h); err == nil {
+		return size, nil
+	}
+
+	return s.Storer.EncodedObjectSize(h)
+}
+
+func (s *overlayStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	if obj, err := s.mem.EncodedObject(t, h); err == nil {
+		return obj, nil
+	}
+
+	return s.Storer.EncodedObject(t, h)
+}
+
+// pathspecMatcher returns a function matching the paths with the given
+// pathspecs, or nil if there are no pathspecs.
+func pathspecMatcher(specs []string) (func(string) bool, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	var prefixes []string
+	var patterns []*regexp.Regexp
+	for _, spec := range specs {
+		spec = strings.TrimSuffix(strings.TrimPrefix(spec, "./"), "/")
+		if spec == "" || spec == "." {
+			return func(string) bool { return true }, nil
+		}
+
+		if !strings.ContainsAny(spec, "*?[") {
+			prefixes = append(prefixes, spec)
+			continue
+		}
+
+		re, err := regexp.Compile(globToRegexp(spec))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPathspec, spec)
+		}
+
+		patterns = append(patterns, re)
+	}
+
+	return func(name string) bool {
+		if name == "" {
+			return false
+		}
+
+		for _, p := range prefixes {
+			if name == p || strings.HasPrefix(name, p+"/") {
+				return true
+			}
+		}
+
+		for _, re := range patterns {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// globToRegexp converts a glob pattern to an anchored regexp, the wildcards
+// matching the slashes as the ones of the pathspecs of git.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(glob[i:]))
+				i = len(glob)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,252 @@
+package git
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+
+	. "gopkg.in/check.v1"
+)
+
+type DiffSuite struct {
+	BaseSuite
+
+	fs   billy.Filesystem
+	r    *Repository
+	w    *Worktree
+	head plumbing.Hash
+}
+
+var _ = Suite(&DiffSuite{})
+
+func (s *DiffSuite) SetUpTest(c *C) {
+	s.fs = memfs.New()
+	r, err := Init(memory.NewStorage(), s.fs)
+	c.Assert(err, IsNil)
+
+	s.r = r
+	s.w, err = r.Worktree()
+	c.Assert(err, IsNil)
+
+	s.write(c, "a.txt", "one\ntwo\nthree\nfour\nfive\nsix\nseven\n")
+	s.write(c, "dir/b.txt", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	s.write(c, "d.txt", "delete me\n")
+	c.Assert(s.w.AddWithOptions(&AddOptions{All: true}), IsNil)
+
+	s.head, err = s.w.Commit("first\n", &CommitOptions{Author: &object.Signature{
+		Name:  "A U Thor",
+		Email: "author@example.com",
+		When:  time.Date(2005, 4, 7, 15, 13, 13, 0, time.UTC),
+	}})
+	c.Assert(err, IsNil)
+
+	// staged: a modification and a rename with a modification
+	s.write(c, "a.txt", "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\n")
+	c.Assert(s.fs.Remove("dir/b.txt"), IsNil)
+	s.write(c, "dir/e.txt", "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n")
+	c.Assert(s.w.AddWithOptions(&AddOptions{All: true}), IsNil)
+
+	// not staged: a modification, a deletion and an untracked file
+	s.write(c, "a.txt", "one\ntwo\nTHREE\nfour\nfive\nsix\nSEVEN\n")
+	c.Assert(s.fs.Remove("d.txt"), IsNil)
+	s.write(c, "u.txt", "untracked\n")
+}
+
+func (s *DiffSuite) write(c *C, name, content string) {
+	c.Assert(util.WriteFile(s.fs, name, []byte(content), 0644), IsNil)
+}
+
+func (s *DiffSuite) TestDiff(c *C) {
+	p, err := s.w.DiffPatch(&DiffOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(p.String(), Equals, `diff --git a/a.txt b/a.txt
+index f8931154cdb1943d21b033ada5ff4cab77349c41..4a37f69b9b1f04f97a1010af5b2ac909c627e59e 100644
+--- a/a.txt
++++ b/a.txt
+@@ -4,4 +4,4 @@ THREE
+ four
+ five
+ six
+-seven
++SEVEN
+diff --git a/d.txt b/d.txt
+deleted file mode 100644
+index 2d030d7bc1bbfbdee332aaf691447b30cdea375b..0000000000000000000000000000000000000000
+--- a/d.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-delete me
+`)
+
+	// the objects of the worktree are not written to the repository
+	_, err = s.r.BlobObject(plumbing.NewHash("4a37f69b9b1f04f97a1010af5b2ac909c627e59e"))
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *DiffSuite) TestDiffCached(c *C) {
+	changes, err := s.w.Diff(&DiffOptions{Cached: true})
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 2)
+
+	c.Assert(changes[0].From.Name, Equals, "a.txt")
+	c.Assert(changes[0].To.Name, Equals, "a.txt")
+	c.Assert(changes[1].From.Name, Equals, "dir/b.txt")
+	c.Assert(changes[1].To.Name, Equals, "dir/e.txt")
+
+	p, err := changes[1].Patch()
+	c.Assert(err, IsNil)
+	c.Assert(p.String(), Equals, `diff --git a/dir/b.txt b/dir/e.txt
+rename from dir/b.txt
+rename to dir/e.txt
+index f00c965d8307308469e537302baa73048488f162..088bd5d92c2a8e0203ca8e7e4c2a5c692f6ae3f7 100644
+--- a/dir/b.txt
++++ b/dir/e.txt
+@@ -7,4 +7,4 @@ 6
+ 7
+ 8
+ 9
+-10
++ten
+`)
+
+	changes, err = s.w.Diff(&DiffOptions{
+		Cached:          true,
+		DiffTreeOptions: &object.DiffTreeOptions{},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 3)
+}
+
+func (s *DiffSuite) TestDiffCommit(c *C) {
+	changes, err := s.w.Diff(&DiffOptions{Commit: s.head})
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 3)
+
+	var names []string
+	for _, ch := range changes {
+		a, err := ch.Action()
+		c.Assert(err, IsNil)
+
+		names = append(names, a.String()+" "+diffChangeName(ch))
+	}
+
+	c.Assert(names, DeepEquals, []string{"Modify a.txt", "Delete d.txt", "Modify dir/e.txt"})
+
+	p, err := s.w.DiffPatch(&DiffOptions{Commit: s.head, Paths: []string{"a.txt"}})
+	c.Assert(err, IsNil)
+	c.Assert(p.String(), Equals, `diff --git a/a.txt b/a.txt
+index 2019eda923040a79f52fe4bf49e0a106f4f658ae..4a37f69b9b1f04f97a1010af5b2ac909c627e59e 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,7 +1,7 @@
+ one
+ two
+-three
++THREE
+ four
+ five
+ six
+-seven
++SEVEN
+`)
+}
+
+func (s *DiffSuite) TestDiffPaths(c *C) {
+	for _, t := range []struct {
+		paths []string
+		exp   []string
+	}{
+		{[]string{"dir"}, []string{"dir/e.txt"}},
+		{[]string{"./dir/"}, []string{"dir/e.txt"}},
+		{[]string{"*.txt"}, []string{"a.txt", "dir/e.txt"}},
+		{[]string{"d?r/[a-e].txt"}, []string{"dir/e.txt"}},
+		{[]string{"."}, []string{"a.txt", "dir/e.txt"}},
+		{[]string{"foo"}, nil},
+	} {
+		changes, err := s.w.Diff(&DiffOptions{Cached: true, Paths: t.paths})
+		c.Assert(err, IsNil)
+
+		var names []string
+		for _, ch := range changes {
+			names = append(names, diffChangeName(ch))
+		}
+
+		c.Assert(names, DeepEquals, t.exp, Commentf("%v", t.paths))
+	}
+
+	// the renames are detected only among the matched paths
+	changes, err := s.w.Diff(&DiffOptions{Cached: true, Paths: []string{"dir/e.txt"}})
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 1)
+
+	a, err := changes[0].Action()
+	c.Assert(err, IsNil)
+	c.Assert(a, Equals, merkletrie.Insert)
+
+	_, err = s.w.Diff(&DiffOptions{Paths: []string{"[z-a]"}})
+	c.Assert(errors.Is(err, ErrInvalidPathspec), Equals, true)
+}
+
+func (s *DiffSuite) TestDiffUnbornBranch(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("foo\n"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	changes, err := w.Diff(&DiffOptions{Cached: true})
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 1)
+	c.Assert(changes[0].To.Name, Equals, "foo")
+
+	changes, err = w.Diff(&DiffOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 0)
+}
+
+func (s *DiffSuite) TestDiffFuncname(c *C) {
+	s.write(c, ".gitattributes", "*.go diff=golang\n*.md diff=notes\n*.txt -diff\n")
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Diff.Drivers = map[string]*config.DiffDriver{
+		"notes": {Name: "notes", XFuncname: "^# (.*)$"},
+	}
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	funcname, err := s.w.DiffFuncname()
+	c.Assert(err, IsNil)
+
+	f := funcname("dir/main.go")
+	c.Assert(f, NotNil)
+	name, ok := f.Match("func main() {\n")
+	c.Assert(ok, Equals, true)
+	c.Assert(name, Equals, "func main() {")
+
+	f = funcname("README.md")
+	c.Assert(f, NotNil)
+	name, ok = f.Match("# Usage\n")
+	c.Assert(ok, Equals, true)
+	c.Assert(name, Equals, "Usage")
+
+	c.Assert(funcname("a.txt"), IsNil)
+	c.Assert(funcname("Makefile"), IsNil)
+
+	cfg.Diff.Drivers["notes"].XFuncname = "("
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	_, err = s.w.DiffFuncname()
+	c.Assert(err, ErrorMatches, `invalid xfuncname of diff driver "notes": .*`)
+}
@@ -0,0 +1,146 @@
+package git
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"golang.org/x/sys/execabs"
+)
+
+// FileSystemMonitor reports the paths changed in the worktree since a point
+// in time identified by a token, as the core.fsmonitor hook does. The token
+// is stored in the index. If a query fails, all the files are examined.
+type FileSystemMonitor interface {
+	// Query returns the paths changed since the given token, empty if
+	// unknown, and the token of the current state of the worktree.
+	Query(token string) (*FileSystemMonitorResult, error)
+}
+
+// FileSystemMonitorResult is the result of a FileSystemMonitor query.
+type FileSystemMonitorResult struct {
+	// Token identifies the current state of the worktree, it is given to the
+	// next query.
+	Token string
+	// Paths are the paths changed since the queried token, relative to the
+	// root of the worktree. A directory can be given with a trailing slash,
+	// all the files in it are then examined.
+	Paths []string
+	// All is set if any file could have changed, for example if the queried
+	// token is unknown or too old.
+	All bool
+}
+
+// NewHookFileSystemMonitor returns a FileSystemMonitor running the given
+// core.fsmonitor hook in the given directory, the root of the worktree, with
+// the version 2 of its protocol.
+func NewHookFileSystemMonitor(hook, dir string) FileSystemMonitor {
+	return &hookFileSystemMonitor{hook: hook, dir: dir}
+}
+
+type hookFileSystemMonitor struct {
+	hook string
+	dir  string
+}
+
+// Query runs the hook with the version of the protocol and the token as
+// arguments. It outputs the new token and the changed paths, separated by
+// NUL characters, the path "/" if all the files could have changed.
+func (m *hookFileSystemMonitor) Query(token string) (*FileSystemMonitorResult, error) {
+	cmd := execabs.Command(m.hook, "2", token)
+	cmd.Dir = m.dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := bytes.Split(out, []byte{'\x00'})
+	r := &FileSystemMonitorResult{Token: string(fields[0])}
+	for _, field := range fields[1:] {
+		switch string(field) {
+		case "":
+			continue
+		case "/":
+			r.All = true
+			r.Paths = nil
+			return r, nil
+		}
+
+		r.Paths = append(r.Paths, string(field))
+	}
+
+	return r, nil
+}
+
+// queryFileSystemMonitor queries the FileSystemMonitor of the worktree, if
+// any, with the token of the index, and invalidates the index entries of the
+// reported paths. The other entries can be trusted, unless all the entries
+// are invalidated.
+func (w *Worktree) queryFileSystemMonitor(idx *index.Index) (token string, trusted bool) {
+	if w.FileSystemMonitor == nil {
+		return "", false
+	}
+
+	var last string
+	if idx.FileSystemMonitor != nil {
+		last = idx.FileSystemMonitor.Token
+	}
+
+	r, err := w.FileSystemMonitor.Query(last)
+	if err != nil {
+		setFileSystemMonitorValid(idx, false)
+		return "", false
+	}
+
+	if last == "" || r.All {
+		setFileSystemMonitorValid(idx, false)
+		return r.Token, false
+	}
+
+	changed := make(map[string]bool, len(r.Paths))
+	for _, p := range r.Paths {
+		p = strings.TrimSuffix(p, "/")
+		changed[p] = true
+		idx.UntrackedCache.Invalidate(p)
+	}
+
+	for _, e := range idx.Entries {
+		for name := e.Name; name != "."; name = path.Dir(name) {
+			if changed[name] {
+				e.FSMonitorValid = false
+				break
+			}
+		}
+	}
+
+	return r.Token, true
+}
+
+// updateFileSystemMonitor stores the token of the FileSystemMonitor in the
+// index, and sets its entries as valid, except the ones of the changes
+// between the index and the worktree.
+func updateFileSystemMonitor(idx *index.Index, token string, changes merkletrie.Changes) {
+	setFileSystemMonitorValid(idx, true)
+
+	changed := make(map[string]bool, len(changes))
+	for _, ch := range changes {
+		changed[nameFromAction(&ch)] = true
+	}
+
+	for _, e := range idx.Entries {
+		if changed[e.Name] {
+			e.FSMonitorValid = false
+		}
+	}
+
+	idx.FileSystemMonitor = &index.FileSystemMonitor{Token: token}
+}
+
+func setFileSystemMonitorValid(idx *index.Index, valid bool) {
+	for _, e := range idx.Entries {
+		e.FSMonitorValid = valid
+	}
+}
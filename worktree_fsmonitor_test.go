@@ -0,0 +1,124 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type FileSystemMonitorSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&FileSystemMonitorSuite{})
+
+type testFileSystemMonitor struct {
+	tokens []string
+	paths  []string
+	all    bool
+}
+
+func (m *testFileSystemMonitor) Query(token string) (*FileSystemMonitorResult, error) {
+	m.tokens = append(m.tokens, token)
+	r := &FileSystemMonitorResult{
+		Token: strconv.Itoa(len(m.tokens)),
+		Paths: m.paths,
+		All:   m.all,
+	}
+
+	m.paths = nil
+	return r, nil
+}
+
+func (s *FileSystemMonitorSuite) TestStatus(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo", "bar/baz"} {
+		err = util.WriteFile(fs, name, []byte(name), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	m := &testFileSystemMonitor{}
+	w.FileSystemMonitor = m
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, false)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.FileSystemMonitor.Token, Equals, "1")
+	for _, e := range idx.Entries {
+		c.Assert(e.FSMonitorValid, Equals, true)
+	}
+
+	// the changes not reported are not seen
+	err = util.WriteFile(fs, "foo", []byte("qux"), 0644)
+	c.Assert(err, IsNil)
+	err = util.WriteFile(fs, "bar/baz", []byte("qux"), 0644)
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+	c.Assert(status.File("bar/baz").Worktree, Equals, Unmodified)
+
+	m.paths = []string{"bar/"}
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+	c.Assert(status.File("bar/baz").Worktree, Equals, Modified)
+
+	e, err := idx.Entry("bar/baz")
+	c.Assert(err, IsNil)
+	c.Assert(e.FSMonitorValid, Equals, false)
+
+	m.all = true
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+	c.Assert(status.File("bar/baz").Worktree, Equals, Modified)
+
+	c.Assert(m.tokens, DeepEquals, []string{"", "1", "2", "3"})
+}
+
+func (s *FileSystemMonitorSuite) TestHookFileSystemMonitor(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("the hook is a shell script")
+	}
+
+	dir := c.MkDir()
+	hook := filepath.Join(dir, "fsmonitor")
+	err := os.WriteFile(hook, []byte("#!/bin/sh\n"+
+		"[ \"$1\" = 2 ] || exit 1\n"+
+		"if [ -z \"$2\" ]; then printf 'foo\\0/\\0'; exit 0; fi\n"+
+		"printf 'bar\\0qux\\0quux/\\0'\n"), 0755)
+	c.Assert(err, IsNil)
+
+	m := NewHookFileSystemMonitor(hook, dir)
+	r, err := m.Query("")
+	c.Assert(err, IsNil)
+	c.Assert(r, DeepEquals, &FileSystemMonitorResult{Token: "foo", All: true})
+
+	r, err = m.Query("foo")
+	c.Assert(err, IsNil)
+	c.Assert(r, DeepEquals, &FileSystemMonitorResult{
+		Token: "bar",
+		Paths: []string{"qux", "quux/"},
+	})
+}
@@ -0,0 +1,383 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var (
+	ErrMalformedMail  = errors.New("malformed email message")
+	ErrMailDirtyIndex = errors.New("index does not match HEAD, cannot apply patches")
+)
+
+// mailPatch is a patch read from an email message.
+type mailPatch struct {
+	author  object.Signature
+	subject string
+	body    string
+	patch   fdiff.Patch
+}
+
+// message returns the commit message of the patch.
+func (p *mailPatch) message() string {
+	if p.body == "" {
+		return p.subject + "\n"
+	}
+
+	return p.subject + "\n\n" + p.body
+}
+
+// ApplyMailbox applies the patches of the messages of a mailbox in the mbox
+// format, as the ones written by Repository.FormatPatch, and commits each
+// one with the author, date and message of its email message. It is
+// equivalent to running `git am <mbox>`.
+//
+// The patches are applied to the index and to the working tree, which must
+// match HEAD for the patched files. If a patch does not apply the previous
+// commits are kept, and the error is returned. The messages without a
+// patch, as the cover letters, are skipped. It returns the hashes of the
+// new commits.
+func (w *Worktree) ApplyMailbox(mbox io.Reader, o *ApplyMailboxOptions) ([]plumbing.Hash, error) {
+	if o == nil {
+		o = &ApplyMailboxOptions{}
+	}
+
+	if err := o.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	messages, err := splitMailbox(mbox)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.applyMails(messages, o)
+}
+
+// ApplyMaildir applies the patches of the messages of a maildir, read in
+// the order of their file names, as ApplyMailbox does.
+func (w *Worktree) ApplyMaildir(dir billy.Filesystem, o *ApplyMailboxOptions) ([]plumbing.Hash, error) {
+	if o == nil {
+		o = &ApplyMailboxOptions{}
+	}
+
+	if err := o.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	messages, err := readMaildir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.applyMails(messages, o)
+}
+
+func (w *Worktree) applyMails(messages [][]byte, o *ApplyMailboxOptions) ([]plumbing.Hash, error) {
+	if err := w.checkIndexMatchesHead(); err != nil {
+		return nil, err
+	}
+
+	var commits []plumbing.Hash
+	for _, m := range messages {
+		p, err := parseMailPatch(m, o.KeepSubject)
+		if err != nil {
+			return commits, err
+		}
+
+		if p.patch == nil {
+			continue
+		}
+
+		err = w.Apply(p.patch, &ApplyOptions{
+			Index:      true,
+			ThreeWay:   o.ThreeWay,
+			Whitespace: o.Whitespace,
+		})
+		if err != nil {
+			return commits, fmt.Errorf("applying %q: %w", p.subject, err)
+		}
+
+		author := p.author
+		committer := *o.Committer
+		h, err := w.Commit(p.message(), &CommitOptions{
+			Author:    &author,
+			Committer: &committer,
+		})
+		if err != nil {
+			return commits, err
+		}
+
+		commits = append(commits, h)
+	}
+
+	return commits, nil
+}
+
+// checkIndexMatchesHead returns ErrMailDirtyIndex if the index has changes
+// not committed.
+func (w *Worktree) checkIndexMatchesHead() error {
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, fs := range status {
+		if fs.Staging != Unmodified && fs.Staging != Untracked {
+			return ErrMailDirtyIndex
+		}
+	}
+
+	return nil
+}
+
+// splitMailbox returns the messages of a mbox, each one starting after a
+// `From ` line at the beginning of the mbox or after an empty line.
+func splitMailbox(r io.Reader) ([][]byte, error) {
+	var (
+		messages [][]byte
+		current  *bytes.Buffer
+		empty    = true
+	)
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) != 0 {
+			switch {
+			case empty && bytes.HasPrefix(line, []byte("From ")):
+				current = bytes.NewBuffer(nil)
+				messages = append(messages, nil)
+			case current == nil:
+				if len(bytes.TrimSpace(line)) != 0 {
+					return nil, ErrMalformedMail
+				}
+			default:
+				current.Write(line)
+				messages[len(messages)-1] = current.Bytes()
+			}
+
+			empty = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return messages, nil
+}
+
+// readMaildir returns the messages of the cur and new directories of a
+// maildir, sorted by their file names.
+func readMaildir(dir billy.Filesystem) ([][]byte, error) {
+	var names []string
+	for _, sub := range []string{"cur", "new"} {
+		files, err := dir.ReadDir(sub)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		for _, fi := range files {
+			if !fi.IsDir() && !strings.HasPrefix(fi.Name(), ".") {
+				names = append(names, path.Join(sub, fi.Name()))
+			}
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return path.Base(names[i]) < path.Base(names[j])
+	})
+
+	messages := make([][]byte, 0, len(names))
+	for _, name := range names {
+		m, err := util.ReadFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// parseMailPatch parses an email message, the headers of the body, if
+// any, replace the ones of the message, and the message ends at the `---`
+// line or where the patch starts, as git does.
+func parseMailPatch(raw []byte, keepSubject bool) (*mailPatch, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformedMail, err)
+	}
+
+	body, err := readMailBody(m)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &mailPatch{}
+	headers := map[string]string{
+		"From":    m.Header.Get("From"),
+		"Date":    m.Header.Get("Date"),
+		"Subject": m.Header.Get("Subject"),
+	}
+
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	lines = parseInBodyHeaders(lines, headers)
+
+	if err := p.setHeaders(headers, keepSubject); err != nil {
+		return nil, err
+	}
+
+	var message []string
+	for i, line := range lines {
+		if strings.TrimRight(line, " \t") == "---" {
+			lines = lines[i+1:]
+			break
+		}
+
+		if strings.HasPrefix(line, "diff -") || strings.HasPrefix(line, "Index: ") {
+			lines = lines[i:]
+			break
+		}
+
+		message = append(message, line)
+		if i == len(lines)-1 {
+			lines = nil
+		}
+	}
+
+	p.body = strings.TrimSpace(strings.Join(message, "\n"))
+	if p.body != "" {
+		p.body += "\n"
+	}
+
+	if len(lines) == 0 {
+		return p, nil
+	}
+
+	patch, err := fdiff.NewUnifiedDecoder(strings.NewReader(strings.Join(lines, "\n"))).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patch.FilePatches()) != 0 {
+		p.patch = patch
+	}
+
+	return p, nil
+}
+
+// readMailBody returns the body of the message, decoded if it is encoded
+// as quoted-printable or base64.
+func readMailBody(m *mail.Message) (string, error) {
+	var r io.Reader = m.Body
+	switch strings.ToLower(m.Header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrMalformedMail, err)
+	}
+
+	return string(body), nil
+}
+
+// parseInBodyHeaders reads the From, Date and Subject headers at the
+// beginning of the body, used when the author of the patch is not the
+// sender of the message, and returns the rest of the body.
+func parseInBodyHeaders(lines []string, headers map[string]string) []string {
+	i := 0
+	for ; i < len(lines); i++ {
+		name, value, ok := strings.Cut(lines[i], ":")
+		if _, known := headers[name]; !ok || !known {
+			break
+		}
+
+		headers[name] = strings.TrimSpace(value)
+	}
+
+	if i != 0 && i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	return lines[i:]
+}
+
+func (p *mailPatch) setHeaders(headers map[string]string, keepSubject bool) error {
+	dec := &mime.WordDecoder{}
+
+	from, err := (&mail.AddressParser{WordDecoder: dec}).Parse(headers["From"])
+	if err != nil {
+		return fmt.Errorf("%w: invalid author: %s", ErrMalformedMail, err)
+	}
+
+	when, err := mail.ParseDate(headers["Date"])
+	if err != nil {
+		return fmt.Errorf("%w: invalid date: %s", ErrMalformedMail, err)
+	}
+
+	subject, err := dec.DecodeHeader(headers["Subject"])
+	if err != nil {
+		return fmt.Errorf("%w: invalid subject: %s", ErrMalformedMail, err)
+	}
+
+	if !keepSubject {
+		subject = cleanupMailSubject(subject)
+	}
+
+	p.author = object.Signature{Name: from.Name, Email: from.Address, When: when}
+	p.subject = strings.TrimSpace(subject)
+	return nil
+}
+
+// cleanupMailSubject removes the `Re:` and the bracketed prefixes of the
+// subject, as `[PATCH n/m]`.
+func cleanupMailSubject(s string) string {
+	for {
+		s = strings.TrimSpace(s)
+		switch {
+		case len(s) >= 3 && strings.EqualFold(s[:3], "re:"):
+			s = s[3:]
+		case strings.HasPrefix(s, "["):
+			i := strings.Index(s, "]")
+			if i < 0 {
+				return s
+			}
+
+			s = s[i+1:]
+		default:
+			return s
+		}
+	}
+}
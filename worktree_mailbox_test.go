@@ -0,0 +1,211 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type MailboxSuite struct {
+	BaseSuite
+
+	fs      billy.Filesystem
+	r       *Repository
+	w       *Worktree
+	commits []plumbing.Hash
+}
+
+var _ = Suite(&MailboxSuite{})
+
+var mailboxCommitter = &object.Signature{
+	Name:  "Committer",
+	Email: "committer@example.com",
+	When:  time.Date(2005, 4, 9, 0, 0, 0, 0, time.UTC),
+}
+
+func (s *MailboxSuite) SetUpTest(c *C) {
+	s.fs = memfs.New()
+	r, err := Init(memory.NewStorage(), s.fs)
+	c.Assert(err, IsNil)
+
+	s.r = r
+	s.w, err = r.Worktree()
+	c.Assert(err, IsNil)
+
+	s.commits = nil
+	s.commit(c, "A U Thor", "initial\n", "a.txt", "a\nb\n")
+	s.commit(c, "A U Thor", "first change\n\nbody line\n", "a.txt", "a\nB\n")
+	s.commit(c, "Jösé", "Ünïcode second\n", "n.txt", "x\n")
+}
+
+func (s *MailboxSuite) commit(c *C, author, msg, name, content string) {
+	s.commits = append(s.commits, commitPatchFile(c, s.r, len(s.commits), author, msg, name, content))
+}
+
+func (s *MailboxSuite) reset(c *C, i int) {
+	err := s.w.Reset(&ResetOptions{Commit: s.commits[i], Mode: HardReset})
+	c.Assert(err, IsNil)
+}
+
+func (s *MailboxSuite) assertCommit(c *C, h plumbing.Hash, expected plumbing.Hash) {
+	commit, err := s.r.CommitObject(h)
+	c.Assert(err, IsNil)
+
+	original, err := s.r.CommitObject(expected)
+	c.Assert(err, IsNil)
+
+	c.Assert(commit.Message, Equals, original.Message)
+	c.Assert(commit.TreeHash, Equals, original.TreeHash)
+	c.Assert(commit.Author.Name, Equals, original.Author.Name)
+	c.Assert(commit.Author.Email, Equals, original.Author.Email)
+	c.Assert(commit.Author.When.Equal(original.Author.When), Equals, true)
+	c.Assert(commit.Committer.Name, Equals, mailboxCommitter.Name)
+}
+
+func (s *MailboxSuite) TestApplyMailbox(c *C) {
+	mbox := bytes.NewBuffer(nil)
+	err := s.r.FormatPatch("HEAD~2", &FormatPatchOptions{
+		Output:      mbox,
+		CoverLetter: true,
+		Author:      mailboxCommitter,
+		Signature:   "go-git",
+	})
+	c.Assert(err, IsNil)
+
+	s.reset(c, 0)
+
+	commits, err := s.w.ApplyMailbox(mbox, &ApplyMailboxOptions{Committer: mailboxCommitter})
+	c.Assert(err, IsNil)
+	c.Assert(commits, HasLen, 2)
+
+	s.assertCommit(c, commits[0], s.commits[1])
+	s.assertCommit(c, commits[1], s.commits[2])
+
+	head, err := s.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, commits[1])
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *MailboxSuite) TestApplyMailboxNilOptions(c *C) {
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.User.Name = mailboxCommitter.Name
+	cfg.User.Email = mailboxCommitter.Email
+	c.Assert(s.r.SetConfig(cfg), IsNil)
+
+	mbox := bytes.NewBuffer(nil)
+	err = s.r.FormatPatch("HEAD~1", &FormatPatchOptions{Output: mbox})
+	c.Assert(err, IsNil)
+
+	s.reset(c, 1)
+
+	commits, err := s.w.ApplyMailbox(mbox, nil)
+	c.Assert(err, IsNil)
+	c.Assert(commits, HasLen, 1)
+	s.assertCommit(c, commits[0], s.commits[2])
+}
+
+const maildirMessage = `Return-Path: <sender@example.com>
+From: Sender <sender@example.com>
+Date: Fri, 8 Apr 2005 00:00:00 +0000
+Subject: Re: [PATCH v2] change
+ the b line
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: quoted-printable
+
+From: J=C3=B6s=C3=A9 <jose@example.com>
+Date: Thu, 7 Apr 2005 15:13:13 -0700
+
+The b line is changed, and the line is long enough to be encoded as quot=
+ed-printable.
+---
+ a.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++B=3D
+`
+
+func (s *MailboxSuite) TestApplyMaildir(c *C) {
+	s.reset(c, 0)
+
+	dir := memfs.New()
+	c.Assert(util.WriteFile(dir, "cur/2:2,S", []byte(maildirMessage), 0644), IsNil)
+	c.Assert(util.WriteFile(dir, "new/1", []byte("From: A <a@example.com>\n"+
+		"Date: Fri, 8 Apr 2005 00:00:00 +0000\nSubject: [PATCH 0/1] cover\n\nblurb\n"), 0644), IsNil)
+
+	commits, err := s.w.ApplyMaildir(dir, &ApplyMailboxOptions{Committer: mailboxCommitter})
+	c.Assert(err, IsNil)
+	c.Assert(commits, HasLen, 1)
+
+	commit, err := s.r.CommitObject(commits[0])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Name, Equals, "Jösé")
+	c.Assert(commit.Author.Email, Equals, "jose@example.com")
+	c.Assert(commit.Author.When.Unix(), Equals, int64(1112911993))
+	c.Assert(commit.Message, Equals, "change the b line\n\n"+
+		"The b line is changed, and the line is long enough to be encoded as quoted-printable.\n")
+
+	content, err := util.ReadFile(s.fs, "a.txt")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "a\nB=\n")
+
+	s.reset(c, 0)
+	commits, err = s.w.ApplyMaildir(dir, &ApplyMailboxOptions{
+		Committer:   mailboxCommitter,
+		KeepSubject: true,
+	})
+	c.Assert(err, IsNil)
+
+	commit, err = s.r.CommitObject(commits[0])
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(commit.Message, "Re: [PATCH v2] change the b line\n\n"), Equals, true)
+}
+
+func (s *MailboxSuite) TestApplyMailboxErrors(c *C) {
+	mbox := bytes.NewBuffer(nil)
+	err := s.r.FormatPatch("HEAD~2", &FormatPatchOptions{Output: mbox})
+	c.Assert(err, IsNil)
+
+	s.reset(c, 0)
+	c.Assert(util.WriteFile(s.fs, "n.txt", []byte("x\n"), 0644), IsNil)
+	_, err = s.w.Add("n.txt")
+	c.Assert(err, IsNil)
+
+	o := &ApplyMailboxOptions{Committer: mailboxCommitter}
+	_, err = s.w.ApplyMailbox(bytes.NewReader(mbox.Bytes()), o)
+	c.Assert(err, Equals, ErrMailDirtyIndex)
+
+	_, err = s.w.Commit("add n.txt\n", &CommitOptions{Author: mailboxCommitter})
+	c.Assert(err, IsNil)
+
+	commits, err := s.w.ApplyMailbox(bytes.NewReader(mbox.Bytes()), o)
+	c.Assert(errors.Is(err, ErrPatchFileExists), Equals, true)
+	c.Assert(commits, HasLen, 1)
+
+	_, err = s.w.ApplyMailbox(strings.NewReader("not a mailbox\n"), o)
+	c.Assert(err, Equals, ErrMalformedMail)
+
+	_, err = s.w.ApplyMailbox(strings.NewReader("From x\nSubject: no author\n\nbody\n"), o)
+	c.Assert(errors.Is(err, ErrMalformedMail), Equals, true)
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -15,6 +17,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/utils/ioutil"
 	"github.com/go-git/go-git/v5/utils/merkletrie"
 	"github.com/go-git/go-git/v5/utils/merkletrie/filesystem"
@@ -130,6 +133,17 @@ func nameFromAction(ch *merkletrie.Change) string {
 }
 
 func (w *Worktree) diffStagingWithWorktree(reverse, excludeIgnoredChanges bool) (merkletrie.Changes, error) {
+	// the checksum is read first, so the index is not replaced if it's
+	// written while the files are compared
+	var checksum plumbing.Hash
+	cas, canWrite := w.r.Storer.(storer.IndexCheckAndSetStorer)
+	if canWrite {
+		var err error
+		if checksum, err = cas.IndexChecksum(); err != nil {
+			return nil, err
+		}
+	}
+
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return nil, err
@@ -141,7 +155,25 @@ func (w *Worktree) diffStagingWithWorktree(reverse, excludeIgnoredChanges bool)
 		return nil, err
 	}
 
-	to := filesystem.NewRootNode(w.Filesystem, submodules)
+	var modTime time.Time
+	if s, ok := w.r.Storer.(storer.IndexModTimeStorer); ok {
+		if modTime, err = s.IndexModTime(); err != nil {
+			return nil, err
+		}
+	}
+
+	token, trusted := w.queryFileSystemMonitor(idx)
+
+	var refreshed bool
+	to := filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, filesystem.Options{
+		Index:             idx,
+		IndexModTime:      modTime,
+		UntrackedCache:    w.useUntrackedCache(),
+		FileSystemMonitor: trusted,
+		SystemInfo:        fillSystemInfo,
+		OnRefresh:         func(*index.Entry) { refreshed = true },
+		Workers:           runtime.GOMAXPROCS(0),
+	})
 
 	var c merkletrie.Changes
 	if reverse {
@@ -154,12 +186,35 @@ func (w *Worktree) diffStagingWithWorktree(reverse, excludeIgnoredChanges bool)
 		return nil, err
 	}
 
+	if token != "" {
+		updateFileSystemMonitor(idx, token, c)
+		refreshed = true
+	}
+
+	// the refreshed stat data is written, as git does, so the files are not
+	// read again. The write is opportunistic, it's skipped if the index is
+	// locked, was changed since it was read or can't be written
+	if refreshed && canWrite {
+		_ = cas.CheckAndSetIndex(idx, checksum)
+	}
+
 	if excludeIgnoredChanges {
 		return w.excludeIgnoredChanges(c), nil
 	}
 	return c, nil
 }
 
+// useUntrackedCache returns false if the untracked cache of the index should
+// not be used, when core.untrackedCache is false.
+func (w *Worktree) useUntrackedCache() bool {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return false
+	}
+
+	return cfg.Raw.Section("core").Option("untrackedCache") != "false"
+}
+
 func (w *Worktree) excludeIgnoredChanges(changes merkletrie.Changes) merkletrie.Changes {
 	patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
 	if err != nil {
@@ -477,12 +532,18 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string, ignorePatt
 }
 
 func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error) {
+	return w.copyFileToStorer(w.r.Storer, path)
+}
+
+// copyFileToStorer writes the content of a file, or the target of a symlink,
+// as a blob to the given storer.
+func (w *Worktree) copyFileToStorer(s storer.EncodedObjectStorer, path string) (hash plumbing.Hash, err error) {
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
-	obj := w.r.Storer.NewEncodedObject()
+	obj := s.NewEncodedObject()
 	obj.SetType(plumbing.BlobObject)
 	obj.SetSize(fi.Size())
 
@@ -503,7 +564,7 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 		return plumbing.ZeroHash, err
 	}
 
-	return w.r.Storer.SetEncodedObject(obj)
+	return s.SetEncodedObject(obj)
 }
 
 func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, _ os.FileInfo) (err error) {
@@ -541,7 +602,16 @@ func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, filename string, h p
 		return w.doAddFileToIndex(idx, filename, h)
 	}
 
-	return w.doUpdateFileToIndex(e, filename, h)
+	hash, mode := e.Hash, e.Mode
+	if err := w.doUpdateFileToIndex(e, filename, h); err != nil {
+		return err
+	}
+
+	if e.Hash != hash || e.Mode != mode {
+		idx.Cache.Invalidate(e.Name)
+	}
+
+	return nil
 }
 
 func (w *Worktree) doAddFileToIndex(idx *index.Index, filename string, h plumbing.Hash) error {
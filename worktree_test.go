@@ -1386,6 +1386,132 @@ func (s *WorktreeSuite) TestStatusModified(c *C) {
 	c.Assert(status.File(".gitignore").Worktree, Equals, Modified)
 }
 
+func (s *WorktreeSuite) TestStatusRefreshIndex(c *C) {
+	fs := s.TemporalFilesystem(c)
+
+	r, err := PlainInit(fs.Root(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	// same content, other modification time
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err = os.Chtimes(filepath.Join(fs.Root(), "foo"), modTime, modTime)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.ModifiedAt.Equal(modTime), Equals, true)
+}
+
+func (s *WorktreeSuite) TestStatusRefreshIndexLocked(c *C) {
+	fs := s.TemporalFilesystem(c)
+
+	r, err := PlainInit(fs.Root(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err = os.Chtimes(filepath.Join(fs.Root(), "foo"), modTime, modTime)
+	c.Assert(err, IsNil)
+
+	// another process is writing the index
+	err = util.WriteFile(fs, filepath.Join(GitDirName, "index.lock"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	stored, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(stored, DeepEquals, idx)
+}
+
+// indexRaceStorage runs concurrent before writing the index with
+// CheckAndSetIndex, as if the index was written while it's compared with the
+// files.
+type indexRaceStorage struct {
+	*filesystem.Storage
+	concurrent func()
+}
+
+func (s *indexRaceStorage) CheckAndSetIndex(idx *index.Index, old plumbing.Hash) error {
+	s.concurrent()
+	return s.Storage.CheckAndSetIndex(idx, old)
+}
+
+func (s *WorktreeSuite) TestStatusRefreshIndexChanged(c *C) {
+	fs := s.TemporalFilesystem(c)
+
+	r, err := PlainInit(fs.Root(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err = os.Chtimes(filepath.Join(fs.Root(), "foo"), modTime, modTime)
+	c.Assert(err, IsNil)
+
+	r.Storer = &indexRaceStorage{
+		Storage: r.Storer.(*filesystem.Storage),
+		concurrent: func() {
+			other, err := PlainOpen(fs.Root())
+			c.Assert(err, IsNil)
+			ow, err := other.Worktree()
+			c.Assert(err, IsNil)
+
+			err = util.WriteFile(ow.Filesystem, "bar", []byte("bar"), 0644)
+			c.Assert(err, IsNil)
+			_, err = ow.Add("bar")
+			c.Assert(err, IsNil)
+		},
+	}
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	other, err := PlainOpen(fs.Root())
+	c.Assert(err, IsNil)
+	idx, err := other.Storer.Index()
+	c.Assert(err, IsNil)
+	_, err = idx.Entry("bar")
+	c.Assert(err, IsNil)
+}
+
 func (s *WorktreeSuite) TestStatusIgnored(c *C) {
 	fs := memfs.New()
 	w := &Worktree{